@@ -0,0 +1,57 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the current time. Code with expiry or scheduling logic takes a Clock
+// instead of calling time.Now directly so tests can control time.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock reads the wall clock
+type systemClock struct{}
+
+// Now returns the current wall-clock time
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// System returns the real wall clock
+func System() Clock {
+	return systemClock{}
+}
+
+// Mock is a manually controlled clock for tests
+type Mock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewMock creates a mock clock stopped at now
+func NewMock(now time.Time) *Mock {
+	return &Mock{now: now}
+}
+
+// Now returns the mock's current time
+func (m *Mock) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.now
+}
+
+// Set moves the mock clock to t
+func (m *Mock) Set(t time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = t
+}
+
+// Advance moves the mock clock forward by d
+func (m *Mock) Advance(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = m.now.Add(d)
+}
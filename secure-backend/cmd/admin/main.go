@@ -0,0 +1,99 @@
+// Command admin manages admin access without editing the database by hand:
+//
+//	go run ./cmd/admin bootstrap-token
+//	go run ./cmd/admin break-glass -email ops@example.com [-password-stdin]
+//	go run ./cmd/admin break-glass-disable -email ops@example.com
+//
+// bootstrap-token issues a new one-time token for claiming the first admin (POST
+// /api/admin/bootstrap), revoking any unused one. break-glass creates a local-auth emergency
+// admin or resets its password, printing a generated password unless one is read from
+// stdin. Every action is written to the admin audit log. DATABASE_URL must be set.
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"secure-backend/database"
+	"secure-backend/services"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	_ = godotenv.Load()
+
+	if err := database.InitDB(); err != nil {
+		fatalf("failed to connect to database: %v", err)
+	}
+	ctx := context.Background()
+
+	switch command, args := os.Args[1], os.Args[2:]; command {
+	case "bootstrap-token":
+		if exists, err := database.AdminExists(ctx); err != nil {
+			fatalf("%v", err)
+		} else if exists {
+			fatalf("an admin account already exists; bootstrap tokens can no longer be claimed")
+		}
+
+		token, expiresAt, err := services.IssueBootstrapToken(ctx)
+		if err != nil {
+			fatalf("failed to issue bootstrap token: %v", err)
+		}
+		fmt.Printf("Bootstrap token (expires %s):\n%s\n", expiresAt.Format(time.RFC3339), token)
+
+	case "break-glass":
+		fs := flag.NewFlagSet(command, flag.ExitOnError)
+		email := fs.String("email", "", "email of the break-glass account")
+		passwordStdin := fs.Bool("password-stdin", false, "read the password from stdin instead of generating one")
+		fs.Parse(args)
+
+		var password string
+		if *passwordStdin {
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && line == "" {
+				fatalf("failed to read password: %v", err)
+			}
+			password = strings.TrimRight(line, "\r\n")
+		}
+
+		password, err := services.ProvisionBreakGlassAdmin(ctx, *email, password)
+		if err != nil {
+			fatalf("failed to provision break-glass account: %v", err)
+		}
+		fmt.Printf("Break-glass admin %s is ready.\n", strings.ToLower(strings.TrimSpace(*email)))
+		if !*passwordStdin {
+			fmt.Printf("Password (store it offline, it is not shown again):\n%s\n", password)
+		}
+
+	case "break-glass-disable":
+		fs := flag.NewFlagSet(command, flag.ExitOnError)
+		email := fs.String("email", "", "email of the break-glass account")
+		fs.Parse(args)
+
+		if err := services.DisableBreakGlassAdmin(ctx, *email); err != nil {
+			fatalf("failed to disable break-glass account: %v", err)
+		}
+		fmt.Println("Break-glass account disabled.")
+
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: admin bootstrap-token | break-glass -email <email> [-password-stdin] | break-glass-disable -email <email>")
+	os.Exit(2)
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "admin: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,234 @@
+// Command benchgate compares `go test -bench` output against a stored baseline and exits
+// non-zero when a benchmark got slower or allocates more than the baseline allows.
+//
+//	go test -p 1 -run '^$' -bench . -benchmem -count 10 ./... | go run ./cmd/benchgate
+//	go test -p 1 -run '^$' -bench . -benchmem -count 10 ./... | go run ./cmd/benchgate -update
+//
+// With -count > 1 the median of each metric is compared, which keeps single noisy runs
+// from failing the gate. Baselines are machine specific: refresh them with -update on the
+// machine that runs the gate.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Result holds the median metrics of one benchmark
+type Result struct {
+	NsPerOp     float64
+	BytesPerOp  float64
+	AllocsPerOp float64
+}
+
+// benchLine matches a benchmark result line, e.g.
+// "BenchmarkGetCartItems-8   1000   123456 ns/op   2048 B/op   17 allocs/op"
+var benchLine = regexp.MustCompile(`^(Benchmark\S+?)(?:-\d+)?\s+\d+\s+(.*)$`)
+
+// Parse reads `go test -bench` output and returns the median metrics per benchmark, keyed
+// by package and name, plus the benchmark lines themselves (for writing a new baseline).
+// A failing package makes the whole run fail.
+func Parse(r io.Reader) (map[string]Result, []string, error) {
+	samples := map[string][]Result{}
+	var lines []string
+	pkg, pkgLine := "", ""
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "pkg: "):
+			pkg, pkgLine = strings.TrimPrefix(line, "pkg: "), line
+		case strings.HasPrefix(line, "FAIL") || strings.HasPrefix(line, "--- FAIL"):
+			return nil, nil, fmt.Errorf("benchmark run failed: %s", line)
+		}
+
+		m := benchLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		result, ok := parseMetrics(m[2])
+		if !ok {
+			continue
+		}
+		key := m[1]
+		if pkg != "" {
+			key = pkg + "." + m[1]
+		}
+		samples[key] = append(samples[key], result)
+		if pkgLine != "" {
+			// Only keep headers of packages that have benchmarks
+			lines = append(lines, pkgLine)
+			pkgLine = ""
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	results := make(map[string]Result, len(samples))
+	for name, runs := range samples {
+		results[name] = Result{
+			NsPerOp:     median(runs, func(r Result) float64 { return r.NsPerOp }),
+			BytesPerOp:  median(runs, func(r Result) float64 { return r.BytesPerOp }),
+			AllocsPerOp: median(runs, func(r Result) float64 { return r.AllocsPerOp }),
+		}
+	}
+	return results, lines, nil
+}
+
+// parseMetrics reads the "value unit" pairs after the iteration count
+func parseMetrics(s string) (Result, bool) {
+	var result Result
+	fields := strings.Fields(s)
+	seen := false
+	for i := 0; i+1 < len(fields); i += 2 {
+		value, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return result, false
+		}
+		switch fields[i+1] {
+		case "ns/op":
+			result.NsPerOp, seen = value, true
+		case "B/op":
+			result.BytesPerOp = value
+		case "allocs/op":
+			result.AllocsPerOp = value
+		}
+	}
+	return result, seen
+}
+
+func median(runs []Result, metric func(Result) float64) float64 {
+	values := make([]float64, len(runs))
+	for i, r := range runs {
+		values[i] = metric(r)
+	}
+	sort.Float64s(values)
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
+
+// Regression describes a benchmark that got worse than the baseline allows
+type Regression struct {
+	Name   string
+	Metric string
+	Old    float64
+	New    float64
+}
+
+// Compare returns the benchmarks whose time grew by more than maxSlowdown (0.2 = 20%)
+// or whose allocations per op grew at all
+func Compare(baseline, current map[string]Result, maxSlowdown float64) []Regression {
+	var regressions []Regression
+	for _, name := range sortedNames(current) {
+		old, ok := baseline[name]
+		if !ok {
+			continue
+		}
+		now := current[name]
+		if old.NsPerOp > 0 && now.NsPerOp > old.NsPerOp*(1+maxSlowdown) {
+			regressions = append(regressions, Regression{name, "ns/op", old.NsPerOp, now.NsPerOp})
+		}
+		if now.AllocsPerOp > old.AllocsPerOp {
+			regressions = append(regressions, Regression{name, "allocs/op", old.AllocsPerOp, now.AllocsPerOp})
+		}
+	}
+	return regressions
+}
+
+func sortedNames(results map[string]Result) []string {
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// delta formats the relative change from old to new
+func delta(old, new float64) string {
+	if old == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.1f%%", (new-old)/old*100)
+}
+
+func main() {
+	baselinePath := flag.String("baseline", "benchmarks/baseline.txt", "baseline file (go test -bench output)")
+	maxSlowdown := flag.Float64("max-slowdown", 0.25, "allowed relative slowdown in ns/op before failing")
+	update := flag.Bool("update", false, "write the benchmark results read from stdin as the new baseline")
+	flag.Parse()
+
+	current, lines, err := Parse(os.Stdin)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if len(current) == 0 {
+		fmt.Fprintln(os.Stderr, "no benchmark results on stdin")
+		os.Exit(1)
+	}
+
+	if *update {
+		data := strings.Join(lines, "\n") + "\n"
+		if err := os.WriteFile(*baselinePath, []byte(data), 0o644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote baseline for %d benchmarks to %s\n", len(current), *baselinePath)
+		return
+	}
+
+	file, err := os.Open(*baselinePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open baseline: %v (create one with -update)\n", err)
+		os.Exit(1)
+	}
+	baseline, _, err := Parse(file)
+	file.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read baseline: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%-70s %14s %14s %9s %9s\n", "benchmark", "old ns/op", "new ns/op", "delta", "allocs")
+	for _, name := range sortedNames(current) {
+		now := current[name]
+		old, ok := baseline[name]
+		if !ok {
+			fmt.Printf("%-70s %14s %14.0f %9s %9.0f\n", name, "-", now.NsPerOp, "new", now.AllocsPerOp)
+			continue
+		}
+		fmt.Printf("%-70s %14.0f %14.0f %9s %9s\n", name, old.NsPerOp, now.NsPerOp,
+			delta(old.NsPerOp, now.NsPerOp), delta(old.AllocsPerOp, now.AllocsPerOp))
+	}
+	for _, name := range sortedNames(baseline) {
+		if _, ok := current[name]; !ok {
+			fmt.Printf("%-70s missing from this run\n", name)
+		}
+	}
+
+	regressions := Compare(baseline, current, *maxSlowdown)
+	if len(regressions) == 0 {
+		fmt.Println("\nNo performance regressions.")
+		return
+	}
+
+	fmt.Printf("\n%d performance regression(s):\n", len(regressions))
+	for _, r := range regressions {
+		fmt.Printf("  %s: %s %.0f -> %.0f (%s)\n", r.Name, r.Metric, r.Old, r.New, delta(r.Old, r.New))
+	}
+	os.Exit(1)
+}
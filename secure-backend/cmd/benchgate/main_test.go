@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleOutput = `goos: linux
+goarch: amd64
+pkg: secure-backend/middleware
+BenchmarkAuth-8   	  100000	      1000 ns/op	    2936 B/op	      46 allocs/op
+BenchmarkAuth-8   	  100000	      1400 ns/op	    2936 B/op	      46 allocs/op
+BenchmarkAuth-8   	  100000	      1100 ns/op	    2936 B/op	      46 allocs/op
+pkg: secure-backend/database
+BenchmarkPage/offset=200-8   	  5000	    250000 ns/op	   9000 B/op	     120 allocs/op
+PASS
+ok  	secure-backend/database	2.1s
+`
+
+func TestParseTakesMedians(t *testing.T) {
+	results, lines, err := Parse(strings.NewReader(sampleOutput))
+	require.NoError(t, err)
+
+	assert.Equal(t, Result{NsPerOp: 1100, BytesPerOp: 2936, AllocsPerOp: 46}, results["secure-backend/middleware.BenchmarkAuth"])
+	assert.Equal(t, 250000.0, results["secure-backend/database.BenchmarkPage/offset=200"].NsPerOp)
+	assert.Len(t, lines, 6)
+}
+
+func TestParseRejectsFailedRuns(t *testing.T) {
+	_, _, err := Parse(strings.NewReader(sampleOutput + "FAIL\tsecure-backend/handlers\t0.01s\n"))
+	assert.Error(t, err)
+}
+
+func TestCompare(t *testing.T) {
+	baseline := map[string]Result{
+		"a": {NsPerOp: 1000, AllocsPerOp: 10},
+		"b": {NsPerOp: 1000, AllocsPerOp: 10},
+		"c": {NsPerOp: 1000, AllocsPerOp: 10},
+	}
+	current := map[string]Result{
+		"a": {NsPerOp: 1150, AllocsPerOp: 10}, // within the allowed slowdown
+		"b": {NsPerOp: 1300, AllocsPerOp: 10}, // too slow
+		"c": {NsPerOp: 900, AllocsPerOp: 11},  // faster but allocates more
+		"d": {NsPerOp: 5000, AllocsPerOp: 50}, // new benchmark, no baseline
+	}
+
+	regressions := Compare(baseline, current, 0.2)
+	require.Len(t, regressions, 2)
+	assert.Equal(t, Regression{"b", "ns/op", 1000, 1300}, regressions[0])
+	assert.Equal(t, Regression{"c", "allocs/op", 10, 11}, regressions[1])
+}
@@ -0,0 +1,230 @@
+// Package config lists the environment variables the API reads and reports the effective
+// value of each with where it came from: the process environment, the .env file or the
+// built-in default. Secrets are masked, so the report can be compared across environments
+// when a setup works locally but not in staging.
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/joho/godotenv"
+)
+
+// Value sources
+const (
+	SourceEnv     = "env"     // set in the process environment
+	SourceFile    = "file"    // loaded from the .env file
+	SourceDefault = "default" // not set; the built-in default applies
+)
+
+// Setting describes one environment variable read by the API
+type Setting struct {
+	Name        string
+	Default     string // effective value when unset, described in words where it is derived
+	Secret      bool   // never shown; only whether it is set and a fingerprint
+	Description string
+}
+
+// settings are the variables the API reads, grouped by area. Add new variables here so
+// they show up in the effective configuration.
+var settings = []Setting{
+	// Server
+	{Name: "PORT", Default: "8080", Description: "HTTP port"},
+	{Name: "GIN_MODE", Default: "release", Description: "Gin mode; CORS uses ALLOWED_ORIGINS only when it is set to release explicitly"},
+	{Name: "ALLOWED_ORIGINS", Default: "http://localhost:3000,http://localhost:5173 outside release mode", Description: "CORS origins in release mode (comma-separated)"},
+	{Name: "PUBLIC_API_URL", Description: "Base URL of signed links to the API"},
+	{Name: "DEBUG_ALLOWED_IPS", Description: "Addresses or CIDRs admins can use the pprof and runtime debug endpoints from; off when unset"},
+	{Name: "LOG_LEVEL", Default: "info", Description: "Minimum level logged: debug, info, warn or error"},
+
+	// Database and auth
+	{Name: "DATABASE_URL", Description: "Postgres connection string (password masked)"},
+	{Name: "DATABASE_REPLICA_URL", Description: "Read replica connection string for product listing, search and cart reads (password masked; unset reads the primary)"},
+	{Name: "DATABASE_REPLICA_MAX_LAG", Default: "5s", Description: "Replication lag beyond which the read replica is taken out of use; users who wrote within it read the primary"},
+	{Name: "DB_SLOW_QUERY_THRESHOLD", Default: "200ms", Description: "Duration from which statements are logged as slow queries (0 off)"},
+	{Name: "DB_STATEMENT_TIMEOUT", Default: "30s", Description: "Longest a statement may run before Postgres cancels it (0 no limit)"},
+	{Name: "DB_AUTO_MIGRATE", Default: "true", Description: "Apply pending schema migrations on startup; false when deploys run `migrate` instead"},
+	{Name: "SUPABASE_URL", Description: "Supabase project URL, for its JWKS"},
+	{Name: "SUPABASE_JWKS_URL", Default: "SUPABASE_URL/auth/v1/.well-known/jwks.json", Description: "Key set verifying RS256/ES256 tokens"},
+	{Name: "SUPABASE_JWT_SECRET", Secret: true, Description: "Shared secret verifying HS256 tokens"},
+	{Name: "ROLE_CACHE_TTL", Default: "1m0s", Description: "How long user roles and token revocations are cached"},
+	{Name: "SESSION_MODE", Default: "bearer", Description: "cookie enables server-side cookie sessions"},
+	{Name: "SESSION_COOKIE_KEY", Secret: true, Description: "Key encrypting session cookies (32 bytes, hex or base64)"},
+	{Name: "SESSION_COOKIE_SECURE", Default: "true", Description: "Secure flag of session cookies"},
+	{Name: "SESSION_TTL", Default: "12h0m0s", Description: "Session lifetime"},
+
+	// Signed tokens
+	{Name: "TOKEN_SIGNING_KEYS", Secret: true, Description: "Token signing keys (kid:secret,...; the first signs)"},
+	{Name: "EXPORT_SIGNING_SECRET", Secret: true, Description: "Single signing key used without TOKEN_SIGNING_KEYS"},
+	{Name: "VAULT_ADDR", Description: "Vault server holding the token signing keys"},
+	{Name: "VAULT_TOKEN", Secret: true, Description: "Vault token"},
+	{Name: "TOKEN_KEYS_VAULT_PATH", Description: "Vault path of the token signing keys"},
+
+	// Payments
+	{Name: "STRIPE_SECRET_KEY", Secret: true, Description: "Stripe API key; payments are off without it"},
+	{Name: "STRIPE_WEBHOOK_SECRET", Secret: true, Description: "Stripe webhook signing secret"},
+	{Name: "STRIPE_CURRENCY", Default: "usd", Description: "Currency of charges"},
+	{Name: "PAYMENT_RETRY_DELAYS", Default: "1h,6h,24h", Description: "Waits before each retry of a failed payment"},
+
+	// Cart and checkout
+	{Name: "CART_MAX_UNITS", Default: "500", Description: "Most units a cart can hold"},
+	{Name: "CART_ABANDON_AFTER_DAYS", Default: "7", Description: "Idle days before a cart counts as abandoned"},
+	{Name: "CART_ABANDON_RELEASE_STOCK", Default: "false", Description: "Cancel pending checkouts of abandoned carts"},
+	{Name: "CHECKOUT_RESERVATION_TTL", Default: "15m0s", Description: "How long checkout holds stock"},
+	{Name: "ORDER_ADDRESS_EDIT_WINDOW", Default: "1h0m0s", Description: "How long buyers can edit an order's address"},
+	{Name: "MODERATION_BLOCKED_WORDS", Description: "Words masked in buyer-seller messages in addition to the built-in list (comma-separated)"},
+	{Name: "SHIPPING_FLAT_RATE", Default: "0", Description: "Shipping charged below the free shipping threshold"},
+	{Name: "FREE_SHIPPING_THRESHOLD", Default: "0", Description: "Subtotal from which shipping is free (0 never)"},
+
+	// Invoices and tax
+	{Name: "INVOICE_ISSUER_NAME", Default: "SecureShop", Description: "Seller name on invoices"},
+	{Name: "INVOICE_ISSUER_ADDRESS", Description: "Seller address on invoices"},
+	{Name: "INVOICE_ISSUER_TAX_ID", Description: "Seller tax ID on invoices"},
+	{Name: "INVOICE_TAX_RATE", Default: "0", Description: "Tax rate included in prices"},
+	{Name: "TAX_JURISDICTION", Default: "default", Description: "Jurisdiction of recorded tax lines"},
+
+	// Storage, jobs and exports
+	{Name: "S3_BUCKET", Description: "Bucket of product images; image uploads are off without it"},
+	{Name: "S3_REGION", Default: "us-east-1", Description: "Bucket region"},
+	{Name: "S3_ENDPOINT", Default: "https://s3.S3_REGION.amazonaws.com", Description: "S3-compatible endpoint"},
+	{Name: "S3_PUBLIC_URL", Description: "Public base URL of stored images"},
+	{Name: "S3_ACCESS_KEY_ID", Secret: true, Description: "S3 access key ID"},
+	{Name: "S3_SECRET_ACCESS_KEY", Secret: true, Description: "S3 secret access key"},
+	{Name: "JOB_WORKERS", Default: "2", Description: "Background job workers"},
+	{Name: "EXPORT_DIR", Default: "a secureshop-exports temp directory", Description: "Directory of job results"},
+
+	// Shipping labels
+	{Name: "SHIPPING_LABEL_API_URL", Description: "Label provider API; label purchase is off without it (also needs S3_BUCKET)"},
+	{Name: "SHIPPING_LABEL_API_KEY", Secret: true, Description: "Label provider API key"},
+	{Name: "SHIPPING_LABEL_SERVICE", Description: "Service level labels are bought with unless the seller picks one"},
+
+	// Push notifications
+	{Name: "FCM_CREDENTIALS_FILE", Description: "Firebase service account file; Android push is off without it"},
+	{Name: "APNS_KEY_FILE", Description: "APNs signing key file; iOS push is off without it"},
+	{Name: "APNS_KEY_ID", Description: "APNs key ID"},
+	{Name: "APNS_TEAM_ID", Description: "Apple team ID"},
+	{Name: "APNS_TOPIC", Description: "App bundle ID"},
+	{Name: "APNS_PRODUCTION", Default: "false", Description: "Use the production APNs gateway"},
+
+	// Email and privacy
+	{Name: "SMTP_HOST", Description: "SMTP server; email is off without it"},
+	{Name: "SMTP_PORT", Default: "587", Description: "SMTP port"},
+	{Name: "SMTP_USERNAME", Description: "SMTP user"},
+	{Name: "SMTP_PASSWORD", Secret: true, Description: "SMTP password"},
+	{Name: "MAIL_FROM", Description: "Sender address of emails"},
+	{Name: "ERASURE_CONFIRM_URL", Description: "Page confirming account erasure requests"},
+	{Name: "ERASURE_GRACE_PERIOD", Default: "336h0m0s", Description: "Wait between confirming and carrying out an erasure"},
+	{Name: "IP_HASH_SECRET", Secret: true, Description: "Key of the IP address hashes stored with consent"},
+
+	// Demo mode
+	{Name: "DEMO_MODE", Default: "false", Description: "Seed demo accounts and a sample catalog, reset nightly, and allow demo sessions"},
+	{Name: "DEMO_RESET_HOUR", Default: "3", Description: "Hour (UTC) demo data is reset at"},
+
+	// Security events, tracing and error reporting
+	{Name: "SECURITY_ALERT_WEBHOOK_URL", Secret: true, Description: "Webhook receiving high-severity security events"},
+	{Name: "SECURITY_ALERT_WEBHOOK_SECRET", Secret: true, Description: "Key signing security alerts"},
+	{Name: "SECURITY_ALERT_WEBHOOK_VERSION", Default: "v1", Description: "Contract version of alert bodies (v1 or v2)"},
+	{Name: "SECURITY_EVENT_RETENTION", Default: "8760h0m0s", Description: "How long security events are kept"},
+	{Name: "REQUEST_AUDIT_ROUTES", Description: "Route prefixes whose requests and responses are recorded, redacted, in the request audit log (comma-separated); off when unset"},
+	{Name: "REQUEST_AUDIT_RETENTION", Default: "2160h0m0s", Description: "How long request audit entries are kept"},
+	{Name: "OTEL_EXPORTER_OTLP_ENDPOINT", Description: "OTLP/HTTP collector; tracing is off without it"},
+	{Name: "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", Default: "OTEL_EXPORTER_OTLP_ENDPOINT/v1/traces", Description: "Full URL traces are exported to"},
+	{Name: "OTEL_EXPORTER_OTLP_HEADERS", Secret: true, Description: "Headers of export requests, such as API keys"},
+	{Name: "OTEL_SERVICE_NAME", Default: "secure-backend", Description: "Service name in traces"},
+	{Name: "OTEL_TRACES_SAMPLER_ARG", Default: "1", Description: "Share of new traces recorded"},
+	{Name: "SENTRY_DSN", Secret: true, Description: "Sentry project DSN; panics and 5xx responses are reported to it when set"},
+	{Name: "SENTRY_ENVIRONMENT", Description: "Environment tag of error reports"},
+	{Name: "SENTRY_RELEASE", Description: "Release tag of error reports"},
+}
+
+// Settings returns the variables the API reads
+func Settings() []Setting {
+	return append([]Setting(nil), settings...)
+}
+
+var (
+	mu       sync.RWMutex
+	fromFile = map[string]bool{}
+)
+
+// LoadFile loads variables from the .env file (or the given files) that aren't already set in
+// the environment, like godotenv.Load, and remembers which ones came from it
+func LoadFile(filenames ...string) error {
+	values, err := godotenv.Read(filenames...)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for name, value := range values {
+		if _, set := os.LookupEnv(name); set {
+			continue
+		}
+		if err := os.Setenv(name, value); err != nil {
+			return err
+		}
+		fromFile[name] = true
+	}
+	return nil
+}
+
+// Value is the effective value of a setting
+type Value struct {
+	Name        string `json:"name"`
+	Value       string `json:"value"`
+	Source      string `json:"source"`
+	Secret      bool   `json:"secret,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"` // of a set secret, to tell whether two environments share it
+	Description string `json:"description"`
+}
+
+// maskedValue stands in for set secrets
+const maskedValue = "********"
+
+// Effective returns the value and source of every setting, in the order of Settings. Set
+// secrets show as ******** with the first 8 hex digits of their SHA-256, and passwords in
+// URLs show as xxxxx.
+func Effective() []Value {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	values := make([]Value, 0, len(settings))
+	for _, setting := range settings {
+		v := Value{Name: setting.Name, Secret: setting.Secret, Description: setting.Description}
+		raw, set := os.LookupEnv(setting.Name)
+		switch {
+		case !set || raw == "":
+			v.Source = SourceDefault
+			v.Value = setting.Default
+			values = append(values, v)
+			continue
+		case fromFile[setting.Name]:
+			v.Source = SourceFile
+		default:
+			v.Source = SourceEnv
+		}
+
+		if setting.Secret {
+			sum := sha256.Sum256([]byte(raw))
+			v.Value = maskedValue
+			v.Fingerprint = hex.EncodeToString(sum[:4])
+		} else {
+			v.Value = maskURLPassword(raw)
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+// maskURLPassword hides the password of a URL such as a connection string
+func maskURLPassword(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return value
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"secure-backend/models"
+	"time"
+)
+
+// MarkAbandonedCarts marks up to limit carts whose last change was before cutoff as abandoned
+// and records an abandonment for each. Only carts with items or an unpaid checkout holding
+// stock count; a cart is recorded once per cart version, and the next change revives it.
+func MarkAbandonedCarts(ctx context.Context, cutoff, now time.Time, limit int) ([]models.CartAbandonment, error) {
+	abandonments := []models.CartAbandonment{}
+	err := DB.SelectContext(ctx, &abandonments, `
+		WITH idle AS (
+			SELECT v.user_id, v.version, v.updated_at
+			FROM cart_versions v
+			WHERE v.abandoned_at IS NULL AND v.updated_at < $1
+				AND (
+					EXISTS (SELECT 1 FROM cart_items ci WHERE ci.user_id = v.user_id)
+					OR EXISTS (
+						SELECT 1 FROM orders o JOIN stock_reservations r ON r.order_id = o.id
+						WHERE o.buyer_id = v.user_id AND o.status = 'pending' AND r.status = 'active'
+					)
+				)
+			ORDER BY v.updated_at
+			LIMIT $2
+			FOR UPDATE OF v SKIP LOCKED
+		), marked AS (
+			UPDATE cart_versions v SET abandoned_at = $3
+			FROM idle
+			WHERE v.user_id = idle.user_id
+			RETURNING idle.user_id, idle.version, idle.updated_at
+		)
+		INSERT INTO cart_abandonments (user_id, cart_version, item_count, subtotal, last_activity_at)
+		SELECT m.user_id, m.version, COALESCE(SUM(ci.quantity), 0), COALESCE(SUM(ci.quantity * p.price), 0), m.updated_at
+		FROM marked m
+		LEFT JOIN cart_items ci ON ci.user_id = m.user_id
+		LEFT JOIN products p ON p.id = ci.product_id
+		GROUP BY m.user_id, m.version, m.updated_at
+		ON CONFLICT (user_id, cart_version) DO NOTHING
+		RETURNING id, user_id, cart_version, item_count, subtotal, last_activity_at, released_orders, notified_at, created_at
+	`, cutoff, limit, now)
+	return abandonments, err
+}
+
+// GetPendingCheckoutOrders returns the buyer's unpaid orders that still hold reserved stock
+func GetPendingCheckoutOrders(ctx context.Context, buyerID string) ([]string, error) {
+	var orderIDs []string
+	err := DB.SelectContext(ctx, &orderIDs, `
+		SELECT DISTINCT o.id
+		FROM orders o
+		JOIN stock_reservations r ON r.order_id = o.id
+		WHERE o.buyer_id = $1 AND o.status = 'pending' AND r.status = 'active'
+	`, buyerID)
+	return orderIDs, err
+}
+
+// SetCartAbandonmentReleasedOrders records how many unpaid checkouts were cancelled for an abandonment
+func SetCartAbandonmentReleasedOrders(ctx context.Context, abandonmentID string, released int) error {
+	_, err := DB.ExecContext(ctx, `UPDATE cart_abandonments SET released_orders = $2 WHERE id = $1`, abandonmentID, released)
+	return err
+}
+
+// GetCartAbandonments returns a page of abandonments recorded within a time range (newest
+// first) and their total count, with whether each user consents to marketing. With
+// marketingOnly, abandonments of users who don't are left out.
+func GetCartAbandonments(ctx context.Context, from, to time.Time, marketingOnly bool, limit, offset int) ([]models.CartAbandonment, int, error) {
+	marketing := consentSQL("marketing", "a.user_id")
+
+	var total int
+	err := DB.GetContext(ctx, &total, `
+		SELECT COUNT(*) FROM cart_abandonments a
+		WHERE a.created_at >= $1 AND a.created_at < $2 AND (NOT $3 OR `+marketing+`)
+	`, from, to, marketingOnly)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	abandonments := []models.CartAbandonment{}
+	err = DB.SelectContext(ctx, &abandonments, `
+		SELECT a.id, a.user_id, a.cart_version, a.item_count, a.subtotal, a.last_activity_at, a.released_orders,
+			a.notified_at, a.created_at, `+marketing+` AS marketing_consent
+		FROM cart_abandonments a
+		WHERE a.created_at >= $1 AND a.created_at < $2 AND (NOT $3 OR `+marketing+`)
+		ORDER BY a.created_at DESC
+		LIMIT $4 OFFSET $5
+	`, from, to, marketingOnly, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return abandonments, total, nil
+}
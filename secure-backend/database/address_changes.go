@@ -0,0 +1,182 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"secure-backend/models"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrAddressChangePending is returned when the order already has an address change
+// waiting for support
+var ErrAddressChangePending = errors.New("an address change for this order is already waiting for support")
+
+// ErrAddressChangeResolved is returned when resolving an address change that isn't waiting for support
+var ErrAddressChangeResolved = errors.New("address change was already resolved")
+
+const addressChangeColumns = `id, order_id, COALESCE(requested_by::text, '') AS requested_by, old_address, new_address,
+	status, reason, resolved_by, resolution_note, resolved_at, created_at`
+
+// ChangeOrderAddress applies a buyer's new shipping address when window allows a
+// self-service edit, or records it as a request for support otherwise. The order is locked
+// so the decision can't race with fulfillment. Returns sql.ErrNoRows if the buyer has no
+// such order and models.ErrAddressLocked if the order is closed.
+func ChangeOrderAddress(ctx context.Context, orderID, buyerID, address string, window time.Duration, now time.Time) (*models.OrderAddressChange, error) {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var order models.Order
+	err = tx.GetContext(ctx, &order, `
+		SELECT id, buyer_id, status, total_amount, COALESCE(shipping_address, '') AS shipping_address, client_platform, created_at, updated_at
+		FROM orders
+		WHERE id = $1 AND buyer_id = $2
+		FOR UPDATE
+	`, orderID, buyerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var fulfillmentStarted bool
+	err = tx.GetContext(ctx, &fulfillmentStarted, `
+		SELECT EXISTS (SELECT 1 FROM order_items WHERE order_id = $1 AND fulfillment_status <> 'pending')
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	reason, err := models.AddressEditDecision(order, fulfillmentStarted, window, now)
+	if err != nil {
+		return nil, err
+	}
+
+	status := models.AddressChangeApplied
+	if reason != "" {
+		status = models.AddressChangeRequested
+	} else if err := setOrderAddress(ctx, tx, orderID, address); err != nil {
+		return nil, err
+	}
+
+	var change models.OrderAddressChange
+	err = tx.GetContext(ctx, &change, `
+		INSERT INTO order_address_changes (order_id, requested_by, old_address, new_address, status, reason)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+addressChangeColumns+`
+	`, orderID, buyerID, order.ShippingAddress, address, status, reason)
+	if hasErrorCode(err, uniqueViolation) {
+		return nil, ErrAddressChangePending
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &change, tx.Commit()
+}
+
+// setOrderAddress stores an order's shipping address
+func setOrderAddress(ctx context.Context, q sqlx.ExecerContext, orderID, address string) error {
+	_, err := q.ExecContext(ctx, `UPDATE orders SET shipping_address = $2, updated_at = now() WHERE id = $1`, orderID, address)
+	return err
+}
+
+// GetOrderAddressChanges returns the address change history of an order, oldest first
+func GetOrderAddressChanges(ctx context.Context, orderID string) ([]models.OrderAddressChange, error) {
+	changes := []models.OrderAddressChange{}
+	err := DB.SelectContext(ctx, &changes, `
+		SELECT `+addressChangeColumns+`
+		FROM order_address_changes
+		WHERE order_id = $1
+		ORDER BY created_at
+	`, orderID)
+	return changes, err
+}
+
+// GetAddressChangeRequests returns a page of address changes with the given status, oldest
+// first, and the total count
+func GetAddressChangeRequests(ctx context.Context, status string, limit, offset int) ([]models.OrderAddressChange, int, error) {
+	var total int
+	err := DB.GetContext(ctx, &total, `SELECT COUNT(*) FROM order_address_changes WHERE status = $1`, status)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	changes := []models.OrderAddressChange{}
+	err = DB.SelectContext(ctx, &changes, `
+		SELECT `+addressChangeColumns+`
+		FROM order_address_changes
+		WHERE status = $1
+		ORDER BY created_at
+		LIMIT $2 OFFSET $3
+	`, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return changes, total, nil
+}
+
+// ResolveAddressChange records support's decision on an address change request. Approving
+// applies the requested address unless the order has since been closed. The decision is
+// written to the admin audit log in the same transaction. Returns sql.ErrNoRows if the
+// request doesn't exist and ErrAddressChangeResolved if it was already resolved.
+func ResolveAddressChange(ctx context.Context, id, status, note string, audit *models.AuditEntry) (*models.OrderAddressChange, error) {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var change models.OrderAddressChange
+	err = tx.GetContext(ctx, &change, `SELECT `+addressChangeColumns+` FROM order_address_changes WHERE id = $1 FOR UPDATE`, id)
+	if err != nil {
+		return nil, err
+	}
+	if change.Status != models.AddressChangeRequested {
+		return nil, ErrAddressChangeResolved
+	}
+
+	if status == models.AddressChangeApproved {
+		var orderStatus string
+		err := tx.GetContext(ctx, &orderStatus, `SELECT status FROM orders WHERE id = $1 FOR UPDATE`, change.OrderID)
+		if err != nil {
+			return nil, err
+		}
+		if orderStatus == "cancelled" || orderStatus == "refunded" || orderStatus == "delivered" {
+			return nil, models.ErrAddressLocked
+		}
+		if err := setOrderAddress(ctx, tx, change.OrderID, change.NewAddress); err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.GetContext(ctx, &change, `
+		UPDATE order_address_changes
+		SET status = $2, resolution_note = $3, resolved_by = $4, resolved_at = now()
+		WHERE id = $1
+		RETURNING `+addressChangeColumns+`
+	`, id, status, note, audit.ActorID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordAdminAudit(ctx, tx, audit); err != nil {
+		return nil, err
+	}
+
+	return &change, tx.Commit()
+}
+
+// GetOrderSellerIDs returns the sellers with items in an order
+func GetOrderSellerIDs(ctx context.Context, orderID string) ([]string, error) {
+	sellerIDs := []string{}
+	err := DB.SelectContext(ctx, &sellerIDs, `
+		SELECT DISTINCT p.seller_id
+		FROM order_items oi
+		JOIN products p ON p.id = oi.product_id
+		WHERE oi.order_id = $1
+	`, orderID)
+	return sellerIDs, err
+}
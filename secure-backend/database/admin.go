@@ -0,0 +1,273 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"secure-backend/models"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	// ErrInvalidBootstrapToken is returned for bootstrap tokens that are unknown, used or expired
+	ErrInvalidBootstrapToken = errors.New("invalid or expired bootstrap token")
+	// ErrAdminExists is returned when claiming the bootstrap token after an admin was created
+	ErrAdminExists = errors.New("an admin account already exists")
+	// ErrNotBreakGlass is returned when provisioning a break-glass account over a regular user
+	ErrNotBreakGlass = errors.New("email belongs to a regular (Supabase) account")
+)
+
+// RecordAdminAudit appends an entry to the admin audit log
+func RecordAdminAudit(ctx context.Context, entry *models.AuditEntry) error {
+	return recordAdminAudit(ctx, DB, entry)
+}
+
+// recordAdminAudit appends an audit entry using the given connection or transaction
+func recordAdminAudit(ctx context.Context, q sqlx.ExecerContext, entry *models.AuditEntry) error {
+	_, err := q.ExecContext(ctx, `
+		INSERT INTO admin_audit_log (actor_id, actor_email, action, detail, ip_address)
+		VALUES ($1, $2, $3, $4, $5)
+	`, entry.ActorID, entry.ActorEmail, entry.Action, entry.Detail, entry.IPAddress)
+	return err
+}
+
+// GetAdminAuditLog returns a page of audit entries (newest first) and the total count.
+// An empty action matches every action.
+func GetAdminAuditLog(ctx context.Context, action string, limit, offset int) ([]models.AuditEntry, int, error) {
+	var total int
+	err := DB.GetContext(ctx, &total, `SELECT COUNT(*) FROM admin_audit_log WHERE ($1 = '' OR action = $1)`, action)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	entries := []models.AuditEntry{}
+	err = DB.SelectContext(ctx, &entries, `
+		SELECT id, actor_id, actor_email, action, detail, ip_address, created_at
+		FROM admin_audit_log
+		WHERE ($1 = '' OR action = $1)
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`, action, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return entries, total, nil
+}
+
+// AdminExists reports whether any user has the admin role
+func AdminExists(ctx context.Context) (bool, error) {
+	var exists bool
+	err := DB.GetContext(ctx, &exists, `SELECT EXISTS (SELECT 1 FROM users WHERE role = 'admin')`)
+	return exists, err
+}
+
+// GetAdminIDs returns the users with the admin role, except break-glass accounts
+func GetAdminIDs(ctx context.Context) ([]string, error) {
+	ids := []string{}
+	err := DB.SelectContext(ctx, &ids, `SELECT id FROM users WHERE role = 'admin' AND NOT break_glass ORDER BY created_at`)
+	return ids, err
+}
+
+// HasLiveBootstrapToken reports whether an unused, unexpired bootstrap token exists
+func HasLiveBootstrapToken(ctx context.Context, now time.Time) (bool, error) {
+	var exists bool
+	err := DB.GetContext(ctx, &exists, `
+		SELECT EXISTS (SELECT 1 FROM admin_bootstrap_tokens WHERE used_at IS NULL AND expires_at > $1)
+	`, now)
+	return exists, err
+}
+
+// CreateBootstrapToken stores the hash of a new bootstrap token, revoking any unused ones
+// so only the most recently printed token works
+func CreateBootstrapToken(ctx context.Context, tokenHash string, expiresAt, now time.Time) error {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE admin_bootstrap_tokens SET expires_at = $1 WHERE used_at IS NULL AND expires_at > $1
+	`, now)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO admin_bootstrap_tokens (token_hash, expires_at) VALUES ($1, $2)
+	`, tokenHash, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	err = recordAdminAudit(ctx, tx, &models.AuditEntry{
+		Action: models.AuditBootstrapIssued,
+		Detail: "expires " + expiresAt.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ClaimAdminBootstrap makes the user an admin if tokenHash matches a live bootstrap token and
+// no admin exists yet, marking the token used. The claim is audited in the same transaction.
+func ClaimAdminBootstrap(ctx context.Context, tokenHash string, user *models.AuthUser, ipAddress string, now time.Time) error {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var tokenID string
+	err = tx.GetContext(ctx, &tokenID, `
+		SELECT id FROM admin_bootstrap_tokens
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+		FOR UPDATE
+	`, tokenHash, now)
+	if err == sql.ErrNoRows {
+		return ErrInvalidBootstrapToken
+	} else if err != nil {
+		return err
+	}
+
+	var adminExists bool
+	if err := tx.GetContext(ctx, &adminExists, `SELECT EXISTS (SELECT 1 FROM users WHERE role = 'admin')`); err != nil {
+		return err
+	}
+	if adminExists {
+		return ErrAdminExists
+	}
+
+	result, err := tx.ExecContext(ctx, `UPDATE users SET role = 'admin', updated_at = now() WHERE id = $1`, user.ID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE admin_bootstrap_tokens SET used_at = $1, used_by = $2 WHERE id = $3
+	`, now, user.ID, tokenID)
+	if err != nil {
+		return err
+	}
+
+	err = recordAdminAudit(ctx, tx, &models.AuditEntry{
+		ActorID:    &user.ID,
+		ActorEmail: user.Email,
+		Action:     models.AuditBootstrapClaimed,
+		IPAddress:  ipAddress,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpsertBreakGlassAdmin creates a break-glass admin account or resets its password.
+// It refuses to take over a regular account with the same email (ErrNotBreakGlass).
+func UpsertBreakGlassAdmin(ctx context.Context, email, passwordHash string) (*models.User, error) {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var user models.User
+	err = tx.GetContext(ctx, &user, `
+		INSERT INTO users (email, role, password_hash, break_glass)
+		VALUES ($1, 'admin', $2, true)
+		ON CONFLICT (email) DO UPDATE
+		SET role = 'admin', password_hash = EXCLUDED.password_hash, updated_at = now()
+		WHERE users.break_glass
+		RETURNING id, email, role, break_glass, created_at, updated_at
+	`, email, passwordHash)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotBreakGlass
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = recordAdminAudit(ctx, tx, &models.AuditEntry{
+		ActorID:    &user.ID,
+		ActorEmail: user.Email,
+		Action:     models.AuditBreakGlassProvisioned,
+		Detail:     "via cmd/admin",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, tx.Commit()
+}
+
+// DisableBreakGlassAdmin removes the password and admin role of a break-glass account,
+// which also invalidates its outstanding break-glass tokens
+func DisableBreakGlassAdmin(ctx context.Context, email string) error {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var userID string
+	err = tx.GetContext(ctx, &userID, `
+		UPDATE users SET role = 'buyer', password_hash = NULL, break_glass = false, updated_at = now()
+		WHERE email = $1 AND break_glass
+		RETURNING id
+	`, email)
+	if err != nil {
+		return err
+	}
+
+	err = recordAdminAudit(ctx, tx, &models.AuditEntry{
+		ActorID:    &userID,
+		ActorEmail: email,
+		Action:     models.AuditBreakGlassDisabled,
+		Detail:     "via cmd/admin",
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetBreakGlassUser returns the break-glass account with the given email, including its password hash
+func GetBreakGlassUser(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	err := DB.GetContext(ctx, &user, `
+		SELECT id, email, role, COALESCE(password_hash, '') AS password_hash, break_glass, created_at, updated_at
+		FROM users
+		WHERE email = $1 AND break_glass
+	`, email)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetBreakGlassUserByID returns the break-glass account with the given ID
+func GetBreakGlassUserByID(ctx context.Context, id string) (*models.User, error) {
+	var user models.User
+	err := DB.GetContext(ctx, &user, `
+		SELECT id, email, role, break_glass, created_at, updated_at
+		FROM users
+		WHERE id = $1 AND break_glass
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
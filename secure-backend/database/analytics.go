@@ -0,0 +1,44 @@
+package database
+
+import (
+	"context"
+	"secure-backend/models"
+	"time"
+)
+
+// RecordCartEvent stores a cart action for analytics. Actions of users who haven't
+// consented to analytics are not stored.
+func RecordCartEvent(ctx context.Context, event *models.CartEvent) error {
+	_, err := DB.ExecContext(ctx, `
+		INSERT INTO cart_events (user_id, action, product_id, quantity, platform, app_version)
+		SELECT $1, $2, $3, $4, $5, NULLIF($6, '')
+		WHERE `+consentSQL("analytics", "$1::uuid")+`
+	`, event.UserID, event.Action, event.ProductID, event.Quantity, event.Platform, event.AppVersion)
+	return err
+}
+
+// GetCartEventBreakdown counts cart events per platform and action within a time range
+func GetCartEventBreakdown(ctx context.Context, from, to time.Time) ([]models.PlatformActionCount, error) {
+	counts := []models.PlatformActionCount{}
+	err := DB.SelectContext(ctx, &counts, `
+		SELECT platform, action, COUNT(*) AS events, COUNT(DISTINCT user_id) AS users
+		FROM cart_events
+		WHERE created_at >= $1 AND created_at < $2
+		GROUP BY platform, action
+		ORDER BY platform, action
+	`, from, to)
+	return counts, err
+}
+
+// GetOrderPlatformBreakdown summarizes orders per client platform within a time range
+func GetOrderPlatformBreakdown(ctx context.Context, from, to time.Time) ([]models.PlatformOrderStats, error) {
+	stats := []models.PlatformOrderStats{}
+	err := DB.SelectContext(ctx, &stats, `
+		SELECT client_platform AS platform, COUNT(*) AS orders, COALESCE(SUM(total_amount), 0) AS revenue
+		FROM orders
+		WHERE created_at >= $1 AND created_at < $2
+		GROUP BY client_platform
+		ORDER BY client_platform
+	`, from, to)
+	return stats, err
+}
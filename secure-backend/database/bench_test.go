@@ -0,0 +1,106 @@
+//go:build e2e
+
+// Query benchmarks against a seeded database (with schema.sql applied):
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run '^$' -bench . ./database
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+const (
+	benchProductCount = 500
+	benchCartItems    = 50
+)
+
+// seedBenchData connects to TEST_DATABASE_URL and creates a seller with benchProductCount
+// published products and a buyer with benchCartItems of them in their cart.
+// It returns the buyer's ID; the rows are removed when the benchmark ends.
+func seedBenchData(b *testing.B) string {
+	b.Helper()
+
+	users := createTestUsers(b, "bench", "seller", "buyer")
+	sellerID, buyerID := users[0], users[1]
+
+	var productIDs []string
+	err := DB.SelectContext(context.Background(), &productIDs, `
+		INSERT INTO products (name, description, price, stock, status, seller_id)
+		SELECT 'Bench product ' || n, 'Seeded for query benchmarks', 9.99 + n, 100, 'published', $1
+		FROM generate_series(1, $2) AS n
+		RETURNING id
+	`, sellerID, benchProductCount)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, productID := range productIDs[:benchCartItems] {
+		if _, err := AddToCart(context.Background(), buyerID, productID, 2); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	return buyerID
+}
+
+func BenchmarkGetCartItems(b *testing.B) {
+	buyerID := seedBenchData(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		items, err := GetCartItems(context.Background(), buyerID)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(items) != benchCartItems {
+			b.Fatalf("got %d cart items, want %d", len(items), benchCartItems)
+		}
+	}
+}
+
+func BenchmarkGetCartItemsSince(b *testing.B) {
+	buyerID := seedBenchData(b)
+	version, err := GetCartVersion(context.Background(), buyerID)
+	if err != nil {
+		b.Fatal(err)
+	}
+	since := version - 5
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := GetCartItemsSince(context.Background(), buyerID, since); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetCartItemCount(b *testing.B) {
+	buyerID := seedBenchData(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := GetCartItemCount(context.Background(), buyerID); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetPublishedProductsPage(b *testing.B) {
+	seedBenchData(b)
+
+	for _, offset := range []int{0, 200} {
+		b.Run(fmt.Sprintf("offset=%d", offset), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := GetPublishedProducts(context.Background(), ProductFilter{}, 20, offset); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
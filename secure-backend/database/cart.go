@@ -1,158 +1,264 @@
 package database
 
 import (
-	"database/sql"
+	"context"
 	"secure-backend/models"
+
+	"github.com/jmoiron/sqlx"
 )
 
+// maxCartItemQuantity matches the per-item limit the cart handlers enforce
+const maxCartItemQuantity = models.MaxCartItemQuantity
+
 // GetCartItems retrieves all cart items for a user with product details
-func GetCartItems(userID string) ([]models.CartItemWithProduct, error) {
-	var items []models.CartItemWithProduct
-	query := `
+func GetCartItems(ctx context.Context, userID string) ([]models.CartItemWithProduct, error) {
+	return queryCartItems(ctx, `
 		SELECT 
-			ci.id, ci.user_id, ci.product_id, ci.quantity, ci.created_at, ci.updated_at,
+			ci.id, ci.user_id, ci.product_id, ci.quantity, ci.version, ci.added_version, ci.added_price, ci.created_at, ci.updated_at,
 			p.id, p.name, p.description, p.price, p.image, p.stock, p.status, p.seller_id, p.created_at, p.updated_at
 		FROM cart_items ci
 		JOIN products p ON ci.product_id = p.id
 		WHERE ci.user_id = $1
-		ORDER BY ci.created_at DESC`
+		ORDER BY ci.created_at DESC`, userID)
+}
 
-	rows, err := DB.Query(query, userID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var item models.CartItemWithProduct
-		err := rows.Scan(
-			&item.ID, &item.UserID, &item.ProductID, &item.Quantity, &item.CreatedAt, &item.UpdatedAt,
-			&item.Product.ID, &item.Product.Name, &item.Product.Description, &item.Product.Price,
-			&item.Product.Image, &item.Product.Stock, &item.Product.Status, &item.Product.SellerID,
-			&item.Product.CreatedAt, &item.Product.UpdatedAt,
-		)
+// GetCartItemsSince retrieves cart items for a user that changed after the given cart version
+func GetCartItemsSince(ctx context.Context, userID string, since int64) ([]models.CartItemWithProduct, error) {
+	return queryCartItems(ctx, `
+		SELECT 
+			ci.id, ci.user_id, ci.product_id, ci.quantity, ci.version, ci.added_version, ci.added_price, ci.created_at, ci.updated_at,
+			p.id, p.name, p.description, p.price, p.image, p.stock, p.status, p.seller_id, p.created_at, p.updated_at
+		FROM cart_items ci
+		JOIN products p ON ci.product_id = p.id
+		WHERE ci.user_id = $1 AND ci.version > $2
+		ORDER BY ci.version ASC`, userID, since)
+}
+
+// queryCartItems runs a cart item query and scans the joined product details, on the read
+// replica when ctx prefers it
+func queryCartItems(ctx context.Context, query string, args ...interface{}) ([]models.CartItemWithProduct, error) {
+	var items []models.CartItemWithProduct
+	err := withReader(ctx, func(q sqlx.QueryerContext) error {
+		items = nil
+		rows, err := q.QueryContext(ctx, query, args...)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		items = append(items, item)
-	}
+		defer rows.Close()
 
+		for rows.Next() {
+			var item models.CartItemWithProduct
+			err := rows.Scan(
+				&item.ID, &item.UserID, &item.ProductID, &item.Quantity, &item.Version, &item.AddedVersion, &item.AddedPrice,
+				&item.CreatedAt, &item.UpdatedAt,
+				&item.Product.ID, &item.Product.Name, &item.Product.Description, &item.Product.Price,
+				&item.Product.Image, &item.Product.Stock, &item.Product.Status, &item.Product.SellerID,
+				&item.Product.CreatedAt, &item.Product.UpdatedAt,
+			)
+			if err != nil {
+				return err
+			}
+			items = append(items, item)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
 	return items, nil
 }
 
-// AddToCart adds a product to the user's cart or updates quantity if exists
-func AddToCart(userID, productID string, quantity int) (*models.CartItem, error) {
-	// First check if item already exists
-	var existingItem models.CartItem
-	err := DB.Get(&existingItem, `
-		SELECT id, user_id, product_id, quantity, created_at, updated_at 
-		FROM cart_items 
-		WHERE user_id = $1 AND product_id = $2
-	`, userID, productID)
+// GetCartRemovalsSince returns the cart items removed after the given cart version
+func GetCartRemovalsSince(ctx context.Context, userID string, since int64) ([]models.CartItemRemoval, error) {
+	var removals []models.CartItemRemoval
+	err := withReader(ctx, func(q sqlx.QueryerContext) error {
+		removals = nil
+		return sqlx.SelectContext(ctx, q, &removals, `
+			SELECT cart_item_id, product_id, version
+			FROM cart_item_tombstones
+			WHERE user_id = $1 AND version > $2
+			ORDER BY version ASC
+		`, userID, since)
+	})
+	return removals, err
+}
 
-	if err == sql.ErrNoRows {
-		// Item doesn't exist, create new
-		query := `
-			INSERT INTO cart_items (user_id, product_id, quantity)
-			VALUES ($1, $2, $3)
-			RETURNING id, user_id, product_id, quantity, created_at, updated_at`
-
-		var newItem models.CartItem
-		err = DB.QueryRow(query, userID, productID, quantity).Scan(
-			&newItem.ID, &newItem.UserID, &newItem.ProductID, &newItem.Quantity,
-			&newItem.CreatedAt, &newItem.UpdatedAt,
-		)
-		return &newItem, err
-	} else if err != nil {
-		return nil, err
-	}
+// GetCartVersion returns the current cart version for a user (0 if the cart was never modified)
+func GetCartVersion(ctx context.Context, userID string) (int64, error) {
+	var version int64
+	err := withReader(ctx, func(q sqlx.QueryerContext) error {
+		return sqlx.GetContext(ctx, q, &version, `
+			SELECT COALESCE((SELECT version FROM cart_versions WHERE user_id = $1), 0)
+		`, userID)
+	})
+	return version, err
+}
 
-	// Item exists, update quantity
-	_, err = DB.Exec(`
-		UPDATE cart_items 
-		SET quantity = quantity + $1, updated_at = now()
-		WHERE user_id = $2 AND product_id = $3
-	`, quantity, userID, productID)
+// nextCartVersion atomically increments and returns the user's cart version
+func nextCartVersion(ctx context.Context, q sqlx.QueryerContext, userID string) (int64, error) {
+	var version int64
+	err := sqlx.GetContext(ctx, q, &version, `
+		INSERT INTO cart_versions (user_id, version)
+		VALUES ($1, 1)
+		ON CONFLICT (user_id) DO UPDATE
+		SET version = cart_versions.version + 1, abandoned_at = NULL, updated_at = now()
+		RETURNING version
+	`, userID)
+	return version, err
+}
+
+// AddToCart adds a product to the user's cart, adding to the quantity if it is already there.
+// The cart version bump and the upsert run in one transaction, so concurrent adds of the
+// same product end up in a single cart item.
+func AddToCart(ctx context.Context, userID, productID string, quantity int) (*models.CartItem, error) {
+	var item models.CartItem
+	err := WithTx(ctx, func(tx *sqlx.Tx) error {
+		version, err := nextCartVersion(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
 
+		return tx.GetContext(ctx, &item, `
+			INSERT INTO cart_items (user_id, product_id, quantity, version, added_version, added_price)
+			VALUES ($1, $2, $3, $4, $4, (SELECT price FROM products WHERE id = $2))
+			ON CONFLICT (user_id, product_id) DO UPDATE
+			SET quantity = LEAST(cart_items.quantity + EXCLUDED.quantity, $5), version = $4,
+				added_price = EXCLUDED.added_price, updated_at = now()
+			RETURNING id, user_id, product_id, quantity, version, added_version, added_price, created_at, updated_at
+		`, userID, productID, quantity, version, maxCartItemQuantity)
+	})
 	if err != nil {
 		return nil, err
 	}
-
-	// Return updated item
-	err = DB.Get(&existingItem, `
-		SELECT id, user_id, product_id, quantity, created_at, updated_at 
-		FROM cart_items 
-		WHERE user_id = $1 AND product_id = $2
-	`, userID, productID)
-
-	return &existingItem, err
+	return &item, nil
 }
 
 // UpdateCartItemQuantity updates the quantity of a specific cart item
-func UpdateCartItemQuantity(cartItemID, userID string, quantity int) error {
+func UpdateCartItemQuantity(ctx context.Context, cartItemID, userID string, quantity int) error {
 	if quantity <= 0 {
 		// If quantity is 0 or negative, remove the item
-		return RemoveFromCart(cartItemID, userID)
-	}
-
-	result, err := DB.Exec(`
-		UPDATE cart_items 
-		SET quantity = $1, updated_at = now()
-		WHERE id = $2 AND user_id = $3
-	`, quantity, cartItemID, userID)
-
-	if err != nil {
-		return err
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
+		return RemoveFromCart(ctx, cartItemID, userID)
 	}
 
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
-	}
+	// The version bump is rolled back with the update when the item doesn't exist
+	return WithTx(ctx, func(tx *sqlx.Tx) error {
+		version, err := nextCartVersion(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
 
-	return nil
+		return execOwned(ctx, tx, `
+			UPDATE cart_items 
+			SET quantity = $1, version = $4, updated_at = now()
+			WHERE id = $2 AND user_id = $3
+		`, quantity, cartItemID, userID, version)
+	})
 }
 
 // RemoveFromCart removes a specific item from the user's cart
-func RemoveFromCart(cartItemID, userID string) error {
-	result, err := DB.Exec(`
-		DELETE FROM cart_items 
-		WHERE id = $1 AND user_id = $2
-	`, cartItemID, userID)
+func RemoveFromCart(ctx context.Context, cartItemID, userID string) error {
+	return WithTx(ctx, func(tx *sqlx.Tx) error {
+		version, err := nextCartVersion(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
 
-	if err != nil {
-		return err
-	}
+		// Delete the item and record a tombstone in a single statement
+		return execOwned(ctx, tx, `
+			WITH deleted AS (
+				DELETE FROM cart_items 
+				WHERE id = $1 AND user_id = $2
+				RETURNING id, product_id
+			)
+			INSERT INTO cart_item_tombstones (user_id, cart_item_id, product_id, version)
+			SELECT $2, id, product_id, $3 FROM deleted
+		`, cartItemID, userID, version)
+	})
+}
+
+// ClearCart removes all items from the user's cart
+func ClearCart(ctx context.Context, userID string) error {
+	return clearCart(ctx, DB, userID)
+}
 
-	rowsAffected, err := result.RowsAffected()
+// clearCart removes all cart items using the given connection or transaction
+func clearCart(ctx context.Context, q sqlx.ExtContext, userID string) error {
+	version, err := nextCartVersion(ctx, q, userID)
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
-	}
-
-	return nil
-}
-
-// ClearCart removes all items from the user's cart
-func ClearCart(userID string) error {
-	_, err := DB.Exec(`DELETE FROM cart_items WHERE user_id = $1`, userID)
+	_, err = q.ExecContext(ctx, `
+		WITH deleted AS (
+			DELETE FROM cart_items WHERE user_id = $1
+			RETURNING id, product_id
+		)
+		INSERT INTO cart_item_tombstones (user_id, cart_item_id, product_id, version)
+		SELECT $1, id, product_id, $2 FROM deleted
+	`, userID, version)
 	return err
 }
 
 // GetCartItemCount returns the total number of items in user's cart
-func GetCartItemCount(userID string) (int, error) {
+func GetCartItemCount(ctx context.Context, userID string) (int, error) {
 	var count int
-	err := DB.Get(&count, `
-		SELECT COALESCE(SUM(quantity), 0) 
-		FROM cart_items 
-		WHERE user_id = $1
-	`, userID)
+	err := withReader(ctx, func(q sqlx.QueryerContext) error {
+		return sqlx.GetContext(ctx, q, &count, `
+			SELECT COALESCE(SUM(quantity), 0) 
+			FROM cart_items 
+			WHERE user_id = $1
+		`, userID)
+	})
 	return count, err
 }
+
+// GetCartUnits returns how many units the user's cart holds in total, leaving out the cart
+// item exceptItemID (empty to count every item)
+func GetCartUnits(ctx context.Context, userID, exceptItemID string) (int, error) {
+	var units int
+	err := DB.GetContext(ctx, &units, `
+		SELECT COALESCE(SUM(quantity), 0)
+		FROM cart_items
+		WHERE user_id = $1 AND id::text <> $2
+	`, userID, exceptItemID)
+	return units, err
+}
+
+// GetCartItemByProduct retrieves the user's cart item for a product
+func GetCartItemByProduct(ctx context.Context, userID, productID string) (*models.CartItem, error) {
+	var item models.CartItem
+	err := DB.GetContext(ctx, &item, `
+		SELECT id, user_id, product_id, quantity, version, added_version, added_price, created_at, updated_at
+		FROM cart_items 
+		WHERE user_id = $1 AND product_id = $2
+	`, userID, productID)
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// GetLastRemovalVersion returns the cart version at which a product was last removed from the user's cart (0 if never)
+func GetLastRemovalVersion(ctx context.Context, userID, productID string) (int64, error) {
+	var version int64
+	err := DB.GetContext(ctx, &version, `
+		SELECT COALESCE(MAX(version), 0)
+		FROM cart_item_tombstones
+		WHERE user_id = $1 AND product_id = $2
+	`, userID, productID)
+	return version, err
+}
+
+// GetCartItemProduct retrieves the product of one of the user's cart items
+func GetCartItemProduct(ctx context.Context, cartItemID, userID string) (*models.Product, error) {
+	var product models.Product
+	err := DB.GetContext(ctx, &product, `
+		SELECT `+productColumns+`
+		FROM products
+		WHERE id = (SELECT product_id FROM cart_items WHERE id = $1 AND user_id = $2)
+	`, cartItemID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
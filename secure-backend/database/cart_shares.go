@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"secure-backend/models"
+	"time"
+)
+
+// CreateCartShare snapshots the user's cart into a share that expires at expiresAt.
+// Returns ErrCartEmpty if there is nothing to share.
+func CreateCartShare(ctx context.Context, userID string, expiresAt time.Time) (*models.CartShare, error) {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var share models.CartShare
+	err = tx.GetContext(ctx, &share, `
+		INSERT INTO cart_shares (user_id, expires_at)
+		VALUES ($1, $2)
+		RETURNING id, user_id, expires_at, created_at
+	`, userID, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.SelectContext(ctx, &share.Items, `
+		INSERT INTO cart_share_items (share_id, product_id, quantity)
+		SELECT $1, product_id, quantity FROM cart_items WHERE user_id = $2
+		RETURNING product_id, quantity
+	`, share.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(share.Items) == 0 {
+		return nil, ErrCartEmpty
+	}
+
+	return &share, tx.Commit()
+}
+
+// GetCartShare returns a share with its items unless it expired before now
+// (sql.ErrNoRows if it doesn't exist or expired)
+func GetCartShare(ctx context.Context, shareID string, now time.Time) (*models.CartShare, error) {
+	var share models.CartShare
+	err := DB.GetContext(ctx, &share, `
+		SELECT id, user_id, expires_at, created_at
+		FROM cart_shares
+		WHERE id = $1 AND expires_at > $2
+	`, shareID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	err = DB.SelectContext(ctx, &share.Items, `
+		SELECT product_id, quantity FROM cart_share_items WHERE share_id = $1 ORDER BY product_id
+	`, share.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &share, nil
+}
+
+// DeleteExpiredCartShares removes cart shares that expired before now
+func DeleteExpiredCartShares(ctx context.Context, now time.Time) (int64, error) {
+	result, err := DB.ExecContext(ctx, `DELETE FROM cart_shares WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,276 @@
+//go:build e2e
+
+// Cart concurrency tests against a real PostgreSQL database (with schema.sql applied):
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run 'TestAddToCart|TestCartVersion|TestMergeGuestCart' ./database
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"secure-backend/models"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+func TestAddToCartConcurrent(t *testing.T) {
+	users := createTestUsers(t, "cart", "seller", "buyer")
+	sellerID, buyerID := users[0], users[1]
+
+	var productID string
+	err := DB.GetContext(context.Background(), &productID, `
+		INSERT INTO products (name, price, stock, status, seller_id)
+		VALUES ('Concurrent product', 5, 100, 'published', $1)
+		RETURNING id
+	`, sellerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Concurrent adds of the same product must land in one cart item
+	const adds = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, adds)
+	for i := 0; i < adds; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := AddToCart(context.Background(), buyerID, productID, 1); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("AddToCart: %v", err)
+	}
+
+	var rows, quantity int
+	err = DB.QueryRowContext(context.Background(), `SELECT COUNT(*), COALESCE(SUM(quantity), 0) FROM cart_items WHERE user_id = $1`, buyerID).Scan(&rows, &quantity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows != 1 || quantity != adds {
+		t.Errorf("got %d cart items with quantity %d, want 1 item with quantity %d", rows, quantity, adds)
+	}
+
+	version, err := GetCartVersion(context.Background(), buyerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != adds {
+		t.Errorf("cart version = %d, want %d", version, adds)
+	}
+}
+
+func TestWithTxRetriesSerializationFailures(t *testing.T) {
+	initTestDB(t)
+
+	email := "tx-retry-" + uuid.NewString()[:8] + "@example.com"
+	t.Cleanup(func() {
+		DB.ExecContext(context.Background(), `DELETE FROM users WHERE email = $1`, email)
+	})
+
+	// The first attempt's insert must be rolled back before the transaction runs again
+	attempts := 0
+	err := WithTx(context.Background(), func(tx *sqlx.Tx) error {
+		attempts++
+		if _, err := tx.ExecContext(context.Background(), `INSERT INTO users (email) VALUES ($1)`, email); err != nil {
+			return err
+		}
+		if attempts == 1 {
+			return &pq.Error{Code: "40001", Message: "could not serialize access"}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 {
+		t.Fatalf("expected 2 attempts, got %d", attempts)
+	}
+
+	var count int
+	if err := DB.GetContext(context.Background(), &count, `SELECT COUNT(*) FROM users WHERE email = $1`, email); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("expected 1 user, got %d", count)
+	}
+}
+
+func TestMergeGuestCartSizeLimit(t *testing.T) {
+	users := createTestUsers(t, "merge", "seller", "buyer")
+	sellerID, buyerID := users[0], users[1]
+	ctx := context.Background()
+
+	var productID, otherID string
+	for _, id := range []*string{&productID, &otherID} {
+		err := DB.GetContext(ctx, id, `
+			INSERT INTO products (name, price, stock, status, seller_id)
+			VALUES ('Merged product', 5, 100, 'published', $1)
+			RETURNING id
+		`, sellerID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := AddToCart(ctx, buyerID, productID, 6); err != nil {
+		t.Fatal(err)
+	}
+	guest, err := CreateCartSession(ctx, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		DB.ExecContext(context.Background(), `DELETE FROM cart_sessions WHERE id = $1`, guest.ID)
+	})
+	if _, err := AddToGuestCart(ctx, guest.ID, otherID, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	// 6 + 5 units don't fit a cart of 10: nothing is merged and the guest cart stays
+	_, err = MergeGuestCart(ctx, guest.ID, buyerID, 10, time.Now())
+	var ruleErr *models.OrderRuleError
+	if !errors.As(err, &ruleErr) || ruleErr.Code != models.OrderRuleAboveCartSizeLimit {
+		t.Fatalf("expected the cart size limit error, got %v", err)
+	}
+	if units, err := GetCartUnits(ctx, buyerID, ""); err != nil || units != 6 {
+		t.Fatalf("expected the cart to keep 6 units, got %d (%v)", units, err)
+	}
+	if _, err := GetCartSession(ctx, guest.ID, time.Now()); err != nil {
+		t.Fatalf("expected the guest cart to remain: %v", err)
+	}
+
+	// They fit a cart of 11
+	merged, err := MergeGuestCart(ctx, guest.ID, buyerID, 11, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merged) != 1 {
+		t.Fatalf("expected 1 merged item, got %d", len(merged))
+	}
+	if units, err := GetCartUnits(ctx, buyerID, ""); err != nil || units != 11 {
+		t.Fatalf("expected 11 units in the cart, got %d (%v)", units, err)
+	}
+}
+
+func TestCartVersionDeltas(t *testing.T) {
+	users := createTestUsers(t, "delta", "seller", "buyer")
+	sellerID, buyerID := users[0], users[1]
+	ctx := context.Background()
+
+	var lampID, mugID string
+	for _, id := range []*string{&lampID, &mugID} {
+		err := DB.GetContext(ctx, id, `
+			INSERT INTO products (name, price, stock, status, seller_id)
+			VALUES ('Delta product', 5, 100, 'published', $1)
+			RETURNING id
+		`, sellerID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// changedSince returns the product IDs of the items and removals after a version
+	changedSince := func(since int64) (items, removals []string) {
+		t.Helper()
+		changed, err := GetCartItemsSince(ctx, buyerID, since)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range changed {
+			items = append(items, item.ProductID)
+		}
+		removed, err := GetCartRemovalsSince(ctx, buyerID, since)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, removal := range removed {
+			removals = append(removals, removal.ProductID)
+		}
+		return items, removals
+	}
+	wantVersion := func(want int64) {
+		t.Helper()
+		if version, err := GetCartVersion(ctx, buyerID); err != nil || version != want {
+			t.Fatalf("cart version = %d (%v), want %d", version, err, want)
+		}
+	}
+
+	wantVersion(0)
+	lamp, err := AddToCart(ctx, buyerID, lampID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mug, err := AddToCart(ctx, buyerID, mugID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantVersion(2)
+	if lamp.Version != 1 || lamp.AddedVersion != 1 || mug.Version != 2 || mug.AddedVersion != 2 {
+		t.Fatalf("unexpected item versions: lamp %d/%d, mug %d/%d", lamp.Version, lamp.AddedVersion, mug.Version, mug.AddedVersion)
+	}
+	if items, _ := changedSince(0); len(items) != 2 {
+		t.Fatalf("expected both items since version 0, got %v", items)
+	}
+	if items, _ := changedSince(1); len(items) != 1 || items[0] != mugID {
+		t.Fatalf("expected only the mug since version 1, got %v", items)
+	}
+
+	// Adding to an item already in the cart moves its version but not the version it was added at
+	again, err := AddToCart(ctx, buyerID, lampID, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != lamp.ID || again.Version != 3 || again.AddedVersion != 1 || again.Quantity != 3 {
+		t.Fatalf("expected the lamp at version 3 added at 1 with 3 units, got %+v", again)
+	}
+	if items, _ := changedSince(2); len(items) != 1 || items[0] != lampID {
+		t.Fatalf("expected only the lamp since version 2, got %v", items)
+	}
+
+	if err := RemoveFromCart(ctx, mug.ID, buyerID); err != nil {
+		t.Fatal(err)
+	}
+	wantVersion(4)
+	items, removals := changedSince(3)
+	if len(items) != 0 || len(removals) != 1 || removals[0] != mugID {
+		t.Fatalf("expected only the mug's removal since version 3, got items %v and removals %v", items, removals)
+	}
+	items, removals = changedSince(2)
+	if len(items) != 1 || len(removals) != 1 {
+		t.Fatalf("expected the lamp update and the mug removal since version 2, got items %v and removals %v", items, removals)
+	}
+
+	// The current version has nothing after it
+	if items, removals := changedSince(4); len(items) != 0 || len(removals) != 0 {
+		t.Fatalf("expected no changes since the current version, got items %v and removals %v", items, removals)
+	}
+
+	// A change to a missing item rolls back its version bump
+	if err := UpdateCartItemQuantity(ctx, mug.ID, buyerID, 2); err != sql.ErrNoRows {
+		t.Fatalf("expected sql.ErrNoRows updating a removed item, got %v", err)
+	}
+	if err := RemoveFromCart(ctx, mug.ID, buyerID); err != sql.ErrNoRows {
+		t.Fatalf("expected sql.ErrNoRows removing a removed item, got %v", err)
+	}
+	wantVersion(4)
+
+	// Clearing removes every item at one version
+	if err := ClearCart(ctx, buyerID); err != nil {
+		t.Fatal(err)
+	}
+	wantVersion(5)
+	if items, removals := changedSince(4); len(items) != 0 || len(removals) != 1 || removals[0] != lampID {
+		t.Fatalf("expected only the lamp's removal since version 4, got items %v and removals %v", items, removals)
+	}
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"secure-backend/models"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// GetCatalogChanges returns up to limit changes of the catalog change feed after the cursor,
+// in feed order. Only transactions older than the oldest one still running are read, so a
+// change committed later always sorts after those returned now. Stock changes are left out
+// unless includeStock is set.
+func GetCatalogChanges(ctx context.Context, after models.ChangeCursor, includeStock bool, limit int) ([]models.CatalogChange, error) {
+	return catalogChanges(ctx, DB, after, includeStock, limit)
+}
+
+// catalogChanges runs GetCatalogChanges with q, e.g. in the transaction that saves a reader's position
+func catalogChanges(ctx context.Context, q sqlx.QueryerContext, after models.ChangeCursor, includeStock bool, limit int) ([]models.CatalogChange, error) {
+	changes := []models.CatalogChange{}
+	err := sqlx.SelectContext(ctx, q, &changes, `
+		SELECT id, xact_id::text AS xact_id, product_id, change, created_at
+		FROM catalog_changes
+		WHERE (xact_id, id) > ($1::text::xid8, $2)
+			AND xact_id < pg_snapshot_xmin(pg_current_snapshot())
+			AND ($3 OR change <> $4)
+		ORDER BY xact_id, id
+		LIMIT $5
+	`, strconv.FormatUint(after.XactID, 10), after.ID, includeStock, models.CatalogStockChanged, limit)
+	return changes, err
+}
+
+// CatalogChangeExists reports whether the change with the ID is still in the feed, i.e. a
+// cursor pointing at it hasn't expired
+func CatalogChangeExists(ctx context.Context, id int64) (bool, error) {
+	var exists bool
+	err := DB.GetContext(ctx, &exists, `SELECT EXISTS (SELECT 1 FROM catalog_changes WHERE id = $1)`, id)
+	return exists, err
+}
+
+// DeleteCatalogChangesBefore deletes catalog changes recorded before cutoff
+func DeleteCatalogChangesBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := DB.ExecContext(ctx, `DELETE FROM catalog_changes WHERE created_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,143 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"secure-backend/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrSlugTaken       = errors.New("slug is already in use")
+	ErrUnknownCategory = errors.New("category does not exist")
+	ErrUnknownTag      = errors.New("tag does not exist")
+)
+
+// GetCategories returns all categories by name with their published product counts
+func GetCategories(ctx context.Context) ([]models.Category, error) {
+	categories := []models.Category{}
+	err := DB.SelectContext(ctx, &categories, `
+		SELECT c.id, c.name, c.slug, c.description, c.created_at, c.updated_at,
+			COUNT(p.id) AS product_count
+		FROM categories c
+		LEFT JOIN products p ON p.category_id = c.id AND p.status = 'published'
+		GROUP BY c.id
+		ORDER BY c.name
+	`)
+	return categories, err
+}
+
+// CreateCategory inserts a category, returning ErrSlugTaken if the slug is in use
+func CreateCategory(ctx context.Context, category *models.Category) error {
+	err := DB.QueryRowContext(ctx, `
+		INSERT INTO categories (name, slug, description)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at
+	`, category.Name, category.Slug, category.Description).Scan(&category.ID, &category.CreatedAt, &category.UpdatedAt)
+	if hasErrorCode(err, uniqueViolation) {
+		return ErrSlugTaken
+	}
+	return err
+}
+
+// UpdateCategory renames a category. It returns sql.ErrNoRows if the category does not exist
+// and ErrSlugTaken if the new slug is in use.
+func UpdateCategory(ctx context.Context, category *models.Category) error {
+	err := DB.QueryRowContext(ctx, `
+		UPDATE categories SET name = $2, slug = $3, description = $4, updated_at = now()
+		WHERE id = $1
+		RETURNING created_at, updated_at
+	`, category.ID, category.Name, category.Slug, category.Description).Scan(&category.CreatedAt, &category.UpdatedAt)
+	if hasErrorCode(err, uniqueViolation) {
+		return ErrSlugTaken
+	}
+	return err
+}
+
+// DeleteCategory removes a category; its products become uncategorized
+func DeleteCategory(ctx context.Context, id string) (int64, error) {
+	result, err := DB.ExecContext(ctx, `DELETE FROM categories WHERE id = $1`, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetTags returns all tags by name with their published product counts
+func GetTags(ctx context.Context) ([]models.Tag, error) {
+	tags := []models.Tag{}
+	err := DB.SelectContext(ctx, &tags, `
+		SELECT t.id, t.name, t.slug, t.created_at, t.updated_at,
+			COUNT(p.id) AS product_count
+		FROM tags t
+		LEFT JOIN product_tags pt ON pt.tag_id = t.id
+		LEFT JOIN products p ON p.id = pt.product_id AND p.status = 'published'
+		GROUP BY t.id
+		ORDER BY t.name
+	`)
+	return tags, err
+}
+
+// CreateTag inserts a tag, returning ErrSlugTaken if the slug is in use
+func CreateTag(ctx context.Context, tag *models.Tag) error {
+	err := DB.QueryRowContext(ctx, `
+		INSERT INTO tags (name, slug)
+		VALUES ($1, $2)
+		RETURNING id, created_at, updated_at
+	`, tag.Name, tag.Slug).Scan(&tag.ID, &tag.CreatedAt, &tag.UpdatedAt)
+	if hasErrorCode(err, uniqueViolation) {
+		return ErrSlugTaken
+	}
+	return err
+}
+
+// UpdateTag renames a tag. It returns sql.ErrNoRows if the tag does not exist
+// and ErrSlugTaken if the new slug is in use.
+func UpdateTag(ctx context.Context, tag *models.Tag) error {
+	err := DB.QueryRowContext(ctx, `
+		UPDATE tags SET name = $2, slug = $3, updated_at = now()
+		WHERE id = $1
+		RETURNING created_at, updated_at
+	`, tag.ID, tag.Name, tag.Slug).Scan(&tag.CreatedAt, &tag.UpdatedAt)
+	if hasErrorCode(err, uniqueViolation) {
+		return ErrSlugTaken
+	}
+	return err
+}
+
+// DeleteTag removes a tag from the catalog and from every product carrying it
+func DeleteTag(ctx context.Context, id string) (int64, error) {
+	result, err := DB.ExecContext(ctx, `DELETE FROM tags WHERE id = $1`, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// setProductTags replaces the tags of a product with the tags having the given slugs.
+// It returns ErrUnknownTag if any slug does not match a tag.
+func setProductTags(ctx context.Context, tx *sqlx.Tx, productID string, slugs []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM product_tags WHERE product_id = $1`, productID); err != nil {
+		return err
+	}
+	if len(slugs) == 0 {
+		return nil
+	}
+
+	var found int
+	err := tx.GetContext(ctx, &found, `SELECT COUNT(*) FROM tags WHERE slug = ANY($1)`, pq.Array(slugs))
+	if err != nil {
+		return err
+	}
+	if found != len(slugs) {
+		return ErrUnknownTag
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO product_tags (product_id, tag_id)
+		SELECT $1, id FROM tags WHERE slug = ANY($2)
+	`, productID, pq.Array(slugs))
+	return err
+}
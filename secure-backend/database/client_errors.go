@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"secure-backend/models"
+)
+
+// CreateClientErrors stores a batch of client error reports in a single transaction
+func CreateClientErrors(ctx context.Context, reports []models.ClientError) error {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, r := range reports {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO client_errors (user_id, request_id, client_request_id, message, stack, url, component,
+				user_agent, platform, app_version, occurred_at)
+			VALUES ($1, $2, NULLIF($3, ''), $4, NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''),
+				NULLIF($8, ''), $9, NULLIF($10, ''), $11)
+		`, r.UserID, r.RequestID, r.ClientRequestID, r.Message, r.Stack, r.URL, r.Component,
+			r.UserAgent, r.Platform, r.AppVersion, r.OccurredAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetClientErrors returns a page of client error reports (newest first) and the total count.
+// An empty platform matches every platform.
+func GetClientErrors(ctx context.Context, platform string, limit, offset int) ([]models.ClientError, int, error) {
+	var total int
+	err := DB.GetContext(ctx, &total, `SELECT COUNT(*) FROM client_errors WHERE ($1 = '' OR platform = $1)`, platform)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	reports := []models.ClientError{}
+	err = DB.SelectContext(ctx, &reports, `
+		SELECT id, user_id, request_id, COALESCE(client_request_id, '') AS client_request_id, message,
+			COALESCE(stack, '') AS stack, COALESCE(url, '') AS url, COALESCE(component, '') AS component,
+			COALESCE(user_agent, '') AS user_agent, platform, COALESCE(app_version, '') AS app_version,
+			occurred_at, created_at
+		FROM client_errors
+		WHERE ($1 = '' OR platform = $1)
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`, platform, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return reports, total, nil
+}
@@ -2,19 +2,38 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"log"
 	"os"
+	"secure-backend/clock"
+	"secure-backend/idgen"
 	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 // DB is the global database connection
 var DB *sqlx.DB
 
+// clk and ids provide the times and IDs assigned in Go rather than by the database
+var (
+	clk clock.Clock     = clock.System()
+	ids idgen.Generator = idgen.UUID()
+)
+
+// SetClock replaces the clock used for expiry times (tests use a clock.Mock)
+func SetClock(c clock.Clock) {
+	clk = c
+}
+
+// SetIDGenerator replaces the generator of order, reservation, job and refund IDs
+func SetIDGenerator(g idgen.Generator) {
+	ids = g
+}
+
 // Config holds database connection configuration
 type Config struct {
 	MaxOpenConns int
@@ -29,6 +48,29 @@ var defaultConfig = Config{
 	MaxLifetime:  5 * time.Minute,
 }
 
+// defaultStatementTimeout is how long Postgres lets a statement run before cancelling it
+// unless DB_STATEMENT_TIMEOUT says otherwise
+const defaultStatementTimeout = 30 * time.Second
+
+// statementTimeout is read once by InitDB and set on every new connection; 0 means no limit
+var statementTimeout = defaultStatementTimeout
+
+// StatementTimeout returns the longest a statement may run, configurable via
+// DB_STATEMENT_TIMEOUT (e.g. "10s", "0" for no limit). It bounds queries that run without a
+// request to cancel them, such as those of background jobs.
+func StatementTimeout() time.Duration {
+	if value := os.Getenv("DB_STATEMENT_TIMEOUT"); value != "" {
+		if value == "0" {
+			return 0
+		}
+		if timeout, err := time.ParseDuration(value); err == nil && timeout >= 0 {
+			return timeout
+		}
+		log.Printf("Invalid DB_STATEMENT_TIMEOUT %q, using %s", value, defaultStatementTimeout)
+	}
+	return defaultStatementTimeout
+}
+
 // InitDB initializes the database connection with retries
 func InitDB() error {
 	return initDBWithConfig(&defaultConfig)
@@ -40,6 +82,9 @@ func initDBWithConfig(cfg *Config) error {
 		cfg = &defaultConfig
 	}
 
+	slowQueryThreshold = SlowQueryThreshold()
+	statementTimeout = StatementTimeout()
+
 	var err error
 	maxRetries := 5
 	retryDelay := time.Second
@@ -48,7 +93,7 @@ func initDBWithConfig(cfg *Config) error {
 		DB, err = connectDB(cfg)
 		if err == nil {
 			log.Printf("Successfully connected to database (attempt %d/%d)", i+1, maxRetries)
-			return nil
+			return initReplica(cfg)
 		}
 
 		log.Printf("Failed to connect to database (attempt %d/%d): %v", i+1, maxRetries, err)
@@ -73,17 +118,11 @@ func connectDB(cfg *Config) (*sqlx.DB, error) {
 	sanitizedURL := sanitizeConnString(connStr)
 	log.Printf("Attempting to connect to database: %s", sanitizedURL)
 
-	// Open connection
-	db, err := sqlx.Open("postgres", connStr)
+	db, err := openDB(connStr, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("opening database connection: %w", err)
+		return nil, err
 	}
 
-	// Configure connection pool
-	db.SetMaxOpenConns(cfg.MaxOpenConns)
-	db.SetMaxIdleConns(cfg.MaxIdleConns)
-	db.SetConnMaxLifetime(cfg.MaxLifetime)
-
 	// Verify connection with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -97,6 +136,21 @@ func connectDB(cfg *Config) (*sqlx.DB, error) {
 	return db, nil
 }
 
+// openDB creates a connection pool for connStr. Connections are opened when first needed;
+// queries run with a traced context become spans of its trace.
+func openDB(connStr string, cfg *Config) (*sqlx.DB, error) {
+	connector, err := pq.NewConnector(connStr)
+	if err != nil {
+		return nil, fmt.Errorf("opening database connection: %w", err)
+	}
+	db := sqlx.NewDb(sql.OpenDB(tracedConnector{connector}), "postgres")
+
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.MaxLifetime)
+	return db, nil
+}
+
 // sanitizeConnString removes sensitive information from connection string for logging
 func sanitizeConnString(connStr string) string {
 	// Basic sanitation - you might want to improve this
@@ -120,3 +174,65 @@ func HealthCheck() error {
 
 	return DB.PingContext(ctx)
 }
+
+// Ping checks that the database answers within ctx
+func Ping(ctx context.Context) error {
+	return DB.PingContext(ctx)
+}
+
+// Now returns the database's clock, which stamps updated_at columns. Cursors compared with
+// those columns must come from it rather than the app server's clock, which can drift.
+func Now(ctx context.Context) (time.Time, error) {
+	var now time.Time
+	err := DB.GetContext(ctx, &now, `SELECT now()`)
+	return now, err
+}
+
+// PoolStats describes the connection pool, for diagnosing capacity problems: requests
+// waiting for a connection (WaitCount, WaitDurationMs) while InUse sits at MaxOpen mean the
+// pool is too small or connections are held too long
+type PoolStats struct {
+	MaxOpen           int     `json:"max_open"`
+	Open              int     `json:"open"`
+	InUse             int     `json:"in_use"`
+	Idle              int     `json:"idle"`
+	WaitCount         int64   `json:"wait_count"`       // connections waited for since startup
+	WaitDurationMs    float64 `json:"wait_duration_ms"` // total time spent waiting for them
+	MaxIdleClosed     int64   `json:"max_idle_closed"`
+	MaxLifetimeClosed int64   `json:"max_lifetime_closed"`
+}
+
+// GetPoolStats returns the connection pool statistics (zero before InitDB)
+func GetPoolStats() PoolStats {
+	return poolStats(DB)
+}
+
+// poolStats returns the statistics of a connection pool (zero for nil)
+func poolStats(db *sqlx.DB) PoolStats {
+	if db == nil {
+		return PoolStats{}
+	}
+	stats := db.Stats()
+	return PoolStats{
+		MaxOpen:           stats.MaxOpenConnections,
+		Open:              stats.OpenConnections,
+		InUse:             stats.InUse,
+		Idle:              stats.Idle,
+		WaitCount:         stats.WaitCount,
+		WaitDurationMs:    float64(stats.WaitDuration.Microseconds()) / 1000,
+		MaxIdleClosed:     stats.MaxIdleClosed,
+		MaxLifetimeClosed: stats.MaxLifetimeClosed,
+	}
+}
+
+// CheckMigrations returns an error naming the embedded migrations the database hasn't applied
+func CheckMigrations(ctx context.Context) error {
+	pending, err := PendingMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("pending migrations: %s", strings.Join(pending, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"secure-backend/models"
+)
+
+// consentSQL returns an expression for whether the user in userExpr currently consents to
+// purpose (a consent_records column): the choice of their latest record, false without one
+func consentSQL(purpose, userExpr string) string {
+	return fmt.Sprintf(`COALESCE((
+		SELECT cr.%s FROM consent_records cr WHERE cr.user_id = %s ORDER BY cr.created_at DESC LIMIT 1
+	), false)`, purpose, userExpr)
+}
+
+// RecordConsent appends a user's consent choices and fills in the stored record
+func RecordConsent(ctx context.Context, consent *models.Consent) error {
+	return DB.QueryRowContext(ctx, `
+		INSERT INTO consent_records (user_id, analytics, marketing, policy_version, ip_hash)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, consent.UserID, consent.Analytics, consent.Marketing, consent.PolicyVersion, consent.IPHash).
+		Scan(&consent.ID, &consent.CreatedAt)
+}
+
+// GetConsent returns a user's latest consent choices (sql.ErrNoRows if they never chose)
+func GetConsent(ctx context.Context, userID string) (*models.Consent, error) {
+	var consent models.Consent
+	err := DB.GetContext(ctx, &consent, `
+		SELECT id, user_id, analytics, marketing, policy_version, ip_hash, created_at
+		FROM consent_records
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &consent, nil
+}
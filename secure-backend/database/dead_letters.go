@@ -0,0 +1,107 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"secure-backend/models"
+
+	"github.com/lib/pq"
+)
+
+// Dead-letter sources
+const (
+	DeadLetterJobs     = "jobs"
+	DeadLetterWebhooks = "webhooks"
+)
+
+// ErrUnknownDeadLetterSource is returned for an unsupported dead-letter source
+var ErrUnknownDeadLetterSource = errors.New("unknown dead-letter source")
+
+// deadLetterQueries select the failed, not discarded items of each source in DeadLetter shape
+var deadLetterQueries = map[string]string{
+	DeadLetterJobs: `
+		SELECT 'jobs' AS source, id, type, attempts, COALESCE(error, '') AS error, params AS payload,
+			user_id::text AS owner_id, created_at, updated_at AS failed_at
+		FROM jobs
+		WHERE status = 'failed' AND discarded_at IS NULL`,
+	DeadLetterWebhooks: `
+		SELECT 'webhooks' AS source, id, event_type AS type, attempts, last_error AS error, payload,
+			'' AS owner_id, created_at, updated_at AS failed_at
+		FROM webhook_events
+		WHERE processed_at IS NULL AND last_error IS NOT NULL AND discarded_at IS NULL`,
+}
+
+// IsValidDeadLetterSource reports whether source is a known dead-letter source
+func IsValidDeadLetterSource(source string) bool {
+	_, ok := deadLetterQueries[source]
+	return ok
+}
+
+// GetDeadLetters returns a page of a source's dead letters (most recently failed first) and their total count
+func GetDeadLetters(ctx context.Context, source string, limit, offset int) ([]models.DeadLetter, int, error) {
+	query, ok := deadLetterQueries[source]
+	if !ok {
+		return nil, 0, ErrUnknownDeadLetterSource
+	}
+
+	var total int
+	if err := DB.GetContext(ctx, &total, `SELECT COUNT(*) FROM (`+query+`) dl`); err != nil {
+		return nil, 0, err
+	}
+
+	letters := []models.DeadLetter{}
+	err := DB.SelectContext(ctx, &letters, `SELECT * FROM (`+query+`) dl ORDER BY failed_at DESC LIMIT $1 OFFSET $2`, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return letters, total, nil
+}
+
+// GetDeadLetter retrieves a single dead letter; it returns sql.ErrNoRows if the item is not (or no longer) failed
+func GetDeadLetter(ctx context.Context, source, id string) (*models.DeadLetter, error) {
+	query, ok := deadLetterQueries[source]
+	if !ok {
+		return nil, ErrUnknownDeadLetterSource
+	}
+
+	var letter models.DeadLetter
+	if err := DB.GetContext(ctx, &letter, `SELECT * FROM (`+query+`) dl WHERE id = $1`, id); err != nil {
+		return nil, err
+	}
+	return &letter, nil
+}
+
+// GetDeliveryErrors returns the error history of an item, oldest first
+func GetDeliveryErrors(ctx context.Context, source, itemID string) ([]models.DeliveryError, error) {
+	history := []models.DeliveryError{}
+	err := DB.SelectContext(ctx, &history, `
+		SELECT id, attempt, error, created_at
+		FROM delivery_errors
+		WHERE source = $1 AND item_id = $2
+		ORDER BY created_at ASC
+	`, source, itemID)
+	return history, err
+}
+
+// DiscardDeadLetters removes failed items from the dead-letter queue without processing them
+// and returns how many were discarded
+func DiscardDeadLetters(ctx context.Context, source string, ids []string) (int64, error) {
+	var query string
+	switch source {
+	case DeadLetterJobs:
+		query = `UPDATE jobs SET discarded_at = now(), updated_at = now()
+			WHERE id = ANY($1) AND status = 'failed' AND discarded_at IS NULL`
+	case DeadLetterWebhooks:
+		query = `UPDATE webhook_events SET discarded_at = now(), updated_at = now()
+			WHERE id = ANY($1) AND processed_at IS NULL AND discarded_at IS NULL`
+	default:
+		return 0, ErrUnknownDeadLetterSource
+	}
+
+	result, err := DB.ExecContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
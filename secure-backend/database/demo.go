@@ -0,0 +1,249 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"secure-backend/models"
+	"secure-backend/money"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// demoResetLock is the advisory lock key held while demo data is reset, so instances
+// running the nightly reset at the same time don't both rebuild it
+const demoResetLock = 0x64656d6f // "demo"
+
+// ResetDemoData replaces the demo accounts and everything they own with seed, in one
+// transaction. Every account on the demo email domain is deleted with its carts, products,
+// jobs and so on, together with the orders placed by them or containing their products and
+// those orders' payments, refunds, disputes and invoices. Categories are matched by slug
+// and kept. The reset is skipped (false) when the demo data was already seeded at or after
+// since, e.g. by another instance; a zero since always resets.
+func ResetDemoData(ctx context.Context, seed *models.DemoSeed, since time.Time) (reset bool, err error) {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, demoResetLock); err != nil {
+		return false, err
+	}
+	var seededAt *time.Time
+	err = tx.GetContext(ctx, &seededAt, `SELECT MIN(created_at) FROM users WHERE email LIKE '%@' || $1::text`, models.DemoEmailDomain)
+	if err != nil {
+		return false, err
+	}
+	if seededAt != nil && !since.IsZero() && !seededAt.Before(since) {
+		return false, nil
+	}
+
+	seedIDs := make([]string, len(seed.Users))
+	for i, user := range seed.Users {
+		seedIDs[i] = user.ID
+	}
+	statements := []string{
+		`CREATE TEMP TABLE demo_users (id UUID PRIMARY KEY) ON COMMIT DROP`,
+		`CREATE TEMP TABLE demo_orders (id UUID PRIMARY KEY) ON COMMIT DROP`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return false, err
+		}
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO demo_users SELECT id FROM users WHERE email LIKE '%@' || $1::text OR id = ANY($2::uuid[])
+	`, models.DemoEmailDomain, pq.Array(seedIDs))
+	if err != nil {
+		return false, err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO demo_orders
+		SELECT id FROM orders WHERE buyer_id IN (SELECT id FROM demo_users)
+		UNION
+		SELECT oi.order_id FROM order_items oi JOIN products p ON p.id = oi.product_id
+		WHERE p.seller_id IN (SELECT id FROM demo_users)
+	`)
+	if err != nil {
+		return false, err
+	}
+
+	// Rows that keep orders, payments and users from being deleted
+	statements = []string{
+		`DELETE FROM refunds WHERE order_id IN (SELECT id FROM demo_orders) OR actor_id IN (SELECT id FROM demo_users)`,
+		`DELETE FROM disputes WHERE order_id IN (SELECT id FROM demo_orders)`,
+		`DELETE FROM invoices WHERE order_id IN (SELECT id FROM demo_orders)`,
+		`DELETE FROM payments WHERE order_id IN (SELECT id FROM demo_orders)`,
+		`DELETE FROM orders WHERE id IN (SELECT id FROM demo_orders)`,
+		`DELETE FROM users WHERE id IN (SELECT id FROM demo_users)`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return false, err
+		}
+	}
+
+	for _, user := range seed.Users {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO users (id, email, role) VALUES ($1, $2, $3)`, user.ID, user.Email, user.Role); err != nil {
+			return false, err
+		}
+	}
+
+	categoryIDs := make(map[string]string, len(seed.Categories))
+	for _, category := range seed.Categories {
+		var id string
+		err := tx.GetContext(ctx, &id, `
+			INSERT INTO categories (name, slug, description) VALUES ($1, $2, $3)
+			ON CONFLICT (slug) DO UPDATE SET slug = EXCLUDED.slug
+			RETURNING id
+		`, category.Name, category.Slug, category.Description)
+		if err != nil {
+			return false, err
+		}
+		categoryIDs[category.Slug] = id
+	}
+
+	productIDs := make([]string, len(seed.Products))
+	stock := make([]int, len(seed.Products))
+	for i, product := range seed.Products {
+		slug, err := nextProductSlug(ctx, tx, product.Slug)
+		if err != nil {
+			return false, err
+		}
+		var categoryID *string
+		if id, ok := categoryIDs[product.CategorySlug]; ok {
+			categoryID = &id
+		}
+
+		productIDs[i], stock[i] = ids.NewID(), product.Stock
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO products (id, name, description, price, stock, status, seller_id, category_id, slug)
+			VALUES ($1, $2, $3, $4, $5, 'published', $6, $7, $8)
+		`, productIDs[i], product.Name, product.Description, product.Price, product.Stock, product.SellerID, categoryID, slug)
+		if err != nil {
+			return false, err
+		}
+		if err := recordPriceChange(ctx, tx, productIDs[i], nil, product.Price, product.SellerID); err != nil {
+			return false, err
+		}
+		sellerID := product.SellerID
+		err = recordStockMovement(ctx, tx, &models.StockMovement{
+			ProductID: productIDs[i], Quantity: product.Stock, Reason: models.MovementOpening, ActorID: &sellerID,
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	for _, order := range seed.Orders {
+		if err := insertDemoOrder(ctx, tx, seed, order, productIDs, stock); err != nil {
+			return false, err
+		}
+	}
+
+	for _, item := range seed.CartItems {
+		version, err := nextCartVersion(ctx, tx, item.UserID)
+		if err != nil {
+			return false, err
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO cart_items (user_id, product_id, quantity, version, added_version, added_price)
+			VALUES ($1, $2, $3, $4, $4, $5)
+		`, item.UserID, productIDs[item.Product], item.Quantity, version, seed.Products[item.Product].Price)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, tx.Commit()
+}
+
+// insertDemoOrder records a sample order the way checkout and the later status changes
+// would have: stock is taken out with committed reservations, tax lines are recorded and
+// each transition is in the status history
+func insertDemoOrder(ctx context.Context, tx *sqlx.Tx, seed *models.DemoSeed, order models.DemoOrder, productIDs []string, stock []int) error {
+	var total money.Amount
+	for _, item := range order.Items {
+		total += seed.Products[item.Product].Price.Times(item.Quantity)
+	}
+
+	orderID := ids.NewID()
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO orders (id, buyer_id, status, total_amount, shipping_address, client_platform, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+	`, orderID, order.BuyerID, order.Status, total, order.ShippingAddress, order.ClientPlatform, order.PlacedAt)
+	if err != nil {
+		return err
+	}
+
+	fulfillment := map[string]string{"paid": "pending", "shipped": "shipped", "delivered": "fulfilled"}[order.Status]
+	for _, item := range order.Items {
+		product := seed.Products[item.Product]
+		productID := productIDs[item.Product]
+		if stock[item.Product] < item.Quantity {
+			return fmt.Errorf("demo orders take more of %q than its stock", product.Name)
+		}
+		stock[item.Product] -= item.Quantity
+
+		if _, err := tx.ExecContext(ctx, `UPDATE products SET stock = stock - $1 WHERE id = $2`, item.Quantity, productID); err != nil {
+			return err
+		}
+		err := recordStockMovement(ctx, tx, &models.StockMovement{
+			ProductID: productID, Quantity: -item.Quantity, Reason: models.MovementReservation, OrderID: &orderID,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO stock_reservations (order_id, product_id, quantity, status, expires_at, created_at)
+			VALUES ($1, $2, $3, 'committed', $4, $4)
+		`, orderID, productID, item.Quantity, order.PlacedAt)
+		if err != nil {
+			return err
+		}
+
+		itemID := ids.NewID()
+		gross := product.Price.Times(item.Quantity)
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO order_items (id, order_id, product_id, quantity, unit_price, total_price, fulfillment_status, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, itemID, orderID, productID, item.Quantity, product.Price, gross, fulfillment, order.PlacedAt)
+		if err != nil {
+			return err
+		}
+		taxLine := models.TaxLine{
+			OrderID: orderID, OrderItemID: itemID, SellerID: product.SellerID,
+			Jurisdiction: seed.Jurisdiction, Rate: seed.TaxRate, GrossAmount: gross,
+		}
+		taxLine.TaxableAmount, taxLine.TaxAmount = models.SplitIncludedTax(gross, seed.TaxRate)
+		if err := recordTaxLine(ctx, tx, &taxLine); err != nil {
+			return err
+		}
+	}
+
+	// pending -> paid by the payment webhook, then shipped and delivered a day apart
+	transitions := []string{"pending", "paid", "shipped", "delivered"}
+	for i := 1; i < len(transitions); i++ {
+		at := order.PlacedAt.Add(time.Duration(i-1) * 24 * time.Hour)
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO order_status_history (order_id, from_status, to_status, actor_role, created_at)
+			VALUES ($1, $2, $3, 'system', $4)
+		`, orderID, transitions[i-1], transitions[i], at)
+		if err != nil {
+			return err
+		}
+		if transitions[i] == order.Status {
+			break
+		}
+	}
+	return nil
+}
+
+// CountNonDemoUsers returns the number of accounts outside the demo email domain
+func CountNonDemoUsers(ctx context.Context) (int, error) {
+	var count int
+	err := DB.GetContext(ctx, &count, `SELECT COUNT(*) FROM users WHERE email NOT LIKE '%@' || $1::text`, models.DemoEmailDomain)
+	return count, err
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"secure-backend/models"
+)
+
+// RegisterDeviceToken stores a push token for a user, re-assigning it if another user registered it before
+func RegisterDeviceToken(ctx context.Context, userID, token, platform string) (*models.DeviceToken, error) {
+	var device models.DeviceToken
+	err := DB.GetContext(ctx, &device, `
+		INSERT INTO device_tokens (user_id, token, platform)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (token) DO UPDATE
+		SET user_id = EXCLUDED.user_id, platform = EXCLUDED.platform, updated_at = now()
+		RETURNING id, user_id, token, platform, created_at, updated_at
+	`, userID, token, platform)
+	if err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// GetDeviceTokens returns every push token registered by a user
+func GetDeviceTokens(ctx context.Context, userID string) ([]models.DeviceToken, error) {
+	var devices []models.DeviceToken
+	err := DB.SelectContext(ctx, &devices, `
+		SELECT id, user_id, token, platform, created_at, updated_at
+		FROM device_tokens
+		WHERE user_id = $1
+	`, userID)
+	return devices, err
+}
+
+// DeleteDeviceToken removes a user's push token
+func DeleteDeviceToken(ctx context.Context, userID, token string) (int64, error) {
+	result, err := DB.ExecContext(ctx, `DELETE FROM device_tokens WHERE user_id = $1 AND token = $2`, userID, token)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// PurgeDeviceToken removes a push token regardless of owner (used when the provider rejects it permanently)
+func PurgeDeviceToken(ctx context.Context, token string) error {
+	_, err := DB.ExecContext(ctx, `DELETE FROM device_tokens WHERE token = $1`, token)
+	return err
+}
@@ -0,0 +1,172 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"secure-backend/models"
+)
+
+const disputeColumns = `id, order_id, payment_id, provider, provider_dispute_id, amount, currency, reason, status,
+	evidence_due_by, evidence, evidence_submitted_at, evidence_submitted_by, closed_at, created_at, updated_at`
+
+// ErrDisputeClosed is returned when evidence is submitted for a dispute that was already decided
+var ErrDisputeClosed = errors.New("dispute is already closed")
+
+// RecordDispute stores a dispute reported by the payment provider, or updates the one already
+// recorded under the same provider ID, and fills in the stored record. A dispute that was
+// closed keeps its final status if older events arrive late. The seller payouts of the order
+// are held while the dispute is open and after it is lost; held and released report whether
+// this call placed or lifted the hold.
+func RecordDispute(ctx context.Context, dispute *models.Dispute) (held, released bool, err error) {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, false, err
+	}
+	defer tx.Rollback()
+
+	closed := models.DisputeClosed(dispute.Status)
+	err = tx.GetContext(ctx, dispute, `
+		INSERT INTO disputes (order_id, payment_id, provider, provider_dispute_id, amount, currency, reason, status,
+			evidence_due_by, closed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, CASE WHEN $10 THEN now() END)
+		ON CONFLICT (provider, provider_dispute_id) DO UPDATE SET
+			amount = EXCLUDED.amount,
+			reason = EXCLUDED.reason,
+			status = CASE WHEN disputes.closed_at IS NULL THEN EXCLUDED.status ELSE disputes.status END,
+			evidence_due_by = EXCLUDED.evidence_due_by,
+			closed_at = COALESCE(disputes.closed_at, EXCLUDED.closed_at)
+		RETURNING `+disputeColumns,
+		dispute.OrderID, dispute.PaymentID, dispute.Provider, dispute.ProviderDisputeID, dispute.Amount,
+		dispute.Currency, dispute.Reason, dispute.Status, dispute.EvidenceDueBy, closed)
+	if err != nil {
+		return false, false, err
+	}
+
+	if dispute.Status == models.DisputeWon || dispute.Status == models.DisputeWarningClosed {
+		result, err := tx.ExecContext(ctx, `
+			UPDATE payout_holds SET released_at = now()
+			WHERE dispute_id = $1 AND released_at IS NULL
+		`, dispute.ID)
+		if err != nil {
+			return false, false, err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return false, false, err
+		}
+		released = rowsAffected > 0
+	} else {
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO payout_holds (order_id, dispute_id, reason)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (dispute_id) DO NOTHING
+		`, dispute.OrderID, dispute.ID, "dispute "+dispute.ProviderDisputeID+" ("+dispute.Reason+")")
+		if err != nil {
+			return false, false, err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return false, false, err
+		}
+		held = rowsAffected > 0
+	}
+
+	return held, released, tx.Commit()
+}
+
+// GetDispute returns a dispute by ID
+func GetDispute(ctx context.Context, id string) (*models.Dispute, error) {
+	var dispute models.Dispute
+	err := DB.GetContext(ctx, &dispute, `SELECT `+disputeColumns+` FROM disputes WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	return &dispute, nil
+}
+
+// GetDisputes returns a page of open (or closed) disputes, those with the earliest evidence
+// deadline first, and the total count
+func GetDisputes(ctx context.Context, closed bool, limit, offset int) ([]models.Dispute, int, error) {
+	var total int
+	err := DB.GetContext(ctx, &total, `SELECT COUNT(*) FROM disputes WHERE (closed_at IS NOT NULL) = $1`, closed)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	disputes := []models.Dispute{}
+	err = DB.SelectContext(ctx, &disputes, `
+		SELECT `+disputeColumns+`
+		FROM disputes
+		WHERE (closed_at IS NOT NULL) = $1
+		ORDER BY evidence_due_by ASC NULLS LAST, created_at
+		LIMIT $2 OFFSET $3
+	`, closed, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return disputes, total, nil
+}
+
+// GetDisputesByOrder returns the disputes of an order, oldest first
+func GetDisputesByOrder(ctx context.Context, orderID string) ([]models.Dispute, error) {
+	disputes := []models.Dispute{}
+	err := DB.SelectContext(ctx, &disputes, `SELECT `+disputeColumns+` FROM disputes WHERE order_id = $1 ORDER BY created_at`, orderID)
+	return disputes, err
+}
+
+// HasOpenDispute reports whether one of the order's payments is being disputed
+func HasOpenDispute(ctx context.Context, orderID string) (bool, error) {
+	var open bool
+	err := DB.GetContext(ctx, &open, `SELECT EXISTS (SELECT 1 FROM disputes WHERE order_id = $1 AND closed_at IS NULL)`, orderID)
+	return open, err
+}
+
+// SaveDisputeEvidence merges evidence fields into a dispute, marking the evidence submitted
+// if it was sent to the provider for review, and records it in the admin audit log in the same
+// transaction. Returns sql.ErrNoRows if the dispute doesn't exist and ErrDisputeClosed if it
+// was already decided.
+func SaveDisputeEvidence(ctx context.Context, id string, evidence models.DisputeEvidence, submitted bool, audit *models.AuditEntry) (*models.Dispute, error) {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var dispute models.Dispute
+	err = tx.GetContext(ctx, &dispute, `SELECT `+disputeColumns+` FROM disputes WHERE id = $1 FOR UPDATE`, id)
+	if err != nil {
+		return nil, err
+	}
+	if dispute.ClosedAt != nil {
+		return nil, ErrDisputeClosed
+	}
+
+	err = tx.GetContext(ctx, &dispute, `
+		UPDATE disputes SET
+			evidence = evidence || $2,
+			evidence_submitted_at = CASE WHEN $3 THEN now() ELSE evidence_submitted_at END,
+			evidence_submitted_by = CASE WHEN $3 THEN $4 ELSE evidence_submitted_by END
+		WHERE id = $1
+		RETURNING `+disputeColumns,
+		id, evidence, submitted, audit.ActorID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordAdminAudit(ctx, tx, audit); err != nil {
+		return nil, err
+	}
+	return &dispute, tx.Commit()
+}
+
+// GetPayoutHolds returns the holds placed on an order's seller payouts, oldest first
+func GetPayoutHolds(ctx context.Context, orderID string) ([]models.PayoutHold, error) {
+	holds := []models.PayoutHold{}
+	err := DB.SelectContext(ctx, &holds, `
+		SELECT id, order_id, dispute_id, reconciliation_id, reason, released_at, created_at
+		FROM payout_holds
+		WHERE order_id = $1
+		ORDER BY created_at
+	`, orderID)
+	return holds, err
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"secure-backend/models"
+	"time"
+)
+
+// StartPaymentDunning starts retrying an order's failed payment, first at firstAttemptAt and
+// for the last time at finalAttemptAt. The order's stock reservations are extended to holdUntil
+// so the stock stays held while retries are pending. It reports false if the order's payment
+// is already being retried, e.g. when a retry itself fails.
+func StartPaymentDunning(ctx context.Context, orderID, paymentID string, firstAttemptAt, finalAttemptAt, holdUntil time.Time) (bool, error) {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO payment_dunning (order_id, payment_id, next_attempt_at, final_attempt_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (order_id) DO NOTHING
+	`, orderID, paymentID, firstAttemptAt, finalAttemptAt)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE stock_reservations SET expires_at = GREATEST(expires_at, $2), updated_at = now()
+		WHERE order_id = $1 AND status = 'active'
+	`, orderID, holdUntil)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// GetPaymentDunning returns the payment retries of an order
+func GetPaymentDunning(ctx context.Context, orderID string) (*models.PaymentDunning, error) {
+	var dunning models.PaymentDunning
+	err := DB.GetContext(ctx, &dunning, `
+		SELECT d.order_id, d.payment_id, p.provider_payment_id, d.status, d.attempts, d.next_attempt_at,
+			d.final_attempt_at, COALESCE(d.last_error, '') AS last_error, d.created_at, d.updated_at
+		FROM payment_dunning d
+		JOIN payments p ON d.payment_id = p.id
+		WHERE d.order_id = $1
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	return &dunning, nil
+}
+
+// RecordDunningAttempt counts a failed retry and when the next one is due (nil after the last).
+// It returns sql.ErrNoRows if the order's retries are no longer active.
+func RecordDunningAttempt(ctx context.Context, orderID, lastError string, nextAttemptAt *time.Time) error {
+	result, err := DB.ExecContext(ctx, `
+		UPDATE payment_dunning
+		SET attempts = attempts + 1, last_error = NULLIF($2, ''), next_attempt_at = $3
+		WHERE order_id = $1 AND status = $4
+	`, orderID, lastError, nextAttemptAt, models.DunningActive)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CloseDunning ends the payment retries of an order as recovered or cancelled.
+// It returns sql.ErrNoRows if the order has no active retries.
+func CloseDunning(ctx context.Context, orderID, status string) error {
+	result, err := DB.ExecContext(ctx, `
+		UPDATE payment_dunning SET status = $2, next_attempt_at = NULL
+		WHERE order_id = $1 AND status = $3
+	`, orderID, status, models.DunningActive)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"secure-backend/models"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrAlreadyReviewed is returned when a duplicate listing flag was already reviewed
+var ErrAlreadyReviewed = errors.New("duplicate listing was already reviewed")
+
+// fingerprintColumns lists the product columns selected into models.ListingFingerprint
+const fingerprintColumns = `p.id, p.seller_id, p.name, COALESCE(p.description, '') AS description,
+	COALESCE(p.image_hash, '') AS image_hash, p.created_at`
+
+// duplicateListingColumns lists the columns selected into models.DuplicateListing
+const duplicateListingColumns = `d.id, d.product_id, p.name AS product_name, p.seller_id,
+	d.duplicate_of_id, o.name AS duplicate_of_name, o.seller_id AS duplicate_of_seller_id,
+	d.score, d.reasons, d.status, d.reviewed_by, d.review_note, d.reviewed_at, d.created_at`
+
+// GetUncheckedListings returns published listings that weren't compared against the
+// catalog since they last changed, oldest change first
+func GetUncheckedListings(ctx context.Context, limit int) ([]models.ListingFingerprint, error) {
+	listings := []models.ListingFingerprint{}
+	err := DB.SelectContext(ctx, &listings, `
+		SELECT `+fingerprintColumns+`
+		FROM products p
+		LEFT JOIN product_duplicate_checks c ON c.product_id = p.id
+		WHERE p.status = 'published' AND (c.checked_at IS NULL OR c.checked_at < p.updated_at)
+		ORDER BY p.updated_at
+		LIMIT $1
+	`, limit)
+	return listings, err
+}
+
+// GetDuplicateCandidates returns other sellers' published listings that might duplicate the
+// given one: those with the same uploaded image and those whose text best matches its name
+func GetDuplicateCandidates(ctx context.Context, listing *models.ListingFingerprint, limit int) ([]models.ListingFingerprint, error) {
+	// Any of the name's words may match; the similarity check decides how alike they are
+	words := strings.FieldsFunc(strings.ToLower(listing.Name), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 127)
+	})
+	query := strings.Join(words, " or ")
+
+	candidates := []models.ListingFingerprint{}
+	err := DB.SelectContext(ctx, &candidates, `
+		SELECT `+fingerprintColumns+`
+		FROM products p, websearch_to_tsquery('english', $3) AS q(query)
+		WHERE p.status = 'published' AND p.seller_id <> $1 AND p.id <> $2
+			AND (p.image_hash = NULLIF($4, '') OR p.search_vector @@ q.query)
+		ORDER BY p.image_hash = NULLIF($4, '') DESC NULLS LAST, ts_rank_cd(p.search_vector, q.query) DESC
+		LIMIT $5
+	`, listing.SellerID, listing.ID, query, listing.ImageHash, limit)
+	return candidates, err
+}
+
+// RecordDuplicateCheck stores the duplicates found for a listing and marks it checked at
+// checkedAt. Pairs flagged before, including dismissed ones, aren't flagged again.
+func RecordDuplicateCheck(ctx context.Context, productID string, duplicates []models.DuplicateListing, checkedAt time.Time) (int, error) {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	flagged := 0
+	for _, d := range duplicates {
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO duplicate_listings (product_id, duplicate_of_id, score, reasons)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (product_id, duplicate_of_id) DO NOTHING
+		`, d.ProductID, d.DuplicateOfID, d.Score, pq.Array(d.Reasons))
+		if err != nil {
+			return 0, err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		flagged += int(rowsAffected)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO product_duplicate_checks (product_id, checked_at)
+		VALUES ($1, $2)
+		ON CONFLICT (product_id) DO UPDATE SET checked_at = EXCLUDED.checked_at
+	`, productID, checkedAt)
+	if err != nil {
+		return 0, err
+	}
+
+	return flagged, tx.Commit()
+}
+
+// GetDuplicateListings returns a page of duplicate listing flags with the given status
+// (newest first) and the total count
+func GetDuplicateListings(ctx context.Context, status string, limit, offset int) ([]models.DuplicateListing, int, error) {
+	var total int
+	err := DB.GetContext(ctx, &total, `SELECT COUNT(*) FROM duplicate_listings WHERE status = $1`, status)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	duplicates := []models.DuplicateListing{}
+	err = DB.SelectContext(ctx, &duplicates, `
+		SELECT `+duplicateListingColumns+`
+		FROM duplicate_listings d
+		JOIN products p ON p.id = d.product_id
+		JOIN products o ON o.id = d.duplicate_of_id
+		WHERE d.status = $1
+		ORDER BY d.score DESC, d.created_at DESC
+		LIMIT $2 OFFSET $3
+	`, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return duplicates, total, nil
+}
+
+// ReviewDuplicateListing records an admin's decision on a pending duplicate listing flag.
+// Confirming archives the newer listing. The decision is written to the admin audit log
+// in the same transaction. Returns sql.ErrNoRows if the flag doesn't exist.
+func ReviewDuplicateListing(ctx context.Context, id, status, note string, audit *models.AuditEntry) (*models.DuplicateListing, error) {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var current string
+	err = tx.GetContext(ctx, &current, `SELECT status FROM duplicate_listings WHERE id = $1 FOR UPDATE`, id)
+	if err != nil {
+		return nil, err
+	}
+	if current != models.DuplicatePending {
+		return nil, ErrAlreadyReviewed
+	}
+
+	var productID string
+	err = tx.GetContext(ctx, &productID, `
+		UPDATE duplicate_listings
+		SET status = $2, review_note = $3, reviewed_by = $4, reviewed_at = now()
+		WHERE id = $1
+		RETURNING product_id
+	`, id, status, note, audit.ActorID)
+	if err != nil {
+		return nil, err
+	}
+
+	if status == models.DuplicateConfirmed {
+		_, err := tx.ExecContext(ctx, `UPDATE products SET status = 'archived', updated_at = now() WHERE id = $1`, productID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := recordAdminAudit(ctx, tx, audit); err != nil {
+		return nil, err
+	}
+
+	var duplicate models.DuplicateListing
+	err = tx.GetContext(ctx, &duplicate, `
+		SELECT `+duplicateListingColumns+`
+		FROM duplicate_listings d
+		JOIN products p ON p.id = d.product_id
+		JOIN products o ON o.id = d.duplicate_of_id
+		WHERE d.id = $1
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &duplicate, tx.Commit()
+}
@@ -0,0 +1,237 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"secure-backend/models"
+	"time"
+)
+
+// erasureColumns are selected for erasure requests
+const erasureColumns = `r.id, r.user_id, r.status, r.confirm_by, r.confirmed_at, r.scheduled_for,
+	r.completed_at, r.cancelled_at, r.created_at`
+
+// erasedTokensBefore is the token revocation cut-off of erased accounts: every token is refused
+var erasedTokensBefore = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// ErrErasurePending is returned when requesting erasure while another request is in progress
+var ErrErasurePending = errors.New("an erasure request is already in progress")
+
+// CreateErasureRequest records a user's erasure request awaiting confirmation until confirmBy.
+// Unconfirmed requests that have run out are marked expired first. Returns ErrErasurePending
+// if a request is still awaiting confirmation or scheduled.
+func CreateErasureRequest(ctx context.Context, userID string, confirmBy, now time.Time) (*models.ErasureRequest, error) {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE erasure_requests SET status = 'expired'
+		WHERE user_id = $1 AND status = 'awaiting_confirmation' AND confirm_by <= $2
+	`, userID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var request models.ErasureRequest
+	err = tx.GetContext(ctx, &request, `
+		INSERT INTO erasure_requests AS r (id, user_id, status, confirm_by, created_at)
+		VALUES ($1, $2, 'awaiting_confirmation', $3, $4)
+		RETURNING `+erasureColumns, ids.NewID(), userID, confirmBy, now)
+	if hasErrorCode(err, uniqueViolation) {
+		return nil, ErrErasurePending
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &request, tx.Commit()
+}
+
+// GetErasureRequest returns an erasure request by ID
+func GetErasureRequest(ctx context.Context, id string) (*models.ErasureRequest, error) {
+	var request models.ErasureRequest
+	err := DB.GetContext(ctx, &request, `SELECT `+erasureColumns+` FROM erasure_requests r WHERE r.id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// GetLatestErasureRequest returns the user's most recent erasure request (sql.ErrNoRows if none)
+func GetLatestErasureRequest(ctx context.Context, userID string) (*models.ErasureRequest, error) {
+	var request models.ErasureRequest
+	err := DB.GetContext(ctx, &request, `
+		SELECT `+erasureColumns+` FROM erasure_requests r
+		WHERE r.user_id = $1
+		ORDER BY r.created_at DESC
+		LIMIT 1
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// ConfirmErasureRequest schedules an erasure request awaiting confirmation (before its
+// confirm_by) for scheduledFor. Confirming a request that is already scheduled returns it
+// unchanged. Returns sql.ErrNoRows if the request can no longer be confirmed.
+func ConfirmErasureRequest(ctx context.Context, id string, now, scheduledFor time.Time) (*models.ErasureRequest, error) {
+	var request models.ErasureRequest
+	err := DB.GetContext(ctx, &request, `
+		UPDATE erasure_requests r
+		SET status = 'scheduled', confirmed_at = $2, scheduled_for = $3
+		WHERE r.id = $1 AND r.status = 'awaiting_confirmation' AND r.confirm_by > $2
+		RETURNING `+erasureColumns, id, now, scheduledFor)
+	if err == sql.ErrNoRows {
+		existing, getErr := GetErasureRequest(ctx, id)
+		if getErr == nil && existing.Status == models.ErasureScheduled {
+			return existing, nil
+		}
+		return nil, sql.ErrNoRows
+	} else if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// CancelErasureRequest cancels the user's erasure request that is awaiting confirmation or
+// scheduled. Returns sql.ErrNoRows if there is none.
+func CancelErasureRequest(ctx context.Context, userID string, now time.Time) (*models.ErasureRequest, error) {
+	var request models.ErasureRequest
+	err := DB.GetContext(ctx, &request, `
+		UPDATE erasure_requests r SET status = 'cancelled', cancelled_at = $2
+		WHERE r.user_id = $1 AND r.status IN ('awaiting_confirmation', 'scheduled')
+		RETURNING `+erasureColumns, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// GetErasureRequests returns a page of erasure requests with a status and the total count,
+// the scheduled ones by when they run and the others newest first
+func GetErasureRequests(ctx context.Context, status string, limit, offset int) ([]models.ErasureRequest, int, error) {
+	var total int
+	err := DB.GetContext(ctx, &total, `SELECT COUNT(*) FROM erasure_requests WHERE status = $1`, status)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	requests := []models.ErasureRequest{}
+	err = DB.SelectContext(ctx, &requests, `
+		SELECT `+erasureColumns+` FROM erasure_requests r
+		WHERE r.status = $1
+		ORDER BY r.scheduled_for, r.created_at DESC
+		LIMIT $2 OFFSET $3
+	`, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return requests, total, nil
+}
+
+// GetDueErasureRequests returns up to limit scheduled erasure requests whose time has come
+func GetDueErasureRequests(ctx context.Context, now time.Time, limit int) ([]string, error) {
+	requestIDs := []string{}
+	err := DB.SelectContext(ctx, &requestIDs, `
+		SELECT id FROM erasure_requests
+		WHERE status = 'scheduled' AND scheduled_for <= $1
+		ORDER BY scheduled_for
+		LIMIT $2
+	`, now, limit)
+	return requestIDs, err
+}
+
+// EraseUser carries out a scheduled erasure request once it is due, in one transaction. The
+// user's carts, wishlist, events, devices, sessions, consent and error reports are deleted.
+// Addresses on orders and address changes and notes on their status changes are cleared, and
+// the email is replaced with models.ErasedEmail. Orders, payments, refunds, invoices, tax lines
+// and store credit are kept for the accounts. Result files of the user's exports expire, every
+// token of the account is refused and the erasure is recorded in the admin audit log.
+// erased is false if the request was cancelled, isn't due or is being erased elsewhere.
+func EraseUser(ctx context.Context, requestID string, now time.Time) (erased bool, err error) {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var userID string
+	err = tx.GetContext(ctx, &userID, `
+		SELECT user_id FROM erasure_requests
+		WHERE id = $1 AND status = 'scheduled' AND scheduled_for <= $2
+		FOR UPDATE SKIP LOCKED
+	`, requestID, now)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	statements := []string{
+		`DELETE FROM cart_items WHERE user_id = $1`,
+		`DELETE FROM cart_item_tombstones WHERE user_id = $1`,
+		`DELETE FROM cart_versions WHERE user_id = $1`,
+		`DELETE FROM cart_abandonments WHERE user_id = $1`,
+		`DELETE FROM cart_shares WHERE user_id = $1`,
+		`DELETE FROM cart_events WHERE user_id = $1`,
+		`DELETE FROM saved_items WHERE user_id = $1`,
+		`DELETE FROM wishlist_items WHERE user_id = $1`,
+		`DELETE FROM saved_searches WHERE user_id = $1`,
+		`DELETE FROM device_tokens WHERE user_id = $1`,
+		`DELETE FROM sessions WHERE user_id = $1`,
+		`DELETE FROM consent_records WHERE user_id = $1`,
+		`DELETE FROM client_errors WHERE user_id = $1`,
+		`DELETE FROM api_usage WHERE user_id = $1`,
+		`UPDATE recommendation_events SET user_id = NULL WHERE user_id = $1`,
+		`UPDATE orders SET shipping_address = NULL, updated_at = now() WHERE buyer_id = $1`,
+		`UPDATE order_address_changes SET old_address = '', new_address = '', resolution_note = ''
+			WHERE order_id IN (SELECT id FROM orders WHERE buyer_id = $1)`,
+		`UPDATE order_status_history SET note = NULL WHERE actor_id = $1`,
+		`UPDATE messages SET body = '' WHERE sender_id = $1`,
+		`UPDATE seller_ratings SET comment = '' WHERE buyer_id = $1`,
+		`UPDATE jobs SET result_expires_at = now() WHERE user_id = $1 AND result_path IS NOT NULL`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement, userID); err != nil {
+			return false, err
+		}
+	}
+
+	erasedEmail := models.ErasedEmail(userID)
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET email = $2, updated_at = now() WHERE id = $1`, userID, erasedEmail); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE admin_audit_log SET actor_email = $2 WHERE actor_id = $1`, userID, erasedEmail); err != nil {
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO token_revocations (user_id, revoked_before, reason)
+		VALUES ($1, $2, 'account erased')
+		ON CONFLICT (user_id) DO UPDATE SET revoked_before = EXCLUDED.revoked_before, reason = EXCLUDED.reason,
+			revoked_by = NULL, updated_at = now()
+	`, userID, erasedTokensBefore)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE erasure_requests SET status = 'completed', completed_at = $2 WHERE id = $1`, requestID, now); err != nil {
+		return false, err
+	}
+
+	err = recordAdminAudit(ctx, tx, &models.AuditEntry{
+		ActorEmail: "system",
+		Action:     models.AuditUserErased,
+		Detail:     "erased personal data of user " + userID + " (request " + requestID + ")",
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
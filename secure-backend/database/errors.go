@@ -0,0 +1,38 @@
+package database
+
+import "errors"
+
+// Postgres error codes the package acts on
+const (
+	uniqueViolation      = "23505"
+	foreignKeyViolation  = "23503"
+	serializationFailure = "40001"
+	deadlockDetected     = "40P01"
+	adminShutdown        = "57P01"
+	crashShutdown        = "57P02"
+	cannotConnectNow     = "57P03"
+
+	connectionExceptionClass = "08"
+)
+
+// sqlStateError is implemented by the errors of Postgres drivers that carry a SQLSTATE code:
+// lib/pq's *pq.Error and pgx's *pgconn.PgError both do, so code checks don't depend on the
+// driver in use
+type sqlStateError interface {
+	error
+	SQLState() string
+}
+
+// sqlState returns the SQLSTATE code of a Postgres error, or "" for other errors
+func sqlState(err error) string {
+	var stateErr sqlStateError
+	if errors.As(err, &stateErr) {
+		return stateErr.SQLState()
+	}
+	return ""
+}
+
+// hasErrorCode reports whether err is a Postgres error with the given SQLSTATE code
+func hasErrorCode(err error, code string) bool {
+	return err != nil && sqlState(err) == code
+}
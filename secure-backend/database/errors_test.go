@@ -0,0 +1,27 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// pgxError has the shape of pgx's *pgconn.PgError
+type pgxError struct{ Code string }
+
+func (e *pgxError) Error() string    { return "ERROR (SQLSTATE " + e.Code + ")" }
+func (e *pgxError) SQLState() string { return e.Code }
+
+func TestHasErrorCode(t *testing.T) {
+	assert.True(t, hasErrorCode(&pq.Error{Code: uniqueViolation}, uniqueViolation))
+	assert.True(t, hasErrorCode(fmt.Errorf("insert: %w", &pgxError{Code: uniqueViolation}), uniqueViolation))
+	assert.True(t, retryableTxError(&pgxError{Code: deadlockDetected}))
+
+	assert.False(t, hasErrorCode(&pq.Error{Code: foreignKeyViolation}, uniqueViolation))
+	assert.False(t, hasErrorCode(errors.New("23505"), uniqueViolation))
+	assert.False(t, hasErrorCode(nil, uniqueViolation))
+	assert.Equal(t, "", sqlState(errors.New("connection refused")))
+}
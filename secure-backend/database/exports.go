@@ -0,0 +1,135 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"secure-backend/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Order export scopes
+const (
+	OrderExportScopeBuyer  = "buyer"  // orders placed by the user
+	OrderExportScopeSeller = "seller" // order items for the user's products
+	OrderExportScopeAll    = "all"    // every order (admins)
+)
+
+// orderExportFilter returns the WHERE clause and arguments for an order export scope
+func orderExportFilter(scope, userID string) (string, []interface{}, error) {
+	switch scope {
+	case OrderExportScopeBuyer:
+		return "WHERE o.buyer_id = $1", []interface{}{userID}, nil
+	case OrderExportScopeSeller:
+		return "WHERE p.seller_id = $1", []interface{}{userID}, nil
+	case OrderExportScopeAll:
+		return "", nil, nil
+	}
+	return "", nil, fmt.Errorf("unknown order export scope %q", scope)
+}
+
+// CountOrderExportRows returns the number of rows an order export will contain
+func CountOrderExportRows(ctx context.Context, scope, userID string) (int, error) {
+	where, args, err := orderExportFilter(scope, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	err = DB.GetContext(ctx, &count, `
+		SELECT COUNT(*)
+		FROM order_items oi
+		JOIN orders o ON oi.order_id = o.id
+		JOIN products p ON oi.product_id = p.id
+		`+where, args...)
+	return count, err
+}
+
+// streamingTx begins a read-only transaction without the statement timeout, for queries
+// whose rows are streamed to a slow consumer such as an export file. The rows are read from
+// one snapshot; the caller's context still cancels the query.
+func streamingTx(ctx context.Context) (*sqlx.Tx, error) {
+	tx, err := DB.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `SET LOCAL statement_timeout = 0`); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return tx, nil
+}
+
+// ForEachOrderExportRow streams order export rows (oldest first) to fn without loading them all in memory
+func ForEachOrderExportRow(ctx context.Context, scope, userID string, fn func(row *models.OrderExportRow) error) error {
+	where, args, err := orderExportFilter(scope, userID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := streamingTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryxContext(ctx, `
+		SELECT
+			o.id AS order_id, o.created_at AS ordered_at, o.status AS order_status, o.buyer_id, o.client_platform,
+			oi.product_id, p.name AS product_name, p.seller_id, oi.quantity, oi.unit_price, oi.total_price,
+			oi.fulfillment_status
+		FROM order_items oi
+		JOIN orders o ON oi.order_id = o.id
+		JOIN products p ON oi.product_id = p.id
+		`+where+`
+		ORDER BY o.created_at, oi.created_at`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row models.OrderExportRow
+		if err := rows.StructScan(&row); err != nil {
+			return err
+		}
+		if err := fn(&row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ForEachRow streams the rows of a query as column maps. Callers must only pass
+// queries built from constants; arguments are bound as parameters.
+func ForEachRow(ctx context.Context, query string, args []interface{}, fn func(row map[string]interface{}) error) error {
+	tx, err := streamingTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		row := make(map[string]interface{})
+		if err := rows.MapScan(row); err != nil {
+			return err
+		}
+		// Text, numeric and JSON columns are returned as bytes by the driver
+		for key, value := range row {
+			if b, ok := value.([]byte); ok {
+				row[key] = string(b)
+			}
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
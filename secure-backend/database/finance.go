@@ -0,0 +1,107 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"secure-backend/models"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// dateLayout formats financial_periods.period, a DATE, independently of the session time zone
+const dateLayout = "2006-01-02"
+
+// ErrPeriodAlreadyClosed is returned when closing a month that is already closed
+var ErrPeriodAlreadyClosed = errors.New("period is already closed")
+
+// GetFinancialSummary returns the summary of the month starting at period: the figures it was
+// closed with, or the live figures while it is open
+func GetFinancialSummary(ctx context.Context, period time.Time) (*models.FinancialSummary, error) {
+	var summary models.FinancialSummary
+	err := DB.GetContext(ctx, &summary, `
+		SELECT gross_sales, refunds, fees, net, order_count, refund_count, closed_by, closed_at
+		FROM financial_periods
+		WHERE period = $1
+	`, period.Format(dateLayout))
+	if err == nil {
+		summary.Period = period.Format("2006-01")
+		summary.Closed = true
+		return &summary, nil
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+	return computeFinancialSummary(ctx, DB, period)
+}
+
+// computeFinancialSummary adds up the month's paid orders, succeeded refunds and provider fees
+func computeFinancialSummary(ctx context.Context, q sqlx.QueryerContext, period time.Time) (*models.FinancialSummary, error) {
+	from, to := period, period.AddDate(0, 1, 0)
+	summary := models.FinancialSummary{Period: period.Format("2006-01")}
+
+	err := sqlx.GetContext(ctx, q, &summary, `
+		SELECT COUNT(*) AS order_count, COALESCE(SUM(o.total_amount), 0) AS gross_sales,
+			COALESCE(SUM((SELECT SUM(p.fee) FROM payments p WHERE p.order_id = o.id)), 0) AS fees
+		FROM orders o
+		WHERE o.created_at >= $1 AND o.created_at < $2
+			AND EXISTS (SELECT 1 FROM order_status_history h WHERE h.order_id = o.id AND h.to_status = 'paid')
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	err = sqlx.GetContext(ctx, q, &summary, `
+		SELECT COUNT(*) AS refund_count, COALESCE(SUM(amount), 0) AS refunds
+		FROM refunds
+		WHERE status = 'succeeded' AND created_at >= $1 AND created_at < $2
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	summary.Net = summary.GrossSales - summary.Refunds - summary.Fees
+	return &summary, nil
+}
+
+// CloseFinancialPeriod closes the month starting at period with its current figures and records
+// it in the admin audit log in the same transaction. Returns ErrPeriodAlreadyClosed if it was
+// closed before.
+func CloseFinancialPeriod(ctx context.Context, period time.Time, audit *models.AuditEntry) (*models.FinancialSummary, error) {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	summary, err := computeFinancialSummary(ctx, tx, period)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO financial_periods (period, gross_sales, refunds, fees, net, order_count, refund_count, closed_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (period) DO NOTHING
+		RETURNING closed_by, closed_at
+	`, period.Format(dateLayout), summary.GrossSales, summary.Refunds, summary.Fees, summary.Net, summary.OrderCount,
+		summary.RefundCount, audit.ActorID).Scan(&summary.ClosedBy, &summary.ClosedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrPeriodAlreadyClosed
+	} else if err != nil {
+		return nil, err
+	}
+	summary.Closed = true
+
+	if err := recordAdminAudit(ctx, tx, audit); err != nil {
+		return nil, err
+	}
+	return summary, tx.Commit()
+}
+
+// IsPeriodClosed reports whether the month containing t is closed
+func IsPeriodClosed(ctx context.Context, t time.Time) (bool, error) {
+	var closed bool
+	err := DB.GetContext(ctx, &closed, `SELECT EXISTS (SELECT 1 FROM financial_periods WHERE period = $1)`,
+		models.PeriodStart(t).Format(dateLayout))
+	return closed, err
+}
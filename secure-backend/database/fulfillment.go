@@ -0,0 +1,36 @@
+package database
+
+import (
+	"context"
+	"secure-backend/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// fulfillmentItemsQuery selects a seller's order items that are still to be shipped in paid
+// (or partly shipped) orders; callers add the batch condition
+const fulfillmentItemsQuery = `
+	SELECT oi.id AS order_item_id, oi.order_id, o.created_at AS ordered_at,
+		COALESCE(o.shipping_address, '') AS shipping_address,
+		p.id AS product_id, p.name AS product_name, p.slug, p.shelf_location, p.weight_kg, oi.quantity
+	FROM order_items oi
+	JOIN products p ON p.id = oi.product_id
+	JOIN orders o ON o.id = oi.order_id
+	WHERE p.seller_id = $1 AND oi.fulfillment_status = 'pending' AND o.status IN ('paid', 'shipped')`
+
+// GetFulfillmentItemsPlacedBetween returns the seller's items still to be shipped in orders
+// placed in [from, to)
+func GetFulfillmentItemsPlacedBetween(ctx context.Context, sellerID string, from, to time.Time) ([]models.FulfillmentItem, error) {
+	items := []models.FulfillmentItem{}
+	err := DB.SelectContext(ctx, &items, fulfillmentItemsQuery+` AND o.created_at >= $2 AND o.created_at < $3`, sellerID, from, to)
+	return items, err
+}
+
+// GetFulfillmentItemsOfOrders returns the seller's items still to be shipped in the orders.
+// IDs of other orders, or of orders without such items, are ignored.
+func GetFulfillmentItemsOfOrders(ctx context.Context, sellerID string, orderIDs []string) ([]models.FulfillmentItem, error) {
+	items := []models.FulfillmentItem{}
+	err := DB.SelectContext(ctx, &items, fulfillmentItemsQuery+` AND o.id::text = ANY($2)`, sellerID, pq.Array(orderIDs))
+	return items, err
+}
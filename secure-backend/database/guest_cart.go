@@ -0,0 +1,248 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"secure-backend/models"
+	"time"
+)
+
+// CreateCartSession starts an anonymous cart that expires at expiresAt
+func CreateCartSession(ctx context.Context, expiresAt time.Time) (*models.CartSession, error) {
+	var session models.CartSession
+	err := DB.GetContext(ctx, &session, `
+		INSERT INTO cart_sessions (expires_at)
+		VALUES ($1)
+		RETURNING id, expires_at, created_at
+	`, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetCartSession returns the unexpired anonymous cart with the given ID
+func GetCartSession(ctx context.Context, id string, now time.Time) (*models.CartSession, error) {
+	var session models.CartSession
+	err := DB.GetContext(ctx, &session, `
+		SELECT id, expires_at, created_at
+		FROM cart_sessions
+		WHERE id = $1 AND expires_at > $2
+	`, id, now)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetGuestCartItems retrieves the items of an anonymous cart with product details
+func GetGuestCartItems(ctx context.Context, cartSessionID string) ([]models.GuestCartItemWithProduct, error) {
+	var items []models.GuestCartItemWithProduct
+
+	rows, err := DB.QueryContext(ctx, `
+		SELECT
+			gi.id, gi.cart_session_id, gi.product_id, gi.quantity, gi.created_at, gi.updated_at,
+			p.id, p.name, p.description, p.price, p.image, p.stock, p.status, p.seller_id, p.created_at, p.updated_at
+		FROM guest_cart_items gi
+		JOIN products p ON gi.product_id = p.id
+		WHERE gi.cart_session_id = $1
+		ORDER BY gi.created_at DESC`, cartSessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.GuestCartItemWithProduct
+		err := rows.Scan(
+			&item.ID, &item.CartSessionID, &item.ProductID, &item.Quantity, &item.CreatedAt, &item.UpdatedAt,
+			&item.Product.ID, &item.Product.Name, &item.Product.Description, &item.Product.Price,
+			&item.Product.Image, &item.Product.Stock, &item.Product.Status, &item.Product.SellerID,
+			&item.Product.CreatedAt, &item.Product.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// AddToGuestCart adds a product to an anonymous cart, or increases its quantity if already there
+func AddToGuestCart(ctx context.Context, cartSessionID, productID string, quantity int) (*models.GuestCartItem, error) {
+	var item models.GuestCartItem
+	err := DB.GetContext(ctx, &item, `
+		INSERT INTO guest_cart_items (cart_session_id, product_id, quantity)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (cart_session_id, product_id) DO UPDATE
+		SET quantity = LEAST(guest_cart_items.quantity + EXCLUDED.quantity, $4), updated_at = now()
+		RETURNING id, cart_session_id, product_id, quantity, created_at, updated_at
+	`, cartSessionID, productID, quantity, maxCartItemQuantity)
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// UpdateGuestCartItemQuantity sets the quantity of an anonymous cart item (0 removes it)
+func UpdateGuestCartItemQuantity(ctx context.Context, cartItemID, cartSessionID string, quantity int) error {
+	if quantity <= 0 {
+		return RemoveFromGuestCart(ctx, cartItemID, cartSessionID)
+	}
+
+	result, err := DB.ExecContext(ctx, `
+		UPDATE guest_cart_items
+		SET quantity = $1
+		WHERE id = $2 AND cart_session_id = $3
+	`, quantity, cartItemID, cartSessionID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// RemoveFromGuestCart removes an item from an anonymous cart
+func RemoveFromGuestCart(ctx context.Context, cartItemID, cartSessionID string) error {
+	result, err := DB.ExecContext(ctx, `
+		DELETE FROM guest_cart_items WHERE id = $1 AND cart_session_id = $2
+	`, cartItemID, cartSessionID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// MergeGuestCart moves the published items of an anonymous cart into the user's cart and
+// deletes the anonymous cart. Quantities of products already in the user's cart are added
+// together (up to the per-item limit); unpublished products are dropped. Returns the guest
+// items that were merged, or sql.ErrNoRows if the anonymous cart doesn't exist or expired.
+// If the merged cart would hold more than maxCartUnits units (0 means no limit), nothing is
+// merged and the *models.OrderRuleError of the cart size limit is returned.
+func MergeGuestCart(ctx context.Context, cartSessionID, userID string, maxCartUnits int, now time.Time) ([]models.GuestCartItem, error) {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Lock the anonymous cart so a concurrent merge of the same token can't apply it twice
+	var id string
+	err = tx.GetContext(ctx, &id, `
+		SELECT id FROM cart_sessions WHERE id = $1 AND expires_at > $2 FOR UPDATE
+	`, cartSessionID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []models.GuestCartItem
+	err = tx.SelectContext(ctx, &items, `
+		SELECT gi.id, gi.cart_session_id, gi.product_id, gi.quantity, gi.created_at, gi.updated_at
+		FROM guest_cart_items gi
+		JOIN products p ON gi.product_id = p.id
+		WHERE gi.cart_session_id = $1 AND p.status = 'published'
+		ORDER BY gi.created_at ASC
+	`, cartSessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(items) > 0 {
+		version, err := nextCartVersion(ctx, tx, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range items {
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO cart_items (user_id, product_id, quantity, version, added_version, added_price)
+				VALUES ($1, $2, $3, $4, $4, (SELECT price FROM products WHERE id = $2))
+				ON CONFLICT (user_id, product_id) DO UPDATE
+				SET quantity = LEAST(cart_items.quantity + EXCLUDED.quantity, $5), version = $4,
+					added_price = EXCLUDED.added_price, updated_at = now()
+			`, userID, item.ProductID, item.Quantity, version, maxCartItemQuantity)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// The cart version bump locked the user's cart, so no other write can slip past this
+		var units int
+		if err := tx.GetContext(ctx, &units, `SELECT COALESCE(SUM(quantity), 0) FROM cart_items WHERE user_id = $1`, userID); err != nil {
+			return nil, err
+		}
+		if err := models.CheckCartLimits("", 0, units, maxCartUnits); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM cart_sessions WHERE id = $1`, cartSessionID); err != nil {
+		return nil, err
+	}
+
+	return items, tx.Commit()
+}
+
+// DeleteExpiredCartSessions removes anonymous carts that expired before now
+func DeleteExpiredCartSessions(ctx context.Context, now time.Time) (int64, error) {
+	result, err := DB.ExecContext(ctx, `DELETE FROM cart_sessions WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetGuestCartItemQuantity returns how many units of a product an anonymous cart holds (0 if none)
+func GetGuestCartItemQuantity(ctx context.Context, cartSessionID, productID string) (int, error) {
+	var quantity int
+	err := DB.GetContext(ctx, &quantity, `
+		SELECT COALESCE((SELECT quantity FROM guest_cart_items WHERE cart_session_id = $1 AND product_id = $2), 0)
+	`, cartSessionID, productID)
+	return quantity, err
+}
+
+// GetGuestCartUnits returns how many units an anonymous cart holds in total, leaving out the
+// cart item exceptItemID (empty to count every item)
+func GetGuestCartUnits(ctx context.Context, cartSessionID, exceptItemID string) (int, error) {
+	var units int
+	err := DB.GetContext(ctx, &units, `
+		SELECT COALESCE(SUM(quantity), 0)
+		FROM guest_cart_items
+		WHERE cart_session_id = $1 AND id::text <> $2
+	`, cartSessionID, exceptItemID)
+	return units, err
+}
+
+// GetGuestCartItemProduct retrieves the product of an anonymous cart item
+func GetGuestCartItemProduct(ctx context.Context, cartItemID, cartSessionID string) (*models.Product, error) {
+	var product models.Product
+	err := DB.GetContext(ctx, &product, `
+		SELECT `+productColumns+`
+		FROM products
+		WHERE id = (SELECT product_id FROM guest_cart_items WHERE id = $1 AND cart_session_id = $2)
+	`, cartItemID, cartSessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"secure-backend/models"
+)
+
+// ErrTemplateNameTaken is returned when a seller already has an import template of that name
+var ErrTemplateNameTaken = errors.New("an import template with this name already exists")
+
+// importTemplateColumns lists the columns selected into models.ImportTemplate
+const importTemplateColumns = `id, seller_id, name, mapping, created_at, updated_at`
+
+// GetImportTemplates returns a seller's import templates by name
+func GetImportTemplates(ctx context.Context, sellerID string) ([]models.ImportTemplate, error) {
+	templates := []models.ImportTemplate{}
+	err := DB.SelectContext(ctx, &templates, `
+		SELECT `+importTemplateColumns+` FROM import_templates WHERE seller_id = $1 ORDER BY name
+	`, sellerID)
+	return templates, err
+}
+
+// GetImportTemplate returns one of a seller's import templates
+func GetImportTemplate(ctx context.Context, id, sellerID string) (*models.ImportTemplate, error) {
+	var template models.ImportTemplate
+	err := DB.GetContext(ctx, &template, `
+		SELECT `+importTemplateColumns+` FROM import_templates WHERE id = $1 AND seller_id = $2
+	`, id, sellerID)
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// CreateImportTemplate saves a seller's import template
+func CreateImportTemplate(ctx context.Context, template *models.ImportTemplate) error {
+	err := DB.GetContext(ctx, template, `
+		INSERT INTO import_templates (seller_id, name, mapping)
+		VALUES ($1, $2, $3)
+		RETURNING `+importTemplateColumns,
+		template.SellerID, template.Name, template.Mapping)
+	if hasErrorCode(err, uniqueViolation) {
+		return ErrTemplateNameTaken
+	}
+	return err
+}
+
+// UpdateImportTemplate renames a seller's import template and replaces its mapping
+func UpdateImportTemplate(ctx context.Context, template *models.ImportTemplate) error {
+	err := DB.GetContext(ctx, template, `
+		UPDATE import_templates SET name = $3, mapping = $4
+		WHERE id = $1 AND seller_id = $2
+		RETURNING `+importTemplateColumns,
+		template.ID, template.SellerID, template.Name, template.Mapping)
+	if hasErrorCode(err, uniqueViolation) {
+		return ErrTemplateNameTaken
+	}
+	return err
+}
+
+// DeleteImportTemplate deletes one of a seller's import templates
+func DeleteImportTemplate(ctx context.Context, id, sellerID string) error {
+	return execOwned(ctx, DB, `DELETE FROM import_templates WHERE id = $1 AND seller_id = $2`, id, sellerID)
+}
@@ -0,0 +1,175 @@
+package database
+
+import (
+	"context"
+	"secure-backend/models"
+	"secure-backend/money"
+
+	"github.com/lib/pq"
+)
+
+// inventoryImportNote marks the stock movements of inventory imports
+const inventoryImportNote = "inventory import"
+
+// ApplyInventoryUpdates sets the stock and price of a seller's products from an inventory
+// file in one transaction. The rows are copied into a temporary table with COPY and applied
+// with a few set-based statements, so files of thousands of products take about as long as
+// one product update. Only changed products are written; their stock changes are recorded as
+// seller_update stock movements and their price changes in the price history, as an edit
+// would. A product named by several rows (once by ID, once by slug) gets the last one.
+// With dryRun the results are computed and the transaction rolled back.
+func ApplyInventoryUpdates(ctx context.Context, sellerID string, updates []models.InventoryUpdate, dryRun bool) ([]models.InventoryResult, error) {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		`CREATE TEMP TABLE inventory_import (
+			line INTEGER PRIMARY KEY, product_id UUID, slug TEXT, stock INTEGER, price DECIMAL(10,2)
+		) ON COMMIT DROP`,
+		`CREATE TEMP TABLE inventory_changes (
+			line INTEGER PRIMARY KEY, product_id UUID NOT NULL UNIQUE,
+			old_stock INTEGER NOT NULL, new_stock INTEGER NOT NULL,
+			old_price DECIMAL(10,2) NOT NULL, new_price DECIMAL(10,2) NOT NULL
+		) ON COMMIT DROP`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return nil, err
+		}
+	}
+
+	copyIn, err := tx.PrepareContext(ctx, pq.CopyIn("inventory_import", "line", "product_id", "slug", "stock", "price"))
+	if err != nil {
+		return nil, err
+	}
+	for _, update := range updates {
+		var productID, slug *string
+		if update.ProductID != "" {
+			productID = &update.ProductID
+		} else {
+			slug = &update.Slug
+		}
+		if _, err := copyIn.ExecContext(ctx, update.Line, productID, slug, update.Stock, update.Price); err != nil {
+			copyIn.Close()
+			return nil, err
+		}
+	}
+	if _, err := copyIn.ExecContext(ctx); err != nil {
+		copyIn.Close()
+		return nil, err
+	}
+	if err := copyIn.Close(); err != nil {
+		return nil, err
+	}
+
+	// Resolve slugs to the seller's products, lock the products, then pair each with its last row
+	_, err = tx.ExecContext(ctx, `
+		UPDATE inventory_import i SET product_id = p.id
+		FROM products p
+		WHERE i.product_id IS NULL AND p.slug = i.slug AND p.seller_id = $1
+	`, sellerID)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, `
+		SELECT p.id FROM products p JOIN inventory_import i ON p.id = i.product_id
+		WHERE p.seller_id = $1
+		ORDER BY p.id
+		FOR UPDATE OF p
+	`, sellerID)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO inventory_changes (line, product_id, old_stock, new_stock, old_price, new_price)
+		SELECT DISTINCT ON (p.id) i.line, p.id, p.stock, COALESCE(i.stock, p.stock), p.price, COALESCE(i.price, p.price)
+		FROM inventory_import i JOIN products p ON p.id = i.product_id
+		WHERE p.seller_id = $1
+		ORDER BY p.id, i.line DESC
+	`, sellerID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE products p SET stock = c.new_stock, price = c.new_price, updated_at = now()
+		FROM inventory_changes c
+		WHERE p.id = c.product_id AND (c.new_stock <> c.old_stock OR c.new_price <> c.old_price)
+	`)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO stock_movements (product_id, quantity, reason, actor_id, note)
+		SELECT product_id, new_stock - old_stock, $2, $1, $3
+		FROM inventory_changes WHERE new_stock <> old_stock
+	`, sellerID, models.MovementSellerUpdate, inventoryImportNote)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO product_price_history (product_id, old_price, new_price, changed_by)
+		SELECT product_id, old_price, new_price, $1
+		FROM inventory_changes WHERE new_price <> old_price
+	`, sellerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Line      int           `db:"line"`
+		ProductID *string       `db:"product_id"`
+		Slug      *string       `db:"slug"`
+		Paired    bool          `db:"paired"`
+		Matched   bool          `db:"matched"`
+		OldStock  *int          `db:"old_stock"`
+		NewStock  *int          `db:"new_stock"`
+		OldPrice  *money.Amount `db:"old_price"`
+		NewPrice  *money.Amount `db:"new_price"`
+	}
+	err = tx.SelectContext(ctx, &rows, `
+		SELECT i.line, i.product_id, i.slug, c.line IS NOT NULL AS paired,
+			EXISTS (SELECT 1 FROM products p WHERE p.id = i.product_id AND p.seller_id = $1) AS matched,
+			c.old_stock, c.new_stock, c.old_price, c.new_price
+		FROM inventory_import i LEFT JOIN inventory_changes c ON c.line = i.line
+		ORDER BY i.line
+	`, sellerID)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]models.InventoryResult, len(rows))
+	for i, row := range rows {
+		result := models.InventoryResult{Line: row.Line}
+		if row.ProductID != nil {
+			result.ProductID = *row.ProductID
+		}
+		if row.Slug != nil {
+			result.Slug = *row.Slug
+		}
+		switch {
+		case row.Paired:
+			result.OldStock, result.NewStock = row.OldStock, row.NewStock
+			result.OldPrice, result.NewPrice = row.OldPrice, row.NewPrice
+			result.Outcome = models.InventoryUnchanged
+			if *row.OldStock != *row.NewStock || *row.OldPrice != *row.NewPrice {
+				result.Outcome = models.InventoryUpdated
+			}
+		case row.Matched:
+			result.Outcome = models.InventoryDuplicate
+			result.Error = "a later row names the same product"
+		default:
+			result.Outcome = models.InventoryNotFound
+			result.Error = "no product of yours has this ID or slug"
+		}
+		results[i] = result
+	}
+
+	if dryRun {
+		return results, nil
+	}
+	return results, tx.Commit()
+}
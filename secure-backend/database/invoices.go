@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"secure-backend/models"
+)
+
+// GetOrCreateInvoice returns the order's invoice, issuing the next invoice number if the
+// order has none yet. Numbers come from a locked counter so they are sequential without gaps.
+func GetOrCreateInvoice(ctx context.Context, orderID string, taxRate float64) (*models.Invoice, error) {
+	var invoice models.Invoice
+	err := DB.GetContext(ctx, &invoice, `
+		SELECT id, order_id, number, tax_rate, issued_at FROM invoices WHERE order_id = $1
+	`, orderID)
+	if err != sql.ErrNoRows {
+		if err != nil {
+			return nil, err
+		}
+		return &invoice, nil
+	}
+
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Serializes invoice creation; also re-checks for a concurrently issued invoice
+	var number int64
+	err = tx.GetContext(ctx, &number, `UPDATE invoice_counter SET last_number = last_number + 1 RETURNING last_number`)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.GetContext(ctx, &invoice, `
+		SELECT id, order_id, number, tax_rate, issued_at FROM invoices WHERE order_id = $1
+	`, orderID)
+	if err == nil {
+		// Issued while we waited for the counter lock; rolling back returns the number
+		return &invoice, nil
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	err = tx.GetContext(ctx, &invoice, `
+		INSERT INTO invoices (order_id, number, tax_rate)
+		VALUES ($1, $2, $3)
+		RETURNING id, order_id, number, tax_rate, issued_at
+	`, orderID, number, taxRate)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// GetInvoiceLines returns the order's items with product names and seller contacts
+func GetInvoiceLines(ctx context.Context, orderID string) ([]models.InvoiceLine, error) {
+	lines := []models.InvoiceLine{}
+	err := DB.SelectContext(ctx, &lines, `
+		SELECT p.name AS product_name, COALESCE(u.email, '') AS seller_email,
+			oi.quantity, oi.unit_price, oi.total_price
+		FROM order_items oi
+		JOIN products p ON oi.product_id = p.id
+		LEFT JOIN users u ON p.seller_id = u.id
+		WHERE oi.order_id = $1
+		ORDER BY oi.created_at
+	`, orderID)
+	return lines, err
+}
@@ -0,0 +1,195 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"secure-backend/models"
+	"time"
+)
+
+const jobColumns = `id, user_id, type, status, progress, params, attempts, run_at, COALESCE(error, '') AS error,
+	COALESCE(result_path, '') AS result_path, result_expires_at, started_at, completed_at, created_at, updated_at`
+
+// CreateJob queues a new background job, to run at job.RunAt or right away if it is zero
+func CreateJob(ctx context.Context, job *models.Job) error {
+	var runAt *time.Time
+	if !job.RunAt.IsZero() {
+		runAt = &job.RunAt
+	}
+	return DB.GetContext(ctx, job, `
+		INSERT INTO jobs (id, user_id, type, params, run_at)
+		VALUES ($1, $2, $3, $4, COALESCE($5, now()))
+		RETURNING `+jobColumns, ids.NewID(), job.UserID, job.Type, job.Params, runAt)
+}
+
+// GetJobByID retrieves a job by its ID
+func GetJobByID(ctx context.Context, jobID string) (*models.Job, error) {
+	var job models.Job
+	err := DB.GetContext(ctx, &job, `SELECT `+jobColumns+` FROM jobs WHERE id = $1`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ClaimNextJob marks the queued job that has been due longest as running and returns it. Concurrent
+// workers (including other server instances) never claim the same job.
+// It returns sql.ErrNoRows when no job is due.
+func ClaimNextJob(ctx context.Context) (*models.Job, error) {
+	var job models.Job
+	err := DB.GetContext(ctx, &job, `
+		UPDATE jobs SET status = 'running', started_at = now(), progress = 0, attempts = attempts + 1, updated_at = now()
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE status = 'queued' AND run_at <= now()
+			ORDER BY run_at
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING `+jobColumns)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// UpdateJobProgress records the progress (0-100) of a running job. It reports false
+// when the job is no longer running, e.g. because it was cancelled.
+func UpdateJobProgress(ctx context.Context, jobID string, progress int) (bool, error) {
+	result, err := DB.ExecContext(ctx, `
+		UPDATE jobs SET progress = $2, updated_at = now()
+		WHERE id = $1 AND status = 'running'
+	`, jobID, progress)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+// CompleteJob marks a running job as completed with its downloadable result, if it has one.
+// It returns sql.ErrNoRows if the job is no longer running.
+func CompleteJob(ctx context.Context, jobID, resultPath string, expiresAt time.Time) error {
+	result, err := DB.ExecContext(ctx, `
+		UPDATE jobs
+		SET status = 'completed', progress = 100, result_path = NULLIF($2, ''),
+			result_expires_at = CASE WHEN $2 = '' THEN NULL ELSE $3::timestamptz END,
+			completed_at = now(), updated_at = now()
+		WHERE id = $1 AND status = 'running'
+	`, jobID, resultPath, expiresAt)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// FailJob marks a running job as failed and adds the error to its error history
+func FailJob(ctx context.Context, jobID, errMsg string) error {
+	_, err := DB.ExecContext(ctx, `
+		WITH failed AS (
+			UPDATE jobs SET status = 'failed', error = $2, completed_at = now(), updated_at = now()
+			WHERE id = $1 AND status = 'running'
+			RETURNING id, attempts
+		)
+		INSERT INTO delivery_errors (source, item_id, attempt, error)
+		SELECT '`+DeadLetterJobs+`', id, attempts, $2 FROM failed
+	`, jobID, errMsg)
+	return err
+}
+
+// CancelJob cancels a queued or running job. It returns sql.ErrNoRows if the job already finished.
+func CancelJob(ctx context.Context, jobID string) (*models.Job, error) {
+	var job models.Job
+	err := DB.GetContext(ctx, &job, `
+		UPDATE jobs SET status = 'cancelled', completed_at = now(), updated_at = now()
+		WHERE id = $1 AND status IN ('queued', 'running')
+		RETURNING `+jobColumns, jobID)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// RetryJob puts a failed or cancelled job back in the queue. It returns sql.ErrNoRows
+// if the job is not in a retryable state.
+func RetryJob(ctx context.Context, jobID string) (*models.Job, error) {
+	var job models.Job
+	err := DB.GetContext(ctx, &job, `
+		UPDATE jobs
+		SET status = 'queued', progress = 0, error = NULL, started_at = NULL, completed_at = NULL,
+			discarded_at = NULL, updated_at = now()
+		WHERE id = $1 AND status IN ('failed', 'cancelled')
+		RETURNING `+jobColumns, jobID)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// JobFilter narrows a job listing; empty fields match everything
+type JobFilter struct {
+	UserID string
+	Status string
+	Type   string
+}
+
+// GetJobs returns a page of jobs matching the filter (newest first) and the total match count
+func GetJobs(ctx context.Context, filter JobFilter, limit, offset int) ([]models.Job, int, error) {
+	where := `WHERE ($1 = '' OR user_id::text = $1) AND ($2 = '' OR status = $2) AND ($3 = '' OR type = $3)`
+	args := []interface{}{filter.UserID, filter.Status, filter.Type}
+
+	var total int
+	if err := DB.GetContext(ctx, &total, `SELECT COUNT(*) FROM jobs `+where, args...); err != nil {
+		return nil, 0, err
+	}
+
+	jobs := []models.Job{}
+	err := DB.SelectContext(ctx, &jobs, `
+		SELECT `+jobColumns+` FROM jobs `+where+`
+		ORDER BY created_at DESC
+		LIMIT $4 OFFSET $5
+	`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return jobs, total, nil
+}
+
+// RequeueStaleJobs puts jobs that have been running since before the given time back in the
+// queue; they were interrupted by a server restart
+func RequeueStaleJobs(ctx context.Context, startedBefore time.Time) (int64, error) {
+	result, err := DB.ExecContext(ctx, `
+		UPDATE jobs SET status = 'queued', progress = 0, started_at = NULL, updated_at = now()
+		WHERE status = 'running' AND updated_at < $1
+	`, startedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetExpiredJobResults returns completed jobs whose result file has expired
+func GetExpiredJobResults(ctx context.Context, now time.Time, limit int) ([]models.Job, error) {
+	var jobs []models.Job
+	err := DB.SelectContext(ctx, &jobs, `
+		SELECT `+jobColumns+` FROM jobs
+		WHERE result_path IS NOT NULL AND result_expires_at < $1
+		ORDER BY result_expires_at
+		LIMIT $2
+	`, now, limit)
+	return jobs, err
+}
+
+// ClearJobResult forgets the result file of a job after it has been deleted
+func ClearJobResult(ctx context.Context, jobID string) error {
+	_, err := DB.ExecContext(ctx, `UPDATE jobs SET result_path = NULL, updated_at = now() WHERE id = $1`, jobID)
+	return err
+}
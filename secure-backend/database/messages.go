@@ -0,0 +1,201 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"secure-backend/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ErrMessageSellerAmbiguous is returned when a message about an order with several sellers
+// doesn't name the product it is about
+var ErrMessageSellerAmbiguous = errors.New("the order has items from several sellers; name the product the message is about")
+
+const messageThreadColumns = `id, buyer_id, seller_id, product_id, order_id, flagged, last_message_at, created_at`
+
+const messageColumns = `id, thread_id, sender_id, body, flags, created_at`
+
+// MessageSubject is what a buyer's message is about: a product, or an order and optionally
+// the product of the order whose seller it is for
+type MessageSubject struct {
+	ProductID string
+	OrderID   string
+}
+
+// MessageThreadFilter narrows the threads admins list. Empty fields match every thread.
+type MessageThreadFilter struct {
+	OrderID     string
+	UserID      string // buyer or seller
+	FlaggedOnly bool
+}
+
+// SendBuyerMessage adds a buyer's message to their thread with the seller of the subject,
+// starting the thread with the first message. A product must be published; an order must
+// be the buyer's, which the query finding its seller checks. Returns sql.ErrNoRows when
+// there is no such product or order and ErrMessageSellerAmbiguous when an order has several
+// sellers and no product was named. started reports whether the message started the thread.
+func SendBuyerMessage(ctx context.Context, buyerID string, subject MessageSubject, body string, flags []string) (thread *models.MessageThread, message *models.Message, started bool, err error) {
+	thread, message = &models.MessageThread{}, &models.Message{}
+	err = WithTx(ctx, func(tx *sqlx.Tx) error {
+		var sellerIDs []string
+		var err error
+		if subject.OrderID == "" {
+			err = tx.SelectContext(ctx, &sellerIDs, `
+				SELECT seller_id FROM products WHERE id = $1 AND status = 'published'
+			`, subject.ProductID)
+		} else {
+			err = tx.SelectContext(ctx, &sellerIDs, `
+				SELECT DISTINCT p.seller_id
+				FROM orders o
+				JOIN order_items oi ON oi.order_id = o.id
+				JOIN products p ON p.id = oi.product_id
+				WHERE o.id = $1 AND o.buyer_id = $2 AND ($3 = '' OR p.id::text = $3)
+			`, subject.OrderID, buyerID, subject.ProductID)
+		}
+		if err != nil {
+			return err
+		}
+		switch {
+		case len(sellerIDs) == 0:
+			return sql.ErrNoRows
+		case len(sellerIDs) > 1:
+			return ErrMessageSellerAmbiguous
+		}
+
+		if subject.OrderID == "" {
+			err = tx.GetContext(ctx, thread, `
+				INSERT INTO message_threads (buyer_id, seller_id, product_id, flagged)
+				VALUES ($1, $2, $3, $4)
+				ON CONFLICT (buyer_id, product_id) WHERE order_id IS NULL
+				DO UPDATE SET last_message_at = now(), flagged = message_threads.flagged OR EXCLUDED.flagged
+				RETURNING `+messageThreadColumns,
+				buyerID, sellerIDs[0], subject.ProductID, len(flags) > 0)
+		} else {
+			err = tx.GetContext(ctx, thread, `
+				INSERT INTO message_threads (buyer_id, seller_id, order_id, flagged)
+				VALUES ($1, $2, $3, $4)
+				ON CONFLICT (buyer_id, seller_id, order_id) WHERE order_id IS NOT NULL
+				DO UPDATE SET last_message_at = now(), flagged = message_threads.flagged OR EXCLUDED.flagged
+				RETURNING `+messageThreadColumns,
+				buyerID, sellerIDs[0], subject.OrderID, len(flags) > 0)
+		}
+		if err != nil {
+			return err
+		}
+
+		err = tx.GetContext(ctx, &started, `SELECT NOT EXISTS (SELECT 1 FROM messages WHERE thread_id = $1)`, thread.ID)
+		if err != nil {
+			return err
+		}
+		return insertMessage(ctx, tx, message, thread.ID, buyerID, body, flags)
+	})
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return thread, message, started, nil
+}
+
+// ReplyToMessageThread adds a message to a thread the sender takes part in. The participant
+// check is part of the update, so a thread of other users returns sql.ErrNoRows like a
+// missing one.
+func ReplyToMessageThread(ctx context.Context, threadID, senderID, body string, flags []string) (*models.MessageThread, *models.Message, error) {
+	var thread models.MessageThread
+	var message models.Message
+	err := WithTx(ctx, func(tx *sqlx.Tx) error {
+		err := tx.GetContext(ctx, &thread, `
+			UPDATE message_threads SET last_message_at = now(), flagged = flagged OR $3
+			WHERE id = $1 AND (buyer_id = $2 OR seller_id = $2)
+			RETURNING `+messageThreadColumns,
+			threadID, senderID, len(flags) > 0)
+		if err != nil {
+			return err
+		}
+		return insertMessage(ctx, tx, &message, thread.ID, senderID, body, flags)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &thread, &message, nil
+}
+
+// insertMessage stores a screened message of a thread
+func insertMessage(ctx context.Context, tx *sqlx.Tx, message *models.Message, threadID, senderID, body string, flags []string) error {
+	if flags == nil {
+		flags = []string{}
+	}
+	return tx.GetContext(ctx, message, `
+		INSERT INTO messages (thread_id, sender_id, body, flags)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+messageColumns,
+		threadID, senderID, body, pq.Array(flags))
+}
+
+// GetUserMessageThreads returns a page of the threads a user takes part in as buyer or
+// seller, most recently active first, and the total count
+func GetUserMessageThreads(ctx context.Context, userID string, limit, offset int) ([]models.MessageThread, int, error) {
+	return FindMessageThreads(ctx, MessageThreadFilter{UserID: userID}, limit, offset)
+}
+
+// FindMessageThreads returns a page of the threads matching filter, most recently active
+// first, and the total count
+func FindMessageThreads(ctx context.Context, filter MessageThreadFilter, limit, offset int) ([]models.MessageThread, int, error) {
+	const where = `
+		WHERE ($1 = '' OR order_id::text = $1) AND ($2 = '' OR buyer_id::text = $2 OR seller_id::text = $2)
+			AND (flagged OR NOT $3)`
+
+	var total int
+	err := DB.GetContext(ctx, &total, `SELECT COUNT(*) FROM message_threads`+where, filter.OrderID, filter.UserID, filter.FlaggedOnly)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	threads := []models.MessageThread{}
+	err = DB.SelectContext(ctx, &threads, `
+		SELECT `+messageThreadColumns+`
+		FROM message_threads`+where+`
+		ORDER BY last_message_at DESC, id
+		LIMIT $4 OFFSET $5
+	`, filter.OrderID, filter.UserID, filter.FlaggedOnly, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return threads, total, nil
+}
+
+// GetMessageThread returns a thread by ID
+func GetMessageThread(ctx context.Context, id string) (*models.MessageThread, error) {
+	var thread models.MessageThread
+	err := DB.GetContext(ctx, &thread, `SELECT `+messageThreadColumns+` FROM message_threads WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	return &thread, nil
+}
+
+// GetOrderMessageThreads returns the threads about an order, oldest first
+func GetOrderMessageThreads(ctx context.Context, orderID string) ([]models.MessageThread, error) {
+	threads := []models.MessageThread{}
+	err := DB.SelectContext(ctx, &threads, `
+		SELECT `+messageThreadColumns+`
+		FROM message_threads
+		WHERE order_id = $1
+		ORDER BY created_at
+	`, orderID)
+	return threads, err
+}
+
+// GetThreadMessages returns the messages of a thread, oldest first
+func GetThreadMessages(ctx context.Context, threadID string) ([]models.Message, error) {
+	messages := []models.Message{}
+	err := DB.SelectContext(ctx, &messages, `
+		SELECT `+messageColumns+`
+		FROM messages
+		WHERE thread_id = $1
+		ORDER BY created_at, id
+	`, threadID)
+	return messages, err
+}
@@ -0,0 +1,185 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// schemaFiles are the schema of a fresh database and the migrations existing databases need
+// beyond it, built into the binary so a deploy carries its own schema
+//
+//go:embed schema.sql migrations/*.sql
+var schemaFiles embed.FS
+
+// migrationLockID is the advisory lock held while migrating, so instances starting together
+// don't apply the same migration twice
+const migrationLockID = 7340517
+
+// AutoMigrate reports whether the API applies pending migrations on startup. It does unless
+// DB_AUTO_MIGRATE is false, for deploys that run `migrate` as a separate step.
+func AutoMigrate() bool {
+	on, err := strconv.ParseBool(os.Getenv("DB_AUTO_MIGRATE"))
+	return err != nil || on
+}
+
+// migrations returns the versions of the embedded migrations (file names without .sql) in
+// the order to apply them
+func migrations() ([]string, error) {
+	names, err := fs.Glob(schemaFiles, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]string, len(names))
+	for i, name := range names {
+		versions[i] = strings.TrimSuffix(strings.TrimPrefix(name, "migrations/"), ".sql")
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
+
+// Migrate brings the database schema up to date and returns the migrations it applied.
+//
+// A database without tables gets schema.sql, which already contains every migration, and
+// the migrations are recorded as applied. A database created by hand before migrations were
+// tracked, from the original schema.sql or a later one, gets every migration: 000_baseline
+// adds whatever it lacks of the schema the numbered migrations build on, and each migration
+// is safe to run more than once. After that, migrations not yet in schema_migrations are
+// applied in order. Each file commits its own changes, and is recorded once it has.
+func Migrate(ctx context.Context) ([]string, error) {
+	versions, err := migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	// Session settings and the advisory lock need a single connection
+	conn, err := DB.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return nil, fmt.Errorf("locking migrations: %w", err)
+	}
+	defer conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+	// Migrations rewrite whole tables; let them run as long as they need, and restore the
+	// connection's limit before it goes back to the pool
+	if _, err := conn.ExecContext(ctx, `SET statement_timeout = 0`); err != nil {
+		return nil, err
+	}
+	defer conn.ExecContext(context.WithoutCancel(ctx), fmt.Sprintf(`SET statement_timeout = %d`, statementTimeout.Milliseconds()))
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version TEXT PRIMARY KEY,
+			applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+		);
+		ALTER TABLE schema_migrations ENABLE ROW LEVEL SECURITY;
+	`); err != nil {
+		return nil, fmt.Errorf("creating schema_migrations: %w", err)
+	}
+
+	var applied []string
+	if err := conn.SelectContext(ctx, &applied, `SELECT version FROM schema_migrations`); err != nil {
+		return nil, err
+	}
+
+	if len(applied) == 0 {
+		var fresh bool
+		if err := conn.GetContext(ctx, &fresh, `SELECT to_regclass('users') IS NULL`); err != nil {
+			return nil, err
+		}
+		if fresh {
+			if err := applySchemaFile(ctx, conn, "schema.sql"); err != nil {
+				return nil, fmt.Errorf("applying schema.sql: %w", err)
+			}
+			for _, version := range versions {
+				if err := recordMigration(ctx, conn, version); err != nil {
+					return nil, err
+				}
+			}
+			log.Printf("Created the database schema (%d migrations included)", len(versions))
+			return nil, nil
+		}
+	}
+
+	done := make(map[string]bool, len(applied))
+	for _, version := range applied {
+		done[version] = true
+	}
+	var ran []string
+	for _, version := range versions {
+		if done[version] {
+			continue
+		}
+		if err := applySchemaFile(ctx, conn, "migrations/"+version+".sql"); err != nil {
+			return ran, fmt.Errorf("applying migration %s: %w", version, err)
+		}
+		if err := recordMigration(ctx, conn, version); err != nil {
+			return ran, err
+		}
+		log.Printf("Applied migration %s", version)
+		ran = append(ran, version)
+	}
+	return ran, nil
+}
+
+// PendingMigrations returns the embedded migrations the database hasn't recorded as applied.
+// Before the first Migrate, that is all of them.
+func PendingMigrations(ctx context.Context) ([]string, error) {
+	versions, err := migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var tracked bool
+	if err := DB.GetContext(ctx, &tracked, `SELECT to_regclass('schema_migrations') IS NOT NULL`); err != nil {
+		return nil, err
+	}
+	done := map[string]bool{}
+	if tracked {
+		var applied []string
+		if err := DB.SelectContext(ctx, &applied, `SELECT version FROM schema_migrations`); err != nil {
+			return nil, err
+		}
+		for _, version := range applied {
+			done[version] = true
+		}
+	}
+
+	var pending []string
+	for _, version := range versions {
+		if !done[version] {
+			pending = append(pending, version)
+		}
+	}
+	return pending, nil
+}
+
+// applySchemaFile runs an embedded SQL file. Files may hold several statements and their own
+// BEGIN/COMMIT, so they are sent without parameters as one simple query.
+func applySchemaFile(ctx context.Context, conn *sqlx.Conn, name string) error {
+	script, err := schemaFiles.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	_, err = conn.ExecContext(ctx, string(script))
+	return err
+}
+
+func recordMigration(ctx context.Context, conn *sqlx.Conn, version string) error {
+	_, err := conn.ExecContext(ctx, `
+		INSERT INTO schema_migrations (version) VALUES ($1) ON CONFLICT (version) DO NOTHING
+	`, version)
+	return err
+}
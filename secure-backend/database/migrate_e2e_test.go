@@ -0,0 +1,176 @@
+//go:build e2e
+
+// Migration tests against a real PostgreSQL server. Each test creates and drops its own
+// databases next to the one TEST_DATABASE_URL points at, so the role needs CREATEDB:
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run TestMigrate ./database
+package database
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// schemaCatalogQueries describe a database's public schema, one sorted line per column,
+// constraint, index, trigger, policy, row level security setting and function
+var schemaCatalogQueries = map[string]string{
+	"columns": `
+		SELECT format('%s.%s %s%s default=%s generated=%s', c.relname, a.attname,
+			format_type(a.atttypid, a.atttypmod), CASE WHEN a.attnotnull THEN ' not null' ELSE '' END,
+			coalesce(pg_get_expr(d.adbin, d.adrelid), ''), a.attgenerated)
+		FROM pg_attribute a
+		JOIN pg_class c ON c.oid = a.attrelid
+		LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		WHERE c.relnamespace = 'public'::regnamespace AND c.relkind = 'r' AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY 1`,
+	"constraints": `
+		SELECT format('%s %s %s', conrelid::regclass, conname, pg_get_constraintdef(oid))
+		FROM pg_constraint
+		WHERE connamespace = 'public'::regnamespace
+		ORDER BY 1`,
+	"indexes": `
+		SELECT indexdef FROM pg_indexes WHERE schemaname = 'public' ORDER BY 1`,
+	"triggers": `
+		SELECT pg_get_triggerdef(t.oid)
+		FROM pg_trigger t JOIN pg_class c ON c.oid = t.tgrelid
+		WHERE c.relnamespace = 'public'::regnamespace AND NOT t.tgisinternal
+		ORDER BY 1`,
+	"policies": `
+		SELECT format('%s %s %s %s %s', tablename, policyname, cmd, qual, with_check)
+		FROM pg_policies WHERE schemaname = 'public'
+		ORDER BY 1`,
+	"row level security": `
+		SELECT format('%s %s', relname, relrowsecurity)
+		FROM pg_class WHERE relnamespace = 'public'::regnamespace AND relkind = 'r'
+		ORDER BY 1`,
+	"functions": `
+		SELECT format('%s %s', proname, md5(prosrc))
+		FROM pg_proc WHERE pronamespace = 'public'::regnamespace
+		ORDER BY 1`,
+}
+
+// TestMigrateBaselineMatchesSchema migrates a database created from the original schema.sql,
+// with some rows in it, and checks it ends up with the same schema as a fresh database.
+func TestMigrateBaselineMatchesSchema(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+
+	fresh := createScratchDatabase(t, dsn, "fresh")
+	migrateScratchDatabase(t, fresh)
+
+	baseline := createScratchDatabase(t, dsn, "baseline")
+	script, err := os.ReadFile("testdata/baseline_schema.sql")
+	require.NoError(t, err)
+	_, err = baseline.ExecContext(ctx, string(script))
+	require.NoError(t, err)
+
+	var sellerID, buyerID, productID, orderID string
+	require.NoError(t, baseline.GetContext(ctx, &sellerID, `INSERT INTO users (email, role) VALUES ('seller@example.com', 'seller') RETURNING id`))
+	require.NoError(t, baseline.GetContext(ctx, &buyerID, `INSERT INTO users (email) VALUES ('buyer@example.com') RETURNING id`))
+	require.NoError(t, baseline.GetContext(ctx, &productID, `
+		INSERT INTO products (name, price, stock, status, seller_id) VALUES ('Lamp', 20, 5, 'published', $1) RETURNING id
+	`, sellerID))
+	_, err = baseline.ExecContext(ctx, `INSERT INTO cart_items (user_id, product_id, quantity) VALUES ($1, $2, 2)`, buyerID, productID)
+	require.NoError(t, err)
+	require.NoError(t, baseline.GetContext(ctx, &orderID, `
+		INSERT INTO orders (buyer_id, status, total_amount) VALUES ($1, 'confirmed', 20) RETURNING id
+	`, buyerID))
+	_, err = baseline.ExecContext(ctx, `
+		INSERT INTO order_items (order_id, product_id, quantity, unit_price, total_price) VALUES ($1, $2, 1, 20, 20)
+	`, orderID, productID)
+	require.NoError(t, err)
+
+	ran := migrateScratchDatabase(t, baseline)
+	versions, err := migrations()
+	require.NoError(t, err)
+	assert.Equal(t, versions, ran, "a database predating the migrations gets every one of them")
+	assert.Empty(t, migrateScratchDatabase(t, baseline), "a migrated database has nothing left to apply")
+
+	// Running the baseline again changes nothing
+	_, err = baseline.ExecContext(ctx, string(mustReadSchemaFile(t, "migrations/000_baseline.sql")))
+	require.NoError(t, err)
+
+	for name, query := range schemaCatalogQueries {
+		var want, got []string
+		require.NoError(t, fresh.SelectContext(ctx, &want, query), name)
+		require.NoError(t, baseline.SelectContext(ctx, &got, query), name)
+		assert.Equal(t, want, got, "%s of the migrated database differ from schema.sql", name)
+	}
+
+	// Existing rows were carried over
+	var status string
+	require.NoError(t, baseline.GetContext(ctx, &status, `SELECT status FROM orders WHERE id = $1`, orderID))
+	assert.Equal(t, "paid", status, "confirmed orders become paid")
+	var addedPrice float64
+	require.NoError(t, baseline.GetContext(ctx, &addedPrice, `SELECT added_price FROM cart_items WHERE user_id = $1`, buyerID))
+	assert.Equal(t, 20.0, addedPrice)
+	var slug string
+	require.NoError(t, baseline.GetContext(ctx, &slug, `SELECT slug FROM products WHERE id = $1`, productID))
+	assert.NotEmpty(t, slug)
+	var opening int
+	require.NoError(t, baseline.GetContext(ctx, &opening, `SELECT COUNT(*) FROM stock_movements WHERE product_id = $1`, productID))
+	assert.Positive(t, opening, "the stock ledger was seeded")
+}
+
+// createScratchDatabase creates an empty database on the server dsn points at, with the
+// auth.uid() function of Supabase the schema's policies call, and drops it when the test ends
+func createScratchDatabase(t *testing.T, dsn, kind string) *sqlx.DB {
+	t.Helper()
+	ctx := context.Background()
+
+	u, err := url.Parse(dsn)
+	if err != nil || u.Scheme == "" {
+		t.Skip("TEST_DATABASE_URL must be a postgres:// URL")
+	}
+	admin, err := sqlx.Connect("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { admin.Close() })
+
+	name := "secureshop_migrate_" + kind + "_" + uuid.NewString()[:8]
+	_, err = admin.ExecContext(ctx, `CREATE DATABASE `+name)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		admin.ExecContext(context.Background(), `DROP DATABASE IF EXISTS `+name+` WITH (FORCE)`)
+	})
+
+	u.Path = "/" + name
+	db, err := sqlx.Connect("postgres", u.String())
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.ExecContext(ctx, `
+		CREATE SCHEMA auth;
+		CREATE FUNCTION auth.uid() RETURNS UUID LANGUAGE sql STABLE AS $$ SELECT NULL::uuid $$;
+	`)
+	require.NoError(t, err)
+	return db
+}
+
+// migrateScratchDatabase runs Migrate against db in place of the package connection pool
+func migrateScratchDatabase(t *testing.T, db *sqlx.DB) []string {
+	t.Helper()
+	previous := DB
+	DB = db
+	defer func() { DB = previous }()
+
+	ran, err := Migrate(context.Background())
+	require.NoError(t, err)
+	return ran
+}
+
+func mustReadSchemaFile(t *testing.T, name string) []byte {
+	t.Helper()
+	script, err := schemaFiles.ReadFile(name)
+	require.NoError(t, err)
+	return script
+}
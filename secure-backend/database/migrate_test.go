@@ -0,0 +1,40 @@
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationsAreEmbeddedInOrder(t *testing.T) {
+	versions, err := migrations()
+	require.NoError(t, err)
+	require.NotEmpty(t, versions)
+
+	assert.Equal(t, "000_baseline", versions[0], "the baseline runs before the numbered migrations")
+	for i, version := range versions {
+		assert.NotContains(t, version, "/")
+		assert.False(t, strings.HasSuffix(version, ".sql"))
+		if i > 0 {
+			assert.Less(t, versions[i-1], version, "migrations must be numbered in the order to apply them")
+		}
+		script, err := schemaFiles.ReadFile("migrations/" + version + ".sql")
+		require.NoError(t, err)
+		assert.NotEmpty(t, script)
+	}
+
+	schema, err := schemaFiles.ReadFile("schema.sql")
+	require.NoError(t, err)
+	assert.Contains(t, string(schema), "CREATE TABLE users")
+}
+
+func TestAutoMigrate(t *testing.T) {
+	t.Setenv("DB_AUTO_MIGRATE", "")
+	assert.True(t, AutoMigrate(), "migrations run on startup by default")
+	t.Setenv("DB_AUTO_MIGRATE", "false")
+	assert.False(t, AutoMigrate())
+	t.Setenv("DB_AUTO_MIGRATE", "true")
+	assert.True(t, AutoMigrate())
+}
@@ -0,0 +1,1004 @@
+-- Bring a database created from the original schema.sql, before migrations were tracked,
+-- up to the schema the numbered migrations build on: the columns added to the original
+-- tables and every table, index, trigger and row level security setting added since.
+-- Databases created from a later schema.sql by hand get whatever they are missing. Orders
+-- in the retired 'confirmed' status become 'paid'. Safe to run more than once.
+
+BEGIN;
+
+-- Users: seller plan, break-glass admins, minimum order value and vacation mode
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS plan VARCHAR(20) NOT NULL DEFAULT 'free' CHECK (plan IN ('free', 'pro', 'enterprise')),
+    ADD COLUMN IF NOT EXISTS break_glass BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS min_order_value DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (min_order_value >= 0),
+    ADD COLUMN IF NOT EXISTS vacation_starts_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS vacation_ends_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS vacation_message TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS vacation_hide_listings BOOLEAN NOT NULL DEFAULT false;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conrelid = 'users'::regclass AND conname = 'users_check') THEN
+        ALTER TABLE users ADD CHECK (vacation_ends_at IS NULL OR vacation_ends_at > vacation_starts_at);
+    END IF;
+END
+$$;
+
+-- Product categories (used for storefront navigation)
+CREATE TABLE IF NOT EXISTS categories (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    slug VARCHAR(100) UNIQUE NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Products: image details, dimensions, category, slug and SEO fields, purchase limits,
+-- shelf location and the full-text search document. Existing products get a random slug.
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS image_alt TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS image_hash TEXT,
+    ADD COLUMN IF NOT EXISTS width_cm DECIMAL(10,2) CHECK (width_cm > 0),
+    ADD COLUMN IF NOT EXISTS height_cm DECIMAL(10,2) CHECK (height_cm > 0),
+    ADD COLUMN IF NOT EXISTS depth_cm DECIMAL(10,2) CHECK (depth_cm > 0),
+    ADD COLUMN IF NOT EXISTS weight_kg DECIMAL(10,3) CHECK (weight_kg > 0),
+    ADD COLUMN IF NOT EXISTS category_id UUID REFERENCES categories(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS slug VARCHAR(80) NOT NULL UNIQUE DEFAULT gen_random_uuid()::text,
+    ADD COLUMN IF NOT EXISTS meta_title VARCHAR(70) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS meta_description VARCHAR(160) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS min_order_quantity INTEGER NOT NULL DEFAULT 1 CHECK (min_order_quantity >= 1),
+    ADD COLUMN IF NOT EXISTS shelf_location VARCHAR(50) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(description, '')), 'B')
+    ) STORED;
+
+-- Added on its own: its check refers to min_order_quantity
+ALTER TABLE products ADD COLUMN IF NOT EXISTS max_order_quantity INTEGER CHECK (max_order_quantity >= min_order_quantity);
+
+-- Every price a product has had; old_price is NULL for the price set at creation
+CREATE TABLE IF NOT EXISTS product_price_history (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    old_price DECIMAL(10,2),
+    new_price DECIMAL(10,2) NOT NULL CHECK (new_price >= 0),
+    changed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Product tags (free-form labels, many per product)
+CREATE TABLE IF NOT EXISTS tags (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(50) NOT NULL,
+    slug VARCHAR(50) UNIQUE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS product_tags (
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (product_id, tag_id)
+);
+
+-- Cart items: cart versions for delta sync (added_price follows in migration 003, which
+-- backfills it)
+ALTER TABLE cart_items
+    ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS added_version BIGINT NOT NULL DEFAULT 0;
+
+-- Monotonically increasing cart version per user (used for delta sync)
+CREATE TABLE IF NOT EXISTS cart_versions (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    version BIGINT NOT NULL DEFAULT 0,
+    abandoned_at TIMESTAMP WITH TIME ZONE, -- Set when the cart went idle; cleared by the next cart change
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+ALTER TABLE cart_versions ADD COLUMN IF NOT EXISTS abandoned_at TIMESTAMP WITH TIME ZONE;
+
+-- Tombstones for removed cart items so clients can sync deletions
+CREATE TABLE IF NOT EXISTS cart_item_tombstones (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    cart_item_id UUID NOT NULL,
+    product_id UUID NOT NULL,
+    version BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Carts that went idle with items in them (input for abandoned-cart emails)
+CREATE TABLE IF NOT EXISTS cart_abandonments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    cart_version BIGINT NOT NULL, -- Cart version that was abandoned
+    item_count INTEGER NOT NULL,
+    subtotal DECIMAL(10,2) NOT NULL,
+    last_activity_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    released_orders INTEGER NOT NULL DEFAULT 0, -- Unpaid checkouts cancelled to release their stock
+    notified_at TIMESTAMP WITH TIME ZONE, -- Set once a reminder email went out
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE(user_id, cart_version)
+);
+
+-- Accessories (cross-sells) and upgrades (upsells) a seller recommends with a product
+CREATE TABLE IF NOT EXISTS product_recommendations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    recommended_product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('cross_sell', 'upsell')),
+    label VARCHAR(100) NOT NULL DEFAULT '', -- e.g. "Add batteries"
+    position INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE(product_id, recommended_product_id),
+    CHECK (product_id <> recommended_product_id)
+);
+
+-- Clicks on recommendations and recommended products added to the cart (conversion tracking)
+CREATE TABLE IF NOT EXISTS recommendation_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    recommended_product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(20) NOT NULL CHECK (action IN ('click', 'attach')),
+    placement VARCHAR(20) NOT NULL CHECK (placement IN ('product', 'cart', 'checkout')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Items the buyer moved out of the active cart to buy later
+CREATE TABLE IF NOT EXISTS saved_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE(user_id, product_id)
+);
+
+-- Products buyers keep an eye on; price_alert opts into price-drop notifications
+CREATE TABLE IF NOT EXISTS wishlist_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    price_alert BOOLEAN NOT NULL DEFAULT false,
+    target_price DECIMAL(10,2) CHECK (target_price > 0), -- alert only at or below this price (NULL = any drop)
+    alert_price DECIMAL(10,2) NOT NULL, -- price when added or last alerted; drops are measured from here
+    last_alerted_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE(user_id, product_id)
+);
+
+-- Orders: the status state machine replaced 'confirmed' with 'paid' and added 'refunded'
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS client_platform VARCHAR(20) NOT NULL DEFAULT 'unknown';
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conrelid = 'orders'::regclass AND conname = 'orders_status_check'
+            AND pg_get_constraintdef(oid) LIKE '%refunded%'
+    ) THEN
+        ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_status_check;
+        UPDATE orders SET status = 'paid' WHERE status = 'confirmed';
+        ALTER TABLE orders ADD CONSTRAINT orders_status_check
+            CHECK (status IN ('pending', 'paid', 'shipped', 'delivered', 'cancelled', 'refunded'));
+    END IF;
+END
+$$;
+
+-- Order items: per-seller fulfillment
+ALTER TABLE order_items
+    ADD COLUMN IF NOT EXISTS fulfillment_status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (fulfillment_status IN ('pending', 'shipped', 'fulfilled')),
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE DEFAULT now();
+
+-- Order status history (one row per status transition, used for the order timeline)
+CREATE TABLE IF NOT EXISTS order_status_history (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    from_status VARCHAR(20) NOT NULL,
+    to_status VARCHAR(20) NOT NULL,
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL, -- NULL for system transitions
+    actor_role VARCHAR(50) NOT NULL,
+    note TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Shipping address edits by buyers, and requests routed to support once the self-service
+-- window has closed; the audit trail of every address change
+CREATE TABLE IF NOT EXISTS order_address_changes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    requested_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    old_address TEXT NOT NULL DEFAULT '',
+    new_address TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('applied', 'support_requested', 'approved', 'rejected')),
+    reason VARCHAR(30) NOT NULL DEFAULT '',
+    resolved_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    resolution_note TEXT NOT NULL DEFAULT '',
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Push notification device tokens
+CREATE TABLE IF NOT EXISTS device_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token TEXT UNIQUE NOT NULL,
+    platform VARCHAR(20) NOT NULL CHECK (platform IN ('ios', 'android')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Cart analytics events (which platform performed each cart action)
+CREATE TABLE IF NOT EXISTS cart_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    action VARCHAR(20) NOT NULL,
+    product_id UUID REFERENCES products(id) ON DELETE SET NULL,
+    quantity INTEGER NOT NULL DEFAULT 0,
+    platform VARCHAR(20) NOT NULL DEFAULT 'unknown',
+    app_version VARCHAR(64),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Consent choices of users, kept as a history; the latest record applies and without one
+-- the user consented to nothing. The IP address is stored only as a keyed hash.
+CREATE TABLE IF NOT EXISTS consent_records (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    analytics BOOLEAN NOT NULL,
+    marketing BOOLEAN NOT NULL,
+    policy_version VARCHAR(32) NOT NULL,
+    ip_hash VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Requests by buyers to erase their account. A request awaits confirmation from the emailed
+-- link until confirm_by, then is scheduled for erasure after a grace period in which it can
+-- still be cancelled. Completed requests stay as the record of the erasure.
+CREATE TABLE IF NOT EXISTS erasure_requests (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(30) NOT NULL CHECK (status IN ('awaiting_confirmation', 'scheduled', 'completed', 'cancelled', 'expired')),
+    confirm_by TIMESTAMP WITH TIME ZONE NOT NULL,
+    confirmed_at TIMESTAMP WITH TIME ZONE,
+    scheduled_for TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    cancelled_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Stock held for pending orders during checkout; released back to stock if the order
+-- isn't paid before expires_at
+CREATE TABLE IF NOT EXISTS stock_reservations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'committed', 'released')),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Column mappings sellers save for product imports (file column -> product field, number format)
+CREATE TABLE IF NOT EXISTS import_templates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    seller_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    mapping JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE(seller_id, name)
+);
+
+-- Probable duplicate or copied listings across sellers, queued for admin review.
+-- product_id is the newer listing, duplicate_of_id the older one it resembles.
+CREATE TABLE IF NOT EXISTS duplicate_listings (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    duplicate_of_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    score DECIMAL(4,3) NOT NULL CHECK (score >= 0 AND score <= 1),
+    reasons TEXT[] NOT NULL DEFAULT '{}', -- image, name, description
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'dismissed', 'confirmed')),
+    reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    review_note TEXT NOT NULL DEFAULT '',
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE(product_id, duplicate_of_id),
+    CHECK (product_id <> duplicate_of_id)
+);
+
+-- When each listing was last compared against the catalog; listings changed since are checked again
+CREATE TABLE IF NOT EXISTS product_duplicate_checks (
+    product_id UUID PRIMARY KEY REFERENCES products(id) ON DELETE CASCADE,
+    checked_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Ledger of every change to product stock; the sum of a product's movements is its stock.
+-- Reservation and release movements mirror stock_reservations (used by the stock audit).
+CREATE TABLE IF NOT EXISTS stock_movements (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL CHECK (quantity <> 0), -- signed change to the stock
+    reason VARCHAR(20) NOT NULL CHECK (reason IN ('opening', 'seller_update', 'reservation', 'release', 'restock', 'adjustment')),
+    order_id UUID REFERENCES orders(id) ON DELETE SET NULL,
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Payments with external providers (one order may have several attempts)
+CREATE TABLE IF NOT EXISTS payments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE RESTRICT,
+    provider VARCHAR(20) NOT NULL,
+    provider_payment_id TEXT NOT NULL,
+    amount DECIMAL(10,2) NOT NULL CHECK (amount >= 0),
+    currency VARCHAR(3) NOT NULL,
+    status VARCHAR(40) NOT NULL,
+    fee DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (fee >= 0), -- provider fee, known once its payout is reconciled
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE(provider, provider_payment_id)
+);
+
+-- Chargebacks and inquiries buyers opened with their card issuer, as reported by the payment
+-- provider. status is the provider's (needs_response, under_review, won, lost, warning_*).
+CREATE TABLE IF NOT EXISTS disputes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE RESTRICT,
+    payment_id UUID NOT NULL REFERENCES payments(id) ON DELETE RESTRICT,
+    provider VARCHAR(20) NOT NULL,
+    provider_dispute_id TEXT NOT NULL,
+    amount DECIMAL(10,2) NOT NULL CHECK (amount >= 0),
+    currency VARCHAR(3) NOT NULL,
+    reason VARCHAR(50) NOT NULL DEFAULT '',
+    status VARCHAR(40) NOT NULL,
+    evidence_due_by TIMESTAMP WITH TIME ZONE,
+    evidence JSONB NOT NULL DEFAULT '{}',
+    evidence_submitted_at TIMESTAMP WITH TIME ZONE,
+    evidence_submitted_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    closed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE(provider, provider_dispute_id)
+);
+
+-- Payouts from the payment provider checked against the shop's payments and refunds. A
+-- payout that doesn't reconcile is blocked until it does or an admin resolves it.
+CREATE TABLE IF NOT EXISTS payout_reconciliations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    provider VARCHAR(20) NOT NULL,
+    provider_payout_id TEXT NOT NULL,
+    amount DECIMAL(12,2) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    arrival_date TIMESTAMP WITH TIME ZONE,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('reconciled', 'blocked', 'resolved')),
+    transaction_count INTEGER NOT NULL DEFAULT 0,
+    checked_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    resolved_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    resolution_note TEXT,
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE(provider, provider_payout_id)
+);
+
+-- Differences found by the latest check of a payout (replaced on every check)
+CREATE TABLE IF NOT EXISTS reconciliation_mismatches (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    reconciliation_id UUID NOT NULL REFERENCES payout_reconciliations(id) ON DELETE CASCADE,
+    balance_transaction_id TEXT NOT NULL,
+    kind VARCHAR(30) NOT NULL, -- unknown_payment, unknown_refund, amount_mismatch, status_mismatch, total_mismatch
+    order_id UUID REFERENCES orders(id) ON DELETE SET NULL,
+    expected DECIMAL(12,2),
+    actual DECIMAL(12,2),
+    detail TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Closed accounting months (period is the first day, UTC) with the figures they closed with.
+-- Orders and refunds of a closed month can only be changed by admins.
+CREATE TABLE IF NOT EXISTS financial_periods (
+    period DATE PRIMARY KEY CHECK (EXTRACT(DAY FROM period) = 1),
+    gross_sales DECIMAL(14,2) NOT NULL,
+    refunds DECIMAL(14,2) NOT NULL,
+    fees DECIMAL(14,2) NOT NULL,
+    net DECIMAL(14,2) NOT NULL,
+    order_count INTEGER NOT NULL,
+    refund_count INTEGER NOT NULL,
+    closed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    closed_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Seller payouts of an order held back, e.g. while a chargeback is open or while the provider
+-- payout that funded them doesn't reconcile (released_at is set when the hold is lifted)
+CREATE TABLE IF NOT EXISTS payout_holds (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    dispute_id UUID UNIQUE REFERENCES disputes(id) ON DELETE CASCADE,
+    reconciliation_id UUID REFERENCES payout_reconciliations(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL,
+    released_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+ALTER TABLE payout_holds ADD COLUMN IF NOT EXISTS reconciliation_id UUID REFERENCES payout_reconciliations(id) ON DELETE CASCADE;
+
+-- Retries of an order's failed card payment before the order is cancelled (dunning)
+CREATE TABLE IF NOT EXISTS payment_dunning (
+    order_id UUID PRIMARY KEY REFERENCES orders(id) ON DELETE CASCADE,
+    payment_id UUID NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'recovered', 'cancelled')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE,
+    final_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Error reports sent by frontend and mobile clients
+CREATE TABLE IF NOT EXISTS client_errors (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    request_id VARCHAR(100) NOT NULL,
+    client_request_id VARCHAR(100),
+    message TEXT NOT NULL,
+    stack TEXT,
+    url TEXT,
+    component VARCHAR(200),
+    user_agent TEXT,
+    platform VARCHAR(20) NOT NULL DEFAULT 'unknown',
+    app_version VARCHAR(64),
+    occurred_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Received payment provider webhook events (idempotency and replay protection)
+CREATE TABLE IF NOT EXISTS webhook_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    provider VARCHAR(20) NOT NULL,
+    event_id TEXT NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 1,
+    last_error TEXT,
+    processed_at TIMESTAMP WITH TIME ZONE,
+    discarded_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE(provider, event_id)
+);
+
+ALTER TABLE webhook_events ADD COLUMN IF NOT EXISTS discarded_at TIMESTAMP WITH TIME ZONE;
+
+-- Refunds issued for orders (full or partial)
+CREATE TABLE IF NOT EXISTS refunds (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE RESTRICT,
+    payment_id UUID NOT NULL REFERENCES payments(id) ON DELETE RESTRICT,
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL,
+    reason TEXT,
+    restock BOOLEAN NOT NULL DEFAULT false,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'failed')),
+    provider_refund_id TEXT,
+    actor_id UUID NOT NULL REFERENCES users(id) ON DELETE RESTRICT,
+    actor_role VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Order item quantities covered by a refund
+CREATE TABLE IF NOT EXISTS refund_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    refund_id UUID NOT NULL REFERENCES refunds(id) ON DELETE CASCADE,
+    order_item_id UUID NOT NULL REFERENCES order_items(id) ON DELETE RESTRICT,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE RESTRICT,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    amount DECIMAL(10,2) NOT NULL CHECK (amount >= 0)
+);
+
+-- How a refund is split across the payments of an order (e.g. store credit plus card)
+CREATE TABLE IF NOT EXISTS refund_allocations (
+    refund_id UUID NOT NULL REFERENCES refunds(id) ON DELETE CASCADE,
+    payment_id UUID NOT NULL REFERENCES payments(id) ON DELETE RESTRICT,
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+    provider_refund_id TEXT,
+    PRIMARY KEY (refund_id, payment_id)
+);
+
+-- Invoices, numbered sequentially without gaps from invoice_counter
+CREATE TABLE IF NOT EXISTS invoices (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID UNIQUE NOT NULL REFERENCES orders(id) ON DELETE RESTRICT,
+    number BIGINT UNIQUE NOT NULL,
+    tax_rate DECIMAL(5,4) NOT NULL DEFAULT 0 CHECK (tax_rate >= 0 AND tax_rate < 1),
+    issued_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS invoice_counter (
+    id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+    last_number BIGINT NOT NULL DEFAULT 0
+);
+INSERT INTO invoice_counter (id, last_number) VALUES (true, 0) ON CONFLICT (id) DO NOTHING;
+
+-- Tax included in each order item, recorded at checkout with the jurisdiction and rate that
+-- applied (TAX_JURISDICTION, INVOICE_TAX_RATE); the source of tax reports
+CREATE TABLE IF NOT EXISTS order_tax_lines (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    order_item_id UUID UNIQUE NOT NULL REFERENCES order_items(id) ON DELETE CASCADE,
+    seller_id UUID NOT NULL REFERENCES users(id) ON DELETE RESTRICT,
+    jurisdiction VARCHAR(20) NOT NULL,
+    rate DECIMAL(5,4) NOT NULL CHECK (rate >= 0 AND rate < 1),
+    gross_amount DECIMAL(10,2) NOT NULL CHECK (gross_amount >= 0),
+    taxable_amount DECIMAL(10,2) NOT NULL CHECK (taxable_amount >= 0),
+    tax_amount DECIMAL(10,2) NOT NULL CHECK (tax_amount >= 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- User-triggered background jobs (exports, imports, bulk operations)
+CREATE TABLE IF NOT EXISTS jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(50) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'completed', 'failed', 'cancelled')),
+    progress INTEGER NOT NULL DEFAULT 0 CHECK (progress >= 0 AND progress <= 100),
+    params JSONB NOT NULL DEFAULT '{}',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    run_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(), -- Scheduled jobs aren't claimed before this
+    error TEXT,
+    result_path TEXT,
+    result_expires_at TIMESTAMP WITH TIME ZONE,
+    started_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    discarded_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Columns and statuses jobs gained after the table was created (run_at is migration 005's)
+ALTER TABLE jobs
+    ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS discarded_at TIMESTAMP WITH TIME ZONE;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conrelid = 'jobs'::regclass AND conname = 'jobs_status_check'
+            AND pg_get_constraintdef(oid) LIKE '%cancelled%'
+    ) THEN
+        ALTER TABLE jobs DROP CONSTRAINT IF EXISTS jobs_status_check;
+        ALTER TABLE jobs ADD CONSTRAINT jobs_status_check
+            CHECK (status IN ('queued', 'running', 'completed', 'failed', 'cancelled'));
+    END IF;
+END
+$$;
+
+-- Error history of failed background work (dead-letter queue), per source
+CREATE TABLE IF NOT EXISTS delivery_errors (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    source VARCHAR(20) NOT NULL,
+    item_id UUID NOT NULL,
+    attempt INTEGER NOT NULL,
+    error TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- API requests per user per day and per month (quota enforcement survives restarts)
+CREATE TABLE IF NOT EXISTS api_usage (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    period VARCHAR(10) NOT NULL CHECK (period IN ('day', 'month')),
+    period_start DATE NOT NULL,
+    count INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, period, period_start)
+);
+
+-- Server-side sessions for cookie authentication (web storefront). Only a hash of the
+-- session token is stored; the token itself lives in an encrypted httpOnly cookie.
+CREATE TABLE IF NOT EXISTS sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    csrf_token VARCHAR(64) NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Cut-off for a user's access tokens after an account compromise: tokens issued before
+-- revoked_before are refused by the auth middleware
+CREATE TABLE IF NOT EXISTS token_revocations (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    revoked_before TIMESTAMP WITH TIME ZONE NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    revoked_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- One-time tokens for claiming the first admin account. Only a hash is stored; the token
+-- is printed at startup (or by cmd/admin) while no admin exists.
+CREATE TABLE IF NOT EXISTS admin_bootstrap_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    used_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Audit trail of admin bootstrap and break-glass access (append-only)
+CREATE TABLE IF NOT EXISTS admin_audit_log (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    actor_email VARCHAR(255) NOT NULL DEFAULT '',
+    action VARCHAR(50) NOT NULL,
+    detail TEXT NOT NULL DEFAULT '',
+    ip_address VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Requests to the routes in REQUEST_AUDIT_ROUTES with their bodies, tokens, email addresses
+-- and postal addresses redacted; pruned after REQUEST_AUDIT_RETENTION
+CREATE TABLE IF NOT EXISTS request_audit_log (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    request_id VARCHAR(64) NOT NULL DEFAULT '',
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    method VARCHAR(10) NOT NULL,
+    route VARCHAR(255) NOT NULL,
+    path TEXT NOT NULL,
+    status INTEGER NOT NULL,
+    request_body TEXT NOT NULL DEFAULT '',
+    response_body TEXT NOT NULL DEFAULT '',
+    ip_address VARCHAR(64) NOT NULL DEFAULT '',
+    duration_ms INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Security event log (failed admin logins, role changes, API keys issued, throttled clients,
+-- JWKS failures), kept apart from application logs and pruned after SECURITY_EVENT_RETENTION
+CREATE TABLE IF NOT EXISTS security_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    type VARCHAR(50) NOT NULL,
+    severity VARCHAR(10) NOT NULL CHECK (severity IN ('low', 'medium', 'high')),
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    ip_address VARCHAR(64) NOT NULL DEFAULT '',
+    detail TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- API keys of comparison-shopping partners syncing the catalog. Only a hash of the key is
+-- stored; fields lists the product fields the partner may read.
+CREATE TABLE IF NOT EXISTS partner_api_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    key_prefix VARCHAR(16) NOT NULL,
+    fields TEXT[] NOT NULL DEFAULT '{}',
+    daily_quota INTEGER NOT NULL DEFAULT 0 CHECK (daily_quota >= 0),
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Catalog requests per partner key per UTC day
+CREATE TABLE IF NOT EXISTS partner_api_usage (
+    key_id UUID NOT NULL REFERENCES partner_api_keys(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    count INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (key_id, day)
+);
+
+-- Outbox of catalog changes, written by a trigger on products in the transaction making the
+-- change, so partners can mirror the catalog from a feed instead of polling full lists. The
+-- feed is ordered by (xact_id, id) and only serves transactions older than the oldest one
+-- still running, so events committed late never land behind a cursor already handed out.
+CREATE TABLE IF NOT EXISTS catalog_changes (
+    id BIGSERIAL PRIMARY KEY,
+    xact_id XID8 NOT NULL DEFAULT pg_current_xact_id(),
+    product_id UUID NOT NULL, -- no foreign key: deletions stay in the feed
+    change VARCHAR(20) NOT NULL CHECK (change IN ('created', 'updated', 'deleted', 'stock_changed')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Anonymous carts, identified by a signed cart token held by the client and merged
+-- into the buyer's cart after login
+CREATE TABLE IF NOT EXISTS cart_sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS guest_cart_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    cart_session_id UUID NOT NULL REFERENCES cart_sessions(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE(cart_session_id, product_id)
+);
+
+-- Snapshots of carts shared by link (team purchasing); the link carries a signed token
+-- naming the share, and other users can import its items into their own cart
+CREATE TABLE IF NOT EXISTS cart_shares (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS cart_share_items (
+    share_id UUID NOT NULL REFERENCES cart_shares(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (share_id, product_id)
+);
+
+-- Prepaid gift cards, stored by the hash of their code, redeemed into store credit
+CREATE TABLE IF NOT EXISTS gift_cards (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    code_hash CHAR(64) NOT NULL UNIQUE,
+    code_last4 VARCHAR(4) NOT NULL,
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+    expires_at TIMESTAMP WITH TIME ZONE,
+    redeemed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    redeemed_at TIMESTAMP WITH TIME ZONE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Store credit ledger; a buyer's balance is the sum of their entries
+CREATE TABLE IF NOT EXISTS store_credit_entries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    amount DECIMAL(10,2) NOT NULL CHECK (amount <> 0),
+    reason VARCHAR(20) NOT NULL CHECK (reason IN ('gift_card', 'payment', 'payment_released', 'refund')),
+    order_id UUID REFERENCES orders(id) ON DELETE SET NULL,
+    refund_id UUID REFERENCES refunds(id) ON DELETE SET NULL,
+    gift_card_id UUID REFERENCES gift_cards(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Shipping labels sellers bought for their items of an order, one per seller and order. The
+-- label PDF is kept in private object storage under storage_key.
+CREATE TABLE IF NOT EXISTS shipping_labels (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    seller_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    job_id UUID REFERENCES jobs(id) ON DELETE SET NULL,
+    provider_label_id TEXT NOT NULL,
+    carrier VARCHAR(50) NOT NULL,
+    service VARCHAR(50) NOT NULL,
+    tracking_number VARCHAR(100) NOT NULL,
+    cost DECIMAL(10,2) NOT NULL CHECK (cost >= 0),
+    currency VARCHAR(3) NOT NULL,
+    storage_key TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE(order_id, seller_id)
+);
+
+-- Seller payout ledger: charges (negative) and credits settled with a seller's payouts, such
+-- as the cost of shipping labels bought through the shop
+CREATE TABLE IF NOT EXISTS seller_ledger_entries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    seller_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    amount DECIMAL(10,2) NOT NULL CHECK (amount <> 0),
+    currency VARCHAR(3) NOT NULL,
+    reason VARCHAR(20) NOT NULL CHECK (reason IN ('shipping_label')),
+    order_id UUID REFERENCES orders(id) ON DELETE SET NULL,
+    shipping_label_id UUID UNIQUE REFERENCES shipping_labels(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Indexes (idx_jobs_queued is migration 005's)
+CREATE INDEX IF NOT EXISTS idx_products_seller_id ON products(seller_id);
+CREATE INDEX IF NOT EXISTS idx_products_status ON products(status);
+CREATE INDEX IF NOT EXISTS idx_products_search_vector ON products USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_products_category_id ON products(category_id);
+CREATE INDEX IF NOT EXISTS idx_product_tags_tag_id ON product_tags(tag_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions(expires_at);
+CREATE INDEX IF NOT EXISTS idx_cart_sessions_expires_at ON cart_sessions(expires_at);
+CREATE INDEX IF NOT EXISTS idx_cart_shares_expires_at ON cart_shares(expires_at);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_created_at ON admin_audit_log(created_at);
+CREATE INDEX IF NOT EXISTS idx_request_audit_log_created_at ON request_audit_log(created_at);
+CREATE INDEX IF NOT EXISTS idx_request_audit_log_route ON request_audit_log(route, created_at);
+CREATE INDEX IF NOT EXISTS idx_security_events_created_at ON security_events(created_at);
+CREATE INDEX IF NOT EXISTS idx_security_events_type ON security_events(type, created_at);
+CREATE INDEX IF NOT EXISTS idx_disputes_order_id ON disputes(order_id);
+CREATE INDEX IF NOT EXISTS idx_disputes_status ON disputes(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_payout_holds_order_id ON payout_holds(order_id) WHERE released_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payout_holds_reconciliation ON payout_holds(reconciliation_id, order_id) WHERE reconciliation_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_payout_reconciliations_status ON payout_reconciliations(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_reconciliation_mismatches_reconciliation_id ON reconciliation_mismatches(reconciliation_id);
+CREATE INDEX IF NOT EXISTS idx_product_price_history_product_id ON product_price_history(product_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_cart_items_user_id ON cart_items(user_id);
+CREATE INDEX IF NOT EXISTS idx_cart_items_user_version ON cart_items(user_id, version);
+CREATE INDEX IF NOT EXISTS idx_cart_item_tombstones_user_version ON cart_item_tombstones(user_id, version);
+CREATE INDEX IF NOT EXISTS idx_cart_versions_idle ON cart_versions(updated_at) WHERE abandoned_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_cart_abandonments_created_at ON cart_abandonments(created_at);
+CREATE INDEX IF NOT EXISTS idx_saved_items_user_id ON saved_items(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_wishlist_items_user_id ON wishlist_items(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_wishlist_items_price_alert ON wishlist_items(product_id) WHERE price_alert;
+CREATE INDEX IF NOT EXISTS idx_product_recommendations_product_id ON product_recommendations(product_id, position);
+CREATE INDEX IF NOT EXISTS idx_recommendation_events_created_at ON recommendation_events(created_at);
+CREATE INDEX IF NOT EXISTS idx_orders_buyer_id ON orders(buyer_id);
+CREATE INDEX IF NOT EXISTS idx_order_items_order_id ON order_items(order_id);
+CREATE INDEX IF NOT EXISTS idx_order_items_product_id ON order_items(product_id);
+CREATE INDEX IF NOT EXISTS idx_cart_events_created_at ON cart_events(created_at);
+CREATE INDEX IF NOT EXISTS idx_orders_created_at ON orders(created_at);
+CREATE INDEX IF NOT EXISTS idx_stock_reservations_order_id ON stock_reservations(order_id);
+CREATE INDEX IF NOT EXISTS idx_stock_reservations_active_expiry ON stock_reservations(expires_at) WHERE status = 'active';
+CREATE INDEX IF NOT EXISTS idx_stock_movements_product_id ON stock_movements(product_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_import_templates_seller_id ON import_templates(seller_id);
+CREATE INDEX IF NOT EXISTS idx_duplicate_listings_status ON duplicate_listings(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_products_image_hash ON products(image_hash) WHERE image_hash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_products_updated_at ON products(updated_at);
+CREATE INDEX IF NOT EXISTS idx_payments_order_id ON payments(order_id);
+CREATE INDEX IF NOT EXISTS idx_client_errors_created_at ON client_errors(created_at);
+CREATE INDEX IF NOT EXISTS idx_device_tokens_user_id ON device_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_order_status_history_order_id ON order_status_history(order_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_order_address_changes_order_id ON order_address_changes(order_id, created_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_order_address_changes_open ON order_address_changes(order_id) WHERE status = 'support_requested';
+CREATE INDEX IF NOT EXISTS idx_refunds_order_id ON refunds(order_id);
+CREATE INDEX IF NOT EXISTS idx_refund_items_order_item_id ON refund_items(order_item_id);
+CREATE INDEX IF NOT EXISTS idx_refund_allocations_payment_id ON refund_allocations(payment_id);
+CREATE INDEX IF NOT EXISTS idx_order_tax_lines_order_id ON order_tax_lines(order_id);
+CREATE INDEX IF NOT EXISTS idx_consent_records_user_created ON consent_records(user_id, created_at DESC);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_erasure_requests_open ON erasure_requests(user_id) WHERE status IN ('awaiting_confirmation', 'scheduled');
+CREATE INDEX IF NOT EXISTS idx_erasure_requests_due ON erasure_requests(scheduled_for) WHERE status = 'scheduled';
+CREATE INDEX IF NOT EXISTS idx_store_credit_entries_user_id ON store_credit_entries(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_shipping_labels_seller_id ON shipping_labels(seller_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_seller_ledger_entries_seller_id ON seller_ledger_entries(seller_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_jobs_user_id ON jobs(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_delivery_errors_item ON delivery_errors(source, item_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_catalog_changes_position ON catalog_changes(xact_id, id);
+CREATE INDEX IF NOT EXISTS idx_catalog_changes_created_at ON catalog_changes(created_at);
+
+-- Timestamp and catalog change feed triggers
+CREATE OR REPLACE FUNCTION update_updated_at_column()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = now();
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY[
+        'users',
+        'products',
+        'categories',
+        'tags',
+        'cart_items',
+        'orders',
+        'device_tokens',
+        'stock_reservations',
+        'payments',
+        'payment_dunning',
+        'disputes',
+        'payout_reconciliations',
+        'order_items',
+        'refunds',
+        'jobs',
+        'import_templates',
+        'wishlist_items',
+        'guest_cart_items',
+        'partner_api_keys',
+        'token_revocations'
+    ] LOOP
+        IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgrelid = t::regclass AND tgname = 'update_' || t || '_updated_at') THEN
+            EXECUTE format('CREATE TRIGGER %I BEFORE UPDATE ON %I FOR EACH ROW EXECUTE FUNCTION update_updated_at_column()',
+                'update_' || t || '_updated_at', t);
+        END IF;
+    END LOOP;
+END
+$$;
+
+-- Record product changes in the catalog change feed. Updates of only the stock are recorded
+-- as stock_changed; changes to fields partners never see, and updates changing nothing, aren't
+-- recorded.
+CREATE OR REPLACE FUNCTION record_catalog_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO catalog_changes (product_id, change) VALUES (NEW.id, 'created');
+    ELSIF TG_OP = 'DELETE' THEN
+        INSERT INTO catalog_changes (product_id, change) VALUES (OLD.id, 'deleted');
+    ELSIF to_jsonb(NEW) - ARRAY['stock', 'updated_at', 'shelf_location', 'image_hash', 'search_vector']
+            IS DISTINCT FROM to_jsonb(OLD) - ARRAY['stock', 'updated_at', 'shelf_location', 'image_hash', 'search_vector'] THEN
+        INSERT INTO catalog_changes (product_id, change) VALUES (NEW.id, 'updated');
+    ELSIF NEW.stock <> OLD.stock THEN
+        INSERT INTO catalog_changes (product_id, change) VALUES (NEW.id, 'stock_changed');
+    END IF;
+    RETURN NULL;
+END;
+$$ language 'plpgsql';
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgrelid = 'products'::regclass AND tgname = 'record_products_catalog_change') THEN
+        CREATE TRIGGER record_products_catalog_change AFTER INSERT OR UPDATE OR DELETE ON products FOR EACH ROW EXECUTE FUNCTION record_catalog_change();
+    END IF;
+END
+$$;
+
+-- Row level security (the original tables' policies are unchanged)
+ALTER TABLE users ENABLE ROW LEVEL SECURITY;
+ALTER TABLE products ENABLE ROW LEVEL SECURITY;
+ALTER TABLE categories ENABLE ROW LEVEL SECURITY;
+ALTER TABLE tags ENABLE ROW LEVEL SECURITY;
+ALTER TABLE product_tags ENABLE ROW LEVEL SECURITY;
+ALTER TABLE product_price_history ENABLE ROW LEVEL SECURITY;
+ALTER TABLE cart_items ENABLE ROW LEVEL SECURITY;
+ALTER TABLE cart_versions ENABLE ROW LEVEL SECURITY;
+ALTER TABLE cart_item_tombstones ENABLE ROW LEVEL SECURITY;
+ALTER TABLE cart_abandonments ENABLE ROW LEVEL SECURITY;
+ALTER TABLE saved_items ENABLE ROW LEVEL SECURITY;
+ALTER TABLE wishlist_items ENABLE ROW LEVEL SECURITY;
+ALTER TABLE product_recommendations ENABLE ROW LEVEL SECURITY;
+ALTER TABLE recommendation_events ENABLE ROW LEVEL SECURITY;
+ALTER TABLE orders ENABLE ROW LEVEL SECURITY;
+ALTER TABLE order_items ENABLE ROW LEVEL SECURITY;
+ALTER TABLE order_status_history ENABLE ROW LEVEL SECURITY;
+ALTER TABLE order_address_changes ENABLE ROW LEVEL SECURITY;
+ALTER TABLE device_tokens ENABLE ROW LEVEL SECURITY;
+ALTER TABLE cart_events ENABLE ROW LEVEL SECURITY;
+ALTER TABLE stock_reservations ENABLE ROW LEVEL SECURITY;
+ALTER TABLE stock_movements ENABLE ROW LEVEL SECURITY;
+ALTER TABLE import_templates ENABLE ROW LEVEL SECURITY;
+ALTER TABLE duplicate_listings ENABLE ROW LEVEL SECURITY;
+ALTER TABLE product_duplicate_checks ENABLE ROW LEVEL SECURITY;
+ALTER TABLE payments ENABLE ROW LEVEL SECURITY;
+ALTER TABLE payment_dunning ENABLE ROW LEVEL SECURITY;
+ALTER TABLE disputes ENABLE ROW LEVEL SECURITY;
+ALTER TABLE payout_holds ENABLE ROW LEVEL SECURITY;
+ALTER TABLE payout_reconciliations ENABLE ROW LEVEL SECURITY;
+ALTER TABLE reconciliation_mismatches ENABLE ROW LEVEL SECURITY;
+ALTER TABLE financial_periods ENABLE ROW LEVEL SECURITY;
+ALTER TABLE client_errors ENABLE ROW LEVEL SECURITY;
+ALTER TABLE webhook_events ENABLE ROW LEVEL SECURITY;
+ALTER TABLE refunds ENABLE ROW LEVEL SECURITY;
+ALTER TABLE refund_items ENABLE ROW LEVEL SECURITY;
+ALTER TABLE invoices ENABLE ROW LEVEL SECURITY;
+ALTER TABLE order_tax_lines ENABLE ROW LEVEL SECURITY;
+ALTER TABLE consent_records ENABLE ROW LEVEL SECURITY;
+ALTER TABLE erasure_requests ENABLE ROW LEVEL SECURITY;
+ALTER TABLE invoice_counter ENABLE ROW LEVEL SECURITY;
+ALTER TABLE jobs ENABLE ROW LEVEL SECURITY;
+ALTER TABLE delivery_errors ENABLE ROW LEVEL SECURITY;
+ALTER TABLE api_usage ENABLE ROW LEVEL SECURITY;
+ALTER TABLE sessions ENABLE ROW LEVEL SECURITY;
+ALTER TABLE token_revocations ENABLE ROW LEVEL SECURITY;
+ALTER TABLE cart_sessions ENABLE ROW LEVEL SECURITY;
+ALTER TABLE guest_cart_items ENABLE ROW LEVEL SECURITY;
+ALTER TABLE cart_shares ENABLE ROW LEVEL SECURITY;
+ALTER TABLE cart_share_items ENABLE ROW LEVEL SECURITY;
+ALTER TABLE admin_bootstrap_tokens ENABLE ROW LEVEL SECURITY;
+ALTER TABLE admin_audit_log ENABLE ROW LEVEL SECURITY;
+ALTER TABLE request_audit_log ENABLE ROW LEVEL SECURITY;
+ALTER TABLE security_events ENABLE ROW LEVEL SECURITY;
+ALTER TABLE partner_api_keys ENABLE ROW LEVEL SECURITY;
+ALTER TABLE partner_api_usage ENABLE ROW LEVEL SECURITY;
+ALTER TABLE catalog_changes ENABLE ROW LEVEL SECURITY;
+ALTER TABLE refund_allocations ENABLE ROW LEVEL SECURITY;
+ALTER TABLE gift_cards ENABLE ROW LEVEL SECURITY;
+ALTER TABLE store_credit_entries ENABLE ROW LEVEL SECURITY;
+ALTER TABLE shipping_labels ENABLE ROW LEVEL SECURITY;
+ALTER TABLE seller_ledger_entries ENABLE ROW LEVEL SECURITY;
+
+COMMIT;
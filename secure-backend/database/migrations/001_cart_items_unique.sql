@@ -0,0 +1,37 @@
+-- Merge duplicate cart items and enforce one cart item per user and product.
+-- schema.sql already declares UNIQUE(user_id, product_id); databases created before the
+-- constraint may hold duplicates written by concurrent adds. Safe to run more than once.
+
+BEGIN;
+
+-- Keep the most recently changed row of each duplicate set with the combined quantity
+-- (capped at the per-item limit of 100) and delete the rest
+WITH ranked AS (
+    SELECT id, user_id, product_id,
+        ROW_NUMBER() OVER (PARTITION BY user_id, product_id ORDER BY version DESC, updated_at DESC, id) AS rn,
+        LEAST(SUM(quantity) OVER (PARTITION BY user_id, product_id), 100) AS merged_quantity,
+        MIN(added_version) OVER (PARTITION BY user_id, product_id) AS first_added_version
+    FROM cart_items
+), merged AS (
+    UPDATE cart_items ci
+    SET quantity = r.merged_quantity, added_version = r.first_added_version, updated_at = now()
+    FROM ranked r
+    WHERE ci.id = r.id AND r.rn = 1
+        AND EXISTS (SELECT 1 FROM ranked d WHERE d.user_id = r.user_id AND d.product_id = r.product_id AND d.rn > 1)
+)
+DELETE FROM cart_items ci
+USING ranked r
+WHERE ci.id = r.id AND r.rn > 1;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conrelid = 'cart_items'::regclass AND conname = 'cart_items_user_id_product_id_key'
+    ) THEN
+        ALTER TABLE cart_items ADD CONSTRAINT cart_items_user_id_product_id_key UNIQUE (user_id, product_id);
+    END IF;
+END
+$$;
+
+COMMIT;
@@ -0,0 +1,26 @@
+-- Seed the stock movement ledger for products that predate it, so the stock audit starts
+-- from a clean baseline: an opening movement with the units on the shelf plus those held
+-- for orders, and a reservation movement per active or committed reservation.
+-- Safe to run more than once.
+
+BEGIN;
+
+CREATE TEMP TABLE unledgered_products ON COMMIT DROP AS
+SELECT p.id, p.stock
+FROM products p
+WHERE NOT EXISTS (SELECT 1 FROM stock_movements m WHERE m.product_id = p.id);
+
+INSERT INTO stock_movements (product_id, quantity, reason, note)
+SELECT u.id, u.stock + COALESCE(SUM(r.quantity), 0), 'opening', 'Backfilled by migration 002'
+FROM unledgered_products u
+LEFT JOIN stock_reservations r ON r.product_id = u.id AND r.status IN ('active', 'committed')
+GROUP BY u.id, u.stock
+HAVING u.stock + COALESCE(SUM(r.quantity), 0) <> 0;
+
+INSERT INTO stock_movements (product_id, quantity, reason, order_id, note, created_at)
+SELECT r.product_id, -r.quantity, 'reservation', r.order_id, 'Backfilled by migration 002', r.created_at
+FROM stock_reservations r
+JOIN unledgered_products u ON u.id = r.product_id
+WHERE r.status IN ('active', 'committed');
+
+COMMIT;
@@ -0,0 +1,26 @@
+-- Snapshot the product price on cart items so carts can flag price changes.
+-- Items already in carts start from the current price. Safe to run more than once.
+
+BEGIN;
+
+ALTER TABLE cart_items ADD COLUMN IF NOT EXISTS added_price DECIMAL(10,2);
+
+UPDATE cart_items ci
+SET added_price = p.price
+FROM products p
+WHERE p.id = ci.product_id AND ci.added_price IS NULL;
+
+ALTER TABLE cart_items ALTER COLUMN added_price SET NOT NULL;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conrelid = 'cart_items'::regclass AND conname = 'cart_items_added_price_check'
+    ) THEN
+        ALTER TABLE cart_items ADD CONSTRAINT cart_items_added_price_check CHECK (added_price >= 0);
+    END IF;
+END
+$$;
+
+COMMIT;
@@ -0,0 +1,11 @@
+-- Record the payment split of refunds that predate refund_allocations: each was returned in
+-- full through the single payment it references. Safe to run more than once.
+
+BEGIN;
+
+INSERT INTO refund_allocations (refund_id, payment_id, amount, provider_refund_id)
+SELECT r.id, r.payment_id, r.amount, r.provider_refund_id
+FROM refunds r
+WHERE NOT EXISTS (SELECT 1 FROM refund_allocations a WHERE a.refund_id = r.id);
+
+COMMIT;
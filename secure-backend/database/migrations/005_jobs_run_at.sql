@@ -0,0 +1,11 @@
+-- Let jobs be scheduled for later: workers only claim queued jobs whose run_at has passed.
+-- Existing jobs are due right away. Safe to run more than once.
+
+BEGIN;
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS run_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now();
+
+DROP INDEX IF EXISTS idx_jobs_queued;
+CREATE INDEX idx_jobs_queued ON jobs(run_at) WHERE status = 'queued';
+
+COMMIT;
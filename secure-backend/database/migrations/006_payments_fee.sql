@@ -0,0 +1,9 @@
+-- Record the payment provider's processing fee on each payment, filled in when the payout
+-- that included the charge is reconciled. Existing payments start at zero and pick up their
+-- fee on the next reconciliation of their payout. Safe to run more than once.
+
+BEGIN;
+
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS fee DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (fee >= 0);
+
+COMMIT;
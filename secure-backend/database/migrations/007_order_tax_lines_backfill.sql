@@ -0,0 +1,16 @@
+-- Record tax lines for orders placed before order_tax_lines. Only invoiced orders are covered:
+-- their invoice holds the rate that applied. The jurisdiction wasn't recorded then, so these
+-- lines use 'default'. Safe to run more than once.
+
+BEGIN;
+
+INSERT INTO order_tax_lines (order_id, order_item_id, seller_id, jurisdiction, rate, gross_amount, taxable_amount, tax_amount)
+SELECT oi.order_id, oi.id, p.seller_id, 'default', i.tax_rate, oi.total_price,
+    ROUND(oi.total_price / (1 + i.tax_rate), 2),
+    oi.total_price - ROUND(oi.total_price / (1 + i.tax_rate), 2)
+FROM order_items oi
+JOIN invoices i ON i.order_id = oi.order_id
+JOIN products p ON p.id = oi.product_id
+WHERE NOT EXISTS (SELECT 1 FROM order_tax_lines t WHERE t.order_item_id = oi.id);
+
+COMMIT;
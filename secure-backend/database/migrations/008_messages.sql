@@ -0,0 +1,38 @@
+-- Create the buyer-to-seller messaging tables for databases that predate them: threads
+-- between a buyer and a seller about a product or an order, and their moderated messages.
+-- Safe to run more than once.
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS message_threads (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    buyer_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    seller_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    product_id UUID REFERENCES products(id) ON DELETE SET NULL,
+    order_id UUID REFERENCES orders(id) ON DELETE CASCADE,
+    flagged BOOLEAN NOT NULL DEFAULT false,
+    last_message_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    CHECK (product_id IS NULL OR order_id IS NULL)
+);
+
+CREATE TABLE IF NOT EXISTS messages (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    thread_id UUID NOT NULL REFERENCES message_threads(id) ON DELETE CASCADE,
+    sender_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    body TEXT NOT NULL,
+    flags TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_message_threads_product ON message_threads(buyer_id, product_id) WHERE order_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_message_threads_order ON message_threads(buyer_id, seller_id, order_id) WHERE order_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_message_threads_buyer_id ON message_threads(buyer_id, last_message_at);
+CREATE INDEX IF NOT EXISTS idx_message_threads_seller_id ON message_threads(seller_id, last_message_at);
+CREATE INDEX IF NOT EXISTS idx_message_threads_order_id ON message_threads(order_id) WHERE order_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_messages_thread_id ON messages(thread_id, created_at);
+
+ALTER TABLE message_threads ENABLE ROW LEVEL SECURITY;
+ALTER TABLE messages ENABLE ROW LEVEL SECURITY;
+
+COMMIT;
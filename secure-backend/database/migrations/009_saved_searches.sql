@@ -0,0 +1,56 @@
+-- Create the saved search tables for databases that predate them: buyers' saved queries,
+-- the products they were alerted about, and the positions of catalog change feed readers
+-- such as the saved search alerts. Safe to run more than once.
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS saved_searches (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    query VARCHAR(200) NOT NULL DEFAULT '',
+    category VARCHAR(100) NOT NULL DEFAULT '',
+    tag VARCHAR(100) NOT NULL DEFAULT '',
+    min_price DECIMAL(10,2) CHECK (min_price >= 0),
+    max_price DECIMAL(10,2) CHECK (max_price >= 0),
+    alert BOOLEAN NOT NULL DEFAULT false,
+    last_alerted_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    CHECK (query <> '' OR category <> '' OR tag <> '' OR min_price IS NOT NULL OR max_price IS NOT NULL),
+    CHECK (max_price >= min_price)
+);
+
+CREATE TABLE IF NOT EXISTS saved_search_matches (
+    saved_search_id UUID NOT NULL REFERENCES saved_searches(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    PRIMARY KEY (saved_search_id, product_id)
+);
+
+CREATE TABLE IF NOT EXISTS catalog_change_readers (
+    name VARCHAR(50) PRIMARY KEY,
+    xact_id XID8 NOT NULL,
+    change_id BIGINT NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_saved_searches_user_id ON saved_searches(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_saved_search_matches_product_id ON saved_search_matches(product_id);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_trigger
+        WHERE tgrelid = 'saved_searches'::regclass AND tgname = 'update_saved_searches_updated_at'
+    ) THEN
+        CREATE TRIGGER update_saved_searches_updated_at BEFORE UPDATE ON saved_searches FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+    END IF;
+END
+$$;
+
+ALTER TABLE saved_searches ENABLE ROW LEVEL SECURITY;
+ALTER TABLE saved_search_matches ENABLE ROW LEVEL SECURITY;
+ALTER TABLE catalog_change_readers ENABLE ROW LEVEL SECURITY;
+
+COMMIT;
@@ -0,0 +1,41 @@
+-- Create the seller ratings table for databases that predate it: buyers' ratings of a seller's
+-- shipping speed and communication for an order, one per order and seller. Safe to run more
+-- than once.
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS seller_ratings (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    seller_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    buyer_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    shipping_speed SMALLINT NOT NULL CHECK (shipping_speed BETWEEN 1 AND 5),
+    communication SMALLINT NOT NULL CHECK (communication BETWEEN 1 AND 5),
+    comment TEXT NOT NULL DEFAULT '',
+    flags TEXT[] NOT NULL DEFAULT '{}',
+    status VARCHAR(20) NOT NULL DEFAULT 'published' CHECK (status IN ('published', 'hidden')),
+    moderation_note TEXT NOT NULL DEFAULT '',
+    moderated_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    moderated_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE(order_id, seller_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_seller_ratings_seller_id ON seller_ratings(seller_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_seller_ratings_buyer_id ON seller_ratings(buyer_id);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_trigger
+        WHERE tgrelid = 'seller_ratings'::regclass AND tgname = 'update_seller_ratings_updated_at'
+    ) THEN
+        CREATE TRIGGER update_seller_ratings_updated_at BEFORE UPDATE ON seller_ratings FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+    END IF;
+END
+$$;
+
+ALTER TABLE seller_ratings ENABLE ROW LEVEL SECURITY;
+
+COMMIT;
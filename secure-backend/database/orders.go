@@ -0,0 +1,195 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"secure-backend/models"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ErrFulfillmentStarted is returned when cancelling an order some of whose items were already shipped
+var ErrFulfillmentStarted = errors.New("order items were already shipped")
+
+// GetOrdersByBuyer returns a page of a buyer's orders (newest first) and the total order count
+func GetOrdersByBuyer(ctx context.Context, buyerID string, limit, offset int) ([]models.Order, int, error) {
+	var total int
+	if err := DB.GetContext(ctx, &total, `SELECT COUNT(*) FROM orders WHERE buyer_id = $1`, buyerID); err != nil {
+		return nil, 0, err
+	}
+
+	var orders []models.Order
+	err := DB.SelectContext(ctx, &orders, `
+		SELECT id, buyer_id, status, total_amount, COALESCE(shipping_address, '') AS shipping_address, client_platform, created_at, updated_at
+		FROM orders
+		WHERE buyer_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`, buyerID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return orders, total, nil
+}
+
+// GetOrderByBuyer retrieves a single order ensuring it belongs to the specified buyer
+func GetOrderByBuyer(ctx context.Context, orderID, buyerID string) (*models.Order, error) {
+	var order models.Order
+	err := DB.GetContext(ctx, &order, `
+		SELECT id, buyer_id, status, total_amount, COALESCE(shipping_address, '') AS shipping_address, client_platform, created_at, updated_at
+		FROM orders
+		WHERE id = $1 AND buyer_id = $2
+	`, orderID, buyerID)
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// GetOrderItemsForOrders retrieves the items of the given orders with product details, grouped by order ID
+func GetOrderItemsForOrders(ctx context.Context, orderIDs []string) (map[string][]models.OrderItemWithProduct, error) {
+	itemsByOrder := make(map[string][]models.OrderItemWithProduct)
+	if len(orderIDs) == 0 {
+		return itemsByOrder, nil
+	}
+
+	rows, err := DB.QueryContext(ctx, `
+		SELECT
+			oi.id, oi.order_id, oi.product_id, oi.quantity, oi.unit_price, oi.total_price,
+			oi.fulfillment_status, oi.created_at, oi.updated_at,
+			p.id, p.name, p.description, p.price, p.image, p.stock, p.status, p.seller_id, p.created_at, p.updated_at
+		FROM order_items oi
+		JOIN products p ON oi.product_id = p.id
+		WHERE oi.order_id = ANY($1)
+		ORDER BY oi.created_at ASC`, pq.Array(orderIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.OrderItemWithProduct
+		err := rows.Scan(
+			&item.ID, &item.OrderID, &item.ProductID, &item.Quantity, &item.UnitPrice, &item.TotalPrice,
+			&item.FulfillmentStatus, &item.CreatedAt, &item.UpdatedAt,
+			&item.Product.ID, &item.Product.Name, &item.Product.Description, &item.Product.Price,
+			&item.Product.Image, &item.Product.Stock, &item.Product.Status, &item.Product.SellerID,
+			&item.Product.CreatedAt, &item.Product.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		itemsByOrder[item.OrderID] = append(itemsByOrder[item.OrderID], item)
+	}
+
+	return itemsByOrder, rows.Err()
+}
+
+// GetOrdersByBuyerSince returns a buyer's orders that changed after the given time
+func GetOrdersByBuyerSince(ctx context.Context, buyerID string, since time.Time) ([]models.Order, error) {
+	var orders []models.Order
+	err := DB.SelectContext(ctx, &orders, `
+		SELECT id, buyer_id, status, total_amount, COALESCE(shipping_address, '') AS shipping_address, client_platform, created_at, updated_at
+		FROM orders
+		WHERE buyer_id = $1 AND updated_at > $2
+		ORDER BY updated_at ASC
+	`, buyerID, since)
+	return orders, err
+}
+
+// GetOrderByID retrieves a single order by its ID
+func GetOrderByID(ctx context.Context, orderID string) (*models.Order, error) {
+	var order models.Order
+	err := DB.GetContext(ctx, &order, `
+		SELECT id, buyer_id, status, total_amount, COALESCE(shipping_address, '') AS shipping_address, client_platform, created_at, updated_at
+		FROM orders
+		WHERE id = $1
+	`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// UpdateOrderStatus moves an order from one status to another and records the transition
+// in the order timeline. It returns sql.ErrNoRows if the order is no longer in fromStatus
+// and ErrFulfillmentStarted when cancelling an order a seller already started shipping.
+func UpdateOrderStatus(ctx context.Context, change *models.OrderStatusChange) error {
+	return WithTx(ctx, func(tx *sqlx.Tx) error {
+		if change.ToStatus == "cancelled" {
+			// Lock the order so a seller can't ship an item between the check and the cancellation
+			if _, err := tx.ExecContext(ctx, `SELECT 1 FROM orders WHERE id = $1 FOR UPDATE`, change.OrderID); err != nil {
+				return err
+			}
+			var fulfillmentStarted bool
+			err := tx.GetContext(ctx, &fulfillmentStarted, `
+				SELECT EXISTS (SELECT 1 FROM order_items WHERE order_id = $1 AND fulfillment_status <> 'pending')
+			`, change.OrderID)
+			if err != nil {
+				return err
+			}
+			if fulfillmentStarted {
+				return ErrFulfillmentStarted
+			}
+		}
+
+		result, err := tx.ExecContext(ctx, `
+			UPDATE orders SET status = $3, updated_at = now()
+			WHERE id = $1 AND status = $2
+		`, change.OrderID, change.FromStatus, change.ToStatus)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return sql.ErrNoRows
+		}
+
+		// Settle the stock held for the order during checkout
+		switch change.ToStatus {
+		case "paid":
+			err = CommitReservations(ctx, tx, change.OrderID)
+		case "cancelled":
+			err = releaseReservations(ctx, tx, change.OrderID)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Store credit applied to an unpaid order goes back to the buyer; paid orders are refunded instead
+		if change.FromStatus == "pending" && change.ToStatus == "cancelled" {
+			if err := releaseStoreCredit(ctx, tx, change.OrderID); err != nil {
+				return err
+			}
+		}
+
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO order_status_history (order_id, from_status, to_status, actor_id, actor_role, note)
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))
+			RETURNING id, created_at
+		`, change.OrderID, change.FromStatus, change.ToStatus, change.ActorID, change.ActorRole, change.Note).Scan(
+			&change.ID, &change.CreatedAt,
+		)
+		return err
+	})
+}
+
+// GetOrderStatusHistory returns the status transitions of an order, oldest first
+func GetOrderStatusHistory(ctx context.Context, orderID string) ([]models.OrderStatusChange, error) {
+	history := []models.OrderStatusChange{}
+	err := DB.SelectContext(ctx, &history, `
+		SELECT id, order_id, from_status, to_status, actor_id, actor_role, COALESCE(note, '') AS note, created_at
+		FROM order_status_history
+		WHERE order_id = $1
+		ORDER BY created_at ASC
+	`, orderID)
+	return history, err
+}
@@ -0,0 +1,30 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// execOwned runs an UPDATE or DELETE whose WHERE clause matches the row by its ID and its
+// owner together, so checking ownership and changing the row is a single statement with no
+// window between them. It returns sql.ErrNoRows when nothing matched: a row that belongs to
+// someone else is indistinguishable from a missing one, which is how handlers report both.
+// Statements that need the row back use RETURNING with GetContext instead, which fails with
+// sql.ErrNoRows the same way.
+func execOwned(ctx context.Context, q sqlx.ExecerContext, query string, args ...interface{}) error {
+	result, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+//go:build e2e
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"secure-backend/models"
+
+	"github.com/google/uuid"
+)
+
+func TestOwnedMutations(t *testing.T) {
+	userIDs := createTestUsers(t, "owned", "seller", "seller", "buyer")
+	users := map[string]string{"seller": userIDs[0], "other": userIDs[1], "buyer": userIDs[2]}
+	ctx := context.Background()
+
+	var productID string
+	err := DB.GetContext(ctx, &productID, `
+		INSERT INTO products (name, price, stock, status, seller_id)
+		VALUES ('Owned product', 5, 10, 'published', $1)
+		RETURNING id
+	`, users["seller"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	update := &models.Product{ID: productID, SellerID: users["other"], Name: "Taken over", Price: 100, Stock: 10, Status: "published"}
+	if err := UpdateProduct(ctx, update); err != sql.ErrNoRows {
+		t.Fatalf("UpdateProduct by another seller: got %v, want sql.ErrNoRows", err)
+	}
+	if err := DeleteProduct(ctx, productID, users["other"]); err != sql.ErrNoRows {
+		t.Fatalf("DeleteProduct by another seller: got %v, want sql.ErrNoRows", err)
+	}
+
+	// The seller's own update goes through and records the price it replaced
+	update.SellerID, update.Name, update.Price = users["seller"], "Owned product", 700
+	if err := UpdateProduct(ctx, update); err != nil {
+		t.Fatalf("UpdateProduct by its seller: %v", err)
+	}
+	history, err := GetPriceHistory(ctx, productID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].OldPrice == nil || *history[0].OldPrice != 500 || history[0].NewPrice != 700 {
+		t.Fatalf("price history = %+v, want one change from 5.00 to 7.00", history)
+	}
+
+	item, err := AddToCart(ctx, users["buyer"], productID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateCartItemQuantity(ctx, item.ID, users["other"], 3); err != sql.ErrNoRows {
+		t.Fatalf("UpdateCartItemQuantity of another user's item: got %v, want sql.ErrNoRows", err)
+	}
+	if err := RemoveFromCart(ctx, item.ID, users["other"]); err != sql.ErrNoRows {
+		t.Fatalf("RemoveFromCart of another user's item: got %v, want sql.ErrNoRows", err)
+	}
+	if err := RemoveFromCart(ctx, item.ID, users["buyer"]); err != nil {
+		t.Fatalf("RemoveFromCart by its owner: %v", err)
+	}
+
+	// Bulk changes skip other sellers' products, and deletes keep ordered ones
+	var orderID, unorderedID string
+	if err := DB.GetContext(ctx, &orderID, `INSERT INTO orders (buyer_id, status, total_amount) VALUES ($1, 'pending', 7) RETURNING id`, users["buyer"]); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { DB.ExecContext(ctx, `DELETE FROM orders WHERE id = $1`, orderID) })
+	if _, err := DB.ExecContext(ctx, `INSERT INTO order_items (order_id, product_id, quantity, unit_price, total_price) VALUES ($1, $2, 1, 7, 7)`, orderID, productID); err != nil {
+		t.Fatal(err)
+	}
+	err = DB.GetContext(ctx, &unorderedID, `
+		INSERT INTO products (name, price, stock, status, seller_id)
+		VALUES ('Unordered product', 5, 10, 'draft', $1)
+		RETURNING id
+	`, users["seller"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sellerID := users["seller"]
+	ids := []string{productID, unorderedID, uuid.NewString()}
+	archived, err := ArchiveProducts(ctx, users["other"], ids, &models.AuditEntry{Action: models.AuditProductsArchived})
+	if err != nil || len(archived) != 0 {
+		t.Fatalf("ArchiveProducts by another seller = %v, %v; want nothing archived", archived, err)
+	}
+	deleted, ordered, err := DeleteProducts(ctx, users["seller"], ids, &models.AuditEntry{ActorID: &sellerID, Action: models.AuditProductsDeleted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0] != unorderedID || len(ordered) != 1 || ordered[0] != productID {
+		t.Fatalf("DeleteProducts = %v deleted, %v ordered; want %s deleted and %s kept", deleted, ordered, unorderedID, productID)
+	}
+	archived, err = ArchiveProducts(ctx, users["seller"], ordered, &models.AuditEntry{ActorID: &sellerID, Action: models.AuditProductsArchived})
+	if err != nil || len(archived) != 1 {
+		t.Fatalf("ArchiveProducts by its seller = %v, %v; want the ordered product archived", archived, err)
+	}
+}
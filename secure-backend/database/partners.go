@@ -0,0 +1,136 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"secure-backend/models"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const partnerKeyColumns = `id, name, key_prefix, fields, daily_quota, last_used_at, revoked_at, created_at, updated_at`
+
+// CreatePartnerAPIKey stores a new partner key by the hash of the key and records it in
+// the admin audit log in the same transaction
+func CreatePartnerAPIKey(ctx context.Context, key *models.PartnerAPIKey, keyHash string, audit *models.AuditEntry) (*models.PartnerAPIKey, error) {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var created models.PartnerAPIKey
+	err = tx.GetContext(ctx, &created, `
+		INSERT INTO partner_api_keys (name, key_hash, key_prefix, fields, daily_quota, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+partnerKeyColumns+`
+	`, key.Name, keyHash, key.KeyPrefix, key.Fields, key.DailyQuota, audit.ActorID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordAdminAudit(ctx, tx, audit); err != nil {
+		return nil, err
+	}
+
+	return &created, tx.Commit()
+}
+
+// GetPartnerAPIKeys lists all partner keys, newest first
+func GetPartnerAPIKeys(ctx context.Context) ([]models.PartnerAPIKey, error) {
+	keys := []models.PartnerAPIKey{}
+	err := DB.SelectContext(ctx, &keys, `SELECT `+partnerKeyColumns+` FROM partner_api_keys ORDER BY created_at DESC`)
+	return keys, err
+}
+
+// RevokePartnerAPIKey revokes a key so it stops working immediately, and records it in the
+// admin audit log. Returns sql.ErrNoRows if the key doesn't exist or is already revoked.
+func RevokePartnerAPIKey(ctx context.Context, id string, audit *models.AuditEntry) error {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE partner_api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL
+	`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	if err := recordAdminAudit(ctx, tx, audit); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetPartnerAPIKeyByHash returns the unrevoked partner key with the given hash
+// (sql.ErrNoRows if there is none)
+func GetPartnerAPIKeyByHash(ctx context.Context, keyHash string) (*models.PartnerAPIKey, error) {
+	var key models.PartnerAPIKey
+	err := DB.GetContext(ctx, &key, `
+		SELECT `+partnerKeyColumns+`
+		FROM partner_api_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL
+	`, keyHash)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// RecordPartnerUsage counts a catalog request against the key's usage for the UTC day and
+// returns the day's count so far
+func RecordPartnerUsage(ctx context.Context, keyID string, day time.Time) (int, error) {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var count int
+	err = tx.GetContext(ctx, &count, `
+		INSERT INTO partner_api_usage (key_id, day, count)
+		VALUES ($1, $2, 1)
+		ON CONFLICT (key_id, day) DO UPDATE SET count = partner_api_usage.count + 1
+		RETURNING count
+	`, keyID, usageDate(day))
+	if err != nil {
+		return 0, err
+	}
+
+	if err := touchPartnerKey(ctx, tx, keyID); err != nil {
+		return 0, err
+	}
+	return count, tx.Commit()
+}
+
+// touchPartnerKey records when a key was last used
+func touchPartnerKey(ctx context.Context, q sqlx.ExecerContext, keyID string) error {
+	_, err := q.ExecContext(ctx, `UPDATE partner_api_keys SET last_used_at = now() WHERE id = $1`, keyID)
+	return err
+}
+
+// GetPartnerCatalog returns up to limit products ordered by (updated_at, id), starting after
+// the cursor. Unpublished products are included so partners syncing deltas learn about
+// withdrawn listings.
+func GetPartnerCatalog(ctx context.Context, after models.CatalogCursor, limit int) ([]models.Product, error) {
+	products := []models.Product{}
+	err := DB.SelectContext(ctx, &products, `
+		SELECT `+productColumns+`
+		FROM products
+		WHERE (updated_at, id::text) > ($1, $2)
+		ORDER BY updated_at, id::text
+		LIMIT $3
+	`, after.UpdatedAt, after.ID, limit)
+	return products, err
+}
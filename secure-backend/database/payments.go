@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"secure-backend/models"
+
+	"github.com/lib/pq"
+)
+
+// CreatePayment stores a payment record, returning the existing record if the provider payment is already known
+func CreatePayment(ctx context.Context, payment *models.Payment) error {
+	return DB.QueryRowContext(ctx, `
+		INSERT INTO payments (order_id, provider, provider_payment_id, amount, currency, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (provider, provider_payment_id) DO UPDATE SET updated_at = now()
+		RETURNING id, status, created_at, updated_at
+	`, payment.OrderID, payment.Provider, payment.ProviderPaymentID, payment.Amount, payment.Currency, payment.Status).Scan(
+		&payment.ID, &payment.Status, &payment.CreatedAt, &payment.UpdatedAt,
+	)
+}
+
+// GetPaymentsByOrder returns every payment record for an order, newest first
+func GetPaymentsByOrder(ctx context.Context, orderID string) ([]models.Payment, error) {
+	payments := []models.Payment{}
+	err := DB.SelectContext(ctx, &payments, `
+		SELECT id, order_id, provider, provider_payment_id, amount, currency, status, fee, created_at, updated_at
+		FROM payments
+		WHERE order_id = $1
+		ORDER BY created_at DESC
+	`, orderID)
+	return payments, err
+}
+
+// GetPaymentByProviderID retrieves a payment by the provider's payment identifier
+func GetPaymentByProviderID(ctx context.Context, provider, providerPaymentID string) (*models.Payment, error) {
+	var payment models.Payment
+	err := DB.GetContext(ctx, &payment, `
+		SELECT id, order_id, provider, provider_payment_id, amount, currency, status, fee, created_at, updated_at
+		FROM payments
+		WHERE provider = $1 AND provider_payment_id = $2
+	`, provider, providerPaymentID)
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// UpdatePaymentStatus records the latest provider status of a payment
+func UpdatePaymentStatus(ctx context.Context, paymentID, status string) error {
+	_, err := DB.ExecContext(ctx, `UPDATE payments SET status = $1, updated_at = now() WHERE id = $2`, status, paymentID)
+	return err
+}
+
+// AdvancePaymentStatus records a new provider status of a payment unless the payment is already
+// in one of the settled statuses (setting the status it already has is allowed, so redelivered
+// events are applied again). Returns false if the payment was left as it was.
+func AdvancePaymentStatus(ctx context.Context, paymentID, status string, settled []string) (bool, error) {
+	result, err := DB.ExecContext(ctx, `
+		UPDATE payments SET status = $1, updated_at = now()
+		WHERE id = $2 AND (status = $1 OR NOT status = ANY($3))
+	`, status, paymentID, pq.Array(settled))
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
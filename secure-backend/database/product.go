@@ -1,14 +1,35 @@
 package database
 
 import (
+	"context"
+	"fmt"
 	"secure-backend/models"
+	"secure-backend/money"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
+// GetProductBySlug retrieves a single product by its slug
+func GetProductBySlug(ctx context.Context, slug string) (*models.Product, error) {
+	var product models.Product
+	err := DB.GetContext(ctx, &product, `
+		SELECT `+productColumns+`
+		FROM products
+		WHERE slug = $1
+	`, slug)
+	if err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
 // GetProductByID retrieves a single product by its ID
-func GetProductByID(id string) (*models.Product, error) {
+func GetProductByID(ctx context.Context, id string) (*models.Product, error) {
 	var product models.Product
-	err := DB.Get(&product, `
-		SELECT id, name, description, price, image, stock, status, seller_id, created_at, updated_at
+	err := DB.GetContext(ctx, &product, `
+		SELECT `+productColumns+`
 		FROM products 
 		WHERE id = $1
 	`, id)
@@ -18,34 +39,170 @@ func GetProductByID(id string) (*models.Product, error) {
 	return &product, nil
 }
 
-// UpdateProduct updates an existing product
-func UpdateProduct(product *models.Product) error {
-	_, err := DB.Exec(`
-		UPDATE products 
-		SET name = $1, description = $2, price = $3, image = $4, stock = $5, status = $6, updated_at = now()
-		WHERE id = $7 AND seller_id = $8
+// UpdateProduct updates an existing product and records price and stock changes in their history. Its tags are replaced unless product.Tags is nil,
+// its slug unless product.Slug is empty and its shelf location unless product.ShelfLocation is nil. It returns ErrUnknownCategory or ErrUnknownTag
+// for references to missing categories or tags, ErrSlugTaken if the new slug is in use and sql.ErrNoRows if the seller has no such product.
+func UpdateProduct(ctx context.Context, product *models.Product) error {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The seller check, the row lock and the update are one statement; the price and stock
+	// before the update come back from the locked row for the history and stock ledger
+	var old struct {
+		Price money.Amount `db:"price"`
+		Stock int          `db:"stock"`
+	}
+	err = tx.GetContext(ctx, &old, `
+		UPDATE products p
+		SET name = $1, description = $2, price = $3, image = $4, stock = $5, status = $6,
+			image_alt = $9, width_cm = $10, height_cm = $11, depth_cm = $12, weight_kg = $13,
+			category_id = $14, slug = COALESCE(NULLIF($15, ''), p.slug), meta_title = $16,
+			meta_description = $17, min_order_quantity = $18, max_order_quantity = $19,
+			shelf_location = COALESCE($20, p.shelf_location),
+			image_hash = CASE WHEN p.image IS DISTINCT FROM $4 THEN NULL ELSE p.image_hash END, updated_at = now()
+		FROM (SELECT id, price, stock FROM products WHERE id = $7 AND seller_id = $8 FOR UPDATE) old
+		WHERE p.id = old.id
+		RETURNING old.price, old.stock
 	`, product.Name, product.Description, product.Price,
-		product.Image, product.Stock, product.Status, product.ID, product.SellerID)
+		product.Image, product.Stock, product.Status, product.ID, product.SellerID,
+		product.ImageAlt, product.WidthCm, product.HeightCm, product.DepthCm, product.WeightKg,
+		product.CategoryID, product.Slug, product.MetaTitle, product.MetaDescription,
+		product.MinOrderQuantity, product.MaxOrderQuantity, product.ShelfLocation)
+	if hasErrorCode(err, foreignKeyViolation) {
+		return ErrUnknownCategory
+	} else if hasErrorCode(err, uniqueViolation) {
+		return ErrSlugTaken
+	} else if err != nil {
+		return err
+	}
+
+	if old.Price != product.Price {
+		if err := recordPriceChange(ctx, tx, product.ID, &old.Price, product.Price, product.SellerID); err != nil {
+			return err
+		}
+	}
+
+	if product.Stock != old.Stock {
+		err := recordStockMovement(ctx, tx, &models.StockMovement{
+			ProductID: product.ID, Quantity: product.Stock - old.Stock, Reason: models.MovementSellerUpdate, ActorID: &product.SellerID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if product.Tags != nil {
+		if err := setProductTags(ctx, tx, product.ID, product.Tags); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UpdateProductImage points a seller's product at a newly uploaded image with the given
+// content hash (used to find duplicate listings). It returns sql.ErrNoRows if the seller has
+// no such product.
+func UpdateProductImage(ctx context.Context, productID string, sellerID string, imageURL string, imageHash string) error {
+	return execOwned(ctx, DB, `
+		UPDATE products
+		SET image = $3, image_hash = $4, updated_at = now()
+		WHERE id = $1 AND seller_id = $2
+	`, productID, sellerID, imageURL, imageHash)
+}
+
+// recordPriceChange appends an entry to a product's price history
+func recordPriceChange(ctx context.Context, tx *sqlx.Tx, productID string, oldPrice *money.Amount, newPrice money.Amount, changedBy string) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO product_price_history (product_id, old_price, new_price, changed_by)
+		VALUES ($1, $2, $3, $4)
+	`, productID, oldPrice, newPrice, changedBy)
 	return err
 }
 
-// DeleteProduct deletes a product by ID and seller ID
-func DeleteProduct(productID string, sellerID string) (int64, error) {
-	result, err := DB.Exec(`
+// GetPriceHistory returns a product's price changes, oldest first
+func GetPriceHistory(ctx context.Context, productID string) ([]models.PriceChange, error) {
+	history := []models.PriceChange{}
+	err := DB.SelectContext(ctx, &history, `
+		SELECT id, product_id, old_price, new_price, changed_by, created_at
+		FROM product_price_history
+		WHERE product_id = $1
+		ORDER BY created_at ASC, id
+	`, productID)
+	return history, err
+}
+
+// DeleteProduct deletes one of a seller's products. It returns sql.ErrNoRows if the seller
+// has no such product.
+func DeleteProduct(ctx context.Context, productID string, sellerID string) error {
+	return execOwned(ctx, DB, `
 		DELETE FROM products 
 		WHERE id = $1 AND seller_id = $2
 	`, productID, sellerID)
-	if err != nil {
-		return 0, err
-	}
-	return result.RowsAffected()
 }
 
-// GetProductBySeller retrieves a product ensuring it belongs to the specified seller
-func GetProductBySeller(productID string, sellerID string) (*models.Product, error) {
+// ArchiveProducts archives the seller's products among ids in one statement and records the
+// change in the audit log. It returns the IDs that were archived; the others don't exist or
+// belong to another seller. Nothing is audited when no product matched.
+func ArchiveProducts(ctx context.Context, sellerID string, ids []string, audit *models.AuditEntry) ([]string, error) {
+	var archived []string
+	err := WithTx(ctx, func(tx *sqlx.Tx) error {
+		archived = nil
+		err := tx.SelectContext(ctx, &archived, `
+			UPDATE products
+			SET status = 'archived', updated_at = now()
+			WHERE id::text = ANY($1) AND seller_id = $2
+			RETURNING id
+		`, pq.Array(ids), sellerID)
+		if err != nil || len(archived) == 0 {
+			return err
+		}
+
+		audit.Detail = fmt.Sprintf("archived %d products: %s", len(archived), strings.Join(archived, ", "))
+		return recordAdminAudit(ctx, tx, audit)
+	})
+	return archived, err
+}
+
+// DeleteProducts deletes the seller's products among ids in one statement and records the
+// deletion in the audit log. Products that were ordered can't be deleted (order items keep
+// them) and are left alone; their IDs are returned as ordered so they can be archived
+// instead. IDs in neither list don't exist or belong to another seller.
+func DeleteProducts(ctx context.Context, sellerID string, ids []string, audit *models.AuditEntry) (deleted, ordered []string, err error) {
+	err = WithTx(ctx, func(tx *sqlx.Tx) error {
+		deleted, ordered = nil, nil
+		err := tx.SelectContext(ctx, &deleted, `
+			DELETE FROM products p
+			WHERE p.id::text = ANY($1) AND p.seller_id = $2
+				AND NOT EXISTS (SELECT 1 FROM order_items oi WHERE oi.product_id = p.id)
+			RETURNING p.id
+		`, pq.Array(ids), sellerID)
+		if err != nil {
+			return err
+		}
+
+		err = tx.SelectContext(ctx, &ordered, `
+			SELECT id FROM products WHERE id::text = ANY($1) AND seller_id = $2
+		`, pq.Array(ids), sellerID)
+		if err != nil || len(deleted) == 0 {
+			return err
+		}
+
+		audit.Detail = fmt.Sprintf("deleted %d products: %s", len(deleted), strings.Join(deleted, ", "))
+		return recordAdminAudit(ctx, tx, audit)
+	})
+	return deleted, ordered, err
+}
+
+// GetProductBySeller retrieves a product ensuring it belongs to the specified seller, with
+// the columns only shown to the seller
+func GetProductBySeller(ctx context.Context, productID string, sellerID string) (*models.Product, error) {
 	var product models.Product
-	err := DB.Get(&product, `
-		SELECT id, name, description, price, image, stock, status, seller_id, created_at, updated_at
+	err := DB.GetContext(ctx, &product, `
+		SELECT `+sellerProductColumns+`
 		FROM products 
 		WHERE id = $1 AND seller_id = $2
 	`, productID, sellerID)
@@ -54,3 +211,30 @@ func GetProductBySeller(productID string, sellerID string) (*models.Product, err
 	}
 	return &product, nil
 }
+
+// GetProductShelfLocation returns where the seller keeps a product
+func GetProductShelfLocation(ctx context.Context, productID string) (string, error) {
+	var location string
+	err := DB.GetContext(ctx, &location, `SELECT shelf_location FROM products WHERE id = $1`, productID)
+	return location, err
+}
+
+// GetProductsByIDs retrieves the products with the given IDs, keyed by ID. IDs that don't
+// match a product are left out.
+func GetProductsByIDs(ctx context.Context, ids []string) (map[string]*models.Product, error) {
+	var products []models.Product
+	err := DB.SelectContext(ctx, &products, `
+		SELECT `+productColumns+`
+		FROM products
+		WHERE id::text = ANY($1)
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*models.Product, len(products))
+	for i := range products {
+		byID[products[i].ID] = &products[i]
+	}
+	return byID, nil
+}
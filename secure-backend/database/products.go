@@ -1,38 +1,122 @@
 package database
 
 import (
+	"context"
+	"fmt"
 	"secure-backend/models"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
-// GetProductsBySeller returns all products for a specific seller
-func GetProductsBySeller(sellerID string) ([]models.Product, error) {
-	var products []models.Product
-	err := DB.Select(&products, "SELECT * FROM products WHERE seller_id = $1", sellerID)
-	return products, err
+// productColumns lists the product columns selected into models.Product
+const productColumns = `id, name, description, price, image, image_alt, stock, status, seller_id, category_id,
+	slug, meta_title, meta_description, width_cm, height_cm, depth_cm, weight_kg, created_at, updated_at,
+	min_order_quantity, max_order_quantity,
+	ARRAY(
+		SELECT t.slug FROM product_tags pt JOIN tags t ON t.id = pt.tag_id
+		WHERE pt.product_id = products.id ORDER BY t.slug
+	) AS tags`
+
+// sellerProductColumns adds the columns only shown to the product's seller
+const sellerProductColumns = productColumns + `, shelf_location`
+
+// ProductFilter narrows product listings by category and tag slugs; empty fields match everything
+type ProductFilter struct {
+	Category string
+	Tag      string
 }
 
-// GetAllProducts returns all products (admin only)
-func GetAllProducts() ([]models.Product, error) {
+// getProductPage returns a page of products matching scope and filter (newest first) and the
+// total match count, from the read replica when ctx prefers it
+func getProductPage(ctx context.Context, columns, scope string, args []interface{}, filter ProductFilter, limit, offset int) ([]models.Product, int, error) {
+	n := len(args)
+	where := fmt.Sprintf(`WHERE %s
+		AND ($%d = '' OR category_id = (SELECT id FROM categories WHERE slug = $%d))
+		AND ($%d = '' OR EXISTS (
+			SELECT 1 FROM product_tags pt JOIN tags t ON t.id = pt.tag_id
+			WHERE pt.product_id = products.id AND t.slug = $%d
+		))`, scope, n+1, n+1, n+2, n+2)
+	args = append(args, filter.Category, filter.Tag)
+
+	var total int
 	var products []models.Product
-	err := DB.Select(&products, "SELECT * FROM products")
-	return products, err
+	err := withReader(ctx, func(q sqlx.QueryerContext) error {
+		if err := sqlx.GetContext(ctx, q, &total, `SELECT COUNT(*) FROM products `+where, args...); err != nil {
+			return err
+		}
+
+		n := len(args)
+		products = []models.Product{}
+		return sqlx.SelectContext(ctx, q, &products, fmt.Sprintf(`
+			SELECT %s FROM products %s
+			ORDER BY created_at DESC, id
+			LIMIT $%d OFFSET $%d`, columns, where, n+1, n+2),
+			append(args, limit, offset)...)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return products, total, nil
 }
 
-// GetPublishedProducts returns all published products (for buyers)
-func GetPublishedProducts() ([]models.Product, error) {
-	var products []models.Product
-	err := DB.Select(&products, "SELECT * FROM products WHERE status = 'published'")
-	return products, err
+// GetProductsBySeller returns a page of a seller's products and their total count
+func GetProductsBySeller(ctx context.Context, sellerID string, filter ProductFilter, limit, offset int) ([]models.Product, int, error) {
+	return getProductPage(ctx, sellerProductColumns, "seller_id = $1", []interface{}{sellerID}, filter, limit, offset)
+}
+
+// GetAllProducts returns a page of all products and the total count (admin only)
+func GetAllProducts(ctx context.Context, filter ProductFilter, limit, offset int) ([]models.Product, int, error) {
+	return getProductPage(ctx, productColumns, "TRUE", nil, filter, limit, offset)
+}
+
+// GetPublishedProducts returns a page of published products and their total count (for buyers).
+// Products of sellers whose vacation hides their listings are left out.
+func GetPublishedProducts(ctx context.Context, filter ProductFilter, limit, offset int) ([]models.Product, int, error) {
+	scope := "status = 'published' AND seller_id NOT IN (" + hiddenSellers(1) + ")"
+	return getProductPage(ctx, productColumns, scope, []interface{}{clk.Now()}, filter, limit, offset)
+}
+
+// maxSlugAttempts bounds how often CreateProduct retries after losing a race for a slug
+const maxSlugAttempts = 3
+
+// CreateProduct creates a new product with its tags. product.Slug is the preferred slug;
+// a numeric suffix ("-2", "-3", ...) is appended when it is already taken. It returns
+// ErrUnknownCategory or ErrUnknownTag if the product refers to a category or tag that does not exist.
+func CreateProduct(ctx context.Context, product *models.Product) error {
+	base := product.Slug
+	for attempt := 1; ; attempt++ {
+		err := createProduct(ctx, product, base)
+		if hasErrorCode(err, uniqueViolation) && attempt < maxSlugAttempts {
+			// Another product took the slug between the lookup and the insert
+			continue
+		}
+		return err
+	}
 }
 
-// CreateProduct creates a new product
-func CreateProduct(product *models.Product) error {
+func createProduct(ctx context.Context, product *models.Product, baseSlug string) error {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	product.Slug, err = nextProductSlug(ctx, tx, baseSlug)
+	if err != nil {
+		return err
+	}
+
 	query := `
-		INSERT INTO products (name, description, price, image, stock, status, seller_id)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO products (name, description, price, image, stock, status, seller_id,
+			image_alt, width_cm, height_cm, depth_cm, weight_kg, category_id,
+			slug, meta_title, meta_description, min_order_quantity, max_order_quantity, shelf_location)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, COALESCE($19, ''))
 		RETURNING id, created_at, updated_at`
 
-	return DB.QueryRow(
+	err = tx.QueryRowContext(ctx,
 		query,
 		product.Name,
 		product.Description,
@@ -41,5 +125,122 @@ func CreateProduct(product *models.Product) error {
 		product.Stock,
 		product.Status,
 		product.SellerID,
+		product.ImageAlt,
+		product.WidthCm,
+		product.HeightCm,
+		product.DepthCm,
+		product.WeightKg,
+		product.CategoryID,
+		product.Slug,
+		product.MetaTitle,
+		product.MetaDescription,
+		product.MinOrderQuantity,
+		product.MaxOrderQuantity,
+		product.ShelfLocation,
 	).Scan(&product.ID, &product.CreatedAt, &product.UpdatedAt)
+	if hasErrorCode(err, foreignKeyViolation) {
+		return ErrUnknownCategory
+	} else if err != nil {
+		return err
+	}
+
+	if err := recordPriceChange(ctx, tx, product.ID, nil, product.Price, product.SellerID); err != nil {
+		return err
+	}
+
+	if product.Stock != 0 {
+		err := recordStockMovement(ctx, tx, &models.StockMovement{
+			ProductID: product.ID, Quantity: product.Stock, Reason: models.MovementOpening, ActorID: &product.SellerID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if product.Tags == nil {
+		product.Tags = pq.StringArray{}
+	}
+	if err := setProductTags(ctx, tx, product.ID, product.Tags); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// nextProductSlug returns base, or base with the lowest free numeric suffix if base is taken
+func nextProductSlug(ctx context.Context, tx *sqlx.Tx, base string) (string, error) {
+	var taken []string
+	err := tx.SelectContext(ctx, &taken, `SELECT slug FROM products WHERE slug = $1 OR slug LIKE $1 || '-%'`, base)
+	if err != nil {
+		return "", err
+	}
+
+	used := make(map[string]bool, len(taken))
+	for _, slug := range taken {
+		used[slug] = true
+	}
+	slug := base
+	for n := 2; used[slug]; n++ {
+		slug = fmt.Sprintf("%s-%d", base, n)
+	}
+	return slug, nil
+}
+
+// GetWatchedProductsSince returns products the user cares about (in their cart or previous orders)
+// that changed after the given time
+func GetWatchedProductsSince(ctx context.Context, userID string, since time.Time) ([]models.Product, error) {
+	var products []models.Product
+	err := DB.SelectContext(ctx, &products, `
+		SELECT `+productColumns+`
+		FROM products
+		WHERE updated_at > $2 AND id IN (
+			SELECT product_id FROM cart_items WHERE user_id = $1
+			UNION
+			SELECT oi.product_id FROM order_items oi JOIN orders o ON oi.order_id = o.id WHERE o.buyer_id = $1
+		)
+		ORDER BY updated_at ASC
+	`, userID, since)
+	return products, err
+}
+
+// SearchProducts runs a full-text search (web search syntax: quoted phrases, OR, -exclusions)
+// over product names and descriptions and returns a page of results, best match first,
+// and the total match count. sellerID limits results to a seller; publishedOnly to published
+// products whose listings aren't hidden by their seller's vacation. Reads from the replica
+// when ctx prefers it.
+func SearchProducts(ctx context.Context, query, sellerID string, publishedOnly bool, limit, offset int) ([]models.ProductSearchResult, int, error) {
+	where := `search_vector @@ q.query
+		AND ($2 = '' OR seller_id::text = $2)
+		AND (NOT $3 OR (status = 'published' AND seller_id NOT IN (` + hiddenSellers(4) + `)))`
+	args := []interface{}{query, sellerID, publishedOnly, clk.Now()}
+
+	var total int
+	var results []models.ProductSearchResult
+	err := withReader(ctx, func(q sqlx.QueryerContext) error {
+		err := sqlx.GetContext(ctx, q, &total, `
+			SELECT COUNT(*)
+			FROM products, websearch_to_tsquery('english', $1) AS q(query)
+			WHERE `+where, args...)
+		if err != nil {
+			return err
+		}
+
+		results = []models.ProductSearchResult{}
+		return sqlx.SelectContext(ctx, q, &results, `
+			SELECT `+productColumns+`,
+				ts_rank_cd(search_vector, q.query) AS rank,
+				ts_headline('english', name, q.query, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true') AS name_highlight,
+				ts_headline('english', COALESCE(description, ''), q.query,
+					'StartSel=<mark>, StopSel=</mark>, MinWords=15, MaxWords=35, MaxFragments=2') AS snippet
+			FROM products, websearch_to_tsquery('english', $1) AS q(query)
+			WHERE `+where+`
+			ORDER BY rank DESC, created_at DESC, id
+			LIMIT $5 OFFSET $6
+		`, append(args, limit, offset)...)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return results, total, nil
 }
@@ -0,0 +1,71 @@
+//go:build e2e
+
+// Product listing tests against a real PostgreSQL database (with schema.sql applied):
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run TestGetProductsBySellerPages ./database
+package database
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGetProductsBySellerPages(t *testing.T) {
+	sellerID := createTestUsers(t, "pages", "seller")[0]
+	ctx := context.Background()
+
+	// One statement gives every product the same created_at, so only the ID orders them
+	const products = 5
+	if _, err := DB.ExecContext(ctx, `
+		INSERT INTO products (name, price, stock, status, seller_id)
+		SELECT 'Paged product ' || n, 5, 1, 'published', $1 FROM generate_series(1, $2) n
+	`, sellerID, products); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[string]bool{}
+	for _, page := range []struct{ offset, want int }{{0, 2}, {2, 2}, {4, 1}} {
+		list, total, err := GetProductsBySeller(ctx, sellerID, ProductFilter{}, 2, page.offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != products {
+			t.Errorf("offset %d: total = %d, want %d", page.offset, total, products)
+		}
+		if len(list) != page.want {
+			t.Fatalf("offset %d: got %d products, want %d", page.offset, len(list), page.want)
+		}
+		for _, product := range list {
+			if seen[product.ID] {
+				t.Errorf("offset %d: product %s was already on an earlier page", page.offset, product.ID)
+			}
+			seen[product.ID] = true
+		}
+	}
+	if len(seen) != products {
+		t.Errorf("paging saw %d products, want %d", len(seen), products)
+	}
+
+	// Past the last product: an empty page that still reports the total
+	for _, offset := range []int{products, products + 100} {
+		list, total, err := GetProductsBySeller(ctx, sellerID, ProductFilter{}, 2, offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 0 || total != products {
+			t.Errorf("offset %d: got %d products and total %d, want none and %d", offset, len(list), total, products)
+		}
+		if list == nil {
+			t.Errorf("offset %d: empty page is nil, want an empty list", offset)
+		}
+	}
+
+	// A page as large as the listing returns all of it
+	list, _, err := GetProductsBySeller(ctx, sellerID, ProductFilter{}, products, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != products {
+		t.Errorf("got %d products on a page of %d, want all of them", len(list), products)
+	}
+}
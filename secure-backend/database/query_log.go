@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"secure-backend/logging"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// defaultSlowQueryThreshold is the duration from which statements are logged as slow unless
+// DB_SLOW_QUERY_THRESHOLD says otherwise
+const defaultSlowQueryThreshold = 200 * time.Millisecond
+
+// slowQueryThreshold is read once by InitDB; 0 turns slow query logging off
+var slowQueryThreshold = defaultSlowQueryThreshold
+
+// SlowQueryThreshold returns the duration from which statements are logged as slow,
+// configurable via DB_SLOW_QUERY_THRESHOLD (e.g. "500ms", "0" to turn logging off)
+func SlowQueryThreshold() time.Duration {
+	if value := os.Getenv("DB_SLOW_QUERY_THRESHOLD"); value != "" {
+		if value == "0" {
+			return 0
+		}
+		if threshold, err := time.ParseDuration(value); err == nil && threshold >= 0 {
+			return threshold
+		}
+		log.Printf("Invalid DB_SLOW_QUERY_THRESHOLD %q, using %s", value, defaultSlowQueryThreshold)
+	}
+	return defaultSlowQueryThreshold
+}
+
+// QueryStats summarizes the statements run since startup
+type QueryStats struct {
+	Queries       uint64  `json:"queries"`
+	SlowQueries   uint64  `json:"slow_queries"`
+	Errors        uint64  `json:"errors"`
+	AvgDurationMs float64 `json:"avg_duration_ms"`
+	MaxDurationMs float64 `json:"max_duration_ms"`
+}
+
+var queryCounters struct {
+	queries, slow, errors atomic.Uint64
+	total, max            atomic.Int64 // nanoseconds
+}
+
+// GetQueryStats returns statement counts and durations since startup
+func GetQueryStats() QueryStats {
+	stats := QueryStats{
+		Queries:       queryCounters.queries.Load(),
+		SlowQueries:   queryCounters.slow.Load(),
+		Errors:        queryCounters.errors.Load(),
+		MaxDurationMs: float64(queryCounters.max.Load()) / float64(time.Millisecond),
+	}
+	if stats.Queries > 0 {
+		stats.AvgDurationMs = float64(queryCounters.total.Load()) / float64(time.Millisecond) / float64(stats.Queries)
+	}
+	return stats
+}
+
+// observeQuery records the duration of a statement and logs it at warn level when it took at
+// least slowQueryThreshold. The statement is logged with its placeholders and only the number
+// of arguments, never their values, which may hold personal data or secrets. Queries are timed
+// until their first rows arrive, not while the caller reads them.
+func observeQuery(ctx context.Context, query string, args int, elapsed time.Duration, err error) {
+	queryCounters.queries.Add(1)
+	queryCounters.total.Add(int64(elapsed))
+	for {
+		max := queryCounters.max.Load()
+		if int64(elapsed) <= max || queryCounters.max.CompareAndSwap(max, int64(elapsed)) {
+			break
+		}
+	}
+	if err != nil {
+		queryCounters.errors.Add(1)
+	}
+
+	threshold := slowQueryThreshold
+	if threshold <= 0 || elapsed < threshold {
+		return
+	}
+	queryCounters.slow.Add(1)
+	attrs := []slog.Attr{
+		slog.String("operation", queryOperation(query)),
+		slog.String("statement", queryStatement(query)),
+		slog.Int("args", args),
+		slog.Float64("duration_ms", float64(elapsed.Microseconds())/1000),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	logging.FromContext(ctx).LogAttrs(ctx, slog.LevelWarn, "slow query", attrs...)
+}
+
+// queryOperation returns the first keyword of a statement ("SELECT")
+func queryOperation(query string) string {
+	if fields := strings.Fields(query); len(fields) > 0 {
+		return strings.ToUpper(fields[0])
+	}
+	return "QUERY"
+}
+
+// queryStatement returns a statement on one line, cut at maxTracedStatement
+func queryStatement(query string) string {
+	statement := strings.Join(strings.Fields(query), " ")
+	if len(statement) > maxTracedStatement {
+		statement = statement[:maxTracedStatement] + "..."
+	}
+	return statement
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"secure-backend/logging"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlowQueryThreshold(t *testing.T) {
+	t.Setenv("DB_SLOW_QUERY_THRESHOLD", "")
+	assert.Equal(t, defaultSlowQueryThreshold, SlowQueryThreshold())
+	t.Setenv("DB_SLOW_QUERY_THRESHOLD", "1s")
+	assert.Equal(t, time.Second, SlowQueryThreshold())
+	t.Setenv("DB_SLOW_QUERY_THRESHOLD", "0")
+	assert.Equal(t, time.Duration(0), SlowQueryThreshold())
+	t.Setenv("DB_SLOW_QUERY_THRESHOLD", "soon")
+	assert.Equal(t, defaultSlowQueryThreshold, SlowQueryThreshold())
+}
+
+func TestObserveQueryLogsSlowStatements(t *testing.T) {
+	threshold := slowQueryThreshold
+	slowQueryThreshold = 50 * time.Millisecond
+	t.Cleanup(func() { slowQueryThreshold = threshold })
+
+	var buf bytes.Buffer
+	ctx := logging.WithLogger(context.Background(), logging.New(&buf, true, slog.LevelInfo).With("request_id", "req-1"))
+	before := GetQueryStats()
+
+	observeQuery(ctx, "SELECT id FROM users WHERE email = $1", 1, 10*time.Millisecond, nil)
+	assert.Empty(t, buf.String())
+
+	observeQuery(ctx, "UPDATE users\n\t\tSET email = $2\n\t\tWHERE id = $1", 2, 120*time.Millisecond, errors.New("deadlock detected"))
+	line := buf.String()
+	assert.Contains(t, line, `"msg":"slow query"`)
+	assert.Contains(t, line, `"level":"WARN"`)
+	assert.Contains(t, line, `"request_id":"req-1"`)
+	assert.Contains(t, line, `"operation":"UPDATE"`)
+	assert.Contains(t, line, `"statement":"UPDATE users SET email = $2 WHERE id = $1"`)
+	assert.Contains(t, line, `"args":2`)
+	assert.Contains(t, line, `"duration_ms":120`)
+	assert.Contains(t, line, `"error":"deadlock detected"`)
+
+	after := GetQueryStats()
+	assert.Equal(t, before.Queries+2, after.Queries)
+	assert.Equal(t, before.SlowQueries+1, after.SlowQueries)
+	assert.Equal(t, before.Errors+1, after.Errors)
+	assert.GreaterOrEqual(t, after.MaxDurationMs, 120.0)
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"context"
+	"secure-backend/models"
+	"time"
+)
+
+// usageDate formats a period start for the api_usage DATE column
+func usageDate(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+// RecordAPIUsage counts one request against the user's day and month starting at the given
+// dates and returns the updated counts along with the user's plan
+func RecordAPIUsage(ctx context.Context, userID string, day, month time.Time) (*models.APIUsage, error) {
+	var usage models.APIUsage
+	err := DB.GetContext(ctx, &usage, `
+		WITH counted AS (
+			INSERT INTO api_usage (user_id, period, period_start, count)
+			VALUES ($1, 'day', $2, 1), ($1, 'month', $3, 1)
+			ON CONFLICT (user_id, period, period_start) DO UPDATE SET count = api_usage.count + 1
+			RETURNING period, count
+		)
+		SELECT u.plan,
+			COALESCE((SELECT count FROM counted WHERE period = 'day'), 0) AS daily,
+			COALESCE((SELECT count FROM counted WHERE period = 'month'), 0) AS monthly
+		FROM users u
+		WHERE u.id = $1
+	`, userID, usageDate(day), usageDate(month))
+	if err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+// GetAPIUsage returns the user's plan and request counts without counting a request
+func GetAPIUsage(ctx context.Context, userID string, day, month time.Time) (*models.APIUsage, error) {
+	var usage models.APIUsage
+	err := DB.GetContext(ctx, &usage, `
+		SELECT u.plan,
+			COALESCE((SELECT count FROM api_usage
+				WHERE user_id = u.id AND period = 'day' AND period_start = $2), 0) AS daily,
+			COALESCE((SELECT count FROM api_usage
+				WHERE user_id = u.id AND period = 'month' AND period_start = $3), 0) AS monthly
+		FROM users u
+		WHERE u.id = $1
+	`, userID, usageDate(day), usageDate(month))
+	if err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
@@ -0,0 +1,105 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"secure-backend/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrUnknownProduct is returned when a recommendation names a product that doesn't exist
+var ErrUnknownProduct = errors.New("recommended product does not exist")
+
+// GetProductRecommendations returns the recommendations of the given products in display
+// order. With publishedOnly, recommended products that aren't published are left out.
+func GetProductRecommendations(ctx context.Context, productIDs []string, publishedOnly bool) ([]models.ProductRecommendation, error) {
+	recommendations := []models.ProductRecommendation{}
+	err := DB.SelectContext(ctx, &recommendations, `
+		SELECT r.product_id, r.recommended_product_id, r.kind, r.label, r.position,
+			p.name, p.slug, p.price, p.image, p.stock, p.status
+		FROM product_recommendations r
+		JOIN products p ON p.id = r.recommended_product_id
+		WHERE r.product_id = ANY($1) AND (NOT $2 OR p.status = 'published')
+		ORDER BY r.product_id, r.position, r.created_at
+	`, pq.Array(productIDs), publishedOnly)
+	return recommendations, err
+}
+
+// SetProductRecommendations replaces a product's recommendations; their order sets the position
+func SetProductRecommendations(ctx context.Context, productID string, recommendations []models.ProductRecommendation) error {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM product_recommendations WHERE product_id = $1`, productID); err != nil {
+		return err
+	}
+
+	for i, r := range recommendations {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO product_recommendations (product_id, recommended_product_id, kind, label, position)
+			VALUES ($1, $2, $3, $4, $5)
+		`, productID, r.RecommendedProductID, r.Kind, r.Label, i)
+		if hasErrorCode(err, foreignKeyViolation) {
+			return ErrUnknownProduct
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// RecordRecommendationEvent stores a click or attach for a recommendation. Events for
+// product pairs that aren't configured as a recommendation are ignored; the returned
+// bool reports whether the event was stored. Events of users who haven't consented to
+// analytics are stored without the user, so they only count towards the totals.
+func RecordRecommendationEvent(ctx context.Context, event *models.RecommendationEvent) (bool, error) {
+	result, err := DB.ExecContext(ctx, `
+		INSERT INTO recommendation_events (product_id, recommended_product_id, user_id, action, placement)
+		SELECT $1, $2, CASE WHEN `+consentSQL("analytics", "$3::uuid")+` THEN $3::uuid END, $4, $5
+		WHERE EXISTS (
+			SELECT 1 FROM product_recommendations WHERE product_id = $1 AND recommended_product_id = $2
+		)
+	`, event.ProductID, event.RecommendedProductID, event.UserID, event.Action, event.Placement)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// GetRecommendationStats counts clicks and attaches per recommendation within a time range.
+// A non-empty sellerID limits the report to recommendations on that seller's products.
+func GetRecommendationStats(ctx context.Context, sellerID string, from, to time.Time) ([]models.RecommendationStats, error) {
+	stats := []models.RecommendationStats{}
+	err := DB.SelectContext(ctx, &stats, `
+		SELECT e.product_id, p.name AS product_name, e.recommended_product_id, rp.name AS recommended_name,
+			COUNT(*) FILTER (WHERE e.action = 'click') AS clicks,
+			COUNT(*) FILTER (WHERE e.action = 'attach') AS attaches
+		FROM recommendation_events e
+		JOIN products p ON p.id = e.product_id
+		JOIN products rp ON rp.id = e.recommended_product_id
+		WHERE e.created_at >= $1 AND e.created_at < $2 AND ($3 = '' OR p.seller_id::text = $3)
+		GROUP BY e.product_id, p.name, e.recommended_product_id, rp.name
+		ORDER BY attaches DESC, clicks DESC
+	`, from, to, sellerID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range stats {
+		if stats[i].Clicks > 0 {
+			stats[i].ConversionRate = float64(stats[i].Attaches) / float64(stats[i].Clicks)
+		}
+	}
+	return stats, nil
+}
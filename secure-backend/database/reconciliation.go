@@ -0,0 +1,241 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"secure-backend/models"
+	"secure-backend/money"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const payoutReconciliationColumns = `id, provider, provider_payout_id, amount, currency, arrival_date, status,
+	transaction_count, checked_at, resolved_by, COALESCE(resolution_note, '') AS resolution_note, resolved_at,
+	created_at, updated_at`
+
+// ErrReconciliationNotBlocked is returned when resolving a payout that isn't blocked
+var ErrReconciliationNotBlocked = errors.New("payout is not blocked")
+
+// GetPaymentsByProviderIDs returns the payments with the given provider IDs, keyed by provider ID
+func GetPaymentsByProviderIDs(ctx context.Context, provider string, providerPaymentIDs []string) (map[string]models.Payment, error) {
+	var payments []models.Payment
+	err := DB.SelectContext(ctx, &payments, `
+		SELECT id, order_id, provider, provider_payment_id, amount, currency, status, fee, created_at, updated_at
+		FROM payments
+		WHERE provider = $1 AND provider_payment_id = ANY($2)
+	`, provider, pq.Array(providerPaymentIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]models.Payment, len(payments))
+	for _, payment := range payments {
+		byID[payment.ProviderPaymentID] = payment
+	}
+	return byID, nil
+}
+
+// GetRefundAllocationsByProviderIDs returns the refund allocations issued with the given provider
+// refund IDs, keyed by provider refund ID
+func GetRefundAllocationsByProviderIDs(ctx context.Context, providerRefundIDs []string) (map[string]models.RefundAllocation, error) {
+	var allocations []models.RefundAllocation
+	err := DB.SelectContext(ctx, &allocations, `
+		SELECT a.refund_id, p.order_id, a.payment_id, p.provider, p.provider_payment_id, a.amount, a.provider_refund_id
+		FROM refund_allocations a
+		JOIN payments p ON a.payment_id = p.id
+		WHERE a.provider_refund_id = ANY($1)
+	`, pq.Array(providerRefundIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]models.RefundAllocation, len(allocations))
+	for _, allocation := range allocations {
+		byID[allocation.ProviderRefundID] = allocation
+	}
+	return byID, nil
+}
+
+// GetPayoutReconciliationStatus returns the status of the latest check of a provider payout,
+// or sql.ErrNoRows if it was never checked
+func GetPayoutReconciliationStatus(ctx context.Context, provider, providerPayoutID string) (string, error) {
+	var status string
+	err := DB.GetContext(ctx, &status, `
+		SELECT status FROM payout_reconciliations WHERE provider = $1 AND provider_payout_id = $2
+	`, provider, providerPayoutID)
+	return status, err
+}
+
+// RecordPayoutReconciliation stores the result of checking a provider payout, replacing the
+// mismatches of the previous check, and fills in the stored record. While the payout is blocked
+// the seller payouts of orderIDs (the orders it paid out) are held; once it reconciles the holds
+// are released. The provider fees of the payout's charges are recorded on their payments (fees
+// maps payment IDs to fees). Payouts an admin resolved keep that status. blocked reports whether
+// this check blocked a payout that wasn't blocked before.
+func RecordPayoutReconciliation(ctx context.Context, rec *models.PayoutReconciliation, mismatches []models.ReconciliationMismatch, orderIDs []string, fees map[string]money.Amount) (blocked bool, err error) {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var previous string
+	err = tx.GetContext(ctx, &previous, `
+		SELECT status FROM payout_reconciliations WHERE provider = $1 AND provider_payout_id = $2 FOR UPDATE
+	`, rec.Provider, rec.ProviderPayoutID)
+	if err != nil && err != sql.ErrNoRows {
+		return false, err
+	}
+	if previous == models.ReconciliationResolved {
+		return false, nil
+	}
+
+	err = tx.GetContext(ctx, rec, `
+		INSERT INTO payout_reconciliations (provider, provider_payout_id, amount, currency, arrival_date, status,
+			transaction_count, checked_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (provider, provider_payout_id) DO UPDATE SET
+			amount = EXCLUDED.amount,
+			arrival_date = EXCLUDED.arrival_date,
+			status = EXCLUDED.status,
+			transaction_count = EXCLUDED.transaction_count,
+			checked_at = EXCLUDED.checked_at
+		RETURNING `+payoutReconciliationColumns,
+		rec.Provider, rec.ProviderPayoutID, rec.Amount, rec.Currency, rec.ArrivalDate, rec.Status,
+		rec.TransactionCount, rec.CheckedAt)
+	if err != nil {
+		return false, err
+	}
+
+	for paymentID, fee := range fees {
+		if _, err := tx.ExecContext(ctx, `UPDATE payments SET fee = $2, updated_at = now() WHERE id = $1`, paymentID, fee); err != nil {
+			return false, err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM reconciliation_mismatches WHERE reconciliation_id = $1`, rec.ID); err != nil {
+		return false, err
+	}
+	for i := range mismatches {
+		mismatch := &mismatches[i]
+		mismatch.ReconciliationID = rec.ID
+		err := tx.GetContext(ctx, &mismatch.ID, `
+			INSERT INTO reconciliation_mismatches (reconciliation_id, balance_transaction_id, kind, order_id, expected, actual, detail)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id
+		`, rec.ID, mismatch.BalanceTransactionID, mismatch.Kind, mismatch.OrderID, mismatch.Expected, mismatch.Actual, mismatch.Detail)
+		if err != nil {
+			return false, err
+		}
+	}
+	rec.Mismatches = mismatches
+
+	if rec.Status == models.ReconciliationBlocked {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO payout_holds (order_id, reconciliation_id, reason)
+			SELECT o.id, $1, $3 FROM orders o WHERE o.id = ANY($2)
+			ON CONFLICT (reconciliation_id, order_id) WHERE reconciliation_id IS NOT NULL DO NOTHING
+		`, rec.ID, pq.Array(orderIDs), "payout "+rec.ProviderPayoutID+" does not reconcile")
+	} else {
+		err = releaseReconciliationHolds(ctx, tx, rec.ID)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return rec.Status == models.ReconciliationBlocked && previous != models.ReconciliationBlocked, tx.Commit()
+}
+
+// releaseReconciliationHolds lifts the payout holds placed for a reconciliation
+func releaseReconciliationHolds(ctx context.Context, q sqlx.ExecerContext, reconciliationID string) error {
+	_, err := q.ExecContext(ctx, `
+		UPDATE payout_holds SET released_at = now()
+		WHERE reconciliation_id = $1 AND released_at IS NULL
+	`, reconciliationID)
+	return err
+}
+
+// GetPayoutReconciliations returns a page of checked payouts with the given status, newest
+// first, and the total count
+func GetPayoutReconciliations(ctx context.Context, status string, limit, offset int) ([]models.PayoutReconciliation, int, error) {
+	var total int
+	err := DB.GetContext(ctx, &total, `SELECT COUNT(*) FROM payout_reconciliations WHERE status = $1`, status)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	recs := []models.PayoutReconciliation{}
+	err = DB.SelectContext(ctx, &recs, `
+		SELECT `+payoutReconciliationColumns+`
+		FROM payout_reconciliations
+		WHERE status = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return recs, total, nil
+}
+
+// GetPayoutReconciliation returns a checked payout with the mismatches of its latest check
+func GetPayoutReconciliation(ctx context.Context, id string) (*models.PayoutReconciliation, error) {
+	var rec models.PayoutReconciliation
+	err := DB.GetContext(ctx, &rec, `SELECT `+payoutReconciliationColumns+` FROM payout_reconciliations WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rec.Mismatches = []models.ReconciliationMismatch{}
+	err = DB.SelectContext(ctx, &rec.Mismatches, `
+		SELECT id, reconciliation_id, balance_transaction_id, kind, order_id, expected, actual, detail
+		FROM reconciliation_mismatches
+		WHERE reconciliation_id = $1
+		ORDER BY created_at, balance_transaction_id
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// ResolvePayoutReconciliation releases a blocked payout after an admin reviewed its mismatches,
+// lifting the payout holds placed for it, and records the decision in the admin audit log in
+// the same transaction. Returns sql.ErrNoRows if the payout doesn't exist and
+// ErrReconciliationNotBlocked if it isn't blocked.
+func ResolvePayoutReconciliation(ctx context.Context, id, note string, audit *models.AuditEntry) (*models.PayoutReconciliation, error) {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var status string
+	if err := tx.GetContext(ctx, &status, `SELECT status FROM payout_reconciliations WHERE id = $1 FOR UPDATE`, id); err != nil {
+		return nil, err
+	}
+	if status != models.ReconciliationBlocked {
+		return nil, ErrReconciliationNotBlocked
+	}
+
+	var rec models.PayoutReconciliation
+	err = tx.GetContext(ctx, &rec, `
+		UPDATE payout_reconciliations
+		SET status = $2, resolution_note = $3, resolved_by = $4, resolved_at = now()
+		WHERE id = $1
+		RETURNING `+payoutReconciliationColumns,
+		id, models.ReconciliationResolved, note, audit.ActorID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := releaseReconciliationHolds(ctx, tx, id); err != nil {
+		return nil, err
+	}
+	if err := recordAdminAudit(ctx, tx, audit); err != nil {
+		return nil, err
+	}
+	return &rec, tx.Commit()
+}
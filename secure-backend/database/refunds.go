@@ -0,0 +1,437 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"secure-backend/models"
+	"secure-backend/money"
+
+	"github.com/lib/pq"
+)
+
+// Refund statuses
+const (
+	RefundPending   = "pending"
+	RefundSucceeded = "succeeded"
+	RefundFailed    = "failed"
+)
+
+var (
+	// ErrNothingToRefund is returned when a refund would cover no items and no amount
+	ErrNothingToRefund = errors.New("nothing left to refund")
+	// ErrRefundExceedsPaid is returned when a refund would return more than was paid
+	ErrRefundExceedsPaid = errors.New("refund exceeds the amount paid")
+	// ErrInvalidRefundItem is returned for unknown, foreign or over-refunded order items
+	ErrInvalidRefundItem = errors.New("invalid refund item")
+	// ErrNoCapturedPayment is returned when the order has no payment that collected money
+	ErrNoCapturedPayment = errors.New("order has no captured payment")
+)
+
+// RefundItemRequest asks to refund a quantity of an order item
+type RefundItemRequest struct {
+	OrderItemID string
+	Quantity    int
+}
+
+// RefundRequest holds the data needed to record a refund.
+// With Items, the amount is the items' price; without Items, Amount is refunded
+// as a plain partial refund, or everything still refundable if Amount is zero.
+type RefundRequest struct {
+	OrderID   string
+	Items     []RefundItemRequest
+	Amount    money.Amount
+	Reason    string
+	Restock   bool
+	SellerID  string // when set, only items of this seller's products can be refunded
+	ActorID   string
+	ActorRole string
+}
+
+// refundablePayment is a payment of an order that collected money, with what was already refunded through it
+type refundablePayment struct {
+	ID                string       `db:"id"`
+	Provider          string       `db:"provider"`
+	ProviderPaymentID string       `db:"provider_payment_id"`
+	Currency          string       `db:"currency"`
+	Amount            money.Amount `db:"amount"`
+	Refunded          money.Amount `db:"refunded"`
+}
+
+// CreateRefund validates a refund against the order and what was already refunded and records
+// it as pending. Pending refunds count as refunded so concurrent requests can't over-refund.
+// The refund is split across the order's payments in proportion to what each has left to
+// refund (see models.AllocateRefund), with store credit first and the card last.
+func CreateRefund(ctx context.Context, req RefundRequest) (*models.Refund, error) {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Lock the order so refunds for it are validated one at a time
+	var orderTotal money.Amount
+	err = tx.GetContext(ctx, &orderTotal, `SELECT total_amount FROM orders WHERE id = $1 FOR UPDATE`, req.OrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	var alreadyRefunded money.Amount
+	err = tx.GetContext(ctx, &alreadyRefunded, `
+		SELECT COALESCE(SUM(amount), 0) FROM refunds
+		WHERE order_id = $1 AND status <> $2
+	`, req.OrderID, RefundFailed)
+	if err != nil {
+		return nil, err
+	}
+	remaining := orderTotal - alreadyRefunded
+
+	var payments []refundablePayment
+	err = tx.SelectContext(ctx, &payments, `
+		SELECT p.id, p.provider, p.provider_payment_id, p.currency, p.amount,
+			COALESCE((
+				SELECT SUM(a.amount) FROM refund_allocations a
+				JOIN refunds r ON a.refund_id = r.id
+				WHERE a.payment_id = p.id AND r.status <> $3
+			), 0) AS refunded
+		FROM payments p
+		WHERE p.order_id = $1 AND p.status IN ('succeeded', 'partially_refunded', 'refunded')
+		ORDER BY p.provider = $2 DESC, p.created_at
+	`, req.OrderID, models.PaymentProviderStoreCredit, RefundFailed)
+	if err != nil {
+		return nil, err
+	}
+	if len(payments) == 0 {
+		return nil, ErrNoCapturedPayment
+	}
+	paymentRemaining := make([]money.Amount, len(payments))
+	var refundable money.Amount
+	for i, payment := range payments {
+		paymentRemaining[i] = payment.Amount - payment.Refunded
+		refundable += paymentRemaining[i]
+	}
+	if refundable < remaining {
+		remaining = refundable
+	}
+
+	var orderItems []struct {
+		ID               string       `db:"id"`
+		ProductID        string       `db:"product_id"`
+		SellerID         string       `db:"seller_id"`
+		Quantity         int          `db:"quantity"`
+		UnitPrice        money.Amount `db:"unit_price"`
+		RefundedQuantity int          `db:"refunded_quantity"`
+	}
+	err = tx.SelectContext(ctx, &orderItems, `
+		SELECT oi.id, oi.product_id, p.seller_id, oi.quantity, oi.unit_price,
+			COALESCE((
+				SELECT SUM(ri.quantity) FROM refund_items ri
+				JOIN refunds r ON ri.refund_id = r.id
+				WHERE ri.order_item_id = oi.id AND r.status <> $2
+			), 0) AS refunded_quantity
+		FROM order_items oi
+		JOIN products p ON oi.product_id = p.id
+		WHERE oi.order_id = $1
+		ORDER BY oi.created_at
+	`, req.OrderID, RefundFailed)
+	if err != nil {
+		return nil, err
+	}
+
+	// The refund references the card payment when there is one, as before split payments
+	primary := payments[len(payments)-1]
+	refund := &models.Refund{
+		OrderID:     req.OrderID,
+		PaymentID:   primary.ID,
+		Currency:    primary.Currency,
+		Reason:      req.Reason,
+		Restock:     req.Restock,
+		Status:      RefundPending,
+		ActorID:     req.ActorID,
+		ActorRole:   req.ActorRole,
+		Items:       []models.RefundItem{},
+		Allocations: []models.RefundAllocation{},
+	}
+
+	var amount money.Amount
+	switch {
+	case len(req.Items) > 0:
+		seen := make(map[string]bool)
+		for _, requested := range req.Items {
+			if seen[requested.OrderItemID] {
+				return nil, fmt.Errorf("%w: order item %s listed twice", ErrInvalidRefundItem, requested.OrderItemID)
+			}
+			seen[requested.OrderItemID] = true
+
+			found := false
+			for _, item := range orderItems {
+				if item.ID != requested.OrderItemID {
+					continue
+				}
+				found = true
+				if req.SellerID != "" && item.SellerID != req.SellerID {
+					return nil, fmt.Errorf("%w: order item %s is not yours", ErrInvalidRefundItem, item.ID)
+				}
+				if requested.Quantity < 1 || requested.Quantity > item.Quantity-item.RefundedQuantity {
+					return nil, fmt.Errorf("%w: only %d of order item %s can be refunded",
+						ErrInvalidRefundItem, item.Quantity-item.RefundedQuantity, item.ID)
+				}
+				itemAmount := item.UnitPrice.Times(requested.Quantity)
+				amount += itemAmount
+				refund.Items = append(refund.Items, models.RefundItem{
+					OrderItemID: item.ID,
+					ProductID:   item.ProductID,
+					Quantity:    requested.Quantity,
+					Amount:      itemAmount,
+				})
+			}
+			if !found {
+				return nil, fmt.Errorf("%w: order item %s is not part of this order", ErrInvalidRefundItem, requested.OrderItemID)
+			}
+		}
+
+	case req.Amount > 0:
+		amount = req.Amount
+
+	default:
+		// Full refund of whatever is left, returning every unrefunded item
+		amount = remaining
+		for _, item := range orderItems {
+			if left := item.Quantity - item.RefundedQuantity; left > 0 {
+				refund.Items = append(refund.Items, models.RefundItem{
+					OrderItemID: item.ID,
+					ProductID:   item.ProductID,
+					Quantity:    left,
+					Amount:      item.UnitPrice.Times(left),
+				})
+			}
+		}
+	}
+
+	if amount <= 0 {
+		return nil, ErrNothingToRefund
+	}
+	if amount > remaining {
+		return nil, fmt.Errorf("%w: %s requested, %s refundable", ErrRefundExceedsPaid, amount, remaining)
+	}
+	refund.Amount = amount
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO refunds (id, order_id, payment_id, amount, currency, reason, restock, status, actor_id, actor_role)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9, $10)
+		RETURNING id, created_at, updated_at
+	`, ids.NewID(), refund.OrderID, refund.PaymentID, refund.Amount, refund.Currency, refund.Reason, refund.Restock,
+		refund.Status, refund.ActorID, refund.ActorRole).Scan(&refund.ID, &refund.CreatedAt, &refund.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range refund.Items {
+		item := &refund.Items[i]
+		item.RefundID = refund.ID
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO refund_items (id, refund_id, order_item_id, product_id, quantity, amount)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id
+		`, ids.NewID(), item.RefundID, item.OrderItemID, item.ProductID, item.Quantity, item.Amount).Scan(&item.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i, share := range models.AllocateRefund(amount, paymentRemaining) {
+		if share == 0 {
+			continue
+		}
+		allocation := models.RefundAllocation{
+			RefundID:          refund.ID,
+			PaymentID:         payments[i].ID,
+			Provider:          payments[i].Provider,
+			ProviderPaymentID: payments[i].ProviderPaymentID,
+			Amount:            share,
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO refund_allocations (refund_id, payment_id, amount) VALUES ($1, $2, $3)
+		`, allocation.RefundID, allocation.PaymentID, allocation.Amount)
+		if err != nil {
+			return nil, err
+		}
+		refund.Allocations = append(refund.Allocations, allocation)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// CompleteRefund marks a refund as accepted by the provider, credits the store credit share
+// of it back to the buyer, updates the refunded payments' statuses and, if requested, puts the
+// refunded quantities back in stock. Allocations must carry the provider refund IDs of the card
+// shares. It reports whether the order is now fully refunded, and returns sql.ErrNoRows if the
+// refund is no longer pending.
+func CompleteRefund(ctx context.Context, refund *models.Refund) (bool, error) {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE refunds SET status = $2, provider_refund_id = NULLIF($3, ''), updated_at = now()
+		WHERE id = $1 AND status = $4
+	`, refund.ID, RefundSucceeded, refund.ProviderRefundID, RefundPending)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rowsAffected == 0 {
+		return false, sql.ErrNoRows
+	}
+
+	for _, allocation := range refund.Allocations {
+		if allocation.Provider == models.PaymentProviderStoreCredit {
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO store_credit_entries (user_id, amount, reason, order_id, refund_id)
+				SELECT buyer_id, $3, $4, id, $2 FROM orders WHERE id = $1
+			`, refund.OrderID, refund.ID, allocation.Amount, models.StoreCreditRefund)
+		} else {
+			_, err = tx.ExecContext(ctx, `
+				UPDATE refund_allocations SET provider_refund_id = NULLIF($3, '')
+				WHERE refund_id = $1 AND payment_id = $2
+			`, refund.ID, allocation.PaymentID, allocation.ProviderRefundID)
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+
+	// Each refunded payment is refunded in full once its succeeded allocations reach its amount
+	_, err = tx.ExecContext(ctx, `
+		UPDATE payments p
+		SET status = CASE WHEN t.refunded >= p.amount THEN 'refunded' ELSE 'partially_refunded' END,
+			updated_at = now()
+		FROM (
+			SELECT a.payment_id, SUM(a.amount) AS refunded
+			FROM refund_allocations a
+			JOIN refunds r ON a.refund_id = r.id
+			WHERE r.status = $2 AND a.payment_id IN (SELECT payment_id FROM refund_allocations WHERE refund_id = $1)
+			GROUP BY a.payment_id
+		) t
+		WHERE p.id = t.payment_id
+	`, refund.ID, RefundSucceeded)
+	if err != nil {
+		return false, err
+	}
+
+	if refund.Restock {
+		_, err = tx.ExecContext(ctx, `
+			WITH restocked AS (
+				UPDATE products p SET stock = p.stock + ri.quantity, updated_at = now()
+				FROM refund_items ri
+				WHERE ri.refund_id = $1 AND p.id = ri.product_id
+			)
+			INSERT INTO stock_movements (product_id, quantity, reason, order_id)
+			SELECT product_id, quantity, 'restock', $2
+			FROM refund_items
+			WHERE refund_id = $1
+		`, refund.ID, refund.OrderID)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	var fullyRefunded bool
+	err = tx.GetContext(ctx, &fullyRefunded, `
+		SELECT COALESCE(SUM(r.amount), 0) >= o.total_amount
+		FROM orders o
+		LEFT JOIN refunds r ON r.order_id = o.id AND r.status = $2
+		WHERE o.id = $1
+		GROUP BY o.total_amount
+	`, refund.OrderID, RefundSucceeded)
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	refund.Status = RefundSucceeded
+	return fullyRefunded, nil
+}
+
+// FailRefund marks a pending refund as failed so its amount becomes refundable again
+func FailRefund(ctx context.Context, refundID string) error {
+	_, err := DB.ExecContext(ctx, `
+		UPDATE refunds SET status = $2, updated_at = now()
+		WHERE id = $1 AND status = $3
+	`, refundID, RefundFailed, RefundPending)
+	return err
+}
+
+// GetRefundsByOrder returns the refunds of an order with their items, oldest first
+func GetRefundsByOrder(ctx context.Context, orderID string) ([]models.Refund, error) {
+	refunds := []models.Refund{}
+	err := DB.SelectContext(ctx, &refunds, `
+		SELECT id, order_id, payment_id, amount, currency, COALESCE(reason, '') AS reason, restock, status,
+			COALESCE(provider_refund_id, '') AS provider_refund_id, actor_id, actor_role, created_at, updated_at
+		FROM refunds
+		WHERE order_id = $1
+		ORDER BY created_at ASC
+	`, orderID)
+	if err != nil || len(refunds) == 0 {
+		return refunds, err
+	}
+
+	ids := make([]string, len(refunds))
+	for i, refund := range refunds {
+		ids[i] = refund.ID
+		refunds[i].Items = []models.RefundItem{}
+		refunds[i].Allocations = []models.RefundAllocation{}
+	}
+
+	var items []models.RefundItem
+	err = DB.SelectContext(ctx, &items, `
+		SELECT id, refund_id, order_item_id, product_id, quantity, amount
+		FROM refund_items
+		WHERE refund_id = ANY($1)
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		for i := range refunds {
+			if refunds[i].ID == item.RefundID {
+				refunds[i].Items = append(refunds[i].Items, item)
+			}
+		}
+	}
+
+	var allocations []models.RefundAllocation
+	err = DB.SelectContext(ctx, &allocations, `
+		SELECT a.refund_id, a.payment_id, p.provider, p.provider_payment_id, a.amount,
+			COALESCE(a.provider_refund_id, '') AS provider_refund_id
+		FROM refund_allocations a
+		JOIN payments p ON a.payment_id = p.id
+		WHERE a.refund_id = ANY($1)
+		ORDER BY p.provider = $2 DESC, p.created_at
+	`, pq.Array(ids), models.PaymentProviderStoreCredit)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, allocation := range allocations {
+		for i := range refunds {
+			if refunds[i].ID == allocation.RefundID {
+				refunds[i].Allocations = append(refunds[i].Allocations, allocation)
+			}
+		}
+	}
+	return refunds, nil
+}
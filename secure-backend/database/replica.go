@@ -0,0 +1,236 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// defaultReplicaMaxLag is how far behind the primary the replica may fall before reads go
+// back to the primary, unless DATABASE_REPLICA_MAX_LAG says otherwise
+const defaultReplicaMaxLag = 5 * time.Second
+
+// replicaCheckTimeout bounds a replica health check
+const replicaCheckTimeout = 2 * time.Second
+
+// replica is the read replica pool, nil without DATABASE_REPLICA_URL. It serves the reads of
+// requests that prefer it while it is up; everything else uses DB.
+var replica *sqlx.DB
+
+var replicaState struct {
+	up        atomic.Bool
+	lag       atomic.Uint64 // float64 bits, seconds
+	fallbacks atomic.Uint64 // reads retried on the primary after the replica failed them
+	checkedAt atomic.Int64  // unix nanoseconds of the last health check
+}
+
+// ReplicaMaxLag returns how far the replica may lag behind the primary and still serve
+// reads, configurable via DATABASE_REPLICA_MAX_LAG (e.g. "2s"). Requests of users who wrote
+// within this window read from the primary so they see their own changes.
+func ReplicaMaxLag() time.Duration {
+	if value := os.Getenv("DATABASE_REPLICA_MAX_LAG"); value != "" {
+		if lag, err := time.ParseDuration(value); err == nil && lag > 0 {
+			return lag
+		}
+		log.Printf("Invalid DATABASE_REPLICA_MAX_LAG %q, using %s", value, defaultReplicaMaxLag)
+	}
+	return defaultReplicaMaxLag
+}
+
+// initReplica opens the read replica pool when DATABASE_REPLICA_URL is set. A replica that
+// can't be reached doesn't stop startup: reads use the primary until a health check finds it up.
+func initReplica(cfg *Config) error {
+	connStr := os.Getenv("DATABASE_REPLICA_URL")
+	if connStr == "" {
+		return nil
+	}
+
+	db, err := openDB(connStr, cfg)
+	if err != nil {
+		return fmt.Errorf("read replica: %w", err)
+	}
+	replica = db
+	log.Printf("Using read replica %s", sanitizeConnString(connStr))
+
+	checkReplica(context.Background(), ReplicaMaxLag())
+	return nil
+}
+
+// StartReplicaMonitor checks the read replica every interval until ctx is cancelled, taking
+// it out of use while it is unreachable or lags more than ReplicaMaxLag and back once it has
+// caught up. It does nothing without a replica.
+func StartReplicaMonitor(ctx context.Context, interval time.Duration) {
+	if replica == nil {
+		return
+	}
+
+	maxLag := ReplicaMaxLag()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				checkReplica(ctx, maxLag)
+			}
+		}
+	}()
+}
+
+// checkReplica measures the replica's replication lag and marks it up or down. A replica
+// that has replayed everything it received counts as caught up, since the replay timestamp
+// only moves when the primary commits.
+func checkReplica(ctx context.Context, maxLag time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, replicaCheckTimeout)
+	defer cancel()
+
+	var lag float64
+	err := replica.GetContext(ctx, &lag, `
+		SELECT CASE
+			WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+			ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+		END::float8
+	`)
+	replicaState.checkedAt.Store(clk.Now().UnixNano())
+	switch {
+	case err != nil:
+		setReplicaUp(false, fmt.Sprintf("unreachable: %v", err))
+	case lag > maxLag.Seconds():
+		replicaState.lag.Store(math.Float64bits(lag))
+		setReplicaUp(false, fmt.Sprintf("%.1fs behind the primary", lag))
+	default:
+		replicaState.lag.Store(math.Float64bits(lag))
+		setReplicaUp(true, "caught up")
+	}
+}
+
+// setReplicaUp records whether the replica serves reads, logging changes
+func setReplicaUp(up bool, reason string) {
+	if replicaState.up.Swap(up) == up {
+		return
+	}
+	if up {
+		log.Printf("Read replica in use (%s)", reason)
+	} else {
+		log.Printf("Read replica out of use, reading from the primary (%s)", reason)
+	}
+}
+
+type replicaChoiceKey struct{}
+
+// replicaChoice is the pool a request's reads use. Once a read has gone to the primary the
+// rest follow it: a later read from a lagging replica could miss changes an earlier read saw.
+type replicaChoice struct {
+	primary atomic.Bool
+}
+
+// PreferReplica marks the reads made with the returned context as able to use the read
+// replica, if it is up. Only reads that tolerate ReplicaMaxLag of staleness should be made
+// with it.
+func PreferReplica(ctx context.Context) context.Context {
+	if replica == nil || !replicaState.up.Load() {
+		return ctx
+	}
+	return context.WithValue(ctx, replicaChoiceKey{}, &replicaChoice{})
+}
+
+// UsePrimary undoes PreferReplica for reads that feed a write
+func UsePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replicaChoiceKey{}, (*replicaChoice)(nil))
+}
+
+// reader returns the pool reads with ctx should use: the replica while ctx prefers it and it
+// is up, the primary otherwise
+func reader(ctx context.Context) *sqlx.DB {
+	choice, _ := ctx.Value(replicaChoiceKey{}).(*replicaChoice)
+	if choice == nil || choice.primary.Load() {
+		return DB
+	}
+	if !replicaState.up.Load() {
+		choice.primary.Store(true)
+		return DB
+	}
+	return replica
+}
+
+// withReader runs read-only queries on reader(ctx). When the replica fails to answer, it is
+// taken out of use and fn runs again on the primary, so fn must reset any results it
+// collects when it starts.
+func withReader(ctx context.Context, fn func(q sqlx.QueryerContext) error) error {
+	q := reader(ctx)
+	err := fn(q)
+	if q == DB || !replicaUnavailable(ctx, err) {
+		return err
+	}
+
+	replicaState.fallbacks.Add(1)
+	setReplicaUp(false, err.Error())
+	if choice, _ := ctx.Value(replicaChoiceKey{}).(*replicaChoice); choice != nil {
+		choice.primary.Store(true)
+	}
+	return fn(DB)
+}
+
+// replicaUnavailable reports whether err means the replica couldn't serve the query, as
+// opposed to the query itself failing: the connection failed, the server is shutting down
+// or starting, or the query was cancelled by a conflict with replication. Errors of a
+// cancelled ctx are the caller's and aren't retried.
+func replicaUnavailable(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr) {
+		return true
+	}
+	switch code := sqlState(err); code {
+	case serializationFailure, adminShutdown, crashShutdown, cannotConnectNow:
+		return true
+	default:
+		return strings.HasPrefix(code, connectionExceptionClass)
+	}
+}
+
+// ReplicaStatus describes the read replica, for monitoring
+type ReplicaStatus struct {
+	Configured bool       `json:"configured"`
+	Up         bool       `json:"up"`                   // serving reads
+	LagSeconds float64    `json:"lag_seconds"`          // replication lag at the last check
+	Fallbacks  uint64     `json:"fallbacks"`            // reads retried on the primary since startup
+	CheckedAt  *time.Time `json:"checked_at,omitempty"` // last health check
+	Pool       PoolStats  `json:"database_pool"`
+}
+
+// GetReplicaStatus returns the state of the read replica
+func GetReplicaStatus() ReplicaStatus {
+	if replica == nil {
+		return ReplicaStatus{}
+	}
+
+	status := ReplicaStatus{
+		Configured: true,
+		Up:         replicaState.up.Load(),
+		LagSeconds: math.Float64frombits(replicaState.lag.Load()),
+		Fallbacks:  replicaState.fallbacks.Load(),
+		Pool:       poolStats(replica),
+	}
+	if checked := replicaState.checkedAt.Load(); checked != 0 {
+		at := time.Unix(0, checked).UTC()
+		status.CheckedAt = &at
+	}
+	return status
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplicaUnavailable(t *testing.T) {
+	ctx := context.Background()
+	assert.True(t, replicaUnavailable(ctx, driver.ErrBadConn))
+	assert.True(t, replicaUnavailable(ctx, fmt.Errorf("select: %w", io.ErrUnexpectedEOF)))
+	assert.True(t, replicaUnavailable(ctx, &pq.Error{Code: cannotConnectNow}))
+	assert.True(t, replicaUnavailable(ctx, &pq.Error{Code: "08006"})) // connection_failure
+	assert.True(t, replicaUnavailable(ctx, &pgxError{Code: serializationFailure}))
+
+	assert.False(t, replicaUnavailable(ctx, nil))
+	assert.False(t, replicaUnavailable(ctx, &pq.Error{Code: uniqueViolation}))
+	assert.False(t, replicaUnavailable(ctx, errors.New("syntax error")))
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.False(t, replicaUnavailable(cancelled, driver.ErrBadConn))
+}
+
+func TestReaderWithoutReplicaUsesPrimary(t *testing.T) {
+	ctx := PreferReplica(context.Background())
+	assert.Nil(t, ctx.Value(replicaChoiceKey{}))
+	assert.Same(t, DB, reader(ctx))
+	assert.False(t, GetReplicaStatus().Configured)
+}
+
+func TestWithReaderFallsBackToPrimary(t *testing.T) {
+	primary, down := &sqlx.DB{}, &sqlx.DB{}
+	defer func(db, r *sqlx.DB) { DB, replica = db, r }(DB, replica)
+	DB, replica = primary, down
+	replicaState.up.Store(true)
+	defer replicaState.up.Store(false)
+
+	ctx := PreferReplica(context.Background())
+	var used []sqlx.QueryerContext
+	err := withReader(ctx, func(q sqlx.QueryerContext) error {
+		used = append(used, q)
+		if q == sqlx.QueryerContext(down) {
+			return driver.ErrBadConn
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []sqlx.QueryerContext{down, primary}, used)
+	assert.False(t, replicaState.up.Load())
+	assert.Same(t, primary, reader(ctx)) // the rest of the request stays on the primary
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"secure-backend/models"
+	"time"
+)
+
+// RecordRequestAudit appends an entry to the request audit log
+func RecordRequestAudit(ctx context.Context, entry *models.RequestAudit) error {
+	return DB.QueryRowContext(ctx, `
+		INSERT INTO request_audit_log (request_id, user_id, method, route, path, status,
+			request_body, response_body, ip_address, duration_ms)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at
+	`, entry.RequestID, entry.UserID, entry.Method, entry.Route, entry.Path, entry.Status,
+		entry.RequestBody, entry.ResponseBody, entry.IPAddress, entry.DurationMs,
+	).Scan(&entry.ID, &entry.CreatedAt)
+}
+
+// GetRequestAudits returns a page of request audit entries recorded in [from, to) (newest
+// first) and the total count. An empty route or user ID matches every one.
+func GetRequestAudits(ctx context.Context, route, userID string, from, to time.Time, limit, offset int) ([]models.RequestAudit, int, error) {
+	const filter = `
+		WHERE ($1 = '' OR route = $1) AND ($2 = '' OR user_id::text = $2)
+			AND created_at >= $3 AND created_at < $4`
+
+	var total int
+	err := DB.GetContext(ctx, &total, `SELECT COUNT(*) FROM request_audit_log`+filter, route, userID, from, to)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	entries := []models.RequestAudit{}
+	err = DB.SelectContext(ctx, &entries, `
+		SELECT id, request_id, user_id, method, route, path, status, request_body, response_body,
+			ip_address, duration_ms, created_at
+		FROM request_audit_log`+filter+`
+		ORDER BY created_at DESC
+		LIMIT $5 OFFSET $6
+	`, route, userID, from, to, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return entries, total, nil
+}
+
+// DeleteRequestAuditsBefore removes request audit entries recorded before cutoff and returns
+// how many
+func DeleteRequestAuditsBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := DB.ExecContext(ctx, `DELETE FROM request_audit_log WHERE created_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,270 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"secure-backend/models"
+	"secure-backend/money"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Stock reservation statuses
+const (
+	ReservationActive    = "active"
+	ReservationCommitted = "committed"
+	ReservationReleased  = "released"
+)
+
+// ErrCartEmpty is returned when checking out an empty cart
+var ErrCartEmpty = errors.New("cart is empty")
+
+// StockError is returned when a cart item cannot be reserved
+type StockError struct {
+	ProductID string
+	Name      string
+	Requested int
+	Available int
+	Reason    string // "unavailable" or "insufficient_stock"
+}
+
+// Error implements the error interface
+func (e *StockError) Error() string {
+	if e.Reason == "unavailable" {
+		return fmt.Sprintf("%s is no longer available", e.Name)
+	}
+	return fmt.Sprintf("insufficient stock for %s: requested %d, available %d", e.Name, e.Requested, e.Available)
+}
+
+// CheckoutRequest holds the data needed to turn a cart into a pending order
+type CheckoutRequest struct {
+	BuyerID         string
+	ShippingAddress string
+	ClientPlatform  string
+	ReservationTTL  time.Duration
+	TaxRate         float64 // included in prices
+	TaxJurisdiction string
+}
+
+// CreateOrderFromCart converts the buyer's cart into a pending order in a single transaction:
+// product rows are locked, stock is decremented and held in stock_reservations until
+// expires_at, order items are inserted with their tax lines and the cart is cleared. Purchase limits
+// and sellers' minimum order values are enforced with a *models.OrderRuleError.
+func CreateOrderFromCart(ctx context.Context, req CheckoutRequest) (*models.Order, []models.StockReservation, error) {
+	var order models.Order
+	var reservations []models.StockReservation
+	err := WithTx(ctx, func(tx *sqlx.Tx) error {
+		// Lock the products in a stable order so concurrent checkouts can't deadlock or oversell
+		var lines []struct {
+			ProductID        string       `db:"product_id"`
+			Quantity         int          `db:"quantity"`
+			Name             string       `db:"name"`
+			Price            money.Amount `db:"price"`
+			Stock            int          `db:"stock"`
+			Status           string       `db:"status"`
+			SellerID         string       `db:"seller_id"`
+			MinOrderQuantity int          `db:"min_order_quantity"`
+			MaxOrderQuantity *int         `db:"max_order_quantity"`
+			MinOrderValue    money.Amount `db:"min_order_value"`
+			models.SellerVacation
+		}
+		err := tx.SelectContext(ctx, &lines, `
+			SELECT ci.product_id, ci.quantity, p.name, p.price, p.stock, p.status,
+				p.seller_id, p.min_order_quantity, p.max_order_quantity, s.min_order_value,
+				s.vacation_starts_at, s.vacation_ends_at, s.vacation_message, s.vacation_hide_listings
+			FROM cart_items ci
+			JOIN products p ON ci.product_id = p.id
+			JOIN users s ON p.seller_id = s.id
+			WHERE ci.user_id = $1
+			ORDER BY p.id
+			FOR UPDATE OF p
+		`, req.BuyerID)
+		if err != nil {
+			return err
+		}
+		if len(lines) == 0 {
+			return ErrCartEmpty
+		}
+
+		now := clk.Now()
+		var total money.Amount
+		sellerSubtotals := map[string]money.Amount{}
+		for _, line := range lines {
+			if line.Status != "published" {
+				return &StockError{ProductID: line.ProductID, Name: line.Name, Requested: line.Quantity, Reason: "unavailable"}
+			}
+			if line.Stock < line.Quantity {
+				return &StockError{
+					ProductID: line.ProductID, Name: line.Name,
+					Requested: line.Quantity, Available: line.Stock, Reason: "insufficient_stock",
+				}
+			}
+			product := models.Product{
+				ID: line.ProductID, Name: line.Name,
+				MinOrderQuantity: line.MinOrderQuantity, MaxOrderQuantity: line.MaxOrderQuantity,
+			}
+			if err := product.CheckOrderQuantity(line.Quantity); err != nil {
+				return err
+			}
+			if err := line.SellerVacation.CheckOrders(line.SellerID, line.ProductID, now); err != nil {
+				return err
+			}
+			total += line.Price.Times(line.Quantity)
+			sellerSubtotals[line.SellerID] += line.Price.Times(line.Quantity)
+		}
+
+		// Sellers' minimum order values apply to the subtotal of their products in the order
+		for _, line := range lines {
+			if err := models.CheckSellerOrderValue(line.SellerID, sellerSubtotals[line.SellerID], line.MinOrderValue); err != nil {
+				return err
+			}
+		}
+
+		err = tx.GetContext(ctx, &order, `
+			INSERT INTO orders (id, buyer_id, status, total_amount, shipping_address, client_platform)
+			VALUES ($1, $2, 'pending', $3, NULLIF($4, ''), $5)
+			RETURNING id, buyer_id, status, total_amount, COALESCE(shipping_address, '') AS shipping_address,
+				client_platform, created_at, updated_at
+		`, ids.NewID(), req.BuyerID, total, req.ShippingAddress, req.ClientPlatform)
+		if err != nil {
+			return err
+		}
+
+		expiresAt := now.Add(req.ReservationTTL)
+		reservations = make([]models.StockReservation, 0, len(lines))
+		for _, line := range lines {
+			if _, err := tx.ExecContext(ctx, `UPDATE products SET stock = stock - $1 WHERE id = $2`, line.Quantity, line.ProductID); err != nil {
+				return err
+			}
+			if err := recordStockMovement(ctx, tx, &models.StockMovement{
+				ProductID: line.ProductID, Quantity: -line.Quantity, Reason: models.MovementReservation, OrderID: &order.ID,
+			}); err != nil {
+				return err
+			}
+
+			itemID := ids.NewID()
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO order_items (id, order_id, product_id, quantity, unit_price, total_price)
+				VALUES ($1, $2, $3, $4, $5, $6)
+			`, itemID, order.ID, line.ProductID, line.Quantity, line.Price, line.Price.Times(line.Quantity)); err != nil {
+				return err
+			}
+
+			taxLine := models.TaxLine{
+				OrderID: order.ID, OrderItemID: itemID, SellerID: line.SellerID,
+				Jurisdiction: req.TaxJurisdiction, Rate: req.TaxRate, GrossAmount: line.Price.Times(line.Quantity),
+			}
+			taxLine.TaxableAmount, taxLine.TaxAmount = models.SplitIncludedTax(taxLine.GrossAmount, taxLine.Rate)
+			if err := recordTaxLine(ctx, tx, &taxLine); err != nil {
+				return err
+			}
+
+			var reservation models.StockReservation
+			err := tx.GetContext(ctx, &reservation, `
+				INSERT INTO stock_reservations (id, order_id, product_id, quantity, status, expires_at)
+				VALUES ($1, $2, $3, $4, 'active', $5)
+				RETURNING id, order_id, product_id, quantity, status, expires_at, created_at, updated_at
+			`, ids.NewID(), order.ID, line.ProductID, line.Quantity, expiresAt)
+			if err != nil {
+				return err
+			}
+			reservations = append(reservations, reservation)
+		}
+
+		return clearCart(ctx, tx, req.BuyerID)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &order, reservations, nil
+}
+
+// GetOrderReservations returns the stock reservations held for an order
+func GetOrderReservations(ctx context.Context, orderID string) ([]models.StockReservation, error) {
+	reservations := []models.StockReservation{}
+	err := DB.SelectContext(ctx, &reservations, `
+		SELECT id, order_id, product_id, quantity, status, expires_at, created_at, updated_at
+		FROM stock_reservations
+		WHERE order_id = $1
+		ORDER BY created_at
+	`, orderID)
+	return reservations, err
+}
+
+// CommitReservations marks an order's active reservations as committed (the stock was sold)
+func CommitReservations(ctx context.Context, q sqlx.ExecerContext, orderID string) error {
+	_, err := q.ExecContext(ctx, `
+		UPDATE stock_reservations SET status = 'committed', updated_at = now()
+		WHERE order_id = $1 AND status = 'active'
+	`, orderID)
+	return err
+}
+
+// releaseReservations returns an order's reserved stock when it is cancelled. Committed
+// reservations are released too: a paid order can only be cancelled before it ships.
+func releaseReservations(ctx context.Context, q sqlx.ExecerContext, orderID string) error {
+	_, err := q.ExecContext(ctx, `
+		WITH released AS (
+			UPDATE stock_reservations SET status = 'released', updated_at = now()
+			WHERE order_id = $1 AND status IN ('active', 'committed')
+			RETURNING product_id, quantity
+		), restocked AS (
+			UPDATE products p SET stock = p.stock + r.quantity
+			FROM (SELECT product_id, SUM(quantity) AS quantity FROM released GROUP BY product_id) r
+			WHERE p.id = r.product_id
+		)
+		INSERT INTO stock_movements (product_id, quantity, reason, order_id)
+		SELECT product_id, SUM(quantity), 'release', $1
+		FROM released
+		GROUP BY product_id
+	`, orderID)
+	return err
+}
+
+// GetExpiredReservationOrders returns pending orders whose stock reservations have expired
+func GetExpiredReservationOrders(ctx context.Context, now time.Time, limit int) ([]string, error) {
+	var orderIDs []string
+	err := DB.SelectContext(ctx, &orderIDs, `
+		SELECT DISTINCT r.order_id
+		FROM stock_reservations r
+		JOIN orders o ON r.order_id = o.id
+		WHERE r.status = 'active' AND r.expires_at <= $1 AND o.status = 'pending'
+		LIMIT $2
+	`, now, limit)
+	return orderIDs, err
+}
+
+// ExpireCheckout cancels a still-pending order whose reservation expired and returns its stock,
+// recording the cancellation in the order timeline. It returns sql.ErrNoRows if the order is
+// no longer pending (for example because it was paid in the meantime).
+func ExpireCheckout(ctx context.Context, orderID string) error {
+	return WithTx(ctx, func(tx *sqlx.Tx) error {
+		result, err := tx.ExecContext(ctx, `
+			UPDATE orders SET status = 'cancelled', updated_at = now()
+			WHERE id = $1 AND status = 'pending'
+		`, orderID)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return sql.ErrNoRows
+		}
+
+		if err := releaseReservations(ctx, tx, orderID); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO order_status_history (order_id, from_status, to_status, actor_role, note)
+			VALUES ($1, 'pending', 'cancelled', 'system', 'Checkout expired before payment')
+		`, orderID)
+		return err
+	})
+}
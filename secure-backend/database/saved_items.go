@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+	"secure-backend/models"
+)
+
+// GetSavedItems retrieves the user's saved-for-later items with product details
+func GetSavedItems(ctx context.Context, userID string) ([]models.SavedItemWithProduct, error) {
+	var items []models.SavedItemWithProduct
+
+	rows, err := DB.QueryContext(ctx, `
+		SELECT
+			si.id, si.user_id, si.product_id, si.quantity, si.created_at,
+			p.id, p.name, p.description, p.price, p.image, p.stock, p.status, p.seller_id, p.created_at, p.updated_at
+		FROM saved_items si
+		JOIN products p ON si.product_id = p.id
+		WHERE si.user_id = $1
+		ORDER BY si.created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.SavedItemWithProduct
+		err := rows.Scan(
+			&item.ID, &item.UserID, &item.ProductID, &item.Quantity, &item.CreatedAt,
+			&item.Product.ID, &item.Product.Name, &item.Product.Description, &item.Product.Price,
+			&item.Product.Image, &item.Product.Stock, &item.Product.Status, &item.Product.SellerID,
+			&item.Product.CreatedAt, &item.Product.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// GetSavedItem retrieves one of the user's saved items
+func GetSavedItem(ctx context.Context, savedItemID, userID string) (*models.SavedItem, error) {
+	var item models.SavedItem
+	err := DB.GetContext(ctx, &item, `
+		SELECT id, user_id, product_id, quantity, created_at
+		FROM saved_items
+		WHERE id = $1 AND user_id = $2
+	`, savedItemID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// SaveCartItemForLater moves a cart item to the user's saved items, recording a tombstone
+// so delta sync removes it from other devices. Saving a product that is already saved
+// adds to its saved quantity. Returns sql.ErrNoRows if the cart item doesn't exist.
+func SaveCartItemForLater(ctx context.Context, cartItemID, userID string) (*models.SavedItem, error) {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	version, err := nextCartVersion(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed models.CartItem
+	err = tx.GetContext(ctx, &removed, `
+		DELETE FROM cart_items
+		WHERE id = $1 AND user_id = $2
+		RETURNING id, user_id, product_id, quantity, version, added_version, added_price, created_at, updated_at
+	`, cartItemID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO cart_item_tombstones (user_id, cart_item_id, product_id, version)
+		VALUES ($1, $2, $3, $4)
+	`, userID, removed.ID, removed.ProductID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	var saved models.SavedItem
+	err = tx.GetContext(ctx, &saved, `
+		INSERT INTO saved_items (user_id, product_id, quantity)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, product_id) DO UPDATE
+		SET quantity = LEAST(saved_items.quantity + EXCLUDED.quantity, $4)
+		RETURNING id, user_id, product_id, quantity, created_at
+	`, userID, removed.ProductID, removed.Quantity, maxCartItemQuantity)
+	if err != nil {
+		return nil, err
+	}
+
+	return &saved, tx.Commit()
+}
+
+// MoveSavedItemToCart moves a saved item back into the user's active cart, adding to the
+// quantity if the product is already in the cart. Returns sql.ErrNoRows if the saved item
+// doesn't exist.
+func MoveSavedItemToCart(ctx context.Context, savedItemID, userID string) (*models.CartItem, error) {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var saved models.SavedItem
+	err = tx.GetContext(ctx, &saved, `
+		DELETE FROM saved_items
+		WHERE id = $1 AND user_id = $2
+		RETURNING id, user_id, product_id, quantity, created_at
+	`, savedItemID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := nextCartVersion(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var item models.CartItem
+	err = tx.GetContext(ctx, &item, `
+		INSERT INTO cart_items (user_id, product_id, quantity, version, added_version, added_price)
+		VALUES ($1, $2, $3, $4, $4, (SELECT price FROM products WHERE id = $2))
+		ON CONFLICT (user_id, product_id) DO UPDATE
+		SET quantity = LEAST(cart_items.quantity + EXCLUDED.quantity, $5), version = $4,
+			added_price = EXCLUDED.added_price, updated_at = now()
+		RETURNING id, user_id, product_id, quantity, version, added_version, added_price, created_at, updated_at
+	`, userID, saved.ProductID, saved.Quantity, version, maxCartItemQuantity)
+	if err != nil {
+		return nil, err
+	}
+
+	return &item, tx.Commit()
+}
+
+// RemoveSavedItem deletes one of the user's saved items
+func RemoveSavedItem(ctx context.Context, savedItemID, userID string) error {
+	return execOwned(ctx, DB, `DELETE FROM saved_items WHERE id = $1 AND user_id = $2`, savedItemID, userID)
+}
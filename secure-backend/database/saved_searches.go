@@ -0,0 +1,169 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"secure-backend/models"
+	"strconv"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ErrSavedSearchLimit is returned when a buyer already has models.MaxSavedSearches searches
+var ErrSavedSearchLimit = errors.New("saved search limit reached")
+
+// savedSearchReader is the name the saved search alerts read the catalog change feed under
+const savedSearchReader = "saved_search_alerts"
+
+const savedSearchColumns = `id, user_id, name, query, category, tag, min_price, max_price, alert, last_alerted_at, created_at, updated_at`
+
+// GetSavedSearches returns the user's saved searches, oldest first
+func GetSavedSearches(ctx context.Context, userID string) ([]models.SavedSearch, error) {
+	searches := []models.SavedSearch{}
+	err := DB.SelectContext(ctx, &searches, `
+		SELECT `+savedSearchColumns+`
+		FROM saved_searches
+		WHERE user_id = $1
+		ORDER BY created_at, id
+	`, userID)
+	return searches, err
+}
+
+// CreateSavedSearch saves a search of search.UserID, filling in its ID and timestamps. The
+// user's row is locked while their searches are counted, so concurrent saves can't exceed
+// models.MaxSavedSearches; ErrSavedSearchLimit is returned at the limit.
+func CreateSavedSearch(ctx context.Context, search *models.SavedSearch) error {
+	return WithTx(ctx, func(tx *sqlx.Tx) error {
+		var count int
+		err := tx.GetContext(ctx, &count, `
+			SELECT COUNT(*) FROM saved_searches
+			WHERE user_id = (SELECT id FROM users WHERE id = $1 FOR UPDATE)
+		`, search.UserID)
+		if err != nil {
+			return err
+		}
+		if count >= models.MaxSavedSearches {
+			return ErrSavedSearchLimit
+		}
+
+		return tx.GetContext(ctx, search, `
+			INSERT INTO saved_searches (user_id, name, query, category, tag, min_price, max_price, alert)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING `+savedSearchColumns,
+			search.UserID, search.Name, search.Query, search.Category, search.Tag, search.MinPrice, search.MaxPrice, search.Alert)
+	})
+}
+
+// UpdateSavedSearch replaces the name, query, filters and alert setting of one of the user's
+// saved searches. Returns sql.ErrNoRows if there is no such search of the user.
+func UpdateSavedSearch(ctx context.Context, search *models.SavedSearch) error {
+	return DB.GetContext(ctx, search, `
+		UPDATE saved_searches
+		SET name = $3, query = $4, category = $5, tag = $6, min_price = $7, max_price = $8, alert = $9
+		WHERE id = $1 AND user_id = $2
+		RETURNING `+savedSearchColumns,
+		search.ID, search.UserID, search.Name, search.Query, search.Category, search.Tag, search.MinPrice, search.MaxPrice, search.Alert)
+}
+
+// DeleteSavedSearch deletes one of the user's saved searches. Returns sql.ErrNoRows if there
+// is no such search of the user.
+func DeleteSavedSearch(ctx context.Context, id, userID string) error {
+	return execOwned(ctx, DB, `DELETE FROM saved_searches WHERE id = $1 AND user_id = $2`, id, userID)
+}
+
+// ClaimSavedSearchMatches reads up to limit changes of the catalog change feed after the
+// position of the saved search alerts and records, for each saved search with alerts on,
+// the changed products that are published and match it but weren't matched before. It
+// returns those new matches and how many changes it read; fewer than limit means the feed
+// is caught up. The position starts at the end of the feed, is saved in the same
+// transaction and is locked while the batch runs, so instances share the work and every
+// match is returned once. Sellers' own products and those of sellers on vacation with
+// hidden listings don't match.
+func ClaimSavedSearchMatches(ctx context.Context, limit int) ([]models.SavedSearchMatch, int, error) {
+	var matches []models.SavedSearchMatch
+	var read int
+	err := WithTx(ctx, func(tx *sqlx.Tx) error {
+		matches, read = []models.SavedSearchMatch{}, 0
+
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO catalog_change_readers (name, xact_id, change_id)
+			SELECT $1, xact_id, id FROM (
+				SELECT xact_id, id FROM catalog_changes
+				WHERE xact_id < pg_snapshot_xmin(pg_current_snapshot())
+				UNION ALL
+				SELECT '0'::xid8, 0
+			) feed
+			ORDER BY xact_id DESC, id DESC
+			LIMIT 1
+			ON CONFLICT (name) DO NOTHING
+		`, savedSearchReader)
+		if err != nil {
+			return err
+		}
+		var after models.ChangeCursor
+		err = tx.QueryRowxContext(ctx, `
+			SELECT xact_id::text, change_id FROM catalog_change_readers WHERE name = $1 FOR UPDATE
+		`, savedSearchReader).Scan(&after.XactID, &after.ID)
+		if err != nil {
+			return err
+		}
+
+		changes, err := catalogChanges(ctx, tx, after, false, limit)
+		if err != nil || len(changes) == 0 {
+			return err
+		}
+		read = len(changes)
+
+		productIDs := make([]string, 0, len(changes))
+		for _, change := range changes {
+			if change.Change != models.CatalogDeleted {
+				productIDs = append(productIDs, change.ProductID)
+			}
+		}
+		err = tx.SelectContext(ctx, &matches, `
+			WITH matched AS (
+				INSERT INTO saved_search_matches (saved_search_id, product_id)
+				SELECT ss.id, p.id
+				FROM products p
+				JOIN saved_searches ss ON ss.alert AND ss.user_id <> p.seller_id
+				WHERE p.id = ANY($1::uuid[]) AND p.status = 'published'
+					AND p.seller_id NOT IN (`+hiddenSellers(2)+`)
+					AND (ss.query = '' OR p.search_vector @@ websearch_to_tsquery('english', ss.query))
+					AND (ss.category = '' OR p.category_id = (SELECT id FROM categories WHERE slug = ss.category))
+					AND (ss.tag = '' OR EXISTS (
+						SELECT 1 FROM product_tags pt JOIN tags t ON t.id = pt.tag_id
+						WHERE pt.product_id = p.id AND t.slug = ss.tag
+					))
+					AND (ss.min_price IS NULL OR p.price >= ss.min_price)
+					AND (ss.max_price IS NULL OR p.price <= ss.max_price)
+				ON CONFLICT DO NOTHING
+				RETURNING saved_search_id, product_id
+			), alerted AS (
+				UPDATE saved_searches SET last_alerted_at = now()
+				WHERE id IN (SELECT saved_search_id FROM matched)
+				RETURNING id, user_id, name
+			)
+			SELECT a.id AS saved_search_id, a.user_id, a.name AS search_name,
+				p.id AS product_id, p.name AS product_name, p.price
+			FROM matched m
+			JOIN alerted a ON a.id = m.saved_search_id
+			JOIN products p ON p.id = m.product_id
+			ORDER BY a.id, p.name
+		`, pq.Array(productIDs), clk.Now())
+		if err != nil {
+			return err
+		}
+
+		last := changes[len(changes)-1]
+		_, err = tx.ExecContext(ctx, `
+			UPDATE catalog_change_readers SET xact_id = $2::text::xid8, change_id = $3, updated_at = now()
+			WHERE name = $1
+		`, savedSearchReader, strconv.FormatUint(last.XactID, 10), last.ID)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return matches, read, nil
+}
@@ -6,7 +6,25 @@ CREATE TABLE users (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     email VARCHAR(255) UNIQUE NOT NULL,
     role VARCHAR(50) NOT NULL DEFAULT 'buyer' CHECK (role IN ('buyer', 'seller', 'admin')),
-    password_hash TEXT, -- For non-Supabase auth (if needed)
+    plan VARCHAR(20) NOT NULL DEFAULT 'free' CHECK (plan IN ('free', 'pro', 'enterprise')), -- seller plan, selects the API quota tier
+    password_hash TEXT, -- bcrypt hash, set only for break-glass accounts
+    break_glass BOOLEAN NOT NULL DEFAULT false, -- local-auth emergency admin (provisioned with cmd/admin)
+    min_order_value DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (min_order_value >= 0), -- seller's minimum order subtotal (0 = none)
+    vacation_starts_at TIMESTAMP WITH TIME ZONE, -- seller vacation mode (NULL = none scheduled)
+    vacation_ends_at TIMESTAMP WITH TIME ZONE, -- NULL = until the seller ends it
+    vacation_message TEXT NOT NULL DEFAULT '', -- shown to buyers while on vacation
+    vacation_hide_listings BOOLEAN NOT NULL DEFAULT false, -- leave products out of listings while on vacation
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    CHECK (vacation_ends_at IS NULL OR vacation_ends_at > vacation_starts_at)
+);
+
+-- Product categories (used for storefront navigation)
+CREATE TABLE categories (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    slug VARCHAR(100) UNIQUE NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
     created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
 );
@@ -18,31 +36,158 @@ CREATE TABLE products (
     description TEXT,
     price DECIMAL(10,2) NOT NULL CHECK (price >= 0),
     image_url TEXT, -- URL to image (updated to match frontend usage)
+    image_alt TEXT NOT NULL DEFAULT '', -- Alt text for the product image
+    image_hash TEXT, -- SHA-256 of the uploaded image (used to find duplicate listings)
+    width_cm DECIMAL(10,2) CHECK (width_cm > 0),
+    height_cm DECIMAL(10,2) CHECK (height_cm > 0),
+    depth_cm DECIMAL(10,2) CHECK (depth_cm > 0),
+    weight_kg DECIMAL(10,3) CHECK (weight_kg > 0),
     stock INTEGER NOT NULL DEFAULT 0 CHECK (stock >= 0),
     status VARCHAR(20) NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'published', 'archived')),
     seller_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    category_id UUID REFERENCES categories(id) ON DELETE SET NULL,
+    slug VARCHAR(80) NOT NULL UNIQUE DEFAULT gen_random_uuid()::text, -- the API derives it from the name
+    meta_title VARCHAR(70) NOT NULL DEFAULT '',
+    meta_description VARCHAR(160) NOT NULL DEFAULT '',
+    min_order_quantity INTEGER NOT NULL DEFAULT 1 CHECK (min_order_quantity >= 1),
+    max_order_quantity INTEGER CHECK (max_order_quantity >= min_order_quantity), -- NULL = no maximum
+    shelf_location VARCHAR(50) NOT NULL DEFAULT '', -- where the seller keeps it, printed on pick lists
+    -- Full-text search document: name weighted above description
+    search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(description, '')), 'B')
+    ) STORED,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
 );
 
+-- Every price a product has had; old_price is NULL for the price set at creation
+CREATE TABLE product_price_history (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    old_price DECIMAL(10,2),
+    new_price DECIMAL(10,2) NOT NULL CHECK (new_price >= 0),
+    changed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Product tags (free-form labels, many per product)
+CREATE TABLE tags (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(50) NOT NULL,
+    slug VARCHAR(50) UNIQUE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE TABLE product_tags (
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (product_id, tag_id)
+);
+
 -- Cart items table
 CREATE TABLE cart_items (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
     product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
     quantity INTEGER NOT NULL CHECK (quantity > 0),
+    version BIGINT NOT NULL DEFAULT 0, -- Cart version at which this item last changed
+    added_version BIGINT NOT NULL DEFAULT 0, -- Cart version at which this item was added
+    added_price DECIMAL(10,2) NOT NULL CHECK (added_price >= 0), -- Product price when last added to the cart
     created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
     UNIQUE(user_id, product_id) -- Prevent duplicate cart items
 );
 
+-- Monotonically increasing cart version per user (used for delta sync)
+CREATE TABLE cart_versions (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    version BIGINT NOT NULL DEFAULT 0,
+    abandoned_at TIMESTAMP WITH TIME ZONE, -- Set when the cart went idle; cleared by the next cart change
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Tombstones for removed cart items so clients can sync deletions
+CREATE TABLE cart_item_tombstones (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    cart_item_id UUID NOT NULL,
+    product_id UUID NOT NULL,
+    version BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Carts that went idle with items in them (input for abandoned-cart emails)
+CREATE TABLE cart_abandonments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    cart_version BIGINT NOT NULL, -- Cart version that was abandoned
+    item_count INTEGER NOT NULL,
+    subtotal DECIMAL(10,2) NOT NULL,
+    last_activity_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    released_orders INTEGER NOT NULL DEFAULT 0, -- Unpaid checkouts cancelled to release their stock
+    notified_at TIMESTAMP WITH TIME ZONE, -- Set once a reminder email went out
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE(user_id, cart_version)
+);
+
+-- Accessories (cross-sells) and upgrades (upsells) a seller recommends with a product
+CREATE TABLE product_recommendations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    recommended_product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('cross_sell', 'upsell')),
+    label VARCHAR(100) NOT NULL DEFAULT '', -- e.g. "Add batteries"
+    position INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE(product_id, recommended_product_id),
+    CHECK (product_id <> recommended_product_id)
+);
+
+-- Clicks on recommendations and recommended products added to the cart (conversion tracking)
+CREATE TABLE recommendation_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    recommended_product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(20) NOT NULL CHECK (action IN ('click', 'attach')),
+    placement VARCHAR(20) NOT NULL CHECK (placement IN ('product', 'cart', 'checkout')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Items the buyer moved out of the active cart to buy later
+CREATE TABLE saved_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE(user_id, product_id)
+);
+
+-- Products buyers keep an eye on; price_alert opts into price-drop notifications
+CREATE TABLE wishlist_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    price_alert BOOLEAN NOT NULL DEFAULT false,
+    target_price DECIMAL(10,2) CHECK (target_price > 0), -- alert only at or below this price (NULL = any drop)
+    alert_price DECIMAL(10,2) NOT NULL, -- price when added or last alerted; drops are measured from here
+    last_alerted_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE(user_id, product_id)
+);
+
 -- Orders table
 CREATE TABLE orders (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     buyer_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'confirmed', 'shipped', 'delivered', 'cancelled')),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'paid', 'shipped', 'delivered', 'cancelled', 'refunded')),
     total_amount DECIMAL(10,2) NOT NULL CHECK (total_amount >= 0),
     shipping_address TEXT,
+    client_platform VARCHAR(20) NOT NULL DEFAULT 'unknown', -- Platform the order was placed from (ios, android, web)
     created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
 );
@@ -55,16 +200,752 @@ CREATE TABLE order_items (
     quantity INTEGER NOT NULL CHECK (quantity > 0),
     unit_price DECIMAL(10,2) NOT NULL CHECK (unit_price >= 0), -- Price at time of purchase
     total_price DECIMAL(10,2) NOT NULL CHECK (total_price >= 0),
+    fulfillment_status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (fulfillment_status IN ('pending', 'shipped', 'fulfilled')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Order status history (one row per status transition, used for the order timeline)
+CREATE TABLE order_status_history (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    from_status VARCHAR(20) NOT NULL,
+    to_status VARCHAR(20) NOT NULL,
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL, -- NULL for system transitions
+    actor_role VARCHAR(50) NOT NULL,
+    note TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Shipping address edits by buyers, and requests routed to support once the self-service
+-- window has closed; the audit trail of every address change
+CREATE TABLE order_address_changes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    requested_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    old_address TEXT NOT NULL DEFAULT '',
+    new_address TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('applied', 'support_requested', 'approved', 'rejected')),
+    reason VARCHAR(30) NOT NULL DEFAULT '',
+    resolved_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    resolution_note TEXT NOT NULL DEFAULT '',
+    resolved_at TIMESTAMP WITH TIME ZONE,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
 );
 
+-- Push notification device tokens
+CREATE TABLE device_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token TEXT UNIQUE NOT NULL,
+    platform VARCHAR(20) NOT NULL CHECK (platform IN ('ios', 'android')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Cart analytics events (which platform performed each cart action)
+CREATE TABLE cart_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    action VARCHAR(20) NOT NULL,
+    product_id UUID REFERENCES products(id) ON DELETE SET NULL,
+    quantity INTEGER NOT NULL DEFAULT 0,
+    platform VARCHAR(20) NOT NULL DEFAULT 'unknown',
+    app_version VARCHAR(64),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Consent choices of users, kept as a history; the latest record applies and without one
+-- the user consented to nothing. The IP address is stored only as a keyed hash.
+CREATE TABLE consent_records (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    analytics BOOLEAN NOT NULL,
+    marketing BOOLEAN NOT NULL,
+    policy_version VARCHAR(32) NOT NULL,
+    ip_hash VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Requests by buyers to erase their account. A request awaits confirmation from the emailed
+-- link until confirm_by, then is scheduled for erasure after a grace period in which it can
+-- still be cancelled. Completed requests stay as the record of the erasure.
+CREATE TABLE erasure_requests (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(30) NOT NULL CHECK (status IN ('awaiting_confirmation', 'scheduled', 'completed', 'cancelled', 'expired')),
+    confirm_by TIMESTAMP WITH TIME ZONE NOT NULL,
+    confirmed_at TIMESTAMP WITH TIME ZONE,
+    scheduled_for TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    cancelled_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Stock held for pending orders during checkout; released back to stock if the order
+-- isn't paid before expires_at
+CREATE TABLE stock_reservations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'committed', 'released')),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Column mappings sellers save for product imports (file column -> product field, number format)
+CREATE TABLE import_templates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    seller_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    mapping JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE(seller_id, name)
+);
+
+-- Probable duplicate or copied listings across sellers, queued for admin review.
+-- product_id is the newer listing, duplicate_of_id the older one it resembles.
+CREATE TABLE duplicate_listings (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    duplicate_of_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    score DECIMAL(4,3) NOT NULL CHECK (score >= 0 AND score <= 1),
+    reasons TEXT[] NOT NULL DEFAULT '{}', -- image, name, description
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'dismissed', 'confirmed')),
+    reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    review_note TEXT NOT NULL DEFAULT '',
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE(product_id, duplicate_of_id),
+    CHECK (product_id <> duplicate_of_id)
+);
+
+-- When each listing was last compared against the catalog; listings changed since are checked again
+CREATE TABLE product_duplicate_checks (
+    product_id UUID PRIMARY KEY REFERENCES products(id) ON DELETE CASCADE,
+    checked_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Ledger of every change to product stock; the sum of a product's movements is its stock.
+-- Reservation and release movements mirror stock_reservations (used by the stock audit).
+CREATE TABLE stock_movements (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL CHECK (quantity <> 0), -- signed change to the stock
+    reason VARCHAR(20) NOT NULL CHECK (reason IN ('opening', 'seller_update', 'reservation', 'release', 'restock', 'adjustment')),
+    order_id UUID REFERENCES orders(id) ON DELETE SET NULL,
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Payments with external providers (one order may have several attempts)
+CREATE TABLE payments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE RESTRICT,
+    provider VARCHAR(20) NOT NULL,
+    provider_payment_id TEXT NOT NULL,
+    amount DECIMAL(10,2) NOT NULL CHECK (amount >= 0),
+    currency VARCHAR(3) NOT NULL,
+    status VARCHAR(40) NOT NULL,
+    fee DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (fee >= 0), -- provider fee, known once its payout is reconciled
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE(provider, provider_payment_id)
+);
+
+-- Chargebacks and inquiries buyers opened with their card issuer, as reported by the payment
+-- provider. status is the provider's (needs_response, under_review, won, lost, warning_*).
+CREATE TABLE disputes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE RESTRICT,
+    payment_id UUID NOT NULL REFERENCES payments(id) ON DELETE RESTRICT,
+    provider VARCHAR(20) NOT NULL,
+    provider_dispute_id TEXT NOT NULL,
+    amount DECIMAL(10,2) NOT NULL CHECK (amount >= 0),
+    currency VARCHAR(3) NOT NULL,
+    reason VARCHAR(50) NOT NULL DEFAULT '',
+    status VARCHAR(40) NOT NULL,
+    evidence_due_by TIMESTAMP WITH TIME ZONE,
+    evidence JSONB NOT NULL DEFAULT '{}',
+    evidence_submitted_at TIMESTAMP WITH TIME ZONE,
+    evidence_submitted_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    closed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE(provider, provider_dispute_id)
+);
+
+-- Payouts from the payment provider checked against the shop's payments and refunds. A
+-- payout that doesn't reconcile is blocked until it does or an admin resolves it.
+CREATE TABLE payout_reconciliations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    provider VARCHAR(20) NOT NULL,
+    provider_payout_id TEXT NOT NULL,
+    amount DECIMAL(12,2) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    arrival_date TIMESTAMP WITH TIME ZONE,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('reconciled', 'blocked', 'resolved')),
+    transaction_count INTEGER NOT NULL DEFAULT 0,
+    checked_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    resolved_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    resolution_note TEXT,
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE(provider, provider_payout_id)
+);
+
+-- Differences found by the latest check of a payout (replaced on every check)
+CREATE TABLE reconciliation_mismatches (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    reconciliation_id UUID NOT NULL REFERENCES payout_reconciliations(id) ON DELETE CASCADE,
+    balance_transaction_id TEXT NOT NULL,
+    kind VARCHAR(30) NOT NULL, -- unknown_payment, unknown_refund, amount_mismatch, status_mismatch, total_mismatch
+    order_id UUID REFERENCES orders(id) ON DELETE SET NULL,
+    expected DECIMAL(12,2),
+    actual DECIMAL(12,2),
+    detail TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Closed accounting months (period is the first day, UTC) with the figures they closed with.
+-- Orders and refunds of a closed month can only be changed by admins.
+CREATE TABLE financial_periods (
+    period DATE PRIMARY KEY CHECK (EXTRACT(DAY FROM period) = 1),
+    gross_sales DECIMAL(14,2) NOT NULL,
+    refunds DECIMAL(14,2) NOT NULL,
+    fees DECIMAL(14,2) NOT NULL,
+    net DECIMAL(14,2) NOT NULL,
+    order_count INTEGER NOT NULL,
+    refund_count INTEGER NOT NULL,
+    closed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    closed_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Seller payouts of an order held back, e.g. while a chargeback is open or while the provider
+-- payout that funded them doesn't reconcile (released_at is set when the hold is lifted)
+CREATE TABLE payout_holds (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    dispute_id UUID UNIQUE REFERENCES disputes(id) ON DELETE CASCADE,
+    reconciliation_id UUID REFERENCES payout_reconciliations(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL,
+    released_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Retries of an order's failed card payment before the order is cancelled (dunning)
+CREATE TABLE payment_dunning (
+    order_id UUID PRIMARY KEY REFERENCES orders(id) ON DELETE CASCADE,
+    payment_id UUID NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'recovered', 'cancelled')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE,
+    final_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Error reports sent by frontend and mobile clients
+CREATE TABLE client_errors (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    request_id VARCHAR(100) NOT NULL,
+    client_request_id VARCHAR(100),
+    message TEXT NOT NULL,
+    stack TEXT,
+    url TEXT,
+    component VARCHAR(200),
+    user_agent TEXT,
+    platform VARCHAR(20) NOT NULL DEFAULT 'unknown',
+    app_version VARCHAR(64),
+    occurred_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Received payment provider webhook events (idempotency and replay protection)
+CREATE TABLE webhook_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    provider VARCHAR(20) NOT NULL,
+    event_id TEXT NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 1,
+    last_error TEXT,
+    processed_at TIMESTAMP WITH TIME ZONE,
+    discarded_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE(provider, event_id)
+);
+
+-- Refunds issued for orders (full or partial)
+CREATE TABLE refunds (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE RESTRICT,
+    payment_id UUID NOT NULL REFERENCES payments(id) ON DELETE RESTRICT,
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL,
+    reason TEXT,
+    restock BOOLEAN NOT NULL DEFAULT false,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'failed')),
+    provider_refund_id TEXT,
+    actor_id UUID NOT NULL REFERENCES users(id) ON DELETE RESTRICT,
+    actor_role VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Order item quantities covered by a refund
+CREATE TABLE refund_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    refund_id UUID NOT NULL REFERENCES refunds(id) ON DELETE CASCADE,
+    order_item_id UUID NOT NULL REFERENCES order_items(id) ON DELETE RESTRICT,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE RESTRICT,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    amount DECIMAL(10,2) NOT NULL CHECK (amount >= 0)
+);
+
+-- How a refund is split across the payments of an order (e.g. store credit plus card)
+CREATE TABLE refund_allocations (
+    refund_id UUID NOT NULL REFERENCES refunds(id) ON DELETE CASCADE,
+    payment_id UUID NOT NULL REFERENCES payments(id) ON DELETE RESTRICT,
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+    provider_refund_id TEXT,
+    PRIMARY KEY (refund_id, payment_id)
+);
+
+-- Invoices, numbered sequentially without gaps from invoice_counter
+CREATE TABLE invoices (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID UNIQUE NOT NULL REFERENCES orders(id) ON DELETE RESTRICT,
+    number BIGINT UNIQUE NOT NULL,
+    tax_rate DECIMAL(5,4) NOT NULL DEFAULT 0 CHECK (tax_rate >= 0 AND tax_rate < 1),
+    issued_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE TABLE invoice_counter (
+    id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+    last_number BIGINT NOT NULL DEFAULT 0
+);
+INSERT INTO invoice_counter (id, last_number) VALUES (true, 0);
+
+-- Tax included in each order item, recorded at checkout with the jurisdiction and rate that
+-- applied (TAX_JURISDICTION, INVOICE_TAX_RATE); the source of tax reports
+CREATE TABLE order_tax_lines (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    order_item_id UUID UNIQUE NOT NULL REFERENCES order_items(id) ON DELETE CASCADE,
+    seller_id UUID NOT NULL REFERENCES users(id) ON DELETE RESTRICT,
+    jurisdiction VARCHAR(20) NOT NULL,
+    rate DECIMAL(5,4) NOT NULL CHECK (rate >= 0 AND rate < 1),
+    gross_amount DECIMAL(10,2) NOT NULL CHECK (gross_amount >= 0),
+    taxable_amount DECIMAL(10,2) NOT NULL CHECK (taxable_amount >= 0),
+    tax_amount DECIMAL(10,2) NOT NULL CHECK (tax_amount >= 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- User-triggered background jobs (exports, imports, bulk operations)
+CREATE TABLE jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(50) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'completed', 'failed', 'cancelled')),
+    progress INTEGER NOT NULL DEFAULT 0 CHECK (progress >= 0 AND progress <= 100),
+    params JSONB NOT NULL DEFAULT '{}',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    run_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(), -- Scheduled jobs aren't claimed before this
+    error TEXT,
+    result_path TEXT,
+    result_expires_at TIMESTAMP WITH TIME ZONE,
+    started_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    discarded_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Error history of failed background work (dead-letter queue), per source
+CREATE TABLE delivery_errors (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    source VARCHAR(20) NOT NULL,
+    item_id UUID NOT NULL,
+    attempt INTEGER NOT NULL,
+    error TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- API requests per user per day and per month (quota enforcement survives restarts)
+CREATE TABLE api_usage (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    period VARCHAR(10) NOT NULL CHECK (period IN ('day', 'month')),
+    period_start DATE NOT NULL,
+    count INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, period, period_start)
+);
+
+-- Server-side sessions for cookie authentication (web storefront). Only a hash of the
+-- session token is stored; the token itself lives in an encrypted httpOnly cookie.
+CREATE TABLE sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    csrf_token VARCHAR(64) NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Cut-off for a user's access tokens after an account compromise: tokens issued before
+-- revoked_before are refused by the auth middleware
+CREATE TABLE token_revocations (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    revoked_before TIMESTAMP WITH TIME ZONE NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    revoked_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- One-time tokens for claiming the first admin account. Only a hash is stored; the token
+-- is printed at startup (or by cmd/admin) while no admin exists.
+CREATE TABLE admin_bootstrap_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    used_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Audit trail of admin bootstrap and break-glass access (append-only)
+CREATE TABLE admin_audit_log (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    actor_email VARCHAR(255) NOT NULL DEFAULT '',
+    action VARCHAR(50) NOT NULL,
+    detail TEXT NOT NULL DEFAULT '',
+    ip_address VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Requests to the routes in REQUEST_AUDIT_ROUTES with their bodies, tokens, email addresses
+-- and postal addresses redacted; pruned after REQUEST_AUDIT_RETENTION
+CREATE TABLE request_audit_log (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    request_id VARCHAR(64) NOT NULL DEFAULT '',
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    method VARCHAR(10) NOT NULL,
+    route VARCHAR(255) NOT NULL,
+    path TEXT NOT NULL,
+    status INTEGER NOT NULL,
+    request_body TEXT NOT NULL DEFAULT '',
+    response_body TEXT NOT NULL DEFAULT '',
+    ip_address VARCHAR(64) NOT NULL DEFAULT '',
+    duration_ms INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Security event log (failed admin logins, role changes, API keys issued, throttled clients,
+-- JWKS failures), kept apart from application logs and pruned after SECURITY_EVENT_RETENTION
+CREATE TABLE security_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    type VARCHAR(50) NOT NULL,
+    severity VARCHAR(10) NOT NULL CHECK (severity IN ('low', 'medium', 'high')),
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    ip_address VARCHAR(64) NOT NULL DEFAULT '',
+    detail TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- API keys of comparison-shopping partners syncing the catalog. Only a hash of the key is
+-- stored; fields lists the product fields the partner may read.
+CREATE TABLE partner_api_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    key_prefix VARCHAR(16) NOT NULL,
+    fields TEXT[] NOT NULL DEFAULT '{}',
+    daily_quota INTEGER NOT NULL DEFAULT 0 CHECK (daily_quota >= 0),
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Catalog requests per partner key per UTC day
+CREATE TABLE partner_api_usage (
+    key_id UUID NOT NULL REFERENCES partner_api_keys(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    count INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (key_id, day)
+);
+
+-- Outbox of catalog changes, written by a trigger on products in the transaction making the
+-- change, so partners can mirror the catalog from a feed instead of polling full lists. The
+-- feed is ordered by (xact_id, id) and only serves transactions older than the oldest one
+-- still running, so events committed late never land behind a cursor already handed out.
+CREATE TABLE catalog_changes (
+    id BIGSERIAL PRIMARY KEY,
+    xact_id XID8 NOT NULL DEFAULT pg_current_xact_id(),
+    product_id UUID NOT NULL, -- no foreign key: deletions stay in the feed
+    change VARCHAR(20) NOT NULL CHECK (change IN ('created', 'updated', 'deleted', 'stock_changed')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Anonymous carts, identified by a signed cart token held by the client and merged
+-- into the buyer's cart after login
+CREATE TABLE cart_sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE TABLE guest_cart_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    cart_session_id UUID NOT NULL REFERENCES cart_sessions(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE(cart_session_id, product_id)
+);
+
+-- Snapshots of carts shared by link (team purchasing); the link carries a signed token
+-- naming the share, and other users can import its items into their own cart
+CREATE TABLE cart_shares (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE TABLE cart_share_items (
+    share_id UUID NOT NULL REFERENCES cart_shares(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (share_id, product_id)
+);
+
+-- Prepaid gift cards, stored by the hash of their code, redeemed into store credit
+CREATE TABLE gift_cards (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    code_hash CHAR(64) NOT NULL UNIQUE,
+    code_last4 VARCHAR(4) NOT NULL,
+    amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+    expires_at TIMESTAMP WITH TIME ZONE,
+    redeemed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    redeemed_at TIMESTAMP WITH TIME ZONE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Store credit ledger; a buyer's balance is the sum of their entries
+CREATE TABLE store_credit_entries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    amount DECIMAL(10,2) NOT NULL CHECK (amount <> 0),
+    reason VARCHAR(20) NOT NULL CHECK (reason IN ('gift_card', 'payment', 'payment_released', 'refund')),
+    order_id UUID REFERENCES orders(id) ON DELETE SET NULL,
+    refund_id UUID REFERENCES refunds(id) ON DELETE SET NULL,
+    gift_card_id UUID REFERENCES gift_cards(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Shipping labels sellers bought for their items of an order, one per seller and order. The
+-- label PDF is kept in private object storage under storage_key.
+CREATE TABLE shipping_labels (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    seller_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    job_id UUID REFERENCES jobs(id) ON DELETE SET NULL,
+    provider_label_id TEXT NOT NULL,
+    carrier VARCHAR(50) NOT NULL,
+    service VARCHAR(50) NOT NULL,
+    tracking_number VARCHAR(100) NOT NULL,
+    cost DECIMAL(10,2) NOT NULL CHECK (cost >= 0),
+    currency VARCHAR(3) NOT NULL,
+    storage_key TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE(order_id, seller_id)
+);
+
+-- Seller payout ledger: charges (negative) and credits settled with a seller's payouts, such
+-- as the cost of shipping labels bought through the shop
+CREATE TABLE seller_ledger_entries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    seller_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    amount DECIMAL(10,2) NOT NULL CHECK (amount <> 0),
+    currency VARCHAR(3) NOT NULL,
+    reason VARCHAR(20) NOT NULL CHECK (reason IN ('shipping_label')),
+    order_id UUID REFERENCES orders(id) ON DELETE SET NULL,
+    shipping_label_id UUID UNIQUE REFERENCES shipping_labels(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Conversations between a buyer and a seller about a product (before buying) or an order,
+-- one per buyer and product or per buyer, seller and order. flagged is set once moderation
+-- masked something in one of its messages.
+CREATE TABLE message_threads (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    buyer_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    seller_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    product_id UUID REFERENCES products(id) ON DELETE SET NULL,
+    order_id UUID REFERENCES orders(id) ON DELETE CASCADE,
+    flagged BOOLEAN NOT NULL DEFAULT false,
+    last_message_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    CHECK (product_id IS NULL OR order_id IS NULL)
+);
+
+-- Messages of a thread as stored after moderation; flags lists what was masked
+CREATE TABLE messages (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    thread_id UUID NOT NULL REFERENCES message_threads(id) ON DELETE CASCADE,
+    sender_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    body TEXT NOT NULL,
+    flags TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Search queries and filters saved by buyers. With alert set, the buyer is notified when a
+-- product newly matches; saved_search_matches records the products they were alerted about.
+CREATE TABLE saved_searches (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    query VARCHAR(200) NOT NULL DEFAULT '', -- full-text search query ('' = any)
+    category VARCHAR(100) NOT NULL DEFAULT '', -- category slug ('' = any)
+    tag VARCHAR(100) NOT NULL DEFAULT '', -- tag slug ('' = any)
+    min_price DECIMAL(10,2) CHECK (min_price >= 0),
+    max_price DECIMAL(10,2) CHECK (max_price >= 0),
+    alert BOOLEAN NOT NULL DEFAULT false,
+    last_alerted_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    CHECK (query <> '' OR category <> '' OR tag <> '' OR min_price IS NOT NULL OR max_price IS NOT NULL),
+    CHECK (max_price >= min_price)
+);
+
+CREATE TABLE saved_search_matches (
+    saved_search_id UUID NOT NULL REFERENCES saved_searches(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    PRIMARY KEY (saved_search_id, product_id)
+);
+
+-- Buyers' ratings of a seller for an order, separate from product feedback: shipping speed
+-- and communication from 1 to 5. The comment is stored after moderation (flags lists what
+-- was masked), and admins can hide a rating, which takes it out of the seller's averages.
+CREATE TABLE seller_ratings (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    seller_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    buyer_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    shipping_speed SMALLINT NOT NULL CHECK (shipping_speed BETWEEN 1 AND 5),
+    communication SMALLINT NOT NULL CHECK (communication BETWEEN 1 AND 5),
+    comment TEXT NOT NULL DEFAULT '',
+    flags TEXT[] NOT NULL DEFAULT '{}',
+    status VARCHAR(20) NOT NULL DEFAULT 'published' CHECK (status IN ('published', 'hidden')),
+    moderation_note TEXT NOT NULL DEFAULT '',
+    moderated_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    moderated_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE(order_id, seller_id)
+);
+
+-- Positions of background readers of the catalog change feed, so they resume where they
+-- stopped after a restart
+CREATE TABLE catalog_change_readers (
+    name VARCHAR(50) PRIMARY KEY,
+    xact_id XID8 NOT NULL,
+    change_id BIGINT NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
 -- Indexes for performance
 CREATE INDEX idx_products_seller_id ON products(seller_id);
 CREATE INDEX idx_products_status ON products(status);
+CREATE INDEX idx_products_search_vector ON products USING GIN (search_vector);
+CREATE INDEX idx_products_category_id ON products(category_id);
+CREATE INDEX idx_product_tags_tag_id ON product_tags(tag_id);
+CREATE INDEX idx_sessions_user_id ON sessions(user_id);
+CREATE INDEX idx_sessions_expires_at ON sessions(expires_at);
+CREATE INDEX idx_cart_sessions_expires_at ON cart_sessions(expires_at);
+CREATE INDEX idx_cart_shares_expires_at ON cart_shares(expires_at);
+CREATE INDEX idx_admin_audit_log_created_at ON admin_audit_log(created_at);
+CREATE INDEX idx_request_audit_log_created_at ON request_audit_log(created_at);
+CREATE INDEX idx_request_audit_log_route ON request_audit_log(route, created_at);
+CREATE INDEX idx_security_events_created_at ON security_events(created_at);
+CREATE INDEX idx_security_events_type ON security_events(type, created_at);
+CREATE INDEX idx_disputes_order_id ON disputes(order_id);
+CREATE INDEX idx_disputes_status ON disputes(status, created_at);
+CREATE INDEX idx_payout_holds_order_id ON payout_holds(order_id) WHERE released_at IS NULL;
+CREATE UNIQUE INDEX idx_payout_holds_reconciliation ON payout_holds(reconciliation_id, order_id) WHERE reconciliation_id IS NOT NULL;
+CREATE INDEX idx_payout_reconciliations_status ON payout_reconciliations(status, created_at);
+CREATE INDEX idx_reconciliation_mismatches_reconciliation_id ON reconciliation_mismatches(reconciliation_id);
+CREATE INDEX idx_product_price_history_product_id ON product_price_history(product_id, created_at);
 CREATE INDEX idx_cart_items_user_id ON cart_items(user_id);
+CREATE INDEX idx_cart_items_user_version ON cart_items(user_id, version);
+CREATE INDEX idx_cart_item_tombstones_user_version ON cart_item_tombstones(user_id, version);
+CREATE INDEX idx_cart_versions_idle ON cart_versions(updated_at) WHERE abandoned_at IS NULL;
+CREATE INDEX idx_cart_abandonments_created_at ON cart_abandonments(created_at);
+CREATE INDEX idx_saved_items_user_id ON saved_items(user_id, created_at);
+CREATE INDEX idx_wishlist_items_user_id ON wishlist_items(user_id, created_at);
+CREATE INDEX idx_wishlist_items_price_alert ON wishlist_items(product_id) WHERE price_alert;
+CREATE INDEX idx_product_recommendations_product_id ON product_recommendations(product_id, position);
+CREATE INDEX idx_recommendation_events_created_at ON recommendation_events(created_at);
 CREATE INDEX idx_orders_buyer_id ON orders(buyer_id);
 CREATE INDEX idx_order_items_order_id ON order_items(order_id);
 CREATE INDEX idx_order_items_product_id ON order_items(product_id);
+CREATE INDEX idx_cart_events_created_at ON cart_events(created_at);
+CREATE INDEX idx_orders_created_at ON orders(created_at);
+CREATE INDEX idx_stock_reservations_order_id ON stock_reservations(order_id);
+CREATE INDEX idx_stock_reservations_active_expiry ON stock_reservations(expires_at) WHERE status = 'active';
+CREATE INDEX idx_stock_movements_product_id ON stock_movements(product_id, created_at);
+CREATE INDEX idx_import_templates_seller_id ON import_templates(seller_id);
+CREATE INDEX idx_duplicate_listings_status ON duplicate_listings(status, created_at);
+CREATE INDEX idx_products_image_hash ON products(image_hash) WHERE image_hash IS NOT NULL;
+CREATE INDEX idx_products_updated_at ON products(updated_at);
+CREATE INDEX idx_payments_order_id ON payments(order_id);
+CREATE INDEX idx_client_errors_created_at ON client_errors(created_at);
+CREATE INDEX idx_device_tokens_user_id ON device_tokens(user_id);
+CREATE INDEX idx_order_status_history_order_id ON order_status_history(order_id, created_at);
+CREATE INDEX idx_order_address_changes_order_id ON order_address_changes(order_id, created_at);
+CREATE UNIQUE INDEX idx_order_address_changes_open ON order_address_changes(order_id) WHERE status = 'support_requested';
+CREATE INDEX idx_refunds_order_id ON refunds(order_id);
+CREATE INDEX idx_refund_items_order_item_id ON refund_items(order_item_id);
+CREATE INDEX idx_refund_allocations_payment_id ON refund_allocations(payment_id);
+CREATE INDEX idx_order_tax_lines_order_id ON order_tax_lines(order_id);
+CREATE INDEX idx_consent_records_user_created ON consent_records(user_id, created_at DESC);
+CREATE UNIQUE INDEX idx_erasure_requests_open ON erasure_requests(user_id) WHERE status IN ('awaiting_confirmation', 'scheduled');
+CREATE INDEX idx_erasure_requests_due ON erasure_requests(scheduled_for) WHERE status = 'scheduled';
+CREATE INDEX idx_store_credit_entries_user_id ON store_credit_entries(user_id, created_at);
+CREATE INDEX idx_shipping_labels_seller_id ON shipping_labels(seller_id, created_at);
+CREATE INDEX idx_seller_ledger_entries_seller_id ON seller_ledger_entries(seller_id, created_at);
+CREATE UNIQUE INDEX idx_message_threads_product ON message_threads(buyer_id, product_id) WHERE order_id IS NULL;
+CREATE UNIQUE INDEX idx_message_threads_order ON message_threads(buyer_id, seller_id, order_id) WHERE order_id IS NOT NULL;
+CREATE INDEX idx_message_threads_buyer_id ON message_threads(buyer_id, last_message_at);
+CREATE INDEX idx_message_threads_seller_id ON message_threads(seller_id, last_message_at);
+CREATE INDEX idx_message_threads_order_id ON message_threads(order_id) WHERE order_id IS NOT NULL;
+CREATE INDEX idx_messages_thread_id ON messages(thread_id, created_at);
+CREATE INDEX idx_saved_searches_user_id ON saved_searches(user_id, created_at);
+CREATE INDEX idx_saved_search_matches_product_id ON saved_search_matches(product_id);
+CREATE INDEX idx_seller_ratings_seller_id ON seller_ratings(seller_id, created_at DESC);
+CREATE INDEX idx_seller_ratings_buyer_id ON seller_ratings(buyer_id);
+CREATE INDEX idx_jobs_user_id ON jobs(user_id, created_at);
+CREATE INDEX idx_jobs_queued ON jobs(run_at) WHERE status = 'queued';
+CREATE INDEX idx_delivery_errors_item ON delivery_errors(source, item_id, created_at);
+CREATE INDEX idx_catalog_changes_position ON catalog_changes(xact_id, id);
+CREATE INDEX idx_catalog_changes_created_at ON catalog_changes(created_at);
 
 -- Triggers to update timestamps
 CREATE OR REPLACE FUNCTION update_updated_at_column()
@@ -77,15 +958,118 @@ $$ language 'plpgsql';
 
 CREATE TRIGGER update_users_updated_at BEFORE UPDATE ON users FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 CREATE TRIGGER update_products_updated_at BEFORE UPDATE ON products FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_categories_updated_at BEFORE UPDATE ON categories FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_tags_updated_at BEFORE UPDATE ON tags FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 CREATE TRIGGER update_cart_items_updated_at BEFORE UPDATE ON cart_items FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 CREATE TRIGGER update_orders_updated_at BEFORE UPDATE ON orders FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_device_tokens_updated_at BEFORE UPDATE ON device_tokens FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_stock_reservations_updated_at BEFORE UPDATE ON stock_reservations FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_payments_updated_at BEFORE UPDATE ON payments FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_payment_dunning_updated_at BEFORE UPDATE ON payment_dunning FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_disputes_updated_at BEFORE UPDATE ON disputes FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_payout_reconciliations_updated_at BEFORE UPDATE ON payout_reconciliations FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_order_items_updated_at BEFORE UPDATE ON order_items FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_refunds_updated_at BEFORE UPDATE ON refunds FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_jobs_updated_at BEFORE UPDATE ON jobs FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_import_templates_updated_at BEFORE UPDATE ON import_templates FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_wishlist_items_updated_at BEFORE UPDATE ON wishlist_items FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_guest_cart_items_updated_at BEFORE UPDATE ON guest_cart_items FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_partner_api_keys_updated_at BEFORE UPDATE ON partner_api_keys FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_token_revocations_updated_at BEFORE UPDATE ON token_revocations FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_saved_searches_updated_at BEFORE UPDATE ON saved_searches FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_seller_ratings_updated_at BEFORE UPDATE ON seller_ratings FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Record product changes in the catalog change feed. Updates of only the stock are recorded
+-- as stock_changed; changes to fields partners never see, and updates changing nothing, aren't
+-- recorded.
+CREATE OR REPLACE FUNCTION record_catalog_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO catalog_changes (product_id, change) VALUES (NEW.id, 'created');
+    ELSIF TG_OP = 'DELETE' THEN
+        INSERT INTO catalog_changes (product_id, change) VALUES (OLD.id, 'deleted');
+    ELSIF to_jsonb(NEW) - ARRAY['stock', 'updated_at', 'shelf_location', 'image_hash', 'search_vector']
+            IS DISTINCT FROM to_jsonb(OLD) - ARRAY['stock', 'updated_at', 'shelf_location', 'image_hash', 'search_vector'] THEN
+        INSERT INTO catalog_changes (product_id, change) VALUES (NEW.id, 'updated');
+    ELSIF NEW.stock <> OLD.stock THEN
+        INSERT INTO catalog_changes (product_id, change) VALUES (NEW.id, 'stock_changed');
+    END IF;
+    RETURN NULL;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER record_products_catalog_change AFTER INSERT OR UPDATE OR DELETE ON products FOR EACH ROW EXECUTE FUNCTION record_catalog_change();
 
 -- Enable Row Level Security (RLS) on all tables
 ALTER TABLE users ENABLE ROW LEVEL SECURITY;
 ALTER TABLE products ENABLE ROW LEVEL SECURITY;
+ALTER TABLE categories ENABLE ROW LEVEL SECURITY;
+ALTER TABLE tags ENABLE ROW LEVEL SECURITY;
+ALTER TABLE product_tags ENABLE ROW LEVEL SECURITY;
+ALTER TABLE product_price_history ENABLE ROW LEVEL SECURITY;
 ALTER TABLE cart_items ENABLE ROW LEVEL SECURITY;
+ALTER TABLE cart_versions ENABLE ROW LEVEL SECURITY;
+ALTER TABLE cart_item_tombstones ENABLE ROW LEVEL SECURITY;
+ALTER TABLE cart_abandonments ENABLE ROW LEVEL SECURITY;
+ALTER TABLE saved_items ENABLE ROW LEVEL SECURITY;
+ALTER TABLE wishlist_items ENABLE ROW LEVEL SECURITY;
+ALTER TABLE product_recommendations ENABLE ROW LEVEL SECURITY;
+ALTER TABLE recommendation_events ENABLE ROW LEVEL SECURITY;
 ALTER TABLE orders ENABLE ROW LEVEL SECURITY;
 ALTER TABLE order_items ENABLE ROW LEVEL SECURITY;
+ALTER TABLE order_status_history ENABLE ROW LEVEL SECURITY;
+ALTER TABLE order_address_changes ENABLE ROW LEVEL SECURITY;
+ALTER TABLE device_tokens ENABLE ROW LEVEL SECURITY;
+ALTER TABLE cart_events ENABLE ROW LEVEL SECURITY;
+ALTER TABLE stock_reservations ENABLE ROW LEVEL SECURITY;
+ALTER TABLE stock_movements ENABLE ROW LEVEL SECURITY;
+ALTER TABLE import_templates ENABLE ROW LEVEL SECURITY;
+ALTER TABLE duplicate_listings ENABLE ROW LEVEL SECURITY;
+ALTER TABLE product_duplicate_checks ENABLE ROW LEVEL SECURITY;
+ALTER TABLE payments ENABLE ROW LEVEL SECURITY;
+ALTER TABLE payment_dunning ENABLE ROW LEVEL SECURITY;
+ALTER TABLE disputes ENABLE ROW LEVEL SECURITY;
+ALTER TABLE payout_holds ENABLE ROW LEVEL SECURITY;
+ALTER TABLE payout_reconciliations ENABLE ROW LEVEL SECURITY;
+ALTER TABLE reconciliation_mismatches ENABLE ROW LEVEL SECURITY;
+ALTER TABLE financial_periods ENABLE ROW LEVEL SECURITY;
+ALTER TABLE client_errors ENABLE ROW LEVEL SECURITY;
+ALTER TABLE webhook_events ENABLE ROW LEVEL SECURITY;
+ALTER TABLE refunds ENABLE ROW LEVEL SECURITY;
+ALTER TABLE refund_items ENABLE ROW LEVEL SECURITY;
+ALTER TABLE invoices ENABLE ROW LEVEL SECURITY;
+ALTER TABLE order_tax_lines ENABLE ROW LEVEL SECURITY;
+ALTER TABLE consent_records ENABLE ROW LEVEL SECURITY;
+ALTER TABLE erasure_requests ENABLE ROW LEVEL SECURITY;
+ALTER TABLE invoice_counter ENABLE ROW LEVEL SECURITY;
+ALTER TABLE jobs ENABLE ROW LEVEL SECURITY;
+ALTER TABLE delivery_errors ENABLE ROW LEVEL SECURITY;
+ALTER TABLE api_usage ENABLE ROW LEVEL SECURITY;
+ALTER TABLE sessions ENABLE ROW LEVEL SECURITY;
+ALTER TABLE token_revocations ENABLE ROW LEVEL SECURITY;
+ALTER TABLE cart_sessions ENABLE ROW LEVEL SECURITY;
+ALTER TABLE guest_cart_items ENABLE ROW LEVEL SECURITY;
+ALTER TABLE cart_shares ENABLE ROW LEVEL SECURITY;
+ALTER TABLE cart_share_items ENABLE ROW LEVEL SECURITY;
+ALTER TABLE admin_bootstrap_tokens ENABLE ROW LEVEL SECURITY;
+ALTER TABLE admin_audit_log ENABLE ROW LEVEL SECURITY;
+ALTER TABLE request_audit_log ENABLE ROW LEVEL SECURITY;
+ALTER TABLE security_events ENABLE ROW LEVEL SECURITY;
+ALTER TABLE partner_api_keys ENABLE ROW LEVEL SECURITY;
+ALTER TABLE partner_api_usage ENABLE ROW LEVEL SECURITY;
+ALTER TABLE catalog_changes ENABLE ROW LEVEL SECURITY;
+ALTER TABLE refund_allocations ENABLE ROW LEVEL SECURITY;
+ALTER TABLE gift_cards ENABLE ROW LEVEL SECURITY;
+ALTER TABLE store_credit_entries ENABLE ROW LEVEL SECURITY;
+ALTER TABLE shipping_labels ENABLE ROW LEVEL SECURITY;
+ALTER TABLE seller_ledger_entries ENABLE ROW LEVEL SECURITY;
+ALTER TABLE message_threads ENABLE ROW LEVEL SECURITY;
+ALTER TABLE messages ENABLE ROW LEVEL SECURITY;
+ALTER TABLE saved_searches ENABLE ROW LEVEL SECURITY;
+ALTER TABLE saved_search_matches ENABLE ROW LEVEL SECURITY;
+ALTER TABLE catalog_change_readers ENABLE ROW LEVEL SECURITY;
+ALTER TABLE seller_ratings ENABLE ROW LEVEL SECURITY;
 
 -- RLS Policies for users table
 -- Users can read their own profile
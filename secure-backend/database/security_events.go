@@ -0,0 +1,64 @@
+package database
+
+import (
+	"context"
+	"secure-backend/models"
+	"time"
+)
+
+// securityEventColumns are selected for security events
+const securityEventColumns = `id, type, severity, user_id, ip_address, detail, created_at`
+
+// RecordSecurityEvent appends an event to the security event log and fills in its ID and time
+func RecordSecurityEvent(ctx context.Context, event *models.SecurityEvent) error {
+	return DB.QueryRowContext(ctx, `
+		INSERT INTO security_events (type, severity, user_id, ip_address, detail)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, event.Type, event.Severity, event.UserID, event.IPAddress, event.Detail).Scan(&event.ID, &event.CreatedAt)
+}
+
+// GetSecurityEvents returns a page of security events recorded in [from, to) (newest first)
+// and the total count. An empty type or severity matches every one.
+func GetSecurityEvents(ctx context.Context, eventType, severity string, from, to time.Time, limit, offset int) ([]models.SecurityEvent, int, error) {
+	const filter = `
+		WHERE ($1 = '' OR type = $1) AND ($2 = '' OR severity = $2)
+			AND created_at >= $3 AND created_at < $4`
+
+	var total int
+	err := DB.GetContext(ctx, &total, `SELECT COUNT(*) FROM security_events`+filter, eventType, severity, from, to)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	events := []models.SecurityEvent{}
+	err = DB.SelectContext(ctx, &events, `
+		SELECT `+securityEventColumns+` FROM security_events`+filter+`
+		ORDER BY created_at DESC
+		LIMIT $5 OFFSET $6
+	`, eventType, severity, from, to, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return events, total, nil
+}
+
+// GetSecurityEvent returns a security event by ID
+func GetSecurityEvent(ctx context.Context, id string) (*models.SecurityEvent, error) {
+	var event models.SecurityEvent
+	err := DB.GetContext(ctx, &event, `SELECT `+securityEventColumns+` FROM security_events WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// DeleteSecurityEventsBefore removes security events recorded before cutoff and returns how many
+func DeleteSecurityEventsBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := DB.ExecContext(ctx, `DELETE FROM security_events WHERE created_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"context"
+	"secure-backend/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// GetSellerOrderItems returns a page of order items for the seller's products (newest first)
+// and the total number of matching items. An empty status matches every fulfillment status.
+func GetSellerOrderItems(ctx context.Context, sellerID, status string, limit, offset int) ([]models.SellerOrderItem, int, error) {
+	var total int
+	err := DB.GetContext(ctx, &total, `
+		SELECT COUNT(*)
+		FROM order_items oi
+		JOIN products p ON oi.product_id = p.id
+		WHERE p.seller_id = $1 AND ($2 = '' OR oi.fulfillment_status = $2)
+	`, sellerID, status)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := DB.QueryContext(ctx, `
+		SELECT
+			oi.id, oi.order_id, oi.product_id, oi.quantity, oi.unit_price, oi.total_price,
+			oi.fulfillment_status, oi.created_at, oi.updated_at,
+			p.id, p.name, p.description, p.price, p.image, p.stock, p.status, p.seller_id, p.created_at, p.updated_at,
+			o.status, COALESCE(o.shipping_address, ''), o.created_at,
+			EXISTS (SELECT 1 FROM payout_holds h WHERE h.order_id = oi.order_id AND h.released_at IS NULL)
+		FROM order_items oi
+		JOIN products p ON oi.product_id = p.id
+		JOIN orders o ON oi.order_id = o.id
+		WHERE p.seller_id = $1 AND ($2 = '' OR oi.fulfillment_status = $2)
+		ORDER BY o.created_at DESC, oi.id
+		LIMIT $3 OFFSET $4`, sellerID, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	items := []models.SellerOrderItem{}
+	for rows.Next() {
+		var item models.SellerOrderItem
+		err := rows.Scan(
+			&item.ID, &item.OrderID, &item.ProductID, &item.Quantity, &item.UnitPrice, &item.TotalPrice,
+			&item.FulfillmentStatus, &item.CreatedAt, &item.UpdatedAt,
+			&item.Product.ID, &item.Product.Name, &item.Product.Description, &item.Product.Price,
+			&item.Product.Image, &item.Product.Stock, &item.Product.Status, &item.Product.SellerID,
+			&item.Product.CreatedAt, &item.Product.UpdatedAt,
+			&item.OrderStatus, &item.ShippingAddress, &item.OrderedAt, &item.PayoutHeld,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		items = append(items, item)
+	}
+
+	return items, total, rows.Err()
+}
+
+// GetSellerOrderItem retrieves an order item ensuring its product belongs to the seller
+func GetSellerOrderItem(ctx context.Context, orderItemID, sellerID string) (*models.OrderItem, error) {
+	var item models.OrderItem
+	err := DB.GetContext(ctx, &item, `
+		SELECT oi.id, oi.order_id, oi.product_id, oi.quantity, oi.unit_price, oi.total_price,
+			oi.fulfillment_status, oi.created_at, oi.updated_at
+		FROM order_items oi
+		JOIN products p ON oi.product_id = p.id
+		WHERE oi.id = $1 AND p.seller_id = $2
+	`, orderItemID, sellerID)
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// UpdateOrderItemFulfillment sets the fulfillment status of a seller's order item if it is
+// in one of fromStatuses and its order is paid or partly shipped, and returns the order's ID.
+// The order is locked first so it can't be cancelled while the item ships. Ownership, status
+// and order are checked by the update itself; it returns sql.ErrNoRows if any of them doesn't
+// hold, and callers load the item to tell which.
+func UpdateOrderItemFulfillment(ctx context.Context, orderItemID, sellerID string, fromStatuses []string, toStatus string) (string, error) {
+	var orderID string
+	err := WithTx(ctx, func(tx *sqlx.Tx) error {
+		err := tx.GetContext(ctx, &orderID, `
+			SELECT o.id FROM orders o
+			JOIN order_items oi ON oi.order_id = o.id
+			WHERE oi.id = $1
+			FOR UPDATE OF o
+		`, orderItemID)
+		if err != nil {
+			return err
+		}
+
+		return execOwned(ctx, tx, `
+			UPDATE order_items oi
+			SET fulfillment_status = $4
+			FROM products p, orders o
+			WHERE oi.product_id = p.id AND oi.order_id = o.id
+				AND oi.id = $1 AND p.seller_id = $2 AND oi.fulfillment_status = ANY($3)
+				AND o.status IN ('paid', 'shipped')
+		`, orderItemID, sellerID, pq.Array(fromStatuses), toStatus)
+	})
+	if err != nil {
+		return "", err
+	}
+	return orderID, nil
+}
+
+// OrderItemsShipped reports whether every item of an order has shipped
+func OrderItemsShipped(ctx context.Context, orderID string) (bool, error) {
+	var shipped bool
+	err := DB.GetContext(ctx, &shipped, `
+		SELECT NOT EXISTS (SELECT 1 FROM order_items WHERE order_id = $1 AND fulfillment_status = 'pending')
+	`, orderID)
+	return shipped, err
+}
+
+// OrderHasSellerItems reports whether an order contains any of the seller's products
+func OrderHasSellerItems(ctx context.Context, orderID, sellerID string) (bool, error) {
+	var exists bool
+	err := DB.GetContext(ctx, &exists, `
+		SELECT EXISTS (
+			SELECT 1 FROM order_items oi
+			JOIN products p ON oi.product_id = p.id
+			WHERE oi.order_id = $1 AND p.seller_id = $2
+		)
+	`, orderID, sellerID)
+	return exists, err
+}
@@ -0,0 +1,185 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"secure-backend/models"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	// ErrAlreadyRated is returned when the buyer already rated the seller for the order
+	ErrAlreadyRated = errors.New("seller was already rated for this order")
+	// ErrOrderNotDelivered is returned when a seller is rated before their items of the
+	// order were delivered
+	ErrOrderNotDelivered = errors.New("the seller's items of this order haven't been delivered yet")
+)
+
+const sellerRatingColumns = `id, order_id, seller_id, buyer_id, shipping_speed, communication, comment, flags,
+	status, moderation_note, moderated_by, moderated_at, created_at, updated_at`
+
+// SellerRatingFilter narrows the ratings admins list. Empty fields match every rating.
+type SellerRatingFilter struct {
+	SellerID    string
+	Status      string
+	FlaggedOnly bool
+}
+
+// CreateSellerRating stores a buyer's rating of a seller for one of their orders, filling in
+// its ID, status and timestamps. The order must be the buyer's and contain items of the
+// seller that were delivered, either with the whole order or fulfilled by the seller.
+// Returns sql.ErrNoRows if the buyer has no such order with items of the seller,
+// ErrOrderNotDelivered before delivery and ErrAlreadyRated for a second rating.
+func CreateSellerRating(ctx context.Context, rating *models.SellerRating) error {
+	return WithTx(ctx, func(tx *sqlx.Tx) error {
+		err := tx.GetContext(ctx, rating, `
+			INSERT INTO seller_ratings (order_id, seller_id, buyer_id, shipping_speed, communication, comment, flags)
+			SELECT o.id, $2, o.buyer_id, $4, $5, $6, $7
+			FROM orders o
+			WHERE o.id = $1 AND o.buyer_id = $3 AND EXISTS (
+				SELECT 1 FROM order_items oi JOIN products p ON p.id = oi.product_id
+				WHERE oi.order_id = o.id AND p.seller_id = $2
+					AND (o.status = 'delivered' OR oi.fulfillment_status = 'fulfilled')
+			)
+			RETURNING `+sellerRatingColumns,
+			rating.OrderID, rating.SellerID, rating.BuyerID, rating.ShippingSpeed, rating.Communication, rating.Comment, rating.Flags)
+		if hasErrorCode(err, uniqueViolation) {
+			return ErrAlreadyRated
+		} else if err != sql.ErrNoRows {
+			return err
+		}
+
+		// Nothing was inserted: tell an order that isn't delivered yet from a wrong one
+		var sold bool
+		err = tx.GetContext(ctx, &sold, `
+			SELECT EXISTS (
+				SELECT 1 FROM orders o
+				JOIN order_items oi ON oi.order_id = o.id
+				JOIN products p ON p.id = oi.product_id
+				WHERE o.id = $1 AND o.buyer_id = $3 AND p.seller_id = $2
+			)
+		`, rating.OrderID, rating.SellerID, rating.BuyerID)
+		if err != nil {
+			return err
+		}
+		if sold {
+			return ErrOrderNotDelivered
+		}
+		return sql.ErrNoRows
+	})
+}
+
+// GetSellerRatingSummary returns the averages of a seller's published ratings
+func GetSellerRatingSummary(ctx context.Context, sellerID string) (*models.SellerRatingSummary, error) {
+	var summary models.SellerRatingSummary
+	err := DB.GetContext(ctx, &summary, `
+		SELECT $1::uuid AS seller_id, COUNT(*) AS count,
+			COALESCE(ROUND(AVG(shipping_speed), 2), 0) AS shipping_speed,
+			COALESCE(ROUND(AVG(communication), 2), 0) AS communication,
+			COALESCE(ROUND(AVG((shipping_speed + communication) / 2.0), 2), 0) AS overall
+		FROM seller_ratings
+		WHERE seller_id = $1 AND status = 'published'
+	`, sellerID)
+	if err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// GetSellerRatings returns a page of a seller's published ratings, newest first, and the
+// total count
+func GetSellerRatings(ctx context.Context, sellerID string, limit, offset int) ([]models.SellerRating, int, error) {
+	return FindSellerRatings(ctx, SellerRatingFilter{SellerID: sellerID, Status: models.RatingPublished}, limit, offset)
+}
+
+// FindSellerRatings returns a page of the ratings matching filter, newest first, and the
+// total count
+func FindSellerRatings(ctx context.Context, filter SellerRatingFilter, limit, offset int) ([]models.SellerRating, int, error) {
+	const where = `
+		WHERE ($1 = '' OR seller_id::text = $1) AND ($2 = '' OR status = $2)
+			AND (cardinality(flags) > 0 OR NOT $3)`
+
+	var total int
+	err := DB.GetContext(ctx, &total, `SELECT COUNT(*) FROM seller_ratings`+where, filter.SellerID, filter.Status, filter.FlaggedOnly)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ratings := []models.SellerRating{}
+	err = DB.SelectContext(ctx, &ratings, `
+		SELECT `+sellerRatingColumns+`
+		FROM seller_ratings`+where+`
+		ORDER BY created_at DESC, id
+		LIMIT $4 OFFSET $5
+	`, filter.SellerID, filter.Status, filter.FlaggedOnly, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return ratings, total, nil
+}
+
+// ModerateSellerRating hides a rating or publishes it again, recording the admin's note. The
+// decision is written to the admin audit log in the same transaction. Returns sql.ErrNoRows
+// if the rating doesn't exist.
+func ModerateSellerRating(ctx context.Context, id, status, note string, audit *models.AuditEntry) (*models.SellerRating, error) {
+	var rating models.SellerRating
+	err := WithTx(ctx, func(tx *sqlx.Tx) error {
+		err := tx.GetContext(ctx, &rating, `
+			UPDATE seller_ratings
+			SET status = $2, moderation_note = $3, moderated_by = $4, moderated_at = now(), updated_at = now()
+			WHERE id = $1
+			RETURNING `+sellerRatingColumns,
+			id, status, note, audit.ActorID)
+		if err != nil {
+			return err
+		}
+		return recordAdminAudit(ctx, tx, audit)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rating, nil
+}
+
+// GetSellerQualityStats returns, for every seller, the ratings they received and the orders
+// with their items placed since the given time, with how many of those orders were disputed.
+// Pending and cancelled orders don't count. The score is left for the caller to compute.
+func GetSellerQualityStats(ctx context.Context, since time.Time) ([]models.SellerQuality, error) {
+	stats := []models.SellerQuality{}
+	err := DB.SelectContext(ctx, &stats, `
+		SELECT u.id AS seller_id, u.email,
+			COALESCE(r.ratings, 0) AS ratings, COALESCE(r.average_rating, 0) AS average_rating,
+			COALESCE(r.flagged_ratings, 0) AS flagged_ratings, COALESCE(r.hidden_ratings, 0) AS hidden_ratings,
+			COALESCE(o.orders, 0) AS orders, COALESCE(o.disputes, 0) AS disputes
+		FROM users u
+		LEFT JOIN (
+			SELECT seller_id,
+				COUNT(*) FILTER (WHERE status = 'published') AS ratings,
+				ROUND(AVG((shipping_speed + communication) / 2.0) FILTER (WHERE status = 'published'), 2) AS average_rating,
+				COUNT(*) FILTER (WHERE cardinality(flags) > 0) AS flagged_ratings,
+				COUNT(*) FILTER (WHERE status = 'hidden') AS hidden_ratings
+			FROM seller_ratings
+			WHERE created_at >= $1
+			GROUP BY seller_id
+		) r ON r.seller_id = u.id
+		LEFT JOIN (
+			SELECT sold.seller_id, COUNT(*) AS orders,
+				COUNT(*) FILTER (WHERE EXISTS (SELECT 1 FROM disputes d WHERE d.order_id = sold.order_id)) AS disputes
+			FROM (
+				SELECT DISTINCT p.seller_id, o.id AS order_id
+				FROM orders o
+				JOIN order_items oi ON oi.order_id = o.id
+				JOIN products p ON p.id = oi.product_id
+				WHERE o.created_at >= $1 AND o.status NOT IN ('pending', 'cancelled')
+			) sold
+			GROUP BY sold.seller_id
+		) o ON o.seller_id = u.id
+		WHERE u.role = 'seller'
+		ORDER BY u.email
+	`, since)
+	return stats, err
+}
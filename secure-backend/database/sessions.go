@@ -0,0 +1,46 @@
+package database
+
+import (
+	"context"
+	"secure-backend/models"
+	"time"
+)
+
+// CreateSession stores a new login session
+func CreateSession(ctx context.Context, session *models.Session) error {
+	return DB.QueryRowContext(ctx, `
+		INSERT INTO sessions (token_hash, user_id, email, csrf_token, user_agent, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, session.TokenHash, session.UserID, session.Email, session.CSRFToken, session.UserAgent, session.ExpiresAt).
+		Scan(&session.ID, &session.CreatedAt)
+}
+
+// GetSession returns the unexpired session with the given token hash
+func GetSession(ctx context.Context, tokenHash string, now time.Time) (*models.Session, error) {
+	var session models.Session
+	err := DB.GetContext(ctx, &session, `
+		SELECT id, token_hash, user_id, email, csrf_token, user_agent, expires_at, created_at
+		FROM sessions
+		WHERE token_hash = $1 AND expires_at > $2
+	`, tokenHash, now)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// DeleteSession ends a session (logout)
+func DeleteSession(ctx context.Context, tokenHash string) error {
+	_, err := DB.ExecContext(ctx, `DELETE FROM sessions WHERE token_hash = $1`, tokenHash)
+	return err
+}
+
+// DeleteExpiredSessions removes sessions that expired before now
+func DeleteExpiredSessions(ctx context.Context, now time.Time) (int64, error) {
+	result, err := DB.ExecContext(ctx, `DELETE FROM sessions WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
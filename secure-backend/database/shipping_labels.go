@@ -0,0 +1,137 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"secure-backend/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrLabelExists is returned when the seller already has a label for the order
+	ErrLabelExists = errors.New("a shipping label was already bought for this order")
+	// ErrOrderNotShippable is returned when the order is no longer paid, e.g. it was cancelled
+	ErrOrderNotShippable = errors.New("order is not paid")
+)
+
+// shippingLabelColumns lists the columns selected into models.ShippingLabel
+const shippingLabelColumns = `id, order_id, seller_id, job_id, provider_label_id, carrier, service,
+	tracking_number, cost, currency, storage_key, created_at`
+
+// RecordShippingLabel saves a bought label, charges its cost to the seller's ledger and marks
+// the seller's order items it ships (orderItemIDs) as shipped, in one transaction. The order is
+// locked first and must be paid or partly shipped; otherwise it returns ErrOrderNotShippable.
+func RecordShippingLabel(ctx context.Context, label *models.ShippingLabel, orderItemIDs []string) error {
+	return WithTx(ctx, func(tx *sqlx.Tx) error {
+		var status string
+		err := tx.GetContext(ctx, &status, `SELECT status FROM orders WHERE id = $1 FOR UPDATE`, label.OrderID)
+		if err != nil {
+			return err
+		}
+		if status != "paid" && status != "shipped" {
+			return ErrOrderNotShippable
+		}
+
+		err = tx.QueryRowxContext(ctx, `
+			INSERT INTO shipping_labels (order_id, seller_id, job_id, provider_label_id, carrier, service,
+				tracking_number, cost, currency, storage_key)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			RETURNING id, created_at
+		`, label.OrderID, label.SellerID, label.JobID, label.ProviderLabelID, label.Carrier, label.Service,
+			label.TrackingNumber, label.Cost, label.Currency, label.StorageKey).Scan(&label.ID, &label.CreatedAt)
+		if hasErrorCode(err, uniqueViolation) {
+			return ErrLabelExists
+		} else if err != nil {
+			return err
+		}
+
+		if label.Cost > 0 {
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO seller_ledger_entries (seller_id, amount, currency, reason, order_id, shipping_label_id)
+				VALUES ($1, $2, $3, $4, $5, $6)
+			`, label.SellerID, -label.Cost, label.Currency, models.SellerLedgerShippingLabel, label.OrderID, label.ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE order_items oi
+			SET fulfillment_status = 'shipped'
+			FROM products p
+			WHERE oi.product_id = p.id AND p.seller_id = $1 AND oi.order_id = $2
+				AND oi.id::text = ANY($3) AND oi.fulfillment_status = 'pending'
+		`, label.SellerID, label.OrderID, pq.Array(orderItemIDs))
+		return err
+	})
+}
+
+// GetShippingLabelForOrder returns the seller's label for an order
+func GetShippingLabelForOrder(ctx context.Context, orderID, sellerID string) (*models.ShippingLabel, error) {
+	var label models.ShippingLabel
+	err := DB.GetContext(ctx, &label, `SELECT `+shippingLabelColumns+` FROM shipping_labels WHERE order_id = $1 AND seller_id = $2`,
+		orderID, sellerID)
+	if err != nil {
+		return nil, err
+	}
+	return &label, nil
+}
+
+// GetShippingLabel returns one of the seller's labels
+func GetShippingLabel(ctx context.Context, id, sellerID string) (*models.ShippingLabel, error) {
+	var label models.ShippingLabel
+	err := DB.GetContext(ctx, &label, `SELECT `+shippingLabelColumns+` FROM shipping_labels WHERE id = $1 AND seller_id = $2`,
+		id, sellerID)
+	if err != nil {
+		return nil, err
+	}
+	return &label, nil
+}
+
+// GetShippingLabels returns a page of the seller's labels, newest first, and their total count
+func GetShippingLabels(ctx context.Context, sellerID string, limit, offset int) ([]models.ShippingLabel, int, error) {
+	var total int
+	if err := DB.GetContext(ctx, &total, `SELECT COUNT(*) FROM shipping_labels WHERE seller_id = $1`, sellerID); err != nil {
+		return nil, 0, err
+	}
+
+	labels := []models.ShippingLabel{}
+	err := DB.SelectContext(ctx, &labels, `
+		SELECT `+shippingLabelColumns+` FROM shipping_labels
+		WHERE seller_id = $1
+		ORDER BY created_at DESC, id
+		LIMIT $2 OFFSET $3
+	`, sellerID, limit, offset)
+	return labels, total, err
+}
+
+// GetSellerLedger returns a page of the seller's ledger entries, newest first, their total
+// count and the balance per currency
+func GetSellerLedger(ctx context.Context, sellerID string, limit, offset int) ([]models.SellerLedgerEntry, int, []models.LedgerBalance, error) {
+	var total int
+	if err := DB.GetContext(ctx, &total, `SELECT COUNT(*) FROM seller_ledger_entries WHERE seller_id = $1`, sellerID); err != nil {
+		return nil, 0, nil, err
+	}
+
+	balances := []models.LedgerBalance{}
+	err := DB.SelectContext(ctx, &balances, `
+		SELECT currency, SUM(amount) AS amount FROM seller_ledger_entries
+		WHERE seller_id = $1
+		GROUP BY currency ORDER BY currency
+	`, sellerID)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	entries := []models.SellerLedgerEntry{}
+	err = DB.SelectContext(ctx, &entries, `
+		SELECT id, seller_id, amount, currency, reason, order_id, shipping_label_id, created_at
+		FROM seller_ledger_entries
+		WHERE seller_id = $1
+		ORDER BY created_at DESC, id
+		LIMIT $2 OFFSET $3
+	`, sellerID, limit, offset)
+	return entries, total, balances, err
+}
@@ -0,0 +1,105 @@
+//go:build e2e
+
+// Shipping label tests against a real PostgreSQL database (with schema.sql applied):
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run TestRecordShippingLabel ./database
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"secure-backend/models"
+	"secure-backend/money"
+)
+
+func TestRecordShippingLabel(t *testing.T) {
+	users := createTestUsers(t, "label", "seller", "buyer")
+	sellerID, buyerID := users[0], users[1]
+	ctx := context.Background()
+	t.Cleanup(func() {
+		DB.ExecContext(context.Background(), `DELETE FROM orders WHERE buyer_id = $1`, buyerID)
+	})
+
+	var productID string
+	err := DB.GetContext(ctx, &productID, `
+		INSERT INTO products (name, price, stock, status, seller_id)
+		VALUES ('Labelled product', 5, 10, 'published', $1)
+		RETURNING id
+	`, sellerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// order creates an order in status with one item of the product
+	order := func(status string) (orderID, itemID string) {
+		t.Helper()
+		if err := DB.GetContext(ctx, &orderID, `INSERT INTO orders (buyer_id, status, total_amount) VALUES ($1, $2, 5) RETURNING id`, buyerID, status); err != nil {
+			t.Fatal(err)
+		}
+		err := DB.GetContext(ctx, &itemID, `
+			INSERT INTO order_items (order_id, product_id, quantity, unit_price, total_price) VALUES ($1, $2, 1, 5, 5) RETURNING id
+		`, orderID, productID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return orderID, itemID
+	}
+	label := func(orderID string) *models.ShippingLabel {
+		return &models.ShippingLabel{
+			OrderID: orderID, SellerID: sellerID, ProviderLabelID: "lbl_" + orderID, Carrier: "USPS",
+			Service: "ground", TrackingNumber: "1Z" + orderID[:8], Cost: money.FromFloat(4.5), Currency: "usd",
+			StorageKey: "shipping-labels/" + sellerID + "/" + orderID + ".pdf",
+		}
+	}
+	fulfillment := func(itemID string) string {
+		t.Helper()
+		var status string
+		if err := DB.GetContext(ctx, &status, `SELECT fulfillment_status FROM order_items WHERE id = $1`, itemID); err != nil {
+			t.Fatal(err)
+		}
+		return status
+	}
+	ledger := func() money.Amount {
+		t.Helper()
+		var total money.Amount
+		if err := DB.GetContext(ctx, &total, `SELECT COALESCE(SUM(amount), 0) FROM seller_ledger_entries WHERE seller_id = $1`, sellerID); err != nil {
+			t.Fatal(err)
+		}
+		return total
+	}
+
+	// A label for a paid order ships its items and is charged to the seller
+	paidID, paidItem := order("paid")
+	if err := RecordShippingLabel(ctx, label(paidID), []string{paidItem}); err != nil {
+		t.Fatal(err)
+	}
+	if status := fulfillment(paidItem); status != "shipped" {
+		t.Errorf("item fulfillment = %q, want shipped", status)
+	}
+	if total := ledger(); total != -money.FromFloat(4.5) {
+		t.Errorf("seller ledger = %s, want -4.50", total)
+	}
+	if err := RecordShippingLabel(ctx, label(paidID), []string{paidItem}); !errors.Is(err, ErrLabelExists) {
+		t.Errorf("second label: got %v, want ErrLabelExists", err)
+	}
+
+	// Orders that were cancelled or never paid get no label and no charge
+	for _, status := range []string{"cancelled", "pending"} {
+		orderID, itemID := order(status)
+		if err := RecordShippingLabel(ctx, label(orderID), []string{itemID}); !errors.Is(err, ErrOrderNotShippable) {
+			t.Errorf("%s order: got %v, want ErrOrderNotShippable", status, err)
+		}
+		if _, err := GetShippingLabelForOrder(ctx, orderID, sellerID); err != sql.ErrNoRows {
+			t.Errorf("%s order: label lookup = %v, want sql.ErrNoRows", status, err)
+		}
+		if status := fulfillment(itemID); status != "pending" {
+			t.Errorf("item fulfillment = %q, want pending", status)
+		}
+	}
+	if total := ledger(); total != -money.FromFloat(4.5) {
+		t.Errorf("seller ledger = %s after refused labels, want -4.50", total)
+	}
+}
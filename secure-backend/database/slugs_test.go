@@ -0,0 +1,82 @@
+//go:build e2e
+
+// Product slug tests against a real PostgreSQL database (with schema.sql applied):
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run TestProductSlugs ./database
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"secure-backend/models"
+	"secure-backend/money"
+
+	"github.com/google/uuid"
+)
+
+func TestProductSlugs(t *testing.T) {
+	sellerID := createTestUsers(t, "slugs", "seller")[0]
+	ctx := context.Background()
+	base := "slug-lamp-" + uuid.NewString()[:8]
+
+	create := func(slug string) *models.Product {
+		t.Helper()
+		product := &models.Product{
+			Name: "Slug lamp", Price: money.FromFloat(5), Stock: 1, Status: "draft", SellerID: sellerID, Slug: slug,
+			MetaTitle: "Slug lamp", MetaDescription: "A lamp with a friendly URL",
+		}
+		if err := CreateProduct(ctx, product); err != nil {
+			t.Fatal(err)
+		}
+		return product
+	}
+
+	// Taken slugs get the lowest free numeric suffix; similar slugs don't count as taken
+	create(base + "-kit")
+	first, second, third := create(base), create(base), create(base)
+	for product, want := range map[*models.Product]string{first: base, second: base + "-2", third: base + "-3"} {
+		if product.Slug != want {
+			t.Errorf("slug = %q, want %q", product.Slug, want)
+		}
+	}
+	if _, err := DB.ExecContext(ctx, `DELETE FROM products WHERE id = $1`, second.ID); err != nil {
+		t.Fatal(err)
+	}
+	if fourth := create(base); fourth.Slug != base+"-2" {
+		t.Errorf("slug after a gap = %q, want %q", fourth.Slug, base+"-2")
+	}
+
+	found, err := GetProductBySlug(ctx, base+"-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.ID != third.ID || found.MetaDescription != "A lamp with a friendly URL" {
+		t.Errorf("GetProductBySlug = %s (%q), want %s with its metadata", found.ID, found.MetaDescription, third.ID)
+	}
+
+	// Updates keep the slug unless a new one is given, which must be free
+	third.Slug = ""
+	if err := UpdateProduct(ctx, third); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetProductBySlug(ctx, base+"-3"); err != nil {
+		t.Errorf("slug after an update without one: %v", err)
+	}
+	third.Slug = base
+	if err := UpdateProduct(ctx, third); !errors.Is(err, ErrSlugTaken) {
+		t.Errorf("update to a taken slug: got %v, want ErrSlugTaken", err)
+	}
+	third.Slug = base + "-renamed"
+	if err := UpdateProduct(ctx, third); err != nil {
+		t.Fatal(err)
+	}
+	if found, err := GetProductBySlug(ctx, base+"-renamed"); err != nil || found.ID != third.ID {
+		t.Errorf("GetProductBySlug after rename = %v, %v", found, err)
+	}
+	if _, err := GetProductBySlug(ctx, base+"-3"); err != sql.ErrNoRows {
+		t.Errorf("old slug: got %v, want sql.ErrNoRows", err)
+	}
+}
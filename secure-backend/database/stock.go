@@ -0,0 +1,127 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"secure-backend/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrStockChanged is returned when a stock adjustment's expected stock no longer matches
+var ErrStockChanged = errors.New("stock changed since it was audited")
+
+// recordStockMovement appends a change to a product's stock to the ledger
+func recordStockMovement(ctx context.Context, q sqlx.ExecerContext, movement *models.StockMovement) error {
+	_, err := q.ExecContext(ctx, `
+		INSERT INTO stock_movements (product_id, quantity, reason, order_id, actor_id, note)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, movement.ProductID, movement.Quantity, movement.Reason, movement.OrderID, movement.ActorID, movement.Note)
+	return err
+}
+
+// GetStockMovements returns a page of a product's stock movements (newest first) and the total count
+func GetStockMovements(ctx context.Context, productID string, limit, offset int) ([]models.StockMovement, int, error) {
+	var total int
+	if err := DB.GetContext(ctx, &total, `SELECT COUNT(*) FROM stock_movements WHERE product_id = $1`, productID); err != nil {
+		return nil, 0, err
+	}
+
+	movements := []models.StockMovement{}
+	err := DB.SelectContext(ctx, &movements, `
+		SELECT id, product_id, quantity, reason, order_id, actor_id, note, created_at
+		FROM stock_movements
+		WHERE product_id = $1
+		ORDER BY created_at DESC, id
+		LIMIT $2 OFFSET $3
+	`, productID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return movements, total, nil
+}
+
+// GetStockDiscrepancies cross-checks every product's stock against its movement ledger and
+// the ledgered reservations against stock_reservations, returning the products that disagree
+func GetStockDiscrepancies(ctx context.Context) ([]models.StockDiscrepancy, error) {
+	discrepancies := []models.StockDiscrepancy{}
+	err := DB.SelectContext(ctx, &discrepancies, `
+		SELECT * FROM (
+			SELECT p.id AS product_id, p.name, p.seller_id, p.stock,
+				COALESCE(m.ledger_stock, 0) AS ledger_stock,
+				COALESCE(m.ledger_reserved, 0) AS ledger_reserved,
+				COALESCE(r.reserved, 0) AS reserved
+			FROM products p
+			LEFT JOIN (
+				SELECT product_id, SUM(quantity) AS ledger_stock,
+					-COALESCE(SUM(quantity) FILTER (WHERE reason IN ('reservation', 'release')), 0) AS ledger_reserved
+				FROM stock_movements
+				GROUP BY product_id
+			) m ON m.product_id = p.id
+			LEFT JOIN (
+				SELECT product_id, SUM(quantity) AS reserved
+				FROM stock_reservations
+				WHERE status IN ('active', 'committed')
+				GROUP BY product_id
+			) r ON r.product_id = p.id
+		) audit
+		WHERE stock <> ledger_stock OR ledger_reserved <> reserved
+		ORDER BY product_id
+	`)
+	return discrepancies, err
+}
+
+// AdjustStock sets a product's stock to newStock after an audit and writes the adjustment
+// movement that brings its ledger to the same value, recording audit (its Detail is the
+// reason) in the admin audit log. expectedStock guards against stock that changed since it
+// was audited (ErrStockChanged). Returns sql.ErrNoRows if the product doesn't exist, and the
+// adjustment movement (nil if the ledger already matched).
+func AdjustStock(ctx context.Context, productID string, expectedStock, newStock int, audit *models.AuditEntry) (*models.StockMovement, error) {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var current int
+	err = tx.GetContext(ctx, &current, `SELECT stock FROM products WHERE id = $1 FOR UPDATE`, productID)
+	if err != nil {
+		return nil, err
+	}
+	if current != expectedStock {
+		return nil, ErrStockChanged
+	}
+
+	var ledgerStock int
+	err = tx.GetContext(ctx, &ledgerStock, `SELECT COALESCE(SUM(quantity), 0) FROM stock_movements WHERE product_id = $1`, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE products SET stock = $2, updated_at = now() WHERE id = $1`, productID, newStock); err != nil {
+		return nil, err
+	}
+
+	var movement *models.StockMovement
+	if newStock != ledgerStock {
+		movement = &models.StockMovement{
+			ProductID: productID,
+			Quantity:  newStock - ledgerStock,
+			Reason:    models.MovementAdjustment,
+			ActorID:   audit.ActorID,
+			Note:      audit.Detail,
+		}
+		if err := recordStockMovement(ctx, tx, movement); err != nil {
+			return nil, err
+		}
+	}
+
+	audit.Detail = fmt.Sprintf("product %s: stock %d -> %d (ledger %d): %s", productID, current, newStock, ledgerStock, audit.Detail)
+	if err := recordAdminAudit(ctx, tx, audit); err != nil {
+		return nil, err
+	}
+
+	return movement, tx.Commit()
+}
@@ -0,0 +1,257 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"secure-backend/models"
+	"secure-backend/money"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const storeCreditEntryColumns = `id, user_id, amount, reason, order_id, refund_id, gift_card_id, created_at`
+
+var (
+	// ErrGiftCardInvalid is returned for gift card codes that don't exist, expired or were already redeemed
+	ErrGiftCardInvalid = errors.New("gift card is invalid, expired or already redeemed")
+	// ErrInsufficientCredit is returned when the buyer has no store credit to apply
+	ErrInsufficientCredit = errors.New("insufficient store credit")
+	// ErrOrderNotAwaitingPayment is returned when credit is applied to an order that isn't pending
+	// or that is already covered
+	ErrOrderNotAwaitingPayment = errors.New("order is not awaiting payment")
+	// ErrCardPaymentStarted is returned when credit is applied after a card payment was started,
+	// which could otherwise let the buyer pay the full total by card as well
+	ErrCardPaymentStarted = errors.New("a card payment was already started for this order")
+)
+
+// CreateGiftCard stores a gift card by the hash of its code and records it in the admin audit log
+// in the same transaction
+func CreateGiftCard(ctx context.Context, card *models.GiftCard, codeHash string, audit *models.AuditEntry) (*models.GiftCard, error) {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var created models.GiftCard
+	err = tx.GetContext(ctx, &created, `
+		INSERT INTO gift_cards (code_hash, code_last4, amount, expires_at, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, code_last4, amount, expires_at, redeemed_by, redeemed_at, created_by, created_at
+	`, codeHash, card.CodeLast4, card.Amount, card.ExpiresAt, audit.ActorID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordAdminAudit(ctx, tx, audit); err != nil {
+		return nil, err
+	}
+
+	return &created, tx.Commit()
+}
+
+// RedeemGiftCard marks the gift card with codeHash redeemed by userID and credits its amount to
+// the user's store credit. Each card can be redeemed once.
+func RedeemGiftCard(ctx context.Context, codeHash, userID string, now time.Time) (*models.StoreCreditEntry, error) {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var card struct {
+		ID     string       `db:"id"`
+		Amount money.Amount `db:"amount"`
+	}
+	err = tx.GetContext(ctx, &card, `
+		UPDATE gift_cards SET redeemed_by = $2, redeemed_at = $3
+		WHERE code_hash = $1 AND redeemed_at IS NULL AND (expires_at IS NULL OR expires_at > $3)
+		RETURNING id, amount
+	`, codeHash, userID, now)
+	if err == sql.ErrNoRows {
+		return nil, ErrGiftCardInvalid
+	} else if err != nil {
+		return nil, err
+	}
+
+	var entry models.StoreCreditEntry
+	err = tx.GetContext(ctx, &entry, `
+		INSERT INTO store_credit_entries (user_id, amount, reason, gift_card_id)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+storeCreditEntryColumns+`
+	`, userID, card.Amount, models.StoreCreditGiftCard, card.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry, tx.Commit()
+}
+
+// GetStoreCreditBalance returns the user's available store credit
+func GetStoreCreditBalance(ctx context.Context, userID string) (money.Amount, error) {
+	return storeCreditBalance(ctx, DB, userID)
+}
+
+// storeCreditBalance sums the user's ledger entries
+func storeCreditBalance(ctx context.Context, q sqlx.QueryerContext, userID string) (money.Amount, error) {
+	var balance money.Amount
+	err := sqlx.GetContext(ctx, q, &balance, `SELECT COALESCE(SUM(amount), 0) FROM store_credit_entries WHERE user_id = $1`, userID)
+	return balance, err
+}
+
+// GetStoreCreditEntries returns a page of the user's store credit ledger, newest first, with the total count
+func GetStoreCreditEntries(ctx context.Context, userID string, limit, offset int) ([]models.StoreCreditEntry, int, error) {
+	var total int
+	err := DB.GetContext(ctx, &total, `SELECT COUNT(*) FROM store_credit_entries WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	entries := []models.StoreCreditEntry{}
+	err = DB.SelectContext(ctx, &entries, `
+		SELECT `+storeCreditEntryColumns+`
+		FROM store_credit_entries
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`, userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// ApplyStoreCredit pays up to amount of a buyer's pending order from their store credit
+// (everything that is due if amount is zero), recording it as a store credit payment.
+// The amount is capped by the balance and by what the order still owes; the returned flag
+// reports whether the order is now fully covered.
+func ApplyStoreCredit(ctx context.Context, orderID, userID string, amount money.Amount, currency string) (*models.Payment, bool, error) {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	// Lock the order, then the buyer, so concurrent applications can't overspend either
+	var order struct {
+		Status      string       `db:"status"`
+		TotalAmount money.Amount `db:"total_amount"`
+	}
+	err = tx.GetContext(ctx, &order, `
+		SELECT status, total_amount FROM orders WHERE id = $1 AND buyer_id = $2 FOR UPDATE
+	`, orderID, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	if order.Status != "pending" {
+		return nil, false, ErrOrderNotAwaitingPayment
+	}
+
+	var cardStarted bool
+	err = tx.GetContext(ctx, &cardStarted, `
+		SELECT EXISTS (
+			SELECT 1 FROM payments WHERE order_id = $1 AND provider <> $2 AND status <> 'canceled'
+		)
+	`, orderID, models.PaymentProviderStoreCredit)
+	if err != nil {
+		return nil, false, err
+	}
+	if cardStarted {
+		return nil, false, ErrCardPaymentStarted
+	}
+
+	if _, err := tx.ExecContext(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+		return nil, false, err
+	}
+	balance, err := storeCreditBalance(ctx, tx, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	applied, err := appliedStoreCredit(ctx, tx, orderID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	due := order.TotalAmount - applied
+	if due <= 0 {
+		return nil, false, ErrOrderNotAwaitingPayment
+	}
+	pay := due
+	if amount > 0 && amount < pay {
+		pay = amount
+	}
+	if balance < pay {
+		pay = balance
+	}
+	if pay <= 0 {
+		return nil, false, ErrInsufficientCredit
+	}
+
+	var entryID string
+	err = tx.GetContext(ctx, &entryID, `
+		INSERT INTO store_credit_entries (user_id, amount, reason, order_id)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, userID, -pay, models.StoreCreditPayment, orderID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	payment := &models.Payment{
+		OrderID:           orderID,
+		Provider:          models.PaymentProviderStoreCredit,
+		ProviderPaymentID: entryID,
+		Amount:            pay,
+		Currency:          currency,
+		Status:            "succeeded",
+	}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO payments (order_id, provider, provider_payment_id, amount, currency, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at
+	`, payment.OrderID, payment.Provider, payment.ProviderPaymentID, payment.Amount, payment.Currency, payment.Status).Scan(
+		&payment.ID, &payment.CreatedAt, &payment.UpdatedAt,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	return payment, pay == due, nil
+}
+
+// GetAppliedStoreCredit returns how much of an order is already paid from store credit
+func GetAppliedStoreCredit(ctx context.Context, orderID string) (money.Amount, error) {
+	return appliedStoreCredit(ctx, DB, orderID)
+}
+
+// appliedStoreCredit sums the order's settled store credit payments
+func appliedStoreCredit(ctx context.Context, q sqlx.QueryerContext, orderID string) (money.Amount, error) {
+	var applied money.Amount
+	err := sqlx.GetContext(ctx, q, &applied, `
+		SELECT COALESCE(SUM(amount), 0) FROM payments
+		WHERE order_id = $1 AND provider = $2 AND status = 'succeeded'
+	`, orderID, models.PaymentProviderStoreCredit)
+	return applied, err
+}
+
+// releaseStoreCredit returns the store credit applied to an order that is cancelled before
+// it was paid, in the same transaction as the status change
+func releaseStoreCredit(ctx context.Context, q sqlx.ExecerContext, orderID string) error {
+	_, err := q.ExecContext(ctx, `
+		WITH released AS (
+			UPDATE payments SET status = 'canceled', updated_at = now()
+			WHERE order_id = $1 AND provider = $2 AND status = 'succeeded'
+			RETURNING amount
+		)
+		INSERT INTO store_credit_entries (user_id, amount, reason, order_id)
+		SELECT o.buyer_id, r.amount, $3, $1
+		FROM released r
+		JOIN orders o ON o.id = $1
+	`, orderID, models.PaymentProviderStoreCredit, models.StoreCreditPaymentReleased)
+	return err
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"secure-backend/models"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// recordTaxLine stores the tax included in an order item within the checkout transaction
+func recordTaxLine(ctx context.Context, q sqlx.ExecerContext, line *models.TaxLine) error {
+	_, err := q.ExecContext(ctx, `
+		INSERT INTO order_tax_lines (order_id, order_item_id, seller_id, jurisdiction, rate, gross_amount, taxable_amount, tax_amount)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, line.OrderID, line.OrderItemID, line.SellerID, line.Jurisdiction, line.Rate, line.GrossAmount,
+		line.TaxableAmount, line.TaxAmount)
+	return err
+}
+
+// GetTaxReport adds up the tax collected in [from, to) per jurisdiction and rate. Sales are the
+// tax lines of paid orders placed in the range; refunds issued in the range take back the tax
+// share of the items they refunded, or of the whole order when they refunded a plain amount.
+// A non-empty sellerID limits the report to that seller's items.
+func GetTaxReport(ctx context.Context, from, to time.Time, sellerID string) ([]models.TaxReportRow, error) {
+	rows := []models.TaxReportRow{}
+	err := DB.SelectContext(ctx, &rows, `
+		WITH sales AS (
+			SELECT t.jurisdiction, t.rate, COUNT(DISTINCT t.order_id) AS order_count,
+				SUM(t.gross_amount) AS gross_sales, SUM(t.taxable_amount) AS taxable_sales,
+				SUM(t.tax_amount) AS tax_collected
+			FROM order_tax_lines t
+			JOIN orders o ON o.id = t.order_id
+			WHERE o.created_at >= $1 AND o.created_at < $2 AND ($3 = '' OR t.seller_id::text = $3)
+				AND EXISTS (SELECT 1 FROM order_status_history h WHERE h.order_id = o.id AND h.to_status = 'paid')
+			GROUP BY t.jurisdiction, t.rate
+		),
+		refunded_amounts AS (
+			SELECT ri.order_item_id, ri.amount
+			FROM refund_items ri
+			JOIN refunds r ON r.id = ri.refund_id
+			WHERE r.status = 'succeeded' AND r.created_at >= $1 AND r.created_at < $2
+			UNION ALL
+			SELECT t.order_item_id, r.amount * t.gross_amount / o.total_amount
+			FROM refunds r
+			JOIN orders o ON o.id = r.order_id
+			JOIN order_tax_lines t ON t.order_id = r.order_id
+			WHERE r.status = 'succeeded' AND r.created_at >= $1 AND r.created_at < $2 AND o.total_amount > 0
+				AND NOT EXISTS (SELECT 1 FROM refund_items ri WHERE ri.refund_id = r.id)
+		),
+		refunds AS (
+			SELECT t.jurisdiction, t.rate, SUM(ROUND(ra.amount * t.tax_amount / t.gross_amount, 2)) AS tax_refunded
+			FROM refunded_amounts ra
+			JOIN order_tax_lines t ON t.order_item_id = ra.order_item_id
+			WHERE t.gross_amount > 0 AND ($3 = '' OR t.seller_id::text = $3)
+			GROUP BY t.jurisdiction, t.rate
+		)
+		SELECT jurisdiction, rate, COALESCE(s.order_count, 0) AS order_count,
+			COALESCE(s.gross_sales, 0) AS gross_sales, COALESCE(s.taxable_sales, 0) AS taxable_sales,
+			COALESCE(s.tax_collected, 0) AS tax_collected, COALESCE(f.tax_refunded, 0) AS tax_refunded,
+			0 AS net_tax
+		FROM sales s
+		FULL JOIN refunds f USING (jurisdiction, rate)
+		ORDER BY jurisdiction, rate
+	`, from, to, sellerID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range rows {
+		rows[i].NetTax = rows[i].TaxCollected - rows[i].TaxRefunded
+	}
+	return rows, nil
+}
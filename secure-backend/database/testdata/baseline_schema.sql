@@ -0,0 +1,176 @@
+-- SecureShop Database Schema with Foreign Key Constraints
+-- This should be applied to your Supabase database
+
+-- Users table (core user data)
+CREATE TABLE users (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    email VARCHAR(255) UNIQUE NOT NULL,
+    role VARCHAR(50) NOT NULL DEFAULT 'buyer' CHECK (role IN ('buyer', 'seller', 'admin')),
+    password_hash TEXT, -- For non-Supabase auth (if needed)
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Products table with proper foreign key to users
+CREATE TABLE products (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    price DECIMAL(10,2) NOT NULL CHECK (price >= 0),
+    image_url TEXT, -- URL to image (updated to match frontend usage)
+    stock INTEGER NOT NULL DEFAULT 0 CHECK (stock >= 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'published', 'archived')),
+    seller_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Cart items table
+CREATE TABLE cart_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    UNIQUE(user_id, product_id) -- Prevent duplicate cart items
+);
+
+-- Orders table
+CREATE TABLE orders (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    buyer_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'confirmed', 'shipped', 'delivered', 'cancelled')),
+    total_amount DECIMAL(10,2) NOT NULL CHECK (total_amount >= 0),
+    shipping_address TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Order items table (many-to-many between orders and products)
+CREATE TABLE order_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE RESTRICT, -- Don't allow product deletion if in orders
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    unit_price DECIMAL(10,2) NOT NULL CHECK (unit_price >= 0), -- Price at time of purchase
+    total_price DECIMAL(10,2) NOT NULL CHECK (total_price >= 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+-- Indexes for performance
+CREATE INDEX idx_products_seller_id ON products(seller_id);
+CREATE INDEX idx_products_status ON products(status);
+CREATE INDEX idx_cart_items_user_id ON cart_items(user_id);
+CREATE INDEX idx_orders_buyer_id ON orders(buyer_id);
+CREATE INDEX idx_order_items_order_id ON order_items(order_id);
+CREATE INDEX idx_order_items_product_id ON order_items(product_id);
+
+-- Triggers to update timestamps
+CREATE OR REPLACE FUNCTION update_updated_at_column()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = now();
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER update_users_updated_at BEFORE UPDATE ON users FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_products_updated_at BEFORE UPDATE ON products FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_cart_items_updated_at BEFORE UPDATE ON cart_items FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_orders_updated_at BEFORE UPDATE ON orders FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Enable Row Level Security (RLS) on all tables
+ALTER TABLE users ENABLE ROW LEVEL SECURITY;
+ALTER TABLE products ENABLE ROW LEVEL SECURITY;
+ALTER TABLE cart_items ENABLE ROW LEVEL SECURITY;
+ALTER TABLE orders ENABLE ROW LEVEL SECURITY;
+ALTER TABLE order_items ENABLE ROW LEVEL SECURITY;
+
+-- RLS Policies for users table
+-- Users can read their own profile
+CREATE POLICY "Users can read own profile" ON users
+    FOR SELECT USING (auth.uid() = id);
+
+-- Users can update their own profile
+CREATE POLICY "Users can update own profile" ON users
+    FOR UPDATE USING (auth.uid() = id);
+
+-- RLS Policies for products table
+-- Allow all authenticated users to read published products (buyers, sellers, admins)
+CREATE POLICY "Authenticated users can read published products" ON products
+    FOR SELECT USING (status = 'published');
+
+-- Sellers can read their own products (all statuses)
+CREATE POLICY "Sellers can read own products" ON products
+    FOR SELECT USING (auth.uid() = seller_id);
+
+-- Sellers can create products
+CREATE POLICY "Sellers can create products" ON products
+    FOR INSERT WITH CHECK (auth.uid() = seller_id);
+
+-- Sellers can update their own products
+CREATE POLICY "Sellers can update own products" ON products
+    FOR UPDATE USING (auth.uid() = seller_id);
+
+-- Sellers can delete their own products
+CREATE POLICY "Sellers can delete own products" ON products
+    FOR DELETE USING (auth.uid() = seller_id);
+
+-- RLS Policies for cart_items table
+-- Users can manage their own cart items
+CREATE POLICY "Users can read own cart items" ON cart_items
+    FOR SELECT USING (auth.uid() = user_id);
+
+CREATE POLICY "Users can insert own cart items" ON cart_items
+    FOR INSERT WITH CHECK (auth.uid() = user_id);
+
+CREATE POLICY "Users can update own cart items" ON cart_items
+    FOR UPDATE USING (auth.uid() = user_id);
+
+CREATE POLICY "Users can delete own cart items" ON cart_items
+    FOR DELETE USING (auth.uid() = user_id);
+
+-- RLS Policies for orders table
+-- Users can read their own orders
+CREATE POLICY "Users can read own orders" ON orders
+    FOR SELECT USING (auth.uid() = buyer_id);
+
+-- Users can create their own orders
+CREATE POLICY "Users can create own orders" ON orders
+    FOR INSERT WITH CHECK (auth.uid() = buyer_id);
+
+-- Users can update their own orders (limited scenarios)
+CREATE POLICY "Users can update own orders" ON orders
+    FOR UPDATE USING (auth.uid() = buyer_id);
+
+-- RLS Policies for order_items table
+-- Users can read order items for their own orders
+CREATE POLICY "Users can read own order items" ON order_items
+    FOR SELECT USING (
+        EXISTS (
+            SELECT 1 FROM orders 
+            WHERE orders.id = order_items.order_id 
+            AND orders.buyer_id = auth.uid()
+        )
+    );
+
+-- Sellers can read order items for their products
+CREATE POLICY "Sellers can read order items for own products" ON order_items
+    FOR SELECT USING (
+        EXISTS (
+            SELECT 1 FROM products 
+            WHERE products.id = order_items.product_id 
+            AND products.seller_id = auth.uid()
+        )
+    );
+
+-- Users can create order items for their own orders
+CREATE POLICY "Users can create own order items" ON order_items
+    FOR INSERT WITH CHECK (
+        EXISTS (
+            SELECT 1 FROM orders 
+            WHERE orders.id = order_items.order_id 
+            AND orders.buyer_id = auth.uid()
+        )
+    );
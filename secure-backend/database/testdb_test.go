@@ -0,0 +1,50 @@
+//go:build e2e
+
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// initTestDB connects to TEST_DATABASE_URL, skipping the test if it isn't set
+func initTestDB(tb testing.TB) {
+	tb.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		tb.Skip("TEST_DATABASE_URL not set")
+	}
+	if DB == nil {
+		tb.Setenv("DATABASE_URL", dsn)
+		if err := InitDB(); err != nil {
+			tb.Fatalf("failed to connect to test database: %v", err)
+		}
+	}
+}
+
+// createTestUsers connects to the test database and creates a user per role with an email
+// unique to the run, returning their IDs. The users are deleted when the test ends; cleanups
+// registered afterwards run first, so tests can delete rows that would keep them.
+func createTestUsers(tb testing.TB, prefix string, roles ...string) []string {
+	tb.Helper()
+	initTestDB(tb)
+
+	suffix := uuid.NewString()[:8]
+	ids := make([]string, len(roles))
+	for i, role := range roles {
+		email := fmt.Sprintf("%s-%s-%d-%s@example.com", prefix, role, i, suffix)
+		if err := DB.GetContext(context.Background(), &ids[i], `INSERT INTO users (email, role) VALUES ($1, $2) RETURNING id`, email, role); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	tb.Cleanup(func() {
+		DB.ExecContext(context.Background(), `DELETE FROM users WHERE id = ANY($1)`, pq.Array(ids))
+	})
+	return ids
+}
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"secure-backend/tracing"
+	"time"
+)
+
+// maxTracedStatement caps the statement text recorded on database spans and slow query logs
+const maxTracedStatement = 2048
+
+// tracedConnector opens connections whose queries are recorded as child spans of the
+// span in their context, timed and logged when slow. Statements are recorded with their
+// placeholders, never the argument values.
+type tracedConnector struct {
+	driver.Connector
+}
+
+// Connect implements driver.Connector
+func (t tracedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := t.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if statementTimeout > 0 {
+		if err := setStatementTimeout(ctx, conn, statementTimeout); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return &tracedConn{Conn: conn}, nil
+}
+
+// setStatementTimeout makes Postgres cancel the connection's statements that run longer than
+// timeout, as a backstop for queries whose context has no deadline
+func setStatementTimeout(ctx context.Context, conn driver.Conn, timeout time.Duration) error {
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		return nil
+	}
+	_, err := execer.ExecContext(ctx, fmt.Sprintf("SET statement_timeout = %d", timeout.Milliseconds()), nil)
+	return err
+}
+
+// tracedConn wraps a lib/pq connection. Only the context-aware query, exec and begin
+// calls are traced; database/sql uses them whenever the driver provides them.
+type tracedConn struct {
+	driver.Conn
+}
+
+// QueryContext implements driver.QueryerContext
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	observeQuery(ctx, query, len(args), time.Since(start), err)
+	span.SetError(err)
+	return rows, err
+}
+
+// ExecContext implements driver.ExecerContext
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+	start := time.Now()
+	result, err := execer.ExecContext(ctx, query, args)
+	observeQuery(ctx, query, len(args), time.Since(start), err)
+	span.SetError(err)
+	return result, err
+}
+
+// PrepareContext implements driver.ConnPrepareContext
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+// BeginTx implements driver.ConnBeginTx
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	beginner, ok := c.Conn.(driver.ConnBeginTx)
+	if !ok {
+		return c.Conn.Begin()
+	}
+	ctx, span := tracing.StartChild(ctx, "db BEGIN", tracing.KindClient)
+	defer span.End()
+	span.SetAttribute("db.system", "postgresql")
+	tx, err := beginner.BeginTx(ctx, opts)
+	span.SetError(err)
+	return tx, err
+}
+
+// Ping implements driver.Pinger
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// ResetSession implements driver.SessionResetter
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+// IsValid implements driver.Validator
+func (c *tracedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// startQuerySpan starts the span of one statement, named after its operation ("db SELECT")
+func startQuerySpan(ctx context.Context, query string) (context.Context, *tracing.Span) {
+	operation := queryOperation(query)
+	ctx, span := tracing.StartChild(ctx, "db "+operation, tracing.KindClient)
+	if span == nil {
+		return ctx, nil
+	}
+
+	span.SetAttribute("db.system", "postgresql")
+	span.SetAttribute("db.operation.name", operation)
+	span.SetAttribute("db.query.text", queryStatement(query))
+	return ctx, span
+}
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// execConn records the statements executed on it
+type execConn struct {
+	driver.Conn
+	statements []string
+}
+
+func (c *execConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.statements = append(c.statements, query)
+	return driver.RowsAffected(0), nil
+}
+
+func TestStatementTimeout(t *testing.T) {
+	t.Setenv("DB_STATEMENT_TIMEOUT", "")
+	assert.Equal(t, defaultStatementTimeout, StatementTimeout())
+	t.Setenv("DB_STATEMENT_TIMEOUT", "1500ms")
+	assert.Equal(t, 1500*time.Millisecond, StatementTimeout())
+	t.Setenv("DB_STATEMENT_TIMEOUT", "0")
+	assert.Equal(t, time.Duration(0), StatementTimeout())
+	t.Setenv("DB_STATEMENT_TIMEOUT", "-1s")
+	assert.Equal(t, defaultStatementTimeout, StatementTimeout())
+
+	conn := &execConn{}
+	require.NoError(t, setStatementTimeout(context.Background(), conn, 1500*time.Millisecond))
+	assert.Equal(t, []string{"SET statement_timeout = 1500"}, conn.statements)
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// maxTxAttempts is how many times WithTx runs a transaction that Postgres aborts because of
+// a serialization failure or deadlock
+const maxTxAttempts = 3
+
+// txRetryDelay is the wait before the second attempt; it doubles for each one after
+var txRetryDelay = 20 * time.Millisecond
+
+// WithTx runs fn in a transaction, committing it if fn returns nil and rolling it back
+// otherwise; fn's error is returned as it is. When Postgres aborts the transaction because of
+// a serialization failure or a deadlock, the whole transaction is run again, up to
+// maxTxAttempts times. fn must therefore only change the database through tx, and must
+// reset any results it collects when it starts.
+func WithTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	delay := txRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := runTx(ctx, fn)
+		if err == nil || attempt == maxTxAttempts || !retryableTxError(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func runTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// retryableTxError reports whether err aborted a transaction that may succeed if run again:
+// a serialization failure or a deadlock
+func retryableTxError(err error) bool {
+	return hasErrorCode(err, serializationFailure) || hasErrorCode(err, deadlockDetected)
+}
@@ -0,0 +1,20 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryableTxError(t *testing.T) {
+	assert.True(t, retryableTxError(&pq.Error{Code: "40001"}), "serialization failure")
+	assert.True(t, retryableTxError(&pq.Error{Code: "40P01"}), "deadlock")
+	assert.True(t, retryableTxError(fmt.Errorf("checkout: %w", &pq.Error{Code: "40001"})), "wrapped")
+
+	assert.False(t, retryableTxError(&pq.Error{Code: "23505"}), "unique violation")
+	assert.False(t, retryableTxError(sql.ErrNoRows))
+	assert.False(t, retryableTxError(ErrCartEmpty))
+}
@@ -1,14 +1,17 @@
 package database
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"secure-backend/models"
+	"time"
 )
 
 // GetUserByID retrieves a user by their ID
-func GetUserByID(id string) (*models.User, error) {
+func GetUserByID(ctx context.Context, id string) (*models.User, error) {
 	var user models.User
-	err := DB.Get(&user, `SELECT id, email, role FROM users WHERE id = $1`, id)
+	err := DB.GetContext(ctx, &user, `SELECT id, email, role FROM users WHERE id = $1`, id)
 	if err != nil {
 		return nil, err
 	}
@@ -16,9 +19,9 @@ func GetUserByID(id string) (*models.User, error) {
 }
 
 // GetUserByEmail retrieves a user by their email
-func GetUserByEmail(email string) (*models.User, error) {
+func GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
 	var user models.User
-	err := DB.Get(&user, `SELECT id, email, role FROM users WHERE email = $1`, email)
+	err := DB.GetContext(ctx, &user, `SELECT id, email, role FROM users WHERE email = $1`, email)
 	if err != nil {
 		return nil, err
 	}
@@ -26,9 +29,9 @@ func GetUserByEmail(email string) (*models.User, error) {
 }
 
 // GetUserRole fetches a user's role from the users table
-func GetUserRole(userID string) (string, error) {
+func GetUserRole(ctx context.Context, userID string) (string, error) {
 	var role string
-	err := DB.Get(&role, "SELECT role FROM users WHERE id = $1", userID)
+	err := DB.GetContext(ctx, &role, "SELECT role FROM users WHERE id = $1", userID)
 	if err != nil && err.Error() == "sql: no rows in result set" {
 		// If no user is found, this is an error - user should exist
 		return "", errors.New("user not found")
@@ -38,3 +41,118 @@ func GetUserRole(userID string) (string, error) {
 	}
 	return role, nil
 }
+
+// GetSellerSettings retrieves the order rules of a seller
+func GetSellerSettings(ctx context.Context, sellerID string) (*models.SellerSettings, error) {
+	var settings models.SellerSettings
+	err := DB.GetContext(ctx, &settings, `SELECT min_order_value FROM users WHERE id = $1`, sellerID)
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// UpdateSellerSettings stores the order rules of a seller
+func UpdateSellerSettings(ctx context.Context, sellerID string, settings *models.SellerSettings) error {
+	result, err := DB.ExecContext(ctx, `
+		UPDATE users SET min_order_value = $2, updated_at = now()
+		WHERE id = $1
+	`, sellerID, settings.MinOrderValue)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// UpdateUserRole sets a user's role and records the change in the admin audit log in the
+// same transaction. Returns sql.ErrNoRows if the user doesn't exist.
+func UpdateUserRole(ctx context.Context, userID, role string, audit *models.AuditEntry) error {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE users SET role = $2, updated_at = now() WHERE id = $1`, userID, role)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	if err := recordAdminAudit(ctx, tx, audit); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RevokeUserTokens refuses the user's access tokens issued before the given time and ends
+// their cookie sessions started before it, recording the revocation in the admin audit log in
+// the same transaction. An earlier cut-off never replaces a later one; the effective cut-off is
+// returned. Returns sql.ErrNoRows if the user doesn't exist.
+func RevokeUserTokens(ctx context.Context, userID string, before time.Time, reason string, audit *models.AuditEntry) (time.Time, error) {
+	tx, err := DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.GetContext(ctx, &exists, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, userID); err != nil {
+		return time.Time{}, err
+	}
+	if !exists {
+		return time.Time{}, sql.ErrNoRows
+	}
+
+	var revokedBefore time.Time
+	err = tx.GetContext(ctx, &revokedBefore, `
+		INSERT INTO token_revocations (user_id, revoked_before, reason, revoked_by)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE SET
+			revoked_before = GREATEST(token_revocations.revoked_before, EXCLUDED.revoked_before),
+			reason = EXCLUDED.reason,
+			revoked_by = EXCLUDED.revoked_by
+		RETURNING revoked_before
+	`, userID, before, reason, audit.ActorID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = $1 AND created_at < $2`, userID, revokedBefore); err != nil {
+		return time.Time{}, err
+	}
+
+	if err := recordAdminAudit(ctx, tx, audit); err != nil {
+		return time.Time{}, err
+	}
+	return revokedBefore, tx.Commit()
+}
+
+// GetTokenRevokedBefore returns the cut-off before which the user's access tokens are refused,
+// or nil if their tokens were never revoked
+func GetTokenRevokedBefore(ctx context.Context, userID string) (*time.Time, error) {
+	var revokedBefore time.Time
+	err := DB.GetContext(ctx, &revokedBefore, `SELECT revoked_before FROM token_revocations WHERE user_id = $1`, userID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &revokedBefore, nil
+}
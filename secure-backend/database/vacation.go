@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"secure-backend/models"
+
+	"github.com/lib/pq"
+)
+
+// vacationColumns lists the user columns selected into models.SellerVacation
+const vacationColumns = `vacation_starts_at, vacation_ends_at, vacation_message, vacation_hide_listings`
+
+// hiddenSellers returns a subquery selecting the sellers whose listings an active vacation
+// hides at the time bound to parameter n
+func hiddenSellers(n int) string {
+	return fmt.Sprintf(`SELECT id FROM users WHERE vacation_hide_listings
+		AND vacation_starts_at <= $%[1]d AND (vacation_ends_at IS NULL OR vacation_ends_at > $%[1]d)`, n)
+}
+
+// GetSellerVacation returns a seller's vacation settings
+func GetSellerVacation(ctx context.Context, sellerID string) (*models.SellerVacation, error) {
+	var vacation models.SellerVacation
+	err := DB.GetContext(ctx, &vacation, `SELECT `+vacationColumns+` FROM users WHERE id = $1`, sellerID)
+	if err != nil {
+		return nil, err
+	}
+	return &vacation, nil
+}
+
+// GetSellerVacations returns the vacations scheduled by the given sellers, by seller ID.
+// Sellers without a vacation are left out.
+func GetSellerVacations(ctx context.Context, sellerIDs []string) (map[string]*models.SellerVacation, error) {
+	var rows []struct {
+		SellerID string `db:"id"`
+		models.SellerVacation
+	}
+	err := DB.SelectContext(ctx, &rows, `
+		SELECT id, `+vacationColumns+`
+		FROM users
+		WHERE id::text = ANY($1) AND vacation_starts_at IS NOT NULL
+	`, pq.Array(sellerIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	vacations := make(map[string]*models.SellerVacation, len(rows))
+	for i := range rows {
+		vacations[rows[i].SellerID] = &rows[i].SellerVacation
+	}
+	return vacations, nil
+}
+
+// SetSellerVacation schedules a seller's vacation, replacing any previous one
+func SetSellerVacation(ctx context.Context, sellerID string, vacation *models.SellerVacation) error {
+	result, err := DB.ExecContext(ctx, `
+		UPDATE users
+		SET vacation_starts_at = $2, vacation_ends_at = $3, vacation_message = $4,
+			vacation_hide_listings = $5, updated_at = now()
+		WHERE id = $1
+	`, sellerID, vacation.StartsAt, vacation.EndsAt, vacation.Message, vacation.HideListings)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package database
+
+import "context"
+import "secure-backend/models"
+
+// RecordWebhookEvent stores a received webhook event (or counts a redelivery) and reports
+// whether it was already processed successfully, providing idempotency and replay protection
+func RecordWebhookEvent(ctx context.Context, provider, eventID, eventType string, payload []byte) (bool, error) {
+	var processed bool
+	err := DB.GetContext(ctx, &processed, `
+		INSERT INTO webhook_events (provider, event_id, event_type, payload)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (provider, event_id) DO UPDATE
+		SET attempts = webhook_events.attempts + 1, updated_at = now()
+		RETURNING processed_at IS NOT NULL
+	`, provider, eventID, eventType, payload)
+	return processed, err
+}
+
+// MarkWebhookEventProcessed records that a webhook event was handled successfully
+func MarkWebhookEventProcessed(ctx context.Context, provider, eventID string) error {
+	_, err := DB.ExecContext(ctx, `
+		UPDATE webhook_events SET processed_at = now(), last_error = NULL, discarded_at = NULL, updated_at = now()
+		WHERE provider = $1 AND event_id = $2
+	`, provider, eventID)
+	return err
+}
+
+// MarkWebhookEventFailed records the error of a failed webhook event so it can be retried
+func MarkWebhookEventFailed(ctx context.Context, provider, eventID, errMsg string) error {
+	_, err := DB.ExecContext(ctx, `
+		WITH failed AS (
+			UPDATE webhook_events SET last_error = $3, updated_at = now()
+			WHERE provider = $1 AND event_id = $2
+			RETURNING id, attempts
+		)
+		INSERT INTO delivery_errors (source, item_id, attempt, error)
+		SELECT '`+DeadLetterWebhooks+`', id, attempts, $3 FROM failed
+	`, provider, eventID, errMsg)
+	return err
+}
+
+// GetWebhookEventByID retrieves a stored webhook event by its row ID
+func GetWebhookEventByID(ctx context.Context, id string) (*models.WebhookEvent, error) {
+	var event models.WebhookEvent
+	err := DB.GetContext(ctx, &event, `
+		SELECT id, provider, event_id, event_type, payload, attempts, COALESCE(last_error, '') AS last_error,
+			processed_at, discarded_at, created_at, updated_at
+		FROM webhook_events
+		WHERE id = $1
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
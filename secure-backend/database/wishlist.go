@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"secure-backend/models"
+	"secure-backend/money"
+)
+
+// wishlistItemColumns lists the wishlist item columns selected into models.WishlistItem
+const wishlistItemColumns = `id, user_id, product_id, price_alert, target_price, alert_price, last_alerted_at, created_at, updated_at`
+
+// GetWishlist retrieves the user's wishlist with product details, newest first
+func GetWishlist(ctx context.Context, userID string) ([]models.WishlistItemWithProduct, error) {
+	items := []models.WishlistItemWithProduct{}
+
+	rows, err := DB.QueryContext(ctx, `
+		SELECT
+			wi.id, wi.user_id, wi.product_id, wi.price_alert, wi.target_price, wi.alert_price,
+			wi.last_alerted_at, wi.created_at, wi.updated_at,
+			p.id, p.name, p.description, p.price, p.image, p.stock, p.status, p.seller_id, p.created_at, p.updated_at
+		FROM wishlist_items wi
+		JOIN products p ON wi.product_id = p.id
+		WHERE wi.user_id = $1
+		ORDER BY wi.created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.WishlistItemWithProduct
+		err := rows.Scan(
+			&item.ID, &item.UserID, &item.ProductID, &item.PriceAlert, &item.TargetPrice, &item.AlertPrice,
+			&item.LastAlertedAt, &item.CreatedAt, &item.UpdatedAt,
+			&item.Product.ID, &item.Product.Name, &item.Product.Description, &item.Product.Price,
+			&item.Product.Image, &item.Product.Stock, &item.Product.Status, &item.Product.SellerID,
+			&item.Product.CreatedAt, &item.Product.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// AddToWishlist adds a product to the user's wishlist, or updates its price alert settings
+// if it is already there. Price drops are measured from the product's current price.
+func AddToWishlist(ctx context.Context, userID, productID string, priceAlert bool, targetPrice *money.Amount) (*models.WishlistItem, error) {
+	var item models.WishlistItem
+	err := DB.GetContext(ctx, &item, `
+		INSERT INTO wishlist_items (user_id, product_id, price_alert, target_price, alert_price)
+		SELECT $1, id, $3, $4, price FROM products WHERE id = $2
+		ON CONFLICT (user_id, product_id) DO UPDATE
+		SET price_alert = EXCLUDED.price_alert, target_price = EXCLUDED.target_price,
+			alert_price = EXCLUDED.alert_price
+		RETURNING `+wishlistItemColumns,
+		userID, productID, priceAlert, targetPrice)
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// UpdateWishlistItem changes the price alert settings of one of the user's wishlist items.
+// Turning the alert on measures price drops from the product's current price.
+func UpdateWishlistItem(ctx context.Context, itemID, userID string, priceAlert bool, targetPrice *money.Amount) (*models.WishlistItem, error) {
+	var item models.WishlistItem
+	err := DB.GetContext(ctx, &item, `
+		UPDATE wishlist_items wi
+		SET price_alert = $3, target_price = $4,
+			alert_price = CASE WHEN $3 AND NOT wi.price_alert THEN p.price ELSE wi.alert_price END
+		FROM products p
+		WHERE wi.id = $1 AND wi.user_id = $2 AND p.id = wi.product_id
+		RETURNING wi.id, wi.user_id, wi.product_id, wi.price_alert, wi.target_price, wi.alert_price,
+			wi.last_alerted_at, wi.created_at, wi.updated_at
+	`, itemID, userID, priceAlert, targetPrice)
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// RemoveFromWishlist deletes one of the user's wishlist items
+func RemoveFromWishlist(ctx context.Context, itemID, userID string) error {
+	return execOwned(ctx, DB, `DELETE FROM wishlist_items WHERE id = $1 AND user_id = $2`, itemID, userID)
+}
+
+// ClaimPriceDropAlerts returns the price alerts a product's new price triggers: wishlist
+// items whose alert price is above it (and whose target price, if any, it reaches). Their
+// alert price is lowered to the new price in the same statement, so each drop alerts once.
+func ClaimPriceDropAlerts(ctx context.Context, productID string, price money.Amount) ([]models.PriceDropAlert, error) {
+	alerts := []models.PriceDropAlert{}
+	err := DB.SelectContext(ctx, &alerts, `
+		WITH triggered AS (
+			SELECT id, alert_price
+			FROM wishlist_items
+			WHERE product_id = $1 AND price_alert AND alert_price > $2
+				AND (target_price IS NULL OR $2 <= target_price)
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE wishlist_items wi
+		SET alert_price = $2, last_alerted_at = now()
+		FROM triggered t
+		WHERE wi.id = t.id
+		RETURNING wi.user_id, wi.product_id, t.alert_price AS previous_price, wi.alert_price AS price
+	`, productID, price)
+	return alerts, err
+}
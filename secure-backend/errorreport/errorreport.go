@@ -0,0 +1,114 @@
+// Package errorreport sends server errors and panics to an error tracker with the context of
+// the request that hit them. Reporters are pluggable; Init installs the Sentry reporter when
+// SENTRY_DSN is set, and reports are dropped while no reporter is installed.
+package errorreport
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Report levels
+const (
+	LevelError = "error"
+	LevelFatal = "fatal" // a panic
+)
+
+// Frame is one call in a stack trace
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+// Event is an error or panic to report. Request fields are empty for errors outside
+// requests. Only the route is reported, never the raw path or query, since those can hold
+// signed tokens.
+type Event struct {
+	Level     string
+	Message   string
+	ErrorType string  // e.g. the panic value's type
+	Stack     []Frame // innermost call first; empty for plain 5xx responses
+	Time      time.Time
+
+	RequestID string
+	UserID    string
+	Method    string
+	Route     string
+	Status    int
+	TraceID   string
+}
+
+// Reporter delivers events to an error tracker. Report must not block the request.
+type Reporter interface {
+	Report(event *Event)
+	// Flush waits until reported events are delivered or ctx is done
+	Flush(ctx context.Context) error
+}
+
+var (
+	mu       sync.RWMutex
+	reporter Reporter
+)
+
+// SetReporter installs the reporter events go to; nil turns reporting off
+func SetReporter(r Reporter) {
+	mu.Lock()
+	defer mu.Unlock()
+	reporter = r
+}
+
+// Enabled reports whether a reporter is installed
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return reporter != nil
+}
+
+// Report sends an event to the installed reporter, if any
+func Report(event *Event) {
+	mu.RLock()
+	r := reporter
+	mu.RUnlock()
+	if r == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	r.Report(event)
+}
+
+// Flush waits for reported events to be delivered, e.g. before the process exits
+func Flush(ctx context.Context) error {
+	mu.RLock()
+	r := reporter
+	mu.RUnlock()
+	if r == nil {
+		return nil
+	}
+	return r.Flush(ctx)
+}
+
+// Stack returns the calling goroutine's stack, innermost call first, leaving out skip
+// callers above Stack's caller and the runtime's own frames (such as panic handling)
+func Stack(skip int) []Frame {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var stack []Frame
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "runtime.") {
+			stack = append(stack, Frame{Function: frame.Function, File: frame.File, Line: frame.Line})
+		}
+		if !more {
+			break
+		}
+	}
+	return stack
+}
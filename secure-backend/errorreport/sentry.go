@@ -0,0 +1,221 @@
+package errorreport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"secure-backend/idgen"
+	"secure-backend/outbound"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// sentryQueueSize caps the events waiting to be sent; events reported while it is full are dropped
+	sentryQueueSize = 100
+	// sentryClientName identifies this client to Sentry
+	sentryClientName = "secure-backend/1.0"
+)
+
+// Init installs the Sentry reporter when SENTRY_DSN is set. SENTRY_ENVIRONMENT and
+// SENTRY_RELEASE tag the events.
+func Init() error {
+	dsn := os.Getenv("SENTRY_DSN")
+	if dsn == "" {
+		return nil
+	}
+	r, err := NewSentryReporter(dsn, os.Getenv("SENTRY_ENVIRONMENT"), os.Getenv("SENTRY_RELEASE"))
+	if err != nil {
+		return err
+	}
+	SetReporter(r)
+	log.Printf("Error reporting to Sentry enabled")
+	return nil
+}
+
+// SentryReporter sends events to Sentry's envelope endpoint in the background
+type SentryReporter struct {
+	endpoint    string
+	auth        string
+	dsn         string
+	environment string
+	release     string
+	serverName  string
+	client      *http.Client
+	ids         idgen.Generator
+
+	queue   chan *Event
+	pending sync.WaitGroup
+}
+
+// NewSentryReporter returns a reporter for a Sentry DSN
+// ("https://<public key>@<host>/<project id>")
+func NewSentryReporter(dsn, environment, release string) (*SentryReporter, error) {
+	u, err := url.Parse(dsn)
+	if err != nil || u.User == nil || u.User.Username() == "" || u.Host == "" {
+		return nil, errors.New("invalid SENTRY_DSN")
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	slash := strings.LastIndex(path, "/")
+	project := path[slash+1:]
+	if _, err := strconv.ParseUint(project, 10, 64); err != nil {
+		return nil, errors.New("invalid SENTRY_DSN: no project ID")
+	}
+
+	hostname, _ := os.Hostname()
+	r := &SentryReporter{
+		endpoint:    fmt.Sprintf("%s://%s%s/api/%s/envelope/", u.Scheme, u.Host, path[:slash], project),
+		auth:        fmt.Sprintf("Sentry sentry_version=7, sentry_client=%s, sentry_key=%s", sentryClientName, u.User.Username()),
+		dsn:         dsn,
+		environment: environment,
+		release:     release,
+		serverName:  hostname,
+		client:      outbound.NewClient("sentry", 10*time.Second),
+		ids:         idgen.UUID(),
+		queue:       make(chan *Event, sentryQueueSize),
+	}
+	go r.run()
+	return r, nil
+}
+
+// Report implements Reporter
+func (r *SentryReporter) Report(event *Event) {
+	r.pending.Add(1)
+	select {
+	case r.queue <- event:
+	default:
+		r.pending.Done()
+		log.Printf("Error report queue is full, dropping report: %s", event.Message)
+	}
+}
+
+// Flush implements Reporter
+func (r *SentryReporter) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		r.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run sends queued events one at a time
+func (r *SentryReporter) run() {
+	for event := range r.queue {
+		if err := r.send(event); err != nil {
+			log.Printf("Failed to send error report to Sentry: %v", err)
+		}
+		r.pending.Done()
+	}
+}
+
+// send posts one event as an envelope: a header line, an item header line and the event
+func (r *SentryReporter) send(event *Event) error {
+	eventID := strings.ReplaceAll(r.ids.NewID(), "-", "")
+	payload, err := json.Marshal(r.sentryEvent(eventID, event))
+	if err != nil {
+		return err
+	}
+	header, err := json.Marshal(map[string]string{
+		"event_id": eventID, "dsn": r.dsn, "sent_at": time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	body.Write(header)
+	fmt.Fprintf(&body, "\n{\"type\":\"event\",\"length\":%d}\n", len(payload))
+	body.Write(payload)
+
+	req, err := http.NewRequest(http.MethodPost, r.endpoint, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", r.auth)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("sentry returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sentryEvent converts an event to Sentry's event payload
+func (r *SentryReporter) sentryEvent(eventID string, event *Event) map[string]interface{} {
+	tags := map[string]string{}
+	if event.Route != "" {
+		tags["route"] = event.Route
+		tags["http.method"] = event.Method
+	}
+	if event.Status != 0 {
+		tags["http.status_code"] = strconv.Itoa(event.Status)
+	}
+	if event.RequestID != "" {
+		tags["request_id"] = event.RequestID
+	}
+
+	errorType := event.ErrorType
+	if errorType == "" {
+		errorType = "error"
+	}
+	exception := map[string]interface{}{"type": errorType, "value": event.Message}
+	if len(event.Stack) > 0 {
+		// Sentry lists frames outermost first
+		frames := make([]map[string]interface{}, 0, len(event.Stack))
+		for i := len(event.Stack) - 1; i >= 0; i-- {
+			frame := event.Stack[i]
+			frames = append(frames, map[string]interface{}{
+				"function": frame.Function,
+				"abs_path": frame.File,
+				"lineno":   frame.Line,
+				"in_app":   strings.HasPrefix(frame.Function, "secure-backend/") || strings.HasPrefix(frame.Function, "main."),
+			})
+		}
+		exception["stacktrace"] = map[string]interface{}{"frames": frames}
+	}
+
+	payload := map[string]interface{}{
+		"event_id":    eventID,
+		"timestamp":   event.Time.UTC().Format(time.RFC3339Nano),
+		"level":       event.Level,
+		"platform":    "go",
+		"server_name": r.serverName,
+		"exception":   map[string]interface{}{"values": []interface{}{exception}},
+		"tags":        tags,
+	}
+	if event.Route != "" {
+		payload["transaction"] = event.Method + " " + event.Route
+	}
+	if r.environment != "" {
+		payload["environment"] = r.environment
+	}
+	if r.release != "" {
+		payload["release"] = r.release
+	}
+	if event.UserID != "" {
+		payload["user"] = map[string]string{"id": event.UserID}
+	}
+	if event.TraceID != "" {
+		payload["contexts"] = map[string]interface{}{"trace": map[string]string{"trace_id": event.TraceID}}
+	}
+	return payload
+}
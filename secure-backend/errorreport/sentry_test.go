@@ -0,0 +1,106 @@
+package errorreport
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSentryReporterParsesDSN(t *testing.T) {
+	r, err := NewSentryReporter("https://public@sentry.example.com/errors/42", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, "https://sentry.example.com/errors/api/42/envelope/", r.endpoint)
+	assert.Contains(t, r.auth, "sentry_key=public")
+
+	for _, dsn := range []string{"", "https://sentry.example.com/42", "https://public@sentry.example.com/", "::"} {
+		_, err := NewSentryReporter(dsn, "", "")
+		assert.Error(t, err, dsn)
+	}
+}
+
+func TestSentryReporterSendsEnvelope(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		auth      string
+		envelopes [][]string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/7/envelope/", r.URL.Path)
+		var lines []string
+		scanner := bufio.NewScanner(r.Body)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		mu.Lock()
+		auth = r.Header.Get("X-Sentry-Auth")
+		envelopes = append(envelopes, lines)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	r, err := NewSentryReporter(strings.Replace(srv.URL, "://", "://key@", 1)+"/7", "staging", "v1.2.3")
+	require.NoError(t, err)
+	SetReporter(r)
+	t.Cleanup(func() { SetReporter(nil) })
+
+	Report(&Event{
+		Level: LevelFatal, Message: "boom", ErrorType: "string",
+		Stack: []Frame{
+			{Function: "secure-backend/handlers.GetOrder", File: "handlers/orders.go", Line: 10},
+			{Function: "github.com/gin-gonic/gin.(*Context).Next", File: "context.go", Line: 185},
+		},
+		RequestID: "req-1", UserID: "user-1", Method: "GET", Route: "/api/orders/:id", Status: 500,
+		TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, Flush(ctx))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, envelopes, 1)
+	assert.Contains(t, auth, "sentry_key=key")
+	require.Len(t, envelopes[0], 3)
+
+	var header, item, event map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(envelopes[0][0]), &header))
+	require.NoError(t, json.Unmarshal([]byte(envelopes[0][1]), &item))
+	require.NoError(t, json.Unmarshal([]byte(envelopes[0][2]), &event))
+	assert.Equal(t, "event", item["type"])
+	assert.EqualValues(t, len(envelopes[0][2]), item["length"])
+	assert.Equal(t, header["event_id"], event["event_id"])
+	assert.Len(t, event["event_id"], 32)
+
+	assert.Equal(t, "fatal", event["level"])
+	assert.Equal(t, "staging", event["environment"])
+	assert.Equal(t, "v1.2.3", event["release"])
+	assert.Equal(t, "GET /api/orders/:id", event["transaction"])
+	assert.Equal(t, map[string]interface{}{"id": "user-1"}, event["user"])
+	tags := event["tags"].(map[string]interface{})
+	assert.Equal(t, "req-1", tags["request_id"])
+	assert.Equal(t, "/api/orders/:id", tags["route"])
+	assert.Equal(t, "500", tags["http.status_code"])
+	trace := event["contexts"].(map[string]interface{})["trace"].(map[string]interface{})
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", trace["trace_id"])
+
+	exception := event["exception"].(map[string]interface{})["values"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "string", exception["type"])
+	assert.Equal(t, "boom", exception["value"])
+	frames := exception["stacktrace"].(map[string]interface{})["frames"].([]interface{})
+	require.Len(t, frames, 2)
+	// Outermost first, with only our own code in app
+	assert.Equal(t, "github.com/gin-gonic/gin.(*Context).Next", frames[0].(map[string]interface{})["function"])
+	assert.Equal(t, false, frames[0].(map[string]interface{})["in_app"])
+	assert.Equal(t, "secure-backend/handlers.GetOrder", frames[1].(map[string]interface{})["function"])
+	assert.Equal(t, true, frames[1].(map[string]interface{})["in_app"])
+}
@@ -0,0 +1,64 @@
+// Package events defines the payloads the API sends to other systems, such as the security
+// alert webhook, as versioned contracts. The shape of a published version never changes and
+// is described by a JSON Schema served at /.well-known/event-schemas. Internal models are
+// converted into a contract rather than serialized as they are, so changing a model can't
+// change what consumers receive; a change to a payload ships as a new version.
+package events
+
+import (
+	"embed"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// Contract versions
+const (
+	V1 = "v1"
+	V2 = "v2"
+)
+
+// VersionHeader carries the contract version of a webhook body
+const VersionHeader = "X-Event-Version"
+
+// SchemaPath is where the JSON Schemas are published
+const SchemaPath = "/.well-known/event-schemas"
+
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+// Contract is a published version of an event payload
+type Contract struct {
+	Event   string `json:"event"`
+	Version string `json:"version"`
+	Schema  string `json:"schema"` // path of its JSON Schema
+}
+
+// Contracts lists every published event version, by event and version
+func Contracts() []Contract {
+	names, _ := fs.Glob(schemaFiles, "schemas/*.json")
+	contracts := make([]Contract, 0, len(names))
+	for _, name := range names {
+		event, version, ok := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(name, "schemas/"), ".json"), ".")
+		if !ok {
+			continue
+		}
+		contracts = append(contracts, Contract{event, version, SchemaPath + "/" + event + "/" + version})
+	}
+	sort.Slice(contracts, func(i, j int) bool {
+		if contracts[i].Event != contracts[j].Event {
+			return contracts[i].Event < contracts[j].Event
+		}
+		return contracts[i].Version < contracts[j].Version
+	})
+	return contracts
+}
+
+// Schema returns the JSON Schema of a version of an event, or false if it isn't published
+func Schema(event, version string) ([]byte, bool) {
+	if strings.ContainsAny(event+version, "./") {
+		return nil, false
+	}
+	schema, err := schemaFiles.ReadFile("schemas/" + event + "." + version + ".json")
+	return schema, err == nil
+}
@@ -0,0 +1,206 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"secure-backend/models"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The compatibility suite: every published contract version is encoded from fixed events
+// and must match its schema and the payload recorded in testdata. Published
+// versions never change, so a failure here means a change needs a new version instead.
+
+var userID = "7f1c2a4e-0d3b-4f6a-9e2c-1b5d8a6c3e90"
+
+var sampleSecurityEvents = map[string]models.SecurityEvent{
+	"full": {
+		ID:        "3b9e6f2a-5c1d-4e8b-a7f0-2d4c6b8e1a35",
+		Type:      models.SecurityRoleChanged,
+		Severity:  models.SeverityHigh,
+		UserID:    &userID,
+		IPAddress: "203.0.113.9",
+		Detail:    "role changed from buyer to admin",
+		CreatedAt: time.Date(2026, 3, 14, 9, 26, 53, 0, time.UTC),
+	},
+	"minimal": {
+		ID:        "9d2f4b6a-8c0e-4a1b-b3d5-7e9f1a2c4b68",
+		Type:      models.SecurityJWKSFailure,
+		Severity:  models.SeverityHigh,
+		Detail:    "signing keys couldn't be refreshed",
+		CreatedAt: time.Date(2026, 3, 14, 9, 30, 0, 0, time.UTC),
+	},
+}
+
+func TestSecurityAlertContracts(t *testing.T) {
+	for _, version := range []string{V1, V2} {
+		schema := loadSchema(t, SecurityAlert, version)
+		for name, event := range sampleSecurityEvents {
+			t.Run(version+"/"+name, func(t *testing.T) {
+				payload, err := EncodeSecurityAlert(version, event)
+				require.NoError(t, err)
+
+				var document any
+				require.NoError(t, json.Unmarshal(payload, &document))
+				assert.Empty(t, validate(schema, document, "$"))
+
+				golden, err := os.ReadFile(fmt.Sprintf("testdata/%s.%s.%s.json", SecurityAlert, version, name))
+				require.NoError(t, err)
+				assert.JSONEq(t, string(golden), string(payload))
+			})
+		}
+	}
+
+	_, err := EncodeSecurityAlert("v0", sampleSecurityEvents["full"])
+	assert.Error(t, err)
+}
+
+func TestValidateReportsViolations(t *testing.T) {
+	schema := loadSchema(t, SecurityAlert, V2)
+	errs := validate(schema, map[string]any{
+		"id":          "event-1",
+		"event":       "security_alert",
+		"version":     "v1",
+		"occurred_at": "yesterday",
+		"text":        "alert",
+		"data":        map[string]any{"type": "role_changed", "severity": "urgent", "detail": "", "extra": true},
+	}, "$")
+	assert.ElementsMatch(t, []string{
+		"$.version: want v2, got v1",
+		`$.occurred_at: "yesterday" is not a date-time`,
+		"$.data: missing user_id",
+		"$.data: missing ip_address",
+		"$.data: unexpected property extra",
+		"$.data.severity: urgent is not one of [low medium high]",
+	}, errs)
+}
+
+func TestContractsArePublished(t *testing.T) {
+	contracts := Contracts()
+	assert.Equal(t, []Contract{
+		{SecurityAlert, V1, SchemaPath + "/security_alert/v1"},
+		{SecurityAlert, V2, SchemaPath + "/security_alert/v2"},
+	}, contracts, "published versions must never be removed")
+
+	for _, contract := range contracts {
+		schema := loadSchema(t, contract.Event, contract.Version)
+		assert.Equal(t, contract.Schema, schema["$id"])
+	}
+
+	_, ok := Schema("security_alert", "../schemas/security_alert.v1")
+	assert.False(t, ok)
+	_, ok = Schema("security_alert", "v3")
+	assert.False(t, ok)
+}
+
+func loadSchema(t *testing.T, event, version string) map[string]any {
+	t.Helper()
+	raw, ok := Schema(event, version)
+	require.True(t, ok, "no schema for %s %s", event, version)
+	var schema map[string]any
+	require.NoError(t, json.Unmarshal(raw, &schema))
+	return schema
+}
+
+// validate checks a decoded JSON document against the subset of JSON Schema the contracts
+// use (type, const, enum, required, properties, additionalProperties and date-time format)
+// and returns the violations
+func validate(schema map[string]any, value any, path string) []string {
+	var errs []string
+	if want, ok := schema["const"]; ok && want != value {
+		errs = append(errs, fmt.Sprintf("%s: want %v, got %v", path, want, value))
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, allowed := range enum {
+			found = found || allowed == value
+		}
+		if !found {
+			errs = append(errs, fmt.Sprintf("%s: %v is not one of %v", path, value, enum))
+		}
+	}
+	if types, ok := schemaTypes(schema); ok && !contains(types, jsonType(value)) {
+		return append(errs, fmt.Sprintf("%s: want %v, got %s", path, types, jsonType(value)))
+	}
+	if schema["format"] == "date-time" {
+		if s, ok := value.(string); ok {
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %q is not a date-time", path, s))
+			}
+		}
+	}
+
+	object, ok := value.(map[string]any)
+	if !ok {
+		return errs
+	}
+	properties, _ := schema["properties"].(map[string]any)
+	for _, name := range schema["required"].([]any) {
+		if _, ok := object[name.(string)]; !ok {
+			errs = append(errs, fmt.Sprintf("%s: missing %s", path, name))
+		}
+	}
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		property, ok := properties[name].(map[string]any)
+		if !ok {
+			if schema["additionalProperties"] == false {
+				errs = append(errs, fmt.Sprintf("%s: unexpected property %s", path, name))
+			}
+			continue
+		}
+		errs = append(errs, validate(property, object[name], path+"."+name)...)
+	}
+	return errs
+}
+
+func schemaTypes(schema map[string]any) ([]string, bool) {
+	switch t := schema["type"].(type) {
+	case string:
+		return []string{t}, true
+	case []any:
+		types := make([]string, len(t))
+		for i, name := range t {
+			types[i] = name.(string)
+		}
+		return types, true
+	}
+	return nil, false
+}
+
+func jsonType(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return "unknown"
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"secure-backend/models"
+	"time"
+)
+
+// SecurityAlert is the event posted to SECURITY_ALERT_WEBHOOK_URL for high-severity
+// security events
+const SecurityAlert = "security_alert"
+
+// securityAlertV1 is the original alert body: a "text" summary, which chat webhooks such as
+// Slack display, and the event
+type securityAlertV1 struct {
+	Text  string          `json:"text"`
+	Event securityEventV1 `json:"event"`
+}
+
+type securityEventV1 struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Severity  string    `json:"severity"`
+	UserID    *string   `json:"user_id,omitempty"`
+	IPAddress string    `json:"ip_address,omitempty"`
+	Detail    string    `json:"detail"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// securityAlertV2 wraps the alert in the envelope shared by v2 events, so consumers can
+// route on event and version before reading data. Optional fields are always present,
+// as null when unknown.
+type securityAlertV2 struct {
+	ID         string            `json:"id"`
+	Event      string            `json:"event"`
+	Version    string            `json:"version"`
+	OccurredAt time.Time         `json:"occurred_at"`
+	Text       string            `json:"text"`
+	Data       securityAlertData `json:"data"`
+}
+
+type securityAlertData struct {
+	Type      string  `json:"type"`
+	Severity  string  `json:"severity"`
+	UserID    *string `json:"user_id"`
+	IPAddress *string `json:"ip_address"`
+	Detail    string  `json:"detail"`
+}
+
+// EncodeSecurityAlert returns the alert body for a security event in the given contract
+// version
+func EncodeSecurityAlert(version string, event models.SecurityEvent) ([]byte, error) {
+	text := fmt.Sprintf("[%s] Security event %s: %s", event.Severity, event.Type, event.Detail)
+	if event.IPAddress != "" {
+		text += " (from " + event.IPAddress + ")"
+	}
+
+	switch version {
+	case V1:
+		return json.Marshal(securityAlertV1{
+			Text: text,
+			Event: securityEventV1{
+				ID:        event.ID,
+				Type:      event.Type,
+				Severity:  event.Severity,
+				UserID:    event.UserID,
+				IPAddress: event.IPAddress,
+				Detail:    event.Detail,
+				CreatedAt: event.CreatedAt,
+			},
+		})
+	case V2:
+		alert := securityAlertV2{
+			ID:         event.ID,
+			Event:      SecurityAlert,
+			Version:    V2,
+			OccurredAt: event.CreatedAt,
+			Text:       text,
+			Data: securityAlertData{
+				Type:     event.Type,
+				Severity: event.Severity,
+				UserID:   event.UserID,
+				Detail:   event.Detail,
+			},
+		}
+		if event.IPAddress != "" {
+			alert.Data.IPAddress = &event.IPAddress
+		}
+		return json.Marshal(alert)
+	}
+	return nil, fmt.Errorf("unknown %s version %q", SecurityAlert, version)
+}
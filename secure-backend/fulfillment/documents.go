@@ -0,0 +1,266 @@
+// Package fulfillment renders the documents sellers print to fulfil orders: pick lists,
+// which total up what to take off the shelves for a batch of orders, and packing slips,
+// which go in each parcel. Both render as PDF or as HTML for printing from the browser.
+package fulfillment
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"secure-backend/models"
+	"secure-backend/pdftext"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// Output formats
+const (
+	FormatPDF  = "pdf"
+	FormatHTML = "html"
+)
+
+// ErrUnknownFormat is returned for formats other than FormatPDF and FormatHTML
+var ErrUnknownFormat = errors.New("format must be pdf or html")
+
+// PickList is a pick list for a batch of orders
+type PickList struct {
+	Issuer      string
+	Batch       string // what the list covers, e.g. "Orders placed 2026-10-15"
+	GeneratedAt time.Time
+	Lines       []models.PickListLine
+}
+
+// PackingSlips are the packing slips of a batch of orders, one page each
+type PackingSlips struct {
+	Issuer string
+	Slips  []models.PackingSlip
+}
+
+// RenderPickList writes a pick list in the format
+func RenderPickList(w io.Writer, list PickList, format string) error {
+	switch format {
+	case FormatPDF:
+		return pickListPDF(w, list)
+	case FormatHTML:
+		return pickListTemplate.Execute(w, list)
+	}
+	return ErrUnknownFormat
+}
+
+// RenderPackingSlips writes packing slips in the format
+func RenderPackingSlips(w io.Writer, slips PackingSlips, format string) error {
+	switch format {
+	case FormatPDF:
+		return packingSlipsPDF(w, slips)
+	case FormatHTML:
+		return packingSlipsTemplate.Execute(w, slips)
+	}
+	return ErrUnknownFormat
+}
+
+// OrderReference shortens an order ID to the reference printed on documents
+func OrderReference(orderID string) string {
+	if len(orderID) > 8 {
+		orderID = orderID[:8]
+	}
+	return strings.ToUpper(orderID)
+}
+
+// text undoes the HTML escaping product names and addresses are stored with
+func text(s string) string {
+	return html.UnescapeString(s)
+}
+
+// orderReferences lists the references of orders
+func orderReferences(orderIDs []string) string {
+	refs := make([]string, len(orderIDs))
+	for i, id := range orderIDs {
+		refs[i] = OrderReference(id)
+	}
+	return strings.Join(refs, ", ")
+}
+
+// totalUnits adds up the quantities of a pick list
+func totalUnits(lines []models.PickListLine) int {
+	units := 0
+	for _, line := range lines {
+		units += line.Quantity
+	}
+	return units
+}
+
+func pickListPDF(w io.Writer, list PickList) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle("Pick list", true)
+	pdf.SetMargins(15, 15, 15)
+	pdf.AddPage()
+
+	tr := pdftext.Translator(pdf)
+
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(110, 9, tr(list.Issuer), "", 0, "L", false, 0, "")
+	pdf.CellFormat(70, 9, "PICK LIST", "", 1, "R", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(110, 5, tr(list.Batch), "", 0, "L", false, 0, "")
+	pdf.CellFormat(70, 5, "Generated "+list.GeneratedAt.UTC().Format("2006-01-02 15:04")+" UTC", "", 1, "R", false, 0, "")
+	pdf.CellFormat(0, 5, fmt.Sprintf("%d products, %d units", len(list.Lines), totalUnits(list.Lines)), "", 1, "L", false, 0, "")
+
+	pdf.Ln(6)
+	widths := []float64{30, 75, 15, 50, 10}
+	headers := []string{"Location", "Item", "Qty", "Orders", ""}
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.SetFillColor(235, 235, 235)
+	for i, header := range headers {
+		align := "L"
+		if i == 2 {
+			align = "R"
+		}
+		pdf.CellFormat(widths[i], 7, header, "B", 0, align, true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Helvetica", "", 9)
+	for _, line := range list.Lines {
+		location := text(line.ShelfLocation)
+		if location == "" {
+			location = "-"
+		}
+		pdf.CellFormat(widths[0], 7, tr(pdftext.Truncate(location, 18)), "B", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[1], 7, tr(pdftext.Truncate(text(line.ProductName), 45)), "B", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[2], 7, strconv.Itoa(line.Quantity), "B", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[3], 7, tr(pdftext.Truncate(orderReferences(line.OrderIDs), 30)), "B", 0, "L", false, 0, "")
+		// Box to tick once picked
+		x, y := pdf.GetXY()
+		pdf.Rect(x+3, y+1.5, 4, 4, "D")
+		pdf.CellFormat(widths[4], 7, "", "B", 1, "L", false, 0, "")
+	}
+
+	return pdf.Output(w)
+}
+
+func packingSlipsPDF(w io.Writer, slips PackingSlips) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle("Packing slips", true)
+	pdf.SetMargins(20, 20, 20)
+	tr := pdftext.Translator(pdf)
+
+	if len(slips.Slips) == 0 {
+		pdf.AddPage()
+		pdf.SetFont("Helvetica", "", 11)
+		pdf.CellFormat(0, 6, "No orders to pack.", "", 1, "L", false, 0, "")
+	}
+	for _, slip := range slips.Slips {
+		pdf.AddPage()
+		pdf.SetFont("Helvetica", "B", 18)
+		pdf.CellFormat(100, 10, tr(slips.Issuer), "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "B", 14)
+		pdf.CellFormat(70, 10, "PACKING SLIP", "", 1, "R", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.CellFormat(0, 5, "Order: "+OrderReference(slip.OrderID), "", 1, "R", false, 0, "")
+		pdf.CellFormat(0, 5, "Ordered: "+slip.OrderedAt.UTC().Format("2006-01-02"), "", 1, "R", false, 0, "")
+
+		pdf.Ln(6)
+		pdf.SetFont("Helvetica", "B", 11)
+		pdf.CellFormat(0, 6, "Ship to", "", 1, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.MultiCell(0, 5, tr(text(slip.ShippingAddress)), "", "L", false)
+
+		pdf.Ln(8)
+		widths := []float64{110, 35, 25}
+		headers := []string{"Item", "Location", "Qty"}
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.SetFillColor(235, 235, 235)
+		for i, header := range headers {
+			align := "L"
+			if i == 2 {
+				align = "R"
+			}
+			pdf.CellFormat(widths[i], 7, header, "B", 0, align, true, 0, "")
+		}
+		pdf.Ln(-1)
+
+		pdf.SetFont("Helvetica", "", 9)
+		for _, item := range slip.Items {
+			pdf.CellFormat(widths[0], 6, tr(pdftext.Truncate(text(item.ProductName), 65)), "", 0, "L", false, 0, "")
+			pdf.CellFormat(widths[1], 6, tr(pdftext.Truncate(text(item.ShelfLocation), 20)), "", 0, "L", false, 0, "")
+			pdf.CellFormat(widths[2], 6, strconv.Itoa(item.Quantity), "", 1, "R", false, 0, "")
+		}
+	}
+
+	return pdf.Output(w)
+}
+
+var templateFuncs = template.FuncMap{
+	"text":   text,
+	"ref":    OrderReference,
+	"refs":   orderReferences,
+	"units":  totalUnits,
+	"date":   func(t time.Time) string { return t.UTC().Format("2006-01-02") },
+	"minute": func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04") },
+}
+
+// documentStyle is shared by the HTML documents; each packing slip prints on its own page
+const documentStyle = `<style>
+body { font-family: Helvetica, Arial, sans-serif; font-size: 12px; margin: 24px; }
+header { display: flex; justify-content: space-between; align-items: baseline; }
+h1 { font-size: 20px; margin: 0; }
+h2 { font-size: 16px; margin: 0; }
+table { width: 100%; border-collapse: collapse; margin-top: 16px; }
+th { background: #ebebeb; text-align: left; }
+th, td { padding: 6px; border-bottom: 1px solid #ccc; }
+.qty { text-align: right; }
+.check { width: 24px; }
+.address { white-space: pre-line; }
+.slip { page-break-after: always; }
+.slip:last-child { page-break-after: auto; }
+</style>`
+
+var pickListTemplate = template.Must(template.New("pick-list").Funcs(templateFuncs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>Pick list</title>` + documentStyle + `</head>
+<body>
+<header><h1>{{text .Issuer}}</h1><h2>PICK LIST</h2></header>
+<p>{{.Batch}} &middot; generated {{minute .GeneratedAt}} UTC &middot; {{len .Lines}} products, {{units .Lines}} units</p>
+<table>
+<thead><tr><th>Location</th><th>Item</th><th class="qty">Qty</th><th>Orders</th><th class="check"></th></tr></thead>
+<tbody>
+{{- range .Lines}}
+<tr><td>{{with text .ShelfLocation}}{{.}}{{else}}-{{end}}</td><td>{{text .ProductName}}</td><td class="qty">{{.Quantity}}</td><td>{{refs .OrderIDs}}</td><td class="check">&#9744;</td></tr>
+{{- end}}
+</tbody>
+</table>
+</body>
+</html>
+`))
+
+var packingSlipsTemplate = template.Must(template.New("packing-slips").Funcs(templateFuncs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>Packing slips</title>` + documentStyle + `</head>
+<body>
+{{- $issuer := .Issuer}}
+{{- range .Slips}}
+<section class="slip">
+<header><h1>{{text $issuer}}</h1><h2>PACKING SLIP</h2></header>
+<p>Order {{ref .OrderID}} &middot; ordered {{date .OrderedAt}}</p>
+<h3>Ship to</h3>
+<p class="address">{{text .ShippingAddress}}</p>
+<table>
+<thead><tr><th>Item</th><th>Location</th><th class="qty">Qty</th></tr></thead>
+<tbody>
+{{- range .Items}}
+<tr><td>{{text .ProductName}}</td><td>{{text .ShelfLocation}}</td><td class="qty">{{.Quantity}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+</section>
+{{- else}}
+<p>No orders to pack.</p>
+{{- end}}
+</body>
+</html>
+`))
@@ -0,0 +1,55 @@
+package fulfillment
+
+import (
+	"bytes"
+	"secure-backend/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderDocuments(t *testing.T) {
+	list := PickList{
+		Issuer:      "SecureShop",
+		Batch:       "Orders placed 2026-10-15",
+		GeneratedAt: time.Now(),
+		Lines: []models.PickListLine{
+			{ProductName: "Caf&#233; cr&#232;me mug &amp; saucer", ShelfLocation: "A-03-2", Quantity: 3, OrderIDs: []string{"5f0c2a9e-1111", "7b3d9c0e-2222"}},
+			{ProductName: "<script>alert(1)</script>", Quantity: 1, OrderIDs: []string{"5f0c2a9e-1111"}},
+		},
+	}
+	slips := PackingSlips{
+		Issuer: "SecureShop",
+		Slips: []models.PackingSlip{{
+			OrderID: "5f0c2a9e-1111", OrderedAt: time.Now(), ShippingAddress: "1 Main St\nSpringfield",
+			Items: []models.PackingSlipItem{{ProductName: "Mug", ShelfLocation: "A-03-2", Quantity: 3}},
+		}},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, RenderPickList(&out, list, FormatPDF))
+	assert.True(t, bytes.HasPrefix(out.Bytes(), []byte("%PDF")))
+
+	out.Reset()
+	require.NoError(t, RenderPackingSlips(&out, slips, FormatPDF))
+	assert.True(t, bytes.HasPrefix(out.Bytes(), []byte("%PDF")))
+
+	out.Reset()
+	require.NoError(t, RenderPickList(&out, list, FormatHTML))
+	html := out.String()
+	// Stored names are HTML-escaped; they are printed once, not twice
+	assert.Contains(t, html, "Café crème mug &amp; saucer")
+	assert.NotContains(t, html, "<script>")
+	assert.Contains(t, html, "5F0C2A9E, 7B3D9C0E")
+	assert.Contains(t, html, "2 products, 4 units")
+
+	out.Reset()
+	require.NoError(t, RenderPackingSlips(&out, slips, FormatHTML))
+	assert.Contains(t, out.String(), "Order 5F0C2A9E")
+
+	out.Reset()
+	require.NoError(t, RenderPackingSlips(&out, PackingSlips{Issuer: "SecureShop"}, FormatPDF))
+	assert.ErrorIs(t, RenderPickList(&out, list, "csv"), ErrUnknownFormat)
+}
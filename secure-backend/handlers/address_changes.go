@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/services"
+	"secure-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChangeOrderAddress edits the shipping address of the buyer's order. Within
+// ORDER_ADDRESS_EDIT_WINDOW of ordering and before any item ships the address changes
+// right away (200) and the sellers are notified; otherwise the change is sent to support
+// (202) with the reason.
+func ChangeOrderAddress(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		ShippingAddress string `json:"shipping_address" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	address := utils.SanitizeAddress(request.ShippingAddress)
+	if address == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Shipping address is required"})
+		return
+	}
+
+	change, err := services.ChangeOrderAddress(c.Request.Context(), sanitizedIDParam(c), user.ID, address)
+	switch {
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	case errors.Is(err, models.ErrAddressLocked), errors.Is(err, database.ErrAddressChangePending):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change shipping address"})
+		return
+	}
+
+	if change.Status == models.AddressChangeRequested {
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": "The address can no longer be changed directly; your request was sent to support",
+			"change":  change,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Shipping address updated", "change": change})
+}
+
+// GetOrderAddressChanges returns the address change history of the buyer's order,
+// including requests waiting for support
+func GetOrderAddressChanges(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, err := database.GetOrderByBuyer(c.Request.Context(), sanitizedIDParam(c), user.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order"})
+		return
+	}
+
+	changes, err := database.GetOrderAddressChanges(c.Request.Context(), order.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load address changes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"order_id": order.ID, "changes": changes})
+}
+
+// GetAddressChangeRequests lists address changes for support, oldest first
+// (?status=support_requested|approved|rejected|applied, default support_requested; paginated)
+func GetAddressChangeRequests(c *gin.Context) {
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := c.DefaultQuery("status", models.AddressChangeRequested)
+	switch status {
+	case models.AddressChangeRequested, models.AddressChangeApproved, models.AddressChangeRejected, models.AddressChangeApplied:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be support_requested, approved, rejected or applied"})
+		return
+	}
+
+	changes, total, err := database.GetAddressChangeRequests(c.Request.Context(), status, page.Limit, page.Offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load address changes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"changes": changes,
+		"total":   total,
+		"limit":   page.Limit,
+		"offset":  page.Offset,
+	})
+}
+
+// ResolveAddressChange approves (applying the address) or rejects an address change sent
+// to support. The buyer is notified and the decision is recorded in the admin audit log.
+func ResolveAddressChange(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		Decision string `json:"decision" binding:"required,oneof=approved rejected"`
+		Note     string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	note := utils.SanitizeInput(request.Note, utils.DefaultTextOptions)
+
+	id := sanitizedIDParam(c)
+	change, err := services.ResolveAddressChange(c.Request.Context(), id, request.Decision, note, &models.AuditEntry{
+		ActorID:    &user.ID,
+		ActorEmail: user.Email,
+		Action:     models.AuditAddressChangeResolved,
+		Detail:     fmt.Sprintf("%s address change %s: %s", request.Decision, id, note),
+		IPAddress:  c.ClientIP(),
+	})
+	switch {
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Address change not found"})
+		return
+	case errors.Is(err, database.ErrAddressChangeResolved), errors.Is(err, models.ErrAddressLocked):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve address change"})
+		return
+	}
+
+	c.JSON(http.StatusOK, change)
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/middleware"
+	"secure-backend/models"
+	"secure-backend/services"
+	"secure-backend/utils"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClaimAdminBootstrap makes the caller the first admin, given the one-time bootstrap token
+// printed at startup (or by cmd/admin) while no admin account exists
+func ClaimAdminBootstrap(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = services.ClaimAdminBootstrap(c.Request.Context(), request.Token, user, c.ClientIP())
+	switch {
+	case errors.Is(err, database.ErrInvalidBootstrapToken):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, database.ErrAdminExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	case err != nil:
+		log.Printf("Failed to claim admin bootstrap: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to claim admin"})
+		return
+	}
+
+	middleware.InvalidateUserRole(user.ID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "You are now an admin", "role": "admin"})
+}
+
+// UpdateUserRole changes a user's role (admin only). The change is audited and takes effect
+// on the user's next request. Admins can't change their own role, so the last admin can't
+// lock everyone out.
+func UpdateUserRole(c *gin.Context) {
+	admin, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		Role string `json:"role" binding:"required,oneof=buyer seller admin"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := sanitizedIDParam(c)
+	if userID == admin.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You can't change your own role"})
+		return
+	}
+
+	err = database.UpdateUserRole(c.Request.Context(), userID, request.Role, &models.AuditEntry{
+		ActorID:    &admin.ID,
+		ActorEmail: admin.Email,
+		Action:     models.AuditRoleChanged,
+		Detail:     fmt.Sprintf("set role of user %s to %s", userID, request.Role),
+		IPAddress:  c.ClientIP(),
+	})
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+	middleware.InvalidateUserRole(userID)
+
+	// Granting admin is an escalation worth alerting on
+	severity := models.SeverityMedium
+	if request.Role == "admin" {
+		severity = models.SeverityHigh
+	}
+	services.RecordSecurityEvent(c.Request.Context(), &models.SecurityEvent{
+		Type:      models.SecurityRoleChanged,
+		Severity:  severity,
+		UserID:    &userID,
+		IPAddress: c.ClientIP(),
+		Detail:    fmt.Sprintf("%s set role of user %s to %s", admin.Email, userID, request.Role),
+	})
+
+	c.JSON(http.StatusOK, gin.H{"id": userID, "role": request.Role})
+}
+
+// RevokeUserTokens refuses a user's access tokens issued before a point in time (now by
+// default) and ends their cookie sessions, for responding to a compromised account. The user
+// has to sign in again; tokens issued afterwards keep working. The revocation is audited.
+func RevokeUserTokens(c *gin.Context) {
+	admin, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		Before *time.Time `json:"before"`
+		Reason string     `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := clk.Now()
+	before := now
+	if request.Before != nil {
+		if request.Before.After(now) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "before can't be in the future"})
+			return
+		}
+		before = *request.Before
+	}
+	reason := utils.SanitizeInput(request.Reason, utils.SanitizationOptions{
+		TrimWhitespace: true,
+		RemoveNewlines: true,
+		MaxLength:      500,
+	})
+
+	userID := sanitizedIDParam(c)
+	revokedBefore, err := database.RevokeUserTokens(c.Request.Context(), userID, before, reason, &models.AuditEntry{
+		ActorID:    &admin.ID,
+		ActorEmail: admin.Email,
+		Action:     models.AuditTokensRevoked,
+		Detail:     fmt.Sprintf("revoked tokens of user %s issued before %s: %s", userID, before.UTC().Format(time.RFC3339), reason),
+		IPAddress:  c.ClientIP(),
+	})
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke tokens"})
+		return
+	}
+	middleware.InvalidateTokenRevocation(userID)
+
+	c.JSON(http.StatusOK, gin.H{"id": userID, "revoked_before": revokedBefore})
+}
+
+// BreakGlassLogin signs in a break-glass admin account with its password, for emergencies
+// where Supabase Auth is unavailable or no admin can sign in. A reason is mandatory and is
+// written to the admin audit log along with every request made with the returned token.
+func BreakGlassLogin(c *gin.Context) {
+	var request struct {
+		Email    string `json:"email" binding:"required"`
+		Password string `json:"password" binding:"required"`
+		Reason   string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reason := utils.SanitizeInput(request.Reason, utils.SanitizationOptions{
+		TrimWhitespace: true,
+		RemoveNewlines: true,
+		MaxLength:      500,
+	})
+	if len(reason) < 10 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason must describe the emergency (at least 10 characters)"})
+		return
+	}
+
+	token, expiresAt, err := services.BreakGlassLogin(c.Request.Context(), request.Email, request.Password, reason, c.ClientIP())
+	if errors.Is(err, services.ErrInvalidCredentials) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		log.Printf("Break-glass login failed: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Break-glass login is unavailable"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":      token,
+		"token_type": "Bearer",
+		"expires_at": expiresAt,
+	})
+}
+
+// GetAdminAuditLog lists admin bootstrap and break-glass audit entries
+// Only admins can view the audit log; supports ?action= and limit/offset pagination
+func GetAdminAuditLog(c *gin.Context) {
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	action := strings.TrimSpace(c.Query("action"))
+
+	entries, total, err := database.GetAdminAuditLog(c.Request.Context(), action, page.Limit, page.Offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load audit log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"total":   total,
+		"limit":   page.Limit,
+		"offset":  page.Offset,
+	})
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cart event actions
+const (
+	cartActionAdd    = "add"
+	cartActionUpdate = "update"
+	cartActionRemove = "remove"
+	cartActionClear  = "clear"
+	cartActionSync   = "sync"
+	cartActionMerge  = "merge" // guest cart item merged after login
+	cartActionSave   = "save"  // cart item moved to saved items
+)
+
+// defaultReportPeriod is used when a report request has no ?from= parameter
+const defaultReportPeriod = 30 * 24 * time.Hour
+
+// recordCartEvent stores a cart action with the requesting client's platform.
+// Analytics failures are logged and never fail the request.
+func recordCartEvent(c *gin.Context, userID, action, productID string, quantity int) {
+	client := utils.GetClientInfo(c)
+
+	event := &models.CartEvent{
+		UserID:     userID,
+		Action:     action,
+		Quantity:   quantity,
+		Platform:   client.Platform,
+		AppVersion: client.AppVersion,
+	}
+	if productID != "" {
+		event.ProductID = &productID
+	}
+
+	if err := database.RecordCartEvent(c.Request.Context(), event); err != nil {
+		log.Printf("Failed to record cart event: %v", err)
+	}
+}
+
+// parseDateRange reads ?from= and ?to= (YYYY-MM-DD) defaulting to the last 30 days.
+// The returned range is half-open: [from, to).
+func parseDateRange(c *gin.Context) (time.Time, time.Time, error) {
+	to := clk.Now().UTC()
+	from := to.Add(-defaultReportPeriod)
+
+	if toParam := c.Query("to"); toParam != "" {
+		parsed, err := time.Parse("2006-01-02", toParam)
+		if err != nil {
+			return from, to, errors.New("to must be a date in YYYY-MM-DD format")
+		}
+		// Include the whole "to" day
+		to = parsed.AddDate(0, 0, 1)
+	}
+
+	if fromParam := c.Query("from"); fromParam != "" {
+		parsed, err := time.Parse("2006-01-02", fromParam)
+		if err != nil {
+			return from, to, errors.New("from must be a date in YYYY-MM-DD format")
+		}
+		from = parsed
+	}
+
+	if !from.Before(to) {
+		return from, to, errors.New("from must be before to")
+	}
+
+	return from, to, nil
+}
+
+// GetDeviceReport returns cart activity and orders broken down by client platform
+// Only admins can view reports
+func GetDeviceReport(c *gin.Context) {
+	from, to, err := parseDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cartActivity, err := database.GetCartEventBreakdown(c.Request.Context(), from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load cart activity"})
+		return
+	}
+
+	orders, err := database.GetOrderPlatformBreakdown(c.Request.Context(), from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load order breakdown"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":          from,
+		"to":            to,
+		"cart_activity": cartActivity,
+		"orders":        orders,
+	})
+}
+
+// GetAbandonedCarts lists carts that went idle with items in them (?from=&to=, ?limit=&offset=),
+// the input for abandoned-cart emails. ?marketing_consent=true leaves out users who haven't
+// consented to marketing, as email exports must. Only admins can view reports.
+func GetAbandonedCarts(c *gin.Context) {
+	from, to, err := parseDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	abandonments, total, err := database.GetCartAbandonments(c.Request.Context(), from, to, c.Query("marketing_consent") == "true", page.Limit, page.Offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load abandoned carts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":         from,
+		"to":           to,
+		"abandonments": abandonments,
+		"total":        total,
+		"limit":        page.Limit,
+		"offset":       page.Offset,
+	})
+}
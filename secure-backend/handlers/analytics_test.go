@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"secure-backend/clock"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDateRange(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	now := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+	SetClock(clock.NewMock(now))
+	defer SetClock(clock.System())
+
+	parse := func(query string) (time.Time, time.Time, error) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/?"+query, nil)
+		return parseDateRange(c)
+	}
+
+	// The last 30 days by default
+	from, to, err := parse("")
+	require.NoError(t, err)
+	assert.Equal(t, now, to)
+	assert.Equal(t, now.Add(-defaultReportPeriod), from)
+
+	// The "to" day is included in full
+	from, to, err = parse("from=2026-03-01&to=2026-03-01")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), from)
+	assert.Equal(t, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), to)
+
+	for _, query := range []string{"from=2026-03-02&to=2026-03-01", "from=2026-04-01", "from=03/01/2026", "to=yesterday"} {
+		_, _, err := parse(query)
+		assert.Error(t, err, query)
+	}
+}
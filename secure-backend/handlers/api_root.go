@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+	"secure-backend/events"
+	"secure-backend/locale"
+	"secure-backend/mail"
+	"secure-backend/middleware"
+	"secure-backend/notifications"
+	"secure-backend/payments"
+	"secure-backend/services"
+	"secure-backend/sessions"
+	"secure-backend/shipping"
+	"secure-backend/storage"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiVersions are the API versions this deployment serves. Version 1 is served under /api
+// without a version in the path.
+var apiVersions = []gin.H{{"version": "v1", "base_path": "/api", "status": "current"}}
+
+// GetAPIRoot returns the capability document of this deployment: the optional features it
+// has enabled, the API versions, rate limits and quota tiers, and the currencies and
+// languages it supports, so clients can feature-detect instead of assuming each deployment
+// is configured alike
+func GetAPIRoot(c *gin.Context) {
+	channels := notifications.Channels()
+	c.JSON(http.StatusOK, gin.H{
+		"name":         "SecureShop API",
+		"api_versions": apiVersions,
+		"features": gin.H{
+			"payments":           payments.Enabled(),
+			"image_uploads":      storage.Configured(),
+			"shipping_labels":    shipping.Enabled(),
+			"cookie_sessions":    sessions.Enabled(),
+			"push_notifications": slices.Contains(channels, "push"),
+			"email":              mail.Configured(),
+			"demo_mode":          services.DemoMode(),
+		},
+		"rate_limits": middleware.RateLimitPolicies(),
+		"costs":       middleware.RequestCosts,
+		"quotas":      middleware.QuotaTiers,
+		"currencies":  []string{payments.Currency()},
+		"locales": gin.H{
+			"supported": locale.Supported,
+			"default":   locale.Default,
+		},
+		"event_contracts": events.Contracts(),
+		"links": gin.H{
+			"health":        "/api/healthz",
+			"rate_limits":   "/api/rate-limits",
+			"labels":        "/api/labels",
+			"event_schemas": events.SchemaPath,
+		},
+	})
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAPIRoot(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("STRIPE_CURRENCY", "EUR")
+	t.Setenv("DEMO_MODE", "true")
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api", nil)
+	GetAPIRoot(c)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var root struct {
+		APIVersions []map[string]string `json:"api_versions"`
+		Features    map[string]bool     `json:"features"`
+		Currencies  []string            `json:"currencies"`
+		Locales     struct {
+			Supported []string `json:"supported"`
+			Default   string   `json:"default"`
+		} `json:"locales"`
+		Quotas map[string]any `json:"quotas"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &root))
+
+	assert.Equal(t, "/api", root.APIVersions[0]["base_path"])
+	assert.True(t, root.Features["demo_mode"])
+	assert.False(t, root.Features["payments"], "no payment provider is configured in tests")
+	assert.Contains(t, root.Features, "image_uploads")
+	assert.Equal(t, []string{"eur"}, root.Currencies)
+	assert.Equal(t, "en", root.Locales.Default)
+	assert.Contains(t, root.Locales.Supported, "de")
+	assert.Contains(t, root.Quotas, "seller:pro")
+}
@@ -1,15 +1,26 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"net/http"
 	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/payments"
+	"secure-backend/pricing"
+	"secure-backend/services"
 	"secure-backend/utils"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
-// GetCart retrieves the user's cart items with product details
+// GetCart retrieves the user's cart items with product details.
+// When a ?since=<version> query parameter is given, only the changes made
+// after that cart version are returned (see getCartDelta).
+// Every item is flagged with its availability so clients can warn before checkout;
+// with ?adjust=true quantities above the available stock are lowered to it.
 func GetCart(c *gin.Context) {
 	user, err := utils.GetAuthUser(c)
 	if err != nil {
@@ -17,18 +28,121 @@ func GetCart(c *gin.Context) {
 		return
 	}
 
-	items, err := database.GetCartItems(user.ID)
+	if sinceParam, ok := c.GetQuery("since"); ok {
+		since, err := strconv.ParseInt(sinceParam, 10, 64)
+		if err != nil || since < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be a non-negative integer"})
+			return
+		}
+		getCartDelta(c, user.ID, since)
+		return
+	}
+
+	// Adjusting writes quantities computed from the items, which must not come from a replica
+	if c.Query("adjust") == "true" {
+		c.Request = c.Request.WithContext(database.UsePrimary(c.Request.Context()))
+	}
+
+	// Read the version before the items so a concurrent change is re-sent on the next delta
+	version, err := database.GetCartVersion(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve cart"})
+		return
+	}
+
+	items, err := database.GetCartItems(c.Request.Context(), user.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve cart"})
 		return
 	}
 
+	adjusted := 0
+	for i := range items {
+		item := &items[i]
+		item.CheckAvailability()
+		if c.Query("adjust") != "true" || item.Availability != models.CartItemQuantityReduced {
+			continue
+		}
+
+		if err := database.UpdateCartItemQuantity(c.Request.Context(), item.ID, user.ID, item.AvailableQuantity); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to adjust cart"})
+			return
+		}
+		recordCartEvent(c, user.ID, cartActionUpdate, item.ProductID, item.AvailableQuantity)
+		item.Quantity = item.AvailableQuantity
+		item.Adjusted = true
+		adjusted++
+	}
+
+	// Adjustments bumped the cart version; report the new one so delta sync doesn't resend them
+	if adjusted > 0 {
+		if version, err = database.GetCartVersion(c.Request.Context(), user.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve cart"})
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"items": items,
-		"count": len(items),
+		"items":   items,
+		"count":   len(items),
+		"version": version,
 	})
 }
 
+// getCartDelta responds with the items added, updated and removed since the given cart version
+func getCartDelta(c *gin.Context, userID string, since int64) {
+	delta, err := buildCartDelta(c.Request.Context(), userID, since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve cart changes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, delta)
+}
+
+// buildCartDelta collects the cart changes made after the given version
+func buildCartDelta(ctx context.Context, userID string, since int64) (models.CartDelta, error) {
+	delta := models.CartDelta{
+		Since:   since,
+		Added:   []models.CartItemWithProduct{},
+		Updated: []models.CartItemWithProduct{},
+		Removed: []models.CartItemRemoval{},
+	}
+
+	version, err := database.GetCartVersion(ctx, userID)
+	if err != nil {
+		return delta, err
+	}
+	delta.Version = version
+
+	// Nothing changed since the client's version
+	if since >= version {
+		return delta, nil
+	}
+
+	items, err := database.GetCartItemsSince(ctx, userID, since)
+	if err != nil {
+		return delta, err
+	}
+
+	for _, item := range items {
+		item.CheckAvailability()
+		if item.AddedVersion > since {
+			delta.Added = append(delta.Added, item)
+		} else {
+			delta.Updated = append(delta.Updated, item)
+		}
+	}
+
+	removals, err := database.GetCartRemovalsSince(ctx, userID, since)
+	if err != nil {
+		return delta, err
+	}
+	delta.Removed = append(delta.Removed, removals...)
+
+	return delta, nil
+}
+
 // AddToCart adds a product to the user's cart
 func AddToCart(c *gin.Context) {
 	user, err := utils.GetAuthUser(c)
@@ -40,6 +154,11 @@ func AddToCart(c *gin.Context) {
 	var request struct {
 		ProductID string `json:"product_id" binding:"required"`
 		Quantity  int    `json:"quantity" binding:"required,min=1"`
+
+		// Set when the product was added from a recommendation on RecommendedFrom (a product
+		// ID), so the attach is counted in the recommendation report
+		RecommendedFrom string `json:"recommended_from"`
+		Placement       string `json:"placement" binding:"omitempty,oneof=product cart checkout"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -61,35 +180,265 @@ func AddToCart(c *gin.Context) {
 		return
 	}
 
-	// Verify product exists and is available
-	product, err := database.GetProductByID(request.ProductID)
+	product, ok := checkProductAvailable(c, request.ProductID, request.Quantity)
+	if !ok {
+		return
+	}
+
+	// Purchase limits apply to the quantity the cart ends up with
+	inCart, err := quantityInCart(c.Request.Context(), user.ID, request.ProductID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add to cart"})
+		return
+	}
+	if !checkOrderQuantity(c, product, inCart+request.Quantity) {
+		return
+	}
+	if !checkCartLimits(c, user.ID, "", product.ID, inCart+request.Quantity, request.Quantity) {
+		return
+	}
+
+	// Add to cart
+	cartItem, err := database.AddToCart(c.Request.Context(), user.ID, request.ProductID, request.Quantity)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add to cart"})
+		return
+	}
+
+	recordCartEvent(c, user.ID, cartActionAdd, request.ProductID, request.Quantity)
+	if request.RecommendedFrom != "" {
+		recordRecommendationAttach(c.Request.Context(), user.ID, request.RecommendedFrom, request.ProductID, request.Placement)
+	}
+
+	c.JSON(http.StatusCreated, cartItem)
+}
+
+// maxBulkCartItems caps the number of products accepted by one bulk add
+const maxBulkCartItems = 50
+
+// Bulk add rejection codes (purchase limit violations use the models.OrderRule* codes)
+const (
+	bulkRejectNotFound          = "not_found"
+	bulkRejectUnavailable       = "unavailable"
+	bulkRejectInsufficientStock = "insufficient_stock"
+)
+
+// BulkCartItemResult reports how one item of a bulk add was handled
+type BulkCartItemResult struct {
+	ProductID string           `json:"product_id"`
+	Quantity  int              `json:"quantity"`
+	Status    string           `json:"status"` // added, rejected
+	Code      string           `json:"code,omitempty"`
+	Reason    string           `json:"reason,omitempty"`
+	Item      *models.CartItem `json:"item,omitempty"`
+}
+
+// AddToCartBulk adds several products to the cart in one request, for "buy again" and
+// wishlist-to-cart flows. Every item is validated like AddToCart; valid items are added
+// and the rest are rejected with a code, so one unavailable product doesn't fail the batch.
+// Repeated products are combined.
+func AddToCartBulk(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		Items []struct {
+			ProductID string `json:"product_id" binding:"required"`
+			Quantity  int    `json:"quantity" binding:"required,min=1,max=100"`
+		} `json:"items" binding:"required,min=1,dive"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(request.Items) > maxBulkCartItems {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At most 50 items can be added at once"})
+		return
+	}
+
+	// Combine repeated products, keeping the order they were first listed in
+	results := make([]BulkCartItemResult, 0, len(request.Items))
+	index := map[string]int{}
+	for _, item := range request.Items {
+		productID := utils.SanitizeInput(item.ProductID, utils.SanitizationOptions{
+			TrimWhitespace: true,
+			EscapeHTML:     true,
+			RemoveNewlines: true,
+			MaxLength:      100,
+		})
+		if i, ok := index[productID]; ok {
+			results[i].Quantity += item.Quantity
+			continue
+		}
+		index[productID] = len(results)
+		results = append(results, BulkCartItemResult{ProductID: productID, Quantity: item.Quantity})
+	}
+
+	addItemsToCart(c, user.ID, results)
+}
+
+// addItemsToCart validates and adds the products of results to the user's cart like
+// AddToCartBulk, filling in each result and responding with the outcome
+func addItemsToCart(c *gin.Context, userID string, results []BulkCartItemResult) {
+	productIDs := make([]string, 0, len(results))
+	for _, result := range results {
+		productIDs = append(productIDs, result.ProductID)
+	}
+
+	products, err := database.GetProductsByIDs(c.Request.Context(), productIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify products"})
+		return
+	}
+
+	sellerIDs := make([]string, 0, len(products))
+	for _, product := range products {
+		sellerIDs = append(sellerIDs, product.SellerID)
+	}
+	vacations, err := database.GetSellerVacations(c.Request.Context(), sellerIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify products"})
+		return
+	}
+
+	cartItems, err := database.GetCartItems(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve cart"})
+		return
+	}
+	inCart := make(map[string]int, len(cartItems))
+	cartUnits := 0
+	for _, item := range cartItems {
+		inCart[item.ProductID] = item.Quantity
+		cartUnits += item.Quantity
+	}
+	maxCartUnits := services.CartMaxUnits()
+
+	added := 0
+	for i := range results {
+		result := &results[i]
+		product, ok := products[result.ProductID]
+		switch {
+		case !ok:
+			result.Code, result.Reason = bulkRejectNotFound, "Product not found"
+		case product.Status != "published":
+			result.Code, result.Reason = bulkRejectUnavailable, "Product is not available"
+		case product.Stock < result.Quantity:
+			result.Code, result.Reason = bulkRejectInsufficientStock, "Insufficient stock"
+		}
+		if result.Code == "" {
+			quantity := inCart[result.ProductID] + result.Quantity
+			var ruleErr *models.OrderRuleError
+			if err := models.CheckCartLimits(product.ID, quantity, cartUnits+result.Quantity, maxCartUnits); errors.As(err, &ruleErr) {
+				result.Code, result.Reason = ruleErr.Code, ruleErr.Message
+			} else if err := product.CheckOrderQuantity(quantity); errors.As(err, &ruleErr) {
+				result.Code, result.Reason = ruleErr.Code, ruleErr.Message
+			} else if err := vacations[product.SellerID].CheckOrders(product.SellerID, product.ID, clk.Now()); errors.As(err, &ruleErr) {
+				result.Code, result.Reason = ruleErr.Code, ruleErr.Message
+			}
+		}
+		if result.Code != "" {
+			result.Status = "rejected"
+			continue
+		}
+
+		result.Item, err = database.AddToCart(c.Request.Context(), userID, result.ProductID, result.Quantity)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add to cart"})
+			return
+		}
+		result.Status = "added"
+		cartUnits += result.Quantity
+		added++
+		recordCartEvent(c, userID, cartActionAdd, result.ProductID, result.Quantity)
+	}
+
+	version, err := database.GetCartVersion(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve cart"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results":  results,
+		"added":    added,
+		"rejected": len(results) - added,
+		"version":  version,
+	})
+}
+
+// checkProductAvailable verifies that the product exists, is published and has the stock
+// for quantity, responding with an error and returning false if not
+func checkProductAvailable(c *gin.Context, productID string, quantity int) (*models.Product, bool) {
+	product, err := database.GetProductByID(c.Request.Context(), productID)
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
-		return
+		return nil, false
 	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify product"})
-		return
+		return nil, false
 	}
 
 	// Check if product is published and has sufficient stock
 	if product.Status != "published" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Product is not available"})
-		return
+		return nil, false
 	}
 
-	if product.Stock < request.Quantity {
+	if product.Stock < quantity {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient stock"})
-		return
+		return nil, false
 	}
 
-	// Add to cart
-	cartItem, err := database.AddToCart(user.ID, request.ProductID, request.Quantity)
+	if !checkSellerTakingOrders(c, product) {
+		return nil, false
+	}
+
+	return product, true
+}
+
+// checkOrderQuantity verifies that the product's purchase limits allow quantity units in
+// one order, responding with the rule's error code and returning false if not
+func checkOrderQuantity(c *gin.Context, product *models.Product, quantity int) bool {
+	var ruleErr *models.OrderRuleError
+	if err := product.CheckOrderQuantity(quantity); errors.As(err, &ruleErr) {
+		c.JSON(http.StatusBadRequest, ruleErr)
+		return false
+	}
+	return true
+}
+
+// checkCartLimits verifies that the cart can hold itemQuantity units of the product after
+// adding addedUnits units to its items other than exceptItemID (empty when adding to the
+// whole cart), responding with the limit and returning false if not
+func checkCartLimits(c *gin.Context, userID, exceptItemID, productID string, itemQuantity, addedUnits int) bool {
+	units, err := database.GetCartUnits(c.Request.Context(), userID, exceptItemID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add to cart"})
-		return
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify cart size"})
+		return false
 	}
 
-	c.JSON(http.StatusCreated, cartItem)
+	var ruleErr *models.OrderRuleError
+	if err := models.CheckCartLimits(productID, itemQuantity, units+addedUnits, services.CartMaxUnits()); errors.As(err, &ruleErr) {
+		c.JSON(http.StatusBadRequest, ruleErr)
+		return false
+	}
+	return true
+}
+
+// quantityInCart returns how many units of the product the user's cart holds (0 if none)
+func quantityInCart(ctx context.Context, userID, productID string) (int, error) {
+	item, err := database.GetCartItemByProduct(ctx, userID, productID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return item.Quantity, nil
 }
 
 // UpdateCartItem updates the quantity of a cart item
@@ -129,7 +478,24 @@ func UpdateCartItem(c *gin.Context) {
 		return
 	}
 
-	err = database.UpdateCartItemQuantity(cartItemID, user.ID, request.Quantity)
+	if request.Quantity > 0 {
+		product, err := database.GetCartItemProduct(c.Request.Context(), cartItemID, user.ID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Cart item not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update cart item"})
+			return
+		}
+		if !checkOrderQuantity(c, product, request.Quantity) {
+			return
+		}
+		if !checkCartLimits(c, user.ID, cartItemID, product.ID, request.Quantity, request.Quantity) {
+			return
+		}
+	}
+
+	err = database.UpdateCartItemQuantity(c.Request.Context(), cartItemID, user.ID, request.Quantity)
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Cart item not found"})
 		return
@@ -138,6 +504,8 @@ func UpdateCartItem(c *gin.Context) {
 		return
 	}
 
+	recordCartEvent(c, user.ID, cartActionUpdate, "", request.Quantity)
+
 	c.JSON(http.StatusOK, gin.H{"message": "Cart item updated successfully"})
 }
 
@@ -163,7 +531,7 @@ func RemoveCartItem(c *gin.Context) {
 		MaxLength:      100,
 	})
 
-	err = database.RemoveFromCart(cartItemID, user.ID)
+	err = database.RemoveFromCart(c.Request.Context(), cartItemID, user.ID)
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Cart item not found"})
 		return
@@ -172,6 +540,8 @@ func RemoveCartItem(c *gin.Context) {
 		return
 	}
 
+	recordCartEvent(c, user.ID, cartActionRemove, "", 0)
+
 	c.JSON(http.StatusOK, gin.H{"message": "Cart item removed successfully"})
 }
 
@@ -183,15 +553,56 @@ func ClearCart(c *gin.Context) {
 		return
 	}
 
-	err = database.ClearCart(user.ID)
+	err = database.ClearCart(c.Request.Context(), user.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear cart"})
 		return
 	}
 
+	recordCartEvent(c, user.ID, cartActionClear, "", 0)
+
 	c.JSON(http.StatusOK, gin.H{"message": "Cart cleared successfully"})
 }
 
+// GetCartSummary returns the cart's subtotal, estimated tax, shipping estimate and total,
+// computed server-side by the pricing package. Only the quantities that can be bought now
+// are counted; items that are unavailable are reported in unavailable_items.
+func GetCartSummary(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	items, err := database.GetCartItems(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve cart"})
+		return
+	}
+
+	lines := make([]pricing.Line, 0, len(items))
+	unavailable, priceChanged := 0, 0
+	for i := range items {
+		item := &items[i]
+		item.CheckAvailability()
+		if item.PriceChanged {
+			priceChanged++
+		}
+		if item.Availability == models.CartItemUnavailable {
+			unavailable++
+			continue
+		}
+		lines = append(lines, pricing.Line{UnitPrice: item.Product.Price, Quantity: item.AvailableQuantity})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"summary":             pricing.ConfigFromEnv().Summarize(lines),
+		"currency":            payments.Currency(),
+		"unavailable_items":   unavailable,
+		"price_changed_items": priceChanged,
+	})
+}
+
 // GetCartCount returns the total number of items in user's cart
 func GetCartCount(c *gin.Context) {
 	user, err := utils.GetAuthUser(c)
@@ -200,7 +611,7 @@ func GetCartCount(c *gin.Context) {
 		return
 	}
 
-	count, err := database.GetCartItemCount(user.ID)
+	count, err := database.GetCartItemCount(c.Request.Context(), user.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get cart count"})
 		return
@@ -208,3 +619,130 @@ func GetCartCount(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"count": count})
 }
+
+// SaveCartItemForLater moves an item out of the user's cart into their saved items
+func SaveCartItemForLater(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	saved, err := database.SaveCartItemForLater(c.Request.Context(), sanitizedIDParam(c), user.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cart item not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save item for later"})
+		return
+	}
+
+	recordCartEvent(c, user.ID, cartActionSave, saved.ProductID, saved.Quantity)
+
+	c.JSON(http.StatusOK, saved)
+}
+
+// GetSavedItems returns the user's saved-for-later items with product details and availability
+func GetSavedItems(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	items, err := database.GetSavedItems(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve saved items"})
+		return
+	}
+
+	for i := range items {
+		items[i].CheckAvailability()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items": items,
+		"count": len(items),
+	})
+}
+
+// MoveSavedItemToCart moves a saved item back into the user's cart. The product must
+// still be published and in stock.
+func MoveSavedItemToCart(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	savedItemID := sanitizedIDParam(c)
+	saved, err := database.GetSavedItem(c.Request.Context(), savedItemID, user.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Saved item not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve saved item"})
+		return
+	}
+
+	// Quantities above the stock are flagged by GetCart rather than refused here
+	product, ok := checkProductAvailable(c, saved.ProductID, 1)
+	if !ok {
+		return
+	}
+
+	inCart, err := quantityInCart(c.Request.Context(), user.ID, saved.ProductID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move item to cart"})
+		return
+	}
+	if !checkOrderQuantity(c, product, inCart+saved.Quantity) {
+		return
+	}
+	if !checkCartLimits(c, user.ID, "", product.ID, inCart+saved.Quantity, saved.Quantity) {
+		return
+	}
+
+	cartItem, err := database.MoveSavedItemToCart(c.Request.Context(), savedItemID, user.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Saved item not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move item to cart"})
+		return
+	}
+
+	recordCartEvent(c, user.ID, cartActionAdd, cartItem.ProductID, saved.Quantity)
+
+	c.JSON(http.StatusOK, cartItem)
+}
+
+// RemoveSavedItem deletes one of the user's saved items
+func RemoveSavedItem(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = database.RemoveSavedItem(c.Request.Context(), sanitizedIDParam(c), user.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Saved item not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove saved item"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Saved item removed successfully"})
+}
+
+// sanitizedIDParam returns the sanitized :id route parameter
+func sanitizedIDParam(c *gin.Context) string {
+	return utils.SanitizeInput(c.Param("id"), utils.SanitizationOptions{
+		TrimWhitespace: true,
+		EscapeHTML:     true,
+		RemoveNewlines: true,
+		MaxLength:      100,
+	})
+}
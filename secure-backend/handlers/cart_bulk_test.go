@@ -0,0 +1,123 @@
+//go:build e2e
+
+// Bulk add-to-cart tests against a real PostgreSQL database (with database/schema.sql applied):
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run TestAddToCartBulk ./handlers
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"secure-backend/database"
+	"secure-backend/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddToCartBulk(t *testing.T) {
+	users := createTestUsers(t, "bulkcart", "seller", "seller", "buyer")
+	seller, away, buyer := users[0], users[1], users[2]
+	ctx := context.Background()
+	t.Setenv("CART_MAX_UNITS", "8")
+
+	product := func(owner *models.AuthUser, name string, stock int, status string) string {
+		var id string
+		require.NoError(t, database.DB.GetContext(ctx, &id, `
+			INSERT INTO products (name, price, stock, status, seller_id, max_order_quantity)
+			VALUES ($1, 2, $2, $3, $4, 4) RETURNING id
+		`, name, stock, status, owner.ID))
+		return id
+	}
+	mug := product(seller, "Bulk mug", 10, "published")
+	plate := product(seller, "Bulk plate", 10, "published")
+	draft := product(seller, "Bulk draft", 10, "draft")
+	rare := product(seller, "Bulk rarity", 1, "published")
+	holiday := product(away, "Bulk holiday", 10, "published")
+	_, err := database.DB.ExecContext(ctx, `UPDATE users SET vacation_starts_at = now() - interval '1 hour' WHERE id = $1`, away.ID)
+	require.NoError(t, err)
+	unknown := uuid.NewString()
+
+	type bulkResponse struct {
+		Results  []BulkCartItemResult `json:"results"`
+		Added    int                  `json:"added"`
+		Rejected int                  `json:"rejected"`
+		Version  int64                `json:"version"`
+	}
+	bulk := func(items ...string) bulkResponse {
+		t.Helper()
+		w := serve(AddToCartBulk, buyer, http.MethodPost, "/api/cart/bulk", `{"items":[`+strings.Join(items, ",")+`]}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response bulkResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+	item := func(productID string, quantity int) string {
+		return fmt.Sprintf(`{"product_id":%q,"quantity":%d}`, productID, quantity)
+	}
+
+	// Valid items are added and the rest rejected with a code, in the order first listed;
+	// repeated products are combined and count what's already in the cart
+	_, err = database.AddToCart(ctx, buyer.ID, mug, 1)
+	require.NoError(t, err)
+	response := bulk(item(mug, 2), item(draft, 1), item(rare, 2), item(unknown, 1), item(holiday, 1), item(mug, 1))
+	require.Len(t, response.Results, 5)
+	want := []struct {
+		productID, status, code string
+		quantity                int
+	}{
+		{mug, "added", "", 3},
+		{draft, "rejected", bulkRejectUnavailable, 1},
+		{rare, "rejected", bulkRejectInsufficientStock, 2},
+		{unknown, "rejected", bulkRejectNotFound, 1},
+		{holiday, "rejected", models.OrderRuleSellerOnVacation, 1},
+	}
+	for i, w := range want {
+		result := response.Results[i]
+		assert.Equal(t, w.productID, result.ProductID, "result %d", i)
+		assert.Equal(t, w.status, result.Status, "result %d", i)
+		assert.Equal(t, w.code, result.Code, "result %d", i)
+		assert.Equal(t, w.quantity, result.Quantity, "result %d", i)
+	}
+	assert.Equal(t, 1, response.Added)
+	assert.Equal(t, 4, response.Rejected)
+	require.NotNil(t, response.Results[0].Item)
+	assert.Equal(t, 4, response.Results[0].Item.Quantity)
+	assert.NotEmpty(t, response.Results[2].Reason)
+	version, err := database.GetCartVersion(ctx, buyer.ID)
+	require.NoError(t, err)
+	assert.Equal(t, version, response.Version)
+
+	// Purchase limits and the cart size apply to each item as the batch fills the cart
+	response = bulk(item(mug, 1), item(plate, 3), item(rare, 1), item(plate, 1))
+	require.Len(t, response.Results, 3)
+	assert.Equal(t, models.OrderRuleAboveMaxQuantity, response.Results[0].Code)
+	assert.Equal(t, "added", response.Results[1].Status)
+	assert.Equal(t, 4, response.Results[1].Quantity)
+	assert.Equal(t, models.OrderRuleAboveCartSizeLimit, response.Results[2].Code)
+	count, err := database.GetCartItemCount(ctx, buyer.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 8, count)
+
+	// Malformed batches are refused whole
+	tooMany := make([]string, maxBulkCartItems+1)
+	for i := range tooMany {
+		tooMany[i] = item(uuid.NewString(), 1)
+	}
+	for name, body := range map[string]string{
+		"too many":      `{"items":[` + strings.Join(tooMany, ",") + `]}`,
+		"empty":         `{"items":[]}`,
+		"zero quantity": `{"items":[` + item(mug, 0) + `]}`,
+		"too large":     `{"items":[` + item(mug, 101) + `]}`,
+		"no product":    `{"items":[{"quantity":1}]}`,
+	} {
+		w := serve(AddToCartBulk, buyer, http.MethodPost, "/api/cart/bulk", body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, name)
+	}
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/tokens"
+	"secure-backend/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cart share link lifetime in hours: the default and the most a buyer can ask for
+const (
+	defaultCartShareHours = 7 * 24
+	maxCartShareHours     = 30 * 24
+)
+
+// ShareCart snapshots the user's cart and returns a signed, expiring token that other
+// users can open to import the items, e.g. for team purchasing
+func ShareCart(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		ExpiresInHours int `json:"expires_in_hours" binding:"omitempty,min=1,max=720"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	hours := request.ExpiresInHours
+	if hours == 0 {
+		hours = defaultCartShareHours
+	}
+
+	share, err := database.CreateCartShare(c.Request.Context(), user.ID, clk.Now().Add(time.Duration(hours)*time.Hour))
+	if errors.Is(err, database.ErrCartEmpty) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cart is empty"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to share cart"})
+		return
+	}
+
+	token, err := tokens.Sign(tokens.PurposeCartShare, share.ID, share.ExpiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to share cart"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"token":      token,
+		"expires_at": share.ExpiresAt,
+		"items":      share.Items,
+	})
+}
+
+// GetSharedCart previews the items of a shared cart with their current availability
+func GetSharedCart(c *gin.Context) {
+	share, ok := requireCartShare(c)
+	if !ok {
+		return
+	}
+
+	productIDs := make([]string, 0, len(share.Items))
+	for _, item := range share.Items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	products, err := database.GetProductsByIDs(c.Request.Context(), productIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load shared cart"})
+		return
+	}
+	for i := range share.Items {
+		share.Items[i].Product = products[share.Items[i].ProductID]
+		share.Items[i].CheckAvailability()
+	}
+
+	c.JSON(http.StatusOK, share)
+}
+
+// ImportSharedCart adds the items of a shared cart to the user's own cart. Items are
+// validated like a bulk add, so unavailable products are reported and skipped.
+func ImportSharedCart(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	share, ok := requireCartShare(c)
+	if !ok {
+		return
+	}
+	if share.UserID == user.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You can't import your own shared cart"})
+		return
+	}
+
+	results := make([]BulkCartItemResult, 0, len(share.Items))
+	for _, item := range share.Items {
+		results = append(results, BulkCartItemResult{ProductID: item.ProductID, Quantity: item.Quantity})
+	}
+
+	addItemsToCart(c, user.ID, results)
+}
+
+// requireCartShare loads the share named by the :token path parameter, responding with
+// 404 and returning false if the token is invalid or the share expired
+func requireCartShare(c *gin.Context) (*models.CartShare, bool) {
+	shareID, err := tokens.Subject(c.Param("token"), tokens.PurposeCartShare, clk.Now())
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shared cart not found or expired"})
+		return nil, false
+	}
+
+	share, err := database.GetCartShare(c.Request.Context(), shareID, clk.Now())
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shared cart not found or expired"})
+		return nil, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load shared cart"})
+		return nil, false
+	}
+	return share, true
+}
@@ -0,0 +1,149 @@
+//go:build e2e
+
+// Shared cart link tests against a real PostgreSQL database (with database/schema.sql applied):
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run TestCartShares ./handlers
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"secure-backend/clock"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/tokens"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCartShares(t *testing.T) {
+	users := createTestUsers(t, "cartshare", "seller", "buyer", "buyer")
+	seller, sharer, teammate := users[0], users[1], users[2]
+	ctx := context.Background()
+
+	tokens.SetKeyring(tokens.NewKeyring(tokens.Key{ID: "test", Secret: []byte("secret")}))
+	mock := clock.NewMock(time.Now())
+	SetClock(mock)
+	t.Cleanup(func() { SetClock(clock.System()) })
+
+	product := func(name string) string {
+		var id string
+		require.NoError(t, database.DB.GetContext(ctx, &id, `
+			INSERT INTO products (name, price, stock, status, seller_id) VALUES ($1, 2, 10, 'published', $2) RETURNING id
+		`, name, seller.ID))
+		return id
+	}
+	mug, lamp, vase := product("Shared mug"), product("Shared lamp"), product("Shared vase")
+
+	share := func(body string) *httptest.ResponseRecorder {
+		return serve(ShareCart, sharer, http.MethodPost, "/api/cart/share", body)
+	}
+	preview := func(token string) *httptest.ResponseRecorder {
+		return serve(GetSharedCart, teammate, http.MethodGet, "/api/cart/shared/"+token, "", gin.Param{Key: "token", Value: token})
+	}
+	importCart := func(user *models.AuthUser, token string) *httptest.ResponseRecorder {
+		return serve(ImportSharedCart, user, http.MethodPost, "/api/cart/shared/"+token+"/import", "", gin.Param{Key: "token", Value: token})
+	}
+
+	// An empty cart can't be shared, and links last at most 30 days
+	assert.Equal(t, http.StatusBadRequest, share("").Code)
+	for _, item := range []struct {
+		productID string
+		quantity  int
+	}{{mug, 2}, {lamp, 1}, {vase, 1}} {
+		_, err := database.AddToCart(ctx, sharer.ID, item.productID, item.quantity)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, http.StatusBadRequest, share(`{"expires_in_hours":721}`).Code)
+
+	w := share("")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var defaultLink struct {
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &defaultLink))
+	assert.WithinDuration(t, mock.Now().Add(7*24*time.Hour), defaultLink.ExpiresAt, time.Second)
+
+	w = share(`{"expires_in_hours":2}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var link struct {
+		Token     string                 `json:"token"`
+		ExpiresAt time.Time              `json:"expires_at"`
+		Items     []models.CartShareItem `json:"items"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+	assert.WithinDuration(t, mock.Now().Add(2*time.Hour), link.ExpiresAt, time.Second)
+	assert.Len(t, link.Items, 3)
+
+	// The link is a snapshot: later changes to the sharer's cart don't show, while the
+	// preview reports the products' current availability
+	_, err := database.AddToCart(ctx, sharer.ID, mug, 5)
+	require.NoError(t, err)
+	_, err = database.DB.ExecContext(ctx, `UPDATE products SET status = 'draft' WHERE id = $1`, vase)
+	require.NoError(t, err)
+	w = preview(link.Token)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var shared models.CartShare
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &shared))
+	items := make(map[string]models.CartShareItem, len(shared.Items))
+	for _, item := range shared.Items {
+		items[item.ProductID] = item
+	}
+	require.Len(t, items, 3)
+	assert.Equal(t, 2, items[mug].Quantity)
+	require.NotNil(t, items[mug].Product)
+	assert.Equal(t, "Shared mug", items[mug].Product.Name)
+	assert.Equal(t, models.CartItemAvailable, items[mug].Availability)
+	assert.Equal(t, models.CartItemUnavailable, items[vase].Availability)
+	assert.NotContains(t, w.Body.String(), sharer.ID, "the preview doesn't reveal who shared the cart")
+
+	// Tokens that are forged or minted for another purpose don't open the share
+	shareID, err := tokens.Subject(link.Token, tokens.PurposeCartShare, mock.Now())
+	require.NoError(t, err)
+	guestToken, err := tokens.Sign(tokens.PurposeGuestCart, shareID, link.ExpiresAt)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, preview(guestToken).Code)
+	assert.Equal(t, http.StatusNotFound, preview(link.Token+"x").Code)
+
+	// Importing adds what can be bought to the importer's cart and reports the rest
+	assert.Equal(t, http.StatusBadRequest, importCart(sharer, link.Token).Code)
+	w = importCart(teammate, link.Token)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var imported struct {
+		Results  []BulkCartItemResult `json:"results"`
+		Added    int                  `json:"added"`
+		Rejected int                  `json:"rejected"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &imported))
+	assert.Equal(t, 2, imported.Added)
+	assert.Equal(t, 1, imported.Rejected)
+	for _, result := range imported.Results {
+		if result.ProductID == vase {
+			assert.Equal(t, bulkRejectUnavailable, result.Code)
+		}
+	}
+	inCart, err := database.GetCartItemByProduct(ctx, teammate.ID, mug)
+	require.NoError(t, err)
+	assert.Equal(t, 2, inCart.Quantity)
+	count, err := database.GetCartItemCount(ctx, teammate.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	// Expired links stop working and are swept away
+	mock.Advance(2*time.Hour + time.Second)
+	assert.Equal(t, http.StatusNotFound, preview(link.Token).Code)
+	assert.Equal(t, http.StatusNotFound, importCart(teammate, link.Token).Code)
+	deleted, err := database.DeleteExpiredCartShares(ctx, mock.Now())
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, deleted, int64(1))
+	_, err = database.GetCartShare(ctx, shareID, link.ExpiresAt.Add(-time.Hour))
+	assert.Equal(t, sql.ErrNoRows, err)
+}
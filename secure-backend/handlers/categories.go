@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxProductTags caps the number of tags on a single product
+const maxProductTags = 20
+
+// taxonomyRequest is the body for creating or updating a category or tag.
+// The slug is derived from the name when omitted.
+type taxonomyRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Slug        string `json:"slug"`
+	Description string `json:"description"`
+}
+
+// bindTaxonomy validates a category or tag body, writing an error response if it is invalid
+func bindTaxonomy(c *gin.Context) (name, slug, description string, ok bool) {
+	var request taxonomyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", "", "", false
+	}
+
+	// Derive the slug from the raw input; the name is HTML-escaped for display
+	slug = request.Slug
+	if strings.TrimSpace(slug) == "" {
+		slug = request.Name
+	}
+	slug = utils.Slugify(slug)
+	name = utils.SanitizeProductName(request.Name)
+	if name == "" || slug == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name must contain letters or digits"})
+		return "", "", "", false
+	}
+
+	description = utils.SanitizeProductDescription(request.Description)
+	return name, slug, description, true
+}
+
+// respondTaxonomyError writes the response for a failed category or tag write
+func respondTaxonomyError(c *gin.Context, err error, notFound, failed string) {
+	switch {
+	case errors.Is(err, database.ErrSlugTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": failed})
+	}
+}
+
+// normalizeProductTags lowercases, slugifies and de-duplicates the tag slugs sent with a product.
+// A nil slice stays nil so updates without tags keep the current ones.
+func normalizeProductTags(tags []string) []string {
+	if tags == nil {
+		return nil
+	}
+
+	slugs := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		slug := utils.Slugify(tag)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		slugs = append(slugs, slug)
+	}
+	return slugs
+}
+
+// GetCategories lists all categories with their published product counts
+func GetCategories(c *gin.Context) {
+	categories, err := database.GetCategories(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load categories"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"categories": categories})
+}
+
+// CreateCategory adds a category (admins only)
+func CreateCategory(c *gin.Context) {
+	name, slug, description, ok := bindTaxonomy(c)
+	if !ok {
+		return
+	}
+
+	category := models.Category{Name: name, Slug: slug, Description: description}
+	if err := database.CreateCategory(c.Request.Context(), &category); err != nil {
+		respondTaxonomyError(c, err, "Category not found", "Failed to save category")
+		return
+	}
+
+	c.JSON(http.StatusCreated, category)
+}
+
+// UpdateCategory renames a category or changes its slug or description (admins only)
+func UpdateCategory(c *gin.Context) {
+	name, slug, description, ok := bindTaxonomy(c)
+	if !ok {
+		return
+	}
+
+	category := models.Category{ID: c.Param("id"), Name: name, Slug: slug, Description: description}
+	if err := database.UpdateCategory(c.Request.Context(), &category); err != nil {
+		respondTaxonomyError(c, err, "Category not found", "Failed to save category")
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// DeleteCategory removes a category; its products become uncategorized (admins only)
+func DeleteCategory(c *gin.Context) {
+	rowsAffected, err := database.DeleteCategory(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete category"})
+		return
+	}
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully"})
+}
+
+// GetTags lists all tags with their published product counts
+func GetTags(c *gin.Context) {
+	tags, err := database.GetTags(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tags": tags})
+}
+
+// CreateTag adds a tag sellers can attach to their products (admins only)
+func CreateTag(c *gin.Context) {
+	name, slug, _, ok := bindTaxonomy(c)
+	if !ok {
+		return
+	}
+
+	tag := models.Tag{Name: name, Slug: slug}
+	if err := database.CreateTag(c.Request.Context(), &tag); err != nil {
+		respondTaxonomyError(c, err, "Tag not found", "Failed to save tag")
+		return
+	}
+
+	c.JSON(http.StatusCreated, tag)
+}
+
+// UpdateTag renames a tag or changes its slug (admins only)
+func UpdateTag(c *gin.Context) {
+	name, slug, _, ok := bindTaxonomy(c)
+	if !ok {
+		return
+	}
+
+	tag := models.Tag{ID: c.Param("id"), Name: name, Slug: slug}
+	if err := database.UpdateTag(c.Request.Context(), &tag); err != nil {
+		respondTaxonomyError(c, err, "Tag not found", "Failed to save tag")
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// DeleteTag removes a tag and detaches it from all products (admins only)
+func DeleteTag(c *gin.Context) {
+	rowsAffected, err := database.DeleteTag(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete tag"})
+		return
+	}
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tag deleted successfully"})
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/services"
+	"secure-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Checkout converts the buyer's cart into a pending order, holding the stock
+// until the order is paid or the reservation expires
+func Checkout(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		ShippingAddress string `json:"shipping_address" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	address := utils.SanitizeAddress(request.ShippingAddress)
+	if address == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Shipping address is required"})
+		return
+	}
+
+	order, reservations, err := services.Checkout(c.Request.Context(), user, utils.GetClientInfo(c), address)
+	if err != nil {
+		var stockErr *database.StockError
+		var ruleErr *models.OrderRuleError
+		switch {
+		case errors.Is(err, database.ErrCartEmpty):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cart is empty"})
+		case errors.As(err, &stockErr):
+			c.JSON(http.StatusConflict, gin.H{
+				"error":      stockErr.Error(),
+				"code":       stockErr.Reason,
+				"product_id": stockErr.ProductID,
+				"available":  stockErr.Available,
+			})
+		case errors.As(err, &ruleErr):
+			c.JSON(http.StatusConflict, ruleErr)
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
+		}
+		return
+	}
+
+	response := gin.H{
+		"order":        order,
+		"reservations": reservations,
+	}
+	if len(reservations) > 0 {
+		response["reserved_until"] = reservations[0].ExpiresAt
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
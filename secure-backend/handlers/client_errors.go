@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/middleware"
+	"secure-backend/models"
+	"secure-backend/utils"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxClientErrorBatch caps the number of reports accepted per request
+	maxClientErrorBatch = 20
+	// MaxClientErrorBodySize caps the request body of a client error batch
+	MaxClientErrorBodySize = 64 << 10
+)
+
+// clientErrorReport is a single error report as sent by clients
+type clientErrorReport struct {
+	Message    string     `json:"message" binding:"required"`
+	Stack      string     `json:"stack"`
+	URL        string     `json:"url"`
+	Component  string     `json:"component"`
+	RequestID  string     `json:"request_id"`
+	OccurredAt *time.Time `json:"occurred_at"`
+}
+
+// singleLineOptions sanitizes short single-line report fields
+var singleLineOptions = utils.SanitizationOptions{
+	TrimWhitespace: true,
+	EscapeHTML:     true,
+	RemoveNewlines: true,
+	MaxLength:      200,
+}
+
+// ReportClientErrors accepts a batch of frontend error reports and stores them
+// with the reporting request's ID and the authenticated user
+func ReportClientErrors(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		Errors []clientErrorReport `json:"errors" binding:"required,min=1,dive"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(request.Errors) > maxClientErrorBatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Too many error reports in a single batch"})
+		return
+	}
+
+	client := utils.GetClientInfo(c)
+	requestID := c.GetString(middleware.RequestIDKey)
+	userAgent := utils.SanitizeInput(c.GetHeader("User-Agent"), singleLineOptions)
+
+	reports := make([]models.ClientError, 0, len(request.Errors))
+	for _, e := range request.Errors {
+		message := utils.SanitizeInput(utils.RemoveControlCharacters(e.Message), utils.DefaultTextOptions)
+		if strings.TrimSpace(message) == "" {
+			continue
+		}
+
+		reports = append(reports, models.ClientError{
+			UserID:          user.ID,
+			RequestID:       requestID,
+			ClientRequestID: utils.SanitizeInput(e.RequestID, singleLineOptions),
+			Message:         message,
+			Stack:           utils.SanitizeInput(utils.RemoveControlCharacters(e.Stack), utils.DefaultDescriptionOptions),
+			URL:             utils.SanitizeInput(e.URL, utils.SanitizationOptions{TrimWhitespace: true, EscapeHTML: true, RemoveNewlines: true, MaxLength: 2000}),
+			Component:       utils.SanitizeInput(e.Component, singleLineOptions),
+			UserAgent:       userAgent,
+			Platform:        client.Platform,
+			AppVersion:      client.AppVersion,
+			OccurredAt:      e.OccurredAt,
+		})
+	}
+
+	if len(reports) > 0 {
+		if err := database.CreateClientErrors(c.Request.Context(), reports); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store error reports"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"accepted": len(reports), "request_id": requestID})
+}
+
+// GetClientErrors lists client error reports
+// Only admins can view error reports; supports ?platform= and limit/offset pagination
+func GetClientErrors(c *gin.Context) {
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	platform := strings.ToLower(strings.TrimSpace(c.Query("platform")))
+
+	reports, total, err := database.GetClientErrors(c.Request.Context(), platform, page.Limit, page.Offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load error reports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"errors": reports,
+		"total":  total,
+		"limit":  page.Limit,
+		"offset": page.Offset,
+	})
+}
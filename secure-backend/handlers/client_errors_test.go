@@ -0,0 +1,125 @@
+//go:build e2e
+
+// Client error reporting tests against a real PostgreSQL database (with database/schema.sql applied):
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run TestClientErrors ./handlers
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"secure-backend/database"
+	"secure-backend/middleware"
+	"secure-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientErrors(t *testing.T) {
+	users := createTestUsers(t, "clienterrors", "buyer", "admin")
+	buyer, admin := users[0], users[1]
+
+	// A platform of the test's own keeps the admin listing to its reports
+	platform := "test-" + uuid.NewString()[:8]
+	t.Cleanup(func() {
+		database.DB.ExecContext(context.Background(), `DELETE FROM client_errors WHERE platform = $1`, platform)
+	})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.RequestID())
+	router.POST("/api/client-errors", func(c *gin.Context) {
+		c.Set("user", buyer)
+		c.Set("client_info", &models.ClientInfo{Platform: platform, AppVersion: "2.3.1"})
+		ReportClientErrors(c)
+	})
+	report := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/client-errors", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "ShopApp/2.3.1\n(iPhone)")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	list := func(query string) (reports []models.ClientError, total int) {
+		t.Helper()
+		w := serve(GetClientErrors, admin, http.MethodGet, "/api/admin/client-errors?platform="+platform+query, "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response struct {
+			Errors []models.ClientError `json:"errors"`
+			Total  int                  `json:"total"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response.Errors, response.Total
+	}
+
+	// Reports are sanitized, blank ones dropped, and stamped with the reporting request and client
+	w := report(`{"errors":[
+		{"message":"<script>alert(1)</script> failed\u0007","stack":"at Cart (cart.js:10)\nat App (app.js:3)",
+		 "url":"https://shop.example/cart","component":"Cart","request_id":"req-123","occurred_at":"2026-10-01T12:00:00Z"},
+		{"message":"   "}
+	]}`)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var accepted struct {
+		Accepted  int    `json:"accepted"`
+		RequestID string `json:"request_id"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &accepted))
+	assert.Equal(t, 1, accepted.Accepted)
+	assert.Equal(t, w.Header().Get(middleware.RequestIDHeader), accepted.RequestID)
+
+	reports, total := list("")
+	require.Equal(t, 1, total)
+	require.Len(t, reports, 1)
+	stored := reports[0]
+	assert.Equal(t, "&lt;script&gt;alert(1)&lt;/script&gt; failed", stored.Message)
+	assert.Equal(t, "at Cart (cart.js:10)\nat App (app.js:3)", stored.Stack)
+	assert.Equal(t, "https://shop.example/cart", stored.URL)
+	assert.Equal(t, "Cart", stored.Component)
+	assert.Equal(t, "req-123", stored.ClientRequestID)
+	assert.Equal(t, accepted.RequestID, stored.RequestID)
+	assert.Equal(t, buyer.ID, stored.UserID)
+	assert.Equal(t, platform, stored.Platform)
+	assert.Equal(t, "2.3.1", stored.AppVersion)
+	assert.NotContains(t, stored.UserAgent, "\n")
+	require.NotNil(t, stored.OccurredAt)
+	assert.Equal(t, 2026, stored.OccurredAt.Year())
+
+	// Newest first, paginated and filtered by platform
+	require.Equal(t, http.StatusAccepted, report(`{"errors":[{"message":"second"},{"message":"third"}]}`).Code)
+	reports, total = list("&limit=2")
+	assert.Equal(t, 3, total)
+	require.Len(t, reports, 2)
+	assert.NotEqual(t, stored.ID, reports[0].ID)
+	reports, _ = list("&limit=2&offset=2")
+	require.Len(t, reports, 1)
+	assert.Equal(t, stored.ID, reports[0].ID)
+	w = serve(GetClientErrors, admin, http.MethodGet, "/api/admin/client-errors?platform=other-"+platform, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"total":0`)
+
+	// Malformed batches are refused whole
+	batch := make([]string, maxClientErrorBatch+1)
+	for i := range batch {
+		batch[i] = fmt.Sprintf(`{"message":"error %d"}`, i)
+	}
+	for name, body := range map[string]string{
+		"too many":        `{"errors":[` + strings.Join(batch, ",") + `]}`,
+		"empty":           `{"errors":[]}`,
+		"missing message": `{"errors":[{"stack":"at main"}]}`,
+		"not json":        `errors`,
+	} {
+		assert.Equal(t, http.StatusBadRequest, report(body).Code, name)
+	}
+	_, total = list("")
+	assert.Equal(t, 3, total)
+}
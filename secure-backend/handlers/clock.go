@@ -0,0 +1,12 @@
+package handlers
+
+import "secure-backend/clock"
+
+// clk stamps sync cursors, checks download link expiry and seller vacations, and anchors
+// default report ranges and the price history window
+var clk clock.Clock = clock.System()
+
+// SetClock replaces the clock used by handlers (tests use a clock.Mock)
+func SetClock(c clock.Clock) {
+	clk = c
+}
@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"net/http"
+	"secure-backend/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetEffectiveConfig reports every setting the API reads with its effective value and
+// whether it came from the environment, the .env file or the default. Secrets are masked
+// and carry a fingerprint instead, so two environments can be compared without exposing
+// them. Only admins can view it.
+func GetEffectiveConfig(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"settings": config.Effective()})
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/services"
+	"secure-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RecordConsent stores the user's consent to analytics and marketing under the policy
+// version they were shown. Each call adds to the history; the latest choices apply.
+func RecordConsent(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		Analytics     *bool  `json:"analytics" binding:"required"`
+		Marketing     *bool  `json:"marketing" binding:"required"`
+		PolicyVersion string `json:"policy_version" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	version := utils.SanitizeInput(request.PolicyVersion, singleLineOptions)
+	if version == "" || len(version) > 32 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "policy_version must be 1 to 32 characters"})
+		return
+	}
+
+	consent, err := services.RecordConsent(c.Request.Context(), user.ID, *request.Analytics, *request.Marketing, version, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record consent"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, consent)
+}
+
+// GetConsent returns the user's current consent choices. Users who never chose have
+// consented to nothing ("recorded": false).
+func GetConsent(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	consent, err := database.GetConsent(c.Request.Context(), user.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusOK, gin.H{"recorded": false, "analytics": false, "marketing": false})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load consent"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recorded":       true,
+		"analytics":      consent.Analytics,
+		"marketing":      consent.Marketing,
+		"policy_version": consent.PolicyVersion,
+		"recorded_at":    consent.CreatedAt,
+	})
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/jobs"
+	"secure-backend/payments"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxDeadLetterBatch caps the number of items requeued or discarded per request
+const maxDeadLetterBatch = 100
+
+// deadLetterSource validates the :source URL parameter, writing an error response if it is unknown
+func deadLetterSource(c *gin.Context) (string, bool) {
+	source := c.Param("source")
+	if !database.IsValidDeadLetterSource(source) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid source. Must be jobs or webhooks"})
+		return "", false
+	}
+	return source, true
+}
+
+// GetDeadLetters lists failed jobs or webhook events awaiting an operator
+func GetDeadLetters(c *gin.Context) {
+	source, ok := deadLetterSource(c)
+	if !ok {
+		return
+	}
+
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	letters, total, err := database.GetDeadLetters(c.Request.Context(), source, page.Limit, page.Offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load dead letters"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"dead_letters": letters,
+		"total":        total,
+		"limit":        page.Limit,
+		"offset":       page.Offset,
+	})
+}
+
+// GetDeadLetter returns a failed item with its payload and error history
+func GetDeadLetter(c *gin.Context) {
+	source, ok := deadLetterSource(c)
+	if !ok {
+		return
+	}
+
+	letter, err := database.GetDeadLetter(c.Request.Context(), source, c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dead letter not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dead letter"})
+		return
+	}
+
+	history, err := database.GetDeliveryErrors(c.Request.Context(), source, letter.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load error history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"dead_letter": letter, "errors": history})
+}
+
+// bindDeadLetterIDs binds {"ids": [...]} from the body, writing an error response on failure
+func bindDeadLetterIDs(c *gin.Context) ([]string, bool) {
+	var request struct {
+		IDs []string `json:"ids" binding:"required,min=1,dive,required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if len(request.IDs) > maxDeadLetterBatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Too many items in a single request"})
+		return nil, false
+	}
+	return request.IDs, true
+}
+
+// RequeueDeadLetters retries failed items: jobs go back in the job queue and webhook events
+// are processed again immediately. Results are reported per item.
+func RequeueDeadLetters(c *gin.Context) {
+	source, ok := deadLetterSource(c)
+	if !ok {
+		return
+	}
+
+	ids, ok := bindDeadLetterIDs(c)
+	if !ok {
+		return
+	}
+
+	type result struct {
+		ID     string `json:"id"`
+		Status string `json:"status"` // requeued, processed, skipped, failed
+		Error  string `json:"error,omitempty"`
+	}
+	results := make([]result, 0, len(ids))
+
+	for _, id := range ids {
+		if _, err := database.GetDeadLetter(c.Request.Context(), source, id); err == sql.ErrNoRows {
+			results = append(results, result{ID: id, Status: "skipped", Error: "not in the dead-letter queue"})
+			continue
+		} else if err != nil {
+			results = append(results, result{ID: id, Status: "failed", Error: "failed to load item"})
+			continue
+		}
+
+		switch source {
+		case database.DeadLetterJobs:
+			if _, err := jobs.Retry(c.Request.Context(), id); err != nil {
+				results = append(results, result{ID: id, Status: "failed", Error: err.Error()})
+				continue
+			}
+			results = append(results, result{ID: id, Status: "requeued"})
+
+		case database.DeadLetterWebhooks:
+			if err := payments.ReplayWebhookEvent(c.Request.Context(), id); err != nil {
+				results = append(results, result{ID: id, Status: "failed", Error: err.Error()})
+				continue
+			}
+			results = append(results, result{ID: id, Status: "processed"})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+// DiscardDeadLetters drops failed items from the dead-letter queue without retrying them
+func DiscardDeadLetters(c *gin.Context) {
+	source, ok := deadLetterSource(c)
+	if !ok {
+		return
+	}
+
+	ids, ok := bindDeadLetterIDs(c)
+	if !ok {
+		return
+	}
+
+	discarded, err := database.DiscardDeadLetters(c.Request.Context(), source, ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to discard dead letters"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"discarded": discarded})
+}
@@ -0,0 +1,276 @@
+//go:build e2e
+
+// Dead-letter queue tests against a real PostgreSQL database (with database/schema.sql applied):
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run TestDeadLetter ./handlers
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/payments"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deadLetterResult is an item's outcome in a requeue response
+type deadLetterResult struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error"`
+}
+
+// deadLetterIDsBody encodes ids as a requeue or discard request body
+func deadLetterIDsBody(ids ...string) string {
+	data, _ := json.Marshal(map[string][]string{"ids": ids})
+	return string(data)
+}
+
+// listDeadLetters returns the IDs on the first page of a source's dead letters and their total
+func listDeadLetters(t *testing.T, admin *models.AuthUser, source string) (map[string]models.DeadLetter, int) {
+	t.Helper()
+	w := serve(GetDeadLetters, admin, http.MethodGet, "/api/admin/dead-letters/"+source+"?limit=100", "",
+		gin.Param{Key: "source", Value: source})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		DeadLetters []models.DeadLetter `json:"dead_letters"`
+		Total       int                 `json:"total"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	letters := make(map[string]models.DeadLetter, len(response.DeadLetters))
+	for _, letter := range response.DeadLetters {
+		letters[letter.ID] = letter
+	}
+	return letters, response.Total
+}
+
+// requeueDeadLetters requeues ids and returns the results by ID
+func requeueDeadLetters(t *testing.T, admin *models.AuthUser, source string, ids ...string) map[string]deadLetterResult {
+	t.Helper()
+	w := serve(RequeueDeadLetters, admin, http.MethodPost, "/api/admin/dead-letters/"+source+"/requeue",
+		deadLetterIDsBody(ids...), gin.Param{Key: "source", Value: source})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Results []deadLetterResult `json:"results"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	results := make(map[string]deadLetterResult, len(response.Results))
+	for _, result := range response.Results {
+		results[result.ID] = result
+	}
+	return results
+}
+
+// discardDeadLetters discards ids and returns how many were discarded
+func discardDeadLetters(t *testing.T, admin *models.AuthUser, source string, ids ...string) int {
+	t.Helper()
+	w := serve(DiscardDeadLetters, admin, http.MethodPost, "/api/admin/dead-letters/"+source+"/discard",
+		deadLetterIDsBody(ids...), gin.Param{Key: "source", Value: source})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Discarded int `json:"discarded"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response.Discarded
+}
+
+// inspectDeadLetter returns the status code of fetching a dead letter and its error history
+func inspectDeadLetter(t *testing.T, admin *models.AuthUser, source, id string) (int, []models.DeliveryError) {
+	t.Helper()
+	w := serve(GetDeadLetter, admin, http.MethodGet, "/api/admin/dead-letters/"+source+"/"+id, "",
+		gin.Param{Key: "source", Value: source}, gin.Param{Key: "id", Value: id})
+	var response struct {
+		DeadLetter models.DeadLetter      `json:"dead_letter"`
+		Errors     []models.DeliveryError `json:"errors"`
+	}
+	if w.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, id, response.DeadLetter.ID)
+	}
+	return w.Code, response.Errors
+}
+
+func TestDeadLetterJobs(t *testing.T) {
+	users := createTestUsers(t, "deadletter", "admin", "buyer")
+	admin, owner := users[0], users[1]
+	ctx := context.Background()
+
+	var ids []string
+	t.Cleanup(func() {
+		database.DB.ExecContext(context.Background(), `DELETE FROM delivery_errors WHERE item_id = ANY($1)`, pq.Array(ids))
+	})
+
+	// Jobs are scheduled far ahead so no worker claims them once requeued
+	failedJob := func(message string) string {
+		t.Helper()
+		var id string
+		require.NoError(t, database.DB.GetContext(ctx, &id, `
+			INSERT INTO jobs (user_id, type, status, attempts, run_at) VALUES ($1, 'dead_letter_test', 'running', 1, now() + interval '1 day')
+			RETURNING id
+		`, owner.ID))
+		require.NoError(t, database.FailJob(ctx, id, message))
+		ids = append(ids, id)
+		return id
+	}
+	jobStatus := func(id string) string {
+		t.Helper()
+		var status string
+		require.NoError(t, database.DB.GetContext(ctx, &status, `SELECT status FROM jobs WHERE id = $1`, id))
+		return status
+	}
+	first, second, third := failedJob("first failed"), failedJob("second failed"), failedJob("third failed")
+
+	// Failed jobs are listed with their owner and last error
+	letters, total := listDeadLetters(t, admin, database.DeadLetterJobs)
+	assert.GreaterOrEqual(t, total, 3)
+	for id, message := range map[string]string{first: "first failed", second: "second failed", third: "third failed"} {
+		require.Contains(t, letters, id)
+		assert.Equal(t, message, letters[id].Error)
+		assert.Equal(t, owner.ID, letters[id].OwnerID)
+		assert.Equal(t, "dead_letter_test", letters[id].Type)
+	}
+
+	// The queue is paged
+	w := serve(GetDeadLetters, admin, http.MethodGet, "/api/admin/dead-letters/jobs?limit=2", "",
+		gin.Param{Key: "source", Value: database.DeadLetterJobs})
+	require.Equal(t, http.StatusOK, w.Code)
+	var page struct {
+		DeadLetters []models.DeadLetter `json:"dead_letters"`
+		Total       int                 `json:"total"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Len(t, page.DeadLetters, 2)
+	assert.GreaterOrEqual(t, page.Total, 3)
+
+	// Inspecting one shows its error history
+	code, history := inspectDeadLetter(t, admin, database.DeadLetterJobs, first)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, history, 1)
+	assert.Equal(t, "first failed", history[0].Error)
+	assert.Equal(t, 1, history[0].Attempt)
+
+	// Requeueing in bulk puts the jobs back in the queue and skips items that aren't failed
+	missing := uuid.NewString()
+	results := requeueDeadLetters(t, admin, database.DeadLetterJobs, first, second, missing)
+	require.Len(t, results, 3)
+	assert.Equal(t, "requeued", results[first].Status)
+	assert.Equal(t, "requeued", results[second].Status)
+	assert.Equal(t, "skipped", results[missing].Status)
+	assert.Equal(t, "queued", jobStatus(first))
+	assert.Equal(t, "queued", jobStatus(second))
+	code, _ = inspectDeadLetter(t, admin, database.DeadLetterJobs, first)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	// Discarding in bulk only drops jobs that are still failed, and leaves them failed
+	assert.Equal(t, 1, discardDeadLetters(t, admin, database.DeadLetterJobs, third, first))
+	assert.Equal(t, "failed", jobStatus(third))
+	assert.Equal(t, "queued", jobStatus(first))
+	letters, _ = listDeadLetters(t, admin, database.DeadLetterJobs)
+	for _, id := range []string{first, second, third} {
+		assert.NotContains(t, letters, id)
+	}
+	assert.Zero(t, discardDeadLetters(t, admin, database.DeadLetterJobs, third))
+
+	// A discarded job can't be requeued from the dead-letter queue
+	results = requeueDeadLetters(t, admin, database.DeadLetterJobs, third)
+	assert.Equal(t, "skipped", results[third].Status)
+	assert.Equal(t, "failed", jobStatus(third))
+}
+
+func TestDeadLetterWebhooks(t *testing.T) {
+	admin := createTestUsers(t, "deadletter", "admin")[0]
+	ctx := context.Background()
+
+	var ids []string
+	t.Cleanup(func() {
+		database.DB.ExecContext(context.Background(), `DELETE FROM delivery_errors WHERE item_id = ANY($1)`, pq.Array(ids))
+		database.DB.ExecContext(context.Background(), `DELETE FROM webhook_events WHERE id = ANY($1)`, pq.Array(ids))
+	})
+
+	// failedEvent records a webhook event whose processing failed
+	failedEvent := func(eventType, object string) string {
+		t.Helper()
+		eventID := "evt_" + uuid.NewString()
+		payload := `{"id":"` + eventID + `","type":"` + eventType + `","data":{"object":` + object + `}}`
+		_, err := database.RecordWebhookEvent(ctx, payments.ProviderStripe, eventID, eventType, []byte(payload))
+		require.NoError(t, err)
+		require.NoError(t, database.MarkWebhookEventFailed(ctx, payments.ProviderStripe, eventID, "handler crashed"))
+		var id string
+		require.NoError(t, database.DB.GetContext(ctx, &id, `
+			SELECT id FROM webhook_events WHERE provider = $1 AND event_id = $2
+		`, payments.ProviderStripe, eventID))
+		ids = append(ids, id)
+		return id
+	}
+	// An event type the shop ignores processes fine on replay; a malformed payment intent fails again
+	recovered := failedEvent("customer.created", `{}`)
+	broken := failedEvent(payments.EventPaymentSucceeded, `"not an intent"`)
+	discarded := failedEvent("customer.updated", `{}`)
+
+	letters, _ := listDeadLetters(t, admin, database.DeadLetterWebhooks)
+	for _, id := range []string{recovered, broken, discarded} {
+		require.Contains(t, letters, id)
+		assert.Equal(t, "handler crashed", letters[id].Error)
+	}
+	assert.Equal(t, "customer.created", letters[recovered].Type)
+
+	results := requeueDeadLetters(t, admin, database.DeadLetterWebhooks, recovered, broken)
+	assert.Equal(t, "processed", results[recovered].Status)
+	assert.Equal(t, "failed", results[broken].Status)
+	assert.Contains(t, results[broken].Error, "decoding payment intent")
+
+	// The processed event leaves the queue; the failed one stays with another attempt in its history
+	code, _ := inspectDeadLetter(t, admin, database.DeadLetterWebhooks, recovered)
+	assert.Equal(t, http.StatusNotFound, code)
+	code, history := inspectDeadLetter(t, admin, database.DeadLetterWebhooks, broken)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, history, 2)
+	assert.Equal(t, "handler crashed", history[0].Error)
+	assert.Equal(t, 2, history[1].Attempt)
+	assert.Contains(t, history[1].Error, "decoding payment intent")
+
+	// Discarding drops unprocessed events, but not ones that were processed
+	assert.Equal(t, 2, discardDeadLetters(t, admin, database.DeadLetterWebhooks, broken, discarded, recovered))
+	letters, _ = listDeadLetters(t, admin, database.DeadLetterWebhooks)
+	for _, id := range []string{recovered, broken, discarded} {
+		assert.NotContains(t, letters, id)
+	}
+	results = requeueDeadLetters(t, admin, database.DeadLetterWebhooks, discarded)
+	assert.Equal(t, "skipped", results[discarded].Status)
+}
+
+func TestDeadLetterRequests(t *testing.T) {
+	admin := createTestUsers(t, "deadletter", "admin")[0]
+
+	w := serve(GetDeadLetters, admin, http.MethodGet, "/api/admin/dead-letters/emails", "", gin.Param{Key: "source", Value: "emails"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	ids := make([]string, maxDeadLetterBatch+1)
+	for i := range ids {
+		ids[i] = uuid.NewString()
+	}
+	for name, body := range map[string]string{
+		"too many": deadLetterIDsBody(ids...),
+		"empty":    deadLetterIDsBody(),
+		"blank id": deadLetterIDsBody(""),
+		"no ids":   `{}`,
+	} {
+		for _, handler := range []gin.HandlerFunc{RequeueDeadLetters, DiscardDeadLetters} {
+			w := serve(handler, admin, http.MethodPost, "/api/admin/dead-letters/jobs", body, gin.Param{Key: "source", Value: database.DeadLetterJobs})
+			assert.Equal(t, http.StatusBadRequest, w.Code, name)
+		}
+	}
+
+	w = serve(GetDeadLetters, admin, http.MethodGet, "/api/admin/dead-letters/jobs?limit=0", "", gin.Param{Key: "source", Value: database.DeadLetterJobs})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "limit")
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	rpprof "runtime/pprof"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Pprof serves the net/http/pprof handlers under /debug/pprof. CPU profiles and traces
+// run for ?seconds=, which must stay below the server's 15 second write timeout.
+// Access is checked by middleware.DebugAccess.
+func Pprof(c *gin.Context) {
+	switch c.Param("name") {
+	case "/cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "/profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "/symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "/trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		// The index, and named profiles such as /heap and /goroutine
+		pprof.Index(c.Writer, c.Request)
+	}
+}
+
+// RuntimeStats is a snapshot of the Go runtime's memory and scheduler state
+type RuntimeStats struct {
+	Timestamp  time.Time   `json:"timestamp"`
+	Version    string      `json:"version"`
+	NumCPU     int         `json:"num_cpu"`
+	GOMAXPROCS int         `json:"gomaxprocs"`
+	MemLimit   int64       `json:"memory_limit_bytes"` // GOMEMLIMIT
+	Goroutines int         `json:"goroutines"`
+	Heap       HeapStats   `json:"heap"`
+	Memory     MemoryStats `json:"memory"`
+	GC         GCStats     `json:"gc"`
+	Stacks     string      `json:"goroutine_dump,omitempty"` // with ?goroutines=true
+}
+
+// HeapStats describes the heap
+type HeapStats struct {
+	AllocBytes    uint64 `json:"alloc_bytes"`
+	InuseBytes    uint64 `json:"inuse_bytes"`
+	IdleBytes     uint64 `json:"idle_bytes"`
+	ReleasedBytes uint64 `json:"released_bytes"`
+	Objects       uint64 `json:"objects"`
+	NextGCBytes   uint64 `json:"next_gc_bytes"`
+}
+
+// MemoryStats describes memory obtained from the OS
+type MemoryStats struct {
+	SysBytes        uint64 `json:"sys_bytes"`
+	StackInuseBytes uint64 `json:"stack_inuse_bytes"`
+	TotalAllocBytes uint64 `json:"total_alloc_bytes"`
+	Mallocs         uint64 `json:"mallocs"`
+	Frees           uint64 `json:"frees"`
+}
+
+// GCStats describes garbage collection
+type GCStats struct {
+	NumGC         int64      `json:"num_gc"`
+	LastGC        *time.Time `json:"last_gc,omitempty"`
+	PauseTotalMs  float64    `json:"pause_total_ms"`
+	RecentPauseMs []float64  `json:"recent_pauses_ms"` // newest first
+	CPUFraction   float64    `json:"cpu_fraction"`
+}
+
+// maxRecentGCPauses caps the pauses listed in GCStats
+const maxRecentGCPauses = 10
+
+// GetRuntimeDebug reports heap, memory and GC statistics and the goroutine count. With
+// ?goroutines=true it adds a dump of every goroutine's stack. Access is checked by
+// middleware.DebugAccess.
+func GetRuntimeDebug(c *gin.Context) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	var gc debug.GCStats
+	debug.ReadGCStats(&gc)
+
+	stats := RuntimeStats{
+		Timestamp:  clk.Now(),
+		Version:    runtime.Version(),
+		NumCPU:     runtime.NumCPU(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		Goroutines: runtime.NumGoroutine(),
+		MemLimit:   debug.SetMemoryLimit(-1),
+		Heap: HeapStats{
+			AllocBytes:    mem.HeapAlloc,
+			InuseBytes:    mem.HeapInuse,
+			IdleBytes:     mem.HeapIdle,
+			ReleasedBytes: mem.HeapReleased,
+			Objects:       mem.HeapObjects,
+			NextGCBytes:   mem.NextGC,
+		},
+		Memory: MemoryStats{
+			SysBytes:        mem.Sys,
+			StackInuseBytes: mem.StackInuse,
+			TotalAllocBytes: mem.TotalAlloc,
+			Mallocs:         mem.Mallocs,
+			Frees:           mem.Frees,
+		},
+		GC: GCStats{
+			NumGC:         gc.NumGC,
+			PauseTotalMs:  float64(gc.PauseTotal.Microseconds()) / 1000,
+			RecentPauseMs: []float64{},
+			CPUFraction:   mem.GCCPUFraction,
+		},
+	}
+	if !gc.LastGC.IsZero() {
+		stats.GC.LastGC = &gc.LastGC
+	}
+	for i, pause := range gc.Pause {
+		if i == maxRecentGCPauses {
+			break
+		}
+		stats.GC.RecentPauseMs = append(stats.GC.RecentPauseMs, float64(pause.Microseconds())/1000)
+	}
+
+	if c.Query("goroutines") == "true" {
+		var dump bytes.Buffer
+		if err := rpprof.Lookup("goroutine").WriteTo(&dump, 2); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to dump goroutines"})
+			return
+		}
+		stats.Stacks = dump.String()
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"secure-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StartDemoSession issues a one-hour Bearer token for the demo account of a role
+// ({"role": "buyer"|"seller"|"admin"}), so sales demos and the public sandbox need no
+// sign-up. Returns 404 unless DEMO_MODE is on.
+func StartDemoSession(c *gin.Context) {
+	var request struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, expiresAt, user, err := services.IssueDemoSession(request.Role)
+	switch {
+	case errors.Is(err, services.ErrDemoModeOff):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrNoDemoAccount):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("Failed to issue demo session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start demo session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":      token,
+		"token_type": "Bearer",
+		"expires_at": expiresAt,
+		"user":       user,
+	})
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/payments"
+	"secure-backend/utils"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// disputeEvidenceOptions sanitizes evidence text without escaping it, since it is sent to the
+// card issuer rather than rendered
+var disputeEvidenceOptions = utils.SanitizationOptions{
+	TrimWhitespace: true,
+	MaxLength:      5000,
+	PreserveSpaces: true,
+}
+
+// GetDisputes lists payment disputes for admins, earliest evidence deadline first
+// (?state=open|closed, default open; paginated)
+func GetDisputes(c *gin.Context) {
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	state := c.DefaultQuery("state", "open")
+	if state != "open" && state != "closed" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "state must be open or closed"})
+		return
+	}
+
+	disputes, total, err := database.GetDisputes(c.Request.Context(), state == "closed", page.Limit, page.Offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load disputes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"disputes": disputes,
+		"total":    total,
+		"limit":    page.Limit,
+		"offset":   page.Offset,
+	})
+}
+
+// GetDispute returns a dispute with the payout holds and the buyer-seller message threads of
+// its order (admins only)
+func GetDispute(c *gin.Context) {
+	dispute, err := database.GetDispute(c.Request.Context(), sanitizedIDParam(c))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dispute not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load dispute"})
+		return
+	}
+
+	holds, err := database.GetPayoutHolds(c.Request.Context(), dispute.OrderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load dispute"})
+		return
+	}
+
+	threads, err := database.GetOrderMessageThreads(c.Request.Context(), dispute.OrderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load dispute"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"dispute": dispute, "payout_holds": holds, "message_threads": threads})
+}
+
+// SubmitDisputeEvidence adds text evidence to an open dispute and, with submit, sends it to the
+// card issuer for review. Only the provider's text evidence fields are accepted (see
+// models.DisputeEvidenceFields). The outcome arrives later through the payment webhook.
+func SubmitDisputeEvidence(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		Evidence map[string]string `json:"evidence"`
+		Submit   bool              `json:"submit"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(request.Evidence) == 0 && !request.Submit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "evidence is required unless submitting"})
+		return
+	}
+
+	evidence := models.DisputeEvidence{}
+	fields := make([]string, 0, len(request.Evidence))
+	for field, value := range request.Evidence {
+		if !models.DisputeEvidenceFields[field] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unsupported evidence field %q", field)})
+			return
+		}
+		evidence[field] = utils.SanitizeInput(value, disputeEvidenceOptions)
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	id := sanitizedIDParam(c)
+	action := "staged"
+	if request.Submit {
+		action = "submitted"
+	}
+	dispute, err := payments.SubmitDisputeEvidence(c.Request.Context(), id, evidence, request.Submit, &models.AuditEntry{
+		ActorID:    &user.ID,
+		ActorEmail: user.Email,
+		Action:     models.AuditDisputeEvidence,
+		Detail:     fmt.Sprintf("%s evidence for dispute %s: %s", action, id, strings.Join(fields, ", ")),
+		IPAddress:  c.ClientIP(),
+	})
+	switch {
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dispute not found"})
+		return
+	case errors.Is(err, database.ErrDisputeClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		respondPaymentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dispute)
+}
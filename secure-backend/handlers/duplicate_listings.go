@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetDuplicateListings lists probable duplicate listings found by the background scan,
+// highest score first (?status=pending|dismissed|confirmed, default pending; paginated).
+// Only admins can review duplicates.
+func GetDuplicateListings(c *gin.Context) {
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := c.DefaultQuery("status", models.DuplicatePending)
+	switch status {
+	case models.DuplicatePending, models.DuplicateDismissed, models.DuplicateConfirmed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be pending, dismissed or confirmed"})
+		return
+	}
+
+	duplicates, total, err := database.GetDuplicateListings(c.Request.Context(), status, page.Limit, page.Offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load duplicate listings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"duplicates": duplicates,
+		"total":      total,
+		"limit":      page.Limit,
+		"offset":     page.Offset,
+	})
+}
+
+// ReviewDuplicateListing records an admin's decision on a flagged listing: "dismissed" when
+// it isn't a duplicate (the pair isn't flagged again) or "confirmed", which archives the
+// newer listing. Decisions are recorded in the admin audit log.
+func ReviewDuplicateListing(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		Decision string `json:"decision" binding:"required,oneof=dismissed confirmed"`
+		Note     string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	id := sanitizedIDParam(c)
+	note := utils.SanitizeInput(request.Note, utils.DefaultTextOptions)
+	duplicate, err := database.ReviewDuplicateListing(c.Request.Context(), id, request.Decision, note, &models.AuditEntry{
+		ActorID:    &user.ID,
+		ActorEmail: user.Email,
+		Action:     models.AuditDuplicateReviewed,
+		Detail:     fmt.Sprintf("%s duplicate listing %s: %s", request.Decision, id, note),
+		IPAddress:  c.ClientIP(),
+	})
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Duplicate listing not found"})
+		return
+	} else if errors.Is(err, database.ErrAlreadyReviewed) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review duplicate listing"})
+		return
+	}
+
+	c.JSON(http.StatusOK, duplicate)
+}
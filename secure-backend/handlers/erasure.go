@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/services"
+	"secure-backend/tokens"
+	"secure-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestErasure starts the erasure of the buyer's account and personal data and emails a
+// link to confirm it. Nothing is erased until the request is confirmed and its grace period
+// has passed.
+func RequestErasure(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	request, err := services.RequestErasure(c.Request.Context(), user)
+	switch {
+	case errors.Is(err, services.ErrErasureNotAllowed):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, database.ErrErasurePending):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrErasureUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("Failed to request erasure for user %s: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request erasure"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, request)
+}
+
+// GetErasureRequest returns the user's most recent erasure request
+func GetErasureRequest(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	request, err := database.GetLatestErasureRequest(c.Request.Context(), user.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No erasure request"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load erasure request"})
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+// CancelErasureRequest cancels the user's erasure request while it awaits confirmation or
+// its grace period
+func CancelErasureRequest(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	request, err := services.CancelErasure(c.Request.Context(), user.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No erasure request to cancel"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel erasure request"})
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+// ConfirmErasureRequest confirms an erasure request with the token from the emailed link and
+// schedules the erasure. The link is the authorization, so users don't need to be logged in.
+func ConfirmErasureRequest(c *gin.Context) {
+	var body struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	request, err := services.ConfirmErasure(c.Request.Context(), body.Token)
+	switch {
+	case errors.Is(err, tokens.ErrInvalidToken), errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Confirmation link is invalid or expired"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm erasure request"})
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+// GetErasureRequests lists erasure requests with a status (?status=, default scheduled) for
+// admins, so support can see which accounts are about to be erased
+func GetErasureRequests(c *gin.Context) {
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := c.DefaultQuery("status", models.ErasureScheduled)
+	switch status {
+	case models.ErasureAwaitingConfirmation, models.ErasureScheduled, models.ErasureCompleted,
+		models.ErasureCancelled, models.ErasureExpired:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "status must be awaiting_confirmation, scheduled, completed, cancelled or expired",
+		})
+		return
+	}
+
+	requests, total, err := database.GetErasureRequests(c.Request.Context(), status, page.Limit, page.Offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load erasure requests"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"requests": requests,
+		"total":    total,
+		"limit":    page.Limit,
+		"offset":   page.Offset,
+	})
+}
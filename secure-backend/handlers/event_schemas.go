@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http"
+	"secure-backend/events"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetEventSchemas lists the published versions of the payloads the API sends to other
+// systems, with the path of each one's JSON Schema
+func GetEventSchemas(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"contracts": events.Contracts()})
+}
+
+// GetEventSchema returns the JSON Schema of a version of an event payload. Published
+// versions never change, so the schema can be cached.
+func GetEventSchema(c *gin.Context) {
+	schema, ok := events.Schema(c.Param("event"), c.Param("version"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event schema not found"})
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Data(http.StatusOK, "application/schema+json", schema)
+}
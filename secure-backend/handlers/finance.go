@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetFinancialSummary returns the gross sales, refunds, provider fees and net of a month
+// (:period as "2026-03") for admins. Closed months report the figures they were closed with.
+func GetFinancialSummary(c *gin.Context) {
+	period, err := models.ParsePeriod(c.Param("period"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period must be a month such as 2026-03"})
+		return
+	}
+
+	summary, err := database.GetFinancialSummary(c.Request.Context(), period)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load financial summary"})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// CloseFinancialPeriod closes a month that has ended, freezing its summary. Afterwards only
+// admins can change the status or refunds of orders placed in it. Recorded in the admin audit log.
+func CloseFinancialPeriod(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	period, err := models.ParsePeriod(c.Param("period"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period must be a month such as 2026-03"})
+		return
+	}
+	if period.AddDate(0, 1, 0).After(clk.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only months that have ended can be closed"})
+		return
+	}
+
+	summary, err := database.CloseFinancialPeriod(c.Request.Context(), period, &models.AuditEntry{
+		ActorID:    &user.ID,
+		ActorEmail: user.Email,
+		Action:     models.AuditPeriodClosed,
+		Detail:     fmt.Sprintf("closed accounting period %s", c.Param("period")),
+		IPAddress:  c.ClientIP(),
+	})
+	switch {
+	case errors.Is(err, database.ErrPeriodAlreadyClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to close period"})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// GetTaxReport returns the tax collected per jurisdiction and rate over ?from=&to= (default the
+// last 30 days) for filing returns, optionally for one seller (?seller_id=). ?format=csv
+// downloads it as a CSV file instead of JSON. Only admins can view reports.
+func GetTaxReport(c *gin.Context) {
+	from, to, err := parseDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or csv"})
+		return
+	}
+	sellerID := c.Query("seller_id")
+
+	rows, err := database.GetTaxReport(c.Request.Context(), from, to, sellerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load tax report"})
+		return
+	}
+
+	if format == "csv" {
+		var buf bytes.Buffer
+		out := csv.NewWriter(&buf)
+		out.Write([]string{
+			"jurisdiction", "rate", "order_count", "gross_sales", "taxable_sales", "tax_collected", "tax_refunded", "net_tax",
+		})
+		for _, row := range rows {
+			out.Write([]string{
+				row.Jurisdiction, strconv.FormatFloat(row.Rate, 'f', 4, 64), strconv.Itoa(row.OrderCount),
+				row.GrossSales.String(), row.TaxableSales.String(), row.TaxCollected.String(), row.TaxRefunded.String(), row.NetTax.String(),
+			})
+		}
+		out.Flush()
+
+		// The range is half-open; the file is named after the last day it covers
+		name := fmt.Sprintf("tax-report-%s-%s.csv", from.Format("2006-01-02"), to.AddDate(0, 0, -1).Format("2006-01-02"))
+		c.Header("Content-Disposition", `attachment; filename="`+name+`"`)
+		c.Header("Cache-Control", "private, no-store")
+		c.Data(http.StatusOK, "text/csv", buf.Bytes())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":          from,
+		"to":            to,
+		"seller_id":     sellerID,
+		"jurisdictions": rows,
+	})
+}
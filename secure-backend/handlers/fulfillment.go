@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/fulfillment"
+	"secure-backend/invoices"
+	"secure-backend/models"
+	"secure-backend/utils"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxFulfillmentBatch caps the orders of one pick list or set of packing slips
+const maxFulfillmentBatch = 100
+
+// fulfillmentBatch is the orders a pick list or set of packing slips covers
+type fulfillmentBatch struct {
+	name        string // used in file names
+	description string
+	orderIDs    []string
+	from, to    time.Time
+}
+
+// parseFulfillmentBatch reads ?order_ids= (comma-separated) or, without it, ?date=
+// (YYYY-MM-DD, UTC, default today) for the orders placed that day
+func parseFulfillmentBatch(c *gin.Context) (*fulfillmentBatch, error) {
+	if value := c.Query("order_ids"); value != "" {
+		var orderIDs []string
+		for _, id := range strings.Split(value, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				orderIDs = append(orderIDs, id)
+			}
+		}
+		if len(orderIDs) == 0 {
+			return nil, errors.New("order_ids must list at least one order ID")
+		}
+		if len(orderIDs) > maxFulfillmentBatch {
+			return nil, fmt.Errorf("order_ids must list at most %d orders", maxFulfillmentBatch)
+		}
+		description := "Order " + fulfillment.OrderReference(orderIDs[0])
+		if len(orderIDs) > 1 {
+			description = fmt.Sprintf("Batch of %d orders", len(orderIDs))
+		}
+		return &fulfillmentBatch{name: "batch", description: description, orderIDs: orderIDs}, nil
+	}
+
+	day := clk.Now().UTC().Truncate(24 * time.Hour)
+	if value := c.Query("date"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return nil, errors.New("date must be a date in YYYY-MM-DD format")
+		}
+		day = parsed
+	}
+	date := day.Format("2006-01-02")
+	return &fulfillmentBatch{name: date, description: "Orders placed " + date, from: day, to: day.AddDate(0, 0, 1)}, nil
+}
+
+// loadFulfillmentItems reads the seller's items still to be shipped in a batch
+func loadFulfillmentItems(ctx context.Context, sellerID string, batch *fulfillmentBatch) ([]models.FulfillmentItem, error) {
+	if len(batch.orderIDs) > 0 {
+		return database.GetFulfillmentItemsOfOrders(ctx, sellerID, batch.orderIDs)
+	}
+	return database.GetFulfillmentItemsPlacedBetween(ctx, sellerID, batch.from, batch.to)
+}
+
+// fulfillmentDocument checks the seller role and ?format= and loads the batch's items,
+// writing an error response and returning false if any of that fails
+func fulfillmentDocument(c *gin.Context) (items []models.FulfillmentItem, batch *fulfillmentBatch, format string, ok bool) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return nil, nil, "", false
+	}
+
+	format = strings.ToLower(c.DefaultQuery("format", fulfillment.FormatPDF))
+	if format != fulfillment.FormatPDF && format != fulfillment.FormatHTML {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fulfillment.ErrUnknownFormat.Error()})
+		return nil, nil, "", false
+	}
+
+	batch, err = parseFulfillmentBatch(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, nil, "", false
+	}
+
+	items, err = loadFulfillmentItems(c.Request.Context(), user.ID, batch)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load order items"})
+		return nil, nil, "", false
+	}
+	return items, batch, format, true
+}
+
+// writeFulfillmentDocument sends a rendered document: PDFs as a download, HTML for printing
+// from the browser
+func writeFulfillmentDocument(c *gin.Context, document *bytes.Buffer, format, filename string) {
+	c.Header("Cache-Control", "private, no-store")
+	if format == fulfillment.FormatHTML {
+		// The document carries its own print stylesheet and nothing else
+		c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+		c.Data(http.StatusOK, "text/html; charset=utf-8", document.Bytes())
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`.pdf"`)
+	c.Data(http.StatusOK, "application/pdf", document.Bytes())
+}
+
+// GetPickList returns what to take off the shelves for a batch of the seller's orders, one
+// line per product in shelf order. Only items not yet shipped in paid orders are listed.
+// ?date= or ?order_ids= choose the batch and ?format= pdf (default) or html.
+func GetPickList(c *gin.Context) {
+	items, batch, format, ok := fulfillmentDocument(c)
+	if !ok {
+		return
+	}
+
+	var document bytes.Buffer
+	err := fulfillment.RenderPickList(&document, fulfillment.PickList{
+		Issuer:      invoices.IssuerFromEnv().Name,
+		Batch:       batch.description,
+		GeneratedAt: clk.Now(),
+		Lines:       models.NewPickList(items),
+	}, format)
+	if err != nil {
+		log.Printf("Failed to render pick list: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render pick list"})
+		return
+	}
+	writeFulfillmentDocument(c, &document, format, "pick-list-"+batch.name)
+}
+
+// GetPackingSlips returns a packing slip per order of a batch, listing the seller's items
+// not yet shipped. Batch and format are chosen as for GetPickList.
+func GetPackingSlips(c *gin.Context) {
+	items, batch, format, ok := fulfillmentDocument(c)
+	if !ok {
+		return
+	}
+
+	var document bytes.Buffer
+	err := fulfillment.RenderPackingSlips(&document, fulfillment.PackingSlips{
+		Issuer: invoices.IssuerFromEnv().Name,
+		Slips:  models.NewPackingSlips(items),
+	}, format)
+	if err != nil {
+		log.Printf("Failed to render packing slips: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render packing slips"})
+		return
+	}
+	writeFulfillmentDocument(c, &document, format, "packing-slips-"+batch.name)
+}
@@ -0,0 +1,323 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/middleware"
+	"secure-backend/models"
+	"secure-backend/services"
+	"secure-backend/tokens"
+	"secure-backend/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GuestCartTTL is how long an anonymous cart (and its cart token) lives
+const GuestCartTTL = 30 * 24 * time.Hour
+
+// guestCartID returns the anonymous cart ID resolved from the request's cart token, if any
+func guestCartID(c *gin.Context) (string, bool) {
+	id := c.GetString(middleware.GuestCartKey)
+	return id, id != ""
+}
+
+// currentGuestCart returns the unexpired anonymous cart named by the request's cart token,
+// or sql.ErrNoRows if there is no token or the cart was merged, purged or never existed
+func currentGuestCart(c *gin.Context) (*models.CartSession, error) {
+	id, ok := guestCartID(c)
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return database.GetCartSession(c.Request.Context(), id, clk.Now())
+}
+
+// GetGuestCart returns the items of the caller's anonymous cart with product details and
+// availability. Callers without a cart token get an empty cart.
+func GetGuestCart(c *gin.Context) {
+	items := []models.GuestCartItemWithProduct{}
+
+	cart, err := currentGuestCart(c)
+	if err == sql.ErrNoRows {
+		if _, ok := guestCartID(c); ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Cart not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"items": items, "count": 0})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve cart"})
+		return
+	}
+
+	found, err := database.GetGuestCartItems(c.Request.Context(), cart.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve cart"})
+		return
+	}
+	items = append(items, found...)
+	for i := range items {
+		items[i].CheckAvailability()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":      items,
+		"count":      len(items),
+		"expires_at": cart.ExpiresAt,
+	})
+}
+
+// AddToGuestCart adds a product to the caller's anonymous cart. Without a (live) cart token
+// a new cart is started and its token is returned in X-Cart-Token and as cart_token; the
+// client sends it on later guest cart requests and to POST /api/cart/merge after login.
+func AddToGuestCart(c *gin.Context) {
+	var request struct {
+		ProductID string `json:"product_id" binding:"required"`
+		Quantity  int    `json:"quantity" binding:"required,min=1"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	request.ProductID = utils.SanitizeInput(request.ProductID, utils.SanitizationOptions{
+		TrimWhitespace: true,
+		EscapeHTML:     true,
+		RemoveNewlines: true,
+		MaxLength:      100,
+	})
+
+	if request.Quantity < 1 || request.Quantity > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity must be between 1 and 100"})
+		return
+	}
+
+	product, ok := checkProductAvailable(c, request.ProductID, request.Quantity)
+	if !ok {
+		return
+	}
+
+	cart, err := currentGuestCart(c)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Failed to open guest cart: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add to cart"})
+		return
+	}
+
+	// Purchase limits apply to the quantity the cart ends up with
+	inCart := 0
+	if cart != nil {
+		inCart, err = database.GetGuestCartItemQuantity(c.Request.Context(), cart.ID, request.ProductID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add to cart"})
+			return
+		}
+	}
+	if !checkOrderQuantity(c, product, inCart+request.Quantity) {
+		return
+	}
+	if !checkGuestCartLimits(c, cart, "", product.ID, inCart+request.Quantity, request.Quantity) {
+		return
+	}
+
+	var cartToken string
+	if cart == nil {
+		cart, cartToken, err = startGuestCart(c.Request.Context())
+		if err != nil {
+			log.Printf("Failed to open guest cart: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add to cart"})
+			return
+		}
+	}
+
+	item, err := database.AddToGuestCart(c.Request.Context(), cart.ID, request.ProductID, request.Quantity)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add to cart"})
+		return
+	}
+
+	response := gin.H{"item": item}
+	if cartToken != "" {
+		c.Header(middleware.CartTokenHeader, cartToken)
+		response["cart_token"] = cartToken
+		response["expires_at"] = cart.ExpiresAt
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// checkGuestCartLimits is checkCartLimits for an anonymous cart, which is nil before the
+// first item is added
+func checkGuestCartLimits(c *gin.Context, cart *models.CartSession, exceptItemID, productID string, itemQuantity, addedUnits int) bool {
+	units := 0
+	if cart != nil {
+		var err error
+		units, err = database.GetGuestCartUnits(c.Request.Context(), cart.ID, exceptItemID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify cart size"})
+			return false
+		}
+	}
+
+	var ruleErr *models.OrderRuleError
+	if err := models.CheckCartLimits(productID, itemQuantity, units+addedUnits, services.CartMaxUnits()); errors.As(err, &ruleErr) {
+		c.JSON(http.StatusBadRequest, ruleErr)
+		return false
+	}
+	return true
+}
+
+// startGuestCart creates an anonymous cart and signs the token that identifies it
+func startGuestCart(ctx context.Context) (*models.CartSession, string, error) {
+	cart, err := database.CreateCartSession(ctx, clk.Now().Add(GuestCartTTL))
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, err := tokens.Sign(tokens.PurposeGuestCart, cart.ID, cart.ExpiresAt)
+	if err != nil {
+		return nil, "", err
+	}
+	return cart, token, nil
+}
+
+// UpdateGuestCartItem sets the quantity of an item in the caller's anonymous cart
+func UpdateGuestCartItem(c *gin.Context) {
+	cart, ok := requireGuestCart(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Quantity int `json:"quantity" binding:"required,min=0"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if request.Quantity < 0 || request.Quantity > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity must be between 0 and 100"})
+		return
+	}
+
+	cartItemID := sanitizedIDParam(c)
+	if request.Quantity > 0 {
+		product, err := database.GetGuestCartItemProduct(c.Request.Context(), cartItemID, cart.ID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Cart item not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update cart item"})
+			return
+		}
+		if !checkOrderQuantity(c, product, request.Quantity) {
+			return
+		}
+		if !checkGuestCartLimits(c, cart, cartItemID, product.ID, request.Quantity, request.Quantity) {
+			return
+		}
+	}
+
+	err := database.UpdateGuestCartItemQuantity(c.Request.Context(), cartItemID, cart.ID, request.Quantity)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cart item not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update cart item"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Cart item updated successfully"})
+}
+
+// RemoveGuestCartItem removes an item from the caller's anonymous cart
+func RemoveGuestCartItem(c *gin.Context) {
+	cart, ok := requireGuestCart(c)
+	if !ok {
+		return
+	}
+
+	err := database.RemoveFromGuestCart(c.Request.Context(), sanitizedIDParam(c), cart.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cart item not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove cart item"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Cart item removed successfully"})
+}
+
+// requireGuestCart loads the caller's anonymous cart, responding with an error and
+// returning false if the request has no cart token or the cart no longer exists
+func requireGuestCart(c *gin.Context) (*models.CartSession, bool) {
+	if _, ok := guestCartID(c); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": middleware.CartTokenHeader + " header is required"})
+		return nil, false
+	}
+
+	cart, err := currentGuestCart(c)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cart not found"})
+		return nil, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve cart"})
+		return nil, false
+	}
+	return cart, true
+}
+
+// MergeGuestCart moves the items of the anonymous cart named by X-Cart-Token into the
+// authenticated user's cart (called after login) and deletes the anonymous cart, so the
+// token stops working. Quantities of products already in the cart are added together;
+// products that were unpublished in the meantime are dropped. A merge that would take the
+// cart past its size limit is refused with the limit, leaving both carts as they were.
+func MergeGuestCart(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	cartID, ok := guestCartID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": middleware.CartTokenHeader + " header is required"})
+		return
+	}
+
+	merged, err := database.MergeGuestCart(c.Request.Context(), cartID, user.ID, services.CartMaxUnits(), clk.Now())
+	var ruleErr *models.OrderRuleError
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cart not found"})
+		return
+	} else if errors.As(err, &ruleErr) {
+		c.JSON(http.StatusBadRequest, ruleErr)
+		return
+	} else if err != nil {
+		log.Printf("Failed to merge guest cart %s: %v", cartID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge cart"})
+		return
+	}
+
+	for _, item := range merged {
+		recordCartEvent(c, user.ID, cartActionMerge, item.ProductID, item.Quantity)
+	}
+
+	version, err := database.GetCartVersion(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve cart"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"merged":  len(merged),
+		"version": version,
+	})
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/importer"
+	"secure-backend/models"
+	"secure-backend/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxImportFileSize caps the size of a product file uploaded for an import preview
+const MaxImportFileSize = 10 << 20
+
+// MaxImportBodySize leaves room for the multipart framing and mapping around the file
+const MaxImportBodySize = MaxImportFileSize + 64<<10
+
+const (
+	// defaultPreviewRows is how many rows an import preview parses when ?rows= is omitted
+	defaultPreviewRows = 10
+	// maxPreviewRows caps ?rows= of an import preview
+	maxPreviewRows = 50
+)
+
+// importTemplateRequest is the body of the import template create and update routes
+type importTemplateRequest struct {
+	Name    string               `json:"name" binding:"required"`
+	Mapping models.ImportMapping `json:"mapping"`
+}
+
+// bindImportTemplate reads and validates an import template request, responding with the
+// problem and returning false if it is invalid
+func bindImportTemplate(c *gin.Context, template *models.ImportTemplate) bool {
+	var request importTemplateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	template.Name = utils.SanitizeInput(request.Name, utils.SanitizationOptions{
+		TrimWhitespace: true,
+		EscapeHTML:     true,
+		RemoveNewlines: true,
+		MaxLength:      100,
+	})
+	if template.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Template name is required"})
+		return false
+	}
+
+	if err := importer.Validate(&request.Mapping); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	template.Mapping = request.Mapping
+	return true
+}
+
+// GetImportTemplates lists the seller's saved import column mappings
+func GetImportTemplates(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	templates, err := database.GetImportTemplates(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load import templates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"templates": templates})
+}
+
+// CreateImportTemplate saves a column mapping for reuse across imports, e.g. mapping the
+// "Titre" and "Prix" columns of a French shop export with comma decimals
+func CreateImportTemplate(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	template := &models.ImportTemplate{SellerID: user.ID}
+	if !bindImportTemplate(c, template) {
+		return
+	}
+
+	err = database.CreateImportTemplate(c.Request.Context(), template)
+	if errors.Is(err, database.ErrTemplateNameTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save import template"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+// UpdateImportTemplate renames one of the seller's import templates and replaces its mapping
+func UpdateImportTemplate(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	template := &models.ImportTemplate{ID: sanitizedIDParam(c), SellerID: user.ID}
+	if !bindImportTemplate(c, template) {
+		return
+	}
+
+	err = database.UpdateImportTemplate(c.Request.Context(), template)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import template not found"})
+		return
+	} else if errors.Is(err, database.ErrTemplateNameTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update import template"})
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// DeleteImportTemplate deletes one of the seller's import templates
+func DeleteImportTemplate(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = database.DeleteImportTemplate(c.Request.Context(), sanitizedIDParam(c), user.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import template not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete import template"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Import template deleted successfully"})
+}
+
+// PreviewImport parses the first rows of an uploaded product file (multipart "file", CSV)
+// without importing anything, so sellers can check a mapping first. The mapping is a
+// saved template ("template_id") or given inline as JSON ("mapping"). ?rows= sets how
+// many rows are parsed (default 10, at most 50); each row lists its problems.
+func PreviewImport(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	rows := defaultPreviewRows
+	if rowsParam := c.Query("rows"); rowsParam != "" {
+		rows, err = strconv.Atoi(rowsParam)
+		if err != nil || rows < 1 || rows > maxPreviewRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "rows must be between 1 and 50"})
+			return
+		}
+	}
+
+	var mapping models.ImportMapping
+	if templateID := c.PostForm("template_id"); templateID != "" {
+		template, err := database.GetImportTemplate(c.Request.Context(), templateID, user.ID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Import template not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load import template"})
+			return
+		}
+		mapping = template.Mapping
+	} else if err := json.Unmarshal([]byte(c.PostForm("mapping")), &mapping); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A template_id or a JSON mapping is required"})
+		return
+	}
+	if err := importer.Validate(&mapping); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File must be at most 10 MB"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Multipart field \"file\" is required"})
+		return
+	}
+	if header.Size > MaxImportFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File must be at most 10 MB"})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	preview, err := importer.ParsePreview(file, &mapping, rows)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/importer"
+	"secure-backend/models"
+	"secure-backend/utils"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxInventoryRows caps the rows of an inventory file
+const maxInventoryRows = 50000
+
+// ImportInventory sets the stock and price of the seller's products from an inventory file
+// (multipart "file", CSV with an id or slug column and stock and/or price columns, at most
+// 10 MB and 50,000 rows). It is the fast path for frequent syncs with a warehouse or
+// point-of-sale system: nothing but stock and price changes, applied in one transaction.
+// Each row is reported with its outcome and the old and new values; ?dry_run=true reports
+// the changes without making them.
+func ImportInventory(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	dryRun := false
+	if raw := c.Query("dry_run"); raw != "" {
+		if dryRun, err = strconv.ParseBool(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "dry_run must be true or false"})
+			return
+		}
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File must be at most 10 MB"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Multipart field \"file\" is required"})
+		return
+	}
+	if header.Size > MaxImportFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File must be at most 10 MB"})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	updates, invalid, err := importer.ParseInventory(file, maxInventoryRows)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	results, err := database.ApplyInventoryUpdates(c.Request.Context(), user.ID, updates, dryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import inventory"})
+		return
+	}
+	results = append(results, invalid...)
+	sort.Slice(results, func(i, j int) bool { return results[i].Line < results[j].Line })
+
+	summary := map[string]int{
+		models.InventoryUpdated: 0, models.InventoryUnchanged: 0, models.InventoryNotFound: 0,
+		models.InventoryDuplicate: 0, models.InventoryInvalid: 0,
+	}
+	for _, result := range results {
+		summary[result.Outcome]++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"dry_run": dryRun,
+		"summary": summary,
+		"results": results,
+	})
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"log"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/invoices"
+	"secure-backend/payments"
+	"secure-backend/services"
+	"secure-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetOrderInvoice renders the PDF invoice of a paid order for its buyer or an admin.
+// The invoice number is assigned the first time the invoice is requested.
+func GetOrderInvoice(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, err := database.GetOrderByID(c.Request.Context(), c.Param("id"))
+	if err == sql.ErrNoRows || (err == nil && order.UserID != user.ID && user.Role != "admin") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order"})
+		return
+	}
+
+	switch order.Status {
+	case services.OrderStatusPaid, services.OrderStatusShipped, services.OrderStatusDelivered, services.OrderStatusRefunded:
+	default:
+		c.JSON(http.StatusConflict, gin.H{"error": "Invoices are only available for paid orders"})
+		return
+	}
+
+	invoice, err := database.GetOrCreateInvoice(c.Request.Context(), order.ID, invoices.TaxRate())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue invoice"})
+		return
+	}
+
+	lines, err := database.GetInvoiceLines(c.Request.Context(), order.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load order items"})
+		return
+	}
+
+	buyerEmail := ""
+	if buyer, err := database.GetUserByID(c.Request.Context(), order.UserID); err == nil {
+		buyerEmail = buyer.Email
+	}
+
+	var pdf bytes.Buffer
+	err = invoices.Render(&pdf, invoices.Document{
+		Invoice:    invoice,
+		Order:      order,
+		BuyerEmail: buyerEmail,
+		Lines:      lines,
+		Issuer:     invoices.IssuerFromEnv(),
+		Currency:   payments.Currency(),
+	})
+	if err != nil {
+		log.Printf("Failed to render invoice %s: %v", invoice.Reference(), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render invoice"})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+invoice.Reference()+`.pdf"`)
+	c.Header("Cache-Control", "private, no-store")
+	c.Data(http.StatusOK, "application/pdf", pdf.Bytes())
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"secure-backend/database"
+	"secure-backend/jobs"
+	"secure-backend/models"
+	"secure-backend/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateExport queues an export job and returns it immediately; clients poll
+// GET /api/jobs/:id and are notified with a download link once it completes.
+//   - orders_export: CSV of the buyer's orders, the seller's order items, or all orders for admins
+//   - warehouse_dump: zipped JSON Lines dump of the core tables (admins only)
+//   - gdpr_bundle: zipped JSON copy of everything stored about the user
+func CreateExport(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		Type string `json:"type" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var params interface{}
+	switch strings.ToLower(strings.TrimSpace(request.Type)) {
+	case jobs.TypeOrdersExport:
+		request.Type = jobs.TypeOrdersExport
+		scope := database.OrderExportScopeBuyer
+		switch user.Role {
+		case "admin":
+			scope = database.OrderExportScopeAll
+		case "seller":
+			scope = database.OrderExportScopeSeller
+		}
+		params = jobs.OrdersExportParams{Scope: scope}
+	case jobs.TypeWarehouseDump:
+		request.Type = jobs.TypeWarehouseDump
+		if user.Role != "admin" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can export the data warehouse"})
+			return
+		}
+		params = struct{}{}
+	case jobs.TypeGDPRBundle:
+		request.Type = jobs.TypeGDPRBundle
+		params = struct{}{}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid export type. Must be orders_export, warehouse_dump or gdpr_bundle"})
+		return
+	}
+
+	job, err := jobs.Enqueue(c.Request.Context(), user.ID, request.Type, params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start export"})
+		return
+	}
+
+	c.Header("Location", "/api/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, gin.H{"message": "Export started", "job": job})
+}
+
+// canViewJob reports whether the user may see a job: its owner or an admin
+func canViewJob(user *models.AuthUser, job *models.Job) bool {
+	return job.UserID == user.ID || user.Role == "admin"
+}
+
+// ListJobs returns a page of the user's jobs, newest first, optionally filtered by
+// ?status= and ?type=. Admins can list every user's jobs with ?all=true or ?user_id=.
+func ListJobs(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter := database.JobFilter{
+		UserID: user.ID,
+		Status: strings.ToLower(strings.TrimSpace(c.Query("status"))),
+		Type:   strings.ToLower(strings.TrimSpace(c.Query("type"))),
+	}
+	switch filter.Status {
+	case "", jobs.StatusQueued, jobs.StatusRunning, jobs.StatusCompleted, jobs.StatusFailed, jobs.StatusCancelled:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status filter"})
+		return
+	}
+
+	if user.Role == "admin" {
+		if c.Query("all") == "true" {
+			filter.UserID = ""
+		} else if userID := c.Query("user_id"); userID != "" {
+			filter.UserID = userID
+		}
+	}
+
+	list, total, err := database.GetJobs(c.Request.Context(), filter, page.Limit, page.Offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load jobs"})
+		return
+	}
+
+	for i := range list {
+		list[i].DownloadURL = jobs.DownloadURL(&list[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"jobs":   list,
+		"total":  total,
+		"limit":  page.Limit,
+		"offset": page.Offset,
+	})
+}
+
+// loadVisibleJob loads the job named in the URL if the user may see it, writing an error response otherwise
+func loadVisibleJob(c *gin.Context) (*models.Job, bool) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	job, err := database.GetJobByID(c.Request.Context(), c.Param("id"))
+	if err == sql.ErrNoRows || (err == nil && !canViewJob(user, job)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return nil, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch job"})
+		return nil, false
+	}
+
+	return job, true
+}
+
+// GetJob returns the status and progress of a job, with a signed download link once it completed
+func GetJob(c *gin.Context) {
+	job, ok := loadVisibleJob(c)
+	if !ok {
+		return
+	}
+
+	job.DownloadURL = jobs.DownloadURL(job)
+	c.JSON(http.StatusOK, job)
+}
+
+// CancelJob cancels a queued or running job
+func CancelJob(c *gin.Context) {
+	job, ok := loadVisibleJob(c)
+	if !ok {
+		return
+	}
+
+	job, err := jobs.Cancel(c.Request.Context(), job.ID)
+	if errors.Is(err, jobs.ErrNotCancellable) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel job"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Job cancelled", "job": job})
+}
+
+// RetryJob queues a failed or cancelled job again
+func RetryJob(c *gin.Context) {
+	job, ok := loadVisibleJob(c)
+	if !ok {
+		return
+	}
+
+	job, err := jobs.Retry(c.Request.Context(), job.ID)
+	if errors.Is(err, jobs.ErrNotRetryable) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry job"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Job queued", "job": job})
+}
+
+// DownloadJobResult serves the result file of a completed job. It is authorized by the
+// link token instead of a session, so links in notifications work directly.
+func DownloadJobResult(c *gin.Context) {
+	jobID := c.Param("id")
+	if err := jobs.VerifyDownload(jobID, c.Query("token"), clk.Now()); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := database.GetJobByID(c.Request.Context(), jobID)
+	if err == sql.ErrNoRows || (err == nil && job.ResultPath == "") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Download not available"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch job"})
+		return
+	}
+
+	if _, err := os.Stat(job.ResultPath); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Download not available"})
+		return
+	}
+
+	name := strings.TrimPrefix(filepath.Base(job.ResultPath), job.ID+"-")
+	c.Header("Content-Type", jobs.ResultContentType(job.Type))
+	c.Header("Cache-Control", "no-store")
+	c.FileAttachment(job.ResultPath, name)
+}
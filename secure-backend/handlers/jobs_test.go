@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"secure-backend/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanViewJob(t *testing.T) {
+	job := &models.Job{ID: "job-1", UserID: "buyer-1"}
+
+	assert.True(t, canViewJob(&models.AuthUser{ID: "buyer-1", Role: "buyer"}, job), "owner")
+	assert.True(t, canViewJob(&models.AuthUser{ID: "admin-1", Role: "admin"}, job), "admin")
+	assert.False(t, canViewJob(&models.AuthUser{ID: "buyer-2", Role: "buyer"}, job), "another buyer")
+	assert.False(t, canViewJob(&models.AuthUser{ID: "seller-1", Role: "seller"}, job), "a seller")
+}
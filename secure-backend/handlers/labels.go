@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http"
+	"secure-backend/locale"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestLanguage negotiates the language of display labels from Accept-Language and
+// announces it in Content-Language
+func requestLanguage(c *gin.Context) string {
+	lang := locale.Negotiate(c.GetHeader("Accept-Language"))
+	c.Header("Content-Language", lang)
+	c.Header("Vary", "Accept-Language")
+	return lang
+}
+
+// GetLabels returns the display labels of every status in the language negotiated from
+// Accept-Language, so clients don't keep their own copies
+func GetLabels(c *gin.Context) {
+	lang := requestLanguage(c)
+	c.JSON(http.StatusOK, gin.H{
+		"language": lang,
+		"labels":   locale.Labels(lang),
+	})
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/services"
+	"secure-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// messageOptions sanitizes the text of buyer and seller messages
+var messageOptions = utils.SanitizationOptions{
+	TrimWhitespace: true,
+	EscapeHTML:     true,
+	MaxLength:      2000,
+	PreserveSpaces: true,
+}
+
+// sanitizeMessageBody reads the text of a message, answering 400 and returning false when
+// nothing is left of it
+func sanitizeMessageBody(c *gin.Context, raw string) (string, bool) {
+	body := utils.SanitizeInput(raw, messageOptions)
+	if body == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Message body is required"})
+		return "", false
+	}
+	return body, true
+}
+
+// SendMessage sends a buyer's message to the seller of a product (product_id) or of their
+// order (order_id, with product_id to pick the seller when the order has several). The
+// message joins the buyer's thread with that seller about the product or order, starting it
+// if needed. Links, email addresses, phone numbers and profanity are masked before the
+// message is stored, and the seller is notified. A seller on vacation auto-replies to the
+// message starting a thread.
+func SendMessage(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		ProductID string `json:"product_id"`
+		OrderID   string `json:"order_id"`
+		Body      string `json:"body" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.ProductID == "" && request.OrderID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "product_id or order_id is required"})
+		return
+	}
+	body, ok := sanitizeMessageBody(c, request.Body)
+	if !ok {
+		return
+	}
+
+	thread, message, err := services.SendBuyerMessage(c.Request.Context(), user.ID, database.MessageSubject{
+		ProductID: request.ProductID,
+		OrderID:   request.OrderID,
+	}, body)
+	switch {
+	case err == sql.ErrNoRows && request.OrderID != "":
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	case errors.Is(err, database.ErrMessageSellerAmbiguous):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send message"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"thread": thread, "message": message})
+}
+
+// ReplyToMessageThread adds the caller's message to a thread they take part in as buyer or
+// seller. The message is screened like the first one and the other participant is notified.
+func ReplyToMessageThread(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		Body string `json:"body" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	body, ok := sanitizeMessageBody(c, request.Body)
+	if !ok {
+		return
+	}
+
+	thread, message, err := services.ReplyToMessageThread(c.Request.Context(), sanitizedIDParam(c), user.ID, body)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message thread not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send message"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"thread": thread, "message": message})
+}
+
+// GetMessageThreads lists the threads the caller takes part in as buyer or seller, most
+// recently active first (paginated)
+func GetMessageThreads(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	threads, total, err := database.GetUserMessageThreads(c.Request.Context(), user.ID, page.Limit, page.Offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load message threads"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"threads": threads,
+		"total":   total,
+		"limit":   page.Limit,
+		"offset":  page.Offset,
+	})
+}
+
+// GetMessageThread returns a thread the caller takes part in with its messages, oldest first
+func GetMessageThread(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	thread, err := database.GetMessageThread(c.Request.Context(), sanitizedIDParam(c))
+	if err == sql.ErrNoRows || (err == nil && !thread.HasParticipant(user.ID)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message thread not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load message thread"})
+		return
+	}
+
+	respondMessageThread(c, thread)
+}
+
+// GetAdminMessageThreads lists message threads for admins resolving disputes, most recently
+// active first (?order_id=, ?user_id= for either participant, ?flagged=true for threads with
+// moderated messages; paginated)
+func GetAdminMessageThreads(c *gin.Context) {
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	threads, total, err := database.FindMessageThreads(c.Request.Context(), database.MessageThreadFilter{
+		OrderID:     c.Query("order_id"),
+		UserID:      c.Query("user_id"),
+		FlaggedOnly: c.Query("flagged") == "true",
+	}, page.Limit, page.Offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load message threads"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"threads": threads,
+		"total":   total,
+		"limit":   page.Limit,
+		"offset":  page.Offset,
+	})
+}
+
+// GetAdminMessageThread returns any thread with its messages (admins only)
+func GetAdminMessageThread(c *gin.Context) {
+	thread, err := database.GetMessageThread(c.Request.Context(), sanitizedIDParam(c))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message thread not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load message thread"})
+		return
+	}
+
+	respondMessageThread(c, thread)
+}
+
+// respondMessageThread writes a thread with its messages
+func respondMessageThread(c *gin.Context, thread *models.MessageThread) {
+	messages, err := database.GetThreadMessages(c.Request.Context(), thread.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load message thread"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"thread": thread, "messages": messages})
+}
@@ -1,22 +1,29 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"log"
 	"net/http"
 	"runtime"
+	"sync"
 	"time"
 
 	"secure-backend/database"
 	"secure-backend/metrics"
+	"secure-backend/middleware"
+	"secure-backend/outbound"
 
 	"github.com/gin-gonic/gin"
 )
 
 // HealthResponse represents the health check response
 type HealthResponse struct {
-	Status    string            `json:"status"`
-	Timestamp time.Time         `json:"timestamp"`
-	Services  map[string]string `json:"services"`
-	System    SystemInfo        `json:"system"`
+	Status    string             `json:"status"`
+	Timestamp time.Time          `json:"timestamp"`
+	Services  map[string]string  `json:"services"`
+	Pool      database.PoolStats `json:"database_pool"`
+	System    SystemInfo         `json:"system"`
 }
 
 // SystemInfo represents system-level metrics
@@ -41,6 +48,7 @@ func HealthCheck(c *gin.Context) {
 		Services: map[string]string{
 			"database": dbStatus,
 		},
+		Pool: database.GetPoolStats(),
 		System: SystemInfo{
 			NumGoroutine: runtime.NumGoroutine(),
 			NumCPU:       runtime.NumCPU(),
@@ -48,16 +56,143 @@ func HealthCheck(c *gin.Context) {
 		},
 	}
 
+	// A replica that is down doesn't fail anything (reads fall back to the primary) but is reported
+	if replica := database.GetReplicaStatus(); replica.Configured {
+		response.Services["database_replica"] = "down"
+		if replica.Up {
+			response.Services["database_replica"] = "up"
+		}
+	}
+
 	c.JSON(http.StatusOK, response)
 }
 
-// BasicMetrics returns basic application metrics
+// readinessTimeout bounds the dependency checks of a readiness probe, which run in parallel
+const readinessTimeout = 2 * time.Second
+
+// Dependency check statuses
+const (
+	checkUp      = "up"
+	checkDown    = "down"
+	checkSkipped = "skipped" // not configured, or depends on a check that failed
+)
+
+// errUnreachable is reported for a dependency that couldn't be reached; the cause is logged
+// rather than returned, as it may name hosts
+var errUnreachable = errors.New("unreachable")
+
+// DependencyCheck is the result of checking one dependency for readiness
+type DependencyCheck struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// ReadinessResponse represents the readiness probe response
+type ReadinessResponse struct {
+	Status    string                     `json:"status"` // ready or not_ready
+	Timestamp time.Time                  `json:"timestamp"`
+	Checks    map[string]DependencyCheck `json:"checks"`
+}
+
+// Liveness handles the /livez endpoint: the process is up and serving requests. It checks
+// no dependencies, so an outage of one doesn't get every instance restarted.
+func Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "timestamp": time.Now()})
+}
+
+// Readiness handles the /readyz endpoint: the database is reachable and migrated and the
+// signing keys of Supabase tokens are cached, so the instance can serve traffic. Any failed
+// check answers 503, which takes the instance out of the load balancer until it recovers.
+func Readiness(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	timed := func(check func() (string, error)) DependencyCheck {
+		start := time.Now()
+		status, err := check()
+		result := DependencyCheck{Status: status, LatencyMs: float64(time.Since(start).Microseconds()) / 1000}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		return result
+	}
+
+	var db, migrations, signingKeys DependencyCheck
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		db = timed(func() (string, error) {
+			if err := database.Ping(ctx); err != nil {
+				log.Printf("Readiness: database unreachable: %v", err)
+				return checkDown, errUnreachable
+			}
+			return checkUp, nil
+		})
+		migrations = timed(func() (string, error) {
+			if db.Status != checkUp {
+				return checkSkipped, nil
+			}
+			if err := database.CheckMigrations(ctx); err != nil {
+				log.Printf("Readiness: %v", err)
+				return checkDown, err
+			}
+			return checkUp, nil
+		})
+	}()
+	go func() {
+		defer wg.Done()
+		signingKeys = timed(func() (string, error) {
+			configured, err := middleware.SigningKeysReady(ctx)
+			if !configured {
+				return checkSkipped, nil
+			} else if err != nil {
+				log.Printf("Readiness: signing keys unavailable: %v", err)
+				return checkDown, errUnreachable
+			}
+			return checkUp, nil
+		})
+	}()
+	wg.Wait()
+
+	response := ReadinessResponse{
+		Status:    "ready",
+		Timestamp: time.Now(),
+		Checks:    map[string]DependencyCheck{"database": db, "migrations": migrations, "signing_keys": signingKeys},
+	}
+	status := http.StatusOK
+	for _, check := range response.Checks {
+		if check.Status == checkDown {
+			response.Status, status = "not_ready", http.StatusServiceUnavailable
+		}
+	}
+	c.JSON(status, response)
+}
+
+// BasicMetrics returns basic application metrics. The request totals are the sums of the
+// per-route counters.
 func BasicMetrics(c *gin.Context) {
-	currentMetrics := metrics.GetMetrics()
+	totals := metrics.RequestTotals()
 	c.JSON(http.StatusOK, gin.H{
-		"timestamp":      time.Now(),
-		"total_requests": currentMetrics["total_requests"],
-		"error_count":    currentMetrics["error_count"],
-		"goroutines":     runtime.NumGoroutine(),
+		"timestamp":        time.Now(),
+		"total_requests":   totals.Requests,
+		"error_count":      totals.Errors,
+		"goroutines":       runtime.NumGoroutine(),
+		"routes":           metrics.RouteMetrics(),      // requests, status classes and latency percentiles per route
+		"database":         database.GetQueryStats(),    // statement counts and durations
+		"database_pool":    database.GetPoolStats(),     // open, in-use and idle connections and waits for one
+		"database_replica": database.GetReplicaStatus(), // read replica health, lag and reads that fell back to the primary
+		"integrations":     outbound.Stats(),            // calls, failure rate and latency per outbound integration
 	})
 }
+
+// PrometheusMetrics returns the request metrics in the Prometheus text format, read from
+// the same counters as BasicMetrics
+func PrometheusMetrics(c *gin.Context) {
+	c.Header("Content-Type", metrics.PrometheusContentType)
+	c.Status(http.StatusOK)
+	if err := metrics.WritePrometheus(c.Writer); err != nil {
+		log.Printf("Failed to write Prometheus metrics: %v", err)
+	}
+}
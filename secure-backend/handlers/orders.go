@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/locale"
+	"secure-backend/models"
+	"secure-backend/payments"
+	"secure-backend/services"
+	"secure-backend/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetOrders returns the authenticated buyer's order history with items and product details
+func GetOrders(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	orders, total, err := database.GetOrdersByBuyer(c.Request.Context(), user.ID, page.Limit, page.Offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load orders"})
+		return
+	}
+
+	orderIDs := make([]string, 0, len(orders))
+	for _, order := range orders {
+		orderIDs = append(orderIDs, order.ID)
+	}
+
+	itemsByOrder, err := database.GetOrderItemsForOrders(c.Request.Context(), orderIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load order items"})
+		return
+	}
+
+	buyer := orderUser(user)
+	lang := requestLanguage(c)
+	details := make([]models.OrderWithDetails, 0, len(orders))
+	for _, order := range orders {
+		items := itemsByOrder[order.ID]
+		if items == nil {
+			items = []models.OrderItemWithProduct{}
+		}
+		detail := models.OrderWithDetails{Order: order, Items: items, User: buyer}
+		locale.LabelOrder(lang, &detail)
+		details = append(details, detail)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"orders": details,
+		"total":  total,
+		"limit":  page.Limit,
+		"offset": page.Offset,
+	})
+}
+
+// GetOrder returns a single order belonging to the authenticated buyer
+func GetOrder(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	orderID := c.Param("id")
+	if orderID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Order ID is required"})
+		return
+	}
+
+	order, err := database.GetOrderByBuyer(c.Request.Context(), orderID, user.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order"})
+		return
+	}
+
+	itemsByOrder, err := database.GetOrderItemsForOrders(c.Request.Context(), []string{order.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load order items"})
+		return
+	}
+
+	items := itemsByOrder[order.ID]
+	if items == nil {
+		items = []models.OrderItemWithProduct{}
+	}
+
+	details := models.OrderWithDetails{
+		Order: *order,
+		Items: items,
+		User:  orderUser(user),
+	}
+	locale.LabelOrder(requestLanguage(c), &details)
+	c.JSON(http.StatusOK, details)
+}
+
+// orderUser converts the authenticated user into the user details embedded in orders
+func orderUser(user *models.AuthUser) models.User {
+	return models.User{
+		ID:    user.ID,
+		Email: user.Email,
+		Role:  user.Role,
+	}
+}
+
+// GetOrderTimeline returns the status history of an order
+// Buyers can only view their own orders; admins can view any order
+func GetOrderTimeline(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	orderID := c.Param("id")
+	if orderID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Order ID is required"})
+		return
+	}
+
+	var order *models.Order
+	if utils.IsAdmin(c) {
+		order, err = database.GetOrderByID(c.Request.Context(), orderID)
+	} else {
+		order, err = database.GetOrderByBuyer(c.Request.Context(), orderID, user.ID)
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order"})
+		return
+	}
+
+	history, err := database.GetOrderStatusHistory(c.Request.Context(), order.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load order timeline"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"order_id":     order.ID,
+		"status":       order.Status,
+		"status_label": locale.Label(requestLanguage(c), locale.OrderStatus, order.Status),
+		"timeline":     history,
+	})
+}
+
+// UpdateOrderStatus moves an order to a new status following the order workflow
+// Only admins can change order status directly
+func UpdateOrderStatus(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	orderID := c.Param("id")
+	if orderID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Order ID is required"})
+		return
+	}
+
+	var request struct {
+		Status string `json:"status" binding:"required"`
+		Note   string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := strings.ToLower(strings.TrimSpace(request.Status))
+	if !services.IsValidOrderStatus(status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order status"})
+		return
+	}
+
+	note := utils.SanitizeInput(request.Note, utils.DefaultTextOptions)
+
+	change, err := services.TransitionOrder(c.Request.Context(), orderID, status, user, note)
+	if err != nil {
+		respondOrderTransitionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, change)
+}
+
+// CancelOrder lets a buyer cancel their order before it ships. Reserved stock is returned
+// and a paid order is refunded in full.
+func CancelOrder(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		Reason string `json:"reason"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	order, err := database.GetOrderByBuyer(c.Request.Context(), c.Param("id"), user.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order"})
+		return
+	}
+
+	reason := utils.SanitizeInput(request.Reason, utils.DefaultTextOptions)
+	if reason == "" {
+		reason = "Cancelled by buyer"
+	}
+
+	change, refund, err := payments.CancelOrder(c.Request.Context(), order, user, reason)
+	switch {
+	case errors.Is(err, payments.ErrOrderNotCancellable):
+		c.JSON(http.StatusConflict, gin.H{"error": "Only pending or paid orders can be cancelled", "status": order.Status})
+		return
+	case errors.Is(err, services.ErrPeriodClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": "The accounting period of this order is closed; contact support"})
+		return
+	case errors.Is(err, payments.ErrOrderDisputed):
+		c.JSON(http.StatusConflict, gin.H{"error": "The payment of this order is disputed with your card issuer"})
+		return
+	case errors.Is(err, payments.ErrCancelRefundFailed):
+		c.JSON(http.StatusOK, gin.H{
+			"message":      "Order cancelled",
+			"change":       change,
+			"refund_error": "The refund could not be issued automatically and will be handled by support",
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel order"})
+		return
+	}
+
+	response := gin.H{"message": "Order cancelled", "change": change}
+	if refund != nil {
+		response["refund"] = refund
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// respondOrderTransitionError maps order service errors to HTTP responses
+func respondOrderTransitionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	case errors.Is(err, services.ErrInvalidTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTransitionConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "Order status changed, please retry"})
+	case errors.Is(err, database.ErrFulfillmentStarted):
+		c.JSON(http.StatusConflict, gin.H{"error": "Order items were already shipped"})
+	case errors.Is(err, services.ErrPeriodClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order status"})
+	}
+}
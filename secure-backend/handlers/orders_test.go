@@ -0,0 +1,114 @@
+//go:build e2e
+
+// Buyer order history tests against a real PostgreSQL database (with database/schema.sql applied):
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run TestOrderHistory ./handlers
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"secure-backend/database"
+	"secure-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderHistory(t *testing.T) {
+	users := createTestUsers(t, "history", "seller", "buyer", "buyer", "buyer")
+	seller, buyer, other, newcomer := users[0], users[1], users[2], users[3]
+	ctx := context.Background()
+	t.Cleanup(func() {
+		database.DB.ExecContext(context.Background(), `DELETE FROM orders WHERE buyer_id IN ($1, $2)`, buyer.ID, other.ID)
+	})
+
+	var productID string
+	require.NoError(t, database.DB.GetContext(ctx, &productID, `
+		INSERT INTO products (name, price, stock, status, seller_id) VALUES ('History mug', 4, 10, 'published', $1) RETURNING id
+	`, seller.ID))
+
+	// order creates an order placed daysAgo with quantity units of the mug
+	order := func(owner *models.AuthUser, daysAgo, quantity int) string {
+		var id string
+		require.NoError(t, database.DB.GetContext(ctx, &id, `
+			INSERT INTO orders (buyer_id, status, total_amount, created_at)
+			VALUES ($1, 'pending', $2, now() - make_interval(days => $3)) RETURNING id
+		`, owner.ID, 4*quantity, daysAgo))
+		_, err := database.DB.ExecContext(ctx, `
+			INSERT INTO order_items (order_id, product_id, quantity, unit_price, total_price) VALUES ($1, $2, $3, 4, $4)
+		`, id, productID, quantity, 4*quantity)
+		require.NoError(t, err)
+		return id
+	}
+	oldest, middle, newest := order(buyer, 3, 1), order(buyer, 2, 2), order(buyer, 1, 3)
+	foreign := order(other, 0, 1)
+
+	type historyPage struct {
+		Orders []models.OrderWithDetails `json:"orders"`
+		Total  int                       `json:"total"`
+		Limit  int                       `json:"limit"`
+		Offset int                       `json:"offset"`
+	}
+	list := func(user *models.AuthUser, query string) historyPage {
+		t.Helper()
+		w := serve(GetOrders, user, http.MethodGet, "/api/orders"+query, "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var page historyPage
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		return page
+	}
+	orderIDs := func(orders []models.OrderWithDetails) []string {
+		ids := make([]string, len(orders))
+		for i, order := range orders {
+			ids[i] = order.ID
+		}
+		return ids
+	}
+
+	// Newest first, paginated, only the buyer's own orders
+	page := list(buyer, "?limit=2")
+	assert.Equal(t, 3, page.Total)
+	assert.Equal(t, 2, page.Limit)
+	assert.Equal(t, []string{newest, middle}, orderIDs(page.Orders))
+	page = list(buyer, "?limit=2&offset=2")
+	assert.Equal(t, 3, page.Total)
+	assert.Equal(t, []string{oldest}, orderIDs(page.Orders))
+
+	// Orders come with their items, the items' products and the buyer
+	first := list(buyer, "?limit=1").Orders[0]
+	require.Len(t, first.Items, 1)
+	assert.Equal(t, 3, first.Items[0].Quantity)
+	assert.Equal(t, productID, first.Items[0].Product.ID)
+	assert.Equal(t, "History mug", first.Items[0].Product.Name)
+	assert.Equal(t, buyer.ID, first.User.ID)
+
+	// A buyer without orders gets an empty list
+	page = list(newcomer, "")
+	assert.Zero(t, page.Total)
+	assert.NotNil(t, page.Orders)
+	assert.Empty(t, page.Orders)
+
+	w := serve(GetOrders, buyer, http.MethodGet, "/api/orders?offset=-1", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// A single order, only for its buyer
+	get := func(user *models.AuthUser, orderID string) *httptest.ResponseRecorder {
+		return serve(GetOrder, user, http.MethodGet, "/api/orders/"+orderID, "", gin.Param{Key: "id", Value: orderID})
+	}
+	w = get(buyer, middle)
+	require.Equal(t, http.StatusOK, w.Code)
+	var detail models.OrderWithDetails
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &detail))
+	assert.Equal(t, middle, detail.ID)
+	require.Len(t, detail.Items, 1)
+	assert.Equal(t, 2, detail.Items[0].Quantity)
+
+	assert.Equal(t, http.StatusNotFound, get(buyer, foreign).Code, "another buyer's order")
+	assert.Equal(t, http.StatusOK, get(other, foreign).Code)
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+
+	// TotalCountHeader carries the total number of items for endpoints that return a bare list
+	TotalCountHeader = "X-Total-Count"
+)
+
+// Pagination holds limit/offset paging parameters parsed from the query string
+type Pagination struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// parsePagination reads ?limit= and ?offset= from the request, applying defaults and bounds
+func parsePagination(c *gin.Context) (Pagination, error) {
+	page := Pagination{Limit: defaultPageLimit}
+
+	if limitParam := c.Query("limit"); limitParam != "" {
+		limit, err := strconv.Atoi(limitParam)
+		if err != nil || limit < 1 {
+			return page, errors.New("limit must be a positive integer")
+		}
+		if limit > maxPageLimit {
+			limit = maxPageLimit
+		}
+		page.Limit = limit
+	}
+
+	if offsetParam := c.Query("offset"); offsetParam != "" {
+		offset, err := strconv.Atoi(offsetParam)
+		if err != nil || offset < 0 {
+			return page, errors.New("offset must be a non-negative integer")
+		}
+		page.Offset = offset
+	}
+
+	return page, nil
+}
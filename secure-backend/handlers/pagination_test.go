@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePagination(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		query   string
+		want    Pagination
+		wantErr bool
+	}{
+		{"", Pagination{Limit: defaultPageLimit}, false},
+		{"limit=1", Pagination{Limit: 1}, false},
+		{"limit=100", Pagination{Limit: maxPageLimit}, false},
+		{"limit=101", Pagination{Limit: maxPageLimit}, false},
+		{"limit=1000000", Pagination{Limit: maxPageLimit}, false},
+		{"offset=0", Pagination{Limit: defaultPageLimit}, false},
+		{"limit=5&offset=1000000", Pagination{Limit: 5, Offset: 1000000}, false},
+		{"limit=0", Pagination{}, true},
+		{"limit=-1", Pagination{}, true},
+		{"limit=ten", Pagination{}, true},
+		{"limit=1.5", Pagination{}, true},
+		{"offset=-1", Pagination{}, true},
+		{"offset=first", Pagination{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/?"+tt.query, nil)
+
+			page, err := parsePagination(c)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, page)
+		})
+	}
+}
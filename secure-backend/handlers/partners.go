@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/middleware"
+	"secure-backend/models"
+	"secure-backend/services"
+	"secure-backend/utils"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultPartnerDailyQuota is the daily request quota of partner keys created without one
+const defaultPartnerDailyQuota = 5000
+
+// GetPartnerCatalog lists the catalog for a comparison-shopping partner, ordered by last
+// update, with only the fields granted to the partner's key (?fields= narrows them further).
+// A full sync starts without parameters or from ?updated_since= (RFC 3339) and follows
+// next_cursor while has_more is set; polling later with the last next_cursor returns only
+// products changed since. Withdrawn products are listed as unavailable.
+func GetPartnerCatalog(c *gin.Context) {
+	value, _ := c.Get(middleware.PartnerKey)
+	key, ok := value.(*models.PartnerAPIKey)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or missing API key"})
+		return
+	}
+
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var after models.CatalogCursor
+	if cursor := c.Query("cursor"); cursor != "" {
+		after, err = models.DecodeCatalogCursor(cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else if since := c.Query("updated_since"); since != "" {
+		after.UpdatedAt, err = time.Parse(time.RFC3339, since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "updated_since must be an RFC 3339 timestamp"})
+			return
+		}
+	}
+
+	fields, ok := partnerFields(c, key)
+	if !ok {
+		return
+	}
+
+	// Fetch one extra product to tell whether another page follows
+	products, err := database.GetPartnerCatalog(c.Request.Context(), after, page.Limit+1)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load catalog"})
+		return
+	}
+	hasMore := len(products) > page.Limit
+	if hasMore {
+		products = products[:page.Limit]
+	}
+
+	items := make([]map[string]interface{}, 0, len(products))
+	for _, product := range products {
+		items = append(items, models.PartnerCatalogEntry(product, fields))
+	}
+	if len(products) > 0 {
+		last := products[len(products)-1]
+		after = models.CatalogCursor{UpdatedAt: last.UpdatedAt, ID: last.ID}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":       items,
+		"has_more":    hasMore,
+		"next_cursor": after.Encode(),
+	})
+}
+
+// partnerFields returns the fields granted to the key, narrowed to ?fields= when given,
+// responding 403 and returning false if it asks for a field that wasn't granted
+func partnerFields(c *gin.Context, key *models.PartnerAPIKey) ([]string, bool) {
+	fields := []string(key.Fields)
+	if requested := c.Query("fields"); requested != "" {
+		fields = nil
+		for _, field := range strings.Split(requested, ",") {
+			field = strings.TrimSpace(field)
+			if !key.HasField(field) {
+				c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Field %q is not available to this API key", field)})
+				return nil, false
+			}
+			fields = append(fields, field)
+		}
+	}
+	return fields, true
+}
+
+// GetPartnerChanges lists the catalog change feed: product creations, updates, deletions
+// and stock changes in the order they were committed, after ?cursor= (from the start of the
+// feed without one). Each change carries the product's current entry with the granted
+// fields, or none once the product is deleted, so replaying a change is harmless. Stock
+// changes are only listed to keys granted the stock field. Cursors older than the feed's
+// retention answer 410; the partner then resyncs from the catalog.
+func GetPartnerChanges(c *gin.Context) {
+	value, _ := c.Get(middleware.PartnerKey)
+	key, ok := value.(*models.PartnerAPIKey)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or missing API key"})
+		return
+	}
+
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var after models.ChangeCursor
+	if cursor := c.Query("cursor"); cursor != "" {
+		after, err = models.DecodeChangeCursor(cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if after.ID > 0 {
+		exists, err := database.CatalogChangeExists(c.Request.Context(), after.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load changes"})
+			return
+		} else if !exists {
+			c.JSON(http.StatusGone, gin.H{"error": "Cursor has expired; resync from /api/partner/catalog"})
+			return
+		}
+	}
+
+	fields, ok := partnerFields(c, key)
+	if !ok {
+		return
+	}
+
+	// Fetch one extra change to tell whether another page follows
+	changes, err := database.GetCatalogChanges(c.Request.Context(), after, key.HasField("stock"), page.Limit+1)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load changes"})
+		return
+	}
+	hasMore := len(changes) > page.Limit
+	if hasMore {
+		changes = changes[:page.Limit]
+	}
+
+	ids := make([]string, len(changes))
+	for i, change := range changes {
+		ids[i] = change.ProductID
+	}
+	products, err := database.GetProductsByIDs(c.Request.Context(), ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load changes"})
+		return
+	}
+
+	items := make([]gin.H, 0, len(changes))
+	for _, change := range changes {
+		item := gin.H{"change": change.Change, "product_id": change.ProductID, "changed_at": change.CreatedAt}
+		if product, ok := products[change.ProductID]; ok {
+			item["product"] = models.PartnerCatalogEntry(*product, fields)
+		}
+		items = append(items, item)
+	}
+	if len(changes) > 0 {
+		last := changes[len(changes)-1]
+		after = models.ChangeCursor{XactID: last.XactID, ID: last.ID}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"changes":     items,
+		"has_more":    hasMore,
+		"next_cursor": after.Encode(),
+	})
+}
+
+// GetPartnerAPIKeys lists the partner API keys (admin only). Secrets are never returned.
+func GetPartnerAPIKeys(c *gin.Context) {
+	keys, err := database.GetPartnerAPIKeys(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load partner API keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"keys": keys, "available_fields": models.PartnerCatalogFields})
+}
+
+// CreatePartnerAPIKey issues an API key for a partner with the catalog fields it may read
+// and its daily quota (admin only). The key is only shown in this response.
+func CreatePartnerAPIKey(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		Name       string   `json:"name" binding:"required"`
+		Fields     []string `json:"fields" binding:"required,min=1"`
+		DailyQuota *int     `json:"daily_quota" binding:"omitempty,min=0"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key := &models.PartnerAPIKey{
+		Name:       utils.SanitizeInput(request.Name, utils.DefaultTextOptions),
+		DailyQuota: defaultPartnerDailyQuota,
+	}
+	if key.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+		return
+	}
+	if request.DailyQuota != nil {
+		key.DailyQuota = *request.DailyQuota
+	}
+	for _, field := range request.Fields {
+		if !models.ValidPartnerField(field) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown field %q", field)})
+			return
+		}
+		if !key.HasField(field) {
+			key.Fields = append(key.Fields, field)
+		}
+	}
+
+	created, secret, err := services.IssuePartnerAPIKey(c.Request.Context(), key, &models.AuditEntry{
+		ActorID:    &user.ID,
+		ActorEmail: user.Email,
+		Action:     models.AuditPartnerKeyCreated,
+		IPAddress:  c.ClientIP(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create partner API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"key": created, "api_key": secret})
+}
+
+// RevokePartnerAPIKey revokes a partner API key; requests with it fail immediately (admin only)
+func RevokePartnerAPIKey(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	id := sanitizedIDParam(c)
+	err = database.RevokePartnerAPIKey(c.Request.Context(), id, &models.AuditEntry{
+		ActorID:    &user.ID,
+		ActorEmail: user.Email,
+		Action:     models.AuditPartnerKeyRevoked,
+		Detail:     "partner key " + id,
+		IPAddress:  c.ClientIP(),
+	})
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Partner API key not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke partner API key"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
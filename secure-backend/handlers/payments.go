@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/money"
+	"secure-backend/payments"
+	"secure-backend/tokens"
+	"secure-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loadBuyerOrder binds {"order_id"} from the body and loads the order, writing an error response on failure
+func loadBuyerOrder(c *gin.Context) (*models.Order, bool) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	var request struct {
+		OrderID string `json:"order_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	order, err := database.GetOrderByBuyer(c.Request.Context(), request.OrderID, user.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return nil, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order"})
+		return nil, false
+	}
+
+	return order, true
+}
+
+// CreatePaymentIntent creates a Stripe PaymentIntent for one of the buyer's pending orders
+// and returns the client secret used by Stripe.js to collect the payment
+func CreatePaymentIntent(c *gin.Context) {
+	order, ok := loadBuyerOrder(c)
+	if !ok {
+		return
+	}
+
+	intent, payment, err := payments.CreatePaymentIntent(c.Request.Context(), order)
+	if err != nil {
+		respondPaymentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"payment_id":    payment.ID,
+		"client_secret": intent.ClientSecret,
+		"amount":        payment.Amount,
+		"currency":      payment.Currency,
+		"status":        intent.Status,
+	})
+}
+
+// ConfirmPayment verifies with the provider that the order's payment succeeded and marks it paid
+func ConfirmPayment(c *gin.Context) {
+	order, ok := loadBuyerOrder(c)
+	if !ok {
+		return
+	}
+
+	payment, err := payments.ConfirmOrderPayment(c.Request.Context(), order)
+	if errors.Is(err, payments.ErrPaymentIncomplete) {
+		c.JSON(http.StatusPaymentRequired, gin.H{"error": "Payment has not been completed", "status": payment.Status})
+		return
+	} else if err != nil {
+		respondPaymentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Payment confirmed", "payment": payment})
+}
+
+// GetPaymentUpdate opens the signed link sent to a buyer whose payment failed. It returns the
+// order's PaymentIntent client secret so Stripe.js can pay it with another card before the final retry.
+func GetPaymentUpdate(c *gin.Context) {
+	orderID, err := tokens.Subject(c.Param("token"), tokens.PurposePaymentUpdate, clk.Now())
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment link is invalid or expired"})
+		return
+	}
+
+	dunning, intent, err := payments.PaymentUpdate(c.Request.Context(), orderID)
+	if err != nil {
+		respondPaymentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"order_id":         dunning.OrderID,
+		"client_secret":    intent.ClientSecret,
+		"amount":           money.Amount(intent.Amount),
+		"currency":         intent.Currency,
+		"status":           intent.Status,
+		"attempts":         dunning.Attempts,
+		"next_attempt_at":  dunning.NextAttemptAt,
+		"final_attempt_at": dunning.FinalAttemptAt,
+	})
+}
+
+// respondPaymentError maps payment errors to HTTP responses
+func respondPaymentError(c *gin.Context, err error) {
+	var stripeErr *payments.StripeError
+	switch {
+	case errors.Is(err, payments.ErrNotConfigured):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Payments are not configured"})
+	case errors.Is(err, payments.ErrOrderNotPayable):
+		c.JSON(http.StatusConflict, gin.H{"error": "Order is not awaiting payment"})
+	case errors.Is(err, payments.ErrPaymentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "No payment found for this order"})
+	case errors.As(err, &stripeErr) && stripeErr.StatusCode < 500:
+		log.Printf("Stripe rejected request: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Payment provider rejected the request"})
+	default:
+		log.Printf("Payment error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Payment processing failed"})
+	}
+}
+
+// MaxWebhookBodySize caps the size of payment provider webhook payloads
+const MaxWebhookBodySize = 256 << 10
+
+// PaymentWebhook receives payment provider events. The signature is verified against the raw
+// body and each event is processed at most once; redeliveries of processed events are acknowledged.
+func PaymentWebhook(c *gin.Context) {
+	payload, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	event, err := payments.ParseWebhook(payload, c.GetHeader("Stripe-Signature"))
+	switch {
+	case errors.Is(err, payments.ErrWebhookNotConfigured):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Webhooks are not configured"})
+		return
+	case errors.Is(err, payments.ErrInvalidSignature), errors.Is(err, payments.ErrSignatureExpired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid signature"})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event payload"})
+		return
+	}
+
+	duplicate, err := payments.HandleEvent(c.Request.Context(), event, payload)
+	if err != nil {
+		// A non-2xx response makes the provider retry the event later
+		log.Printf("Failed to process webhook event %s (%s): %v", event.ID, event.Type, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process event"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"received": true, "duplicate": duplicate})
+}
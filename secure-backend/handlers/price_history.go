@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/money"
+	"secure-backend/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// referencePriceWindow is how far back the reference (lowest recent) price looks, so
+// buyers can tell whether a "discount" is measured against a genuine earlier price
+const referencePriceWindow = 30 * 24 * time.Hour
+
+// GetPriceHistory lists every price a product has had along with the lowest price in the
+// last 30 days. Unpublished products are only visible to their seller and admins.
+func GetPriceHistory(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized access"})
+		return
+	}
+
+	product, err := database.GetProductByID(c.Request.Context(), c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch product"})
+		return
+	}
+	if product.Status != "published" && product.SellerID != user.ID && user.Role != "admin" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	history, err := database.GetPriceHistory(c.Request.Context(), product.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load price history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"product_id":           product.ID,
+		"current_price":        product.Price,
+		"lowest_price_30_days": lowestPriceSince(history, product.Price, clk.Now().Add(-referencePriceWindow)),
+		"history":              history,
+	})
+}
+
+// lowestPriceSince returns the lowest price in effect at any time after since: the price
+// at the start of the window, every price set during it, and the current price
+func lowestPriceSince(history []models.PriceChange, current money.Amount, since time.Time) money.Amount {
+	lowest := current
+	for i, change := range history {
+		// A change before the window still counts if it was in effect when the window began
+		inEffect := !change.CreatedAt.Before(since) ||
+			i+1 == len(history) || history[i+1].CreatedAt.After(since)
+		if inEffect && change.NewPrice < lowest {
+			lowest = change.NewPrice
+		}
+	}
+	return lowest
+}
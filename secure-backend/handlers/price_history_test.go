@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"secure-backend/models"
+	"secure-backend/money"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLowestPriceSince(t *testing.T) {
+	now := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+	since := now.Add(-referencePriceWindow)
+	change := func(daysAgo int, price money.Amount) models.PriceChange {
+		return models.PriceChange{NewPrice: price, CreatedAt: now.AddDate(0, 0, -daysAgo)}
+	}
+
+	// Created at 50, briefly 40 two months ago, raised to 80 right before a "sale" to 60
+	history := []models.PriceChange{change(90, 5000), change(60, 4000), change(45, 5000), change(5, 8000), change(1, 6000)}
+	assert.Equal(t, money.Amount(5000), lowestPriceSince(history, 6000, since), "price in effect when the window opened counts")
+
+	// Older changes that were superseded before the window don't
+	history = []models.PriceChange{change(90, 1000), change(40, 7000)}
+	assert.Equal(t, money.Amount(7000), lowestPriceSince(history, 7000, since))
+
+	assert.Equal(t, money.Amount(2500), lowestPriceSince(nil, 2500, since))
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/idgen"
+	"secure-backend/storage"
+	"secure-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxProductImageSize caps the size of an uploaded product image
+const MaxProductImageSize = 5 << 20
+
+// MaxProductImageBodySize leaves room for the multipart framing around the image
+const MaxProductImageBodySize = MaxProductImageSize + 64<<10
+
+// productImageTypes maps the accepted image content types to their file extensions
+var productImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// imageIDs names uploaded image objects
+var imageIDs idgen.Generator = idgen.UUID()
+
+// detectImageType sniffs the content type from the file's magic bytes, ignoring whatever
+// type or extension the client claimed. ok is false for anything but an accepted image.
+func detectImageType(data []byte) (contentType, ext string, ok bool) {
+	contentType = http.DetectContentType(data)
+	ext, ok = productImageTypes[contentType]
+	return contentType, ext, ok
+}
+
+// UploadProductImage stores a multipart "image" upload in object storage and makes it the
+// product's image. Only the seller who owns the product can upload.
+func UploadProductImage(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Checked before the upload is stored, so other sellers can't put files under the
+	// product's key prefix; the update below checks again
+	productID := c.Param("id")
+	_, err = database.GetProductBySeller(c.Request.Context(), productID, user.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch product"})
+		return
+	}
+
+	header, err := c.FormFile("image")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image must be at most 5 MB"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Multipart field \"image\" is required"})
+		return
+	}
+	if header.Size > MaxProductImageSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image must be at most 5 MB"})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read image"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, MaxProductImageSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read image"})
+		return
+	}
+	if len(data) > MaxProductImageSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image must be at most 5 MB"})
+		return
+	}
+
+	contentType, ext, ok := detectImageType(data)
+	if !ok {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Image must be a JPEG, PNG, GIF or WebP file"})
+		return
+	}
+
+	key := fmt.Sprintf("products/%s/%s%s", productID, imageIDs.NewID(), ext)
+	imageURL, err := storage.Upload(c.Request.Context(), key, contentType, data)
+	if errors.Is(err, storage.ErrNotConfigured) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Image storage is not configured"})
+		return
+	} else if err != nil {
+		log.Printf("Product image upload failed: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to store image"})
+		return
+	}
+
+	hash := sha256.Sum256(data)
+	err = database.UpdateProductImage(c.Request.Context(), productID, user.ID, imageURL, hex.EncodeToString(hash[:]))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"url": imageURL})
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import "testing"
+
+func TestDetectImageTypeUsesMagicBytes(t *testing.T) {
+	cases := []struct {
+		name string
+		data []byte
+		want string
+		ok   bool
+	}{
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), ".png", true},
+		{"jpeg", []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00"), ".jpg", true},
+		{"gif", []byte("GIF89a\x01\x00\x01\x00"), ".gif", true},
+		{"webp", []byte("RIFF\x24\x00\x00\x00WEBPVP8 "), ".webp", true},
+		{"html", []byte("<html><script>alert(1)</script></html>"), "", false},
+		{"svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), "", false},
+		{"empty", nil, "", false},
+	}
+
+	for _, tc := range cases {
+		_, ext, ok := detectImageType(tc.data)
+		if ok != tc.ok || ext != tc.want {
+			t.Errorf("%s: got (%q, %v), want (%q, %v)", tc.name, ext, ok, tc.want, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProductSlug(t *testing.T) {
+	tests := []struct {
+		requested, name, want string
+	}{
+		{"", "Desk Lamp", "desk-lamp"},
+		{"Reading Light!", "Desk Lamp", "reading-light"},
+		{"---", "Desk Lamp", "desk-lamp"},
+		// The raw name is used, before HTML escaping turns the apostrophe into an entity
+		{"", "Tom's Lamp & Shade", "tom-s-lamp-shade"},
+		{"", "!!!", "product"},
+		{"", strings.Repeat("lamp ", 20), strings.TrimSuffix(strings.Repeat("lamp-", 10), "-")},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, productSlug(tt.requested, tt.name), "%q, %q", tt.requested, tt.name)
+	}
+}
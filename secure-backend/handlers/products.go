@@ -2,19 +2,26 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
+	"log"
 	"net/http"
 	"secure-backend/database"
+	"secure-backend/locale"
 	"secure-backend/models"
+	"secure-backend/services"
 	"secure-backend/utils"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-// GetProducts returns products based on user's role:
+// GetProducts returns a page of products (?limit=, ?offset=, optionally narrowed
+// by ?category= and ?tag= slugs) based on user's role:
 // - Buyers see all published products
 // - Sellers see only their own products
 // - Admins see all products
+// The total number of matching products is returned in the X-Total-Count header.
 func GetProducts(c *gin.Context) {
 	user, err := utils.GetAuthUser(c)
 	if err != nil {
@@ -22,14 +29,26 @@ func GetProducts(c *gin.Context) {
 		return
 	}
 
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter := database.ProductFilter{
+		Category: strings.ToLower(strings.TrimSpace(c.Query("category"))),
+		Tag:      strings.ToLower(strings.TrimSpace(c.Query("tag"))),
+	}
+
 	var products []models.Product
+	var total int
 
 	if utils.IsAdmin(c) {
-		products, err = database.GetAllProducts()
+		products, total, err = database.GetAllProducts(c.Request.Context(), filter, page.Limit, page.Offset)
 	} else if utils.IsSeller(c) {
-		products, err = database.GetProductsBySeller(user.ID)
+		products, total, err = database.GetProductsBySeller(c.Request.Context(), user.ID, filter, page.Limit, page.Offset)
 	} else {
-		products, err = database.GetPublishedProducts()
+		products, total, err = database.GetPublishedProducts(c.Request.Context(), filter, page.Limit, page.Offset)
 	}
 
 	if err != nil {
@@ -37,14 +56,63 @@ func GetProducts(c *gin.Context) {
 		return
 	}
 
+	locale.LabelProducts(requestLanguage(c), products)
+	c.Header(TotalCountHeader, strconv.Itoa(total))
 	c.JSON(http.StatusOK, products)
 }
 
+// SearchProducts runs a full-text search over product names and descriptions (?q=),
+// ranked by relevance with highlighted snippets. Visibility follows GetProducts.
+func SearchProducts(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := utils.SanitizeSearchQuery(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query is required"})
+		return
+	}
+
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sellerID, publishedOnly := "", true
+	if utils.IsAdmin(c) {
+		publishedOnly = false
+	} else if utils.IsSeller(c) {
+		sellerID, publishedOnly = user.ID, false
+	}
+
+	results, total, err := database.SearchProducts(c.Request.Context(), query, sellerID, publishedOnly, page.Limit, page.Offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search products"})
+		return
+	}
+	lang := requestLanguage(c)
+	for i := range results {
+		results[i].StatusLabel = locale.Label(lang, locale.ProductStatus, results[i].Status)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"query":   query,
+		"results": results,
+		"total":   total,
+		"limit":   page.Limit,
+		"offset":  page.Offset,
+	})
+}
+
 // CreateProduct allows sellers to create new products
 func CreateProduct(c *gin.Context) {
-	user, err := utils.RequireRole(c, "seller")
+	user, err := utils.GetAuthUser(c)
 	if err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -54,10 +122,17 @@ func CreateProduct(c *gin.Context) {
 		return
 	}
 
+	// Derive the slug from the raw name (or requested slug); the name is HTML-escaped for display
+	product.Slug = productSlug(product.Slug, product.Name)
+
 	// Sanitize all user inputs
 	product.Name = utils.SanitizeProductName(product.Name)
 	product.Description = utils.SanitizeProductDescription(product.Description)
 	product.Image = utils.SanitizeInput(product.Image, utils.DefaultTextOptions)
+	product.ImageAlt = utils.SanitizeAltText(product.ImageAlt)
+	product.MetaTitle = utils.SanitizeMetaTitle(product.MetaTitle)
+	product.MetaDescription = utils.SanitizeMetaDescription(product.MetaDescription)
+	sanitizeShelfLocation(&product)
 
 	// Validate that required fields are not empty after sanitization
 	if strings.TrimSpace(product.Name) == "" {
@@ -88,25 +163,42 @@ func CreateProduct(c *gin.Context) {
 		return
 	}
 
+	// Validate dimensions and weight if provided
+	if msg := validateMeasurements(&product); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	// Validate purchase limits
+	if msg := validateOrderQuantities(&product); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	// Validate category and tags
+	if msg := normalizeTaxonomy(&product); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
 	// Save the product
-	if err := database.CreateProduct(&product); err != nil {
+	if err := database.CreateProduct(c.Request.Context(), &product); isUnknownTaxonomy(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product"})
 		return
 	}
 
+	// Let the seller know which accessibility metadata is still missing
+	product.Warnings = publishWarnings(&product)
+
 	c.JSON(http.StatusCreated, product)
 }
 
 // GetProduct handles retrieving a single product by ID
 // Any authenticated user can view products
 func GetProduct(c *gin.Context) {
-	// Extract user info from context
-	_, err := utils.GetAuthUser(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized access"})
-		return
-	}
-
 	// Get product ID from URL parameter
 	productID := c.Param("id")
 	if productID == "" {
@@ -114,8 +206,26 @@ func GetProduct(c *gin.Context) {
 		return
 	}
 
-	// Get the product using database package
-	product, err := database.GetProductByID(productID)
+	product, err := database.GetProductByID(c.Request.Context(), productID)
+	respondProduct(c, product, err)
+}
+
+// GetProductBySlug looks a product up by its slug so storefronts can use friendly URLs
+func GetProductBySlug(c *gin.Context) {
+	product, err := database.GetProductBySlug(c.Request.Context(), c.Param("slug"))
+	respondProduct(c, product, err)
+}
+
+// respondProduct writes a looked-up product with its recommendations, and accessibility
+// warnings for its seller
+func respondProduct(c *gin.Context, product *models.Product, err error) {
+	// Extract user info from context
+	user, authErr := utils.GetAuthUser(c)
+	if authErr != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized access"})
+		return
+	}
+
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
 		return
@@ -124,17 +234,125 @@ func GetProduct(c *gin.Context) {
 		return
 	}
 
+	// Show accessibility warnings and the shelf location to the owning seller
+	if product.SellerID == user.ID {
+		product.Warnings = product.AccessibilityWarnings()
+		if location, err := database.GetProductShelfLocation(c.Request.Context(), product.ID); err == nil {
+			product.ShelfLocation = &location
+		} else {
+			log.Printf("Failed to load shelf location of product %s: %v", product.ID, err)
+		}
+	}
+
+	// Cross-sells and upsells are extras; the product is still returned without them
+	recommendations, err := database.GetProductRecommendations(c.Request.Context(), []string{product.ID}, true)
+	if err != nil {
+		log.Printf("Failed to load recommendations for product %s: %v", product.ID, err)
+	}
+	product.Recommendations = recommendations
+
+	// Mark products of sellers on vacation as temporarily unavailable
+	vacation, err := database.GetSellerVacation(c.Request.Context(), product.SellerID)
+	if err != nil {
+		log.Printf("Failed to load vacation of seller %s: %v", product.SellerID, err)
+	} else if vacation.Active(clk.Now()) {
+		product.SellerVacation = vacation
+	}
+
+	product.StatusLabel = locale.Label(requestLanguage(c), locale.ProductStatus, product.Status)
+
 	// Return the product
 	c.JSON(http.StatusOK, product)
 }
 
+// productSlug returns the slug requested for a new product, or one derived from its name
+func productSlug(requested, name string) string {
+	slug := utils.Slugify(requested)
+	if slug == "" {
+		slug = utils.Slugify(name)
+	}
+	if slug == "" {
+		slug = "product"
+	}
+	return slug
+}
+
+// sanitizeShelfLocation sanitizes a product's shelf location if one was sent
+func sanitizeShelfLocation(product *models.Product) {
+	if product.ShelfLocation != nil {
+		location := utils.SanitizeShelfLocation(*product.ShelfLocation)
+		product.ShelfLocation = &location
+	}
+}
+
+// publishWarnings returns missing accessibility metadata for products being published
+func publishWarnings(product *models.Product) []string {
+	if product.Status != "published" {
+		return nil
+	}
+	return product.AccessibilityWarnings()
+}
+
+// validateMeasurements checks that provided dimensions and weight are positive,
+// returning an error message or an empty string
+func validateMeasurements(product *models.Product) string {
+	measurements := []struct {
+		field string
+		value *float64
+	}{
+		{"width_cm", product.WidthCm},
+		{"height_cm", product.HeightCm},
+		{"depth_cm", product.DepthCm},
+		{"weight_kg", product.WeightKg},
+	}
+	for _, m := range measurements {
+		if m.value != nil && *m.value <= 0 {
+			return m.field + " must be greater than 0"
+		}
+	}
+	return ""
+}
+
+// validateOrderQuantities checks a product's per-order purchase limits, defaulting the
+// minimum to 1, and returns an error message or an empty string
+func validateOrderQuantities(product *models.Product) string {
+	if product.MinOrderQuantity < 0 {
+		return "min_order_quantity must not be negative"
+	}
+	if product.MinOrderQuantity == 0 {
+		product.MinOrderQuantity = 1
+	}
+	if product.MaxOrderQuantity != nil && *product.MaxOrderQuantity < product.MinOrderQuantity {
+		return "max_order_quantity must not be less than min_order_quantity"
+	}
+	return ""
+}
+
+// normalizeTaxonomy cleans up the category and tag references of a product,
+// returning an error message or an empty string
+func normalizeTaxonomy(product *models.Product) string {
+	if product.CategoryID != nil && strings.TrimSpace(*product.CategoryID) == "" {
+		product.CategoryID = nil
+	}
+	product.Tags = normalizeProductTags(product.Tags)
+	if len(product.Tags) > maxProductTags {
+		return "A product can have at most 20 tags"
+	}
+	return ""
+}
+
+// isUnknownTaxonomy reports whether a product write failed because of a missing category or tag
+func isUnknownTaxonomy(err error) bool {
+	return errors.Is(err, database.ErrUnknownCategory) || errors.Is(err, database.ErrUnknownTag)
+}
+
 // UpdateProduct handles updating a product
 // Only sellers can update their own products
 func UpdateProduct(c *gin.Context) {
 	// Extract user info and verify seller role
-	user, err := utils.RequireRole(c, "seller")
+	user, err := utils.GetAuthUser(c)
 	if err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -145,16 +363,6 @@ func UpdateProduct(c *gin.Context) {
 		return
 	}
 
-	// Verify product belongs to seller
-	_, err = database.GetProductBySeller(productID, user.ID)
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
-		return
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch product"})
-		return
-	}
-
 	// Bind update data
 	var updateProduct models.Product
 	if err := c.ShouldBindJSON(&updateProduct); err != nil {
@@ -162,10 +370,15 @@ func UpdateProduct(c *gin.Context) {
 		return
 	}
 
-	// Sanitize all user inputs
+	// Sanitize all user inputs (an empty slug keeps the current one)
+	updateProduct.Slug = utils.Slugify(updateProduct.Slug)
 	updateProduct.Name = utils.SanitizeProductName(updateProduct.Name)
 	updateProduct.Description = utils.SanitizeProductDescription(updateProduct.Description)
 	updateProduct.Image = utils.SanitizeInput(updateProduct.Image, utils.DefaultTextOptions)
+	updateProduct.ImageAlt = utils.SanitizeAltText(updateProduct.ImageAlt)
+	updateProduct.MetaTitle = utils.SanitizeMetaTitle(updateProduct.MetaTitle)
+	updateProduct.MetaDescription = utils.SanitizeMetaDescription(updateProduct.MetaDescription)
+	sanitizeShelfLocation(&updateProduct)
 
 	// Validate that required fields are not empty after sanitization
 	if strings.TrimSpace(updateProduct.Name) == "" {
@@ -185,27 +398,61 @@ func UpdateProduct(c *gin.Context) {
 		return
 	}
 
+	// Validate dimensions and weight if provided
+	if msg := validateMeasurements(&updateProduct); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	// Validate purchase limits
+	if msg := validateOrderQuantities(&updateProduct); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	// Validate category and tags
+	if msg := normalizeTaxonomy(&updateProduct); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
 	// Set the product ID and seller ID
 	updateProduct.ID = productID
 	updateProduct.SellerID = user.ID
 
-	// Update the product
-	err = database.UpdateProduct(&updateProduct)
-	if err != nil {
+	// Update the product (only matches the seller's own)
+	err = database.UpdateProduct(c.Request.Context(), &updateProduct)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	} else if isUnknownTaxonomy(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	} else if errors.Is(err, database.ErrSlugTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Product updated successfully"})
+	services.NotifyPriceDrops(c.Request.Context(), &updateProduct)
+
+	response := gin.H{"message": "Product updated successfully"}
+	if warnings := publishWarnings(&updateProduct); len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // DeleteProduct handles product deletion
 // Only sellers can delete their own products
 func DeleteProduct(c *gin.Context) {
 	// Extract user info and verify seller role
-	user, err := utils.RequireRole(c, "seller")
+	user, err := utils.GetAuthUser(c)
 	if err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -216,27 +463,161 @@ func DeleteProduct(c *gin.Context) {
 		return
 	}
 
-	// First verify the product exists and belongs to the seller
-	_, err = database.GetProductBySeller(productID, user.ID)
+	// Delete the product (only matches the seller's own)
+	err = database.DeleteProduct(c.Request.Context(), productID, user.ID)
 	if err == sql.ErrNoRows {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found or not owned by you"})
 		return
 	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch product"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete product"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Product deleted successfully"})
+}
+
+// maxBulkProducts caps the number of products accepted by one bulk archive or delete
+const maxBulkProducts = 200
+
+// Bulk archive and delete rejection codes
+const (
+	bulkRejectNotOwned = "not_found"
+	bulkRejectOrdered  = "ordered"
+)
+
+// BulkProductResult reports how one product of a bulk archive or delete was handled
+type BulkProductResult struct {
+	ProductID string `json:"product_id"`
+	Status    string `json:"status"` // archived, deleted, rejected
+	Code      string `json:"code,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// bindBulkProductIDs reads the {"ids": [...]} body of a bulk product request, dropping
+// repeated IDs, and responds with an error and returns false if it is invalid
+func bindBulkProductIDs(c *gin.Context) ([]string, bool) {
+	var request struct {
+		IDs []string `json:"ids" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if len(request.IDs) > maxBulkProducts {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At most " + strconv.Itoa(maxBulkProducts) + " products can be changed at once"})
+		return nil, false
+	}
+
+	ids := make([]string, 0, len(request.IDs))
+	seen := map[string]bool{}
+	for _, id := range request.IDs {
+		id = utils.SanitizeInput(id, utils.SanitizationOptions{
+			TrimWhitespace: true,
+			EscapeHTML:     true,
+			RemoveNewlines: true,
+			MaxLength:      100,
+		})
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids, true
+}
+
+// bulkProductResults reports each of ids as done with status if it is in done, and rejected
+// otherwise: as ordered if it is in ordered, as not found if in neither
+func bulkProductResults(ids, done, ordered []string, status string) []BulkProductResult {
+	inDone := make(map[string]bool, len(done))
+	for _, id := range done {
+		inDone[id] = true
+	}
+	inOrdered := make(map[string]bool, len(ordered))
+	for _, id := range ordered {
+		inOrdered[id] = true
+	}
+
+	results := make([]BulkProductResult, 0, len(ids))
+	for _, id := range ids {
+		result := BulkProductResult{ProductID: id, Status: status}
+		switch {
+		case inDone[id]:
+		case inOrdered[id]:
+			result.Status, result.Code, result.Reason = "rejected", bulkRejectOrdered, "Product has been ordered; archive it instead"
+		default:
+			result.Status, result.Code, result.Reason = "rejected", bulkRejectNotOwned, "Product not found or not owned by you"
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// BulkArchiveProducts archives several of the seller's products at once ({"ids": [...]}),
+// reporting each product's outcome. IDs of other sellers' products are rejected as not
+// found. The archive is written to the audit log.
+func BulkArchiveProducts(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Delete the product
-	rowsAffected, err := database.DeleteProduct(productID, user.ID)
+	ids, ok := bindBulkProductIDs(c)
+	if !ok {
+		return
+	}
+
+	archived, err := database.ArchiveProducts(c.Request.Context(), user.ID, ids, &models.AuditEntry{
+		ActorID:    &user.ID,
+		ActorEmail: user.Email,
+		Action:     models.AuditProductsArchived,
+		IPAddress:  c.ClientIP(),
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete product"})
+		log.Printf("Failed to archive products of seller %s: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to archive products"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results":  bulkProductResults(ids, archived, nil, "archived"),
+		"archived": len(archived),
+		"rejected": len(ids) - len(archived),
+	})
+}
+
+// BulkDeleteProducts deletes several of the seller's products at once ({"ids": [...]}),
+// reporting each product's outcome. Products that have been ordered are kept and rejected
+// with the code "ordered", so they can be archived instead. The deletion is written to the
+// audit log.
+func BulkDeleteProducts(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found or already deleted"})
+	ids, ok := bindBulkProductIDs(c)
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Product deleted successfully"})
+	deleted, ordered, err := database.DeleteProducts(c.Request.Context(), user.ID, ids, &models.AuditEntry{
+		ActorID:    &user.ID,
+		ActorEmail: user.Email,
+		Action:     models.AuditProductsDeleted,
+		IPAddress:  c.ClientIP(),
+	})
+	if err != nil {
+		log.Printf("Failed to delete products of seller %s: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete products"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results":  bulkProductResults(ids, deleted, ordered, "deleted"),
+		"deleted":  len(deleted),
+		"rejected": len(ids) - len(deleted),
+	})
 }
@@ -0,0 +1,71 @@
+package handlers_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"secure-backend/handlers"
+	"secure-backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// benchProducts builds a product page shaped like a real listing response
+func benchProducts(n int) []models.Product {
+	width, height, depth, weight := 30.0, 20.0, 10.0, 1.25
+	category := "0b6f3c1e-8a2d-4f5b-9c7e-1d2a3b4c5d6e"
+	created := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	products := make([]models.Product, n)
+	for i := range products {
+		products[i] = models.Product{
+			ID:          fmt.Sprintf("00000000-0000-4000-8000-%012d", i),
+			Name:        fmt.Sprintf("Handmade ceramic mug #%d", i),
+			Description: "Wheel-thrown stoneware mug with a speckled glaze. Dishwasher and microwave safe; holds about 350ml.",
+			Price:       2499,
+			Image:       "https://cdn.example.com/products/mug.jpg",
+			ImageAlt:    "A speckled cream mug on a wooden table",
+			Stock:       12,
+			Status:      "published",
+			SellerID:    "6f1c2b9e-3d4a-4c8e-9b1f-2a7d5e8c0f13",
+			CategoryID:  &category,
+			Tags:        []string{"ceramics", "handmade", "kitchen"},
+			WidthCm:     &width,
+			HeightCm:    &height,
+			DepthCm:     &depth,
+			WeightKg:    &weight,
+			CreatedAt:   created,
+			UpdatedAt:   created,
+		}
+	}
+	return products
+}
+
+// benchmarkProductList measures rendering a product page the way GetProducts responds
+func benchmarkProductList(b *testing.B, n int) {
+	gin.SetMode(gin.TestMode)
+	products := benchProducts(n)
+
+	r := gin.New()
+	r.GET("/products", func(c *gin.Context) {
+		c.Header(handlers.TotalCountHeader, "1000")
+		c.JSON(http.StatusOK, products)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/products", nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			b.Fatalf("got status %d", w.Code)
+		}
+	}
+}
+
+func BenchmarkProductListJSON20(b *testing.B)  { benchmarkProductList(b, 20) }
+func BenchmarkProductListJSON100(b *testing.B) { benchmarkProductList(b, 100) }
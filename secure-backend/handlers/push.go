@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/push"
+	"secure-backend/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxDeviceTokenLength bounds push tokens (APNs tokens are 64 hex chars, FCM tokens ~160 chars)
+const maxDeviceTokenLength = 4096
+
+// RegisterDevice registers a device push token for the authenticated user
+func RegisterDevice(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		Token    string `json:"token" binding:"required"`
+		Platform string `json:"platform" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token := strings.TrimSpace(request.Token)
+	if token == "" || len(token) > maxDeviceTokenLength || strings.ContainsAny(token, " \t\r\n") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device token"})
+		return
+	}
+
+	platform := strings.ToLower(strings.TrimSpace(request.Platform))
+	if !push.IsValidPlatform(platform) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid platform. Must be ios or android"})
+		return
+	}
+
+	device, err := database.RegisterDeviceToken(c.Request.Context(), user.ID, token, platform)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register device"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, device)
+}
+
+// UnregisterDevice removes one of the authenticated user's device push tokens
+func UnregisterDevice(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	token := c.Param("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Device token is required"})
+		return
+	}
+
+	rowsAffected, err := database.DeleteDeviceToken(c.Request.Context(), user.ID, token)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unregister device"})
+		return
+	}
+
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Device unregistered successfully"})
+}
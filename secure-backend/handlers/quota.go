@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http"
+	"secure-backend/middleware"
+	"secure-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetQuota returns the caller's quota tier with daily and monthly usage
+func GetQuota(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	status, err := middleware.UserQuota(c.Request.Context(), user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load quota"})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
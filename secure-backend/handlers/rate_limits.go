@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"net/http"
+	"secure-backend/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRateLimits lists the rate limits applied to each group of routes, the cost of the
+// expensive routes and the request quota of each tier so clients can pace themselves.
+// Responses to limited routes also carry X-RateLimit-* and X-Quota-* headers.
+func GetRateLimits(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"rate_limits": middleware.RateLimitPolicies(),
+		"costs":       middleware.RequestCosts,
+		"quotas":      middleware.QuotaTiers,
+	})
+}
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxRecommendationsPerProduct caps how many cross-sells and upsells a product can carry
+const maxRecommendationsPerProduct = 10
+
+// GetProductRecommendations lists a product's cross-sells and upsells. The owning seller
+// and admins also see recommended products that aren't published.
+func GetProductRecommendations(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized access"})
+		return
+	}
+
+	product, err := database.GetProductByID(c.Request.Context(), c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch product"})
+		return
+	}
+	canManage := product.SellerID == user.ID || user.Role == "admin"
+	if product.Status != "published" && !canManage {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	recommendations, err := database.GetProductRecommendations(c.Request.Context(), []string{product.ID}, !canManage)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load recommendations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"product_id": product.ID, "recommendations": recommendations})
+}
+
+// SetProductRecommendations replaces a product's cross-sells and upsells (its seller or admins).
+// The order of the list is the display order.
+func SetProductRecommendations(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		Recommendations []struct {
+			ProductID string `json:"product_id" binding:"required"`
+			Kind      string `json:"kind" binding:"required,oneof=cross_sell upsell"`
+			Label     string `json:"label"`
+		} `json:"recommendations" binding:"dive"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(request.Recommendations) > maxRecommendationsPerProduct {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A product can have at most 10 recommendations"})
+		return
+	}
+
+	product, err := database.GetProductByID(c.Request.Context(), c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch product"})
+		return
+	}
+	if product.SellerID != user.ID && user.Role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only manage recommendations of your own products"})
+		return
+	}
+
+	recommendations := make([]models.ProductRecommendation, 0, len(request.Recommendations))
+	seen := map[string]bool{}
+	for _, r := range request.Recommendations {
+		if r.ProductID == product.ID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A product can't recommend itself"})
+			return
+		}
+		if seen[r.ProductID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Each product can be recommended only once"})
+			return
+		}
+		seen[r.ProductID] = true
+
+		recommendations = append(recommendations, models.ProductRecommendation{
+			RecommendedProductID: r.ProductID,
+			Kind:                 r.Kind,
+			Label: utils.SanitizeInput(r.Label, utils.SanitizationOptions{
+				TrimWhitespace: true,
+				EscapeHTML:     true,
+				RemoveNewlines: true,
+				MaxLength:      100,
+			}),
+		})
+	}
+
+	err = database.SetProductRecommendations(c.Request.Context(), product.ID, recommendations)
+	if errors.Is(err, database.ErrUnknownProduct) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save recommendations"})
+		return
+	}
+
+	saved, err := database.GetProductRecommendations(c.Request.Context(), []string{product.ID}, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load recommendations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"product_id": product.ID, "recommendations": saved})
+}
+
+// GetCartRecommendations returns the cross-sells and upsells of the products in the user's
+// cart, for display at cart and checkout. Products already in the cart are left out and
+// each recommended product is listed once.
+func GetCartRecommendations(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	items, err := database.GetCartItems(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve cart"})
+		return
+	}
+
+	inCart := make(map[string]bool, len(items))
+	productIDs := make([]string, 0, len(items))
+	for _, item := range items {
+		inCart[item.ProductID] = true
+		productIDs = append(productIDs, item.ProductID)
+	}
+
+	recommendations := []models.ProductRecommendation{}
+	if len(productIDs) > 0 {
+		all, err := database.GetProductRecommendations(c.Request.Context(), productIDs, true)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load recommendations"})
+			return
+		}
+		for _, r := range all {
+			if inCart[r.RecommendedProductID] || r.Stock <= 0 {
+				continue
+			}
+			inCart[r.RecommendedProductID] = true // list each recommended product once
+			recommendations = append(recommendations, r)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recommendations": recommendations})
+}
+
+// RecordRecommendationClick tracks a click on a recommendation for conversion analytics.
+// Attaches are tracked when AddToCart is called with recommended_from.
+func RecordRecommendationClick(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		ProductID            string `json:"product_id" binding:"required"`
+		RecommendedProductID string `json:"recommended_product_id" binding:"required"`
+		Placement            string `json:"placement" binding:"required,oneof=product cart checkout"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	recorded, err := database.RecordRecommendationEvent(c.Request.Context(), &models.RecommendationEvent{
+		ProductID:            request.ProductID,
+		RecommendedProductID: request.RecommendedProductID,
+		UserID:               &user.ID,
+		Action:               models.RecommendationClick,
+		Placement:            request.Placement,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record click"})
+		return
+	}
+	if !recorded {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recommendation not found"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// recordRecommendationAttach counts a recommended product added to the cart. Like other
+// analytics, failures are logged and never fail the request.
+func recordRecommendationAttach(ctx context.Context, userID, productID, recommendedProductID, placement string) {
+	if placement == "" {
+		placement = models.PlacementProduct
+	}
+
+	_, err := database.RecordRecommendationEvent(ctx, &models.RecommendationEvent{
+		ProductID:            productID,
+		RecommendedProductID: recommendedProductID,
+		UserID:               &userID,
+		Action:               models.RecommendationAttach,
+		Placement:            placement,
+	})
+	if err != nil {
+		log.Printf("Failed to record recommendation attach: %v", err)
+	}
+}
+
+// GetRecommendationReport returns clicks, attaches and conversion per recommendation
+// (?from=&to=, YYYY-MM-DD). Sellers see their own products; admins see every product.
+func GetRecommendationReport(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	from, to, err := parseDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sellerID := ""
+	if user.Role == "seller" {
+		sellerID = user.ID
+	}
+
+	stats, err := database.GetRecommendationStats(c.Request.Context(), sellerID, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load recommendation report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":            from,
+		"to":              to,
+		"recommendations": stats,
+	})
+}
@@ -0,0 +1,163 @@
+//go:build e2e
+
+// Cross-sell and upsell tests against a real PostgreSQL database (with database/schema.sql applied):
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run TestProductRecommendations ./handlers
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"secure-backend/database"
+	"secure-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductRecommendations(t *testing.T) {
+	users := createTestUsers(t, "recommend", "seller", "seller", "buyer")
+	seller, rival, buyer := users[0], users[1], users[2]
+	ctx := context.Background()
+
+	product := func(owner *models.AuthUser, name string, stock int, status string) string {
+		var id string
+		require.NoError(t, database.DB.GetContext(ctx, &id, `
+			INSERT INTO products (name, price, stock, status, seller_id) VALUES ($1, 3, $2, $3, $4) RETURNING id
+		`, name, stock, status, owner.ID))
+		return id
+	}
+	camera := product(seller, "Camera", 5, "published")
+	battery := product(seller, "Battery", 20, "published")
+	bag := product(seller, "Camera bag", 0, "published")
+	lens := product(seller, "Pro lens", 2, "draft")
+	tripod := product(rival, "Tripod", 4, "published")
+
+	type recommendationList struct {
+		Recommendations []models.ProductRecommendation `json:"recommendations"`
+	}
+	recommended := func(list recommendationList) []string {
+		ids := make([]string, len(list.Recommendations))
+		for i, r := range list.Recommendations {
+			ids[i] = r.RecommendedProductID
+		}
+		return ids
+	}
+	set := func(user *models.AuthUser, productID, body string) (recommendationList, int) {
+		t.Helper()
+		w := serve(SetProductRecommendations, user, http.MethodPut, "/api/products/"+productID+"/recommendations", body, gin.Param{Key: "id", Value: productID})
+		var list recommendationList
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		}
+		return list, w.Code
+	}
+	get := func(user *models.AuthUser, productID string) recommendationList {
+		t.Helper()
+		w := serve(GetProductRecommendations, user, http.MethodGet, "/api/products/"+productID+"/recommendations", "", gin.Param{Key: "id", Value: productID})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var list recommendationList
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		return list
+	}
+	cartRecommendations := func() []string {
+		t.Helper()
+		w := serve(GetCartRecommendations, buyer, http.MethodGet, "/api/cart/recommendations", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var list recommendationList
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		return recommended(list)
+	}
+	click := func(productID, recommendedID, placement string) int {
+		body := fmt.Sprintf(`{"product_id":%q,"recommended_product_id":%q,"placement":%q}`, productID, recommendedID, placement)
+		return serve(RecordRecommendationClick, buyer, http.MethodPost, "/api/recommendations/clicks", body).Code
+	}
+
+	// Only the seller sets a product's recommendations, in display order
+	list, code := set(seller, camera, fmt.Sprintf(`{"recommendations":[
+		{"product_id":%q,"kind":"upsell"},
+		{"product_id":%q,"kind":"cross_sell","label":"Add <b>batteries</b>"},
+		{"product_id":%q,"kind":"cross_sell"}
+	]}`, lens, battery, bag))
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{lens, battery, bag}, recommended(list))
+	require.Len(t, list.Recommendations, 3)
+	assert.Equal(t, models.RecommendationUpsell, list.Recommendations[0].Kind)
+	assert.Equal(t, "Add &lt;b&gt;batteries&lt;/b&gt;", list.Recommendations[1].Label)
+	assert.Equal(t, "Battery", list.Recommendations[1].Name)
+	assert.Equal(t, 1, list.Recommendations[1].Position)
+	_, code = set(rival, camera, `{"recommendations":[]}`)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	// Invalid lists are refused and leave the recommendations as they were
+	tooMany := make([]string, maxRecommendationsPerProduct+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf(`{"product_id":%q,"kind":"cross_sell"}`, uuid.NewString())
+	}
+	for name, body := range map[string]string{
+		"itself":       fmt.Sprintf(`{"recommendations":[{"product_id":%q,"kind":"upsell"}]}`, camera),
+		"twice":        fmt.Sprintf(`{"recommendations":[{"product_id":%q,"kind":"upsell"},{"product_id":%q,"kind":"cross_sell"}]}`, battery, battery),
+		"unknown":      fmt.Sprintf(`{"recommendations":[{"product_id":%q,"kind":"upsell"}]}`, uuid.NewString()),
+		"bad kind":     fmt.Sprintf(`{"recommendations":[{"product_id":%q,"kind":"bundle"}]}`, battery),
+		"too many":     `{"recommendations":[` + strings.Join(tooMany, ",") + `]}`,
+		"missing id":   `{"recommendations":[{"kind":"upsell"}]}`,
+		"not an array": `{"recommendations":{}}`,
+	} {
+		_, code := set(seller, camera, body)
+		assert.Equal(t, http.StatusBadRequest, code, name)
+	}
+	assert.Equal(t, []string{lens, battery, bag}, recommended(get(seller, camera)))
+
+	// Buyers only see published recommended products
+	assert.Equal(t, []string{battery, bag}, recommended(get(buyer, camera)))
+
+	// The cart leaves out products that are in it or out of stock
+	add := func(productID, body string) {
+		t.Helper()
+		w := serve(AddToCart, buyer, http.MethodPost, "/api/cart", fmt.Sprintf(`{"product_id":%q,"quantity":1%s}`, productID, body))
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	}
+	assert.Empty(t, cartRecommendations())
+	add(camera, "")
+	assert.Equal(t, []string{battery}, cartRecommendations())
+
+	// Clicks and attaches are only counted for configured recommendations
+	assert.Equal(t, http.StatusNoContent, click(camera, battery, models.PlacementCart))
+	assert.Equal(t, http.StatusNoContent, click(camera, battery, models.PlacementProduct))
+	assert.Equal(t, http.StatusNotFound, click(camera, tripod, models.PlacementCart))
+	assert.Equal(t, http.StatusBadRequest, click(camera, battery, "email"))
+	add(battery, fmt.Sprintf(`,"recommended_from":%q,"placement":"cart"`, camera))
+	assert.Empty(t, cartRecommendations())
+
+	// Sellers get conversion for their own products only
+	report := func(user *models.AuthUser) map[string]models.RecommendationStats {
+		t.Helper()
+		w := serve(GetRecommendationReport, user, http.MethodGet, "/api/seller/reports/recommendations", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response struct {
+			Recommendations []models.RecommendationStats `json:"recommendations"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		stats := make(map[string]models.RecommendationStats)
+		for _, s := range response.Recommendations {
+			if s.ProductID == camera {
+				stats[s.RecommendedProductID] = s
+			}
+		}
+		return stats
+	}
+	stats := report(seller)
+	require.Contains(t, stats, battery)
+	assert.Equal(t, 2, stats[battery].Clicks)
+	assert.Equal(t, 1, stats[battery].Attaches)
+	assert.InDelta(t, 0.5, stats[battery].ConversionRate, 1e-9)
+	assert.NotContains(t, stats, bag)
+	assert.Empty(t, report(rival))
+}
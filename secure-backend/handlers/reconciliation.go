@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPayoutReconciliations lists checked provider payouts for admins, newest first
+// (?status=blocked|reconciled|resolved, default blocked; paginated)
+func GetPayoutReconciliations(c *gin.Context) {
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := c.DefaultQuery("status", models.ReconciliationBlocked)
+	switch status {
+	case models.ReconciliationBlocked, models.ReconciliationReconciled, models.ReconciliationResolved:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be blocked, reconciled or resolved"})
+		return
+	}
+
+	payouts, total, err := database.GetPayoutReconciliations(c.Request.Context(), status, page.Limit, page.Offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load payouts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"payouts": payouts,
+		"total":   total,
+		"limit":   page.Limit,
+		"offset":  page.Offset,
+	})
+}
+
+// GetPayoutReconciliation returns a checked payout with the mismatches of its latest check (admins only)
+func GetPayoutReconciliation(c *gin.Context) {
+	payout, err := database.GetPayoutReconciliation(c.Request.Context(), sanitizedIDParam(c))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payout not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load payout"})
+		return
+	}
+
+	c.JSON(http.StatusOK, payout)
+}
+
+// ResolvePayoutReconciliation releases a blocked payout once an admin has accounted for its
+// mismatches, lifting the holds on its orders' seller payouts. The note is required and the
+// decision is recorded in the admin audit log.
+func ResolvePayoutReconciliation(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		Note string `json:"note" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	note := utils.SanitizeInput(request.Note, utils.DefaultTextOptions)
+
+	id := sanitizedIDParam(c)
+	payout, err := database.ResolvePayoutReconciliation(c.Request.Context(), id, note, &models.AuditEntry{
+		ActorID:    &user.ID,
+		ActorEmail: user.Email,
+		Action:     models.AuditPayoutResolved,
+		Detail:     fmt.Sprintf("released blocked payout %s: %s", id, note),
+		IPAddress:  c.ClientIP(),
+	})
+	switch {
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payout not found"})
+		return
+	case errors.Is(err, database.ErrReconciliationNotBlocked):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve payout"})
+		return
+	}
+
+	c.JSON(http.StatusOK, payout)
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/locale"
+	"secure-backend/money"
+	"secure-backend/payments"
+	"secure-backend/services"
+	"secure-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateRefund issues a full or partial refund for an order.
+// Admins may refund specific items, a plain amount, or (with neither) everything left;
+// sellers may only refund items of their own products. Restock puts refunded items back in stock.
+func CreateRefund(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		Items []struct {
+			OrderItemID string `json:"order_item_id" binding:"required"`
+			Quantity    int    `json:"quantity" binding:"required,min=1"`
+		} `json:"items" binding:"dive"`
+		Amount  money.Amount `json:"amount" binding:"min=0"`
+		Reason  string       `json:"reason"`
+		Restock bool         `json:"restock"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(request.Items) > 0 && request.Amount > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Specify either items or an amount, not both"})
+		return
+	}
+
+	refundReq := database.RefundRequest{
+		Amount:  request.Amount,
+		Reason:  utils.SanitizeInput(request.Reason, utils.DefaultTextOptions),
+		Restock: request.Restock,
+	}
+	for _, item := range request.Items {
+		refundReq.Items = append(refundReq.Items, database.RefundItemRequest{
+			OrderItemID: item.OrderItemID,
+			Quantity:    item.Quantity,
+		})
+	}
+
+	if user.Role == "seller" {
+		if len(refundReq.Items) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Sellers must specify the items to refund"})
+			return
+		}
+		refundReq.SellerID = user.ID
+	}
+
+	order, err := database.GetOrderByID(c.Request.Context(), c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order"})
+		return
+	}
+
+	refund, err := payments.RefundOrder(c.Request.Context(), order, refundReq, user)
+	if err != nil {
+		respondRefundError(c, err)
+		return
+	}
+
+	refund.StatusLabel = locale.Label(requestLanguage(c), locale.RefundStatus, refund.Status)
+	c.JSON(http.StatusCreated, gin.H{"message": "Refund issued", "refund": refund})
+}
+
+// GetOrderRefunds lists the refunds of an order to its buyer, admins, and sellers with items in it
+func GetOrderRefunds(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, err := database.GetOrderByID(c.Request.Context(), c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order"})
+		return
+	}
+
+	allowed := order.UserID == user.ID || user.Role == "admin"
+	if !allowed && user.Role == "seller" {
+		allowed, err = database.OrderHasSellerItems(c.Request.Context(), order.ID, user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order"})
+			return
+		}
+	}
+	if !allowed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	refunds, err := database.GetRefundsByOrder(c.Request.Context(), order.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load refunds"})
+		return
+	}
+
+	locale.LabelRefunds(requestLanguage(c), refunds)
+	c.JSON(http.StatusOK, gin.H{"refunds": refunds})
+}
+
+// respondRefundError maps refund errors to HTTP responses
+func respondRefundError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, database.ErrInvalidRefundItem), errors.Is(err, database.ErrNothingToRefund):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrRefundExceedsPaid):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, payments.ErrOrderNotRefundable):
+		c.JSON(http.StatusConflict, gin.H{"error": "Order cannot be refunded in its current status"})
+	case errors.Is(err, payments.ErrAlreadyRestocked):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The order was cancelled and its items are already back in stock"})
+	case errors.Is(err, payments.ErrPaymentNotFound):
+		c.JSON(http.StatusConflict, gin.H{"error": "Order has no captured payment to refund"})
+	case errors.Is(err, services.ErrPeriodClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": "The accounting period of this order is closed; contact support"})
+	case errors.Is(err, payments.ErrOrderDisputed):
+		c.JSON(http.StatusConflict, gin.H{"error": "Order has an open payment dispute; it is refunded through the dispute"})
+	default:
+		respondPaymentError(c, err)
+	}
+}
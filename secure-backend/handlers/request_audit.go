@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRequestAudits lists request audit entries (newest first) recorded over ?from=&to=
+// (default the last 30 days), optionally of one ?route= pattern and ?user_id=. Only admins
+// can view them. The listing itself isn't audited.
+func GetRequestAudits(c *gin.Context) {
+	middleware.SkipRequestAudit(c)
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	from, to, err := parseDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entries, total, err := database.GetRequestAudits(c.Request.Context(), c.Query("route"), c.Query("user_id"), from, to, page.Limit, page.Offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load request audit log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"total":   total,
+		"limit":   page.Limit,
+		"offset":  page.Offset,
+	})
+}
@@ -0,0 +1,145 @@
+//go:build e2e
+
+// Save-for-later tests against a real PostgreSQL database (with database/schema.sql applied):
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run TestSaveForLater ./handlers
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"secure-backend/database"
+	"secure-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveForLater(t *testing.T) {
+	users := createTestUsers(t, "saved", "seller", "buyer", "buyer")
+	seller, buyer, other := users[0], users[1], users[2]
+	ctx := context.Background()
+
+	product := func(name string, stock int) string {
+		var id string
+		require.NoError(t, database.DB.GetContext(ctx, &id, `
+			INSERT INTO products (name, price, stock, status, seller_id, max_order_quantity)
+			VALUES ($1, 4, $2, 'published', $3, 5) RETURNING id
+		`, name, stock, seller.ID))
+		return id
+	}
+	mug, lamp := product("Saved mug", 10), product("Saved lamp", 3)
+
+	addToCart := func(productID string, quantity int) *models.CartItem {
+		t.Helper()
+		item, err := database.AddToCart(ctx, buyer.ID, productID, quantity)
+		require.NoError(t, err)
+		return item
+	}
+	save := func(user *models.AuthUser, cartItemID string) (*models.SavedItem, int) {
+		t.Helper()
+		w := serve(SaveCartItemForLater, user, http.MethodPut, "/api/cart/"+cartItemID+"/save", "", gin.Param{Key: "id", Value: cartItemID})
+		if w.Code != http.StatusOK {
+			return nil, w.Code
+		}
+		var saved models.SavedItem
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &saved))
+		return &saved, w.Code
+	}
+	list := func(user *models.AuthUser) map[string]models.SavedItemWithProduct {
+		t.Helper()
+		w := serve(GetSavedItems, user, http.MethodGet, "/api/cart/saved", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response struct {
+			Items []models.SavedItemWithProduct `json:"items"`
+			Count int                           `json:"count"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Equal(t, len(response.Items), response.Count)
+		items := make(map[string]models.SavedItemWithProduct)
+		for _, item := range response.Items {
+			items[item.ProductID] = item
+		}
+		return items
+	}
+	move := func(user *models.AuthUser, savedItemID string) *httptest.ResponseRecorder {
+		return serve(MoveSavedItemToCart, user, http.MethodPost, "/api/cart/saved/"+savedItemID+"/move", "", gin.Param{Key: "id", Value: savedItemID})
+	}
+
+	// Saving takes the item out of the cart and leaves a tombstone for delta sync
+	version, err := database.GetCartVersion(ctx, buyer.ID)
+	require.NoError(t, err)
+	cartItem := addToCart(mug, 2)
+	saved, code := save(buyer, cartItem.ID)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, mug, saved.ProductID)
+	assert.Equal(t, 2, saved.Quantity)
+	_, err = database.GetCartItemByProduct(ctx, buyer.ID, mug)
+	assert.Error(t, err, "saved item still in the cart")
+	removals, err := database.GetCartRemovalsSince(ctx, buyer.ID, version)
+	require.NoError(t, err)
+	require.Len(t, removals, 1)
+	assert.Equal(t, cartItem.ID, removals[0].ID)
+
+	// Saving a product again adds to the saved quantity; others' cart items can't be saved
+	resaved, code := save(buyer, addToCart(mug, 3).ID)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, saved.ID, resaved.ID)
+	assert.Equal(t, 5, resaved.Quantity)
+	lampItem := addToCart(lamp, 2)
+	_, code = save(other, lampItem.ID)
+	assert.Equal(t, http.StatusNotFound, code)
+	savedLamp, code := save(buyer, lampItem.ID)
+	require.Equal(t, http.StatusOK, code)
+
+	// The list carries product details and flags what can no longer be bought as saved
+	_, err = database.DB.ExecContext(ctx, `UPDATE products SET stock = 1 WHERE id = $1`, lamp)
+	require.NoError(t, err)
+	items := list(buyer)
+	require.Len(t, items, 2)
+	assert.Equal(t, "Saved mug", items[mug].Product.Name)
+	assert.Equal(t, models.CartItemAvailable, items[mug].Availability)
+	assert.Equal(t, 5, items[mug].AvailableQuantity)
+	assert.Equal(t, models.CartItemQuantityReduced, items[lamp].Availability)
+	assert.Equal(t, 1, items[lamp].AvailableQuantity)
+	assert.Empty(t, list(other))
+
+	// Moving back respects the product's order limits and its status
+	addToCart(mug, 1)
+	w := move(buyer, saved.ID)
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), models.OrderRuleAboveMaxQuantity)
+	_, err = database.DB.ExecContext(ctx, `UPDATE products SET status = 'draft' WHERE id = $1`, lamp)
+	require.NoError(t, err)
+	w = move(buyer, savedLamp.ID)
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	assert.Len(t, list(buyer), 2, "refused moves keep the saved items")
+
+	// Once it fits, the whole saved quantity goes back into the cart
+	inCart, err := database.GetCartItemByProduct(ctx, buyer.ID, mug)
+	require.NoError(t, err)
+	require.NoError(t, database.RemoveFromCart(ctx, inCart.ID, buyer.ID))
+	assert.Equal(t, http.StatusNotFound, move(other, saved.ID).Code)
+	w = move(buyer, saved.ID)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var moved models.CartItem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &moved))
+	assert.Equal(t, mug, moved.ProductID)
+	assert.Equal(t, 5, moved.Quantity)
+	assert.NotContains(t, list(buyer), mug)
+	assert.Equal(t, http.StatusNotFound, move(buyer, saved.ID).Code)
+
+	// Only the owner can delete a saved item
+	remove := func(user *models.AuthUser, savedItemID string) int {
+		return serve(RemoveSavedItem, user, http.MethodDelete, "/api/cart/saved/"+savedItemID, "", gin.Param{Key: "id", Value: savedItemID}).Code
+	}
+	assert.Equal(t, http.StatusNotFound, remove(other, savedLamp.ID))
+	assert.Equal(t, http.StatusOK, remove(buyer, savedLamp.ID))
+	assert.Equal(t, http.StatusNotFound, remove(buyer, savedLamp.ID))
+	assert.Empty(t, list(buyer))
+}
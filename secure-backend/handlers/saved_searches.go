@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/money"
+	"secure-backend/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSavedSearchSlugLength bounds the category and tag slugs of a saved search
+const maxSavedSearchSlugLength = 100
+
+// savedSearchRequest is a saved search as sent by the client. The query and filters are
+// those of product search (query) and listing (category, tag); at least one must be set.
+type savedSearchRequest struct {
+	Name     string        `json:"name" binding:"required"`
+	Query    string        `json:"query"`
+	Category string        `json:"category"`
+	Tag      string        `json:"tag"`
+	MinPrice *money.Amount `json:"min_price" binding:"omitempty,gte=0"`
+	MaxPrice *money.Amount `json:"max_price" binding:"omitempty,gte=0"`
+	Alert    bool          `json:"alert"`
+}
+
+// bindSavedSearch reads and sanitizes a saved search of the user from the request body,
+// answering 400 and returning false when it is invalid
+func bindSavedSearch(c *gin.Context, userID string) (*models.SavedSearch, bool) {
+	var request savedSearchRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	search := &models.SavedSearch{
+		UserID: userID,
+		Name: utils.SanitizeInput(request.Name, utils.SanitizationOptions{
+			TrimWhitespace: true,
+			EscapeHTML:     true,
+			RemoveNewlines: true,
+			MaxLength:      100,
+			PreserveSpaces: true,
+		}),
+		Query:    utils.SanitizeSearchQuery(request.Query),
+		Category: strings.ToLower(strings.TrimSpace(request.Category)),
+		Tag:      strings.ToLower(strings.TrimSpace(request.Tag)),
+		MinPrice: request.MinPrice,
+		MaxPrice: request.MaxPrice,
+		Alert:    request.Alert,
+	}
+	switch {
+	case search.Name == "":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+		return nil, false
+	case len(search.Category) > maxSavedSearchSlugLength || len(search.Tag) > maxSavedSearchSlugLength:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Category and tag must be slugs of at most 100 characters"})
+		return nil, false
+	case search.Query == "" && search.Category == "" && search.Tag == "" && search.MinPrice == nil && search.MaxPrice == nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A query, category, tag or price range is required"})
+		return nil, false
+	case search.MinPrice != nil && search.MaxPrice != nil && *search.MaxPrice < *search.MinPrice:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_price must not be below min_price"})
+		return nil, false
+	}
+	return search, true
+}
+
+// GetSavedSearches lists the buyer's saved searches, oldest first
+func GetSavedSearches(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	searches, err := database.GetSavedSearches(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load saved searches"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"searches": searches,
+		"count":    len(searches),
+		"limit":    models.MaxSavedSearches,
+	})
+}
+
+// CreateSavedSearch saves a search query and filters for the buyer. With alert set, the
+// buyer is notified of products published (or changed) afterwards that match it.
+func CreateSavedSearch(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	search, ok := bindSavedSearch(c, user.ID)
+	if !ok {
+		return
+	}
+
+	err = database.CreateSavedSearch(c.Request.Context(), search)
+	if errors.Is(err, database.ErrSavedSearchLimit) {
+		c.JSON(http.StatusConflict, gin.H{"error": "You can save up to 25 searches; delete one first"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save search"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, search)
+}
+
+// UpdateSavedSearch replaces the name, query, filters and alert setting of one of the
+// buyer's saved searches
+func UpdateSavedSearch(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	search, ok := bindSavedSearch(c, user.ID)
+	if !ok {
+		return
+	}
+	search.ID = sanitizedIDParam(c)
+
+	err = database.UpdateSavedSearch(c.Request.Context(), search)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Saved search not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update saved search"})
+		return
+	}
+
+	c.JSON(http.StatusOK, search)
+}
+
+// DeleteSavedSearch deletes one of the buyer's saved searches and its alerts
+func DeleteSavedSearch(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = database.DeleteSavedSearch(c.Request.Context(), sanitizedIDParam(c), user.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Saved search not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete saved search"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Saved search deleted successfully"})
+}
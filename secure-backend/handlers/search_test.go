@@ -0,0 +1,110 @@
+//go:build e2e
+
+// Product search tests against a real PostgreSQL database (with database/schema.sql applied):
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run TestSearchProducts ./handlers
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"secure-backend/database"
+	"secure-backend/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchProducts(t *testing.T) {
+	users := createTestUsers(t, "search", "seller", "seller", "buyer", "admin")
+	seller, away, buyer, admin := users[0], users[1], users[2], users[3]
+	ctx := context.Background()
+
+	// A made-up word no other test's products contain, in letters only so it is one lexeme
+	word := "zorb" + strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return 'g' + (r - '0')
+		}
+		return r
+	}, strings.ReplaceAll(uuid.NewString()[:8], "-", ""))
+
+	product := func(owner *models.AuthUser, name, description, status string) string {
+		var id string
+		require.NoError(t, database.DB.GetContext(ctx, &id, `
+			INSERT INTO products (name, description, price, stock, status, seller_id) VALUES ($1, $2, 5, 1, $3, $4) RETURNING id
+		`, name, description, status, owner.ID))
+		return id
+	}
+	inName := product(seller, "Desk lamp "+word, "A bright lamp for reading.", "published")
+	inDescription := product(seller, "Lamp shade",
+		"A linen shade that fits the "+word+" lamp and most other lamps with a standard ring fitting.", "published")
+	draft := product(seller, "Draft "+word, "", "draft")
+	product(seller, "Unrelated lamp", "Nothing to see here.", "published")
+	onVacation := product(away, "Holiday "+word, "", "published")
+	_, err := database.DB.ExecContext(ctx, `
+		UPDATE users SET vacation_hide_listings = true, vacation_starts_at = now() - interval '1 hour' WHERE id = $1
+	`, away.ID)
+	require.NoError(t, err)
+
+	type searchResponse struct {
+		Query   string                       `json:"query"`
+		Results []models.ProductSearchResult `json:"results"`
+		Total   int                          `json:"total"`
+	}
+	search := func(user *models.AuthUser, params string) searchResponse {
+		t.Helper()
+		w := serve(SearchProducts, user, http.MethodGet, "/api/products/search?"+params, "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response searchResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+	ids := func(results []models.ProductSearchResult) []string {
+		ids := make([]string, len(results))
+		for i, result := range results {
+			ids[i] = result.ID
+		}
+		return ids
+	}
+	q := "q=" + url.QueryEscape(word)
+
+	// Buyers find published products of sellers who aren't hiding them, name matches first
+	response := search(buyer, q)
+	assert.Equal(t, word, response.Query)
+	assert.Equal(t, 2, response.Total)
+	assert.Equal(t, []string{inName, inDescription}, ids(response.Results))
+	assert.Greater(t, response.Results[0].Rank, response.Results[1].Rank, "name matches outrank description matches")
+
+	// Matches are highlighted in the name and in a snippet of the description
+	assert.Equal(t, "Desk lamp <mark>"+word+"</mark>", response.Results[0].NameHighlight)
+	assert.Contains(t, response.Results[1].Snippet, "<mark>"+word+"</mark>")
+	assert.NotContains(t, response.Results[1].NameHighlight, "<mark>")
+
+	// Pages follow the ranking
+	response = search(buyer, q+"&limit=1&offset=1")
+	assert.Equal(t, 2, response.Total)
+	assert.Equal(t, []string{inDescription}, ids(response.Results))
+	response = search(buyer, q+"&limit=1&offset=2")
+	assert.Equal(t, 2, response.Total)
+	assert.Empty(t, response.Results)
+
+	// Sellers search all their own products, drafts included; admins search everything
+	assert.ElementsMatch(t, []string{inName, inDescription, draft}, ids(search(seller, q).Results))
+	assert.ElementsMatch(t, []string{onVacation}, ids(search(away, q).Results))
+	assert.ElementsMatch(t, []string{inName, inDescription, draft, onVacation}, ids(search(admin, q).Results))
+
+	// Web search syntax: quoted phrases and excluded words
+	assert.Equal(t, []string{inName}, ids(search(buyer, "q="+url.QueryEscape(`"desk lamp" `+word)).Results))
+	assert.Equal(t, []string{inDescription}, ids(search(buyer, "q="+url.QueryEscape(word+" -desk")).Results))
+
+	for _, params := range []string{"", "q=++", q + "&limit=0"} {
+		w := serve(SearchProducts, buyer, http.MethodGet, "/api/products/search?"+params, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, params)
+	}
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSecurityEvents lists security events (newest first) recorded over ?from=&to= (default
+// the last 30 days), optionally of one ?type= and ?severity=. Only admins can view them.
+func GetSecurityEvents(c *gin.Context) {
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	from, to, err := parseDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	eventType := c.Query("type")
+	switch eventType {
+	case "", models.SecurityAdminLoginFailed, models.SecurityRoleChanged, models.SecurityAPIKeyCreated,
+		models.SecurityRateLimited, models.SecurityJWKSFailure:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "type must be admin_login_failed, role_changed, api_key_created, rate_limited or jwks_failure",
+		})
+		return
+	}
+	severity := c.Query("severity")
+	switch severity {
+	case "", models.SeverityLow, models.SeverityMedium, models.SeverityHigh:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "severity must be low, medium or high"})
+		return
+	}
+
+	events, total, err := database.GetSecurityEvents(c.Request.Context(), eventType, severity, from, to, page.Limit, page.Offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load security events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": events,
+		"total":  total,
+		"limit":  page.Limit,
+		"offset": page.Offset,
+	})
+}
+
+// GetSecurityEvent returns one security event. Only admins can view it.
+func GetSecurityEvent(c *gin.Context) {
+	event, err := database.GetSecurityEvent(c.Request.Context(), sanitizedIDParam(c))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Security event not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load security event"})
+		return
+	}
+
+	c.JSON(http.StatusOK, event)
+}
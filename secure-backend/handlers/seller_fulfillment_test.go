@@ -0,0 +1,118 @@
+//go:build e2e
+
+// Seller fulfillment tests against a real PostgreSQL database (with database/schema.sql applied):
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run TestSellerOrder ./handlers
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSellerOrderFulfillment(t *testing.T) {
+	users := createTestUsers(t, "fulfillment", "seller", "seller", "buyer")
+	sellerA, sellerB, buyer := users[0], users[1], users[2]
+	ctx := context.Background()
+	t.Cleanup(func() {
+		database.DB.ExecContext(context.Background(), `DELETE FROM orders WHERE buyer_id = $1`, buyer.ID)
+	})
+
+	product := func(seller *models.AuthUser) string {
+		var id string
+		require.NoError(t, database.DB.GetContext(ctx, &id, `
+			INSERT INTO products (name, price, stock, status, seller_id) VALUES ('Fulfilled product', 5, 10, 'published', $1) RETURNING id
+		`, seller.ID))
+		return id
+	}
+	productA, productB := product(sellerA), product(sellerB)
+
+	// order creates an order in status with an item of each product, returning the item IDs
+	order := func(status string, productIDs ...string) (string, []string) {
+		var orderID string
+		require.NoError(t, database.DB.GetContext(ctx, &orderID, `
+			INSERT INTO orders (buyer_id, status, total_amount) VALUES ($1, $2, $3) RETURNING id
+		`, buyer.ID, status, 5*len(productIDs)))
+		itemIDs := make([]string, len(productIDs))
+		for i, productID := range productIDs {
+			require.NoError(t, database.DB.GetContext(ctx, &itemIDs[i], `
+				INSERT INTO order_items (order_id, product_id, quantity, unit_price, total_price) VALUES ($1, $2, 1, 5, 5) RETURNING id
+			`, orderID, productID))
+		}
+		return orderID, itemIDs
+	}
+	update := func(seller *models.AuthUser, itemID, status string) *httptest.ResponseRecorder {
+		return serve(UpdateSellerOrderStatus, seller, http.MethodPut, "/api/seller/orders/"+itemID+"/status",
+			`{"status":"`+status+`"}`, gin.Param{Key: "id", Value: itemID})
+	}
+	orderStatus := func(orderID string) string {
+		order, err := database.GetOrderByID(ctx, orderID)
+		require.NoError(t, err)
+		return order.Status
+	}
+
+	paidID, paidItems := order(services.OrderStatusPaid, productA, productB)
+	itemA, itemB := paidItems[0], paidItems[1]
+
+	// Items only move forward
+	for _, status := range []string{"pending", "lost", ""} {
+		assert.Equal(t, http.StatusBadRequest, update(sellerA, itemA, status).Code, status)
+	}
+
+	// Sellers can't touch other sellers' items
+	assert.Equal(t, http.StatusNotFound, update(sellerB, itemA, "shipped").Code)
+
+	// The order stays paid until the other seller ships too
+	assert.Equal(t, http.StatusOK, update(sellerA, itemA, "shipped").Code)
+	assert.Equal(t, services.OrderStatusPaid, orderStatus(paidID))
+	response := update(sellerA, itemA, "shipped")
+	assert.Equal(t, http.StatusConflict, response.Code)
+	assert.Contains(t, response.Body.String(), "from shipped to shipped")
+
+	assert.Equal(t, http.StatusOK, update(sellerB, itemB, "fulfilled").Code)
+	assert.Equal(t, services.OrderStatusShipped, orderStatus(paidID))
+	history, err := database.GetOrderStatusHistory(ctx, paidID)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, services.OrderStatusShipped, history[0].ToStatus)
+	require.NotNil(t, history[0].ActorID)
+	assert.Equal(t, sellerB.ID, *history[0].ActorID)
+
+	// Shipped items of a shipped order can still be fulfilled
+	assert.Equal(t, http.StatusOK, update(sellerA, itemA, "fulfilled").Code)
+
+	// Items of unpaid or cancelled orders can't ship
+	for _, status := range []string{services.OrderStatusPending, services.OrderStatusCancelled} {
+		_, items := order(status, productA)
+		response := update(sellerA, items[0], "shipped")
+		assert.Equal(t, http.StatusConflict, response.Code, status)
+		assert.Contains(t, response.Body.String(), "not paid", status)
+	}
+
+	// The listing filters by fulfillment status
+	w := serve(GetSellerOrders, sellerA, http.MethodGet, "/api/seller/orders?status=fulfilled", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var listing struct {
+		Items []models.SellerOrderItem `json:"items"`
+		Total int                      `json:"total"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listing))
+	assert.Equal(t, 1, listing.Total)
+	require.Len(t, listing.Items, 1)
+	assert.Equal(t, itemA, listing.Items[0].ID)
+	assert.Equal(t, services.OrderStatusShipped, listing.Items[0].OrderStatus)
+
+	w = serve(GetSellerOrders, sellerA, http.MethodGet, "/api/seller/orders?status=lost", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
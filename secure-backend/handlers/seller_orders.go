@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/services"
+	"secure-backend/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// allowedFulfillmentTransitions lists the fulfillment statuses an order item may move to from each status
+var allowedFulfillmentTransitions = map[string][]string{
+	"pending": {"shipped", "fulfilled"},
+	"shipped": {"fulfilled"},
+}
+
+// GetSellerOrders returns order items for the authenticated seller's products
+// Supports ?status= (pending, shipped, fulfilled) and limit/offset pagination
+func GetSellerOrders(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := strings.ToLower(strings.TrimSpace(c.Query("status")))
+	if status != "" && !utils.IsValidFulfillmentStatus(status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status. Must be pending, shipped, or fulfilled"})
+		return
+	}
+
+	items, total, err := database.GetSellerOrderItems(c.Request.Context(), user.ID, status, page.Limit, page.Offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load orders"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":  items,
+		"total":  total,
+		"limit":  page.Limit,
+		"offset": page.Offset,
+	})
+}
+
+// UpdateSellerOrderStatus updates the fulfillment status of an order item
+// Only sellers can update items for their own products
+func UpdateSellerOrderStatus(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	orderItemID := c.Param("id")
+	if orderItemID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Order item ID is required"})
+		return
+	}
+
+	var request struct {
+		Status string `json:"status" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Items can only move forward, so pending is never a target
+	status := strings.ToLower(strings.TrimSpace(request.Status))
+	sources := fulfillmentSources(status)
+	if len(sources) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status. Must be shipped or fulfilled"})
+		return
+	}
+
+	orderID, err := database.UpdateOrderItemFulfillment(c.Request.Context(), orderItemID, user.ID, sources, status)
+	if err == sql.ErrNoRows {
+		respondFulfillmentRefused(c, orderItemID, user.ID, status)
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order item"})
+		return
+	}
+
+	// The item is updated either way; a failure leaves the order for an admin to mark shipped
+	if err := services.ShipOrderIfFulfilled(c.Request.Context(), orderID, user); err != nil {
+		log.Printf("Failed to mark order %s shipped: %v", orderID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Order item status updated successfully", "status": status})
+}
+
+// respondFulfillmentRefused explains why an order item's status couldn't be changed: the item
+// isn't the seller's, its current status doesn't allow the change, or its order isn't paid.
+// It only runs after the update matched nothing, so the common case is a single statement.
+func respondFulfillmentRefused(c *gin.Context, orderItemID, sellerID, status string) {
+	item, err := database.GetSellerOrderItem(c.Request.Context(), orderItemID, sellerID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order item not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order item"})
+		return
+	}
+
+	if !isAllowedFulfillmentTransition(item.FulfillmentStatus, status) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Cannot change status from " + item.FulfillmentStatus + " to " + status,
+		})
+		return
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "Order item was modified or its order is not paid"})
+}
+
+// fulfillmentSources returns the fulfillment statuses an order item may move to status from
+func fulfillmentSources(status string) []string {
+	var sources []string
+	for from := range allowedFulfillmentTransitions {
+		if isAllowedFulfillmentTransition(from, status) {
+			sources = append(sources, from)
+		}
+	}
+	return sources
+}
+
+// isAllowedFulfillmentTransition reports whether an order item may move from one fulfillment status to another
+func isAllowedFulfillmentTransition(from, to string) bool {
+	for _, allowed := range allowedFulfillmentTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFulfillmentSources(t *testing.T) {
+	sources := fulfillmentSources("fulfilled")
+	sort.Strings(sources)
+	assert.Equal(t, []string{"pending", "shipped"}, sources)
+	assert.Equal(t, []string{"pending"}, fulfillmentSources("shipped"))
+
+	// Nothing moves back to pending, and unknown statuses have no sources
+	assert.Empty(t, fulfillmentSources("pending"))
+	assert.Empty(t, fulfillmentSources("lost"))
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/services"
+	"secure-backend/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultQualityDays is the period seller quality is scored over without ?days=
+	defaultQualityDays = 90
+	// maxQualityDays bounds ?days= of the seller quality report
+	maxQualityDays = 365
+)
+
+// CreateSellerRating rates a seller of one of the buyer's orders on shipping speed and
+// communication (1 to 5) with an optional comment, once the seller's items were delivered.
+// Each seller of an order can be rated once. Links, email addresses, phone numbers and
+// profanity are masked in the comment like in messages, and admins can hide ratings.
+func CreateSellerRating(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		SellerID      string `json:"seller_id" binding:"required"`
+		ShippingSpeed int    `json:"shipping_speed" binding:"required,min=1,max=5"`
+		Communication int    `json:"communication" binding:"required,min=1,max=5"`
+		Comment       string `json:"comment"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rating := &models.SellerRating{
+		OrderID:       sanitizedIDParam(c),
+		SellerID:      request.SellerID,
+		BuyerID:       &user.ID,
+		ShippingSpeed: request.ShippingSpeed,
+		Communication: request.Communication,
+		Comment:       utils.SanitizeInput(request.Comment, utils.DefaultTextOptions),
+	}
+	err = services.CreateSellerRating(c.Request.Context(), rating)
+	switch {
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order with items of this seller not found"})
+		return
+	case errors.Is(err, database.ErrOrderNotDelivered):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, database.ErrAlreadyRated):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rate seller"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rating)
+}
+
+// GetSellerRatings returns a seller's profile ratings: the averages of their published
+// ratings and a page of those ratings, newest first. Raters aren't shown.
+func GetSellerRatings(c *gin.Context) {
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	seller, err := database.GetUserByID(ctx, sanitizedIDParam(c))
+	if err == sql.ErrNoRows || err == nil && seller.Role != "seller" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Seller not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load seller"})
+		return
+	}
+
+	summary, err := database.GetSellerRatingSummary(ctx, seller.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load seller ratings"})
+		return
+	}
+	ratings, total, err := database.GetSellerRatings(ctx, seller.ID, page.Limit, page.Offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load seller ratings"})
+		return
+	}
+	for i := range ratings {
+		ratings[i].BuyerID = nil
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"summary": summary,
+		"ratings": ratings,
+		"total":   total,
+		"limit":   page.Limit,
+		"offset":  page.Offset,
+	})
+}
+
+// GetAdminSellerRatings lists seller ratings for moderation, newest first (?seller_id=,
+// ?status=published|hidden, ?flagged=true for comments moderation masked something in;
+// paginated)
+func GetAdminSellerRatings(c *gin.Context) {
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", models.RatingPublished, models.RatingHidden:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be published or hidden"})
+		return
+	}
+
+	ratings, total, err := database.FindSellerRatings(c.Request.Context(), database.SellerRatingFilter{
+		SellerID:    c.Query("seller_id"),
+		Status:      status,
+		FlaggedOnly: c.Query("flagged") == "true",
+	}, page.Limit, page.Offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load seller ratings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ratings": ratings,
+		"total":   total,
+		"limit":   page.Limit,
+		"offset":  page.Offset,
+	})
+}
+
+// ModerateSellerRating hides a seller rating from the seller's profile and averages
+// ("hidden") or restores it ("published"). Decisions are recorded in the admin audit log.
+func ModerateSellerRating(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		Status string `json:"status" binding:"required,oneof=published hidden"`
+		Note   string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	id := sanitizedIDParam(c)
+	note := utils.SanitizeInput(request.Note, utils.DefaultTextOptions)
+	rating, err := database.ModerateSellerRating(c.Request.Context(), id, request.Status, note, &models.AuditEntry{
+		ActorID:    &user.ID,
+		ActorEmail: user.Email,
+		Action:     models.AuditSellerRatingModerated,
+		Detail:     fmt.Sprintf("%s seller rating %s: %s", request.Status, id, note),
+		IPAddress:  c.ClientIP(),
+	})
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Seller rating not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to moderate seller rating"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rating)
+}
+
+// GetSellerQuality scores sellers from their published ratings and how many of their orders
+// were disputed over the last ?days= (default 90, at most 365), lowest score first (paginated).
+// Flagged and hidden rating counts are included for context but don't affect the score.
+func GetSellerQuality(c *gin.Context) {
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	days := defaultQualityDays
+	if daysParam := c.Query("days"); daysParam != "" {
+		days, err = strconv.Atoi(daysParam)
+		if err != nil || days < 1 || days > maxQualityDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 365"})
+			return
+		}
+	}
+
+	sellers, err := services.GetSellerQuality(c.Request.Context(), days)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to score sellers"})
+		return
+	}
+
+	total := len(sellers)
+	end := min(page.Offset+page.Limit, total)
+	start := min(page.Offset, end)
+	c.JSON(http.StatusOK, gin.H{
+		"sellers": sellers[start:end],
+		"days":    days,
+		"total":   total,
+		"limit":   page.Limit,
+		"offset":  page.Offset,
+	})
+}
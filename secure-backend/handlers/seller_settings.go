@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/money"
+	"secure-backend/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSellerSettings returns the order rules the seller applies to their products
+func GetSellerSettings(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, err := database.GetSellerSettings(c.Request.Context(), user.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Seller not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load seller settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdateSellerSettings sets the seller's minimum order value: checkout refuses orders whose
+// subtotal of the seller's products is below it (0 removes the minimum)
+func UpdateSellerSettings(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		MinOrderValue *money.Amount `json:"min_order_value" binding:"required,min=0,max=9999999999"` // limits are in cents
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings := &models.SellerSettings{MinOrderValue: *request.MinOrderValue}
+	err = database.UpdateSellerSettings(c.Request.Context(), user.ID, settings)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Seller not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update seller settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// GetSellerVacation returns the seller's vacation settings and whether the vacation is active
+func GetSellerVacation(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	vacation, err := database.GetSellerVacation(c.Request.Context(), user.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Seller not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load vacation settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"vacation": vacation, "active": vacation.Active(clk.Now())})
+}
+
+// SetSellerVacation schedules the seller's vacation. It starts at starts_at (now if omitted)
+// and lasts until ends_at, or until the seller ends it when ends_at is omitted.
+func SetSellerVacation(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		StartsAt     *time.Time `json:"starts_at"`
+		EndsAt       *time.Time `json:"ends_at"`
+		Message      string     `json:"message"`
+		HideListings bool       `json:"hide_listings"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := clk.Now()
+	if request.StartsAt == nil {
+		request.StartsAt = &now
+	}
+	if request.EndsAt != nil && (!request.EndsAt.After(*request.StartsAt) || !request.EndsAt.After(now)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ends_at must be in the future and after starts_at"})
+		return
+	}
+
+	vacation := &models.SellerVacation{
+		StartsAt: request.StartsAt,
+		EndsAt:   request.EndsAt,
+		Message: utils.SanitizeInput(request.Message, utils.SanitizationOptions{
+			TrimWhitespace: true,
+			EscapeHTML:     true,
+			RemoveNewlines: true,
+			MaxLength:      500,
+		}),
+		HideListings: request.HideListings,
+	}
+	err = database.SetSellerVacation(c.Request.Context(), user.ID, vacation)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Seller not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update vacation settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"vacation": vacation, "active": vacation.Active(now)})
+}
+
+// EndSellerVacation ends or cancels the seller's vacation
+func EndSellerVacation(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = database.SetSellerVacation(c.Request.Context(), user.ID, &models.SellerVacation{})
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Seller not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update vacation settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Vacation ended"})
+}
+
+// checkSellerTakingOrders verifies that the product's seller isn't on vacation, responding
+// with the vacation error and returning false if they are
+func checkSellerTakingOrders(c *gin.Context, product *models.Product) bool {
+	vacation, err := database.GetSellerVacation(c.Request.Context(), product.SellerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify product"})
+		return false
+	}
+
+	var ruleErr *models.OrderRuleError
+	if err := vacation.CheckOrders(product.SellerID, product.ID, clk.Now()); errors.As(err, &ruleErr) {
+		c.JSON(http.StatusBadRequest, ruleErr)
+		return false
+	}
+	return true
+}
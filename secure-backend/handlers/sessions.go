@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"secure-backend/middleware"
+	"secure-backend/models"
+	"secure-backend/sessions"
+	"secure-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// currentSession returns the session the request authenticated with, if any
+func currentSession(c *gin.Context) (*models.Session, bool) {
+	value, ok := c.Get(middleware.SessionKey)
+	if !ok {
+		return nil, false
+	}
+	session, ok := value.(*models.Session)
+	return session, ok
+}
+
+// CreateSession exchanges the caller's Bearer JWT for a cookie session (web storefront).
+// The response carries the CSRF token to send in X-CSRF-Token on requests that change state.
+func CreateSession(c *gin.Context) {
+	if !sessions.Enabled() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Cookie sessions are not enabled"})
+		return
+	}
+	if session, ok := currentSession(c); ok {
+		c.JSON(http.StatusOK, session)
+		return
+	}
+
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	cookie, session, err := sessions.Create(c.Request.Context(), user, c.Request.UserAgent())
+	if err != nil {
+		log.Printf("Failed to create session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+
+	http.SetCookie(c.Writer, cookie)
+	c.JSON(http.StatusCreated, session)
+}
+
+// GetSession returns the CSRF token and expiry of the caller's cookie session, so the
+// storefront can recover them after a page reload
+func GetSession(c *gin.Context) {
+	session, ok := currentSession(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not signed in with a session cookie"})
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// DeleteSession signs out of the cookie session and clears the cookie
+func DeleteSession(c *gin.Context) {
+	value, err := c.Cookie(sessions.CookieName)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not signed in with a session cookie"})
+		return
+	}
+
+	cookie, err := sessions.Destroy(c.Request.Context(), value)
+	if errors.Is(err, sessions.ErrDisabled) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Cookie sessions are not enabled"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end session"})
+		return
+	}
+
+	http.SetCookie(c.Writer, cookie)
+	c.JSON(http.StatusOK, gin.H{"message": "Signed out"})
+}
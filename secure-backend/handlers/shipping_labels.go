@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/jobs"
+	"secure-backend/shipping"
+	"secure-backend/storage"
+	"secure-backend/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxLabelBatch caps the orders of one label purchase
+const maxLabelBatch = 50
+
+// BuyShippingLabels starts a job buying a label for each of the seller's orders, which marks
+// the seller's items in them shipped and charges the labels to the seller's ledger
+func BuyShippingLabels(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !shipping.Enabled() || !storage.Configured() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Label purchase is not available"})
+		return
+	}
+
+	var request struct {
+		OrderIDs    []string `json:"order_ids" binding:"required"`
+		FromAddress string   `json:"from_address" binding:"required"`
+		Service     string   `json:"service"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	params := jobs.ShippingLabelsParams{
+		FromAddress: utils.SanitizeAddress(request.FromAddress),
+		Service:     utils.SanitizeInput(request.Service, utils.SanitizationOptions{TrimWhitespace: true, RemoveNewlines: true, MaxLength: 50}),
+	}
+	seen := map[string]bool{}
+	for _, id := range request.OrderIDs {
+		if id = strings.TrimSpace(id); id != "" && !seen[id] {
+			seen[id] = true
+			params.OrderIDs = append(params.OrderIDs, id)
+		}
+	}
+	switch {
+	case len(params.OrderIDs) == 0:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order_ids must list at least one order ID"})
+		return
+	case len(params.OrderIDs) > maxLabelBatch:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("order_ids must list at most %d orders", maxLabelBatch)})
+		return
+	case params.FromAddress == "":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from_address is required"})
+		return
+	}
+
+	job, err := jobs.Enqueue(c.Request.Context(), user.ID, jobs.TypeShippingLabels, params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start label purchase"})
+		return
+	}
+
+	c.Header("Location", "/api/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, gin.H{"message": "Label purchase started", "job": job})
+}
+
+// GetShippingLabels returns a page of the seller's labels, newest first
+func GetShippingLabels(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	labels, total, err := database.GetShippingLabels(c.Request.Context(), user.ID, page.Limit, page.Offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load shipping labels"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"labels": labels,
+		"total":  total,
+		"limit":  page.Limit,
+		"offset": page.Offset,
+	})
+}
+
+// DownloadShippingLabel returns the PDF of one of the seller's labels
+func DownloadShippingLabel(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	label, err := database.GetShippingLabel(c.Request.Context(), sanitizedIDParam(c), user.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shipping label not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch shipping label"})
+		return
+	}
+
+	pdf, err := storage.Download(c.Request.Context(), label.StorageKey)
+	if err != nil {
+		log.Printf("Failed to download shipping label %s: %v", label.ID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch label PDF"})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="label-`+label.ID+`.pdf"`)
+	c.Header("Cache-Control", "private, no-store")
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
+
+// GetSellerLedger returns a page of the seller's payout ledger, newest first, with the
+// balance per currency
+func GetSellerLedger(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entries, total, balances, err := database.GetSellerLedger(c.Request.Context(), user.ID, page.Limit, page.Offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load ledger"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries":  entries,
+		"balances": balances,
+		"total":    total,
+		"limit":    page.Limit,
+		"offset":   page.Offset,
+	})
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/jobs"
+	"secure-backend/models"
+	"secure-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StartStockAudit queues a stock audit job that cross-checks every product's stock against
+// its movement ledger and the reservation table. The CSV of discrepancies is downloaded
+// from the job like an export. Only admins can audit stock.
+func StartStockAudit(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := jobs.Enqueue(c.Request.Context(), user.ID, jobs.TypeStockAudit, struct{}{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start stock audit"})
+		return
+	}
+
+	c.Header("Location", "/api/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, gin.H{"message": "Stock audit started", "job": job})
+}
+
+// GetStockMovements returns a page of a product's stock ledger, newest first (admins only)
+func GetStockMovements(c *gin.Context) {
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	movements, total, err := database.GetStockMovements(c.Request.Context(), sanitizedIDParam(c), page.Limit, page.Offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load stock movements"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"movements": movements,
+		"total":     total,
+		"limit":     page.Limit,
+		"offset":    page.Offset,
+	})
+}
+
+// AdjustStock corrects a product's stock after an audit, writing an adjustment movement so
+// the ledger matches the new stock. The request must name the stock the admin audited
+// (expected_stock), so a correction never overwrites sales made since, and give a reason;
+// every correction is recorded in the admin audit log.
+func AdjustStock(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		ExpectedStock *int   `json:"expected_stock" binding:"required"`
+		NewStock      *int   `json:"new_stock" binding:"required,min=0"`
+		Reason        string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reason := utils.SanitizeInput(request.Reason, utils.DefaultTextOptions)
+	if len(reason) < 10 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason of at least 10 characters is required"})
+		return
+	}
+
+	productID := sanitizedIDParam(c)
+	movement, err := database.AdjustStock(c.Request.Context(), productID, *request.ExpectedStock, *request.NewStock, &models.AuditEntry{
+		ActorID:    &user.ID,
+		ActorEmail: user.Email,
+		Action:     models.AuditStockAdjusted,
+		Detail:     reason,
+		IPAddress:  c.ClientIP(),
+	})
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	} else if errors.Is(err, database.ErrStockChanged) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Stock changed since it was audited; re-run the audit and retry"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to adjust stock"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"product_id": productID,
+		"stock":      *request.NewStock,
+		"movement":   movement,
+	})
+}
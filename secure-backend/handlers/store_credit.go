@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/money"
+	"secure-backend/payments"
+	"secure-backend/services"
+	"secure-backend/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetStoreCredit returns the buyer's store credit balance and a page of its ledger, newest first
+func GetStoreCredit(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	balance, err := database.GetStoreCreditBalance(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load store credit"})
+		return
+	}
+
+	entries, total, err := database.GetStoreCreditEntries(c.Request.Context(), user.ID, page.Limit, page.Offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load store credit"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"balance": balance,
+		"entries": entries,
+		"total":   total,
+		"limit":   page.Limit,
+		"offset":  page.Offset,
+	})
+}
+
+// RedeemGiftCard adds a gift card's amount to the buyer's store credit
+func RedeemGiftCard(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := services.RedeemGiftCard(c.Request.Context(), request.Code, user.ID, clk.Now())
+	if errors.Is(err, database.ErrGiftCardInvalid) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Gift card is invalid, expired or already redeemed"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to redeem gift card"})
+		return
+	}
+
+	balance, err := database.GetStoreCreditBalance(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load store credit"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Gift card redeemed", "entry": entry, "balance": balance})
+}
+
+// ApplyStoreCredit pays part or all of one of the buyer's pending orders from their store credit.
+// Without an amount, as much as the balance and the order allow is applied. The rest, if any,
+// is paid by card through the payment intent endpoint.
+func ApplyStoreCredit(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		OrderID string       `json:"order_id" binding:"required"`
+		Amount  money.Amount `json:"amount" binding:"min=0"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, err := database.GetOrderByBuyer(c.Request.Context(), request.OrderID, user.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order"})
+		return
+	}
+
+	payment, paid, err := payments.ApplyStoreCredit(c.Request.Context(), order, request.Amount)
+	switch {
+	case errors.Is(err, database.ErrInsufficientCredit):
+		c.JSON(http.StatusConflict, gin.H{"error": "No store credit available"})
+		return
+	case errors.Is(err, database.ErrCardPaymentStarted):
+		c.JSON(http.StatusConflict, gin.H{"error": "A card payment was already started for this order"})
+		return
+	case err != nil:
+		respondPaymentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"payment": payment, "order_paid": paid})
+}
+
+// CreateGiftCard issues a gift card (admins only). The code is returned once and only its
+// last four characters are kept in readable form.
+func CreateGiftCard(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		Amount    money.Amount `json:"amount" binding:"required,gt=0"`
+		ExpiresAt *time.Time   `json:"expires_at"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(clk.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+
+	card := &models.GiftCard{Amount: request.Amount, ExpiresAt: request.ExpiresAt}
+	audit := &models.AuditEntry{
+		ActorID:    &user.ID,
+		ActorEmail: user.Email,
+		Action:     models.AuditGiftCardIssued,
+		IPAddress:  c.ClientIP(),
+	}
+	created, code, err := services.IssueGiftCard(c.Request.Context(), card, audit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create gift card"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"gift_card": created, "code": code})
+}
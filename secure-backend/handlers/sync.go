@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/services"
+	"secure-backend/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSyncOperations caps the number of offline cart operations accepted per sync request
+const maxSyncOperations = 100
+
+// syncOverlap is how far back the next sync re-reads products and orders. Rows are stamped
+// with the start of the transaction writing them, so one still open during a sync commits
+// rows older than the cursor; reading them again is harmless since clients replace rows by ID.
+const syncOverlap = time.Minute
+
+// SyncCursor records how far a client has synced each change feed.
+// It is handed to clients as an opaque base64 token.
+type SyncCursor struct {
+	CartVersion   int64     `json:"c"`
+	ProductsSince time.Time `json:"p"`
+	OrdersSince   time.Time `json:"o"`
+}
+
+// encodeSyncCursor serializes a cursor into an opaque token
+func encodeSyncCursor(cursor SyncCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeSyncCursor parses a token produced by encodeSyncCursor; an empty token starts from scratch
+func decodeSyncCursor(token string) (SyncCursor, error) {
+	var cursor SyncCursor
+	if token == "" {
+		return cursor, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(data, &cursor)
+	return cursor, err
+}
+
+// Sync returns every change relevant to the user since the given cursor:
+// cart changes (as a delta against the cart version), products in the user's
+// cart or order history, and the user's orders. Products and orders changed
+// shortly before the previous sync may be returned again.
+func Sync(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	cursor, err := decodeSyncCursor(c.Query("cursor"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sync cursor"})
+		return
+	}
+
+	// Capture the database's time before reading so nothing changed during the request is
+	// skipped next time
+	syncedAt, err := database.Now(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync"})
+		return
+	}
+	since := syncedAt.Add(-syncOverlap)
+
+	cart, err := buildCartDelta(c.Request.Context(), user.ID, cursor.CartVersion)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync cart"})
+		return
+	}
+
+	products, err := database.GetWatchedProductsSince(c.Request.Context(), user.ID, cursor.ProductsSince)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync products"})
+		return
+	}
+
+	orders, err := database.GetOrdersByBuyerSince(c.Request.Context(), user.ID, cursor.OrdersSince)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync orders"})
+		return
+	}
+
+	if products == nil {
+		products = []models.Product{}
+	}
+	if orders == nil {
+		orders = []models.Order{}
+	}
+
+	next := SyncCursor{
+		CartVersion:   cart.Version,
+		ProductsSince: since,
+		OrdersSince:   since,
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"cart":     cart,
+		"products": products,
+		"orders":   orders,
+		"cursor":   encodeSyncCursor(next),
+	})
+}
+
+// SyncCartOperation is a cart edit made while the client was offline.
+// Quantity is the desired final quantity (0 removes the item) and
+// BaseVersion is the cart version the client last saw before editing.
+type SyncCartOperation struct {
+	ProductID   string `json:"product_id" binding:"required"`
+	Quantity    int    `json:"quantity" binding:"min=0,max=100"`
+	BaseVersion int64  `json:"base_version" binding:"min=0"`
+}
+
+// SyncCartResult reports how a single offline operation was resolved
+type SyncCartResult struct {
+	ProductID string           `json:"product_id"`
+	Status    string           `json:"status"` // applied, adjusted, conflict, rejected
+	Reason    string           `json:"reason,omitempty"`
+	Item      *models.CartItem `json:"item,omitempty"`
+}
+
+// SyncCart replays cart edits made offline using these conflict resolution rules:
+//   - if the server-side item changed or was removed after the client's base version,
+//     the server state wins and the operation is reported as a conflict
+//   - operations on products that are no longer published are rejected
+//   - quantities above the available stock are reduced to the stock level ("adjusted")
+//   - quantities the cart has no room for are reduced to fit its limits ("adjusted"), and
+//     rejected when the cart is already full
+//   - otherwise the client's quantity is applied
+func SyncCart(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		Operations []SyncCartOperation `json:"operations" binding:"required,dive"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(request.Operations) > maxSyncOperations {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Too many operations in a single sync"})
+		return
+	}
+
+	results := make([]SyncCartResult, 0, len(request.Operations))
+	for _, op := range request.Operations {
+		result, err := applySyncCartOperation(c.Request.Context(), user.ID, op)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync cart"})
+			return
+		}
+		results = append(results, result)
+		if result.Status == "applied" || result.Status == "adjusted" {
+			recordCartEvent(c, user.ID, cartActionSync, op.ProductID, op.Quantity)
+		}
+	}
+
+	version, err := database.GetCartVersion(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync cart"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": results,
+		"version": version,
+	})
+}
+
+// applySyncCartOperation resolves and applies a single offline cart operation
+func applySyncCartOperation(ctx context.Context, userID string, op SyncCartOperation) (SyncCartResult, error) {
+	result := SyncCartResult{ProductID: op.ProductID}
+
+	existing, err := database.GetCartItemByProduct(ctx, userID, op.ProductID)
+	if err != nil && err != sql.ErrNoRows {
+		return result, err
+	}
+
+	if existing != nil {
+		// Server item changed after the client's snapshot: server wins
+		if existing.Version > op.BaseVersion {
+			result.Status = "conflict"
+			result.Reason = "Item was modified on another device"
+			result.Item = existing
+			return result, nil
+		}
+
+		if op.Quantity == 0 {
+			if err := database.RemoveFromCart(ctx, existing.ID, userID); err != nil && err != sql.ErrNoRows {
+				return result, err
+			}
+			result.Status = "applied"
+			return result, nil
+		}
+	} else {
+		if op.Quantity == 0 {
+			// Already gone on the server; nothing to do
+			result.Status = "applied"
+			return result, nil
+		}
+
+		// Item was removed on another device after the client's snapshot: server wins
+		removedAt, err := database.GetLastRemovalVersion(ctx, userID, op.ProductID)
+		if err != nil {
+			return result, err
+		}
+		if removedAt > op.BaseVersion {
+			result.Status = "conflict"
+			result.Reason = "Item was removed on another device"
+			return result, nil
+		}
+	}
+
+	product, err := database.GetProductByID(ctx, op.ProductID)
+	if err == sql.ErrNoRows || (err == nil && product.Status != "published") {
+		result.Status = "rejected"
+		result.Reason = "Product is not available"
+		return result, nil
+	} else if err != nil {
+		return result, err
+	}
+
+	quantity := op.Quantity
+	result.Status = "applied"
+	if product.Stock < quantity {
+		if product.Stock <= 0 {
+			result.Status = "rejected"
+			result.Reason = "Product is out of stock"
+			return result, nil
+		}
+		quantity = product.Stock
+		result.Status = "adjusted"
+		result.Reason = "Quantity reduced to available stock"
+	}
+
+	// Purchase limits: offline edits above the maximum are reduced, below the minimum refused
+	if product.MaxOrderQuantity != nil && quantity > *product.MaxOrderQuantity {
+		quantity = *product.MaxOrderQuantity
+		result.Status = "adjusted"
+		result.Reason = "Quantity reduced to the maximum per order"
+	}
+
+	// Cart limits: reduced to what the cart has room for, refused once it is full
+	exceptItemID := ""
+	if existing != nil {
+		exceptItemID = existing.ID
+	}
+	units, err := database.GetCartUnits(ctx, userID, exceptItemID)
+	if err != nil {
+		return result, err
+	}
+	maxCartUnits := services.CartMaxUnits()
+	if quantity > models.MaxCartItemQuantity {
+		quantity = models.MaxCartItemQuantity
+		result.Status = "adjusted"
+		result.Reason = "Quantity reduced to the most a cart can hold of a product"
+	}
+	if room := maxCartUnits - units; maxCartUnits > 0 && room > 0 && quantity > room {
+		quantity = room
+		result.Status = "adjusted"
+		result.Reason = "Quantity reduced to fit the cart size limit"
+	}
+	if err := models.CheckCartLimits(product.ID, quantity, units+quantity, maxCartUnits); err != nil {
+		result.Status = "rejected"
+		result.Reason = err.Error()
+		return result, nil
+	}
+
+	if err := product.CheckOrderQuantity(quantity); err != nil {
+		result.Status = "rejected"
+		result.Reason = err.Error()
+		return result, nil
+	}
+
+	if existing != nil {
+		if err := database.UpdateCartItemQuantity(ctx, existing.ID, userID, quantity); err != nil {
+			return result, err
+		}
+	} else if _, err := database.AddToCart(ctx, userID, op.ProductID, quantity); err != nil {
+		return result, err
+	}
+
+	result.Item, err = database.GetCartItemByProduct(ctx, userID, op.ProductID)
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
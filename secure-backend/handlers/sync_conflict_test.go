@@ -0,0 +1,91 @@
+//go:build e2e
+
+// Offline cart sync tests against a real PostgreSQL database (with database/schema.sql applied):
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run TestApplySyncCartOperation ./handlers
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"secure-backend/database"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplySyncCartOperationConflicts(t *testing.T) {
+	users := createTestUsers(t, "sync", "seller", "buyer")
+	sellerID, buyerID := users[0].ID, users[1].ID
+	t.Setenv("CART_MAX_UNITS", "0")
+	ctx := context.Background()
+
+	var lampID, draftID string
+	require.NoError(t, database.DB.GetContext(ctx, &lampID, `
+		INSERT INTO products (name, price, stock, status, seller_id) VALUES ('Sync lamp', 5, 5, 'published', $1) RETURNING id
+	`, sellerID))
+	require.NoError(t, database.DB.GetContext(ctx, &draftID, `
+		INSERT INTO products (name, price, stock, status, seller_id) VALUES ('Sync draft', 5, 5, 'draft', $1) RETURNING id
+	`, sellerID))
+
+	apply := func(productID string, quantity int, baseVersion int64) SyncCartResult {
+		t.Helper()
+		result, err := applySyncCartOperation(ctx, buyerID, SyncCartOperation{ProductID: productID, Quantity: quantity, BaseVersion: baseVersion})
+		require.NoError(t, err)
+		return result
+	}
+	version := func() int64 {
+		t.Helper()
+		version, err := database.GetCartVersion(ctx, buyerID)
+		require.NoError(t, err)
+		return version
+	}
+
+	// Another device adds the lamp after the offline client's snapshot: the server wins
+	_, err := database.AddToCart(ctx, buyerID, lampID, 1)
+	require.NoError(t, err)
+	result := apply(lampID, 2, 0)
+	assert.Equal(t, "conflict", result.Status)
+	require.NotNil(t, result.Item)
+	assert.Equal(t, 1, result.Item.Quantity, "the server's item is returned unchanged")
+
+	// An edit based on the current version applies the client's quantity
+	result = apply(lampID, 2, version())
+	assert.Equal(t, "applied", result.Status)
+	require.NotNil(t, result.Item)
+	assert.Equal(t, 2, result.Item.Quantity)
+
+	// Quantities above the stock are reduced to it
+	result = apply(lampID, 9, version())
+	assert.Equal(t, "adjusted", result.Status)
+	require.NotNil(t, result.Item)
+	assert.Equal(t, 5, result.Item.Quantity)
+
+	// Another device removes the lamp after the snapshot: the removal wins
+	snapshot := version()
+	item, err := database.GetCartItemByProduct(ctx, buyerID, lampID)
+	require.NoError(t, err)
+	require.NoError(t, database.RemoveFromCart(ctx, item.ID, buyerID))
+	result = apply(lampID, 3, snapshot)
+	assert.Equal(t, "conflict", result.Status)
+	assert.Nil(t, result.Item)
+
+	// Once the client has seen the removal it can add the lamp again
+	result = apply(lampID, 3, version())
+	assert.Equal(t, "applied", result.Status)
+	require.NotNil(t, result.Item)
+	assert.Equal(t, 3, result.Item.Quantity)
+
+	// Removing an item based on the current version removes it, and removing it again is a no-op
+	assert.Equal(t, "applied", apply(lampID, 0, version()).Status)
+	removedAt := version()
+	assert.Equal(t, "applied", apply(lampID, 0, 0).Status)
+	assert.Equal(t, removedAt, version(), "removing a missing item doesn't change the cart")
+
+	// Products that aren't published are refused
+	result = apply(draftID, 1, version())
+	assert.Equal(t, "rejected", result.Status)
+	_, err = database.GetCartItemByProduct(ctx, buyerID, draftID)
+	assert.Error(t, err, "a rejected operation adds nothing")
+}
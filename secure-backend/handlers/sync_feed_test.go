@@ -0,0 +1,64 @@
+//go:build e2e
+
+// Sync change feed tests against a real PostgreSQL database (with database/schema.sql applied):
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run TestSyncFeed ./handlers
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"secure-backend/database"
+	"secure-backend/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncFeedLateCommit(t *testing.T) {
+	buyer := createTestUsers(t, "syncfeed", "buyer")[0]
+	ctx := context.Background()
+	t.Cleanup(func() {
+		database.DB.ExecContext(context.Background(), `DELETE FROM orders WHERE buyer_id = $1`, buyer.ID)
+	})
+
+	var orderID string
+	require.NoError(t, database.DB.GetContext(ctx, &orderID, `
+		INSERT INTO orders (buyer_id, status, total_amount) VALUES ($1, 'pending', 5) RETURNING id
+	`, buyer.ID))
+
+	syncOrders := func(cursor string) (orders []models.Order, next string) {
+		t.Helper()
+		w := serve(Sync, buyer, http.MethodGet, "/api/sync?cursor="+url.QueryEscape(cursor), "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response struct {
+			Orders []models.Order `json:"orders"`
+			Cursor string         `json:"cursor"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response.Orders, response.Cursor
+	}
+
+	// A transaction open during the sync stamps the order with its start time, which is before
+	// the sync read anything, and commits only afterwards
+	tx, err := database.DB.BeginTxx(ctx, nil)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx, `UPDATE orders SET status = 'paid' WHERE id = $1`, orderID)
+	require.NoError(t, err)
+
+	orders, cursor := syncOrders("")
+	require.Len(t, orders, 1)
+	assert.Equal(t, "pending", orders[0].Status)
+	require.NoError(t, tx.Commit())
+
+	// The next sync still picks the change up
+	orders, _ = syncOrders(cursor)
+	require.Len(t, orders, 1)
+	assert.Equal(t, orderID, orders[0].ID)
+	assert.Equal(t, "paid", orders[0].Status)
+}
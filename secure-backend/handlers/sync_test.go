@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncCursorRoundTrip(t *testing.T) {
+	syncedAt := time.Date(2026, 3, 31, 12, 0, 0, 123456789, time.UTC)
+	cursor := SyncCursor{CartVersion: 42, ProductsSince: syncedAt, OrdersSince: syncedAt}
+
+	decoded, err := decodeSyncCursor(encodeSyncCursor(cursor))
+	require.NoError(t, err)
+	assert.Equal(t, cursor.CartVersion, decoded.CartVersion)
+	assert.True(t, decoded.ProductsSince.Equal(syncedAt), "timestamps keep their nanoseconds so no change is skipped")
+	assert.True(t, decoded.OrdersSince.Equal(syncedAt))
+}
+
+func TestDecodeSyncCursor(t *testing.T) {
+	// No cursor syncs everything
+	cursor, err := decodeSyncCursor("")
+	require.NoError(t, err)
+	assert.Zero(t, cursor.CartVersion)
+	assert.True(t, cursor.ProductsSince.IsZero())
+	assert.True(t, cursor.OrdersSince.IsZero())
+
+	for name, token := range map[string]string{
+		"not base64":         "not a cursor!",
+		"padded base64":      base64.URLEncoding.EncodeToString([]byte(`{"c":1}`)),
+		"not json":           base64.RawURLEncoding.EncodeToString([]byte("cursor")),
+		"wrong version type": base64.RawURLEncoding.EncodeToString([]byte(`{"c":"1"}`)),
+		"bad timestamp":      base64.RawURLEncoding.EncodeToString([]byte(`{"p":"yesterday"}`)),
+	} {
+		_, err := decodeSyncCursor(token)
+		assert.Error(t, err, name)
+	}
+}
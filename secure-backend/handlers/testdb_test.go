@@ -0,0 +1,78 @@
+//go:build e2e
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"secure-backend/database"
+	"secure-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testDBOnce sync.Once
+	testDBErr  error
+)
+
+// initTestDB connects the database package to TEST_DATABASE_URL, skipping the test if it isn't set
+func initTestDB(t *testing.T) {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	testDBOnce.Do(func() {
+		os.Setenv("DATABASE_URL", dsn)
+		testDBErr = database.InitDB()
+	})
+	if testDBErr != nil {
+		t.Fatalf("failed to connect to test database: %v", testDBErr)
+	}
+}
+
+// createTestUsers creates a user per role with emails unique to the run and deletes them
+// when the test ends. Cleanups registered afterwards run first, so tests can delete rows
+// that keep the users from being deleted.
+func createTestUsers(t *testing.T, prefix string, roles ...string) []*models.AuthUser {
+	t.Helper()
+	initTestDB(t)
+
+	suffix := uuid.NewString()[:8]
+	users := make([]*models.AuthUser, len(roles))
+	ids := make([]string, len(roles))
+	for i, role := range roles {
+		var id string
+		email := fmt.Sprintf("%s-%s-%d-%s@example.com", prefix, role, i, suffix)
+		require.NoError(t, database.DB.GetContext(context.Background(), &id, `INSERT INTO users (email, role) VALUES ($1, $2) RETURNING id`, email, role))
+		users[i] = &models.AuthUser{ID: id, Email: email, Role: role}
+		ids[i] = id
+	}
+	t.Cleanup(func() {
+		database.DB.ExecContext(context.Background(), `DELETE FROM users WHERE id = ANY($1)`, pq.Array(ids))
+	})
+	return users
+}
+
+// serve runs a handler as user and returns the response
+func serve(handler gin.HandlerFunc, user *models.AuthUser, method, target, body string, params ...gin.Param) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, target, strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = params
+	c.Set("user", user)
+	handler(c)
+	return w
+}
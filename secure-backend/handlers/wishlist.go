@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/money"
+	"secure-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// wishlistAlertRequest holds the price alert settings of a wishlist item. With price_alert
+// set, the buyer is notified when the price drops; target_price limits alerts to drops
+// reaching that price.
+type wishlistAlertRequest struct {
+	PriceAlert  bool          `json:"price_alert"`
+	TargetPrice *money.Amount `json:"target_price" binding:"omitempty,gt=0"`
+}
+
+// GetWishlist lists the user's wishlist with product details and availability
+func GetWishlist(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	items, err := database.GetWishlist(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve wishlist"})
+		return
+	}
+
+	for i := range items {
+		items[i].CheckAvailability()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items": items,
+		"count": len(items),
+	})
+}
+
+// AddToWishlist adds a published product to the user's wishlist. Products that are out of
+// stock can be wishlisted. Adding a product again replaces its price alert settings.
+func AddToWishlist(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request struct {
+		ProductID string `json:"product_id" binding:"required"`
+		wishlistAlertRequest
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	product, err := database.GetProductByID(c.Request.Context(), request.ProductID)
+	if err == sql.ErrNoRows || (err == nil && product.Status != "published") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify product"})
+		return
+	}
+
+	item, err := database.AddToWishlist(c.Request.Context(), user.ID, product.ID, request.PriceAlert, request.TargetPrice)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add to wishlist"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, item)
+}
+
+// UpdateWishlistItem changes the price alert settings of a wishlist item
+func UpdateWishlistItem(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request wishlistAlertRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	item, err := database.UpdateWishlistItem(c.Request.Context(), sanitizedIDParam(c), user.ID, request.PriceAlert, request.TargetPrice)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wishlist item not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update wishlist item"})
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+// RemoveFromWishlist deletes a wishlist item
+func RemoveFromWishlist(c *gin.Context) {
+	user, err := utils.GetAuthUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = database.RemoveFromWishlist(c.Request.Context(), sanitizedIDParam(c), user.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wishlist item not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove wishlist item"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Wishlist item removed successfully"})
+}
@@ -0,0 +1,139 @@
+//go:build e2e
+
+// Wishlist tests against a real PostgreSQL database (with database/schema.sql applied):
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run TestWishlist ./handlers
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWishlist(t *testing.T) {
+	users := createTestUsers(t, "wishlist", "seller", "buyer", "buyer")
+	seller, buyer, other := users[0], users[1], users[2]
+	ctx := context.Background()
+
+	product := func(name string, price float64, stock int, status string) string {
+		var id string
+		require.NoError(t, database.DB.GetContext(ctx, &id, `
+			INSERT INTO products (name, price, stock, status, seller_id) VALUES ($1, $2, $3, $4, $5) RETURNING id
+		`, name, price, stock, status, seller.ID))
+		return id
+	}
+	teapot := product("Wishlist teapot", 20, 3, "published")
+	soldOut := product("Wishlist cups", 8, 0, "published")
+	draft := product("Wishlist saucer", 4, 3, "draft")
+
+	add := func(body string) (*models.WishlistItem, int) {
+		t.Helper()
+		w := serve(AddToWishlist, buyer, http.MethodPost, "/api/wishlist", body)
+		if w.Code != http.StatusCreated {
+			return nil, w.Code
+		}
+		var item models.WishlistItem
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &item))
+		return &item, w.Code
+	}
+	update := func(user *models.AuthUser, itemID, body string) (*models.WishlistItem, int) {
+		t.Helper()
+		w := serve(UpdateWishlistItem, user, http.MethodPut, "/api/wishlist/"+itemID, body, gin.Param{Key: "id", Value: itemID})
+		if w.Code != http.StatusOK {
+			return nil, w.Code
+		}
+		var item models.WishlistItem
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &item))
+		return &item, w.Code
+	}
+	list := func(user *models.AuthUser) []models.WishlistItemWithProduct {
+		t.Helper()
+		w := serve(GetWishlist, user, http.MethodGet, "/api/wishlist", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var response struct {
+			Items []models.WishlistItemWithProduct `json:"items"`
+			Count int                              `json:"count"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Equal(t, len(response.Items), response.Count)
+		return response.Items
+	}
+
+	// Alerts measure drops from the price at the time the product was added
+	item, code := add(fmt.Sprintf(`{"product_id":%q,"price_alert":true}`, teapot))
+	require.Equal(t, http.StatusCreated, code)
+	assert.True(t, item.PriceAlert)
+	assert.Nil(t, item.TargetPrice)
+	assert.Equal(t, money.FromFloat(20), item.AlertPrice)
+
+	// Adding again replaces the alert settings of the same item
+	again, code := add(fmt.Sprintf(`{"product_id":%q,"price_alert":true,"target_price":"15.50"}`, teapot))
+	require.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, item.ID, again.ID)
+	require.NotNil(t, again.TargetPrice)
+	assert.Equal(t, money.FromFloat(15.5), *again.TargetPrice)
+
+	// Only published products can be wishlisted, but they may be out of stock
+	cups, code := add(fmt.Sprintf(`{"product_id":%q}`, soldOut))
+	require.Equal(t, http.StatusCreated, code)
+	assert.False(t, cups.PriceAlert)
+	for name, tc := range map[string]struct {
+		body string
+		code int
+	}{
+		"draft":      {fmt.Sprintf(`{"product_id":%q}`, draft), http.StatusNotFound},
+		"unknown":    {fmt.Sprintf(`{"product_id":%q}`, uuid.NewString()), http.StatusNotFound},
+		"zero price": {fmt.Sprintf(`{"product_id":%q,"target_price":0}`, teapot), http.StatusBadRequest},
+		"no product": {`{"price_alert":true}`, http.StatusBadRequest},
+	} {
+		_, code := add(tc.body)
+		assert.Equal(t, tc.code, code, name)
+	}
+
+	items := list(buyer)
+	require.Len(t, items, 2)
+	assert.Equal(t, cups.ID, items[0].ID, "newest first")
+	assert.Equal(t, models.CartItemUnavailable, items[0].Availability)
+	assert.Equal(t, "Wishlist teapot", items[1].Product.Name)
+	assert.Equal(t, models.CartItemAvailable, items[1].Availability)
+	assert.Empty(t, list(other))
+
+	// Turning an alert back on measures drops from the current price
+	_, code = update(other, item.ID, `{"price_alert":false}`)
+	assert.Equal(t, http.StatusNotFound, code)
+	updated, code := update(buyer, item.ID, `{"price_alert":false}`)
+	require.Equal(t, http.StatusOK, code)
+	assert.False(t, updated.PriceAlert)
+	assert.Nil(t, updated.TargetPrice)
+	_, err := database.DB.ExecContext(ctx, `UPDATE products SET price = 18 WHERE id = $1`, teapot)
+	require.NoError(t, err)
+	updated, code = update(buyer, item.ID, `{"price_alert":true,"target_price":12}`)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, money.FromFloat(18), updated.AlertPrice)
+	updated, code = update(buyer, item.ID, `{"price_alert":true}`)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, money.FromFloat(18), updated.AlertPrice, "an alert that stays on keeps its price")
+	_, code = update(buyer, item.ID, `{"target_price":-1}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// Only the owner can remove an item
+	remove := func(user *models.AuthUser, itemID string) int {
+		return serve(RemoveFromWishlist, user, http.MethodDelete, "/api/wishlist/"+itemID, "", gin.Param{Key: "id", Value: itemID}).Code
+	}
+	assert.Equal(t, http.StatusNotFound, remove(other, item.ID))
+	assert.Equal(t, http.StatusOK, remove(buyer, item.ID))
+	assert.Equal(t, http.StatusNotFound, remove(buyer, item.ID))
+	require.Len(t, list(buyer), 1)
+}
@@ -0,0 +1,42 @@
+package idgen
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Generator creates unique identifiers. Code that assigns IDs takes a Generator
+// instead of calling uuid.New directly so tests can predict them.
+type Generator interface {
+	NewID() string
+}
+
+// uuidGenerator creates random (v4) UUIDs
+type uuidGenerator struct{}
+
+// NewID returns a new random UUID
+func (uuidGenerator) NewID() string {
+	return uuid.New().String()
+}
+
+// UUID returns a generator of random UUIDs
+func UUID() Generator {
+	return uuidGenerator{}
+}
+
+// Sequence generates predictable, valid UUIDs for tests:
+// 00000000-0000-0000-0000-000000000001, ...0002, and so on
+type Sequence struct {
+	mu   sync.Mutex
+	next int64
+}
+
+// NewID returns the next UUID in the sequence
+func (s *Sequence) NewID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next++
+	return fmt.Sprintf("00000000-0000-0000-0000-%012d", s.next)
+}
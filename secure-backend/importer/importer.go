@@ -0,0 +1,285 @@
+// Package importer parses seller product files (CSV) using a column mapping, so files
+// exported from other shops or spreadsheets in any language and number format can be read.
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"secure-backend/models"
+	"secure-backend/money"
+	"strconv"
+	"strings"
+)
+
+// Product fields a file column can be mapped to
+const (
+	FieldName        = "name"
+	FieldDescription = "description"
+	FieldPrice       = "price"
+	FieldStock       = "stock"
+	FieldImage       = "image"
+	FieldImageAlt    = "image_alt"
+)
+
+// fields lists the mappable product fields; name and price must be mapped
+var fields = map[string]bool{
+	FieldName: true, FieldDescription: true, FieldPrice: true,
+	FieldStock: true, FieldImage: true, FieldImageAlt: true,
+}
+
+// ErrNoRows is returned when a file has a header but no data rows
+var ErrNoRows = errors.New("file has no rows")
+
+// Row is one parsed data row of a file. Line is its line number in the file (the header is
+// line 1); Errors lists the values that couldn't be parsed or break product rules.
+type Row struct {
+	Line    int                `json:"line"`
+	Product models.ImportedRow `json:"product"`
+	Errors  []string           `json:"errors,omitempty"`
+}
+
+// Preview holds the first rows of a file parsed with a mapping
+type Preview struct {
+	Headers  []string `json:"headers"`
+	Unmapped []string `json:"unmapped"` // columns of the file the mapping ignores
+	Rows     []Row    `json:"rows"`
+	Valid    int      `json:"valid"`
+	Invalid  int      `json:"invalid"`
+}
+
+// Validate checks that a mapping names known fields, maps name and price, maps each field
+// once and uses supported separators
+func Validate(m *models.ImportMapping) error {
+	mapped := map[string]bool{}
+	for column, field := range m.Columns {
+		if strings.TrimSpace(column) == "" {
+			return errors.New("mapped column names can't be empty")
+		}
+		if !fields[field] {
+			return fmt.Errorf("unknown field %q for column %q", field, column)
+		}
+		if mapped[field] {
+			return fmt.Errorf("field %q is mapped more than once", field)
+		}
+		mapped[field] = true
+	}
+	if !mapped[FieldName] || !mapped[FieldPrice] {
+		return errors.New("columns must be mapped to name and price")
+	}
+
+	switch m.Delimiter {
+	case "", ",", ";", "\t", "|":
+	default:
+		return errors.New("delimiter must be one of , ; | or a tab")
+	}
+	switch m.DecimalSeparator {
+	case "", ".", ",":
+	default:
+		return errors.New("decimal_separator must be . or ,")
+	}
+	switch m.ThousandsSeparator {
+	case "", ",", ".", " ", "'":
+	default:
+		return errors.New("thousands_separator must be empty or one of , . ' or a space")
+	}
+	if m.ThousandsSeparator != "" && m.ThousandsSeparator == decimalSeparator(m) {
+		return errors.New("thousands_separator and decimal_separator must differ")
+	}
+	if len(m.CurrencySymbol) > 5 {
+		return errors.New("currency_symbol must be at most 5 characters")
+	}
+	return nil
+}
+
+// ParsePreview parses the header and up to limit data rows of a CSV file with the mapping.
+// It fails if the file can't be read or lacks a mapped column; problems with single
+// values are reported per row.
+func ParsePreview(r io.Reader, m *models.ImportMapping, limit int) (*Preview, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = ','
+	if m.Delimiter != "" {
+		reader.Comma = rune(m.Delimiter[0])
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	headers, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("file is empty")
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	if len(headers) > 0 {
+		headers[0] = strings.TrimPrefix(headers[0], "\ufeff") // spreadsheet byte order mark
+	}
+
+	// Columns are matched case-insensitively, ignoring surrounding spaces
+	fieldIndex := map[string]int{}
+	preview := &Preview{Headers: headers, Unmapped: []string{}, Rows: []Row{}}
+	for i, header := range headers {
+		field, ok := mappedField(m, header)
+		if !ok {
+			preview.Unmapped = append(preview.Unmapped, header)
+			continue
+		}
+		fieldIndex[field] = i
+	}
+	for column, field := range m.Columns {
+		if _, ok := fieldIndex[field]; !ok {
+			return nil, fmt.Errorf("column %q is missing from the file", column)
+		}
+	}
+
+	for len(preview.Rows) < limit {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read line %d: %w", line, err)
+		}
+
+		row := parseRow(record, fieldIndex, m)
+		row.Line = line
+		if len(row.Errors) == 0 {
+			preview.Valid++
+		} else {
+			preview.Invalid++
+		}
+		preview.Rows = append(preview.Rows, row)
+	}
+	if len(preview.Rows) == 0 {
+		return nil, ErrNoRows
+	}
+
+	return preview, nil
+}
+
+// mappedField returns the field a file column is mapped to
+func mappedField(m *models.ImportMapping, header string) (string, bool) {
+	header = strings.TrimSpace(header)
+	for column, field := range m.Columns {
+		if strings.EqualFold(strings.TrimSpace(column), header) {
+			return field, true
+		}
+	}
+	return "", false
+}
+
+// parseRow converts the mapped values of a record and checks them against product rules
+func parseRow(record []string, fieldIndex map[string]int, m *models.ImportMapping) Row {
+	var row Row
+	value := func(field string) string {
+		i, ok := fieldIndex[field]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	row.Product.Name = value(FieldName)
+	row.Product.Description = value(FieldDescription)
+	row.Product.Image = value(FieldImage)
+	row.Product.ImageAlt = value(FieldImageAlt)
+
+	if row.Product.Name == "" {
+		row.Errors = append(row.Errors, "name is required")
+	} else if len([]rune(row.Product.Name)) > 255 {
+		row.Errors = append(row.Errors, "name must be at most 255 characters")
+	}
+
+	price, err := ParseAmount(value(FieldPrice), m)
+	switch {
+	case err != nil:
+		row.Errors = append(row.Errors, fmt.Sprintf("price: %v", err))
+	case price <= 0:
+		row.Errors = append(row.Errors, "price must be greater than 0")
+	default:
+		row.Product.Price = price
+	}
+
+	if raw := value(FieldStock); raw != "" {
+		stock, err := parseInteger(raw, m)
+		switch {
+		case err != nil:
+			row.Errors = append(row.Errors, fmt.Sprintf("stock: %v", err))
+		case stock < 0:
+			row.Errors = append(row.Errors, "stock can't be negative")
+		default:
+			row.Product.Stock = stock
+		}
+	}
+
+	return row
+}
+
+// ParseAmount parses a money amount written with the mapping's currency symbol and
+// separators ("1.234,50 €" with decimal separator "," and thousands separator ".")
+func ParseAmount(raw string, m *models.ImportMapping) (money.Amount, error) {
+	if m.CurrencySymbol != "" {
+		raw = strings.ReplaceAll(raw, m.CurrencySymbol, "")
+	}
+	number, err := normalizeNumber(raw, m)
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("%q is not a number", raw)
+	}
+	amount, err := money.Parse(number)
+	if err != nil {
+		return 0, fmt.Errorf("%q has more than 2 decimals", raw)
+	}
+	return amount, nil
+}
+
+// parseInteger parses a whole number written with the mapping's thousands separator
+func parseInteger(raw string, m *models.ImportMapping) (int, error) {
+	number, err := normalizeNumber(raw, m)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(number)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a whole number", raw)
+	}
+	return n, nil
+}
+
+// normalizeNumber removes spaces and thousands separators and turns the decimal separator into "."
+func normalizeNumber(raw string, m *models.ImportMapping) (string, error) {
+	raw = strings.Map(func(r rune) rune {
+		if r == '\u00a0' || r == '\u202f' { // non-breaking spaces used as thousands separators
+			return ' '
+		}
+		return r
+	}, raw)
+	if m.ThousandsSeparator != "" {
+		raw = strings.ReplaceAll(raw, m.ThousandsSeparator, "")
+	}
+	raw = strings.ReplaceAll(raw, " ", "")
+	if raw == "" {
+		return "", errors.New("value is required")
+	}
+	if decimal := decimalSeparator(m); decimal != "." {
+		if strings.Contains(raw, ".") {
+			return "", fmt.Errorf("%q uses . but the decimal separator is %s", raw, decimal)
+		}
+		raw = strings.ReplaceAll(raw, decimal, ".")
+	}
+	return raw, nil
+}
+
+// decimalSeparator returns the mapping's decimal separator ("." by default)
+func decimalSeparator(m *models.ImportMapping) string {
+	if m.DecimalSeparator == "" {
+		return "."
+	}
+	return m.DecimalSeparator
+}
@@ -0,0 +1,123 @@
+package importer
+
+import (
+	"secure-backend/models"
+	"secure-backend/money"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	valid := models.ImportMapping{Columns: map[string]string{"Titre": FieldName, "Prix": FieldPrice}}
+	assert.NoError(t, Validate(&valid))
+
+	cases := map[string]models.ImportMapping{
+		"unknown field":   {Columns: map[string]string{"Titre": FieldName, "Prix": FieldPrice, "Poids": "weight"}},
+		"price unmapped":  {Columns: map[string]string{"Titre": FieldName}},
+		"mapped twice":    {Columns: map[string]string{"Titre": FieldName, "Nom": FieldName, "Prix": FieldPrice}},
+		"same separators": {Columns: valid.Columns, DecimalSeparator: ",", ThousandsSeparator: ","},
+		"bad delimiter":   {Columns: valid.Columns, Delimiter: "x"},
+	}
+	for name, mapping := range cases {
+		assert.Error(t, Validate(&mapping), name)
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	french := &models.ImportMapping{DecimalSeparator: ",", ThousandsSeparator: " ", CurrencySymbol: "€"}
+	amount, err := ParseAmount("1 234,50 €", french)
+	require.NoError(t, err)
+	assert.Equal(t, money.Amount(123450), amount)
+
+	amount, err = ParseAmount("1 234,5", french)
+	require.NoError(t, err)
+	assert.Equal(t, money.Amount(123450), amount)
+
+	_, err = ParseAmount("12.50", french) // a dot where a comma is expected is ambiguous
+	assert.Error(t, err)
+
+	us := &models.ImportMapping{ThousandsSeparator: ",", CurrencySymbol: "$"}
+	amount, err = ParseAmount("$1,999.99", us)
+	require.NoError(t, err)
+	assert.Equal(t, money.Amount(199999), amount)
+
+	_, err = ParseAmount("9.999", us)
+	assert.Error(t, err)
+	_, err = ParseAmount("", us)
+	assert.Error(t, err)
+}
+
+func TestParsePreview(t *testing.T) {
+	mapping := &models.ImportMapping{
+		Columns:          map[string]string{"Titre": FieldName, "Prix": FieldPrice, "Quantité": FieldStock},
+		Delimiter:        ";",
+		DecimalSeparator: ",",
+		CurrencySymbol:   "€",
+	}
+	file := "\ufefftitre;Prix;quantité;Couleur\n" +
+		"Lampe;12,50 €;3;rouge\n" +
+		";4,00;1;bleu\n" +
+		"Chaise;gratuit;-2;vert\n" +
+		"Table;99;1;noir\n"
+
+	preview, err := ParsePreview(strings.NewReader(file), mapping, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Couleur"}, preview.Unmapped)
+	require.Len(t, preview.Rows, 3)
+	assert.Equal(t, 1, preview.Valid)
+	assert.Equal(t, 2, preview.Invalid)
+
+	assert.Equal(t, 2, preview.Rows[0].Line)
+	assert.Equal(t, models.ImportedRow{Name: "Lampe", Price: 1250, Stock: 3}, preview.Rows[0].Product)
+	assert.Equal(t, []string{"name is required"}, preview.Rows[1].Errors)
+	assert.Len(t, preview.Rows[2].Errors, 2) // price and stock
+
+	_, err = ParsePreview(strings.NewReader("Name;Price\n"), &models.ImportMapping{
+		Columns: map[string]string{"Titre": FieldName, "Prix": FieldPrice}, Delimiter: ";",
+	}, 10)
+	assert.ErrorContains(t, err, "missing from the file")
+
+	_, err = ParsePreview(strings.NewReader("Titre;Prix;Quantité\n"), mapping, 10)
+	assert.ErrorIs(t, err, ErrNoRows)
+}
+
+func TestParseInventory(t *testing.T) {
+	file := "\ufeffSKU,ID,Slug,Stock,Price\n" +
+		"A1,00000000-0000-4000-8000-00000000AB01,,12,19.90\n" +
+		"A2,,walnut-board,,34\n" +
+		"A3,not-an-id,,-1,10\n" +
+		"A4,,walnut-board,5,\n" +
+		"A5,,mug,,\n" +
+		"A6,,mug,3,1.234\n"
+
+	updates, invalid, err := ParseInventory(strings.NewReader(file), 100)
+	require.NoError(t, err)
+
+	require.Len(t, updates, 2)
+	assert.Equal(t, 2, updates[0].Line)
+	assert.Equal(t, "00000000-0000-4000-8000-00000000ab01", updates[0].ProductID)
+	assert.Equal(t, 12, *updates[0].Stock)
+	assert.Equal(t, money.Amount(1990), *updates[0].Price)
+	assert.Equal(t, "walnut-board", updates[1].Slug)
+	assert.Nil(t, updates[1].Stock)
+	assert.Equal(t, money.Amount(3400), *updates[1].Price)
+
+	problems := map[int]string{}
+	for _, result := range invalid {
+		assert.Equal(t, models.InventoryInvalid, result.Outcome)
+		problems[result.Line] = result.Error
+	}
+	assert.Len(t, problems, 4)
+	assert.Equal(t, "id must be a product ID; stock can't be negative", problems[4])
+	assert.Equal(t, "product is already listed on line 3", problems[5])
+	assert.Equal(t, "stock or price is required", problems[6])
+	assert.Contains(t, problems[7], "more than 2 decimals")
+
+	_, _, err = ParseInventory(strings.NewReader("id,name\nx,y\n"), 100)
+	assert.EqualError(t, err, "file needs a stock or price column")
+	_, _, err = ParseInventory(strings.NewReader("id,stock\n"+strings.Repeat("x,1\n", 3)), 2)
+	assert.EqualError(t, err, "file has more than 2 rows")
+}
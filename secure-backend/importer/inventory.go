@@ -0,0 +1,171 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"secure-backend/models"
+	"secure-backend/money"
+	"strings"
+)
+
+// Columns of an inventory file
+const (
+	InventoryID    = "id"
+	InventorySlug  = "slug"
+	InventoryStock = "stock"
+	InventoryPrice = "price"
+)
+
+// maxInventoryPrice is the largest price products.price (DECIMAL(10,2)) holds
+const maxInventoryPrice money.Amount = 9999999999
+
+// productIDPattern matches a product ID (a UUID, lowercased)
+var productIDPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// inventoryFormat is the fixed number format of inventory files: "." decimals, no
+// thousands separator or currency symbol
+var inventoryFormat = &models.ImportMapping{}
+
+// ParseInventory reads an inventory file: a comma-separated CSV whose header names an id or
+// slug column and a stock and/or price column, in any order and case. Other columns are
+// ignored. It returns the updates of the valid rows and a result for each invalid one, and
+// fails if the file can't be read, lacks the columns or has more than maxRows rows.
+func ParseInventory(r io.Reader, maxRows int) ([]models.InventoryUpdate, []models.InventoryResult, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	headers, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, errors.New("file is empty")
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	if len(headers) > 0 {
+		headers[0] = strings.TrimPrefix(headers[0], "\ufeff") // spreadsheet byte order mark
+	}
+
+	columns := map[string]int{}
+	for i, header := range headers {
+		switch column := strings.ToLower(strings.TrimSpace(header)); column {
+		case InventoryID, InventorySlug, InventoryStock, InventoryPrice:
+			if _, ok := columns[column]; ok {
+				return nil, nil, fmt.Errorf("column %q appears more than once", column)
+			}
+			columns[column] = i
+		}
+	}
+	_, hasID := columns[InventoryID]
+	_, hasSlug := columns[InventorySlug]
+	_, hasStock := columns[InventoryStock]
+	_, hasPrice := columns[InventoryPrice]
+	if !hasID && !hasSlug {
+		return nil, nil, errors.New("file needs an id or slug column")
+	}
+	if !hasStock && !hasPrice {
+		return nil, nil, errors.New("file needs a stock or price column")
+	}
+
+	updates := []models.InventoryUpdate{}
+	invalid := []models.InventoryResult{}
+	seen := map[string]int{} // line of each product ID or slug
+	rows := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read line %d: %w", line, err)
+		}
+		if rows++; rows > maxRows {
+			return nil, nil, fmt.Errorf("file has more than %d rows", maxRows)
+		}
+
+		update, problems := parseInventoryRow(record, columns)
+		update.Line = line
+		key := "id:" + update.ProductID
+		if update.ProductID == "" {
+			key = "slug:" + update.Slug
+		}
+		if first, ok := seen[key]; ok && len(problems) == 0 {
+			problems = append(problems, fmt.Sprintf("product is already listed on line %d", first))
+		}
+		if len(problems) > 0 {
+			invalid = append(invalid, models.InventoryResult{
+				Line: line, Outcome: models.InventoryInvalid, ProductID: update.ProductID, Slug: update.Slug,
+				Error: strings.Join(problems, "; "),
+			})
+			continue
+		}
+		seen[key] = line
+		updates = append(updates, update)
+	}
+	if rows == 0 {
+		return nil, nil, ErrNoRows
+	}
+
+	return updates, invalid, nil
+}
+
+// parseInventoryRow reads the product reference, stock and price of a record. Empty stock or
+// price cells keep the current value.
+func parseInventoryRow(record []string, columns map[string]int) (models.InventoryUpdate, []string) {
+	var update models.InventoryUpdate
+	var problems []string
+	value := func(column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	if id := value(InventoryID); id != "" {
+		update.ProductID = strings.ToLower(id)
+		if !productIDPattern.MatchString(update.ProductID) {
+			problems = append(problems, "id must be a product ID")
+		}
+	} else if update.Slug = value(InventorySlug); update.Slug == "" {
+		problems = append(problems, "id or slug is required")
+	}
+
+	if raw := value(InventoryStock); raw != "" {
+		stock, err := parseInteger(raw, inventoryFormat)
+		switch {
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("stock: %v", err))
+		case stock < 0:
+			problems = append(problems, "stock can't be negative")
+		case stock > math.MaxInt32:
+			problems = append(problems, "stock is too large")
+		default:
+			update.Stock = &stock
+		}
+	}
+
+	if raw := value(InventoryPrice); raw != "" {
+		price, err := ParseAmount(raw, inventoryFormat)
+		switch {
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("price: %v", err))
+		case price <= 0:
+			problems = append(problems, "price must be greater than 0")
+		case price > maxInventoryPrice:
+			problems = append(problems, "price is too large")
+		default:
+			update.Price = &price
+		}
+	}
+
+	if len(problems) == 0 && update.Stock == nil && update.Price == nil {
+		problems = append(problems, "stock or price is required")
+	}
+	return update, problems
+}
@@ -0,0 +1,173 @@
+package invoices
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"secure-backend/models"
+	"secure-backend/money"
+	"secure-backend/pdftext"
+	"strconv"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// Issuer is the business printed as the seller of record on invoices
+type Issuer struct {
+	Name    string
+	Address string
+	TaxID   string
+}
+
+// IssuerFromEnv reads the invoice issuer from INVOICE_ISSUER_NAME, INVOICE_ISSUER_ADDRESS and INVOICE_ISSUER_TAX_ID
+func IssuerFromEnv() Issuer {
+	issuer := Issuer{
+		Name:    os.Getenv("INVOICE_ISSUER_NAME"),
+		Address: os.Getenv("INVOICE_ISSUER_ADDRESS"),
+		TaxID:   os.Getenv("INVOICE_ISSUER_TAX_ID"),
+	}
+	if issuer.Name == "" {
+		issuer.Name = "SecureShop"
+	}
+	return issuer
+}
+
+// TaxRate returns the tax rate included in prices (INVOICE_TAX_RATE, e.g. "0.2"), default 0
+func TaxRate() float64 {
+	value := os.Getenv("INVOICE_TAX_RATE")
+	if value == "" {
+		return 0
+	}
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate < 0 || rate >= 1 {
+		log.Printf("Invalid INVOICE_TAX_RATE %q, using 0", value)
+		return 0
+	}
+	return rate
+}
+
+// TaxJurisdiction returns the jurisdiction INVOICE_TAX_RATE is collected for (TAX_JURISDICTION,
+// e.g. "GB" or "US-CA"), recorded on each order's tax lines for tax reports
+func TaxJurisdiction() string {
+	if value := strings.TrimSpace(os.Getenv("TAX_JURISDICTION")); value != "" {
+		return strings.ToUpper(value)
+	}
+	return models.DefaultTaxJurisdiction
+}
+
+// Document holds everything printed on an invoice
+type Document struct {
+	Invoice    *models.Invoice
+	Order      *models.Order
+	BuyerEmail string
+	Lines      []models.InvoiceLine
+	Issuer     Issuer
+	Currency   string
+}
+
+// Totals splits the tax-inclusive order total into net amount and tax
+func (d *Document) Totals() (net, tax, gross money.Amount) {
+	net, tax = models.SplitIncludedTax(d.Order.TotalAmount, d.Invoice.TaxRate)
+	return net, tax, d.Order.TotalAmount
+}
+
+// Render writes the invoice as a PDF
+func Render(w io.Writer, doc Document) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle("Invoice "+doc.Invoice.Reference(), true)
+	pdf.SetMargins(20, 20, 20)
+	pdf.AddPage()
+
+	tr := pdftext.Translator(pdf)
+	price := func(amount money.Amount) string {
+		return amount.String() + " " + strings.ToUpper(doc.Currency)
+	}
+
+	// Header: issuer on the left, invoice details on the right
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(100, 10, tr(doc.Issuer.Name), "", 0, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.CellFormat(70, 10, "INVOICE", "", 1, "R", false, 0, "")
+
+	pdf.SetFont("Helvetica", "", 10)
+	issuerLines := strings.Split(doc.Issuer.Address, "\n")
+	if doc.Issuer.TaxID != "" {
+		issuerLines = append(issuerLines, "Tax ID: "+doc.Issuer.TaxID)
+	}
+	details := []string{
+		"Invoice no: " + doc.Invoice.Reference(),
+		"Date: " + doc.Invoice.IssuedAt.Format("2006-01-02"),
+		"Order: " + doc.Order.ID,
+	}
+	for i := 0; i < len(issuerLines) || i < len(details); i++ {
+		left, right := "", ""
+		if i < len(issuerLines) {
+			left = issuerLines[i]
+		}
+		if i < len(details) {
+			right = details[i]
+		}
+		pdf.CellFormat(90, 5, tr(left), "", 0, "L", false, 0, "")
+		pdf.CellFormat(80, 5, tr(right), "", 1, "R", false, 0, "")
+	}
+
+	// Bill to
+	pdf.Ln(8)
+	pdf.SetFont("Helvetica", "B", 11)
+	pdf.CellFormat(0, 6, "Bill to", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(0, 5, tr(doc.BuyerEmail), "", 1, "L", false, 0, "")
+	if doc.Order.ShippingAddress != "" {
+		pdf.MultiCell(0, 5, tr(doc.Order.ShippingAddress), "", "L", false)
+	}
+
+	// Line items
+	pdf.Ln(8)
+	widths := []float64{60, 45, 15, 25, 25}
+	headers := []string{"Item", "Sold by", "Qty", "Unit price", "Total"}
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.SetFillColor(235, 235, 235)
+	for i, header := range headers {
+		align := "L"
+		if i >= 2 {
+			align = "R"
+		}
+		pdf.CellFormat(widths[i], 7, header, "B", 0, align, true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Helvetica", "", 9)
+	for _, line := range doc.Lines {
+		pdf.CellFormat(widths[0], 6, tr(pdftext.Truncate(line.ProductName, 40)), "", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[1], 6, tr(pdftext.Truncate(line.SellerEmail, 30)), "", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[2], 6, strconv.Itoa(line.Quantity), "", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[3], 6, price(line.UnitPrice), "", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[4], 6, price(line.TotalPrice), "", 1, "R", false, 0, "")
+	}
+
+	// Totals
+	net, tax, gross := doc.Totals()
+	pdf.Ln(4)
+	totals := [][2]string{
+		{"Subtotal (excl. tax)", price(net)},
+		{fmt.Sprintf("Tax (%.2f%%)", doc.Invoice.TaxRate*100), price(tax)},
+		{"Total", price(gross)},
+	}
+	for i, row := range totals {
+		if i == len(totals)-1 {
+			pdf.SetFont("Helvetica", "B", 10)
+		}
+		pdf.CellFormat(120, 6, row[0], "", 0, "R", false, 0, "")
+		pdf.CellFormat(50, 6, row[1], "", 1, "R", false, 0, "")
+	}
+
+	if doc.Order.Status == "refunded" || doc.Order.Status == "cancelled" {
+		pdf.Ln(6)
+		pdf.SetFont("Helvetica", "I", 9)
+		pdf.CellFormat(0, 5, "This order has been "+doc.Order.Status+".", "", 1, "L", false, 0, "")
+	}
+
+	return pdf.Output(w)
+}
@@ -0,0 +1,35 @@
+package invoices
+
+import (
+	"bytes"
+	"secure-backend/models"
+	"secure-backend/money"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderInvoice(t *testing.T) {
+	doc := Document{
+		Invoice:    &models.Invoice{Number: 42, TaxRate: 0.2, IssuedAt: time.Now()},
+		Order:      &models.Order{ID: "order-1", Status: "paid", TotalAmount: 12000, ShippingAddress: "1 Main St"},
+		BuyerEmail: "buyer@example.com",
+		Lines: []models.InvoiceLine{
+			{ProductName: "Café crème mug", SellerEmail: "seller@example.com", Quantity: 2, UnitPrice: 6000, TotalPrice: 12000},
+		},
+		Issuer:   Issuer{Name: "SecureShop", Address: "1 Market Square\nSpringfield", TaxID: "GB123"},
+		Currency: "usd",
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, Render(&out, doc))
+	assert.True(t, bytes.HasPrefix(out.Bytes(), []byte("%PDF")))
+	assert.Equal(t, "INV-000042", doc.Invoice.Reference())
+
+	net, tax, gross := doc.Totals()
+	assert.Equal(t, money.Amount(10000), net)
+	assert.Equal(t, money.Amount(2000), tax)
+	assert.Equal(t, money.Amount(12000), gross)
+}
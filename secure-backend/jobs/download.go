@@ -0,0 +1,40 @@
+package jobs
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"secure-backend/models"
+	"secure-backend/tokens"
+	"strings"
+	"time"
+)
+
+// ErrInvalidDownloadLink is returned for download links with a bad or expired signature
+var ErrInvalidDownloadLink = errors.New("invalid or expired download link")
+
+// DownloadURL returns a signed link to a completed job's result that is valid until the result expires.
+// Links are absolute when PUBLIC_API_URL is set.
+func DownloadURL(job *models.Job) string {
+	if job.Status != StatusCompleted || job.ResultPath == "" || job.ResultExpiresAt == nil {
+		return ""
+	}
+
+	token, err := tokens.Sign(tokens.PurposeJobDownload, job.ID, *job.ResultExpiresAt)
+	if err != nil {
+		return ""
+	}
+	query := url.Values{}
+	query.Set("token", token)
+
+	base := strings.TrimSuffix(os.Getenv("PUBLIC_API_URL"), "/")
+	return base + "/api/jobs/" + job.ID + "/download?" + query.Encode()
+}
+
+// VerifyDownload checks the expiry and signature of a download link token
+func VerifyDownload(jobID, token string, now time.Time) error {
+	if err := tokens.Verify(token, tokens.PurposeJobDownload, jobID, now); err != nil {
+		return ErrInvalidDownloadLink
+	}
+	return nil
+}
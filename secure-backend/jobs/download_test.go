@@ -0,0 +1,33 @@
+package jobs
+
+import (
+	"net/url"
+	"secure-backend/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadURLRoundTrip(t *testing.T) {
+	t.Setenv("EXPORT_SIGNING_SECRET", "test-secret")
+	expires := time.Now().Add(time.Hour)
+	job := &models.Job{ID: "job-1", Status: StatusCompleted, ResultPath: "/tmp/job-1-orders.csv", ResultExpiresAt: &expires}
+
+	link, err := url.Parse(DownloadURL(job))
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(link.Path, "/api/jobs/job-1/download"))
+
+	token := link.Query().Get("token")
+	assert.NoError(t, VerifyDownload("job-1", token, time.Now()))
+
+	// Token is bound to the job and the expiry
+	assert.ErrorIs(t, VerifyDownload("job-2", token, time.Now()), ErrInvalidDownloadLink)
+	assert.ErrorIs(t, VerifyDownload("job-1", token, expires.Add(time.Second)), ErrInvalidDownloadLink)
+	assert.ErrorIs(t, VerifyDownload("job-1", token+"x", time.Now()), ErrInvalidDownloadLink)
+
+	// No link until the job has a result
+	assert.Empty(t, DownloadURL(&models.Job{ID: "job-3", Status: StatusRunning}))
+}
@@ -0,0 +1,218 @@
+package jobs
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"secure-backend/database"
+	"secure-backend/models"
+	"strconv"
+	"time"
+)
+
+// Export job types
+const (
+	TypeOrdersExport  = "orders_export"
+	TypeWarehouseDump = "warehouse_dump"
+	TypeGDPRBundle    = "gdpr_bundle"
+)
+
+// OrdersExportParams selects which orders an orders export contains
+type OrdersExportParams struct {
+	Scope string `json:"scope"` // database.OrderExportScope*
+}
+
+// dataset is one file of a zipped export, filled by a constant query
+type dataset struct {
+	name  string
+	query string
+}
+
+// warehouseTables are dumped in full by the warehouse export, one JSON Lines file per table.
+// Secrets such as password hashes and device tokens are left out.
+var warehouseTables = []dataset{
+	{"users.jsonl", `SELECT id, email, role, created_at, updated_at FROM users`},
+	{"products.jsonl", `SELECT * FROM products`},
+	{"orders.jsonl", `SELECT * FROM orders`},
+	{"order_items.jsonl", `SELECT * FROM order_items`},
+	{"order_status_history.jsonl", `SELECT * FROM order_status_history`},
+	{"payments.jsonl", `SELECT * FROM payments`},
+	{"order_tax_lines.jsonl", `SELECT * FROM order_tax_lines`},
+	{"stock_reservations.jsonl", `SELECT * FROM stock_reservations`},
+	{"cart_events.jsonl", `SELECT * FROM cart_events`},
+}
+
+// gdprDatasets hold everything stored about a user ($1), for data subject access requests
+var gdprDatasets = []dataset{
+	{"profile.json", `SELECT id, email, role, created_at, updated_at FROM users WHERE id = $1`},
+	{"orders.json", `SELECT * FROM orders WHERE buyer_id = $1 ORDER BY created_at`},
+	{"order_items.json", `
+		SELECT oi.* FROM order_items oi JOIN orders o ON oi.order_id = o.id
+		WHERE o.buyer_id = $1 ORDER BY oi.created_at`},
+	{"order_status_history.json", `
+		SELECT h.* FROM order_status_history h JOIN orders o ON h.order_id = o.id
+		WHERE o.buyer_id = $1 ORDER BY h.created_at`},
+	{"payments.json", `
+		SELECT pay.* FROM payments pay JOIN orders o ON pay.order_id = o.id
+		WHERE o.buyer_id = $1 ORDER BY pay.created_at`},
+	{"cart_items.json", `SELECT * FROM cart_items WHERE user_id = $1 ORDER BY created_at`},
+	{"cart_events.json", `SELECT * FROM cart_events WHERE user_id = $1 ORDER BY created_at`},
+	{"products.json", `SELECT * FROM products WHERE seller_id = $1 ORDER BY created_at`},
+	{"device_tokens.json", `SELECT * FROM device_tokens WHERE user_id = $1 ORDER BY created_at`},
+	{"client_errors.json", `SELECT * FROM client_errors WHERE user_id = $1 ORDER BY created_at`},
+	{"consent.json", `
+		SELECT id, analytics, marketing, policy_version, created_at FROM consent_records
+		WHERE user_id = $1 ORDER BY created_at`},
+	{"jobs.json", `
+		SELECT id, type, status, params, created_at, completed_at FROM jobs
+		WHERE user_id = $1 ORDER BY created_at`},
+}
+
+func init() {
+	Register(TypeOrdersExport, Definition{
+		Description: "orders export",
+		FileName:    "orders.csv",
+		ContentType: "text/csv",
+		Run:         runOrdersExport,
+	})
+	Register(TypeWarehouseDump, Definition{
+		Description: "data warehouse dump",
+		FileName:    "warehouse.zip",
+		ContentType: "application/zip",
+		Run:         runWarehouseDump,
+	})
+	Register(TypeGDPRBundle, Definition{
+		Description: "personal data export",
+		FileName:    "personal-data.zip",
+		ContentType: "application/zip",
+		Run:         runGDPRBundle,
+	})
+}
+
+// runOrdersExport writes order items as CSV
+func runOrdersExport(ctx context.Context, job *models.Job, w *os.File, p *Progress) error {
+	var params OrdersExportParams
+	if err := json.Unmarshal(job.Params, &params); err != nil {
+		return err
+	}
+
+	total, err := database.CountOrderExportRows(ctx, params.Scope, job.UserID)
+	if err != nil {
+		return err
+	}
+	p.SetTotal(total)
+
+	out := csv.NewWriter(w)
+	out.Write([]string{
+		"order_id", "ordered_at", "order_status", "buyer_id", "client_platform",
+		"product_id", "product_name", "seller_id", "quantity", "unit_price", "total_price", "fulfillment_status",
+	})
+
+	err = database.ForEachOrderExportRow(ctx, params.Scope, job.UserID, func(row *models.OrderExportRow) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		out.Write([]string{
+			row.OrderID, row.OrderedAt.UTC().Format(time.RFC3339), row.OrderStatus, row.BuyerID, row.ClientPlatform,
+			row.ProductID, row.ProductName, row.SellerID, strconv.Itoa(row.Quantity),
+			row.UnitPrice.String(), row.TotalPrice.String(),
+			row.FulfillmentStatus,
+		})
+		return p.Add(1)
+	})
+	if err != nil {
+		return err
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// runWarehouseDump writes every warehouse table as JSON Lines into a zip archive
+func runWarehouseDump(ctx context.Context, job *models.Job, w *os.File, p *Progress) error {
+	p.SetTotal(len(warehouseTables))
+	archive := zip.NewWriter(w)
+
+	for _, table := range warehouseTables {
+		entry, err := archive.Create(table.name)
+		if err != nil {
+			return err
+		}
+
+		encoder := json.NewEncoder(entry)
+		err = database.ForEachRow(ctx, table.query, nil, func(row map[string]interface{}) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return encoder.Encode(row)
+		})
+		if err != nil {
+			return fmt.Errorf("dumping %s: %w", table.name, err)
+		}
+
+		if err := p.Add(1); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// runGDPRBundle writes all of the requesting user's data as JSON files into a zip archive
+func runGDPRBundle(ctx context.Context, job *models.Job, w *os.File, p *Progress) error {
+	p.SetTotal(len(gdprDatasets))
+	archive := zip.NewWriter(w)
+
+	for _, set := range gdprDatasets {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		entry, err := archive.Create(set.name)
+		if err != nil {
+			return err
+		}
+		if err := writeJSONArray(ctx, entry, set.query, job.UserID); err != nil {
+			return fmt.Errorf("exporting %s: %w", set.name, err)
+		}
+
+		if err := p.Add(1); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// writeJSONArray streams the rows of a query for the user as a JSON array
+func writeJSONArray(ctx context.Context, w io.Writer, query, userID string) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+
+	first := true
+	err := database.ForEachRow(ctx, query, []interface{}{userID}, func(row map[string]interface{}) error {
+		data, err := json.Marshal(row)
+		if err != nil {
+			return err
+		}
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+		_, err = w.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "]")
+	return err
+}
@@ -0,0 +1,432 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"secure-backend/clock"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/notifications"
+	"secure-backend/outbound"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Job statuses
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+const (
+	// pollInterval is how often idle workers look for queued jobs created by other instances
+	pollInterval = 5 * time.Second
+	// heartbeatInterval is the longest a running job goes without touching its row
+	heartbeatInterval = 30 * time.Second
+	// staleJobTimeout is how long a running job may go without a heartbeat before it is requeued
+	staleJobTimeout = 10 * time.Minute
+	// maintenanceInterval is how often stale jobs and expired results are cleaned up
+	maintenanceInterval = 5 * time.Minute
+	// ResultTTL is how long a job result can be downloaded
+	ResultTTL = 24 * time.Hour
+	// defaultWorkers is the number of jobs run concurrently per instance
+	defaultWorkers = 2
+)
+
+var (
+	// ErrUnknownType is returned when enqueueing a job type with no registered definition
+	ErrUnknownType = errors.New("unknown job type")
+	// ErrCancelled is returned by Progress.Add once the job has been cancelled
+	ErrCancelled = errors.New("job was cancelled")
+	// ErrNotCancellable is returned when cancelling a job that already finished
+	ErrNotCancellable = errors.New("job already finished")
+	// ErrNotRetryable is returned when retrying a job that did not fail or get cancelled
+	ErrNotRetryable = errors.New("only failed or cancelled jobs can be retried")
+)
+
+// Definition describes how to run one type of job. Run writes the job result to w
+// and reports progress through p. System jobs set Task instead: they have no result file
+// and their owner isn't notified when they complete or fail.
+type Definition struct {
+	Description string // human readable name used in notifications, e.g. "orders export"
+	FileName    string // download file name of the result
+	ContentType string
+	Run         func(ctx context.Context, job *models.Job, w *os.File, p *Progress) error
+	Task        func(ctx context.Context, job *models.Job) error
+}
+
+var (
+	// clk times result expiry, stale job detection and progress heartbeats; replaced in tests
+	clk clock.Clock = clock.System()
+
+	registryMu  sync.RWMutex
+	definitions = make(map[string]Definition)
+
+	// wake nudges an idle worker when a job is enqueued on this instance
+	wake = make(chan struct{}, 1)
+
+	// running holds the cancel functions of jobs running on this instance
+	runningMu sync.Mutex
+	running   = make(map[string]context.CancelFunc)
+)
+
+// SetClock replaces the clock used by the job runner
+func SetClock(c clock.Clock) {
+	clk = c
+}
+
+// Register makes a job type available to Enqueue
+func Register(jobType string, def Definition) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	definitions[jobType] = def
+}
+
+// lookup returns the definition of a job type
+func lookup(jobType string) (Definition, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	def, ok := definitions[jobType]
+	return def, ok
+}
+
+// Enqueue creates a queued job for the user; params are stored as JSON and passed to the job
+func Enqueue(ctx context.Context, userID, jobType string, params interface{}) (*models.Job, error) {
+	return Schedule(ctx, userID, jobType, params, time.Time{})
+}
+
+// Schedule creates a job for the user that no worker claims before runAt
+// (right away if runAt is zero). Due jobs are picked up within pollInterval.
+func Schedule(ctx context.Context, userID, jobType string, params interface{}, runAt time.Time) (*models.Job, error) {
+	if _, ok := lookup(jobType); !ok {
+		return nil, ErrUnknownType
+	}
+
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &models.Job{UserID: userID, Type: jobType, Params: data, RunAt: runAt}
+	if err := database.CreateJob(ctx, job); err != nil {
+		return nil, err
+	}
+
+	notifyWorkers()
+	return job, nil
+}
+
+// notifyWorkers wakes an idle worker without blocking
+func notifyWorkers() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// Cancel cancels a queued or running job. A job running on this instance is interrupted
+// immediately; one running elsewhere stops at its next progress update.
+func Cancel(ctx context.Context, jobID string) (*models.Job, error) {
+	job, err := database.CancelJob(ctx, jobID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotCancellable
+	} else if err != nil {
+		return nil, err
+	}
+
+	runningMu.Lock()
+	if cancel, ok := running[jobID]; ok {
+		cancel()
+	}
+	runningMu.Unlock()
+
+	return job, nil
+}
+
+// Retry puts a failed or cancelled job back in the queue
+func Retry(ctx context.Context, jobID string) (*models.Job, error) {
+	job, err := database.RetryJob(ctx, jobID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotRetryable
+	} else if err != nil {
+		return nil, err
+	}
+
+	notifyWorkers()
+	return job, nil
+}
+
+// Workers returns the number of job workers to run, configurable via JOB_WORKERS
+func Workers() int {
+	if value := os.Getenv("JOB_WORKERS"); value != "" {
+		if workers, err := strconv.Atoi(value); err == nil && workers > 0 {
+			return workers
+		}
+		log.Printf("Invalid JOB_WORKERS %q, using %d", value, defaultWorkers)
+	}
+	return defaultWorkers
+}
+
+// ResultContentType returns the content type of a job type's result file
+func ResultContentType(jobType string) string {
+	if def, ok := lookup(jobType); ok && def.ContentType != "" {
+		return def.ContentType
+	}
+	return "application/octet-stream"
+}
+
+// resultDir returns the directory job results are written to (EXPORT_DIR, default a temp dir)
+func resultDir() string {
+	if dir := os.Getenv("EXPORT_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "secureshop-exports")
+}
+
+// Start launches the job workers and periodic cleanup until ctx is cancelled
+func Start(ctx context.Context, workers int) {
+	if err := os.MkdirAll(resultDir(), 0o700); err != nil {
+		log.Printf("Failed to create export directory: %v", err)
+	}
+
+	for i := 0; i < workers; i++ {
+		go worker(ctx)
+	}
+
+	go func() {
+		ticker := time.NewTicker(maintenanceInterval)
+		defer ticker.Stop()
+
+		for {
+			maintain(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// worker runs queued jobs one at a time
+func worker(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			job, err := database.ClaimNextJob(ctx)
+			if err == sql.ErrNoRows {
+				break
+			} else if err != nil {
+				log.Printf("Failed to claim job: %v", err)
+				break
+			}
+			run(ctx, job)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// run executes a claimed job and records its outcome
+func run(parent context.Context, job *models.Job) {
+	// Outbound calls made by the job are logged with its ID and attempt
+	parent = outbound.WithRetry(outbound.WithCorrelationID(parent, "job:"+job.ID), job.Attempts-1)
+	ctx, cancel := context.WithCancel(parent)
+	runningMu.Lock()
+	running[job.ID] = cancel
+	runningMu.Unlock()
+	defer func() {
+		runningMu.Lock()
+		delete(running, job.ID)
+		runningMu.Unlock()
+		cancel()
+	}()
+
+	def, ok := lookup(job.Type)
+	if !ok {
+		fail(parent, job, ErrUnknownType)
+		return
+	}
+	if def.Task != nil {
+		runTask(parent, ctx, job, def)
+		return
+	}
+
+	path := filepath.Join(resultDir(), job.ID+"-"+def.FileName)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		fail(parent, job, err)
+		return
+	}
+
+	err = def.Run(ctx, job, file, &Progress{jobID: job.ID})
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		switch {
+		case parent.Err() != nil:
+			// Server shutting down; the job is requeued once it goes stale
+			log.Printf("Job %s interrupted by shutdown", job.ID)
+		case errors.Is(err, ErrCancelled) || ctx.Err() != nil:
+			log.Printf("Job %s cancelled", job.ID)
+		default:
+			fail(parent, job, err)
+		}
+		return
+	}
+
+	expiresAt := clk.Now().Add(ResultTTL)
+	err = database.CompleteJob(parent, job.ID, path, expiresAt)
+	if err == sql.ErrNoRows {
+		// Cancelled just before it finished
+		os.Remove(path)
+		return
+	} else if err != nil {
+		log.Printf("Failed to complete job %s: %v", job.ID, err)
+		return
+	}
+
+	job.Status = StatusCompleted
+	job.ResultPath = path
+	job.ResultExpiresAt = &expiresAt
+	notifications.Dispatch(notifications.Notification{
+		UserID: job.UserID,
+		Type:   notifications.TypeJobCompleted,
+		Title:  "Your export is ready",
+		Body:   fmt.Sprintf("Your %s is ready to download for the next %s.", def.Description, ResultTTL),
+		Data: map[string]string{
+			"job_id":       job.ID,
+			"download_url": DownloadURL(job),
+		},
+	})
+}
+
+// runTask executes a system job, which has no result file and no owner notifications
+func runTask(parent, ctx context.Context, job *models.Job, def Definition) {
+	if err := def.Task(ctx, job); err != nil {
+		switch {
+		case parent.Err() != nil:
+			log.Printf("Job %s interrupted by shutdown", job.ID)
+		case errors.Is(err, ErrCancelled) || ctx.Err() != nil:
+			log.Printf("Job %s cancelled", job.ID)
+		default:
+			fail(parent, job, err)
+		}
+		return
+	}
+
+	if err := database.CompleteJob(parent, job.ID, "", clk.Now()); err != nil && err != sql.ErrNoRows {
+		log.Printf("Failed to complete job %s: %v", job.ID, err)
+	}
+}
+
+// fail records a job failure and notifies its owner (except for system jobs, whose
+// failures only go to the dead-letter queue)
+func fail(ctx context.Context, job *models.Job, err error) {
+	description := "export"
+	def, ok := lookup(job.Type)
+	if ok {
+		description = def.Description
+	}
+
+	log.Printf("Job %s (%s) failed: %v", job.ID, job.Type, err)
+	if dbErr := database.FailJob(ctx, job.ID, err.Error()); dbErr != nil {
+		log.Printf("Failed to record failure of job %s: %v", job.ID, dbErr)
+	}
+	if ok && def.Task != nil {
+		return
+	}
+
+	notifications.Dispatch(notifications.Notification{
+		UserID: job.UserID,
+		Type:   notifications.TypeJobFailed,
+		Title:  "Export failed",
+		Body:   fmt.Sprintf("Your %s could not be generated. Please try again.", description),
+		Data:   map[string]string{"job_id": job.ID},
+	})
+}
+
+// maintain requeues jobs orphaned by a crashed instance and deletes expired result files
+func maintain(ctx context.Context) {
+	if requeued, err := database.RequeueStaleJobs(ctx, clk.Now().Add(-staleJobTimeout)); err != nil {
+		log.Printf("Failed to requeue stale jobs: %v", err)
+	} else if requeued > 0 {
+		log.Printf("Requeued %d interrupted jobs", requeued)
+	}
+
+	expired, err := database.GetExpiredJobResults(ctx, clk.Now(), 100)
+	if err != nil {
+		log.Printf("Failed to load expired job results: %v", err)
+		return
+	}
+	for _, job := range expired {
+		if err := os.Remove(job.ResultPath); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to delete result of job %s: %v", job.ID, err)
+			continue
+		}
+		if err := database.ClearJobResult(ctx, job.ID); err != nil {
+			log.Printf("Failed to clear result of job %s: %v", job.ID, err)
+		}
+	}
+}
+
+// Progress reports how far a running job has got
+type Progress struct {
+	jobID    string
+	total    int
+	done     int
+	reported int
+	lastSave time.Time
+}
+
+// SetTotal sets the number of units of work the job will perform
+func (p *Progress) SetTotal(total int) {
+	p.total = total
+}
+
+// Add marks n more units of work as done, saving the percentage when it changes
+func (p *Progress) Add(n int) error {
+	p.done += n
+	if p.total <= 0 {
+		return nil
+	}
+
+	percent := p.done * 100 / p.total
+	if percent > 99 {
+		// 100 is reserved for completed jobs
+		percent = 99
+	}
+	if percent == p.reported && clk.Now().Sub(p.lastSave) < heartbeatInterval {
+		return nil
+	}
+
+	p.reported = percent
+	p.lastSave = clk.Now()
+	stillRunning, err := database.UpdateJobProgress(context.Background(), p.jobID, percent)
+	if err != nil {
+		return err
+	}
+	if !stillRunning {
+		return ErrCancelled
+	}
+	return nil
+}
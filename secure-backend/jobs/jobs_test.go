@@ -0,0 +1,36 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnqueueUnknownType(t *testing.T) {
+	_, err := Enqueue(context.Background(), "user-1", "no_such_export", nil)
+	assert.ErrorIs(t, err, ErrUnknownType)
+}
+
+func TestResultContentType(t *testing.T) {
+	assert.Equal(t, "text/csv", ResultContentType(TypeOrdersExport))
+	assert.Equal(t, "application/zip", ResultContentType(TypeGDPRBundle))
+	assert.Equal(t, "application/octet-stream", ResultContentType("no_such_export"))
+}
+
+func TestWorkers(t *testing.T) {
+	t.Setenv("JOB_WORKERS", "")
+	assert.Equal(t, defaultWorkers, Workers())
+	t.Setenv("JOB_WORKERS", "8")
+	assert.Equal(t, 8, Workers())
+	for _, invalid := range []string{"0", "-1", "many"} {
+		t.Setenv("JOB_WORKERS", invalid)
+		assert.Equal(t, defaultWorkers, Workers(), invalid)
+	}
+}
+
+func TestProgressWithoutTotal(t *testing.T) {
+	// Jobs that don't know their total never save progress
+	p := &Progress{jobID: "job-1"}
+	assert.NoError(t, p.Add(1000))
+}
@@ -0,0 +1,286 @@
+//go:build e2e
+
+// Job runner tests against a real PostgreSQL database (with database/schema.sql applied):
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run 'TestRun|TestMaintain|TestCancel|TestGetJobs' ./jobs
+package jobs
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"secure-backend/clock"
+	"secure-backend/database"
+	"secure-backend/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// initJobsTest connects to the test database, writes results to a temporary directory and
+// seeds a user owning the test's jobs
+func initJobsTest(t *testing.T) string {
+	t.Helper()
+	userID := createTestUsers(t, "jobs", "buyer")[0]
+	t.Setenv("EXPORT_DIR", t.TempDir())
+	t.Setenv("EXPORT_SIGNING_SECRET", "test-secret")
+
+	t.Cleanup(func() {
+		database.DB.ExecContext(context.Background(), `DELETE FROM jobs WHERE user_id = $1`, userID)
+		database.DB.ExecContext(context.Background(), `DELETE FROM orders WHERE buyer_id = $1`, userID)
+	})
+	return userID
+}
+
+// enqueueAndRun queues a job and runs it on this goroutine the way a worker would once it
+// claimed it
+func enqueueAndRun(t *testing.T, userID, jobType string, params interface{}) *models.Job {
+	t.Helper()
+	ctx := context.Background()
+	job, err := Enqueue(ctx, userID, jobType, params)
+	require.NoError(t, err)
+	assert.Equal(t, StatusQueued, job.Status)
+
+	_, err = database.DB.ExecContext(ctx, `
+		UPDATE jobs SET status = 'running', started_at = now(), attempts = attempts + 1 WHERE id = $1
+	`, job.ID)
+	require.NoError(t, err)
+	job, err = database.GetJobByID(ctx, job.ID)
+	require.NoError(t, err)
+
+	run(ctx, job)
+	job, err = database.GetJobByID(ctx, job.ID)
+	require.NoError(t, err)
+	return job
+}
+
+func TestRunOrdersExport(t *testing.T) {
+	buyerID := initJobsTest(t)
+	ctx := context.Background()
+
+	sellerID := createTestUsers(t, "jobs", "seller")[0]
+	t.Cleanup(func() {
+		database.DB.ExecContext(context.Background(), `DELETE FROM orders WHERE buyer_id = $1`, buyerID)
+	})
+
+	var productID, orderID string
+	require.NoError(t, database.DB.GetContext(ctx, &productID, `
+		INSERT INTO products (name, price, stock, status, seller_id) VALUES ('Exported lamp', 10, 5, 'published', $1) RETURNING id
+	`, sellerID))
+	require.NoError(t, database.DB.GetContext(ctx, &orderID, `
+		INSERT INTO orders (buyer_id, status, total_amount) VALUES ($1, 'paid', 20) RETURNING id
+	`, buyerID))
+	_, err := database.DB.ExecContext(ctx, `
+		INSERT INTO order_items (order_id, product_id, quantity, unit_price, total_price) VALUES ($1, $2, 2, 10, 20)
+	`, orderID, productID)
+	require.NoError(t, err)
+
+	job := enqueueAndRun(t, buyerID, TypeOrdersExport, OrdersExportParams{Scope: database.OrderExportScopeBuyer})
+	assert.Equal(t, StatusCompleted, job.Status)
+	assert.Equal(t, 100, job.Progress)
+	require.NotNil(t, job.ResultExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(ResultTTL), *job.ResultExpiresAt, time.Minute)
+	assert.NotEmpty(t, DownloadURL(job))
+
+	file, err := os.Open(job.ResultPath)
+	require.NoError(t, err)
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 2, "a header and the buyer's one order item")
+	assert.Equal(t, "order_id", rows[0][0])
+	assert.Equal(t, orderID, rows[1][0])
+	assert.Equal(t, productID, rows[1][5])
+	assert.Equal(t, "2", rows[1][8])
+
+	// A scope the export doesn't know fails the job
+	job = enqueueAndRun(t, buyerID, TypeOrdersExport, OrdersExportParams{Scope: "everyone"})
+	assert.Equal(t, StatusFailed, job.Status)
+	assert.Contains(t, job.Error, "unknown order export scope")
+	assert.Empty(t, job.ResultPath)
+}
+
+func TestRunRecordsFailureAndCancellation(t *testing.T) {
+	userID := initJobsTest(t)
+
+	Register("test_failing", Definition{
+		Description: "failing export",
+		FileName:    "failing.csv",
+		Run: func(ctx context.Context, job *models.Job, w *os.File, p *Progress) error {
+			w.WriteString("partial")
+			return errors.New("warehouse unreachable")
+		},
+	})
+	Register("test_cancelled", Definition{
+		Description: "cancelled export",
+		FileName:    "cancelled.csv",
+		Run: func(ctx context.Context, job *models.Job, w *os.File, p *Progress) error {
+			if _, err := Cancel(ctx, job.ID); err != nil {
+				return err
+			}
+			p.SetTotal(2)
+			return p.Add(1)
+		},
+	})
+
+	job := enqueueAndRun(t, userID, "test_failing", nil)
+	assert.Equal(t, StatusFailed, job.Status)
+	assert.Equal(t, "warehouse unreachable", job.Error)
+	assert.Empty(t, job.ResultPath, "the partial result is removed")
+	entries, err := os.ReadDir(resultDir())
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	// A job cancelled while it runs stops at its next progress update
+	job = enqueueAndRun(t, userID, "test_cancelled", nil)
+	assert.Equal(t, StatusCancelled, job.Status)
+	assert.Empty(t, job.Error)
+	entries, err = os.ReadDir(resultDir())
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	// Finished jobs can't be cancelled
+	_, err = Cancel(context.Background(), job.ID)
+	assert.ErrorIs(t, err, ErrNotCancellable)
+}
+
+func TestMaintainDeletesExpiredResults(t *testing.T) {
+	userID := initJobsTest(t)
+	mock := clock.NewMock(time.Now())
+	SetClock(mock)
+	defer SetClock(clock.System())
+
+	Register("test_result", Definition{
+		Description: "test export",
+		FileName:    "result.txt",
+		Run: func(ctx context.Context, job *models.Job, w *os.File, p *Progress) error {
+			_, err := w.WriteString("result")
+			return err
+		},
+	})
+	job := enqueueAndRun(t, userID, "test_result", nil)
+	require.Equal(t, StatusCompleted, job.Status)
+
+	// Results are kept until they expire
+	maintain(context.Background())
+	assert.FileExists(t, job.ResultPath)
+
+	mock.Advance(ResultTTL + time.Minute)
+	maintain(context.Background())
+	assert.NoFileExists(t, job.ResultPath)
+	job, err := database.GetJobByID(context.Background(), job.ID)
+	require.NoError(t, err)
+	assert.Empty(t, job.ResultPath)
+	assert.Empty(t, DownloadURL(job), "an expired result has no download link")
+}
+
+func TestCancelAndRetry(t *testing.T) {
+	userID := initJobsTest(t)
+	ctx := context.Background()
+	Register("test_idle", Definition{
+		Description: "idle export",
+		FileName:    "idle.txt",
+		Run:         func(ctx context.Context, job *models.Job, w *os.File, p *Progress) error { return nil },
+	})
+
+	// Queued jobs can be cancelled and retried
+	job, err := Enqueue(ctx, userID, "test_idle", nil)
+	require.NoError(t, err)
+	_, err = Retry(ctx, job.ID)
+	assert.ErrorIs(t, err, ErrNotRetryable, "a queued job can't be retried")
+	cancelled, err := Cancel(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCancelled, cancelled.Status)
+	assert.NotNil(t, cancelled.CompletedAt)
+	_, err = Cancel(ctx, job.ID)
+	assert.ErrorIs(t, err, ErrNotCancellable)
+	retried, err := Retry(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusQueued, retried.Status)
+	assert.Nil(t, retried.CompletedAt)
+
+	// A failed job is retried from scratch but keeps its attempt count
+	Register("test_flaky", Definition{
+		Description: "flaky export",
+		FileName:    "flaky.txt",
+		Run: func(ctx context.Context, job *models.Job, w *os.File, p *Progress) error {
+			if job.Attempts == 1 {
+				return errors.New("temporary failure")
+			}
+			return nil
+		},
+	})
+	failed := enqueueAndRun(t, userID, "test_flaky", nil)
+	require.Equal(t, StatusFailed, failed.Status)
+	retried, err = Retry(ctx, failed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusQueued, retried.Status)
+	assert.Empty(t, retried.Error)
+	assert.Zero(t, retried.Progress)
+	assert.Equal(t, 1, retried.Attempts)
+
+	_, err = database.DB.ExecContext(ctx, `UPDATE jobs SET status = 'running', attempts = attempts + 1 WHERE id = $1`, retried.ID)
+	require.NoError(t, err)
+	retried, err = database.GetJobByID(ctx, retried.ID)
+	require.NoError(t, err)
+	run(ctx, retried)
+	completed, err := database.GetJobByID(ctx, retried.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCompleted, completed.Status)
+	assert.Equal(t, 2, completed.Attempts)
+
+	// Completed jobs can be neither cancelled nor retried
+	_, err = Cancel(ctx, completed.ID)
+	assert.ErrorIs(t, err, ErrNotCancellable)
+	_, err = Retry(ctx, completed.ID)
+	assert.ErrorIs(t, err, ErrNotRetryable)
+}
+
+func TestGetJobsFilters(t *testing.T) {
+	userID := initJobsTest(t)
+	otherID := initJobsTest(t)
+	ctx := context.Background()
+	Register("test_listed", Definition{
+		Description: "listed export",
+		FileName:    "listed.txt",
+		Run:         func(ctx context.Context, job *models.Job, w *os.File, p *Progress) error { return nil },
+	})
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		job, err := Enqueue(ctx, userID, "test_listed", nil)
+		require.NoError(t, err)
+		ids = append(ids, job.ID)
+	}
+	_, err := Cancel(ctx, ids[0])
+	require.NoError(t, err)
+	_, err = Enqueue(ctx, otherID, "test_listed", nil)
+	require.NoError(t, err)
+
+	list, total, err := database.GetJobs(ctx, database.JobFilter{UserID: userID}, 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 3, total, "only the user's jobs")
+	require.Len(t, list, 3)
+	assert.Equal(t, ids[2], list[0].ID, "newest first")
+
+	list, total, err = database.GetJobs(ctx, database.JobFilter{UserID: userID, Status: StatusCancelled}, 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	require.Len(t, list, 1)
+	assert.Equal(t, ids[0], list[0].ID)
+
+	_, total, err = database.GetJobs(ctx, database.JobFilter{UserID: userID, Type: TypeOrdersExport}, 10, 0)
+	require.NoError(t, err)
+	assert.Zero(t, total)
+
+	// Pages count the whole match
+	list, total, err = database.GetJobs(ctx, database.JobFilter{UserID: userID}, 2, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	require.Len(t, list, 1)
+	assert.Equal(t, ids[0], list[0].ID)
+}
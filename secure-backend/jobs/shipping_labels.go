@@ -0,0 +1,156 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"os"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/services"
+	"secure-backend/shipping"
+	"secure-backend/storage"
+)
+
+// TypeShippingLabels buys shipping labels for a batch of a seller's orders
+const TypeShippingLabels = "shipping_labels"
+
+// ShippingLabelsParams are the orders to buy labels for and where they ship from
+type ShippingLabelsParams struct {
+	OrderIDs    []string `json:"order_ids"`
+	FromAddress string   `json:"from_address"`
+	Service     string   `json:"service,omitempty"`
+}
+
+// Outcomes of an order in the label purchase report
+const (
+	labelPurchased = "purchased"
+	labelSkipped   = "skipped"
+	labelFailed    = "failed"
+)
+
+func init() {
+	Register(TypeShippingLabels, Definition{
+		Description: "shipping label purchase",
+		FileName:    "shipping-labels.csv",
+		ContentType: "text/csv",
+		Run:         runShippingLabels,
+	})
+}
+
+// runShippingLabels buys a label for each order and writes what happened to each as CSV.
+// One order failing doesn't stop the others.
+func runShippingLabels(ctx context.Context, job *models.Job, w *os.File, p *Progress) error {
+	var params ShippingLabelsParams
+	if err := json.Unmarshal(job.Params, &params); err != nil {
+		return err
+	}
+	p.SetTotal(len(params.OrderIDs))
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"order_id", "outcome", "label_id", "carrier", "service", "tracking_number", "cost", "currency", "detail"})
+
+	for _, orderID := range params.OrderIDs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		label, detail, err := buyShippingLabel(ctx, job, orderID, params)
+		switch {
+		case err != nil:
+			out.Write([]string{orderID, labelFailed, "", "", "", "", "", "", err.Error()})
+		case label == nil:
+			out.Write([]string{orderID, labelSkipped, "", "", "", "", "", "", detail})
+		default:
+			out.Write([]string{
+				orderID, labelPurchased, label.ID, label.Carrier, label.Service, label.TrackingNumber,
+				label.Cost.String(), label.Currency, detail,
+			})
+		}
+
+		if err := p.Add(1); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// buyShippingLabel buys, stores and records the label of the seller's items in one order.
+// It returns a nil label and the reason when there is nothing to buy.
+func buyShippingLabel(ctx context.Context, job *models.Job, orderID string, params ShippingLabelsParams) (*models.ShippingLabel, string, error) {
+	sellerID := job.UserID
+	if existing, err := database.GetShippingLabelForOrder(ctx, orderID, sellerID); err == nil {
+		return nil, "label " + existing.ID + " was already bought", nil
+	} else if err != sql.ErrNoRows {
+		return nil, "", err
+	}
+
+	items, err := database.GetFulfillmentItemsOfOrders(ctx, sellerID, []string{orderID})
+	if err != nil {
+		return nil, "", err
+	}
+	if len(items) == 0 {
+		return nil, "no items left to ship in a paid order", nil
+	}
+
+	var weight float64
+	itemIDs := make([]string, len(items))
+	for i, item := range items {
+		if item.WeightKg == nil {
+			return nil, "", fmt.Errorf("product %q has no weight", html.UnescapeString(item.ProductName))
+		}
+		weight += *item.WeightKg * float64(item.Quantity)
+		itemIDs[i] = item.OrderItemID
+	}
+
+	service := params.Service
+	if service == "" {
+		service = shipping.DefaultService()
+	}
+	// The key stays the same when the job is retried, so a label bought before an
+	// interruption is returned again rather than bought twice
+	bought, err := shipping.BuyLabel(ctx, shipping.LabelRequest{
+		Reference:   orderID,
+		FromAddress: html.UnescapeString(params.FromAddress),
+		ToAddress:   html.UnescapeString(items[0].ShippingAddress),
+		Service:     service,
+		Parcel:      shipping.Parcel{WeightKg: weight},
+	}, "label-"+orderID+"-"+sellerID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	pdf, err := shipping.DownloadLabel(ctx, bought)
+	if err != nil {
+		return nil, "", fmt.Errorf("label %s was bought but not downloaded: %w", bought.ID, err)
+	}
+	key := "shipping-labels/" + sellerID + "/" + orderID + ".pdf"
+	if err := storage.UploadPrivate(ctx, key, "application/pdf", pdf); err != nil {
+		return nil, "", fmt.Errorf("label %s was bought but not stored: %w", bought.ID, err)
+	}
+
+	label := &models.ShippingLabel{
+		OrderID: orderID, SellerID: sellerID, JobID: &job.ID, ProviderLabelID: bought.ID,
+		Carrier: bought.Carrier, Service: bought.Service, TrackingNumber: bought.TrackingNumber,
+		Cost: bought.Cost, Currency: bought.Currency, StorageKey: key,
+	}
+	err = database.RecordShippingLabel(ctx, label, itemIDs)
+	if errors.Is(err, database.ErrLabelExists) {
+		return nil, "label was already bought", nil
+	} else if err != nil {
+		return nil, "", fmt.Errorf("label %s was bought but not recorded: %w", bought.ID, err)
+	}
+
+	// The label is recorded either way; a failure leaves the order for an admin to mark shipped
+	if err := services.ShipOrderIfFulfilled(ctx, orderID, nil); err != nil {
+		log.Printf("Failed to mark order %s shipped: %v", orderID, err)
+	}
+	return label, "", nil
+}
@@ -0,0 +1,58 @@
+//go:build e2e
+
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"secure-backend/database"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testDBOnce sync.Once
+	testDBErr  error
+)
+
+// initTestDB connects the database package to TEST_DATABASE_URL, skipping the test if it isn't set
+func initTestDB(t *testing.T) {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	testDBOnce.Do(func() {
+		os.Setenv("DATABASE_URL", dsn)
+		testDBErr = database.InitDB()
+	})
+	if testDBErr != nil {
+		t.Fatalf("failed to connect to test database: %v", testDBErr)
+	}
+}
+
+// createTestUsers connects to the test database and creates a user per role with an email
+// unique to the run, returning their IDs. The users are deleted when the test ends; cleanups
+// registered afterwards run first, so tests can delete rows that would keep them.
+func createTestUsers(t *testing.T, prefix string, roles ...string) []string {
+	t.Helper()
+	initTestDB(t)
+
+	suffix := uuid.NewString()[:8]
+	ids := make([]string, len(roles))
+	for i, role := range roles {
+		email := fmt.Sprintf("%s-%s-%d-%s@example.com", prefix, role, i, suffix)
+		require.NoError(t, database.DB.GetContext(context.Background(), &ids[i], `INSERT INTO users (email, role) VALUES ($1, $2) RETURNING id`, email, role))
+	}
+	t.Cleanup(func() {
+		database.DB.ExecContext(context.Background(), `DELETE FROM users WHERE id = ANY($1)`, pq.Array(ids))
+	})
+	return ids
+}
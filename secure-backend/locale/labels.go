@@ -0,0 +1,116 @@
+package locale
+
+import "secure-backend/models"
+
+// Kinds of enum values with labels
+const (
+	OrderStatus         = "order_status"
+	ProductStatus       = "product_status"
+	FulfillmentStatus   = "fulfillment_status"
+	RefundStatus        = "refund_status"
+	DisputeStatus       = "dispute_status"
+	AddressChangeStatus = "address_change_status"
+)
+
+// translation is a label in each supported language
+type translation struct{ en, de, fr, es string }
+
+func (t translation) in(lang string) string {
+	switch lang {
+	case "de":
+		return t.de
+	case "fr":
+		return t.fr
+	case "es":
+		return t.es
+	default:
+		return t.en
+	}
+}
+
+// catalog holds the labels of each kind of enum value by code
+var catalog = map[string]map[string]translation{
+	OrderStatus: {
+		"pending":   {"Pending", "Ausstehend", "En attente", "Pendiente"},
+		"paid":      {"Paid", "Bezahlt", "Payée", "Pagado"},
+		"shipped":   {"Shipped", "Versandt", "Expédiée", "Enviado"},
+		"delivered": {"Delivered", "Zugestellt", "Livrée", "Entregado"},
+		"cancelled": {"Cancelled", "Storniert", "Annulée", "Cancelado"},
+		"refunded":  {"Refunded", "Erstattet", "Remboursée", "Reembolsado"},
+	},
+	ProductStatus: {
+		"draft":     {"Draft", "Entwurf", "Brouillon", "Borrador"},
+		"published": {"Published", "Veröffentlicht", "Publié", "Publicado"},
+		"archived":  {"Archived", "Archiviert", "Archivé", "Archivado"},
+	},
+	FulfillmentStatus: {
+		"pending":   {"Awaiting shipment", "Versand ausstehend", "En attente d'expédition", "Pendiente de envío"},
+		"shipped":   {"Shipped", "Versandt", "Expédié", "Enviado"},
+		"fulfilled": {"Fulfilled", "Abgeschlossen", "Traité", "Completado"},
+	},
+	RefundStatus: {
+		"pending":   {"Processing", "In Bearbeitung", "En cours", "En proceso"},
+		"succeeded": {"Refunded", "Erstattet", "Remboursé", "Reembolsado"},
+		"failed":    {"Failed", "Fehlgeschlagen", "Échoué", "Fallido"},
+	},
+	DisputeStatus: {
+		models.DisputeWarningNeedsResponse: {"Inquiry: response needed", "Anfrage: Antwort erforderlich", "Demande : réponse requise", "Consulta: se requiere respuesta"},
+		models.DisputeWarningUnderReview:   {"Inquiry under review", "Anfrage in Prüfung", "Demande en cours d'examen", "Consulta en revisión"},
+		models.DisputeWarningClosed:        {"Inquiry closed", "Anfrage geschlossen", "Demande clôturée", "Consulta cerrada"},
+		models.DisputeNeedsResponse:        {"Response needed", "Antwort erforderlich", "Réponse requise", "Se requiere respuesta"},
+		models.DisputeUnderReview:          {"Under review", "In Prüfung", "En cours d'examen", "En revisión"},
+		models.DisputeWon:                  {"Won", "Gewonnen", "Gagné", "Ganado"},
+		models.DisputeLost:                 {"Lost", "Verloren", "Perdu", "Perdido"},
+	},
+	AddressChangeStatus: {
+		models.AddressChangeApplied:   {"Applied", "Übernommen", "Appliquée", "Aplicado"},
+		models.AddressChangeRequested: {"Waiting for support", "Wartet auf Support", "En attente du support", "Esperando a soporte"},
+		models.AddressChangeApproved:  {"Approved", "Genehmigt", "Approuvée", "Aprobado"},
+		models.AddressChangeRejected:  {"Rejected", "Abgelehnt", "Refusée", "Rechazado"},
+	},
+}
+
+// Label returns the label of a code of the given kind in lang. Codes without a label are
+// returned as they are, so a status added without a translation still shows.
+func Label(lang, kind, code string) string {
+	if t, ok := catalog[kind][code]; ok {
+		return t.in(lang)
+	}
+	return code
+}
+
+// Labels returns every label in lang, by kind and code
+func Labels(lang string) map[string]map[string]string {
+	labels := make(map[string]map[string]string, len(catalog))
+	for kind, codes := range catalog {
+		labels[kind] = make(map[string]string, len(codes))
+		for code, t := range codes {
+			labels[kind][code] = t.in(lang)
+		}
+	}
+	return labels
+}
+
+// LabelOrder fills in the status labels of an order and its items
+func LabelOrder(lang string, order *models.OrderWithDetails) {
+	order.StatusLabel = Label(lang, OrderStatus, order.Status)
+	for i := range order.Items {
+		item := &order.Items[i]
+		item.FulfillmentStatusLabel = Label(lang, FulfillmentStatus, item.FulfillmentStatus)
+		item.Product.StatusLabel = Label(lang, ProductStatus, item.Product.Status)
+	}
+}
+
+// LabelProducts fills in the status labels of products
+func LabelProducts(lang string, products []models.Product) {
+	for i := range products {
+		products[i].StatusLabel = Label(lang, ProductStatus, products[i].Status)
+	}
+}
+
+// LabelRefunds fills in the status labels of refunds
+func LabelRefunds(lang string, refunds []models.Refund) {
+	for i := range refunds {
+		refunds[i].StatusLabel = Label(lang, RefundStatus, refunds[i].Status)
+	}
+}
@@ -0,0 +1,56 @@
+// Package locale picks the language of a response from Accept-Language and translates the
+// enum values the API returns (order, product, fulfillment, refund, dispute and address
+// change statuses) into display labels, so clients show the same wording without keeping
+// their own copies.
+package locale
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Default is the language of responses when the client accepts none of the supported ones
+const Default = "en"
+
+// Supported lists the languages labels are translated into
+var Supported = []string{"en", "de", "fr", "es"}
+
+// Negotiate returns the supported language the Accept-Language header prefers, matching by
+// primary subtag (de-AT matches de), or Default
+func Negotiate(acceptLanguage string) string {
+	type preference struct {
+		lang string
+		q    float64
+	}
+	var preferences []preference
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if primary == "" || q <= 0 {
+			continue
+		}
+		preferences = append(preferences, preference{primary, q})
+	}
+	sort.SliceStable(preferences, func(i, j int) bool { return preferences[i].q > preferences[j].q })
+
+	for _, p := range preferences {
+		if p.lang == "*" {
+			return Default
+		}
+		for _, lang := range Supported {
+			if p.lang == lang {
+				return lang
+			}
+		}
+	}
+	return Default
+}
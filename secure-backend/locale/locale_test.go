@@ -0,0 +1,43 @@
+package locale
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	cases := map[string]string{
+		"":                            "en",
+		"de":                          "de",
+		"de-AT,de;q=0.9,en;q=0.8":     "de",
+		"ja,fr;q=0.5":                 "fr",
+		"en;q=0.2,es;q=0.7":           "es",
+		"ja, zh-CN":                   "en",
+		"*":                           "en",
+		"fr;q=0,de;q=0.1":             "de",
+		"FR-ca":                       "fr",
+		"es;q=bogus,de;q=0.3":         "de",
+		"it;q=0.9, *;q=0.5, de;q=0.1": "en",
+	}
+	for header, want := range cases {
+		assert.Equal(t, want, Negotiate(header), "Accept-Language: %q", header)
+	}
+}
+
+func TestCatalogTranslatesEveryCode(t *testing.T) {
+	for kind, codes := range catalog {
+		for code, labels := range codes {
+			for _, lang := range Supported {
+				assert.NotEmpty(t, labels.in(lang), "%s %s has no %s label", kind, code, lang)
+			}
+		}
+	}
+}
+
+func TestLabel(t *testing.T) {
+	assert.Equal(t, "Versandt", Label("de", OrderStatus, "shipped"))
+	assert.Equal(t, "Shipped", Label("ja", OrderStatus, "shipped"), "unsupported languages fall back to English")
+	assert.Equal(t, "on_hold", Label("de", OrderStatus, "on_hold"), "unknown codes are returned as they are")
+	assert.Equal(t, "Publié", Labels("fr")[ProductStatus]["published"])
+}
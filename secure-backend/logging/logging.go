@@ -0,0 +1,63 @@
+// Package logging configures the application's structured logger. Records are JSON in
+// release mode and text otherwise, filtered by LOG_LEVEL. Lines still written with the
+// standard log package go through the same logger at info level.
+package logging
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Init installs the default logger, writing JSON to stderr when jsonOutput is set
+func Init(jsonOutput bool) {
+	slog.SetDefault(New(os.Stderr, jsonOutput, Level()))
+}
+
+// New returns a logger writing records at or above level to w
+func New(w io.Writer, jsonOutput bool, level slog.Leveler) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	if jsonOutput {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// Level returns the minimum level logged, configurable via LOG_LEVEL (debug, info, warn or
+// error; default info)
+func Level() slog.Level {
+	value := os.Getenv("LOG_LEVEL")
+	if value == "" {
+		return slog.LevelInfo
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.ToUpper(value))); err != nil {
+		log.Printf("Invalid LOG_LEVEL %q, using info", value)
+		return slog.LevelInfo
+	}
+	return level
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx carrying logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// With returns a copy of ctx whose logger adds the given fields to every record
+func With(ctx context.Context, args ...any) context.Context {
+	return WithLogger(ctx, FromContext(ctx).With(args...))
+}
+
+// FromContext returns the logger carried by ctx, with the fields of the request being
+// handled, or the default logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,35 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevel(t *testing.T) {
+	for value, want := range map[string]slog.Level{
+		"":      slog.LevelInfo,
+		"debug": slog.LevelDebug,
+		"WARN":  slog.LevelWarn,
+		"error": slog.LevelError,
+		"loud":  slog.LevelInfo,
+	} {
+		t.Setenv("LOG_LEVEL", value)
+		assert.Equal(t, want, Level(), value)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Same(t, slog.Default(), FromContext(context.Background()))
+
+	var buf bytes.Buffer
+	ctx := WithLogger(context.Background(), New(&buf, false, slog.LevelInfo))
+	ctx = With(ctx, "request_id", "req-1")
+	FromContext(ctx).Debug("hidden")
+	FromContext(ctx).Info("shown")
+	assert.NotContains(t, buf.String(), "hidden")
+	assert.Contains(t, buf.String(), "msg=shown request_id=req-1")
+}
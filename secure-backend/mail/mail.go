@@ -0,0 +1,80 @@
+// Package mail sends transactional email (such as account erasure confirmations) over SMTP.
+// It is configured with SMTP_HOST, SMTP_PORT (default 587), SMTP_USERNAME, SMTP_PASSWORD and
+// MAIL_FROM; without SMTP_HOST and MAIL_FROM nothing can be sent.
+package mail
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// defaultPort is the SMTP submission port used without SMTP_PORT
+const defaultPort = "587"
+
+// ErrNotConfigured is returned when sending without SMTP_HOST or MAIL_FROM
+var ErrNotConfigured = errors.New("email delivery is not configured")
+
+// Message is a plain-text email to one recipient
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// sendMail delivers a message; replaced in tests
+var sendMail = smtp.SendMail
+
+// Configured reports whether email can be sent
+func Configured() bool {
+	return os.Getenv("SMTP_HOST") != "" && os.Getenv("MAIL_FROM") != ""
+}
+
+// Send delivers a message through the configured SMTP server
+func Send(msg Message) error {
+	if !Configured() {
+		return ErrNotConfigured
+	}
+	host := os.Getenv("SMTP_HOST")
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = defaultPort
+	}
+	from := os.Getenv("MAIL_FROM")
+
+	var auth smtp.Auth
+	if username := os.Getenv("SMTP_USERNAME"); username != "" {
+		auth = smtp.PlainAuth("", username, os.Getenv("SMTP_PASSWORD"), host)
+	}
+
+	data, err := compose(from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	return sendMail(net.JoinHostPort(host, port), auth, from, []string{msg.To}, data)
+}
+
+// compose renders the message with its headers. Header values containing line breaks are
+// refused so user-supplied text can't inject headers.
+func compose(from string, msg Message, now time.Time) ([]byte, error) {
+	for _, value := range []string{from, msg.To, msg.Subject} {
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, errors.New("mail header contains a line break")
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", now.UTC().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String()), nil
+}
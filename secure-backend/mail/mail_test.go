@@ -0,0 +1,41 @@
+package mail
+
+import (
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSend(t *testing.T) {
+	t.Setenv("SMTP_HOST", "")
+	t.Setenv("MAIL_FROM", "")
+	assert.ErrorIs(t, Send(Message{To: "buyer@example.com"}), ErrNotConfigured)
+
+	t.Setenv("SMTP_HOST", "smtp.example.com")
+	t.Setenv("MAIL_FROM", "shop@example.com")
+	t.Setenv("SMTP_PORT", "")
+
+	var gotAddr string
+	var gotTo []string
+	var gotData []byte
+	sendMail = func(addr string, _ smtp.Auth, _ string, to []string, data []byte) error {
+		gotAddr, gotTo, gotData = addr, to, data
+		return nil
+	}
+	defer func() { sendMail = smtp.SendMail }()
+
+	require.NoError(t, Send(Message{To: "buyer@example.com", Subject: "Confirm", Body: "line 1\nline 2"}))
+	assert.Equal(t, "smtp.example.com:587", gotAddr)
+	assert.Equal(t, []string{"buyer@example.com"}, gotTo)
+	assert.Contains(t, string(gotData), "Subject: Confirm\r\n")
+	assert.True(t, strings.HasSuffix(string(gotData), "\r\n\r\nline 1\r\nline 2"))
+}
+
+func TestComposeRejectsHeaderInjection(t *testing.T) {
+	_, err := compose("shop@example.com", Message{To: "buyer@example.com", Subject: "Hi\r\nBcc: x@example.com"}, time.Now())
+	assert.Error(t, err)
+}
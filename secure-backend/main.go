@@ -7,123 +7,153 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"secure-backend/config"
 	"secure-backend/database"
-	"secure-backend/handlers"
+	"secure-backend/errorreport"
+	"secure-backend/jobs"
+	"secure-backend/logging"
 	"secure-backend/middleware"
-	"strings"
+	"secure-backend/notifications"
+	"secure-backend/payments"
+	"secure-backend/push"
+	"secure-backend/services"
+	"secure-backend/sessions"
+	"secure-backend/shipping"
+	"secure-backend/storage"
+	"secure-backend/tokens"
+	"secure-backend/tracing"
 	"syscall"
 	"time"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 )
 
 func main() {
-	// Load .env file
-	if err := godotenv.Load(); err != nil {
+	// Load .env file, remembering which settings came from it
+	if err := config.LoadFile(); err != nil {
 		log.Println("No .env file found, using default values")
 	}
 
+	// Set Gin mode, defaulting to release. Gin reads GIN_MODE before the .env file is
+	// loaded, so apply it again.
+	mode := os.Getenv("GIN_MODE")
+	if mode == "" {
+		mode = gin.ReleaseMode
+	}
+	gin.SetMode(mode)
+
+	// Structured logs, as JSON in release mode, filtered by LOG_LEVEL
+	logging.Init(gin.Mode() == gin.ReleaseMode)
+
+	// `migrate` applies schema migrations and exits instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+
+	// `seed` fills the database with demo and generated data and exits
+	if len(os.Args) > 1 && os.Args[1] == "seed" {
+		runSeed(os.Args[2:])
+		return
+	}
+
 	// Validate required environment variables
-	if os.Getenv("SUPABASE_JWT_SECRET") == "" {
-		log.Fatal("SUPABASE_JWT_SECRET environment variable is required")
+	// Supabase tokens are verified with the shared secret (HS256) and/or the project's JWKS (RS256/ES256)
+	if os.Getenv("SUPABASE_JWT_SECRET") == "" && os.Getenv("SUPABASE_URL") == "" && os.Getenv("SUPABASE_JWKS_URL") == "" {
+		log.Fatal("SUPABASE_JWT_SECRET or SUPABASE_URL environment variable is required")
 	}
 	if os.Getenv("DATABASE_URL") == "" {
 		log.Fatal("DATABASE_URL environment variable is required")
 	}
 
+	// Export OpenTelemetry traces when an OTLP endpoint is configured
+	tracing.Init()
+
+	// Report panics and 5xx responses to Sentry when SENTRY_DSN is set
+	if err := errorreport.Init(); err != nil {
+		log.Fatalf("Failed to set up error reporting: %v", err)
+	}
+
+	// Record throttled clients and JWKS failures in the security event log
+	middleware.OnSecurityEvent(services.RecordSecurityEvent)
+
 	// Initialize database connection
 	if err := database.InitDB(); err != nil {
 		log.Fatal("Failed to initialize database:", err)
 	}
 
+	// Bring the schema up to date unless migrations are a separate deploy step
+	if database.AutoMigrate() {
+		if _, err := database.Migrate(context.Background()); err != nil {
+			log.Fatal("Failed to migrate database:", err)
+		}
+	}
+
+	// Print a one-time admin bootstrap token while no admin account exists
+	if err := services.EnsureAdminBootstrap(context.Background()); err != nil {
+		log.Printf("Failed to check admin bootstrap: %v", err)
+	}
+
+	// Configure payment provider
+	payments.Init()
+
+	// Configure object storage for product images and shipping labels
+	storage.Init()
+
+	// Configure the shipping label provider
+	shipping.Init()
+
+	// Register notification delivery channels
+	if ch := push.NewChannelFromEnv(); ch != nil {
+		notifications.Register(ch)
+	}
+
+	// Release stock held by checkouts that were never paid
+	reaperCtx, stopReaper := context.WithCancel(context.Background())
+	defer stopReaper()
+
+	// Enable cookie sessions for the web storefront if configured
+	if err := sessions.Init(reaperCtx); err != nil {
+		log.Fatal("Failed to configure sessions:", err)
+	}
+
+	// Load the keys that sign download links and other internal tokens
+	// (reloaded from Vault when configured, so keys can rotate without a restart)
+	if err := tokens.Init(reaperCtx); err != nil {
+		log.Fatal("Failed to load token signing keys:", err)
+	}
+	services.StartReservationReaper(reaperCtx, time.Minute)
+	services.StartGuestCartReaper(reaperCtx, time.Hour)
+	services.StartAbandonedCartReaper(reaperCtx, time.Hour)
+	services.StartDuplicateListingScanner(reaperCtx, time.Hour)
+	services.StartAccountEraser(reaperCtx, time.Hour)
+	services.StartSecurityEventPruner(reaperCtx, time.Hour)
+	services.StartRequestAuditPruner(reaperCtx, time.Hour)
+	services.StartCatalogChangePruner(reaperCtx, time.Hour)
+	services.StartSavedSearchAlerts(reaperCtx, time.Minute)
+
+	// Take the read replica out of use while it is down or lagging (when configured)
+	database.StartReplicaMonitor(reaperCtx, 5*time.Second)
+
+	// Seed the demo accounts and catalog, and reset them every night at DEMO_RESET_HOUR
+	if services.DemoMode() {
+		services.StartDemoReset(reaperCtx, 10*time.Minute)
+	}
+
+	// Reconcile Stripe payouts against payments and refunds (when Stripe is configured)
+	payments.StartPayoutReconciler(reaperCtx, time.Hour)
+
+	// Run background jobs (exports)
+	jobs.Start(reaperCtx, jobs.Workers())
+
 	// Get port from environment variable
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
-	// Set Gin mode
-	if os.Getenv("GIN_MODE") == "" {
-		gin.SetMode(gin.ReleaseMode)
-	}
-
-	// Initialize router without default middleware
-	r := gin.New()
-
-	// Recovery middleware (must be first to handle panics)
-	r.Use(gin.Recovery())
-
-	// Request ID middleware (for tracing)
-	r.Use(middleware.RequestID())
-
-	// Request logging middleware with metrics
-	r.Use(middleware.RequestLogger())
-
-	// Error handling middleware
-	r.Use(middleware.ErrorHandler())
-
-	// Security headers
-	r.Use(middleware.SecurityHeaders())
-
-	// Request size limits (10MB)
-	r.Use(middleware.RequestSizeMiddleware(10 << 20))
-
-	// CORS middleware with environment-based configuration
-	config := cors.DefaultConfig()
-	if os.Getenv("GIN_MODE") == "release" {
-		// Production CORS settings
-		config.AllowOrigins = strings.Split(os.Getenv("ALLOWED_ORIGINS"), ",")
-	} else {
-		// Development CORS settings
-		config.AllowOrigins = []string{"http://localhost:3000", "http://localhost:5173"}
-	}
-	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization"}
-	config.AllowCredentials = true
-	r.Use(cors.New(config))
-
-	// API routes
-	api := r.Group("/api")
-	{
-		// Public endpoints (no auth required)
-		api.GET("/healthz", handlers.HealthCheck)  // Health check endpoint
-		api.GET("/metrics", handlers.BasicMetrics) // Basic metrics endpoint
-
-		// Rate limit public endpoints by IP
-		api.Use(middleware.RateLimitByIP())
-
-		// Protected routes (require Supabase Auth)
-		protected := api.Group("")
-		protected.Use(middleware.SupabaseAuthMiddleware())
-		protected.Use(middleware.RateLimitByIP()) // Rate limiting for authenticated users
-		{
-			// Product routes
-			products := protected.Group("/products")
-			{
-				products.GET("", handlers.GetProducts)          // List products (filtered by role)
-				products.POST("", handlers.CreateProduct)       // Create product (sellers only)
-				products.GET("/:id", handlers.GetProduct)       // Get single product
-				products.PUT("/:id", handlers.UpdateProduct)    // Update product (seller's own only)
-				products.DELETE("/:id", handlers.DeleteProduct) // Delete product (seller's own only)
-			}
-
-			// Cart routes
-			cart := protected.Group("/cart")
-			{
-				cart.GET("", handlers.GetCart)               // Get user's cart
-				cart.POST("", handlers.AddToCart)            // Add item to cart
-				cart.PUT("/:id", handlers.UpdateCartItem)    // Update cart item quantity
-				cart.DELETE("/:id", handlers.RemoveCartItem) // Remove cart item
-				cart.DELETE("", handlers.ClearCart)          // Clear entire cart
-				cart.GET("/count", handlers.GetCartCount)    // Get cart item count
-			}
-
-			// User routes
-			protected.GET("/user", handlers.GetUserInfo) // Get authenticated user info
-		}
-	}
+	// Build the HTTP router
+	r := setupRouter()
 
 	// Configure server with timeouts
 	srv := &http.Server{
@@ -156,5 +186,13 @@ func main() {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	// Export the spans of the last requests
+	if err := tracing.Shutdown(ctx); err != nil {
+		log.Printf("Failed to export remaining spans: %v", err)
+	}
+	if err := errorreport.Flush(ctx); err != nil {
+		log.Printf("Failed to send remaining error reports: %v", err)
+	}
+
 	log.Println("Server exited gracefully")
 }
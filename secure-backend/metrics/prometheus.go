@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PrometheusContentType is the content type of the Prometheus text exposition format
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// WritePrometheus writes the route metrics in the Prometheus text exposition format:
+// http_requests_total per route and status class, and http_request_duration_seconds as a
+// summary per route, whose quantiles cover the latest 1024 requests
+func WritePrometheus(w io.Writer) error {
+	stats := RouteMetrics()
+
+	var b strings.Builder
+	b.WriteString("# HELP http_requests_total Requests served since startup, by route and status class.\n")
+	b.WriteString("# TYPE http_requests_total counter\n")
+	for _, s := range stats {
+		for _, class := range statusClasses {
+			fmt.Fprintf(&b, "http_requests_total{method=%q,route=%q,status=%q} %d\n", s.Method, s.Route, class, s.Status[class])
+		}
+	}
+
+	b.WriteString("# HELP http_request_duration_seconds Request latency, by route.\n")
+	b.WriteString("# TYPE http_request_duration_seconds summary\n")
+	for _, s := range stats {
+		labels := fmt.Sprintf("method=%q,route=%q", s.Method, s.Route)
+		for _, q := range []struct {
+			quantile string
+			ms       float64
+		}{{"0.5", s.LatencyMs.P50}, {"0.95", s.LatencyMs.P95}, {"0.99", s.LatencyMs.P99}} {
+			fmt.Fprintf(&b, "http_request_duration_seconds{%s,quantile=%q} %g\n", labels, q.quantile, q.ms/1000)
+		}
+		fmt.Fprintf(&b, "http_request_duration_seconds_sum{%s} %g\n", labels, s.TotalLatencyMs/1000)
+		fmt.Fprintf(&b, "http_request_duration_seconds_count{%s} %d\n", labels, s.Requests)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,158 @@
+// Package metrics counts the requests the API serves per route, with their status classes
+// and latencies. RequestLogger records every request here, and both /api/metrics and the
+// Prometheus exposition read these counters.
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencySamples is how many of the latest latencies are kept per route for its percentiles
+const latencySamples = 1024
+
+// unmatchedRoute groups requests that matched no route, so probes of random paths don't add
+// a route each
+const unmatchedRoute = "unmatched"
+
+// statusClasses name the status classes counted per route
+var statusClasses = [...]string{"2xx", "3xx", "4xx", "5xx"}
+
+// LatencyPercentiles are latency percentiles in milliseconds
+type LatencyPercentiles struct {
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+}
+
+// RouteStats summarizes the requests to one route since startup
+type RouteStats struct {
+	Method    string             `json:"method"`
+	Route     string             `json:"route"` // route pattern, e.g. /api/orders/:id
+	Requests  uint64             `json:"requests"`
+	Status    map[string]uint64  `json:"status"`     // requests per status class: 2xx, 3xx, 4xx, 5xx
+	LatencyMs LatencyPercentiles `json:"latency_ms"` // over the latest 1024 requests
+	// TotalLatencyMs is the summed latency of every request, for averages over any interval
+	TotalLatencyMs float64 `json:"total_latency_ms"`
+}
+
+type routeKey struct {
+	method, route string
+}
+
+type routeCounters struct {
+	requests uint64
+	classes  [len(statusClasses)]uint64
+	latency  time.Duration // total
+	samples  []time.Duration
+	next     int // where the next sample goes once samples is full
+}
+
+var (
+	routesMu sync.Mutex
+	routes   = map[routeKey]*routeCounters{}
+)
+
+// RecordRequest counts one request to the route pattern (empty when no route matched) with
+// its status and latency
+func RecordRequest(method, route string, status int, latency time.Duration) {
+	if route == "" {
+		route = unmatchedRoute
+	}
+
+	routesMu.Lock()
+	defer routesMu.Unlock()
+
+	key := routeKey{method, route}
+	c, ok := routes[key]
+	if !ok {
+		c = &routeCounters{}
+		routes[key] = c
+	}
+	c.requests++
+	c.latency += latency
+	if class := status/100 - 2; class >= 0 && class < len(c.classes) {
+		c.classes[class]++
+	}
+	if len(c.samples) < latencySamples {
+		c.samples = append(c.samples, latency)
+	} else {
+		c.samples[c.next] = latency
+		c.next = (c.next + 1) % latencySamples
+	}
+}
+
+// RouteMetrics returns request counts, status classes and latency percentiles per route,
+// by route and method
+func RouteMetrics() []RouteStats {
+	routesMu.Lock()
+	result := make([]RouteStats, 0, len(routes))
+	samples := make([][]time.Duration, 0, len(routes))
+	for key, c := range routes {
+		s := RouteStats{
+			Method:         key.method,
+			Route:          key.route,
+			Requests:       c.requests,
+			Status:         map[string]uint64{},
+			TotalLatencyMs: float64(c.latency) / float64(time.Millisecond),
+		}
+		for i, n := range c.classes {
+			s.Status[statusClasses[i]] = n
+		}
+		result = append(result, s)
+		samples = append(samples, append([]time.Duration(nil), c.samples...))
+	}
+	routesMu.Unlock()
+
+	// Percentiles are computed on copies, so recording isn't held up by the sorting
+	for i := range result {
+		result[i].LatencyMs = percentiles(samples[i])
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Route != result[j].Route {
+			return result[i].Route < result[j].Route
+		}
+		return result[i].Method < result[j].Method
+	})
+	return result
+}
+
+// Totals are the request counts over every route since startup
+type Totals struct {
+	Requests uint64 `json:"total_requests"`
+	Errors   uint64 `json:"error_count"` // 4xx and 5xx responses
+}
+
+// RequestTotals sums the per-route counters, which are the only request counters kept
+func RequestTotals() Totals {
+	routesMu.Lock()
+	defer routesMu.Unlock()
+
+	var totals Totals
+	for _, c := range routes {
+		totals.Requests += c.requests
+		totals.Errors += c.classes[2] + c.classes[3]
+	}
+	return totals
+}
+
+// percentiles returns the nearest-rank percentiles of the latencies, sorting them in place
+func percentiles(latencies []time.Duration) LatencyPercentiles {
+	if len(latencies) == 0 {
+		return LatencyPercentiles{}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	rank := func(p int) float64 {
+		i := (len(latencies)*p+99)/100 - 1
+		return float64(latencies[i]) / float64(time.Millisecond)
+	}
+	return LatencyPercentiles{P50: rank(50), P95: rank(95), P99: rank(99)}
+}
+
+// resetRoutes forgets every route, for tests
+func resetRoutes() {
+	routesMu.Lock()
+	defer routesMu.Unlock()
+	routes = map[routeKey]*routeCounters{}
+}
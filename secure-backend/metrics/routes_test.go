@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteMetrics(t *testing.T) {
+	resetRoutes()
+	defer resetRoutes()
+
+	for i := 1; i <= 100; i++ {
+		status := 200
+		switch {
+		case i > 98:
+			status = 503
+		case i > 90:
+			status = 404
+		}
+		RecordRequest("GET", "/api/products/:id", status, time.Duration(i)*time.Millisecond)
+	}
+	RecordRequest("POST", "/api/orders", 201, 40*time.Millisecond)
+	RecordRequest("GET", "", 404, time.Millisecond)
+
+	stats := RouteMetrics()
+	require.Len(t, stats, 3)
+	assert.Equal(t, "/api/orders", stats[0].Route)
+	assert.Equal(t, "/api/products/:id", stats[1].Route)
+	assert.Equal(t, "unmatched", stats[2].Route)
+
+	product := stats[1]
+	assert.Equal(t, uint64(100), product.Requests)
+	assert.Equal(t, map[string]uint64{"2xx": 90, "3xx": 0, "4xx": 8, "5xx": 2}, product.Status)
+	assert.Equal(t, LatencyPercentiles{P50: 50, P95: 95, P99: 99}, product.LatencyMs)
+	assert.Equal(t, LatencyPercentiles{P50: 40, P95: 40, P99: 40}, stats[0].LatencyMs)
+}
+
+func TestRouteMetricsKeepLatestSamples(t *testing.T) {
+	resetRoutes()
+	defer resetRoutes()
+
+	for i := 0; i < latencySamples; i++ {
+		RecordRequest("GET", "/api/products", 200, time.Second)
+	}
+	for i := 0; i < latencySamples; i++ {
+		RecordRequest("GET", "/api/products", 200, time.Millisecond)
+	}
+
+	stats := RouteMetrics()
+	require.Len(t, stats, 1)
+	assert.Equal(t, uint64(2*latencySamples), stats[0].Requests)
+	assert.Equal(t, float64(1), stats[0].LatencyMs.P99)
+}
+
+func TestRequestTotalsUnderConcurrency(t *testing.T) {
+	resetRoutes()
+	defer resetRoutes()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				RecordRequest("GET", "/api/products", 200, time.Millisecond)
+				RecordRequest("GET", "/api/orders/:id", 404, time.Millisecond)
+				RecordRequest("POST", "/api/orders", 500, time.Millisecond)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, Totals{Requests: 2400, Errors: 1600}, RequestTotals())
+}
+
+func TestWritePrometheus(t *testing.T) {
+	resetRoutes()
+	defer resetRoutes()
+
+	RecordRequest("GET", "/api/products/:id", 200, 10*time.Millisecond)
+	RecordRequest("GET", "/api/products/:id", 404, 30*time.Millisecond)
+
+	var b strings.Builder
+	require.NoError(t, WritePrometheus(&b))
+	out := b.String()
+	assert.Contains(t, out, "# TYPE http_requests_total counter\n")
+	assert.Contains(t, out, `http_requests_total{method="GET",route="/api/products/:id",status="2xx"} 1`+"\n")
+	assert.Contains(t, out, `http_requests_total{method="GET",route="/api/products/:id",status="4xx"} 1`+"\n")
+	assert.Contains(t, out, `http_request_duration_seconds{method="GET",route="/api/products/:id",quantile="0.99"} 0.03`+"\n")
+	assert.Contains(t, out, `http_request_duration_seconds_sum{method="GET",route="/api/products/:id"} 0.04`+"\n")
+	assert.Contains(t, out, `http_request_duration_seconds_count{method="GET",route="/api/products/:id"} 2`+"\n")
+}
@@ -1,23 +1,56 @@
 package middleware
 
 import (
+	"crypto/subtle"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"secure-backend/database"
+	"secure-backend/logging"
 	"secure-backend/models"
+	"secure-backend/services"
+	"secure-backend/sessions"
+	"secure-backend/tokens"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// SupabaseAuthMiddleware validates Supabase Auth tokens and adds user info to context
+// userRole looks up the role of an authenticated user (cached, see RoleCache); benchmarks
+// replace it to skip the database
+var userRole = cachedUserRole
+
+// tokenRevokedBefore looks up the cut-off before which a user's tokens are refused (cached
+// like roles); tests replace it
+var tokenRevokedBefore = cachedTokenRevokedBefore
+
+// breakGlassUser and recordAudit load break-glass accounts and audit their requests; tests replace them
+var (
+	breakGlassUser = database.GetBreakGlassUserByID
+	recordAudit    = database.RecordAdminAudit
+)
+
+// supabaseSigningMethods are the algorithms Supabase signs access tokens with
+var supabaseSigningMethods = []string{"HS256", "RS256", "ES256"}
+
+// SupabaseAuthMiddleware validates Supabase Auth tokens and adds user info to context.
+// When cookie sessions are enabled, requests without an Authorization header may
+// authenticate with the session cookie instead. Break-glass admins authenticate with a
+// Bearer token issued by POST /api/auth/break-glass, which works while Supabase is down.
 func SupabaseAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get the Authorization header
 		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" && sessions.Enabled() {
+			if value, err := c.Cookie(sessions.CookieName); err == nil {
+				sessionAuth(c, value)
+				return
+			}
+		}
 		if authHeader == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing Authorization header"})
 			return
@@ -30,22 +63,46 @@ func SupabaseAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Get JWT secret from environment
+		// Break-glass tokens are signed by the shop itself rather than Supabase
+		if userID, err := tokens.Subject(tokenString, tokens.PurposeBreakGlass, time.Now()); err == nil {
+			breakGlassAuth(c, userID)
+			return
+		}
+
+		// Demo sessions are signed by the shop for the seeded demo accounts
+		if userID, err := tokens.Subject(tokenString, tokens.PurposeDemo, time.Now()); err == nil {
+			demoAuth(c, userID)
+			return
+		}
+
+		// Supabase signs with the shared HS256 secret (SUPABASE_JWT_SECRET) or, after migrating
+		// to asymmetric keys, with RS256/ES256 keys published in the project's JWKS
 		jwtSecret := os.Getenv("SUPABASE_JWT_SECRET")
-		if jwtSecret == "" {
-			log.Printf("SUPABASE_JWT_SECRET not set")
+		keySet := supabaseJWKS()
+		if jwtSecret == "" && keySet == nil {
+			log.Printf("Neither SUPABASE_JWT_SECRET nor SUPABASE_URL is set")
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Authentication configuration error"})
 			return
 		}
 
 		// Parse and validate the JWT
 		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Verify that the token uses the correct signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			switch token.Method.(type) {
+			case *jwt.SigningMethodHMAC:
+				if jwtSecret == "" {
+					return nil, errors.New("HMAC tokens are not accepted without SUPABASE_JWT_SECRET")
+				}
+				return []byte(jwtSecret), nil
+			case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+				if keySet == nil {
+					return nil, fmt.Errorf("no JWKS configured for %v tokens", token.Header["alg"])
+				}
+				kid, _ := token.Header["kid"].(string)
+				return keySet.Key(c.Request.Context(), kid)
+			default:
 				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 			}
-			return []byte(jwtSecret), nil
-		})
+		}, jwt.WithValidMethods(supabaseSigningMethods))
 
 		if err != nil || !token.Valid {
 			log.Printf("Invalid token: %v", err)
@@ -67,25 +124,109 @@ func SupabaseAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Get email from claims (optional)
-		email, _ := claims["email"].(string)
-
-		// Fetch user role from database
-		role, err := database.GetUserRole(userID)
+		// Refuse tokens issued before an admin revoked the user's tokens
+		var issuedAt *time.Time
+		if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+			issuedAt = &iat.Time
+		}
+		revoked, err := tokenRevoked(c.Request.Context(), userID, issuedAt)
 		if err != nil {
-			log.Printf("Error fetching user role: %v", err)
+			log.Printf("Error checking token revocation: %v", err)
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user data"})
 			return
 		}
-
-		// Create user object and store in context
-		user := &models.AuthUser{
-			ID:    userID,
-			Email: email,
-			Role:  role,
+		if revoked {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			return
 		}
 
-		c.Set("user", user)
-		c.Next()
+		// Get email from claims (optional)
+		email, _ := claims["email"].(string)
+
+		setUser(c, userID, email)
 	}
 }
+
+// sessionAuth authenticates a request by its session cookie. Requests that change state
+// must also echo the session's CSRF token, since browsers attach cookies automatically.
+func sessionAuth(c *gin.Context, cookieValue string) {
+	session, err := sessions.Open(c.Request.Context(), cookieValue)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired session"})
+		return
+	}
+
+	if !isSafeMethod(c.Request.Method) &&
+		subtle.ConstantTimeCompare([]byte(c.GetHeader(sessions.CSRFHeader)), []byte(session.CSRFToken)) != 1 {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Missing or invalid CSRF token"})
+		return
+	}
+
+	c.Set(SessionKey, session)
+	setUser(c, session.UserID, session.Email)
+}
+
+// breakGlassAuth authenticates a request made with a break-glass token. The account must
+// still be enabled, and every request is written to the admin audit log; requests that
+// can't be audited are refused.
+func breakGlassAuth(c *gin.Context, userID string) {
+	user, err := breakGlassUser(c.Request.Context(), userID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Break-glass account is disabled"})
+		return
+	}
+
+	err = recordAudit(c.Request.Context(), &models.AuditEntry{
+		ActorID:    &user.ID,
+		ActorEmail: user.Email,
+		Action:     models.AuditBreakGlassRequest,
+		Detail:     c.Request.Method + " " + c.Request.URL.Path,
+		IPAddress:  c.ClientIP(),
+	})
+	if err != nil {
+		log.Printf("Failed to audit break-glass request by %s: %v", user.Email, err)
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Audit log unavailable"})
+		return
+	}
+
+	c.Set(BreakGlassKey, true)
+	setUser(c, user.ID, user.Email)
+}
+
+// demoAuth authenticates a request made with a demo session token. Demo tokens only work
+// for the demo accounts, and only while demo mode is on.
+func demoAuth(c *gin.Context, userID string) {
+	user, ok := models.LookupDemoUser(userID)
+	if !ok || !services.DemoMode() {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+	setUser(c, user.ID, user.Email)
+}
+
+// isSafeMethod reports whether an HTTP method is read-only
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// setUser loads the user's current role and stores the authenticated user in the context
+func setUser(c *gin.Context, userID, email string) {
+	// Fetch user role (cached for ROLE_CACHE_TTL)
+	role, err := userRole(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("Error fetching user role: %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user data"})
+		return
+	}
+
+	// Create user object and store in context
+	user := &models.AuthUser{
+		ID:    userID,
+		Email: email,
+		Role:  role,
+	}
+
+	c.Set(UserKey, user)
+	c.Request = c.Request.WithContext(logging.With(c.Request.Context(), "user_id", user.ID))
+	c.Next()
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const benchJWTSecret = "bench-secret"
+
+// benchAuthRouter serves a no-op route behind the auth middleware with the role and revocation
+// lookups stubbed out
+func benchAuthRouter(b *testing.B) *gin.Engine {
+	b.Helper()
+	b.Setenv("SUPABASE_JWT_SECRET", benchJWTSecret)
+
+	lookup, revoked := userRole, tokenRevokedBefore
+	userRole = func(context.Context, string) (string, error) { return "buyer", nil }
+	tokenRevokedBefore = func(context.Context, string) (*time.Time, error) { return nil, nil }
+	b.Cleanup(func() { userRole, tokenRevokedBefore = lookup, revoked })
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", SupabaseAuthMiddleware(), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	return r
+}
+
+func benchToken(b *testing.B, secret string) string {
+	b.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":   "6f1c2b9e-3d4a-4c8e-9b1f-2a7d5e8c0f13",
+		"email": "bench@example.com",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	})
+	signed, err := token.SignedString([]byte(secret))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return signed
+}
+
+func benchmarkAuth(b *testing.B, token string, wantStatus int) {
+	r := benchAuthRouter(b)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != wantStatus {
+			b.Fatalf("got status %d, want %d", w.Code, wantStatus)
+		}
+	}
+}
+
+func BenchmarkSupabaseAuthValidToken(b *testing.B) {
+	benchmarkAuth(b, benchToken(b, benchJWTSecret), http.StatusNoContent)
+}
+
+func BenchmarkSupabaseAuthInvalidSignature(b *testing.B) {
+	// Rejected tokens are logged; keep the log out of the measurement
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	benchmarkAuth(b, benchToken(b, "wrong-secret"), http.StatusUnauthorized)
+}
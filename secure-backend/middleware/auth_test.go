@@ -0,0 +1,236 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"secure-backend/models"
+	"secure-backend/tokens"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreakGlassTokenIsAudited(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("SUPABASE_JWT_SECRET", "test-secret")
+	tokens.SetKeyring(tokens.NewKeyring(tokens.Key{ID: "test", Secret: []byte("secret")}))
+
+	var audited []*models.AuditEntry
+	var auditErr error
+	lookupRole, lookupUser, audit := userRole, breakGlassUser, recordAudit
+	userRole = func(context.Context, string) (string, error) { return "admin", nil }
+	breakGlassUser = func(_ context.Context, id string) (*models.User, error) {
+		if id != "admin-1" {
+			return nil, errors.New("not a break-glass account")
+		}
+		return &models.User{ID: id, Email: "ops@example.com", Role: "admin", BreakGlass: true}, nil
+	}
+	recordAudit = func(_ context.Context, entry *models.AuditEntry) error {
+		audited = append(audited, entry)
+		return auditErr
+	}
+	t.Cleanup(func() { userRole, breakGlassUser, recordAudit = lookupRole, lookupUser, audit })
+
+	r := gin.New()
+	r.DELETE("/api/products/:id", SupabaseAuthMiddleware(), func(c *gin.Context) {
+		user := c.MustGet(UserKey).(*models.AuthUser)
+		assert.True(t, c.GetBool(BreakGlassKey))
+		c.String(http.StatusOK, user.Role)
+	})
+
+	do := func(subject string) *httptest.ResponseRecorder {
+		token, err := tokens.Sign(tokens.PurposeBreakGlass, subject, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodDelete, "/api/products/p1", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("admin-1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "admin", w.Body.String())
+	require.Len(t, audited, 1)
+	assert.Equal(t, models.AuditBreakGlassRequest, audited[0].Action)
+	assert.Equal(t, "DELETE /api/products/p1", audited[0].Detail)
+
+	// Disabled accounts are refused, and so is every request that can't be audited
+	assert.Equal(t, http.StatusUnauthorized, do("admin-2").Code)
+	auditErr = errors.New("database unavailable")
+	assert.Equal(t, http.StatusServiceUnavailable, do("admin-1").Code)
+}
+
+func TestSupabaseTokensVerifiedWithJWKS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	fetches := 0
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{
+			{"kid": "rsa-1", "kty": "RSA", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kid": "ec-1", "kty": "EC", "crv": "P-256", "x": b64(ecKey.X.Bytes()), "y": b64(ecKey.Y.Bytes())},
+		}})
+	}))
+	defer jwksServer.Close()
+
+	t.Setenv("SUPABASE_JWT_SECRET", "test-secret")
+	t.Setenv("SUPABASE_JWKS_URL", jwksServer.URL)
+	jwksOnce, supabaseKeys = sync.Once{}, nil
+	t.Cleanup(func() { jwksOnce, supabaseKeys = sync.Once{}, nil })
+
+	lookupRole, lookupRevoked := userRole, tokenRevokedBefore
+	userRole = func(context.Context, string) (string, error) { return "buyer", nil }
+	tokenRevokedBefore = func(context.Context, string) (*time.Time, error) { return nil, nil }
+	t.Cleanup(func() { userRole, tokenRevokedBefore = lookupRole, lookupRevoked })
+
+	r := gin.New()
+	r.GET("/", SupabaseAuthMiddleware(), func(c *gin.Context) {
+		c.String(http.StatusOK, c.MustGet(UserKey).(*models.AuthUser).ID)
+	})
+
+	sign := func(method jwt.SigningMethod, kid string, key interface{}) string {
+		token := jwt.NewWithClaims(method, jwt.MapClaims{"sub": "user-1", "exp": time.Now().Add(time.Hour).Unix()})
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+		return signed
+	}
+	do := func(token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, do(sign(jwt.SigningMethodRS256, "rsa-1", rsaKey)).Code)
+	assert.Equal(t, http.StatusOK, do(sign(jwt.SigningMethodES256, "ec-1", ecKey)).Code)
+	assert.Equal(t, http.StatusOK, do(sign(jwt.SigningMethodHS256, "", []byte("test-secret"))).Code)
+	assert.Equal(t, http.StatusUnauthorized, do(sign(jwt.SigningMethodRS256, "rsa-1", otherKey)).Code)
+	assert.Equal(t, http.StatusUnauthorized, do(sign(jwt.SigningMethodRS256, "unknown", rsaKey)).Code)
+	assert.Equal(t, http.StatusUnauthorized, do(sign(jwt.SigningMethodRS512, "rsa-1", rsaKey)).Code)
+
+	// The key set is cached, and unknown kids don't trigger a refetch right away
+	assert.Equal(t, 1, fetches)
+}
+
+func TestJWKSReady(t *testing.T) {
+	up := false
+	fetches := 0
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		if !up {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"keys": []}`))
+	}))
+	defer jwksServer.Close()
+
+	keys := NewJWKS(jwksServer.URL)
+	assert.Error(t, keys.Ready(context.Background()))
+
+	up = true
+	assert.NoError(t, keys.Ready(context.Background()))
+
+	// Once fetched, the cached set keeps the instance ready
+	up = false
+	assert.NoError(t, keys.Ready(context.Background()))
+	assert.Equal(t, 2, fetches)
+}
+
+func TestRevokedTokensAreRefused(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("SUPABASE_JWT_SECRET", "test-secret")
+
+	cutoff := time.Now().Add(-time.Minute)
+	lookupRole, lookupRevoked := userRole, tokenRevokedBefore
+	userRole = func(context.Context, string) (string, error) { return "buyer", nil }
+	tokenRevokedBefore = func(_ context.Context, userID string) (*time.Time, error) {
+		if userID == "compromised" {
+			return &cutoff, nil
+		}
+		return nil, nil
+	}
+	t.Cleanup(func() { userRole, tokenRevokedBefore = lookupRole, lookupRevoked })
+
+	r := gin.New()
+	r.GET("/", SupabaseAuthMiddleware(), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	do := func(claims jwt.MapClaims) int {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test-secret"))
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	before, after := cutoff.Add(-time.Hour).Unix(), cutoff.Add(time.Second).Unix()
+	assert.Equal(t, http.StatusUnauthorized, do(jwt.MapClaims{"sub": "compromised", "iat": before}))
+	assert.Equal(t, http.StatusUnauthorized, do(jwt.MapClaims{"sub": "compromised"}))
+	assert.Equal(t, http.StatusNoContent, do(jwt.MapClaims{"sub": "compromised", "iat": after}))
+	assert.Equal(t, http.StatusNoContent, do(jwt.MapClaims{"sub": "other", "iat": before}))
+}
+
+func TestDemoSessionToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("SUPABASE_JWT_SECRET", "test-secret")
+	tokens.SetKeyring(tokens.NewKeyring(tokens.Key{ID: "test", Secret: []byte("secret")}))
+
+	lookupRole, lookupRevoked := userRole, tokenRevokedBefore
+	userRole = func(context.Context, string) (string, error) { return "seller", nil }
+	tokenRevokedBefore = func(context.Context, string) (*time.Time, error) { return nil, nil }
+	t.Cleanup(func() { userRole, tokenRevokedBefore = lookupRole, lookupRevoked })
+
+	r := gin.New()
+	r.GET("/", SupabaseAuthMiddleware(), func(c *gin.Context) {
+		c.String(http.StatusOK, c.MustGet(UserKey).(*models.AuthUser).Email)
+	})
+	do := func(subject string) *httptest.ResponseRecorder {
+		token, err := tokens.Sign(tokens.PurposeDemo, subject, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		return w
+	}
+	seller, _ := models.DemoUserByRole("seller")
+
+	t.Setenv("DEMO_MODE", "false")
+	assert.Equal(t, http.StatusUnauthorized, do(seller.ID).Code, "demo tokens stop working when demo mode is off")
+
+	t.Setenv("DEMO_MODE", "true")
+	w := do(seller.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, seller.Email, w.Body.String())
+	assert.Equal(t, http.StatusUnauthorized, do("00000000-0000-0000-0000-000000000001").Code, "only demo accounts")
+}
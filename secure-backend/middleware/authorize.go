@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"path"
+	"secure-backend/models"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Ownership names how a handler narrows a route to the caller's own resources. It can't be
+// checked before the resource is loaded, so handlers enforce it; the rule records what they
+// are expected to do and is listed with the route.
+type Ownership string
+
+const (
+	OwnerNone   Ownership = ""       // nothing is scoped to the caller
+	OwnerSelf   Ownership = "self"   // the caller's own records: cart, wishlist, jobs, sessions
+	OwnerBuyer  Ownership = "buyer"  // orders of the buyer who placed them (admins see all)
+	OwnerSeller Ownership = "seller" // products and order items of the seller who lists them
+)
+
+// Access is the authorization rule of a route, declared where the route is registered
+type Access struct {
+	Roles []string  `json:"roles,omitempty"` // roles allowed to call the route; empty admits every authenticated user
+	Owner Ownership `json:"owner,omitempty"` // how the handler scopes the route to the caller
+}
+
+// Rules shared by most routes
+var (
+	Authenticated = Access{}
+	OwnRecords    = Access{Owner: OwnerSelf}
+	BuyerOwned    = Access{Owner: OwnerBuyer}
+	SellerOwned   = Access{Roles: []string{"seller"}, Owner: OwnerSeller}
+	SellerOrAdmin = Access{Roles: []string{"seller", "admin"}}
+	AdminOnly     = Access{Roles: []string{"admin"}}
+)
+
+// OwnedBy returns a copy of the rule with the given ownership
+func (a Access) OwnedBy(owner Ownership) Access {
+	a.Owner = owner
+	return a
+}
+
+// Allows reports whether a user with the role may call the route
+func (a Access) Allows(role string) bool {
+	if len(a.Roles) == 0 {
+		return true
+	}
+	for _, allowed := range a.Roles {
+		if role == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// RouteAccess is the access rule of one registered route
+type RouteAccess struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Access
+}
+
+var (
+	accessMu    sync.RWMutex
+	accessRules = map[string]RouteAccess{}
+)
+
+// RouteAccessRules returns the access rules of the guarded routes, sorted by path and method
+func RouteAccessRules() []RouteAccess {
+	accessMu.RLock()
+	defer accessMu.RUnlock()
+	rules := make([]RouteAccess, 0, len(accessRules))
+	for _, rule := range accessRules {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Path != rules[j].Path {
+			return rules[i].Path < rules[j].Path
+		}
+		return rules[i].Method < rules[j].Method
+	})
+	return rules
+}
+
+func registerAccess(rule RouteAccess) {
+	accessMu.Lock()
+	defer accessMu.Unlock()
+	accessRules[rule.Method+" "+rule.Path] = rule
+}
+
+func lookupAccess(method, route string) (RouteAccess, bool) {
+	accessMu.RLock()
+	defer accessMu.RUnlock()
+	rule, ok := accessRules[method+" "+route]
+	return rule, ok
+}
+
+// Authorize enforces the access rules of the routes registered through Guard. It runs after
+// SupabaseAuthMiddleware and refuses callers whose role the route doesn't allow, so handlers
+// don't check roles themselves. A route without a rule is refused for everyone: Guard is the
+// only way to register routes on the group, so that only happens to routes added around it.
+func Authorize() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rule, ok := lookupAccess(c.Request.Method, c.FullPath())
+		if !ok {
+			log.Printf("No access rule for %s %s; refusing the request", c.Request.Method, c.FullPath())
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden: route has no access rule"})
+			return
+		}
+
+		value, _ := c.Get(UserKey)
+		user, ok := value.(*models.AuthUser)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+			return
+		}
+		if !rule.Allows(user.Role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden: insufficient role"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Routes registers routes on a router group together with their access rule. It has no way
+// to add a route without one, so a new endpoint can't be left unguarded by forgetting a role
+// check in its handler.
+type Routes struct {
+	group *gin.RouterGroup
+}
+
+// Guard wraps a router group whose routes are authorized by Authorize
+func Guard(group *gin.RouterGroup) Routes {
+	return Routes{group: group}
+}
+
+// Use adds middleware to the group
+func (r Routes) Use(middleware ...gin.HandlerFunc) {
+	r.group.Use(middleware...)
+}
+
+// Group creates a guarded subgroup
+func (r Routes) Group(relativePath string, handlers ...gin.HandlerFunc) Routes {
+	return Routes{group: r.group.Group(relativePath, handlers...)}
+}
+
+// GET registers a GET route with its access rule
+func (r Routes) GET(relativePath string, access Access, handlers ...gin.HandlerFunc) {
+	r.handle(http.MethodGet, relativePath, access, handlers)
+}
+
+// POST registers a POST route with its access rule
+func (r Routes) POST(relativePath string, access Access, handlers ...gin.HandlerFunc) {
+	r.handle(http.MethodPost, relativePath, access, handlers)
+}
+
+// PUT registers a PUT route with its access rule
+func (r Routes) PUT(relativePath string, access Access, handlers ...gin.HandlerFunc) {
+	r.handle(http.MethodPut, relativePath, access, handlers)
+}
+
+// DELETE registers a DELETE route with its access rule
+func (r Routes) DELETE(relativePath string, access Access, handlers ...gin.HandlerFunc) {
+	r.handle(http.MethodDelete, relativePath, access, handlers)
+}
+
+func (r Routes) handle(method, relativePath string, access Access, handlers []gin.HandlerFunc) {
+	registerAccess(RouteAccess{Method: method, Path: joinPaths(r.group.BasePath(), relativePath), Access: access})
+	r.group.Handle(method, relativePath, handlers...)
+}
+
+// joinPaths joins a group's base path and a route path the way gin does, keeping a trailing
+// slash of the route path
+func joinPaths(base, relativePath string) string {
+	if relativePath == "" {
+		return base
+	}
+	joined := path.Join(base, relativePath)
+	if strings.HasSuffix(relativePath, "/") && !strings.HasSuffix(joined, "/") {
+		joined += "/"
+	}
+	return joined
+}
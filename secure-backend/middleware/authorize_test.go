@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"secure-backend/models"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	group := r.Group("/authz")
+	group.Use(func(c *gin.Context) {
+		if role := c.GetHeader("X-Test-Role"); role != "" {
+			c.Set(UserKey, &models.AuthUser{ID: "user-1", Role: role})
+		}
+	})
+	routes := Guard(group)
+	routes.Use(Authorize())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+
+	routes.GET("/products", Authenticated, ok)
+	routes.PUT("/products/:id", SellerOwned, ok)
+	admin := routes.Group("/admin")
+	admin.DELETE("/tags/:id", AdminOnly, ok)
+	group.GET("/unguarded", ok)
+
+	do := func(method, path, role string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-Test-Role", role)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/authz/products", "buyer"))
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/authz/products", ""))
+	assert.Equal(t, http.StatusOK, do(http.MethodPut, "/authz/products/p1", "seller"))
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPut, "/authz/products/p1", "buyer"))
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPut, "/authz/products/p1", "admin"))
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/authz/admin/tags/t1", "admin"))
+	assert.Equal(t, http.StatusForbidden, do(http.MethodDelete, "/authz/admin/tags/t1", "seller"))
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/authz/unguarded", "admin"), "routes without a rule are refused")
+
+	var registered []RouteAccess
+	for _, rule := range RouteAccessRules() {
+		if strings.HasPrefix(rule.Path, "/authz/") {
+			registered = append(registered, rule)
+		}
+	}
+	assert.Equal(t, []RouteAccess{
+		{Method: http.MethodDelete, Path: "/authz/admin/tags/:id", Access: AdminOnly},
+		{Method: http.MethodGet, Path: "/authz/products", Access: Authenticated},
+		{Method: http.MethodPut, Path: "/authz/products/:id", Access: SellerOwned},
+	}, registered)
+}
+
+func TestJoinPaths(t *testing.T) {
+	assert.Equal(t, "/api", joinPaths("/api", ""))
+	assert.Equal(t, "/api/cart/:id", joinPaths("/api/cart", "/:id"))
+	assert.Equal(t, "/api/jobs/", joinPaths("/api", "/jobs/"))
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"secure-backend/models"
+	"secure-backend/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// ClientInfoHeader carries structured client details, e.g.
+	// "platform=ios; app_version=1.4.2; device=iPhone15,2"
+	ClientInfoHeader = "X-Client-Info"
+)
+
+// knownPlatforms are the client platforms recorded in analytics; anything else is "unknown"
+var knownPlatforms = map[string]bool{
+	"ios":     true,
+	"android": true,
+	"web":     true,
+}
+
+// ClientInfo middleware parses the X-Client-Info header and stores the result in the context
+func ClientInfo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(ClientInfoKey, ParseClientInfo(c.GetHeader(ClientInfoHeader)))
+		c.Next()
+	}
+}
+
+// ParseClientInfo parses a semicolon-separated list of key=value client attributes
+func ParseClientInfo(header string) *models.ClientInfo {
+	info := &models.ClientInfo{Platform: "unknown"}
+
+	for _, part := range strings.Split(header, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = utils.SanitizeInput(value, utils.SanitizationOptions{
+			TrimWhitespace: true,
+			EscapeHTML:     true,
+			RemoveNewlines: true,
+			MaxLength:      64,
+		})
+
+		switch key {
+		case "platform":
+			if platform := strings.ToLower(value); knownPlatforms[platform] {
+				info.Platform = platform
+			}
+		case "app_version":
+			info.AppVersion = value
+		case "device":
+			info.Device = value
+		}
+	}
+
+	return info
+}
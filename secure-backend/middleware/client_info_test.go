@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"secure-backend/models"
+	"secure-backend/utils"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseClientInfo(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   models.ClientInfo
+	}{
+		{"no header", "", models.ClientInfo{Platform: "unknown"}},
+		{
+			"all attributes",
+			"platform=ios; app_version=1.4.2; device=iPhone15,2",
+			models.ClientInfo{Platform: "ios", AppVersion: "1.4.2", Device: "iPhone15,2"},
+		},
+		{"keys and platforms ignore case", " Platform = Android ;APP_VERSION=2.0", models.ClientInfo{Platform: "android", AppVersion: "2.0"}},
+		{"unknown platform", "platform=smart-fridge", models.ClientInfo{Platform: "unknown"}},
+		{"parts without a value", "platform;web;=ios;app_version=3.1", models.ClientInfo{Platform: "unknown", AppVersion: "3.1"}},
+		{"unknown keys", "platform=web; build=42", models.ClientInfo{Platform: "web"}},
+		{"later values win", "platform=ios; platform=web", models.ClientInfo{Platform: "web"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, *ParseClientInfo(tt.header))
+		})
+	}
+}
+
+func TestParseClientInfoSanitizesValues(t *testing.T) {
+	info := ParseClientInfo("device=<script>alert(1)</script>; app_version=" + strings.Repeat("9", 100))
+
+	assert.NotContains(t, info.Device, "<script>")
+	assert.Len(t, info.AppVersion, 64, "values are truncated")
+}
+
+func TestClientInfoSetsContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var got *models.ClientInfo
+	r := gin.New()
+	r.Use(ClientInfo())
+	r.GET("/", func(c *gin.Context) { got = utils.GetClientInfo(c) })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(ClientInfoHeader, "platform=web; app_version=5.0")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, &models.ClientInfo{Platform: "web", AppVersion: "5.0"}, got)
+
+	// Requests without the header are recorded as unknown
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "unknown", got.Platform)
+}
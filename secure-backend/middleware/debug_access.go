@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"secure-backend/models"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DebugAccess guards the profiling and runtime debug endpoints. It runs after
+// SupabaseAuthMiddleware and admits admins connecting from an address in DEBUG_ALLOWED_IPS
+// (comma-separated IPs or CIDRs). The connecting address is checked rather than
+// X-Forwarded-For, so the endpoints are reached directly (e.g. through a port-forward)
+// instead of through the load balancer. Without DEBUG_ALLOWED_IPS the endpoints are off.
+// Every admitted request is written to the admin audit log.
+func DebugAccess() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get(UserKey)
+		user, ok := value.(*models.AuthUser)
+		if !ok || user.Role != "admin" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden: insufficient role"})
+			return
+		}
+
+		allowed := debugAllowedNets()
+		if len(allowed) == 0 {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Debug endpoints are disabled"})
+			return
+		}
+
+		ip := net.ParseIP(c.RemoteIP())
+		if !containsIP(allowed, ip) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Address not allowed to use debug endpoints"})
+			return
+		}
+
+		err := recordAudit(c.Request.Context(), &models.AuditEntry{
+			ActorID:    &user.ID,
+			ActorEmail: user.Email,
+			Action:     models.AuditDebugAccessed,
+			Detail:     c.Request.Method + " " + c.Request.URL.RequestURI(),
+			IPAddress:  c.RemoteIP(),
+		})
+		if err != nil {
+			log.Printf("Failed to audit debug request by %s: %v", user.Email, err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Audit log unavailable"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// debugAllowedNets parses DEBUG_ALLOWED_IPS, skipping invalid entries. Single addresses
+// become /32 (or /128) networks.
+func debugAllowedNets() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, entry := range strings.Split(os.Getenv("DEBUG_ALLOWED_IPS"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				log.Printf("Skipping invalid DEBUG_ALLOWED_IPS entry %q", entry)
+				continue
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Printf("Skipping invalid DEBUG_ALLOWED_IPS entry %q", entry)
+			continue
+		}
+		nets = append(nets, network)
+	}
+	return nets
+}
+
+// containsIP reports whether ip is in any of the networks
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range nets {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"secure-backend/models"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugAccess(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var audited []*models.AuditEntry
+	audit := recordAudit
+	recordAudit = func(_ context.Context, entry *models.AuditEntry) error {
+		audited = append(audited, entry)
+		return nil
+	}
+	t.Cleanup(func() { recordAudit = audit })
+
+	r := gin.New()
+	r.GET("/debug/*name", func(c *gin.Context) {
+		c.Set(UserKey, &models.AuthUser{ID: "user-1", Email: "ops@example.com", Role: c.GetHeader("X-Test-Role")})
+	}, DebugAccess(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	do := func(role, remoteAddr string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/debug/heap?gc=1", nil)
+		req.Header.Set("X-Test-Role", role)
+		req.Header.Set("X-Forwarded-For", "10.0.0.5")
+		req.RemoteAddr = remoteAddr
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Setenv("DEBUG_ALLOWED_IPS", "")
+	assert.Equal(t, http.StatusNotFound, do("admin", "10.0.0.5:4000"), "off without an allowlist")
+
+	t.Setenv("DEBUG_ALLOWED_IPS", "10.0.0.0/24, 192.0.2.7, not-an-ip, ::1")
+	assert.Equal(t, http.StatusForbidden, do("seller", "10.0.0.5:4000"))
+	assert.Equal(t, http.StatusForbidden, do("admin", "203.0.113.9:4000"), "X-Forwarded-For isn't trusted")
+	assert.Equal(t, http.StatusForbidden, do("admin", "192.0.2.8:4000"))
+	assert.Equal(t, http.StatusOK, do("admin", "10.0.0.5:4000"))
+	assert.Equal(t, http.StatusOK, do("admin", "192.0.2.7:4000"))
+	assert.Equal(t, http.StatusOK, do("admin", "[::1]:4000"))
+
+	require.Len(t, audited, 3)
+	assert.Equal(t, models.AuditDebugAccessed, audited[0].Action)
+	assert.Equal(t, "GET /debug/heap?gc=1", audited[0].Detail)
+	assert.Equal(t, "10.0.0.5", audited[0].IPAddress)
+}
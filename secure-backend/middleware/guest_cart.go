@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+	"secure-backend/tokens"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CartTokenHeader carries the signed token identifying an anonymous (guest) cart
+const CartTokenHeader = "X-Cart-Token"
+
+// GuestCart resolves the cart token in X-Cart-Token to its anonymous cart ID and stores it
+// in the context under GuestCartKey. Requests without a token pass through (the guest cart
+// handlers start a new cart); a token that is forged, expired or minted for another purpose
+// is rejected so the client can discard it.
+func GuestCart() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.GetHeader(CartTokenHeader)
+		if token == "" {
+			c.Next()
+			return
+		}
+
+		cartID, err := tokens.Subject(token, tokens.PurposeGuestCart, time.Now())
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired cart token"})
+			return
+		}
+
+		c.Set(GuestCartKey, cartID)
+		c.Next()
+	}
+}
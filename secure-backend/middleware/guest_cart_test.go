@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"secure-backend/tokens"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuestCartResolvesCartToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokens.SetKeyring(tokens.NewKeyring(tokens.Key{ID: "test", Secret: []byte("secret")}))
+
+	r := gin.New()
+	r.Use(GuestCart())
+	r.GET("/", func(c *gin.Context) { c.String(http.StatusOK, c.GetString(GuestCartKey)) })
+
+	do := func(token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if token != "" {
+			req.Header.Set(CartTokenHeader, token)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	valid, err := tokens.Sign(tokens.PurposeGuestCart, "cart-1", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	w := do(valid)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "cart-1", w.Body.String())
+
+	// No token: the handler starts a new cart
+	w = do("")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	// Tokens for other purposes can't name a cart
+	download, err := tokens.Sign(tokens.PurposeJobDownload, "cart-1", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, do(download).Code)
+	assert.Equal(t, http.StatusUnauthorized, do("not-a-token").Code)
+}
@@ -0,0 +1,237 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"secure-backend/models"
+	"secure-backend/outbound"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JWKS cache timings: keys are refetched after jwksTTL, and a token naming an unknown key
+// triggers a refetch at most every jwksMinRefresh so forged kids can't hammer Supabase
+const (
+	jwksTTL        = 10 * time.Minute
+	jwksMinRefresh = 30 * time.Second
+)
+
+// ErrUnknownKey is returned when a token names a key that isn't in the key set
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// JWKS fetches and caches the public keys of a JSON Web Key Set, used to verify Supabase
+// tokens signed with asymmetric keys (RS256, ES256)
+type JWKS struct {
+	url    string
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]interface{} // kid → *rsa.PublicKey or *ecdsa.PublicKey
+	fetchedAt time.Time
+	failedAt  time.Time // when a failed refresh was last raised as a security event
+}
+
+// NewJWKS creates a key set fetched from url on first use
+func NewJWKS(url string) *JWKS {
+	return &JWKS{url: url, client: outbound.NewClient("supabase-jwks", 10*time.Second)}
+}
+
+var (
+	jwksOnce     sync.Once
+	supabaseKeys *JWKS
+)
+
+// supabaseJWKS returns the Supabase key set from SUPABASE_JWKS_URL, or the project's
+// well-known endpoint under SUPABASE_URL; nil when neither is set
+func supabaseJWKS() *JWKS {
+	jwksOnce.Do(func() {
+		url := os.Getenv("SUPABASE_JWKS_URL")
+		if url == "" {
+			if base := os.Getenv("SUPABASE_URL"); base != "" {
+				url = strings.TrimRight(base, "/") + "/auth/v1/.well-known/jwks.json"
+			}
+		}
+		if url != "" {
+			supabaseKeys = NewJWKS(url)
+		}
+	})
+	return supabaseKeys
+}
+
+// Key returns the public key with the given kid, refreshing the cached set when it is
+// stale or doesn't have the key. A failed refresh keeps serving the cached keys.
+func (j *JWKS) Key(ctx context.Context, kid string) (interface{}, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	key, ok := j.keys[kid]
+	stale := now.Sub(j.fetchedAt) > jwksTTL
+	if (stale || !ok) && now.Sub(j.fetchedAt) > jwksMinRefresh {
+		keys, err := j.fetch(ctx)
+		if err != nil {
+			log.Printf("Failed to refresh JWKS from %s: %v", j.url, err)
+			if now.Sub(j.failedAt) >= throttleEventWindow {
+				j.failedAt = now
+				raiseSecurityEvent(ctx, &models.SecurityEvent{
+					Type:     models.SecurityJWKSFailure,
+					Severity: models.SeverityHigh,
+					Detail:   fmt.Sprintf("failed to refresh signing keys from %s: %v", j.url, err),
+				})
+			}
+		} else {
+			j.keys, j.fetchedAt = keys, now
+			key, ok = keys[kid]
+		}
+	}
+
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// Ready returns nil once the key set has been fetched. Until then it fetches the set, so a
+// new instance doesn't take traffic it couldn't authenticate; later failed refreshes don't
+// matter, as the cached keys keep being served.
+func (j *JWKS) Ready(ctx context.Context) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.keys != nil {
+		return nil
+	}
+	keys, err := j.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	j.keys, j.fetchedAt = keys, time.Now()
+	return nil
+}
+
+// SigningKeysReady checks the Supabase key set with Ready. configured is false when no key
+// set URL is set, as only HS256 tokens are accepted then.
+func SigningKeysReady(ctx context.Context) (configured bool, err error) {
+	keys := supabaseJWKS()
+	if keys == nil {
+		return false, nil
+	}
+	return true, keys.Ready(ctx)
+}
+
+// jsonWebKey holds the members of an RSA or EC public JWK
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// fetch downloads the key set, skipping keys it can't use
+func (j *JWKS) fetch(ctx context.Context) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("JWKS endpoint returned %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("decoding JWKS: %w", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			log.Printf("Skipping JWKS key %q: %v", jwk.Kid, err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
+
+// publicKey decodes the JWK into an RSA or ECDSA public key
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("RSA exponent out of range")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("EC point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// decodeBigInt decodes a base64url (unpadded) big-endian integer
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -2,5 +2,10 @@ package middleware
 
 // Common context keys
 const (
-	UserKey = "user"
+	UserKey       = "user"
+	ClientInfoKey = "client_info"
+	SessionKey    = "session"     // set when the request authenticated with a session cookie
+	GuestCartKey  = "guest_cart"  // ID of the anonymous cart named by a valid cart token
+	BreakGlassKey = "break_glass" // set when the request authenticated with a break-glass token
+	PartnerKey    = "partner"     // partner API key (*models.PartnerAPIKey) of a partner catalog request
 )
@@ -1,59 +1,64 @@
 package middleware
 
 import (
-	"log"
-	"sync/atomic"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"secure-backend/errorreport"
+	"secure-backend/logging"
+	"secure-backend/metrics"
+	"secure-backend/tracing"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-var (
-	// Global request metrics using atomic operations for thread safety
-	totalRequests uint64
-	totalErrors   uint64
-)
-
-// RequestLogger middleware logs HTTP requests with timing information
+// RequestLogger middleware logs each request with its status and latency and records it in
+// the per-route request metrics. Handlers and
+// middleware log with logging.FromContext so their records carry the same request_id,
+// route and (once authenticated) user_id fields.
 func RequestLogger() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Start timer
 		start := time.Now()
 
+		fields := []any{"request_id", c.GetString(RequestIDKey), "method", c.Request.Method, "route", c.FullPath()}
+		if sc := tracing.FromContext(c.Request.Context()).Context(); sc.IsValid() {
+			fields = append(fields, "trace_id", hex.EncodeToString(sc.TraceID[:]))
+		}
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(), fields...))
+
 		// Process request
 		c.Next()
 
-		// Calculate metrics
 		latency := time.Since(start)
 		status := c.Writer.Status()
+		metrics.RecordRequest(c.Request.Method, c.FullPath(), status, latency)
 
-		// Update global metrics
-		atomic.AddUint64(&totalRequests, 1)
-		if status >= 400 {
-			atomic.AddUint64(&totalErrors, 1)
+		// Server errors at error level, client errors at warn, the rest at info
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
 		}
-
-		// Log format: timestamp | method path | status | latency | total_reqs | errors
-		log.Printf("%s | %s %s | %d | %v | total=%d errors=%d",
-			time.Now().Format("2006/01/02 15:04:05"),
-			c.Request.Method,
-			c.Request.URL.Path,
-			status,
-			latency,
-			atomic.LoadUint64(&totalRequests),
-			atomic.LoadUint64(&totalErrors))
-
-		// Store request metrics in context
-		c.Set("RequestMetrics", map[string]interface{}{
-			"latency":     latency,
-			"status":      status,
-			"totalReqs":   atomic.LoadUint64(&totalRequests),
-			"totalErrors": atomic.LoadUint64(&totalErrors),
-		})
+		attrs := []slog.Attr{
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(latency.Microseconds())/1000),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.Last().Error()))
+		}
+		ctx := c.Request.Context()
+		logging.FromContext(ctx).LogAttrs(ctx, level, "request", attrs...)
 	}
 }
 
-// ErrorHandler middleware provides consistent error response format
+// ErrorHandler middleware provides consistent error response format and reports 5xx
+// responses to the error tracker
 func ErrorHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Next()
@@ -81,5 +86,22 @@ func ErrorHandler() gin.HandlerFunc {
 
 			c.JSON(statusCode, errorResponse)
 		}
+
+		reportServerError(c)
+	}
+}
+
+// reportServerError reports a 5xx response with the last error attached to the request, if any
+func reportServerError(c *gin.Context) {
+	status := c.Writer.Status()
+	if status < 500 || !errorreport.Enabled() {
+		return
+	}
+	message := fmt.Sprintf("%s %s responded %d", c.Request.Method, c.FullPath(), status)
+	if len(c.Errors) > 0 {
+		message = c.Errors.Last().Error()
 	}
+	event := requestEvent(c, errorreport.LevelError, message)
+	event.Status = status
+	errorreport.Report(event)
 }
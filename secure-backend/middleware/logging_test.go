@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"secure-backend/logging"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestLoggerAddsRequestFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("SUPABASE_JWT_SECRET", "test-secret")
+	t.Setenv("SUPABASE_URL", "")
+	t.Setenv("SUPABASE_JWKS_URL", "")
+	jwksOnce, supabaseKeys = sync.Once{}, nil
+	t.Cleanup(func() { jwksOnce, supabaseKeys = sync.Once{}, nil })
+
+	lookupRole, lookupRevoked := userRole, tokenRevokedBefore
+	userRole = func(context.Context, string) (string, error) { return "buyer", nil }
+	tokenRevokedBefore = func(context.Context, string) (*time.Time, error) { return nil, nil }
+	t.Cleanup(func() { userRole, tokenRevokedBefore = lookupRole, lookupRevoked })
+
+	var buf bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(logging.New(&buf, true, slog.LevelDebug))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	r := gin.New()
+	r.Use(RequestID(), RequestLogger())
+	r.GET("/orders/:id", SupabaseAuthMiddleware(), func(c *gin.Context) {
+		logging.FromContext(c.Request.Context()).Debug("loading order")
+		c.Status(http.StatusNotFound)
+	})
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "user-1", "exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte("test-secret"))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/orders/42", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set(RequestIDHeader, "req-1")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	var handlerRecord, requestRecord map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &handlerRecord))
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &requestRecord))
+
+	for _, record := range []map[string]interface{}{handlerRecord, requestRecord} {
+		assert.Equal(t, "req-1", record["request_id"])
+		assert.Equal(t, "/orders/:id", record["route"])
+		assert.Equal(t, "user-1", record["user_id"])
+	}
+	assert.Equal(t, "DEBUG", handlerRecord["level"])
+	assert.Equal(t, "request", requestRecord["msg"])
+	assert.Equal(t, "WARN", requestRecord["level"], "client errors log at warn")
+	assert.Equal(t, "/orders/42", requestRecord["path"])
+	assert.EqualValues(t, 404, requestRecord["status"])
+	assert.Contains(t, requestRecord, "latency_ms")
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/services"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// PartnerAPIKeyHeader carries the API key of a comparison-shopping partner
+const PartnerAPIKeyHeader = "X-API-Key"
+
+// authenticatePartner and recordPartnerUsage look up partner keys and count their
+// requests; tests replace them to skip the database
+var (
+	authenticatePartner = services.AuthenticatePartnerKey
+	recordPartnerUsage  = database.RecordPartnerUsage
+)
+
+// PartnerAuth authenticates partner requests by the key in X-API-Key, counts them against
+// the key's daily quota and stores the key in the context under PartnerKey. Unlike
+// EnforceQuota it fails closed: partner traffic is optional and must stay within budget.
+func PartnerAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, err := authenticatePartner(c.Request.Context(), c.GetHeader(PartnerAPIKeyHeader))
+		if errors.Is(err, services.ErrInvalidPartnerKey) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or missing API key"})
+			return
+		} else if err != nil {
+			log.Printf("Failed to authenticate partner key: %v", err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Partner API unavailable"})
+			return
+		}
+
+		now := time.Now()
+		day, _, dayReset, _ := quotaPeriods(now)
+		used, err := recordPartnerUsage(c.Request.Context(), key.ID, day)
+		if err != nil {
+			log.Printf("Failed to record partner API usage: %v", err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Partner API unavailable"})
+			return
+		}
+
+		window := quotaWindow(key.DailyQuota, used, dayReset)
+		if window.Limit > 0 {
+			c.Header(QuotaDailyLimitHeader, strconv.Itoa(window.Limit))
+			c.Header(QuotaDailyRemainingHeader, strconv.Itoa(window.Remaining))
+		}
+		if exceeded(window) {
+			c.Header(RetryAfterHeader, strconv.Itoa(int(window.ResetsAt.Sub(now).Seconds())+1))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error":     "daily request quota exceeded",
+				"resets_at": window.ResetsAt,
+			})
+			return
+		}
+
+		c.Set(PartnerKey, key)
+		c.Next()
+	}
+}
+
+// RateLimitByPartnerWith creates a rate limiting middleware keyed on the partner API key.
+// It must run after PartnerAuth.
+func RateLimitByPartnerWith(scope string, r rate.Limit, b int) gin.HandlerFunc {
+	return rateLimit(RateLimitPolicy{Scope: scope, Key: "partner", Limit: b, RefillPerSecond: float64(r)}, partnerKey)
+}
+
+// partnerKey buckets requests by partner API key, or by client IP without one
+func partnerKey(c *gin.Context) string {
+	if value, ok := c.Get(PartnerKey); ok {
+		if key, ok := value.(*models.PartnerAPIKey); ok {
+			return "partner:" + key.ID
+		}
+	}
+	return ipKey(c)
+}
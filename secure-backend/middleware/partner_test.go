@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/services"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartnerAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authenticatePartner = func(_ context.Context, secret string) (*models.PartnerAPIKey, error) {
+		if secret != "sspk_valid" {
+			return nil, services.ErrInvalidPartnerKey
+		}
+		return &models.PartnerAPIKey{ID: "key-1", DailyQuota: 2}, nil
+	}
+	used := 0
+	recordPartnerUsage = func(_ context.Context, keyID string, day time.Time) (int, error) {
+		used++
+		return used, nil
+	}
+	defer func() {
+		authenticatePartner = services.AuthenticatePartnerKey
+		recordPartnerUsage = database.RecordPartnerUsage
+	}()
+
+	r := gin.New()
+	r.Use(PartnerAuth())
+	r.GET("/", func(c *gin.Context) {
+		key, _ := c.Get(PartnerKey)
+		c.String(http.StatusOK, key.(*models.PartnerAPIKey).ID)
+	})
+
+	do := func(secret string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if secret != "" {
+			req.Header.Set(PartnerAPIKeyHeader, secret)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, do("").Code)
+	assert.Equal(t, http.StatusUnauthorized, do("sspk_revoked").Code)
+	assert.Equal(t, 0, used)
+
+	w := do("sspk_valid")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "key-1", w.Body.String())
+	assert.Equal(t, "1", w.Header().Get(QuotaDailyRemainingHeader))
+
+	assert.Equal(t, http.StatusOK, do("sspk_valid").Code)
+	w = do("sspk_valid")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get(RetryAfterHeader))
+}
@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Quota response headers, set on every authenticated response
+const (
+	QuotaDailyLimitHeader       = "X-Quota-Daily-Limit"
+	QuotaDailyRemainingHeader   = "X-Quota-Daily-Remaining"
+	QuotaMonthlyLimitHeader     = "X-Quota-Monthly-Limit"
+	QuotaMonthlyRemainingHeader = "X-Quota-Monthly-Remaining"
+)
+
+// Quota is a request allowance per UTC day and calendar month; 0 means unlimited
+type Quota struct {
+	Daily   int `json:"daily"`
+	Monthly int `json:"monthly"`
+}
+
+// QuotaTiers maps quota tiers to their allowances. Buyers and admins have one tier each;
+// sellers get the tier of their plan.
+var QuotaTiers = map[string]Quota{
+	"buyer":             {Daily: 10000, Monthly: 200000},
+	"seller:free":       {Daily: 10000, Monthly: 200000},
+	"seller:pro":        {Daily: 100000, Monthly: 2000000},
+	"seller:enterprise": {},
+	"admin":             {},
+}
+
+// recordUsage and getUsage are replaced in tests to skip the database
+var (
+	recordUsage = database.RecordAPIUsage
+	getUsage    = database.GetAPIUsage
+)
+
+// QuotaTier returns the quota tier for a user's role and plan
+func QuotaTier(role, plan string) string {
+	if role == "seller" {
+		return "seller:" + plan
+	}
+	return role
+}
+
+// quotaPeriods returns the start of the current UTC day and month and when each resets
+func quotaPeriods(now time.Time) (day, month, dayReset, monthReset time.Time) {
+	now = now.UTC()
+	day = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	month = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return day, month, day.AddDate(0, 0, 1), month.AddDate(0, 1, 0)
+}
+
+// quotaStatus combines a user's usage with the allowance of their tier
+func quotaStatus(role string, usage *models.APIUsage, now time.Time) *models.QuotaStatus {
+	_, _, dayReset, monthReset := quotaPeriods(now)
+	tier := QuotaTier(role, usage.Plan)
+	quota := QuotaTiers[tier]
+	return &models.QuotaStatus{
+		Tier:    tier,
+		Daily:   quotaWindow(quota.Daily, usage.Daily, dayReset),
+		Monthly: quotaWindow(quota.Monthly, usage.Monthly, monthReset),
+	}
+}
+
+func quotaWindow(limit, used int, resetsAt time.Time) models.QuotaWindow {
+	remaining := 0
+	if limit > used {
+		remaining = limit - used
+	}
+	return models.QuotaWindow{Limit: limit, Used: used, Remaining: remaining, ResetsAt: resetsAt}
+}
+
+// exceeded reports whether usage is over a limited window
+func exceeded(w models.QuotaWindow) bool {
+	return w.Limit > 0 && w.Used > w.Limit
+}
+
+// UserQuota returns the quota tier and current usage of an authenticated user
+func UserQuota(ctx context.Context, user *models.AuthUser) (*models.QuotaStatus, error) {
+	now := time.Now()
+	day, month, _, _ := quotaPeriods(now)
+	usage, err := getUsage(ctx, user.ID, day, month)
+	if err != nil {
+		return nil, err
+	}
+	return quotaStatus(user.Role, usage, now), nil
+}
+
+// EnforceQuota counts each authenticated request against the user's daily and monthly
+// quota and rejects requests over either limit with 429 until the period resets. Counts are
+// stored in the database so restarts don't reset them. It must run after SupabaseAuthMiddleware.
+func EnforceQuota() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, ok := c.Get(UserKey)
+		user, isUser := value.(*models.AuthUser)
+		if !ok || !isUser {
+			c.Next()
+			return
+		}
+
+		now := time.Now()
+		day, month, _, _ := quotaPeriods(now)
+		usage, err := recordUsage(c.Request.Context(), user.ID, day, month)
+		if err != nil {
+			// Fail open: an unavailable usage store should not take the API down
+			log.Printf("Failed to record API usage: %v", err)
+			c.Next()
+			return
+		}
+
+		status := quotaStatus(user.Role, usage, now)
+		setQuotaHeaders(c, status)
+
+		for _, w := range []struct {
+			name   string
+			window models.QuotaWindow
+		}{{"daily", status.Daily}, {"monthly", status.Monthly}} {
+			if exceeded(w.window) {
+				retryAfter := int(w.window.ResetsAt.Sub(now).Seconds()) + 1
+				c.Header(RetryAfterHeader, strconv.Itoa(retryAfter))
+				c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+					"error":     fmt.Sprintf("%s request quota exceeded", w.name),
+					"resets_at": w.window.ResetsAt,
+				})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// setQuotaHeaders writes the limits and remaining requests of limited windows
+func setQuotaHeaders(c *gin.Context, status *models.QuotaStatus) {
+	if status.Daily.Limit > 0 {
+		c.Header(QuotaDailyLimitHeader, strconv.Itoa(status.Daily.Limit))
+		c.Header(QuotaDailyRemainingHeader, strconv.Itoa(status.Daily.Remaining))
+	}
+	if status.Monthly.Limit > 0 {
+		c.Header(QuotaMonthlyLimitHeader, strconv.Itoa(status.Monthly.Limit))
+		c.Header(QuotaMonthlyRemainingHeader, strconv.Itoa(status.Monthly.Remaining))
+	}
+}
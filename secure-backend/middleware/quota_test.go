@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"secure-backend/database"
+	"secure-backend/models"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnforceQuotaRejectsOverDailyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	counts := map[string]int{}
+	recordUsage = func(_ context.Context, userID string, day, month time.Time) (*models.APIUsage, error) {
+		counts[userID]++
+		return &models.APIUsage{Plan: "free", Daily: counts[userID], Monthly: counts[userID]}, nil
+	}
+	defer func() { recordUsage = database.RecordAPIUsage }()
+
+	free := QuotaTiers["seller:free"]
+	QuotaTiers["seller:free"] = Quota{Daily: 2, Monthly: 100}
+	defer func() { QuotaTiers["seller:free"] = free }()
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(UserKey, &models.AuthUser{ID: c.GetHeader("X-Test-User"), Role: c.GetHeader("X-Test-Role")})
+	})
+	r.Use(EnforceQuota())
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	do := func(userID, role string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Test-User", userID)
+		req.Header.Set("X-Test-Role", role)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("seller-1", "seller")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get(QuotaDailyLimitHeader))
+	assert.Equal(t, "1", w.Header().Get(QuotaDailyRemainingHeader))
+	assert.Equal(t, "99", w.Header().Get(QuotaMonthlyRemainingHeader))
+
+	assert.Equal(t, http.StatusOK, do("seller-1", "seller").Code)
+	w = do("seller-1", "seller")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get(RetryAfterHeader))
+
+	// Unlimited tiers never run out and carry no quota headers
+	for i := 0; i < 5; i++ {
+		w = do("admin-1", "admin")
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	assert.Empty(t, w.Header().Get(QuotaDailyLimitHeader))
+}
@@ -1,7 +1,10 @@
 package middleware
 
 import (
+	"math"
 	"net/http"
+	"secure-backend/models"
+	"strconv"
 	"sync"
 	"time"
 
@@ -9,7 +12,7 @@ import (
 	"golang.org/x/time/rate"
 )
 
-// IPRateLimiter stores rate limiters for different IPs
+// IPRateLimiter stores rate limiters per client key (an IP address or a user ID)
 type IPRateLimiter struct {
 	ips map[string]*rate.Limiter
 	mu  *sync.RWMutex
@@ -31,7 +34,7 @@ func NewIPRateLimiter(r rate.Limit, b int) *IPRateLimiter {
 	return i
 }
 
-// GetLimiter returns the rate limiter for the provided IP
+// GetLimiter returns the rate limiter for the provided IP or user key
 func (i *IPRateLimiter) GetLimiter(ip string) *rate.Limiter {
 	i.mu.Lock()
 	defer i.mu.Unlock()
@@ -58,13 +61,120 @@ func (i *IPRateLimiter) cleanupLoop() {
 	}
 }
 
-// RateLimitByIP creates a gin middleware for IP-based rate limiting
-func RateLimitByIP() gin.HandlerFunc {
-	limiter := NewIPRateLimiter(1, 100) // 100 requests per second per IP
+// Rate limit response headers. They are set on every rate limited response so clients
+// can throttle themselves before hitting a 429.
+const (
+	RateLimitLimitHeader     = "X-RateLimit-Limit"     // budget of a full burst
+	RateLimitRemainingHeader = "X-RateLimit-Remaining" // budget left before throttling
+	RateLimitResetHeader     = "X-RateLimit-Reset"     // seconds until the full burst is available again
+	RateLimitCostHeader      = "X-RateLimit-Cost"      // budget the request cost
+	RetryAfterHeader         = "Retry-After"           // seconds to wait after a 429
+)
+
+// RequestCosts weights the routes that are expensive to serve, by method and route pattern.
+// A request takes its cost from every rate limit budget it passes, so a client can make a
+// hundred cheap requests in a burst but only two exports. Other routes cost 1.
+var RequestCosts = map[string]int{
+	"GET /api/products/search":          5,  // full-text search over the catalog
+	"POST /api/products/bulk-archive":   10, // changes up to 200 products
+	"POST /api/products/bulk-delete":    10, // deletes up to 200 products with their images, tags and history
+	"GET /api/seller/orders/pick-list":  10, // renders a PDF
+	"POST /api/seller/inventory/import": 20, // writes up to 50,000 products in one transaction
+	"POST /api/seller/shipping-labels":  20, // buys labels from the carrier
+	"POST /api/exports":                 50, // queues a full export job
+}
+
+// requestCost returns the cost of the request's route, capped at the burst so that an
+// expensive request can still succeed on a full budget
+func requestCost(c *gin.Context, burst int) int {
+	cost, ok := RequestCosts[c.Request.Method+" "+c.FullPath()]
+	if !ok || cost < 1 {
+		cost = 1
+	}
+	return min(cost, burst)
+}
+
+// RateLimitPolicy describes a rate limit applied to a group of routes
+type RateLimitPolicy struct {
+	Scope           string  `json:"scope"`             // routes the limit applies to, e.g. "/api/*"
+	Key             string  `json:"key"`               // what requests are counted by
+	Limit           int     `json:"limit"`             // burst size, in request cost
+	RefillPerSecond float64 `json:"refill_per_second"` // cost regained per second
+}
+
+var (
+	policiesMu sync.RWMutex
+	policies   []RateLimitPolicy
+)
+
+// RateLimitPolicies returns the rate limits registered by the middleware, in registration order
+func RateLimitPolicies() []RateLimitPolicy {
+	policiesMu.RLock()
+	defer policiesMu.RUnlock()
+	return append([]RateLimitPolicy(nil), policies...)
+}
+
+func registerPolicy(policy RateLimitPolicy) {
+	policiesMu.Lock()
+	defer policiesMu.Unlock()
+	policies = append(policies, policy)
+}
+
+// RateLimitByIP creates a gin middleware for IP-based rate limiting of the routes in scope
+func RateLimitByIP(scope string) gin.HandlerFunc {
+	return RateLimitByIPWith(scope, 1, 100) // bursts of 100 requests, refilled at 1 per second per IP
+}
+
+// RateLimitByIPWith creates an IP-based rate limiting middleware with a custom rate and burst
+func RateLimitByIPWith(scope string, r rate.Limit, b int) gin.HandlerFunc {
+	return rateLimit(RateLimitPolicy{Scope: scope, Key: "ip", Limit: b, RefillPerSecond: float64(r)}, ipKey)
+}
+
+// RateLimitByUser creates a rate limiting middleware keyed on the authenticated user, so
+// users sharing an address (e.g. behind a corporate NAT) get separate budgets. It must run
+// after SupabaseAuthMiddleware; requests without a user fall back to their IP.
+func RateLimitByUser(scope string) gin.HandlerFunc {
+	return RateLimitByUserWith(scope, 1, 100) // bursts of 100 requests, refilled at 1 per second per user
+}
+
+// RateLimitByUserWith creates a per-user rate limiting middleware with a custom rate and burst
+func RateLimitByUserWith(scope string, r rate.Limit, b int) gin.HandlerFunc {
+	return rateLimit(RateLimitPolicy{Scope: scope, Key: "user", Limit: b, RefillPerSecond: float64(r)}, userKey)
+}
+
+// ipKey buckets requests by client IP
+func ipKey(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// userKey buckets requests by authenticated user ID, or by client IP for anonymous requests
+func userKey(c *gin.Context) string {
+	if value, ok := c.Get(UserKey); ok {
+		if user, ok := value.(*models.AuthUser); ok && user.ID != "" {
+			return "user:" + user.ID
+		}
+	}
+	return ipKey(c)
+}
+
+// rateLimit registers the policy and returns a middleware enforcing it per key
+func rateLimit(policy RateLimitPolicy, key func(*gin.Context) string) gin.HandlerFunc {
+	r, b := rate.Limit(policy.RefillPerSecond), policy.Limit
+	limiter := NewIPRateLimiter(r, b)
+	registerPolicy(policy)
 
 	return func(c *gin.Context) {
-		limiter := limiter.GetLimiter(c.ClientIP())
-		if !limiter.Allow() {
+		limiter := limiter.GetLimiter(key(c))
+		now := time.Now()
+		cost := requestCost(c, b)
+		allowed := limiter.AllowN(now, cost)
+		tokens := limiter.TokensAt(now)
+
+		setRateLimitHeaders(c, b, r, tokens)
+		c.Header(RateLimitCostHeader, strconv.Itoa(cost))
+		if !allowed {
+			recordThrottled(c, policy.Scope, key(c))
+			c.Header(RetryAfterHeader, strconv.Itoa(secondsUntil(float64(cost)-tokens, r)))
 			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
 				"error": "too many requests",
 			})
@@ -73,3 +183,27 @@ func RateLimitByIP() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// setRateLimitHeaders writes the X-RateLimit-* headers. When several limiters apply to a
+// route, the one with the least remaining budget wins.
+func setRateLimitHeaders(c *gin.Context, burst int, r rate.Limit, tokens float64) {
+	remaining := int(math.Max(0, math.Floor(tokens)))
+	if previous, err := strconv.Atoi(c.Writer.Header().Get(RateLimitRemainingHeader)); err == nil && previous < remaining {
+		return
+	}
+
+	c.Header(RateLimitLimitHeader, strconv.Itoa(burst))
+	c.Header(RateLimitRemainingHeader, strconv.Itoa(remaining))
+	c.Header(RateLimitResetHeader, strconv.Itoa(secondsUntil(float64(burst)-tokens, r)))
+}
+
+// secondsUntil returns how many whole seconds it takes to regain the given number of tokens
+func secondsUntil(tokens float64, r rate.Limit) int {
+	if tokens <= 0 {
+		return 0
+	}
+	if r <= 0 {
+		return math.MaxInt32
+	}
+	return int(math.Ceil(tokens / float64(r)))
+}
@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"secure-backend/models"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RateLimitByIPWith("test", 1, 2))
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	do := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get(RateLimitLimitHeader))
+	assert.Equal(t, "1", w.Header().Get(RateLimitRemainingHeader))
+	assert.Equal(t, "1", w.Header().Get(RateLimitResetHeader))
+	assert.Empty(t, w.Header().Get(RetryAfterHeader))
+
+	do()
+	w = do()
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get(RateLimitRemainingHeader))
+	assert.Equal(t, "1", w.Header().Get(RetryAfterHeader))
+}
+
+func TestRateLimitChargesRequestCost(t *testing.T) {
+	RequestCosts["GET /expensive"] = 4
+	RequestCosts["GET /huge"] = 1000
+	t.Cleanup(func() {
+		delete(RequestCosts, "GET /expensive")
+		delete(RequestCosts, "GET /huge")
+	})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RateLimitByIPWith("cost-test", 1, 10))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/cheap", ok)
+	r.GET("/expensive", ok)
+	r.GET("/huge", ok)
+
+	do := func(path, ip string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("/expensive", "192.0.2.2")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "4", w.Header().Get(RateLimitCostHeader))
+	assert.Equal(t, "6", w.Header().Get(RateLimitRemainingHeader))
+
+	w = do("/cheap", "192.0.2.2")
+	assert.Equal(t, "1", w.Header().Get(RateLimitCostHeader))
+	assert.Equal(t, "5", w.Header().Get(RateLimitRemainingHeader))
+
+	do("/expensive", "192.0.2.2")
+	w = do("/expensive", "192.0.2.2")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get(RateLimitRemainingHeader))
+	assert.Equal(t, "3", w.Header().Get(RetryAfterHeader))
+	assert.Equal(t, http.StatusOK, do("/cheap", "192.0.2.2").Code)
+
+	// A cost above the burst takes the whole budget instead of never succeeding
+	w = do("/huge", "192.0.2.3")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "10", w.Header().Get(RateLimitCostHeader))
+	assert.Equal(t, "0", w.Header().Get(RateLimitRemainingHeader))
+}
+
+func TestRateLimitByUserSeparatesUsersBehindOneIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if id := c.GetHeader("X-Test-User"); id != "" {
+			c.Set(UserKey, &models.AuthUser{ID: id})
+		}
+	})
+	r.Use(RateLimitByUserWith("test", 1, 1))
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	do := func(userID string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("X-Test-User", userID)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, do("alice"))
+	assert.Equal(t, http.StatusOK, do("bob"))
+	assert.Equal(t, http.StatusTooManyRequests, do("alice"))
+
+	// Anonymous requests are limited by IP
+	assert.Equal(t, http.StatusOK, do(""))
+	assert.Equal(t, http.StatusTooManyRequests, do(""))
+}
+
+func TestThrottledClientsRaiseOneSecurityEvent(t *testing.T) {
+	events := make(chan *models.SecurityEvent, 10)
+	OnSecurityEvent(func(_ context.Context, event *models.SecurityEvent) { events <- event })
+	t.Cleanup(func() { OnSecurityEvent(nil) })
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RateLimitByIPWith("security-test", 0.001, 1))
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	do := func(ip string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = ip + ":1234"
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, do("192.0.2.7"))
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, http.StatusTooManyRequests, do("192.0.2.7"))
+	}
+
+	select {
+	case event := <-events:
+		assert.Equal(t, models.SecurityRateLimited, event.Type)
+		assert.Equal(t, "192.0.2.7", event.IPAddress)
+		assert.Contains(t, event.Detail, "security-test")
+	case <-time.After(time.Second):
+		t.Fatal("no security event raised")
+	}
+	select {
+	case event := <-events:
+		t.Fatalf("throttled client raised a second event: %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
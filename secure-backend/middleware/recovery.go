@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"secure-backend/errorreport"
+	"secure-backend/models"
+	"secure-backend/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Recovery middleware turns panics into 500 responses, like gin.Recovery, and reports them
+// with their stack to the error tracker
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
+		// Leave out this function and gin's deferred recover, so the stack starts at the panic
+		event := requestEvent(c, errorreport.LevelFatal, fmt.Sprint(recovered))
+		event.ErrorType = fmt.Sprintf("%T", recovered)
+		event.Stack = errorreport.Stack(2)
+		event.Status = http.StatusInternalServerError
+		errorreport.Report(event)
+
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	})
+}
+
+// requestEvent returns an error report carrying the request ID, route, user and trace of c
+func requestEvent(c *gin.Context, level, message string) *errorreport.Event {
+	event := &errorreport.Event{
+		Level:     level,
+		Message:   message,
+		RequestID: c.GetString(RequestIDKey),
+		Method:    c.Request.Method,
+		Route:     c.FullPath(),
+		Status:    c.Writer.Status(),
+	}
+	if user, ok := c.Get(UserKey); ok {
+		if authUser, ok := user.(*models.AuthUser); ok {
+			event.UserID = authUser.ID
+		}
+	}
+	if sc := tracing.FromContext(c.Request.Context()).Context(); sc.IsValid() {
+		event.TraceID = hex.EncodeToString(sc.TraceID[:])
+	}
+	return event
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"secure-backend/errorreport"
+	"secure-backend/models"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeReporter collects reported events
+type fakeReporter struct {
+	mu     sync.Mutex
+	events []*errorreport.Event
+}
+
+func (f *fakeReporter) Report(event *errorreport.Event) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, event)
+}
+
+func (f *fakeReporter) Flush(context.Context) error { return nil }
+
+func panickingHandler(c *gin.Context) {
+	panic("boom")
+}
+
+func TestPanicsAndServerErrorsAreReported(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	reporter := &fakeReporter{}
+	errorreport.SetReporter(reporter)
+	t.Cleanup(func() { errorreport.SetReporter(nil) })
+
+	r := gin.New()
+	r.Use(Recovery(), RequestID(), ErrorHandler())
+	withUser := func(c *gin.Context) {
+		c.Set(UserKey, &models.AuthUser{ID: "user-1", Role: "buyer"})
+	}
+	r.GET("/orders/:id", withUser, panickingHandler)
+	r.POST("/orders/:id/refunds", withUser, func(c *gin.Context) {
+		c.Error(errors.New("payment provider unavailable"))
+		c.Status(http.StatusBadGateway)
+	})
+	r.GET("/products/:id", func(c *gin.Context) {
+		c.Status(http.StatusServiceUnavailable)
+	})
+	r.GET("/missing", func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+	})
+
+	for _, request := range []struct{ method, path string }{
+		{http.MethodGet, "/orders/42?token=secret"},
+		{http.MethodPost, "/orders/42/refunds"},
+		{http.MethodGet, "/products/7"},
+		{http.MethodGet, "/missing"},
+	} {
+		req := httptest.NewRequest(request.method, request.path, nil)
+		req.Header.Set(RequestIDHeader, "req-"+request.method)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if strings.HasPrefix(request.path, "/orders/42?") {
+			assert.Equal(t, http.StatusInternalServerError, w.Code)
+			assert.JSONEq(t, `{"error":"Internal server error"}`, w.Body.String())
+		}
+	}
+
+	require.Len(t, reporter.events, 3)
+
+	panicked := reporter.events[0]
+	assert.Equal(t, errorreport.LevelFatal, panicked.Level)
+	assert.Equal(t, "boom", panicked.Message)
+	assert.Equal(t, "string", panicked.ErrorType)
+	assert.Equal(t, "req-GET", panicked.RequestID)
+	assert.Equal(t, "user-1", panicked.UserID)
+	assert.Equal(t, "/orders/:id", panicked.Route)
+	assert.Equal(t, http.StatusInternalServerError, panicked.Status)
+	require.NotEmpty(t, panicked.Stack)
+	assert.True(t, strings.HasSuffix(panicked.Stack[0].Function, ".panickingHandler"), panicked.Stack[0].Function)
+
+	failed := reporter.events[1]
+	assert.Equal(t, errorreport.LevelError, failed.Level)
+	assert.Equal(t, "payment provider unavailable", failed.Message)
+	assert.Equal(t, "user-1", failed.UserID)
+	assert.Equal(t, "/orders/:id/refunds", failed.Route)
+	assert.Equal(t, http.StatusBadGateway, failed.Status)
+	assert.Empty(t, failed.Stack)
+
+	unavailable := reporter.events[2]
+	assert.Equal(t, "GET /products/:id responded 503", unavailable.Message)
+	assert.Empty(t, unavailable.UserID)
+}
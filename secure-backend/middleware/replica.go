@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"net/http"
+	"secure-backend/database"
+	"secure-backend/models"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// writeSweepInterval is how often expired entries are dropped from recentWrites
+const writeSweepInterval = time.Minute
+
+// recentWrites remembers when each user last made a write request, so their reads stay on
+// the primary until the read replica has had time to receive the change
+var recentWrites = struct {
+	sync.Mutex
+	at        map[string]time.Time
+	lastSweep time.Time
+}{at: make(map[string]time.Time)}
+
+// TrackWrites records the time of each authenticated request that may write (anything but
+// GET, HEAD and OPTIONS). It must run after SupabaseAuthMiddleware.
+func TrackWrites() gin.HandlerFunc {
+	window := database.ReplicaMaxLag()
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if user, ok := authUser(c); ok {
+				recordWrite(user.ID, time.Now(), window)
+			}
+		}
+		c.Next()
+	}
+}
+
+// ReadReplica lets the route's reads use the read replica, unless the user wrote within
+// DATABASE_REPLICA_MAX_LAG and the replica may not have their change yet. Only routes whose
+// reads tolerate that much staleness should use it.
+func ReadReplica() gin.HandlerFunc {
+	window := database.ReplicaMaxLag()
+	return func(c *gin.Context) {
+		if user, ok := authUser(c); ok && wroteWithin(user.ID, time.Now(), window) {
+			c.Next()
+			return
+		}
+		c.Request = c.Request.WithContext(database.PreferReplica(c.Request.Context()))
+		c.Next()
+	}
+}
+
+// authUser returns the authenticated user of the request, if any
+func authUser(c *gin.Context) (*models.AuthUser, bool) {
+	value, ok := c.Get(UserKey)
+	user, isUser := value.(*models.AuthUser)
+	return user, ok && isUser && user.ID != ""
+}
+
+// recordWrite notes that the user wrote at now, dropping entries older than window once per
+// writeSweepInterval
+func recordWrite(userID string, now time.Time, window time.Duration) {
+	recentWrites.Lock()
+	defer recentWrites.Unlock()
+
+	recentWrites.at[userID] = now
+	if now.Sub(recentWrites.lastSweep) < writeSweepInterval {
+		return
+	}
+	for id, at := range recentWrites.at {
+		if now.Sub(at) >= window {
+			delete(recentWrites.at, id)
+		}
+	}
+	recentWrites.lastSweep = now
+}
+
+// wroteWithin reports whether the user wrote less than window before now
+func wroteWithin(userID string, now time.Time, window time.Duration) bool {
+	recentWrites.Lock()
+	defer recentWrites.Unlock()
+
+	at, ok := recentWrites.at[userID]
+	return ok && now.Sub(at) < window
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecentWritesExpire(t *testing.T) {
+	now := time.Now()
+	window := 5 * time.Second
+	recordWrite("writer", now, window)
+
+	assert.True(t, wroteWithin("writer", now.Add(time.Second), window))
+	assert.False(t, wroteWithin("writer", now.Add(window), window))
+	assert.False(t, wroteWithin("reader", now, window))
+
+	// A later write sweeps the entries that expired
+	recordWrite("other", now.Add(writeSweepInterval+window), window)
+	recentWrites.Lock()
+	_, kept := recentWrites.at["writer"]
+	recentWrites.Unlock()
+	assert.False(t, kept)
+}
@@ -0,0 +1,296 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/url"
+	"os"
+	"regexp"
+	"secure-backend/database"
+	"secure-backend/models"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxAuditBodySize is the largest request or response body kept in the request audit log.
+// Larger bodies are recorded by size only, as a cut JSON document can't be redacted reliably.
+const maxAuditBodySize = 64 << 10
+
+// redactedValue replaces the values of sensitive fields
+const redactedValue = "[redacted]"
+
+// skipAuditKey marks requests whose response isn't recorded
+const skipAuditKey = "SkipRequestAudit"
+
+// recordRequestAudit stores request audit entries; tests replace it
+var recordRequestAudit = database.RecordRequestAudit
+
+// sensitiveFields are the field names, lowercased without "_" and "-", whose values are
+// redacted wherever they appear: credentials, contact details and postal addresses
+var sensitiveFields = []string{
+	"token", "password", "secret", "apikey", "authorization", "cookie", "signature", "otp",
+	"email", "phone", "address", "street", "postalcode", "zip", "city",
+}
+
+var (
+	emailPattern  = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	bearerPattern = regexp.MustCompile(`(?i)bearer\s+\S+`)
+	jwtPattern    = regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	// Runs of 32 or more token characters: API keys, hex digests, signatures. UUIDs, whose
+	// groups are at most 12 characters, are kept.
+	tokenPattern = regexp.MustCompile(`[A-Za-z0-9_+/=]{32,}`)
+)
+
+// AuditRequests records the requests to the routes listed in REQUEST_AUDIT_ROUTES
+// (comma-separated route prefixes such as /api/admin/) in the request audit log, with their
+// JSON or form bodies, the response body, status and caller. Tokens, email addresses and
+// postal addresses are redacted before the entry is stored. Without REQUEST_AUDIT_ROUTES
+// nothing is recorded. Entries are written in the background, so a failing audit store
+// doesn't fail the request.
+func AuditRequests() gin.HandlerFunc {
+	prefixes := auditedRoutes()
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" || !hasAnyPrefix(route, prefixes) {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		requestBody := captureRequestBody(c)
+		writer := &auditWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		c.Next()
+		if c.GetBool(skipAuditKey) {
+			return
+		}
+
+		entry := &models.RequestAudit{
+			RequestID:  c.GetString(RequestIDKey),
+			Method:     c.Request.Method,
+			Route:      route,
+			Path:       c.Request.URL.Path,
+			Status:     c.Writer.Status(),
+			IPAddress:  c.ClientIP(),
+			DurationMs: int(time.Since(start).Milliseconds()),
+		}
+		if value, ok := c.Get(UserKey); ok {
+			if user, ok := value.(*models.AuthUser); ok && user.ID != "" {
+				entry.UserID = &user.ID
+			}
+		}
+		query := c.Request.URL.RawQuery
+		requestType := c.ContentType()
+		responseType := writer.Header().Get("Content-Type")
+		ctx := context.WithoutCancel(c.Request.Context())
+
+		go func() {
+			if query != "" {
+				entry.Path += "?" + redactQuery(query)
+			}
+			entry.RequestBody = redactBody(requestType, requestBody)
+			entry.ResponseBody = redactBody(responseType, writer.captured())
+			if err := recordRequestAudit(ctx, entry); err != nil {
+				log.Printf("Failed to audit %s %s: %v", entry.Method, entry.Route, err)
+			}
+		}()
+	}
+}
+
+// SkipRequestAudit keeps the request out of the request audit log. The audit log's own
+// listing calls it, so reading the log doesn't copy it into itself.
+func SkipRequestAudit(c *gin.Context) {
+	c.Set(skipAuditKey, true)
+}
+
+// auditedRoutes parses REQUEST_AUDIT_ROUTES. A trailing "*" is ignored, so /api/admin/* and
+// /api/admin/ are the same prefix.
+func auditedRoutes() []string {
+	var prefixes []string
+	for _, entry := range strings.Split(os.Getenv("REQUEST_AUDIT_ROUTES"), ",") {
+		if entry = strings.TrimSuffix(strings.TrimSpace(entry), "*"); entry != "" {
+			prefixes = append(prefixes, entry)
+		}
+	}
+	return prefixes
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// capturedBody is a request or response body as read, up to maxAuditBodySize
+type capturedBody struct {
+	data []byte
+	size int  // bytes seen, including those past the limit
+	cut  bool // the body was larger than maxAuditBodySize
+}
+
+// captureRequestBody reads a JSON or form request body up to the limit and puts it back for
+// the handler. Other bodies (file uploads) are recorded by content type only, without
+// buffering them.
+func captureRequestBody(c *gin.Context) capturedBody {
+	if c.Request.Body == nil || !auditableType(c.ContentType()) {
+		return capturedBody{}
+	}
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxAuditBodySize+1))
+	c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(data), c.Request.Body), c.Request.Body}
+	if err != nil {
+		return capturedBody{}
+	}
+	body := capturedBody{data: data, size: len(data)}
+	if len(data) > maxAuditBodySize {
+		body.data, body.cut = nil, true
+	}
+	return body
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// auditWriter copies the response body as it is written, up to the limit
+type auditWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+	size int
+}
+
+func (w *auditWriter) Write(data []byte) (int, error) {
+	w.keep(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *auditWriter) WriteString(s string) (int, error) {
+	w.keep([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *auditWriter) keep(data []byte) {
+	w.size += len(data)
+	if room := maxAuditBodySize - w.body.Len(); room > 0 {
+		w.body.Write(data[:min(room, len(data))])
+	}
+}
+
+func (w *auditWriter) captured() capturedBody {
+	if w.size > maxAuditBodySize {
+		return capturedBody{size: w.size, cut: true}
+	}
+	return capturedBody{data: w.body.Bytes(), size: w.size}
+}
+
+// auditableType reports whether bodies of the media type are kept: JSON and forms
+func auditableType(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "application/json" || mediaType == "application/x-www-form-urlencoded"
+}
+
+// redactBody returns a body as stored in the audit log: JSON and forms with their sensitive
+// fields and values redacted, other bodies as a placeholder with their type and size
+func redactBody(contentType string, body capturedBody) string {
+	if body.size == 0 {
+		return ""
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "unknown"
+	}
+	if body.cut {
+		return fmt.Sprintf("[%s body over %d bytes not recorded]", mediaType, maxAuditBodySize)
+	}
+	if !auditableType(mediaType) {
+		return fmt.Sprintf("[%s body of %d bytes not recorded]", mediaType, body.size)
+	}
+
+	if mediaType == "application/x-www-form-urlencoded" {
+		return redactQuery(string(body.data))
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body.data))
+	decoder.UseNumber()
+	var document any
+	if err := decoder.Decode(&document); err != nil {
+		return fmt.Sprintf("[invalid JSON body of %d bytes not recorded]", body.size)
+	}
+	redacted, err := json.Marshal(redactJSON(document))
+	if err != nil {
+		return fmt.Sprintf("[JSON body of %d bytes not recorded]", body.size)
+	}
+	return string(redacted)
+}
+
+// redactJSON replaces the values of sensitive fields, whole objects included, and masks
+// email addresses and tokens in the remaining strings
+func redactJSON(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, field := range value {
+			if sensitiveField(key) {
+				value[key] = redactedValue
+			} else {
+				value[key] = redactJSON(field)
+			}
+		}
+		return value
+	case []any:
+		for i, item := range value {
+			value[i] = redactJSON(item)
+		}
+		return value
+	case string:
+		return redactText(value)
+	default:
+		return value
+	}
+}
+
+// redactQuery redacts a URL-encoded query string or form body field by field
+func redactQuery(raw string) string {
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return redactedValue
+	}
+	for key, list := range values {
+		for i, value := range list {
+			if sensitiveField(key) {
+				list[i] = redactedValue
+			} else {
+				list[i] = redactText(value)
+			}
+		}
+	}
+	return values.Encode()
+}
+
+// redactText masks email addresses, bearer credentials, JWTs and token-like strings
+func redactText(s string) string {
+	s = emailPattern.ReplaceAllString(s, "[redacted email]")
+	s = bearerPattern.ReplaceAllString(s, "[redacted token]")
+	s = jwtPattern.ReplaceAllString(s, "[redacted token]")
+	return tokenPattern.ReplaceAllString(s, "[redacted token]")
+}
+
+// sensitiveField reports whether a field name contains one of the sensitive names
+func sensitiveField(name string) bool {
+	name = strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(name))
+	for _, sensitive := range sensitiveFields {
+		if strings.Contains(name, sensitive) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"secure-backend/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditRequestsRedactsBodies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("REQUEST_AUDIT_ROUTES", "/api/admin/*")
+
+	entries := make(chan *models.RequestAudit, 10)
+	record := recordRequestAudit
+	recordRequestAudit = func(_ context.Context, entry *models.RequestAudit) error {
+		entries <- entry
+		return nil
+	}
+	t.Cleanup(func() { recordRequestAudit = record })
+
+	r := gin.New()
+	r.Use(AuditRequests())
+	r.POST("/api/admin/users/:id/role", func(c *gin.Context) {
+		c.Set(UserKey, &models.AuthUser{ID: "admin-1"})
+		var body map[string]any
+		require.NoError(t, c.ShouldBindJSON(&body))
+		c.JSON(http.StatusOK, gin.H{
+			"role":  body["role"],
+			"email": "alice@example.com",
+			"shipping_address": gin.H{
+				"street": "1 Main St", "city": "Springfield",
+			},
+			"note": "contact bob@example.com, token eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxMjM0NTY3ODkwIn0.abc",
+		})
+	})
+	r.GET("/api/admin/request-audit", func(c *gin.Context) {
+		SkipRequestAudit(c)
+		c.Status(http.StatusOK)
+	})
+	r.GET("/api/products", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/users/u-1/role?email=alice@example.com&page=2",
+		strings.NewReader(`{"role":"seller","password":"hunter2","reason":"requested"}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "alice@example.com", "the client gets the unredacted response")
+
+	var entry *models.RequestAudit
+	select {
+	case entry = <-entries:
+	case <-time.After(time.Second):
+		t.Fatal("request not audited")
+	}
+	assert.Equal(t, "/api/admin/users/:id/role", entry.Route)
+	assert.Equal(t, "/api/admin/users/u-1/role?email=%5Bredacted%5D&page=2", entry.Path)
+	require.NotNil(t, entry.UserID)
+	assert.Equal(t, "admin-1", *entry.UserID)
+	assert.Equal(t, http.StatusOK, entry.Status)
+	assert.JSONEq(t, `{"role":"seller","password":"[redacted]","reason":"requested"}`, entry.RequestBody)
+	assert.JSONEq(t, `{
+		"role": "seller",
+		"email": "[redacted]",
+		"shipping_address": "[redacted]",
+		"note": "contact [redacted email], token [redacted token]"
+	}`, entry.ResponseBody)
+
+	for _, path := range []string{"/api/admin/request-audit", "/api/products"} {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+	select {
+	case entry := <-entries:
+		t.Fatalf("unexpected audit entry for %s", entry.Route)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestRedactBodySkipsUnsupportedAndLargeBodies(t *testing.T) {
+	assert.Equal(t, "", redactBody("application/json", capturedBody{}))
+	assert.Equal(t, "[application/pdf body of 3 bytes not recorded]",
+		redactBody("application/pdf", capturedBody{data: []byte("pdf"), size: 3}))
+	assert.Equal(t, "[application/json body over 65536 bytes not recorded]",
+		redactBody("application/json; charset=utf-8", capturedBody{size: maxAuditBodySize + 1, cut: true}))
+	assert.Equal(t, "name=Bob&phone=%5Bredacted%5D",
+		redactBody("application/x-www-form-urlencoded", capturedBody{data: []byte("phone=555-0100&name=Bob"), size: 23}))
+}
@@ -1,8 +1,10 @@
 package middleware
 
 import (
+	"secure-backend/idgen"
+	"secure-backend/outbound"
+
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
 const (
@@ -14,16 +16,23 @@ const (
 
 // RequestID middleware adds a unique request ID to each request
 func RequestID() gin.HandlerFunc {
+	return RequestIDWith(idgen.UUID())
+}
+
+// RequestIDWith is RequestID with a custom generator for new request IDs
+func RequestIDWith(ids idgen.Generator) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Check if request ID exists in header
 		requestID := c.GetHeader(RequestIDHeader)
 		if requestID == "" {
-			requestID = uuid.New().String()
+			requestID = ids.NewID()
 		}
 
-		// Add request ID to context and response headers
+		// Add request ID to context and response headers, and tag outbound
+		// integration calls made while handling the request with it
 		c.Set(RequestIDKey, requestID)
 		c.Header(RequestIDHeader, requestID)
+		c.Request = c.Request.WithContext(outbound.WithCorrelationID(c.Request.Context(), requestID))
 		c.Next()
 	}
 }
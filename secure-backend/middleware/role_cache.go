@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"os"
+	"secure-backend/clock"
+	"secure-backend/database"
+	"sync"
+	"time"
+)
+
+// defaultRoleCacheTTL is how long a user's role is cached when ROLE_CACHE_TTL is unset
+const defaultRoleCacheTTL = time.Minute
+
+// RoleCache keeps user roles in memory so authenticated requests don't each query the
+// users table. Entries expire after the TTL, which bounds how long other instances serve
+// a stale role; the instance that changes a role invalidates it right away.
+type RoleCache struct {
+	cache *ttlCache[string]
+}
+
+// NewRoleCache creates a cache that loads missing roles with load
+func NewRoleCache(ttl time.Duration, load func(ctx context.Context, userID string) (string, error), clk clock.Clock) *RoleCache {
+	return &RoleCache{cache: newTTLCache(ttl, load, clk)}
+}
+
+// Role returns the user's role from the cache, loading it on a miss. Lookup errors are
+// not cached.
+func (rc *RoleCache) Role(ctx context.Context, userID string) (string, error) {
+	return rc.cache.get(ctx, userID)
+}
+
+// Invalidate drops the cached role of a user so the next request reloads it
+func (rc *RoleCache) Invalidate(userID string) {
+	rc.cache.invalidate(userID)
+}
+
+var (
+	rolesOnce sync.Once
+	roles     *RoleCache
+)
+
+// roleCache returns the process-wide role cache, configured from ROLE_CACHE_TTL (a
+// duration, default 1m; 0 disables caching) on first use
+func roleCache() *RoleCache {
+	rolesOnce.Do(func() {
+		ttl := defaultRoleCacheTTL
+		if value := os.Getenv("ROLE_CACHE_TTL"); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil || parsed < 0 {
+				log.Printf("Invalid ROLE_CACHE_TTL %q, using %s", value, defaultRoleCacheTTL)
+			} else {
+				ttl = parsed
+			}
+		}
+		roles = NewRoleCache(ttl, database.GetUserRole, clock.System())
+	})
+	return roles
+}
+
+// cachedUserRole looks up a user's role through the role cache
+func cachedUserRole(ctx context.Context, userID string) (string, error) {
+	return roleCache().Role(ctx, userID)
+}
+
+// InvalidateUserRole drops a user's cached role; call it after changing the role
+func InvalidateUserRole(userID string) {
+	roleCache().Invalidate(userID)
+}
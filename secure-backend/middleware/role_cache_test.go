@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"secure-backend/clock"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleCache(t *testing.T) {
+	clk := clock.NewMock(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	role, loads := "buyer", 0
+	var loadErr error
+	cache := NewRoleCache(time.Minute, func(_ context.Context, userID string) (string, error) {
+		loads++
+		return role, loadErr
+	}, clk)
+
+	got, err := cache.Role(context.Background(), "user-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "buyer", got)
+
+	// Cached until the TTL passes
+	role = "seller"
+	got, _ = cache.Role(context.Background(), "user-1")
+	assert.Equal(t, "buyer", got)
+	assert.Equal(t, 1, loads)
+
+	clk.Advance(time.Minute)
+	got, _ = cache.Role(context.Background(), "user-1")
+	assert.Equal(t, "seller", got)
+	assert.Equal(t, 2, loads)
+
+	// Invalidation reloads on the next lookup
+	role = "admin"
+	cache.Invalidate("user-1")
+	got, _ = cache.Role(context.Background(), "user-1")
+	assert.Equal(t, "admin", got)
+
+	// Errors are not cached
+	loadErr = errors.New("user not found")
+	_, err = cache.Role(context.Background(), "user-2")
+	assert.Error(t, err)
+	loadErr = nil
+	got, err = cache.Role(context.Background(), "user-2")
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", got)
+	assert.Equal(t, 5, loads)
+}
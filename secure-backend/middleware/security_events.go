@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"secure-backend/models"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// throttleEventWindow is how long after recording a throttled client it isn't recorded again
+// for the same limit, so a client hammering an endpoint produces one event, not thousands
+const throttleEventWindow = 10 * time.Minute
+
+var (
+	securityMu       sync.RWMutex
+	securityRecorder func(context.Context, *models.SecurityEvent)
+
+	throttledMu sync.Mutex
+	throttled   = map[string]time.Time{}
+)
+
+// OnSecurityEvent sets where the security events raised by middleware (throttled clients,
+// JWKS failures) are recorded. They are dropped until it is called.
+func OnSecurityEvent(record func(context.Context, *models.SecurityEvent)) {
+	securityMu.Lock()
+	defer securityMu.Unlock()
+	securityRecorder = record
+}
+
+// raiseSecurityEvent hands an event to the recorder in the background, with the values but
+// not the cancellation of ctx
+func raiseSecurityEvent(ctx context.Context, event *models.SecurityEvent) {
+	securityMu.RLock()
+	record := securityRecorder
+	securityMu.RUnlock()
+	if record != nil {
+		go record(context.WithoutCancel(ctx), event)
+	}
+}
+
+// recordThrottled raises a rate_limited event for a client refused by the limit of scope,
+// at most once per throttleEventWindow for each client and limit
+func recordThrottled(c *gin.Context, scope, key string) {
+	now := time.Now()
+	throttledMu.Lock()
+	if last, ok := throttled[scope+"|"+key]; ok && now.Sub(last) < throttleEventWindow {
+		throttledMu.Unlock()
+		return
+	}
+	throttled[scope+"|"+key] = now
+	if len(throttled) > 10000 {
+		for k, last := range throttled {
+			if now.Sub(last) >= throttleEventWindow {
+				delete(throttled, k)
+			}
+		}
+	}
+	throttledMu.Unlock()
+
+	event := &models.SecurityEvent{
+		Type:      models.SecurityRateLimited,
+		Severity:  models.SeverityMedium,
+		IPAddress: c.ClientIP(),
+		Detail:    fmt.Sprintf("%s %s throttled by the %s limit", c.Request.Method, c.Request.URL.Path, scope),
+	}
+	if value, ok := c.Get(UserKey); ok {
+		if user, ok := value.(*models.AuthUser); ok && user.ID != "" {
+			event.UserID = &user.ID
+		}
+	}
+	raiseSecurityEvent(c.Request.Context(), event)
+}
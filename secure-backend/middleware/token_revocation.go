@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"context"
+	"secure-backend/clock"
+	"secure-backend/database"
+	"sync"
+	"time"
+)
+
+// RevocationCache keeps the token revocation cut-off of users in memory, next to their role,
+// so checking for revoked tokens doesn't query the database on every request. Entries expire
+// after the same TTL as roles; the instance that revokes a user's tokens invalidates the entry
+// right away.
+type RevocationCache struct {
+	cache *ttlCache[*time.Time]
+}
+
+// NewRevocationCache creates a cache that loads missing cut-offs with load
+func NewRevocationCache(ttl time.Duration, load func(ctx context.Context, userID string) (*time.Time, error), clk clock.Clock) *RevocationCache {
+	return &RevocationCache{cache: newTTLCache(ttl, load, clk)}
+}
+
+// RevokedBefore returns the time before which the user's tokens are refused, or nil if they
+// were never revoked. Lookup errors are not cached.
+func (rc *RevocationCache) RevokedBefore(ctx context.Context, userID string) (*time.Time, error) {
+	return rc.cache.get(ctx, userID)
+}
+
+// Invalidate drops the cached cut-off of a user so the next request reloads it
+func (rc *RevocationCache) Invalidate(userID string) {
+	rc.cache.invalidate(userID)
+}
+
+var (
+	revocationsOnce sync.Once
+	revocations     *RevocationCache
+)
+
+// revocationCache returns the process-wide revocation cache, which shares ROLE_CACHE_TTL
+// with the role cache
+func revocationCache() *RevocationCache {
+	revocationsOnce.Do(func() {
+		revocations = NewRevocationCache(roleCache().cache.ttl, database.GetTokenRevokedBefore, clock.System())
+	})
+	return revocations
+}
+
+// cachedTokenRevokedBefore looks up a user's token revocation cut-off through the cache
+func cachedTokenRevokedBefore(ctx context.Context, userID string) (*time.Time, error) {
+	return revocationCache().RevokedBefore(ctx, userID)
+}
+
+// InvalidateTokenRevocation drops a user's cached revocation cut-off; call it after revoking
+// their tokens
+func InvalidateTokenRevocation(userID string) {
+	revocationCache().Invalidate(userID)
+}
+
+// tokenRevoked reports whether a token issued at issuedAt (nil if the token has no iat claim)
+// was revoked for the user. Tokens without an issue time are refused once any cut-off is set.
+func tokenRevoked(ctx context.Context, userID string, issuedAt *time.Time) (bool, error) {
+	revokedBefore, err := tokenRevokedBefore(ctx, userID)
+	if err != nil || revokedBefore == nil {
+		return false, err
+	}
+	return issuedAt == nil || issuedAt.Before(*revokedBefore), nil
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"fmt"
+	"secure-backend/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Tracing starts a server span for each request, continuing the caller's trace when the
+// request carries a traceparent header. Database and integration calls made with the
+// request's context become its child spans. Does nothing while tracing is off.
+func Tracing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !tracing.Enabled() {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		if parent, ok := tracing.ParseTraceparent(c.GetHeader(tracing.TraceparentHeader)); ok {
+			ctx = tracing.WithRemoteParent(ctx, parent)
+		}
+
+		// Name spans after the route rather than the path so IDs don't make each one unique
+		route := c.FullPath()
+		name := c.Request.Method + " " + route
+		if route == "" {
+			name = c.Request.Method
+		}
+
+		ctx, span := tracing.Start(ctx, name, tracing.KindServer)
+		defer span.End()
+		span.SetAttribute("http.request.method", c.Request.Method)
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("url.path", c.Request.URL.Path)
+		span.SetAttribute("request.id", c.GetString(RequestIDKey))
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttribute("http.response.status_code", status)
+		if status >= 500 {
+			span.SetError(fmt.Errorf("%d response", status))
+		}
+	}
+}
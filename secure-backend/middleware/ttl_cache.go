@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"context"
+	"secure-backend/clock"
+	"sync"
+	"time"
+)
+
+// maxCacheEntries bounds each per-user cache; expired entries are swept when it fills up
+const maxCacheEntries = 100000
+
+// ttlCache keeps a value per user in memory, loaded on a miss and kept for the TTL. It backs
+// the role and token revocation caches.
+type ttlCache[V any] struct {
+	ttl        time.Duration // 0 disables caching
+	load       func(ctx context.Context, userID string) (V, error)
+	clock      clock.Clock
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]ttlEntry[V]
+}
+
+type ttlEntry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+func newTTLCache[V any](ttl time.Duration, load func(ctx context.Context, userID string) (V, error), clk clock.Clock) *ttlCache[V] {
+	return &ttlCache[V]{ttl: ttl, load: load, clock: clk, maxEntries: maxCacheEntries, entries: map[string]ttlEntry[V]{}}
+}
+
+// get returns the user's value from the cache, loading it on a miss. Lookup errors are not
+// cached.
+func (tc *ttlCache[V]) get(ctx context.Context, userID string) (V, error) {
+	if tc.ttl <= 0 {
+		return tc.load(ctx, userID)
+	}
+
+	now := tc.clock.Now()
+	tc.mu.Lock()
+	entry, ok := tc.entries[userID]
+	tc.mu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.value, nil
+	}
+
+	value, err := tc.load(ctx, userID)
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if len(tc.entries) >= tc.maxEntries {
+		for id, e := range tc.entries {
+			if !now.Before(e.expiresAt) {
+				delete(tc.entries, id)
+			}
+		}
+		if len(tc.entries) >= tc.maxEntries {
+			tc.entries = map[string]ttlEntry[V]{}
+		}
+	}
+	tc.entries[userID] = ttlEntry[V]{value: value, expiresAt: now.Add(tc.ttl)}
+	return value, nil
+}
+
+// invalidate drops the cached value of a user so the next lookup reloads it
+func (tc *ttlCache[V]) invalidate(userID string) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	delete(tc.entries, userID)
+}
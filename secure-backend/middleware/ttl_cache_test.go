@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"context"
+	"secure-backend/clock"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTTLCacheSweepsWhenFull(t *testing.T) {
+	clk := clock.NewMock(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	loads := map[string]int{}
+	cache := newTTLCache(time.Minute, func(_ context.Context, userID string) (int, error) {
+		loads[userID]++
+		return loads[userID], nil
+	}, clk)
+	cache.maxEntries = 2
+
+	cache.get(context.Background(), "user-1")
+	clk.Advance(30 * time.Second)
+	cache.get(context.Background(), "user-2")
+	clk.Advance(30 * time.Second)
+
+	// user-1 expired: it is swept to make room, user-2 stays cached
+	cache.get(context.Background(), "user-3")
+	assert.Len(t, cache.entries, 2)
+	assert.NotContains(t, cache.entries, "user-1")
+	got, _ := cache.get(context.Background(), "user-2")
+	assert.Equal(t, 1, got)
+
+	// Nothing expired: the cache starts over rather than growing
+	cache.get(context.Background(), "user-4")
+	assert.Len(t, cache.entries, 1)
+	assert.Contains(t, cache.entries, "user-4")
+}
+
+func TestTTLCacheDisabled(t *testing.T) {
+	loads := 0
+	cache := newTTLCache(0, func(_ context.Context, userID string) (string, error) {
+		loads++
+		return "buyer", nil
+	}, clock.System())
+
+	cache.get(context.Background(), "user-1")
+	cache.get(context.Background(), "user-1")
+	assert.Equal(t, 2, loads)
+	assert.Empty(t, cache.entries)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"secure-backend/database"
+)
+
+// runMigrate handles the migrate subcommand, for deploys that apply schema changes as a
+// separate step (with DB_AUTO_MIGRATE=false):
+//
+//	main migrate          apply pending migrations
+//	main migrate status   list pending migrations, exiting 1 if there are any
+func runMigrate(args []string) {
+	if os.Getenv("DATABASE_URL") == "" {
+		log.Fatal("DATABASE_URL environment variable is required")
+	}
+	if err := database.InitDB(); err != nil {
+		log.Fatal("Failed to initialize database:", err)
+	}
+	defer database.DB.Close()
+	ctx := context.Background()
+
+	switch {
+	case len(args) == 0:
+		applied, err := database.Migrate(ctx)
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		fmt.Printf("Schema is up to date (%d migrations applied)\n", len(applied))
+
+	case len(args) == 1 && args[0] == "status":
+		pending, err := database.PendingMigrations(ctx)
+		if err != nil {
+			log.Fatalf("Failed to check migrations: %v", err)
+		}
+		if len(pending) == 0 {
+			fmt.Println("Schema is up to date")
+			return
+		}
+		for _, version := range pending {
+			fmt.Printf("pending: %s\n", version)
+		}
+		os.Exit(1)
+
+	default:
+		fmt.Fprintln(os.Stderr, "usage: main migrate [status]")
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Order address change statuses
+const (
+	AddressChangeApplied   = "applied"           // buyer edited the address within the window
+	AddressChangeRequested = "support_requested" // outside the window; waiting for support
+	AddressChangeApproved  = "approved"          // support applied the requested address
+	AddressChangeRejected  = "rejected"          // support declined the request
+)
+
+// Reasons a self-service address edit was routed to support
+const (
+	AddressReasonWindowClosed = "edit_window_closed"
+	AddressReasonFulfillment  = "fulfillment_started"
+)
+
+// ErrAddressLocked is returned for orders whose address can't change at all (cancelled,
+// refunded or delivered)
+var ErrAddressLocked = errors.New("the shipping address of this order can no longer be changed")
+
+// OrderAddressChange records an edit of an order's shipping address, or a request for
+// support to make one. Together they are the audit trail of address changes.
+type OrderAddressChange struct {
+	ID             string     `db:"id" json:"id"`
+	OrderID        string     `db:"order_id" json:"order_id"`
+	RequestedBy    string     `db:"requested_by" json:"requested_by"`
+	OldAddress     string     `db:"old_address" json:"old_address"`
+	NewAddress     string     `db:"new_address" json:"new_address"`
+	Status         string     `db:"status" json:"status"`
+	Reason         string     `db:"reason" json:"reason,omitempty"` // why it was routed to support
+	ResolvedBy     *string    `db:"resolved_by" json:"resolved_by,omitempty"`
+	ResolutionNote string     `db:"resolution_note" json:"resolution_note,omitempty"`
+	ResolvedAt     *time.Time `db:"resolved_at" json:"resolved_at,omitempty"`
+	CreatedAt      time.Time  `db:"created_at" json:"created_at"`
+}
+
+// AddressEditDecision decides whether the buyer can edit the order's address themselves.
+// Edits are self-service within window of placing the order and before any item ships;
+// otherwise the returned reason says why the edit goes to support. Orders that are closed
+// return ErrAddressLocked.
+func AddressEditDecision(order Order, fulfillmentStarted bool, window time.Duration, now time.Time) (reason string, err error) {
+	switch order.Status {
+	case "pending", "paid":
+	case "shipped":
+		return AddressReasonFulfillment, nil
+	default:
+		return "", ErrAddressLocked
+	}
+
+	if fulfillmentStarted {
+		return AddressReasonFulfillment, nil
+	}
+	if !now.Before(order.CreatedAt.Add(window)) {
+		return AddressReasonWindowClosed, nil
+	}
+	return "", nil
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAddressEditDecision(t *testing.T) {
+	placed := time.Date(2024, 7, 10, 12, 0, 0, 0, time.UTC)
+	window := 2 * time.Hour
+
+	cases := []struct {
+		name    string
+		status  string
+		started bool
+		now     time.Time
+		reason  string
+		locked  bool
+	}{
+		{"within window", "paid", false, placed.Add(time.Hour), "", false},
+		{"unpaid within window", "pending", false, placed.Add(time.Hour), "", false},
+		{"window closed", "paid", false, placed.Add(window), AddressReasonWindowClosed, false},
+		{"item shipped", "paid", true, placed.Add(time.Hour), AddressReasonFulfillment, false},
+		{"order shipped", "shipped", false, placed.Add(time.Hour), AddressReasonFulfillment, false},
+		{"delivered", "delivered", true, placed.Add(time.Hour), "", true},
+		{"cancelled", "cancelled", false, placed.Add(time.Hour), "", true},
+	}
+
+	for _, tc := range cases {
+		order := Order{Status: tc.status, CreatedAt: placed}
+		reason, err := AddressEditDecision(order, tc.started, window, tc.now)
+		if (err == ErrAddressLocked) != tc.locked {
+			t.Errorf("%s: err = %v, want locked %t", tc.name, err, tc.locked)
+		}
+		if reason != tc.reason {
+			t.Errorf("%s: reason = %q, want %q", tc.name, reason, tc.reason)
+		}
+	}
+}
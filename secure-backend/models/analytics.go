@@ -0,0 +1,40 @@
+package models
+
+import (
+	"secure-backend/money"
+	"time"
+)
+
+// ClientInfo describes the client application that made a request (from the X-Client-Info header)
+type ClientInfo struct {
+	Platform   string `json:"platform"` // ios, android, web or unknown
+	AppVersion string `json:"app_version,omitempty"`
+	Device     string `json:"device,omitempty"`
+}
+
+// CartEvent records a cart action and the client platform that performed it
+type CartEvent struct {
+	ID         string    `db:"id" json:"id"`
+	UserID     string    `db:"user_id" json:"user_id"`
+	Action     string    `db:"action" json:"action"`
+	ProductID  *string   `db:"product_id" json:"product_id,omitempty"`
+	Quantity   int       `db:"quantity" json:"quantity"`
+	Platform   string    `db:"platform" json:"platform"`
+	AppVersion string    `db:"app_version" json:"app_version,omitempty"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
+
+// PlatformActionCount is the number of cart events per platform and action
+type PlatformActionCount struct {
+	Platform string `db:"platform" json:"platform"`
+	Action   string `db:"action" json:"action"`
+	Events   int    `db:"events" json:"events"`
+	Users    int    `db:"users" json:"users"`
+}
+
+// PlatformOrderStats summarizes orders placed from one platform
+type PlatformOrderStats struct {
+	Platform string       `db:"platform" json:"platform"`
+	Orders   int          `db:"orders" json:"orders"`
+	Revenue  money.Amount `db:"revenue" json:"revenue"`
+}
@@ -1,45 +1,202 @@
 package models
 
-import "time"
+import (
+	"secure-backend/money"
+	"time"
+)
 
 // CartItem represents an item in a user's shopping cart
 type CartItem struct {
+	ID           string       `db:"id" json:"id"`
+	UserID       string       `db:"user_id" json:"user_id"`
+	ProductID    string       `db:"product_id" json:"product_id"`
+	Quantity     int          `db:"quantity" json:"quantity"`
+	Version      int64        `db:"version" json:"version"`
+	AddedVersion int64        `db:"added_version" json:"-"`
+	AddedPrice   money.Amount `db:"added_price" json:"added_price"` // product price when last added
+	CreatedAt    time.Time    `db:"created_at" json:"created_at"`
+	UpdatedAt    time.Time    `db:"updated_at" json:"updated_at"`
+}
+
+// Cart item availability, checked against the product's live status and stock
+const (
+	CartItemAvailable       = "available"
+	CartItemUnavailable     = "unavailable"      // unpublished or out of stock
+	CartItemQuantityReduced = "quantity_reduced" // less stock than the quantity in the cart
+)
+
+// CartItemWithProduct represents a cart item with full product details
+type CartItemWithProduct struct {
+	CartItem
+	Product Product `json:"product"`
+
+	// Availability is one of the CartItem* constants; AvailableQuantity is how many can be bought now
+	Availability      string `json:"availability"`
+	AvailableQuantity int    `json:"available_quantity"`
+	// Adjusted is set when GetCart lowered the quantity to the available stock (?adjust=true)
+	Adjusted bool `json:"adjusted,omitempty"`
+	// PriceChanged is set when the product price differs from AddedPrice; PriceDifference is
+	// the current price minus AddedPrice (negative when the price dropped)
+	PriceChanged    bool         `json:"price_changed"`
+	PriceDifference money.Amount `json:"price_difference,omitempty"`
+}
+
+// CheckAvailability sets the item's availability from its product's status and stock, and
+// whether the price changed since the item was added
+func (i *CartItemWithProduct) CheckAvailability() {
+	i.Availability, i.AvailableQuantity = availability(i.Product, i.Quantity)
+
+	i.PriceDifference = i.Product.Price - i.AddedPrice
+	i.PriceChanged = i.PriceDifference != 0
+}
+
+// availability returns how many of quantity can be bought from product now, and the matching CartItem* constant
+func availability(product Product, quantity int) (string, int) {
+	switch {
+	case product.Status != "published" || product.Stock <= 0:
+		return CartItemUnavailable, 0
+	case product.Stock < quantity:
+		return CartItemQuantityReduced, product.Stock
+	default:
+		return CartItemAvailable, quantity
+	}
+}
+
+// CartShare is a snapshot of a buyer's cart shared by link, so other users can import its items
+type CartShare struct {
+	ID        string          `db:"id" json:"id"`
+	UserID    string          `db:"user_id" json:"-"`
+	ExpiresAt time.Time       `db:"expires_at" json:"expires_at"`
+	CreatedAt time.Time       `db:"created_at" json:"created_at"`
+	Items     []CartShareItem `db:"-" json:"items"`
+}
+
+// CartShareItem is a product and quantity of a shared cart; Product is only set when the
+// product still exists
+type CartShareItem struct {
+	ProductID    string   `db:"product_id" json:"product_id"`
+	Quantity     int      `db:"quantity" json:"quantity"`
+	Product      *Product `db:"-" json:"product,omitempty"`
+	Availability string   `db:"-" json:"availability"`
+}
+
+// CheckAvailability sets whether the shared quantity of the product can be bought now
+func (i *CartShareItem) CheckAvailability() {
+	if i.Product == nil {
+		i.Availability = CartItemUnavailable
+		return
+	}
+	i.Availability, _ = availability(*i.Product, i.Quantity)
+}
+
+// SavedItem is a product the buyer moved out of the active cart to buy later
+type SavedItem struct {
 	ID        string    `db:"id" json:"id"`
 	UserID    string    `db:"user_id" json:"user_id"`
 	ProductID string    `db:"product_id" json:"product_id"`
 	Quantity  int       `db:"quantity" json:"quantity"`
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
-	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
 }
 
-// CartItemWithProduct represents a cart item with full product details
-type CartItemWithProduct struct {
-	CartItem
-	Product Product `json:"product"`
+// SavedItemWithProduct is a saved item with product details and availability
+type SavedItemWithProduct struct {
+	SavedItem
+	Product           Product `json:"product"`
+	Availability      string  `json:"availability"`
+	AvailableQuantity int     `json:"available_quantity"`
+}
+
+// CheckAvailability sets the item's availability from its product's status and stock
+func (i *SavedItemWithProduct) CheckAvailability() {
+	i.Availability, i.AvailableQuantity = availability(i.Product, i.Quantity)
+}
+
+// CartSession is an anonymous cart, identified to its client by a signed cart token
+type CartSession struct {
+	ID        string    `db:"id" json:"id"`
+	ExpiresAt time.Time `db:"expires_at" json:"expires_at"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// GuestCartItem is an item in an anonymous cart
+type GuestCartItem struct {
+	ID            string    `db:"id" json:"id"`
+	CartSessionID string    `db:"cart_session_id" json:"-"`
+	ProductID     string    `db:"product_id" json:"product_id"`
+	Quantity      int       `db:"quantity" json:"quantity"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// GuestCartItemWithProduct is a guest cart item with product details and availability
+type GuestCartItemWithProduct struct {
+	GuestCartItem
+	Product           Product `json:"product"`
+	Availability      string  `json:"availability"`
+	AvailableQuantity int     `json:"available_quantity"`
+}
+
+// CheckAvailability sets the item's availability from its product's status and stock
+func (i *GuestCartItemWithProduct) CheckAvailability() {
+	i.Availability, i.AvailableQuantity = availability(i.Product, i.Quantity)
+}
+
+// CartDelta represents the changes to a user's cart since a client-held version
+type CartDelta struct {
+	Version int64                 `json:"version"`
+	Since   int64                 `json:"since"`
+	Added   []CartItemWithProduct `json:"added"`
+	Updated []CartItemWithProduct `json:"updated"`
+	Removed []CartItemRemoval     `json:"removed"`
+}
+
+// CartItemRemoval identifies a cart item that was removed after a given version
+type CartItemRemoval struct {
+	ID        string `db:"cart_item_id" json:"id"`
+	ProductID string `db:"product_id" json:"product_id"`
+	Version   int64  `db:"version" json:"version"`
 }
 
 // Order represents a customer order
 type Order struct {
-	ID              string    `db:"id" json:"id"`
-	UserID          string    `db:"user_id" json:"user_id"`
-	Status          string    `db:"status" json:"status"`
-	TotalAmount     float64   `db:"total_amount" json:"total_amount"`
-	ShippingAddress string    `db:"shipping_address" json:"shipping_address"`
-	CreatedAt       time.Time `db:"created_at" json:"created_at"`
-	UpdatedAt       time.Time `db:"updated_at" json:"updated_at"`
+	ID              string       `db:"id" json:"id"`
+	UserID          string       `db:"buyer_id" json:"user_id"`
+	Status          string       `db:"status" json:"status"`
+	StatusLabel     string       `db:"-" json:"status_label,omitempty"` // Status in the caller's language
+	TotalAmount     money.Amount `db:"total_amount" json:"total_amount"`
+	ShippingAddress string       `db:"shipping_address" json:"shipping_address"`
+	ClientPlatform  string       `db:"client_platform" json:"client_platform"`
+	CreatedAt       time.Time    `db:"created_at" json:"created_at"`
+	UpdatedAt       time.Time    `db:"updated_at" json:"updated_at"`
 }
 
-// OrderItem represents individual items within an order
-type OrderItem struct {
+// OrderStatusChange records a single order status transition for the order timeline
+type OrderStatusChange struct {
 	ID         string    `db:"id" json:"id"`
 	OrderID    string    `db:"order_id" json:"order_id"`
-	ProductID  string    `db:"product_id" json:"product_id"`
-	Quantity   int       `db:"quantity" json:"quantity"`
-	UnitPrice  float64   `db:"unit_price" json:"unit_price"`
-	TotalPrice float64   `db:"total_price" json:"total_price"`
+	FromStatus string    `db:"from_status" json:"from_status"`
+	ToStatus   string    `db:"to_status" json:"to_status"`
+	ActorID    *string   `db:"actor_id" json:"actor_id,omitempty"`
+	ActorRole  string    `db:"actor_role" json:"actor_role"`
+	Note       string    `db:"note" json:"note,omitempty"`
 	CreatedAt  time.Time `db:"created_at" json:"created_at"`
 }
 
+// OrderItem represents individual items within an order
+type OrderItem struct {
+	ID         string       `db:"id" json:"id"`
+	OrderID    string       `db:"order_id" json:"order_id"`
+	ProductID  string       `db:"product_id" json:"product_id"`
+	Quantity   int          `db:"quantity" json:"quantity"`
+	UnitPrice  money.Amount `db:"unit_price" json:"unit_price"`
+	TotalPrice money.Amount `db:"total_price" json:"total_price"`
+	// FulfillmentStatus tracks the seller's progress on this item (pending, shipped, fulfilled)
+	FulfillmentStatus      string    `db:"fulfillment_status" json:"fulfillment_status"`
+	FulfillmentStatusLabel string    `db:"-" json:"fulfillment_status_label,omitempty"`
+	CreatedAt              time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt              time.Time `db:"updated_at" json:"updated_at"`
+}
+
 // OrderWithDetails represents an order with full product and user details
 type OrderWithDetails struct {
 	Order
@@ -52,3 +209,40 @@ type OrderItemWithProduct struct {
 	OrderItem
 	Product Product `json:"product"`
 }
+
+// SellerOrderItem represents an order item for one of a seller's products,
+// together with the order context the seller needs to fulfill it
+type SellerOrderItem struct {
+	OrderItemWithProduct
+	OrderStatus     string    `json:"order_status"`
+	ShippingAddress string    `json:"shipping_address"`
+	OrderedAt       time.Time `json:"ordered_at"`
+	PayoutHeld      bool      `json:"payout_held"` // e.g. while the buyer disputes the payment
+}
+
+// StockReservation holds product stock for a pending order until it is paid or expires
+type StockReservation struct {
+	ID        string    `db:"id" json:"id"`
+	OrderID   string    `db:"order_id" json:"order_id"`
+	ProductID string    `db:"product_id" json:"product_id"`
+	Quantity  int       `db:"quantity" json:"quantity"`
+	Status    string    `db:"status" json:"status"` // active, committed, released
+	ExpiresAt time.Time `db:"expires_at" json:"expires_at"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// CartAbandonment records a cart that went idle with items (or an unpaid checkout) in it,
+// for abandoned-cart reminder emails
+type CartAbandonment struct {
+	ID               string       `db:"id" json:"id"`
+	UserID           string       `db:"user_id" json:"user_id"`
+	CartVersion      int64        `db:"cart_version" json:"cart_version"`
+	ItemCount        int          `db:"item_count" json:"item_count"`
+	Subtotal         money.Amount `db:"subtotal" json:"subtotal"`
+	LastActivityAt   time.Time    `db:"last_activity_at" json:"last_activity_at"`
+	ReleasedOrders   int          `db:"released_orders" json:"released_orders"`
+	NotifiedAt       *time.Time   `db:"notified_at" json:"notified_at,omitempty"`
+	CreatedAt        time.Time    `db:"created_at" json:"created_at"`
+	MarketingConsent bool         `db:"marketing_consent" json:"marketing_consent"` // the user may be sent abandoned-cart emails
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"secure-backend/money"
+	"testing"
+)
+
+func TestCheckAvailability(t *testing.T) {
+	cases := []struct {
+		status        string
+		stock, inCart int
+		want          string
+		available     int
+	}{
+		{"published", 10, 3, CartItemAvailable, 3},
+		{"published", 3, 3, CartItemAvailable, 3},
+		{"published", 2, 5, CartItemQuantityReduced, 2},
+		{"published", 0, 1, CartItemUnavailable, 0},
+		{"draft", 10, 1, CartItemUnavailable, 0},
+		{"archived", 10, 1, CartItemUnavailable, 0},
+	}
+
+	for _, tc := range cases {
+		item := CartItemWithProduct{CartItem: CartItem{Quantity: tc.inCart}, Product: Product{Status: tc.status, Stock: tc.stock}}
+		item.CheckAvailability()
+		if item.Availability != tc.want || item.AvailableQuantity != tc.available {
+			t.Errorf("%s product with stock %d, %d in cart: got (%s, %d), want (%s, %d)",
+				tc.status, tc.stock, tc.inCart, item.Availability, item.AvailableQuantity, tc.want, tc.available)
+		}
+	}
+}
+
+func TestCheckAvailabilityPriceChanged(t *testing.T) {
+	cases := []struct {
+		added, price money.Amount
+		changed      bool
+		difference   money.Amount
+	}{
+		{1999, 1999, false, 0},
+		{30, 31, true, 1},
+		{1999, 1749, true, -250},
+		{1000, 1225, true, 225},
+	}
+
+	for _, tc := range cases {
+		item := CartItemWithProduct{
+			CartItem: CartItem{Quantity: 1, AddedPrice: tc.added},
+			Product:  Product{Status: "published", Stock: 5, Price: tc.price},
+		}
+		item.CheckAvailability()
+		if item.PriceChanged != tc.changed || item.PriceDifference != tc.difference {
+			t.Errorf("added at %s, now %s: got (%t, %s), want (%t, %s)",
+				tc.added, tc.price, item.PriceChanged, item.PriceDifference, tc.changed, tc.difference)
+		}
+	}
+}
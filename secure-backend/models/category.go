@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Category groups products for storefront navigation. A product belongs to at most one category.
+type Category struct {
+	ID           string    `db:"id" json:"id"`
+	Name         string    `db:"name" json:"name"`
+	Slug         string    `db:"slug" json:"slug"`
+	Description  string    `db:"description" json:"description"`
+	ProductCount int       `db:"product_count" json:"product_count"` // Published products in the category
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt    time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// Tag is a free-form label; a product may carry any number of tags
+type Tag struct {
+	ID           string    `db:"id" json:"id"`
+	Name         string    `db:"name" json:"name"`
+	Slug         string    `db:"slug" json:"slug"`
+	ProductCount int       `db:"product_count" json:"product_count"` // Published products with the tag
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt    time.Time `db:"updated_at" json:"updated_at"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// ClientError is an error report sent by a frontend or mobile client
+type ClientError struct {
+	ID              string     `db:"id" json:"id"`
+	UserID          string     `db:"user_id" json:"user_id"`
+	RequestID       string     `db:"request_id" json:"request_id"`               // ID of the reporting request
+	ClientRequestID string     `db:"client_request_id" json:"client_request_id"` // X-Request-ID of the failed API call, if any
+	Message         string     `db:"message" json:"message"`
+	Stack           string     `db:"stack" json:"stack,omitempty"`
+	URL             string     `db:"url" json:"url,omitempty"`
+	Component       string     `db:"component" json:"component,omitempty"`
+	UserAgent       string     `db:"user_agent" json:"user_agent,omitempty"`
+	Platform        string     `db:"platform" json:"platform"`
+	AppVersion      string     `db:"app_version" json:"app_version,omitempty"`
+	OccurredAt      *time.Time `db:"occurred_at" json:"occurred_at,omitempty"`
+	CreatedAt       time.Time  `db:"created_at" json:"created_at"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Consent is one recorded set of a user's consent choices. Choices are kept as a history;
+// the latest record applies. Without a record the user has consented to nothing.
+type Consent struct {
+	ID            string    `db:"id" json:"id"`
+	UserID        string    `db:"user_id" json:"user_id"`
+	Analytics     bool      `db:"analytics" json:"analytics"` // usage events such as cart activity and recommendation clicks
+	Marketing     bool      `db:"marketing" json:"marketing"` // marketing messages such as abandoned-cart emails
+	PolicyVersion string    `db:"policy_version" json:"policy_version"`
+	IPHash        string    `db:"ip_hash" json:"-"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx/types"
+)
+
+// DeadLetter is a unit of background work that failed and awaits an operator:
+// a failed job or a webhook event that could not be processed
+type DeadLetter struct {
+	Source    string         `db:"source" json:"source"` // jobs or webhooks
+	ID        string         `db:"id" json:"id"`
+	Type      string         `db:"type" json:"type"`
+	Attempts  int            `db:"attempts" json:"attempts"`
+	Error     string         `db:"error" json:"error"`
+	Payload   types.JSONText `db:"payload" json:"payload"`
+	OwnerID   string         `db:"owner_id" json:"owner_id,omitempty"`
+	CreatedAt time.Time      `db:"created_at" json:"created_at"`
+	FailedAt  time.Time      `db:"failed_at" json:"failed_at"`
+}
+
+// DeliveryError is one failed attempt in the error history of a dead letter
+type DeliveryError struct {
+	ID        string    `db:"id" json:"id"`
+	Attempt   int       `db:"attempt" json:"attempt"`
+	Error     string    `db:"error" json:"error"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// WebhookEvent is a payment provider event as received
+type WebhookEvent struct {
+	ID          string         `db:"id" json:"id"`
+	Provider    string         `db:"provider" json:"provider"`
+	EventID     string         `db:"event_id" json:"event_id"`
+	EventType   string         `db:"event_type" json:"event_type"`
+	Payload     types.JSONText `db:"payload" json:"payload"`
+	Attempts    int            `db:"attempts" json:"attempts"`
+	LastError   string         `db:"last_error" json:"last_error,omitempty"`
+	ProcessedAt *time.Time     `db:"processed_at" json:"processed_at,omitempty"`
+	DiscardedAt *time.Time     `db:"discarded_at" json:"discarded_at,omitempty"`
+	CreatedAt   time.Time      `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time      `db:"updated_at" json:"updated_at"`
+}
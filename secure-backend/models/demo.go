@@ -0,0 +1,90 @@
+package models
+
+import (
+	"secure-backend/money"
+	"time"
+)
+
+// DemoEmailDomain is the email domain of the accounts seeded in demo mode
+const DemoEmailDomain = "demo.secureshop.invalid"
+
+// DemoUser is an account seeded in demo mode. Its ID is fixed, so demo sessions issued
+// before a reset keep working after it.
+type DemoUser struct {
+	ID    string `json:"id"`
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// DemoUsers are the demo accounts, one per role plus a second seller
+var DemoUsers = []DemoUser{
+	{ID: "00000000-0000-4000-8000-00000000de01", Email: "buyer@" + DemoEmailDomain, Role: "buyer"},
+	{ID: "00000000-0000-4000-8000-00000000de02", Email: "seller@" + DemoEmailDomain, Role: "seller"},
+	{ID: "00000000-0000-4000-8000-00000000de03", Email: "crafts@" + DemoEmailDomain, Role: "seller"},
+	{ID: "00000000-0000-4000-8000-00000000de04", Email: "admin@" + DemoEmailDomain, Role: "admin"},
+}
+
+// DemoUserByRole returns the first demo account with the role
+func DemoUserByRole(role string) (DemoUser, bool) {
+	for _, user := range DemoUsers {
+		if user.Role == role {
+			return user, true
+		}
+	}
+	return DemoUser{}, false
+}
+
+// LookupDemoUser returns the demo account with the ID
+func LookupDemoUser(id string) (DemoUser, bool) {
+	for _, user := range DemoUsers {
+		if user.ID == id {
+			return user, true
+		}
+	}
+	return DemoUser{}, false
+}
+
+// DemoSeed is the data demo mode restores every night, or the seed command loads
+type DemoSeed struct {
+	Users        []DemoUser
+	Categories   []Category // matched to existing categories by slug
+	Products     []DemoProduct
+	Orders       []DemoOrder
+	CartItems    []DemoCartItem
+	TaxRate      float64 // recorded on the tax lines of sample orders
+	Jurisdiction string
+}
+
+// DemoProduct is a published product of the demo catalog
+type DemoProduct struct {
+	SellerID     string
+	CategorySlug string
+	Slug         string // preferred slug; suffixed if a non-demo product has it
+	Name         string
+	Description  string
+	Price        money.Amount
+	Stock        int // before the sample orders are taken out
+}
+
+// DemoOrder is a sample order of a demo buyer
+type DemoOrder struct {
+	BuyerID         string
+	Status          string // paid, shipped or delivered
+	PlacedAt        time.Time
+	ShippingAddress string
+	ClientPlatform  string
+	Items           []DemoOrderItem
+}
+
+// DemoOrderItem is a line of a sample order
+type DemoOrderItem struct {
+	Product  int // index into DemoSeed.Products
+	Quantity int
+}
+
+// DemoCartItem is an item in a demo user's cart
+type DemoCartItem struct {
+	UserID   string
+	Product  int // index into DemoSeed.Products
+	Quantity int
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// DeviceToken represents a mobile device registered for push notifications
+type DeviceToken struct {
+	ID        string    `db:"id" json:"id"`
+	UserID    string    `db:"user_id" json:"user_id"`
+	Token     string    `db:"token" json:"token"`
+	Platform  string    `db:"platform" json:"platform"` // ios or android
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
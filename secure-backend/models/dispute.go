@@ -0,0 +1,103 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"secure-backend/money"
+	"time"
+)
+
+// Dispute statuses reported by the payment provider. Inquiries ("warning_*") may turn into
+// chargebacks; won, lost and warning_closed are final.
+const (
+	DisputeWarningNeedsResponse = "warning_needs_response"
+	DisputeWarningUnderReview   = "warning_under_review"
+	DisputeWarningClosed        = "warning_closed"
+	DisputeNeedsResponse        = "needs_response"
+	DisputeUnderReview          = "under_review"
+	DisputeWon                  = "won"
+	DisputeLost                 = "lost"
+)
+
+// DisputeClosed reports whether a dispute status is final
+func DisputeClosed(status string) bool {
+	return status == DisputeWon || status == DisputeLost || status == DisputeWarningClosed
+}
+
+// DisputeEvidenceFields are the text evidence fields admins may submit for a dispute; they are
+// passed to the provider as is
+var DisputeEvidenceFields = map[string]bool{
+	"product_description":          true,
+	"customer_name":                true,
+	"customer_email_address":       true,
+	"billing_address":              true,
+	"shipping_address":             true,
+	"shipping_carrier":             true,
+	"shipping_tracking_number":     true,
+	"shipping_date":                true,
+	"access_activity_log":          true,
+	"refund_policy_disclosure":     true,
+	"refund_refusal_explanation":   true,
+	"cancellation_rebuttal":        true,
+	"duplicate_charge_explanation": true,
+	"uncategorized_text":           true,
+}
+
+// DisputeEvidence holds the evidence fields submitted for a dispute
+type DisputeEvidence map[string]string
+
+// Value stores the evidence as JSON
+func (e DisputeEvidence) Value() (driver.Value, error) {
+	if e == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(map[string]string(e))
+}
+
+// Scan reads evidence stored as JSON
+func (e *DisputeEvidence) Scan(src interface{}) error {
+	switch data := src.(type) {
+	case []byte:
+		return json.Unmarshal(data, e)
+	case string:
+		return json.Unmarshal([]byte(data), e)
+	default:
+		return errors.New("dispute evidence must be stored as JSON")
+	}
+}
+
+// Dispute is a chargeback (or inquiry) a buyer opened with their card issuer against one of
+// an order's payments. While it is open the seller payouts of the order are held.
+type Dispute struct {
+	ID                  string          `db:"id" json:"id"`
+	OrderID             string          `db:"order_id" json:"order_id"`
+	PaymentID           string          `db:"payment_id" json:"payment_id"`
+	Provider            string          `db:"provider" json:"provider"`
+	ProviderDisputeID   string          `db:"provider_dispute_id" json:"provider_dispute_id"`
+	Amount              money.Amount    `db:"amount" json:"amount"`
+	Currency            string          `db:"currency" json:"currency"`
+	Reason              string          `db:"reason" json:"reason"`
+	Status              string          `db:"status" json:"status"`
+	EvidenceDueBy       *time.Time      `db:"evidence_due_by" json:"evidence_due_by,omitempty"`
+	Evidence            DisputeEvidence `db:"evidence" json:"evidence"`
+	EvidenceSubmittedAt *time.Time      `db:"evidence_submitted_at" json:"evidence_submitted_at,omitempty"`
+	EvidenceSubmittedBy *string         `db:"evidence_submitted_by" json:"evidence_submitted_by,omitempty"`
+	ClosedAt            *time.Time      `db:"closed_at" json:"closed_at,omitempty"`
+	CreatedAt           time.Time       `db:"created_at" json:"created_at"`
+	UpdatedAt           time.Time       `db:"updated_at" json:"updated_at"`
+}
+
+// PayoutHold keeps the seller payouts of an order from being paid out, e.g. while a chargeback
+// is open. Holds placed for a dispute are released when the dispute is won or closed as an
+// inquiry; lost disputes keep the hold since the money went back to the buyer. Holds placed
+// for a provider payout that doesn't reconcile are released once it does or an admin resolves it.
+type PayoutHold struct {
+	ID               string     `db:"id" json:"id"`
+	OrderID          string     `db:"order_id" json:"order_id"`
+	DisputeID        *string    `db:"dispute_id" json:"dispute_id,omitempty"`
+	ReconciliationID *string    `db:"reconciliation_id" json:"reconciliation_id,omitempty"`
+	Reason           string     `db:"reason" json:"reason"`
+	ReleasedAt       *time.Time `db:"released_at" json:"released_at,omitempty"`
+	CreatedAt        time.Time  `db:"created_at" json:"created_at"`
+}
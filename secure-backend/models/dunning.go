@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Dunning statuses
+const (
+	DunningActive    = "active"    // retries are scheduled
+	DunningRecovered = "recovered" // the order was paid
+	DunningCancelled = "cancelled" // the order was cancelled after the final attempt, or by the buyer
+)
+
+// PaymentDunning tracks the retries of an order's failed card payment
+type PaymentDunning struct {
+	OrderID           string     `db:"order_id" json:"order_id"`
+	PaymentID         string     `db:"payment_id" json:"payment_id"`
+	ProviderPaymentID string     `db:"provider_payment_id" json:"-"`
+	Status            string     `db:"status" json:"status"`
+	Attempts          int        `db:"attempts" json:"attempts"`
+	NextAttemptAt     *time.Time `db:"next_attempt_at" json:"next_attempt_at,omitempty"`
+	FinalAttemptAt    time.Time  `db:"final_attempt_at" json:"final_attempt_at"`
+	LastError         string     `db:"last_error" json:"last_error,omitempty"`
+	CreatedAt         time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt         time.Time  `db:"updated_at" json:"updated_at"`
+}
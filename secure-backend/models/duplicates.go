@@ -0,0 +1,119 @@
+package models
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/lib/pq"
+)
+
+// Duplicate listing review statuses
+const (
+	DuplicatePending   = "pending"
+	DuplicateDismissed = "dismissed" // not a duplicate; the pair isn't flagged again
+	DuplicateConfirmed = "confirmed" // the newer listing was archived
+)
+
+// Signals that made a pair of listings look alike
+const (
+	DuplicateReasonImage       = "image"
+	DuplicateReasonName        = "name"
+	DuplicateReasonDescription = "description"
+)
+
+// DuplicateThreshold is the similarity score from which a pair of listings is flagged
+const DuplicateThreshold = 0.8
+
+// minCopiedDescriptionWords is how long a description must be for copying it alone to flag
+// a listing; short descriptions ("Brand new, never used") are alike by chance
+const minCopiedDescriptionWords = 20
+
+// ListingFingerprint holds the fields of a product that are compared to find duplicates
+type ListingFingerprint struct {
+	ID          string    `db:"id"`
+	SellerID    string    `db:"seller_id"`
+	Name        string    `db:"name"`
+	Description string    `db:"description"`
+	ImageHash   string    `db:"image_hash"` // SHA-256 of the uploaded image, empty if none
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// DuplicateListing flags a listing as a probable duplicate or copy of an older listing of
+// another seller, for admin review
+type DuplicateListing struct {
+	ID                  string         `db:"id" json:"id"`
+	ProductID           string         `db:"product_id" json:"product_id"` // the newer listing
+	ProductName         string         `db:"product_name" json:"product_name"`
+	SellerID            string         `db:"seller_id" json:"seller_id"`
+	DuplicateOfID       string         `db:"duplicate_of_id" json:"duplicate_of_id"` // the older listing
+	DuplicateOfName     string         `db:"duplicate_of_name" json:"duplicate_of_name"`
+	DuplicateOfSellerID string         `db:"duplicate_of_seller_id" json:"duplicate_of_seller_id"`
+	Score               float64        `db:"score" json:"score"`
+	Reasons             pq.StringArray `db:"reasons" json:"reasons"`
+	Status              string         `db:"status" json:"status"`
+	ReviewedBy          *string        `db:"reviewed_by" json:"reviewed_by,omitempty"`
+	ReviewNote          string         `db:"review_note" json:"review_note,omitempty"`
+	ReviewedAt          *time.Time     `db:"reviewed_at" json:"reviewed_at,omitempty"`
+	CreatedAt           time.Time      `db:"created_at" json:"created_at"`
+}
+
+// ListingSimilarity scores how alike two listings are, from 0 to 1, and names the signals
+// that matched. The same uploaded image scores 1. Otherwise names and descriptions are
+// compared as sets of words: the score is their average overlap, or the description
+// overlap alone for long descriptions, since copied text is a listing copy on its own.
+func ListingSimilarity(a, b *ListingFingerprint) (float64, []string) {
+	var reasons []string
+	score := 0.0
+
+	if a.ImageHash != "" && a.ImageHash == b.ImageHash {
+		reasons = append(reasons, DuplicateReasonImage)
+		score = 1
+	}
+
+	nameSimilarity := wordOverlap(listingWords(a.Name), listingWords(b.Name))
+	if nameSimilarity >= DuplicateThreshold {
+		reasons = append(reasons, DuplicateReasonName)
+	}
+
+	aWords, bWords := listingWords(a.Description), listingWords(b.Description)
+	descriptionSimilarity := wordOverlap(aWords, bWords)
+	if descriptionSimilarity >= DuplicateThreshold {
+		reasons = append(reasons, DuplicateReasonDescription)
+	}
+
+	score = max(score, (nameSimilarity+descriptionSimilarity)/2)
+	if len(aWords) >= minCopiedDescriptionWords && len(bWords) >= minCopiedDescriptionWords {
+		score = max(score, descriptionSimilarity)
+	}
+
+	return score, reasons
+}
+
+// listingWords returns the distinct lowercase words of a text, ignoring one-letter words
+func listingWords(text string) map[string]bool {
+	words := map[string]bool{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(word)) > 1 {
+			words[word] = true
+		}
+	}
+	return words
+}
+
+// wordOverlap returns the Jaccard index of two word sets (0 if either is empty)
+func wordOverlap(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for word := range a {
+		if b[word] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestListingSimilarity(t *testing.T) {
+	long := "Handmade oak cutting board with a deep juice groove, rubber feet and a food-safe " +
+		"mineral oil finish. Each board is cut from a single plank, sanded smooth and ready for daily use."
+
+	cases := []struct {
+		name    string
+		a, b    ListingFingerprint
+		flagged bool
+		reasons []string
+	}{
+		{
+			"same image",
+			ListingFingerprint{Name: "Lamp", ImageHash: "abc"},
+			ListingFingerprint{Name: "Chair", ImageHash: "abc"},
+			true, []string{DuplicateReasonImage},
+		},
+		{
+			"copied name and description",
+			ListingFingerprint{Name: "Blue Ceramic Mug, 350ml", Description: "Glazed stoneware mug, dishwasher safe"},
+			ListingFingerprint{Name: "blue ceramic mug 350ml", Description: "Glazed stoneware mug - dishwasher safe!"},
+			true, []string{DuplicateReasonName, DuplicateReasonDescription},
+		},
+		{
+			"copied long description",
+			ListingFingerprint{Name: "Oak board", Description: long},
+			ListingFingerprint{Name: "Chopping block XL", Description: long},
+			true, []string{DuplicateReasonDescription},
+		},
+		{
+			"same name only",
+			ListingFingerprint{Name: "USB-C cable", Description: "Braided, 2 m, 100 W charging"},
+			ListingFingerprint{Name: "USB-C Cable", Description: "Short cable for phones"},
+			false, []string{DuplicateReasonName},
+		},
+		{
+			"unrelated",
+			ListingFingerprint{Name: "Garden hose", Description: "25 m hose with spray nozzle"},
+			ListingFingerprint{Name: "Wool socks", Description: "Warm merino socks"},
+			false, nil,
+		},
+	}
+
+	for _, tc := range cases {
+		score, reasons := ListingSimilarity(&tc.a, &tc.b)
+		if flagged := score >= DuplicateThreshold; flagged != tc.flagged {
+			t.Errorf("%s: score %.2f, flagged %t, want %t", tc.name, score, flagged, tc.flagged)
+		}
+		if strings.Join(reasons, ",") != strings.Join(tc.reasons, ",") {
+			t.Errorf("%s: reasons %v, want %v", tc.name, reasons, tc.reasons)
+		}
+	}
+}
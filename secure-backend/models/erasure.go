@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Erasure request statuses
+const (
+	ErasureAwaitingConfirmation = "awaiting_confirmation" // emailed, waiting for the link to be followed
+	ErasureScheduled            = "scheduled"             // confirmed; erased once the grace period ends
+	ErasureCompleted            = "completed"
+	ErasureCancelled            = "cancelled"
+	ErasureExpired              = "expired" // never confirmed
+)
+
+// ErasureRequest is a user's request to have their personal data erased. Once confirmed from
+// the emailed link the account is erased after a grace period, during which the user can
+// still cancel. Financial records are kept with the personal data removed.
+type ErasureRequest struct {
+	ID           string     `db:"id" json:"id"`
+	UserID       string     `db:"user_id" json:"user_id"`
+	Status       string     `db:"status" json:"status"`
+	ConfirmBy    time.Time  `db:"confirm_by" json:"confirm_by"` // the emailed link expires then
+	ConfirmedAt  *time.Time `db:"confirmed_at" json:"confirmed_at,omitempty"`
+	ScheduledFor *time.Time `db:"scheduled_for" json:"scheduled_for,omitempty"`
+	CompletedAt  *time.Time `db:"completed_at" json:"completed_at,omitempty"`
+	CancelledAt  *time.Time `db:"cancelled_at" json:"cancelled_at,omitempty"`
+	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
+}
+
+// ErasedEmail is the placeholder address an erased user's email is replaced with; it stays
+// unique per user as users.email requires
+func ErasedEmail(userID string) string {
+	return "erased-" + userID + "@erased.invalid"
+}
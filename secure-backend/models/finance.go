@@ -0,0 +1,34 @@
+package models
+
+import (
+	"secure-backend/money"
+	"time"
+)
+
+// FinancialSummary is the accounting summary of one calendar month (UTC). Sales count the
+// orders placed in the month that were paid; refunds count the refunds issued in the month;
+// fees are the provider fees of the month's orders. Closed months report the figures they
+// were closed with.
+type FinancialSummary struct {
+	Period      string       `db:"-" json:"period"` // e.g. "2026-03"
+	GrossSales  money.Amount `db:"gross_sales" json:"gross_sales"`
+	Refunds     money.Amount `db:"refunds" json:"refunds"`
+	Fees        money.Amount `db:"fees" json:"fees"`
+	Net         money.Amount `db:"net" json:"net"`
+	OrderCount  int          `db:"order_count" json:"order_count"`
+	RefundCount int          `db:"refund_count" json:"refund_count"`
+	Closed      bool         `db:"-" json:"closed"`
+	ClosedBy    *string      `db:"closed_by" json:"closed_by,omitempty"`
+	ClosedAt    *time.Time   `db:"closed_at" json:"closed_at,omitempty"`
+}
+
+// PeriodStart returns the first instant of the month (UTC) containing t
+func PeriodStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// ParsePeriod parses a month written as "2006-01" into its first instant (UTC)
+func ParsePeriod(value string) (time.Time, error) {
+	return time.Parse("2006-01", value)
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPeriodStart(t *testing.T) {
+	cases := []struct {
+		at   time.Time
+		want string
+	}{
+		{time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), "2026-03-01T00:00:00Z"},
+		{time.Date(2026, 3, 31, 23, 59, 59, 0, time.UTC), "2026-03-01T00:00:00Z"},
+		// 01:00 on April 1st in UTC+2 is still March in UTC
+		{time.Date(2026, 4, 1, 1, 0, 0, 0, time.FixedZone("UTC+2", 2*3600)), "2026-03-01T00:00:00Z"},
+	}
+
+	for _, tc := range cases {
+		if got := PeriodStart(tc.at).Format(time.RFC3339); got != tc.want {
+			t.Errorf("PeriodStart(%s) = %s, want %s", tc.at, got, tc.want)
+		}
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+	period, err := ParsePeriod("2026-03")
+	if err != nil {
+		t.Fatalf("ParsePeriod: %v", err)
+	}
+	if !period.Equal(PeriodStart(period)) {
+		t.Errorf("ParsePeriod(2026-03) = %s, want the start of the month", period)
+	}
+
+	for _, value := range []string{"", "2026-3", "2026-13", "2026-03-01", "March"} {
+		if _, err := ParsePeriod(value); err == nil {
+			t.Errorf("ParsePeriod(%q) succeeded, want error", value)
+		}
+	}
+}
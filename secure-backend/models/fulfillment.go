@@ -0,0 +1,123 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// FulfillmentItem is an order item of a seller's product that is still to be picked and packed
+type FulfillmentItem struct {
+	OrderItemID     string    `db:"order_item_id"`
+	OrderID         string    `db:"order_id"`
+	OrderedAt       time.Time `db:"ordered_at"`
+	ShippingAddress string    `db:"shipping_address"`
+	ProductID       string    `db:"product_id"`
+	ProductName     string    `db:"product_name"`
+	Slug            string    `db:"slug"`
+	ShelfLocation   string    `db:"shelf_location"`
+	WeightKg        *float64  `db:"weight_kg"` // of one unit
+	Quantity        int       `db:"quantity"`
+}
+
+// PickListLine is one product to take off the shelf for a batch of orders
+type PickListLine struct {
+	ProductID     string
+	ProductName   string
+	Slug          string
+	ShelfLocation string
+	Quantity      int      // across the batch
+	OrderIDs      []string // orders the units go to, oldest first
+}
+
+// PackingSlipItem is a line of a packing slip
+type PackingSlipItem struct {
+	ProductName   string
+	Slug          string
+	ShelfLocation string
+	Quantity      int
+}
+
+// PackingSlip lists what a seller packs for one order. Items of other sellers in the same
+// order are left out, since they ship separately.
+type PackingSlip struct {
+	OrderID         string
+	OrderedAt       time.Time
+	ShippingAddress string
+	Items           []PackingSlipItem
+}
+
+// NewPickList groups items by product, in walking order: by shelf location, products
+// without one last, then by name
+func NewPickList(items []FulfillmentItem) []PickListLine {
+	lines := []PickListLine{}
+	index := map[string]int{}
+	for _, item := range sortedFulfillmentItems(items) {
+		i, ok := index[item.ProductID]
+		if !ok {
+			i = len(lines)
+			index[item.ProductID] = i
+			lines = append(lines, PickListLine{
+				ProductID: item.ProductID, ProductName: item.ProductName, Slug: item.Slug, ShelfLocation: item.ShelfLocation,
+			})
+		}
+		lines[i].Quantity += item.Quantity
+		if n := len(lines[i].OrderIDs); n == 0 || lines[i].OrderIDs[n-1] != item.OrderID {
+			lines[i].OrderIDs = append(lines[i].OrderIDs, item.OrderID)
+		}
+	}
+	return lines
+}
+
+// NewPackingSlips returns a packing slip per order, oldest order first, with its items in
+// the pick list's walking order
+func NewPackingSlips(items []FulfillmentItem) []PackingSlip {
+	slips := []PackingSlip{}
+	index := map[string]int{}
+	for _, item := range sortedFulfillmentItems(items) {
+		i, ok := index[item.OrderID]
+		if !ok {
+			i = len(slips)
+			index[item.OrderID] = i
+			slips = append(slips, PackingSlip{
+				OrderID: item.OrderID, OrderedAt: item.OrderedAt, ShippingAddress: item.ShippingAddress,
+			})
+		}
+		slips[i].Items = append(slips[i].Items, PackingSlipItem{
+			ProductName: item.ProductName, Slug: item.Slug, ShelfLocation: item.ShelfLocation, Quantity: item.Quantity,
+		})
+	}
+
+	sort.SliceStable(slips, func(i, j int) bool {
+		if !slips[i].OrderedAt.Equal(slips[j].OrderedAt) {
+			return slips[i].OrderedAt.Before(slips[j].OrderedAt)
+		}
+		return slips[i].OrderID < slips[j].OrderID
+	})
+	return slips
+}
+
+// sortedFulfillmentItems returns the items in walking order, and oldest order first for
+// the same product
+func sortedFulfillmentItems(items []FulfillmentItem) []FulfillmentItem {
+	sorted := append([]FulfillmentItem(nil), items...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if (a.ShelfLocation == "") != (b.ShelfLocation == "") {
+			return a.ShelfLocation != ""
+		}
+		if a.ShelfLocation != b.ShelfLocation {
+			return a.ShelfLocation < b.ShelfLocation
+		}
+		if a.ProductName != b.ProductName {
+			return a.ProductName < b.ProductName
+		}
+		if a.ProductID != b.ProductID {
+			return a.ProductID < b.ProductID
+		}
+		if !a.OrderedAt.Equal(b.OrderedAt) {
+			return a.OrderedAt.Before(b.OrderedAt)
+		}
+		return a.OrderID < b.OrderID
+	})
+	return sorted
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFulfillmentDocuments(t *testing.T) {
+	day := time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)
+	items := []FulfillmentItem{
+		{OrderID: "order-2", OrderedAt: day.Add(2 * time.Hour), ProductID: "mug", ProductName: "Mug", ShelfLocation: "B-01", Quantity: 1},
+		{OrderID: "order-2", OrderedAt: day.Add(2 * time.Hour), ProductID: "pen", ProductName: "Pen", Quantity: 2},
+		{OrderID: "order-1", OrderedAt: day.Add(time.Hour), ProductID: "mug", ProductName: "Mug", ShelfLocation: "B-01", Quantity: 3},
+		{OrderID: "order-1", OrderedAt: day.Add(time.Hour), ProductID: "lamp", ProductName: "Lamp", ShelfLocation: "A-07", Quantity: 1},
+	}
+
+	// Products in shelf order, those without a location last
+	lines := NewPickList(items)
+	require.Len(t, lines, 3)
+	assert.Equal(t, "lamp", lines[0].ProductID)
+	assert.Equal(t, 1, lines[0].Quantity)
+	assert.Equal(t, "mug", lines[1].ProductID)
+	assert.Equal(t, 4, lines[1].Quantity)
+	assert.Equal(t, []string{"order-1", "order-2"}, lines[1].OrderIDs)
+	assert.Equal(t, "pen", lines[2].ProductID)
+
+	// Oldest order first, items in shelf order
+	slips := NewPackingSlips(items)
+	require.Len(t, slips, 2)
+	assert.Equal(t, "order-1", slips[0].OrderID)
+	require.Len(t, slips[0].Items, 2)
+	assert.Equal(t, "Lamp", slips[0].Items[0].ProductName)
+	assert.Equal(t, 3, slips[0].Items[1].Quantity)
+	assert.Equal(t, "order-2", slips[1].OrderID)
+	assert.Equal(t, "Mug", slips[1].Items[0].ProductName)
+	assert.Equal(t, "Pen", slips[1].Items[1].ProductName)
+
+	assert.Empty(t, NewPickList(nil))
+	assert.Empty(t, NewPackingSlips(nil))
+}
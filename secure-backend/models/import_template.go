@@ -0,0 +1,57 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"secure-backend/money"
+	"time"
+)
+
+// ImportMapping tells the product importer how to read a seller's file: which column holds
+// which product field, and how the file writes numbers
+type ImportMapping struct {
+	// Columns maps file column headers to product fields, e.g. {"Titre": "name", "Prix": "price"}
+	Columns            map[string]string `json:"columns"`
+	Delimiter          string            `json:"delimiter,omitempty"`           // "," by default
+	DecimalSeparator   string            `json:"decimal_separator,omitempty"`   // "." by default
+	ThousandsSeparator string            `json:"thousands_separator,omitempty"` // none by default
+	CurrencySymbol     string            `json:"currency_symbol,omitempty"`     // stripped from prices, e.g. "€"
+}
+
+// Value stores the mapping as JSON
+func (m ImportMapping) Value() (driver.Value, error) {
+	return json.Marshal(m)
+}
+
+// Scan reads a mapping stored as JSON
+func (m *ImportMapping) Scan(src interface{}) error {
+	switch data := src.(type) {
+	case []byte:
+		return json.Unmarshal(data, m)
+	case string:
+		return json.Unmarshal([]byte(data), m)
+	default:
+		return errors.New("import mapping must be stored as JSON")
+	}
+}
+
+// ImportTemplate is a column mapping a seller saved for reuse across imports
+type ImportTemplate struct {
+	ID        string        `db:"id" json:"id"`
+	SellerID  string        `db:"seller_id" json:"seller_id"`
+	Name      string        `db:"name" json:"name"`
+	Mapping   ImportMapping `db:"mapping" json:"mapping"`
+	CreatedAt time.Time     `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time     `db:"updated_at" json:"updated_at"`
+}
+
+// ImportedRow holds the product values parsed from one row of an import file
+type ImportedRow struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description,omitempty"`
+	Price       money.Amount `json:"price"`
+	Stock       int          `json:"stock"`
+	Image       string       `json:"image,omitempty"`
+	ImageAlt    string       `json:"image_alt,omitempty"`
+}
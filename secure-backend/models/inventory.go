@@ -0,0 +1,35 @@
+package models
+
+import "secure-backend/money"
+
+// Outcomes of an inventory import row
+const (
+	InventoryUpdated   = "updated"   // stock or price changed
+	InventoryUnchanged = "unchanged" // the file matched the product already
+	InventoryNotFound  = "not_found" // no product of the seller has the ID or slug
+	InventoryDuplicate = "duplicate" // a later row of the file names the same product
+	InventoryInvalid   = "invalid"   // the row couldn't be parsed
+)
+
+// InventoryUpdate is one row of an inventory file: a product of the seller by ID or slug and
+// its new stock and/or price (nil keeps the current value)
+type InventoryUpdate struct {
+	Line      int
+	ProductID string
+	Slug      string
+	Stock     *int
+	Price     *money.Amount
+}
+
+// InventoryResult reports what an inventory import did with one row of the file
+type InventoryResult struct {
+	Line      int           `json:"line"`
+	Outcome   string        `json:"outcome"`
+	ProductID string        `json:"product_id,omitempty"`
+	Slug      string        `json:"slug,omitempty"` // as given in the file
+	OldStock  *int          `json:"old_stock,omitempty"`
+	NewStock  *int          `json:"new_stock,omitempty"`
+	OldPrice  *money.Amount `json:"old_price,omitempty"`
+	NewPrice  *money.Amount `json:"new_price,omitempty"`
+	Error     string        `json:"error,omitempty"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"fmt"
+	"secure-backend/money"
+	"time"
+)
+
+// Invoice is the sequentially numbered invoice issued for an order
+type Invoice struct {
+	ID       string    `db:"id" json:"id"`
+	OrderID  string    `db:"order_id" json:"order_id"`
+	Number   int64     `db:"number" json:"number"`
+	TaxRate  float64   `db:"tax_rate" json:"tax_rate"` // e.g. 0.2 for 20%, included in prices
+	IssuedAt time.Time `db:"issued_at" json:"issued_at"`
+}
+
+// Reference returns the printed invoice number, e.g. INV-000042
+func (i *Invoice) Reference() string {
+	return fmt.Sprintf("INV-%06d", i.Number)
+}
+
+// InvoiceLine is an order item as printed on an invoice
+type InvoiceLine struct {
+	ProductName string       `db:"product_name"`
+	SellerEmail string       `db:"seller_email"`
+	Quantity    int          `db:"quantity"`
+	UnitPrice   money.Amount `db:"unit_price"`
+	TotalPrice  money.Amount `db:"total_price"`
+}
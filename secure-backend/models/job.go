@@ -0,0 +1,44 @@
+package models
+
+import (
+	"secure-backend/money"
+	"time"
+
+	"github.com/jmoiron/sqlx/types"
+)
+
+// Job is a unit of user-triggered background work (exports, imports, bulk operations)
+type Job struct {
+	ID              string         `db:"id" json:"id"`
+	UserID          string         `db:"user_id" json:"user_id"`
+	Type            string         `db:"type" json:"type"`
+	Status          string         `db:"status" json:"status"`     // queued, running, completed, failed, cancelled
+	Progress        int            `db:"progress" json:"progress"` // 0-100
+	Params          types.JSONText `db:"params" json:"params"`
+	Attempts        int            `db:"attempts" json:"attempts"`
+	RunAt           time.Time      `db:"run_at" json:"run_at"` // not claimed before this time
+	Error           string         `db:"error" json:"error,omitempty"`
+	ResultPath      string         `db:"result_path" json:"-"`
+	ResultExpiresAt *time.Time     `db:"result_expires_at" json:"result_expires_at,omitempty"`
+	DownloadURL     string         `db:"-" json:"download_url,omitempty"`
+	StartedAt       *time.Time     `db:"started_at" json:"started_at,omitempty"`
+	CompletedAt     *time.Time     `db:"completed_at" json:"completed_at,omitempty"`
+	CreatedAt       time.Time      `db:"created_at" json:"created_at"`
+	UpdatedAt       time.Time      `db:"updated_at" json:"updated_at"`
+}
+
+// OrderExportRow is one line of the orders CSV export (an order item with its order)
+type OrderExportRow struct {
+	OrderID           string       `db:"order_id"`
+	OrderedAt         time.Time    `db:"ordered_at"`
+	OrderStatus       string       `db:"order_status"`
+	BuyerID           string       `db:"buyer_id"`
+	ClientPlatform    string       `db:"client_platform"`
+	ProductID         string       `db:"product_id"`
+	ProductName       string       `db:"product_name"`
+	SellerID          string       `db:"seller_id"`
+	Quantity          int          `db:"quantity"`
+	UnitPrice         money.Amount `db:"unit_price"`
+	TotalPrice        money.Amount `db:"total_price"`
+	FulfillmentStatus string       `db:"fulfillment_status"`
+}
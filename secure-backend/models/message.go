@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// MessageThread is a conversation between a buyer and a seller about a product or an order
+type MessageThread struct {
+	ID            string    `db:"id" json:"id"`
+	BuyerID       string    `db:"buyer_id" json:"buyer_id"`
+	SellerID      string    `db:"seller_id" json:"seller_id"`
+	ProductID     *string   `db:"product_id" json:"product_id,omitempty"`
+	OrderID       *string   `db:"order_id" json:"order_id,omitempty"`
+	Flagged       bool      `db:"flagged" json:"flagged"` // moderation masked something in one of its messages
+	LastMessageAt time.Time `db:"last_message_at" json:"last_message_at"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+}
+
+// HasParticipant reports whether the user is the thread's buyer or seller
+func (t MessageThread) HasParticipant(userID string) bool {
+	return t.BuyerID == userID || t.SellerID == userID
+}
+
+// RecipientOf returns the other participant of a message sent by senderID
+func (t MessageThread) RecipientOf(senderID string) string {
+	if senderID == t.BuyerID {
+		return t.SellerID
+	}
+	return t.BuyerID
+}
+
+// Message is a message of a thread, as stored after moderation
+type Message struct {
+	ID        string         `db:"id" json:"id"`
+	ThreadID  string         `db:"thread_id" json:"thread_id"`
+	SenderID  *string        `db:"sender_id" json:"sender_id,omitempty"` // nil once the sender's account is deleted
+	Body      string         `db:"body" json:"body"`
+	Flags     pq.StringArray `db:"flags" json:"flags"` // what moderation masked (link, email, phone, profanity)
+	CreatedAt time.Time      `db:"created_at" json:"created_at"`
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"fmt"
+	"secure-backend/money"
+	"time"
+)
+
+// Order rule violation codes, returned as "code" so clients can explain the rule
+const (
+	OrderRuleBelowMinQuantity      = "below_min_quantity"
+	OrderRuleAboveMaxQuantity      = "above_max_quantity"
+	OrderRuleBelowSellerOrderValue = "below_seller_min_order_value"
+	OrderRuleAboveCartItemLimit    = "above_cart_item_limit"
+	OrderRuleAboveCartSizeLimit    = "above_cart_size_limit"
+)
+
+// MaxCartItemQuantity is the most units of one product a cart can hold
+const MaxCartItemQuantity = 100
+
+// OrderRuleError reports a purchase that breaks a product's quantity limits or a seller's
+// minimum order value, or that a seller on vacation can't take. It serializes as the error
+// response body.
+type OrderRuleError struct {
+	Message   string     `json:"error"`
+	Code      string     `json:"code"`
+	ProductID string     `json:"product_id,omitempty"`
+	SellerID  string     `json:"seller_id,omitempty"`
+	Limit     float64    `json:"limit,omitempty"` // the minimum or maximum quantity, or the minimum order value
+	Until     *time.Time `json:"until,omitempty"` // when a seller on vacation is back
+}
+
+// Error implements the error interface
+func (e *OrderRuleError) Error() string {
+	return e.Message
+}
+
+// CheckOrderQuantity returns an *OrderRuleError if quantity units of the product can't be
+// bought in one order. Wholesale sellers set these limits; by default any quantity is allowed.
+func (p *Product) CheckOrderQuantity(quantity int) error {
+	if p.MinOrderQuantity > 1 && quantity < p.MinOrderQuantity {
+		return &OrderRuleError{
+			Message:   fmt.Sprintf("%s must be ordered in quantities of at least %d", p.Name, p.MinOrderQuantity),
+			Code:      OrderRuleBelowMinQuantity,
+			ProductID: p.ID,
+			Limit:     float64(p.MinOrderQuantity),
+		}
+	}
+	if p.MaxOrderQuantity != nil && quantity > *p.MaxOrderQuantity {
+		return &OrderRuleError{
+			Message:   fmt.Sprintf("%s can be ordered in quantities of at most %d", p.Name, *p.MaxOrderQuantity),
+			Code:      OrderRuleAboveMaxQuantity,
+			ProductID: p.ID,
+			Limit:     float64(*p.MaxOrderQuantity),
+		}
+	}
+	return nil
+}
+
+// CheckSellerOrderValue returns an *OrderRuleError if an order's subtotal of a seller's
+// products is below the seller's minimum order value (0 means no minimum)
+func CheckSellerOrderValue(sellerID string, subtotal, minOrderValue money.Amount) error {
+	if minOrderValue > 0 && subtotal < minOrderValue {
+		return &OrderRuleError{
+			Message:  fmt.Sprintf("Orders from this seller must total at least %s", minOrderValue),
+			Code:     OrderRuleBelowSellerOrderValue,
+			SellerID: sellerID,
+			Limit:    minOrderValue.Float64(),
+		}
+	}
+	return nil
+}
+
+// CheckCartLimits returns an *OrderRuleError if a cart holding itemQuantity units of the
+// product and cartUnits units in total breaks the per-item limit or the cart size limit
+// maxCartUnits (0 means no cart size limit)
+func CheckCartLimits(productID string, itemQuantity, cartUnits, maxCartUnits int) error {
+	if itemQuantity > MaxCartItemQuantity {
+		return &OrderRuleError{
+			Message:   fmt.Sprintf("A cart can hold at most %d of a product", MaxCartItemQuantity),
+			Code:      OrderRuleAboveCartItemLimit,
+			ProductID: productID,
+			Limit:     MaxCartItemQuantity,
+		}
+	}
+	if maxCartUnits > 0 && cartUnits > maxCartUnits {
+		return &OrderRuleError{
+			Message: fmt.Sprintf("A cart can hold at most %d items", maxCartUnits),
+			Code:    OrderRuleAboveCartSizeLimit,
+			Limit:   float64(maxCartUnits),
+		}
+	}
+	return nil
+}
+
+// SellerSettings holds the order rules a seller applies to their products
+type SellerSettings struct {
+	MinOrderValue money.Amount `db:"min_order_value" json:"min_order_value"`
+}
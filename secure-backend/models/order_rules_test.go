@@ -0,0 +1,76 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckOrderQuantity(t *testing.T) {
+	maxQuantity := 50
+	cases := []struct {
+		min      int
+		max      *int
+		quantity int
+		code     string
+	}{
+		{0, nil, 1, ""},
+		{1, nil, 100, ""},
+		{10, nil, 9, OrderRuleBelowMinQuantity},
+		{10, nil, 10, ""},
+		{10, &maxQuantity, 50, ""},
+		{10, &maxQuantity, 51, OrderRuleAboveMaxQuantity},
+	}
+
+	for _, tc := range cases {
+		product := Product{ID: "p1", Name: "Pallet", MinOrderQuantity: tc.min, MaxOrderQuantity: tc.max}
+		err := product.CheckOrderQuantity(tc.quantity)
+
+		var ruleErr *OrderRuleError
+		switch {
+		case tc.code == "" && err != nil:
+			t.Errorf("min %d, quantity %d: unexpected error %v", tc.min, tc.quantity, err)
+		case tc.code != "" && (!errors.As(err, &ruleErr) || ruleErr.Code != tc.code):
+			t.Errorf("min %d, quantity %d: got %v, want code %s", tc.min, tc.quantity, err, tc.code)
+		}
+	}
+}
+
+func TestCheckSellerOrderValue(t *testing.T) {
+	if err := CheckSellerOrderValue("s1", 1000, 0); err != nil {
+		t.Errorf("no minimum: unexpected error %v", err)
+	}
+	if err := CheckSellerOrderValue("s1", 10000, 10000); err != nil {
+		t.Errorf("subtotal at the minimum: unexpected error %v", err)
+	}
+
+	var ruleErr *OrderRuleError
+	err := CheckSellerOrderValue("s1", 9999, 10000)
+	if !errors.As(err, &ruleErr) || ruleErr.Code != OrderRuleBelowSellerOrderValue || ruleErr.SellerID != "s1" {
+		t.Errorf("subtotal below the minimum: got %v", err)
+	}
+}
+
+func TestCheckCartLimits(t *testing.T) {
+	cases := []struct {
+		item, cart, max int
+		code            string
+	}{
+		{100, 100, 500, ""},
+		{101, 101, 500, OrderRuleAboveCartItemLimit},
+		{10, 500, 500, ""},
+		{10, 501, 500, OrderRuleAboveCartSizeLimit},
+		{10, 5000, 0, ""}, // no cart size limit
+	}
+
+	for _, tc := range cases {
+		err := CheckCartLimits("p1", tc.item, tc.cart, tc.max)
+
+		var ruleErr *OrderRuleError
+		switch {
+		case tc.code == "" && err != nil:
+			t.Errorf("%d of the product, %d in the cart: unexpected error %v", tc.item, tc.cart, err)
+		case tc.code != "" && (!errors.As(err, &ruleErr) || ruleErr.Code != tc.code):
+			t.Errorf("%d of the product, %d in the cart: got %v, want code %s", tc.item, tc.cart, err, tc.code)
+		}
+	}
+}
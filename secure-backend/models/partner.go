@@ -0,0 +1,167 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PartnerCatalogFields are the product fields a partner key can be granted. Every catalog
+// entry also carries id, available and updated_at, which delta sync depends on.
+var PartnerCatalogFields = []string{"name", "slug", "description", "price", "stock", "image", "category_id"}
+
+// ValidPartnerField reports whether field can be granted to partner keys
+func ValidPartnerField(field string) bool {
+	for _, f := range PartnerCatalogFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// PartnerAPIKey is an API key issued to a comparison-shopping partner. Only a hash of the
+// key is stored; KeyPrefix identifies it in listings.
+type PartnerAPIKey struct {
+	ID         string         `db:"id" json:"id"`
+	Name       string         `db:"name" json:"name"`
+	KeyPrefix  string         `db:"key_prefix" json:"key_prefix"`
+	Fields     pq.StringArray `db:"fields" json:"fields"`
+	DailyQuota int            `db:"daily_quota" json:"daily_quota"` // catalog requests per UTC day, 0 means unlimited
+	LastUsedAt *time.Time     `db:"last_used_at" json:"last_used_at"`
+	RevokedAt  *time.Time     `db:"revoked_at" json:"revoked_at,omitempty"`
+	CreatedAt  time.Time      `db:"created_at" json:"created_at"`
+	UpdatedAt  time.Time      `db:"updated_at" json:"updated_at"`
+}
+
+// HasField reports whether the key was granted field
+func (k *PartnerAPIKey) HasField(field string) bool {
+	for _, f := range k.Fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// PartnerCatalogEntry returns the whitelisted fields of a product for a partner. Products
+// that aren't published are listed as unavailable with no other fields, so partners
+// syncing deltas can drop them.
+func PartnerCatalogEntry(p Product, fields []string) map[string]interface{} {
+	available := p.Status == "published"
+	entry := map[string]interface{}{
+		"id":         p.ID,
+		"available":  available,
+		"updated_at": p.UpdatedAt,
+	}
+	if !available {
+		return entry
+	}
+
+	for _, field := range fields {
+		switch field {
+		case "name":
+			entry[field] = p.Name
+		case "slug":
+			entry[field] = p.Slug
+		case "description":
+			entry[field] = p.Description
+		case "price":
+			entry[field] = p.Price
+		case "stock":
+			entry[field] = p.Stock
+		case "image":
+			entry[field] = p.Image
+		case "category_id":
+			entry[field] = p.CategoryID
+		}
+	}
+	return entry
+}
+
+// ErrInvalidCursor is returned for catalog cursors that weren't issued by the server
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// CatalogCursor is a position in the catalog ordered by (updated_at, id); a sync resumes
+// after the last product it received
+type CatalogCursor struct {
+	UpdatedAt time.Time
+	ID        string
+}
+
+// Encode returns the opaque form of the cursor handed to partners
+func (c CatalogCursor) Encode() string {
+	raw := strconv.FormatInt(c.UpdatedAt.UnixNano(), 10) + ":" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCatalogCursor parses a cursor returned by Encode
+func DecodeCatalogCursor(s string) (CatalogCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return CatalogCursor{}, ErrInvalidCursor
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok || id == "" {
+		return CatalogCursor{}, ErrInvalidCursor
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return CatalogCursor{}, ErrInvalidCursor
+	}
+	return CatalogCursor{UpdatedAt: time.Unix(0, n).UTC(), ID: id}, nil
+}
+
+// Kinds of catalog change
+const (
+	CatalogCreated      = "created"
+	CatalogUpdated      = "updated"
+	CatalogDeleted      = "deleted"
+	CatalogStockChanged = "stock_changed" // only the stock changed
+)
+
+// CatalogChange is an event of the catalog change feed
+type CatalogChange struct {
+	ID        int64     `db:"id"`
+	XactID    uint64    `db:"xact_id"` // transaction that made the change
+	ProductID string    `db:"product_id"`
+	Change    string    `db:"change"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// ChangeCursor is a position in the catalog change feed ordered by (xact_id, id); a reader
+// resumes after the last change it received. The zero cursor is the start of the feed.
+type ChangeCursor struct {
+	XactID uint64
+	ID     int64
+}
+
+// Encode returns the opaque form of the cursor handed to partners
+func (c ChangeCursor) Encode() string {
+	raw := strconv.FormatUint(c.XactID, 10) + ":" + strconv.FormatInt(c.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeChangeCursor parses a cursor returned by Encode
+func DecodeChangeCursor(s string) (ChangeCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return ChangeCursor{}, ErrInvalidCursor
+	}
+	xact, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return ChangeCursor{}, ErrInvalidCursor
+	}
+	var cursor ChangeCursor
+	if cursor.XactID, err = strconv.ParseUint(xact, 10, 64); err != nil {
+		return ChangeCursor{}, ErrInvalidCursor
+	}
+	if cursor.ID, err = strconv.ParseInt(id, 10, 64); err != nil || cursor.ID < 0 {
+		return ChangeCursor{}, ErrInvalidCursor
+	}
+	return cursor, nil
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"secure-backend/money"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartnerCatalogEntry(t *testing.T) {
+	updated := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	product := Product{ID: "p1", Name: "Lamp", Price: 1999, Stock: 4, SellerID: "s1", Status: "published", UpdatedAt: updated}
+
+	// Only granted fields are included, plus the fields delta sync relies on
+	entry := PartnerCatalogEntry(product, []string{"name", "price"})
+	assert.Equal(t, map[string]interface{}{
+		"id": "p1", "available": true, "updated_at": updated, "name": "Lamp", "price": money.Amount(1999),
+	}, entry)
+
+	// Unpublished products are listed as unavailable without details
+	product.Status = "archived"
+	entry = PartnerCatalogEntry(product, []string{"name", "price"})
+	assert.Equal(t, map[string]interface{}{"id": "p1", "available": false, "updated_at": updated}, entry)
+}
+
+func TestCatalogCursor(t *testing.T) {
+	cursor := CatalogCursor{UpdatedAt: time.Date(2026, 3, 1, 12, 0, 0, 123456789, time.UTC), ID: "p1"}
+	decoded, err := DecodeCatalogCursor(cursor.Encode())
+	assert.NoError(t, err)
+	assert.Equal(t, cursor, decoded)
+
+	for _, s := range []string{"", "not base64!", "MTIz", "YWJjOnAx"} {
+		_, err := DecodeCatalogCursor(s)
+		assert.ErrorIs(t, err, ErrInvalidCursor, s)
+	}
+}
+
+func TestChangeCursor(t *testing.T) {
+	cursor := ChangeCursor{XactID: 1 << 40, ID: 1234}
+	decoded, err := DecodeChangeCursor(cursor.Encode())
+	assert.NoError(t, err)
+	assert.Equal(t, cursor, decoded)
+
+	for _, s := range []string{"", "not base64!", "MTIz", "YWJjOjE", "MTotMQ"} {
+		_, err := DecodeChangeCursor(s)
+		assert.ErrorIs(t, err, ErrInvalidCursor, s)
+	}
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"secure-backend/money"
+	"time"
+)
+
+// Payment represents a payment attempt with an external provider for an order
+type Payment struct {
+	ID                string       `db:"id" json:"id"`
+	OrderID           string       `db:"order_id" json:"order_id"`
+	Provider          string       `db:"provider" json:"provider"`
+	ProviderPaymentID string       `db:"provider_payment_id" json:"provider_payment_id"`
+	Amount            money.Amount `db:"amount" json:"amount"`
+	Currency          string       `db:"currency" json:"currency"`
+	Status            string       `db:"status" json:"status"`
+	Fee               money.Amount `db:"fee" json:"fee"` // provider fee, known once its payout is reconciled
+	CreatedAt         time.Time    `db:"created_at" json:"created_at"`
+	UpdatedAt         time.Time    `db:"updated_at" json:"updated_at"`
+}
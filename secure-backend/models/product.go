@@ -1,17 +1,90 @@
 package models
 
-import "time"
+import (
+	"secure-backend/money"
+	"time"
+
+	"github.com/lib/pq"
+)
 
 // Product represents a product in the system
 type Product struct {
-	ID          string    `db:"id" json:"id"`
-	Name        string    `db:"name" json:"name"`
-	Description string    `db:"description" json:"description"`
-	Price       float64   `db:"price" json:"price"`
-	Image       string    `db:"image" json:"image"`
-	Stock       int       `db:"stock" json:"stock"`
-	Status      string    `db:"status" json:"status"`
-	SellerID    string    `db:"seller_id" json:"seller_id"`
-	CreatedAt   time.Time `db:"created_at" json:"created_at"`
-	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
+	ID          string       `db:"id" json:"id"`
+	Name        string       `db:"name" json:"name"`
+	Description string       `db:"description" json:"description"`
+	Price       money.Amount `db:"price" json:"price"`
+	Image       string       `db:"image" json:"image"`
+	ImageAlt    string       `db:"image_alt" json:"image_alt"` // Alternative text describing the image for screen readers
+	Stock       int          `db:"stock" json:"stock"`
+	Status      string       `db:"status" json:"status"`
+	StatusLabel string       `db:"-" json:"status_label,omitempty"` // Status in the caller's language
+	SellerID    string       `db:"seller_id" json:"seller_id"`
+	CategoryID  *string      `db:"category_id" json:"category_id"`
+	Slug        string       `db:"slug" json:"slug"` // URL-friendly unique name, generated from the name on create
+	CreatedAt   time.Time    `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time    `db:"updated_at" json:"updated_at"`
+
+	// Physical dimensions (optional, but expected before publishing)
+	WidthCm  *float64 `db:"width_cm" json:"width_cm"`
+	HeightCm *float64 `db:"height_cm" json:"height_cm"`
+	DepthCm  *float64 `db:"depth_cm" json:"depth_cm"`
+	WeightKg *float64 `db:"weight_kg" json:"weight_kg"`
+
+	// Purchase limits per order for wholesale sellers; MaxOrderQuantity nil means no maximum
+	MinOrderQuantity int  `db:"min_order_quantity" json:"min_order_quantity"`
+	MaxOrderQuantity *int `db:"max_order_quantity" json:"max_order_quantity"`
+
+	// Search engine metadata; storefronts fall back to the name and description when empty
+	MetaTitle       string `db:"meta_title" json:"meta_title"`
+	MetaDescription string `db:"meta_description" json:"meta_description"`
+
+	// ShelfLocation is where the seller keeps the product (e.g. "A-03-2"), printed on pick lists.
+	// Only returned to the seller; on update, omitting it keeps the current one.
+	ShelfLocation *string `db:"shelf_location" json:"shelf_location,omitempty"`
+
+	// Tags holds the slugs of the product's tags. On update, omitting tags keeps the current ones.
+	Tags pq.StringArray `db:"tags" json:"tags"`
+
+	// Warnings lists missing accessibility metadata; only set in responses to the owning seller
+	Warnings []string `db:"-" json:"warnings,omitempty"`
+
+	// Recommendations are the seller's cross-sells and upsells; only set on product detail
+	Recommendations []ProductRecommendation `db:"-" json:"recommendations,omitempty"`
+	// SellerVacation is set on product detail while the seller is on vacation
+	SellerVacation *SellerVacation `db:"-" json:"seller_vacation,omitempty"`
+}
+
+// AccessibilityWarnings returns the accessibility metadata missing from a product
+func (p *Product) AccessibilityWarnings() []string {
+	var warnings []string
+	if p.Image != "" && p.ImageAlt == "" {
+		warnings = append(warnings, "Product image has no alt text")
+	}
+	if p.WidthCm == nil || p.HeightCm == nil || p.DepthCm == nil {
+		warnings = append(warnings, "Product dimensions (width, height, depth) are missing")
+	}
+	if p.WeightKg == nil {
+		warnings = append(warnings, "Product weight is missing")
+	}
+	return warnings
+}
+
+// PriceChange is one entry of a product's price history. OldPrice is nil for the
+// price the product was created with.
+type PriceChange struct {
+	ID        string        `db:"id" json:"id"`
+	ProductID string        `db:"product_id" json:"product_id"`
+	OldPrice  *money.Amount `db:"old_price" json:"old_price"`
+	NewPrice  money.Amount  `db:"new_price" json:"new_price"`
+	ChangedBy *string       `db:"changed_by" json:"-"`
+	CreatedAt time.Time     `db:"created_at" json:"created_at"`
+}
+
+// ProductSearchResult is a product matching a full-text search, with its relevance
+// and the matching text highlighted in <mark> tags
+type ProductSearchResult struct {
+	Product
+	Rank          float64 `db:"rank" json:"rank"`
+	NameHighlight string  `db:"name_highlight" json:"name_highlight"`
+	Snippet       string  `db:"snippet" json:"snippet"`
 }
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// APIUsage is a user's plan and request counts for the current day and month
+type APIUsage struct {
+	Plan    string `db:"plan"`
+	Daily   int    `db:"daily"`
+	Monthly int    `db:"monthly"`
+}
+
+// QuotaWindow reports usage of one quota period. A limit of 0 means unlimited.
+type QuotaWindow struct {
+	Limit     int       `json:"limit"`
+	Used      int       `json:"used"`
+	Remaining int       `json:"remaining"`
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+// QuotaStatus is a user's quota tier and usage
+type QuotaStatus struct {
+	Tier    string      `json:"tier"`
+	Daily   QuotaWindow `json:"daily"`
+	Monthly QuotaWindow `json:"monthly"`
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"secure-backend/money"
+	"time"
+)
+
+// Recommendation kinds
+const (
+	RecommendationCrossSell = "cross_sell" // accessory bought alongside the product
+	RecommendationUpsell    = "upsell"     // upgrade bought instead of the product
+)
+
+// Recommendation event actions and the places recommendations are shown
+const (
+	RecommendationClick  = "click"
+	RecommendationAttach = "attach" // recommended product added to the cart
+
+	PlacementProduct  = "product"
+	PlacementCart     = "cart"
+	PlacementCheckout = "checkout"
+)
+
+// ProductRecommendation is a product a seller recommends alongside ProductID, with the
+// recommended product's details for display ("Add batteries for $3")
+type ProductRecommendation struct {
+	ProductID            string       `db:"product_id" json:"product_id"`
+	RecommendedProductID string       `db:"recommended_product_id" json:"recommended_product_id"`
+	Kind                 string       `db:"kind" json:"kind"`
+	Label                string       `db:"label" json:"label"`
+	Position             int          `db:"position" json:"position"`
+	Name                 string       `db:"name" json:"name"`
+	Slug                 string       `db:"slug" json:"slug"`
+	Price                money.Amount `db:"price" json:"price"`
+	Image                string       `db:"image" json:"image"`
+	Stock                int          `db:"stock" json:"stock"`
+	Status               string       `db:"status" json:"status"`
+}
+
+// RecommendationEvent is a click on a recommendation or an add-to-cart that came from one
+type RecommendationEvent struct {
+	ID                   string    `db:"id" json:"id"`
+	ProductID            string    `db:"product_id" json:"product_id"`
+	RecommendedProductID string    `db:"recommended_product_id" json:"recommended_product_id"`
+	UserID               *string   `db:"user_id" json:"user_id,omitempty"`
+	Action               string    `db:"action" json:"action"`
+	Placement            string    `db:"placement" json:"placement"`
+	CreatedAt            time.Time `db:"created_at" json:"created_at"`
+}
+
+// RecommendationStats counts clicks and attaches of one recommendation within a report range
+type RecommendationStats struct {
+	ProductID            string  `db:"product_id" json:"product_id"`
+	ProductName          string  `db:"product_name" json:"product_name"`
+	RecommendedProductID string  `db:"recommended_product_id" json:"recommended_product_id"`
+	RecommendedName      string  `db:"recommended_name" json:"recommended_name"`
+	Clicks               int     `db:"clicks" json:"clicks"`
+	Attaches             int     `db:"attaches" json:"attaches"`
+	ConversionRate       float64 `db:"-" json:"conversion_rate"` // attaches per click (0 without clicks)
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"secure-backend/money"
+	"time"
+)
+
+// Payout reconciliation statuses
+const (
+	ReconciliationReconciled = "reconciled" // every transaction matched the shop's records
+	ReconciliationBlocked    = "blocked"    // mismatches found; seller payouts of its orders are held
+	ReconciliationResolved   = "resolved"   // an admin reviewed the mismatches and released the payout
+)
+
+// Kinds of reconciliation mismatches
+const (
+	MismatchUnknownPayment = "unknown_payment" // the provider charged a payment the shop has no record of
+	MismatchUnknownRefund  = "unknown_refund"  // the provider refunded money the shop has no record of
+	MismatchAmount         = "amount_mismatch" // the amounts differ
+	MismatchStatus         = "status_mismatch" // the shop doesn't consider the payment collected
+	MismatchTotal          = "total_mismatch"  // the transactions don't add up to the payout
+)
+
+// PayoutReconciliation is the result of checking a payout from the payment provider (a batch
+// of balance transactions) against the shop's payments and refunds
+type PayoutReconciliation struct {
+	ID               string                   `db:"id" json:"id"`
+	Provider         string                   `db:"provider" json:"provider"`
+	ProviderPayoutID string                   `db:"provider_payout_id" json:"provider_payout_id"`
+	Amount           money.Amount             `db:"amount" json:"amount"`
+	Currency         string                   `db:"currency" json:"currency"`
+	ArrivalDate      *time.Time               `db:"arrival_date" json:"arrival_date,omitempty"`
+	Status           string                   `db:"status" json:"status"`
+	TransactionCount int                      `db:"transaction_count" json:"transaction_count"`
+	CheckedAt        time.Time                `db:"checked_at" json:"checked_at"`
+	ResolvedBy       *string                  `db:"resolved_by" json:"resolved_by,omitempty"`
+	ResolutionNote   string                   `db:"resolution_note" json:"resolution_note,omitempty"`
+	ResolvedAt       *time.Time               `db:"resolved_at" json:"resolved_at,omitempty"`
+	Mismatches       []ReconciliationMismatch `db:"-" json:"mismatches,omitempty"`
+	CreatedAt        time.Time                `db:"created_at" json:"created_at"`
+	UpdatedAt        time.Time                `db:"updated_at" json:"updated_at"`
+}
+
+// ReconciliationMismatch is a provider balance transaction that doesn't match the shop's records
+type ReconciliationMismatch struct {
+	ID                   string        `db:"id" json:"id"`
+	ReconciliationID     string        `db:"reconciliation_id" json:"-"`
+	BalanceTransactionID string        `db:"balance_transaction_id" json:"balance_transaction_id"`
+	Kind                 string        `db:"kind" json:"kind"`
+	OrderID              *string       `db:"order_id" json:"order_id,omitempty"`
+	Expected             *money.Amount `db:"expected" json:"expected,omitempty"`
+	Actual               *money.Amount `db:"actual" json:"actual,omitempty"`
+	Detail               string        `db:"detail" json:"detail"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"secure-backend/money"
+	"time"
+)
+
+// Refund is money returned to the buyer for (part of) an order
+type Refund struct {
+	ID               string             `db:"id" json:"id"`
+	OrderID          string             `db:"order_id" json:"order_id"`
+	PaymentID        string             `db:"payment_id" json:"payment_id"`
+	Amount           money.Amount       `db:"amount" json:"amount"`
+	Currency         string             `db:"currency" json:"currency"`
+	Reason           string             `db:"reason" json:"reason,omitempty"`
+	Restock          bool               `db:"restock" json:"restock"`
+	Status           string             `db:"status" json:"status"` // pending, succeeded, failed
+	StatusLabel      string             `db:"-" json:"status_label,omitempty"`
+	ProviderRefundID string             `db:"provider_refund_id" json:"provider_refund_id,omitempty"`
+	ActorID          string             `db:"actor_id" json:"actor_id"`
+	ActorRole        string             `db:"actor_role" json:"actor_role"`
+	Items            []RefundItem       `db:"-" json:"items"`
+	Allocations      []RefundAllocation `db:"-" json:"allocations"`
+	CreatedAt        time.Time          `db:"created_at" json:"created_at"`
+	UpdatedAt        time.Time          `db:"updated_at" json:"updated_at"`
+}
+
+// RefundItem is the quantity of an order item covered by a refund
+type RefundItem struct {
+	ID          string       `db:"id" json:"id"`
+	RefundID    string       `db:"refund_id" json:"refund_id"`
+	OrderItemID string       `db:"order_item_id" json:"order_item_id"`
+	ProductID   string       `db:"product_id" json:"product_id"`
+	Quantity    int          `db:"quantity" json:"quantity"`
+	Amount      money.Amount `db:"amount" json:"amount"`
+}
+
+// RefundAllocation is the part of a refund returned through one of the order's payments,
+// e.g. to store credit and to the card when the order was paid with both
+type RefundAllocation struct {
+	RefundID          string       `db:"refund_id" json:"-"`
+	OrderID           string       `db:"order_id" json:"-"`
+	PaymentID         string       `db:"payment_id" json:"payment_id"`
+	Provider          string       `db:"provider" json:"provider"`
+	ProviderPaymentID string       `db:"provider_payment_id" json:"-"`
+	Amount            money.Amount `db:"amount" json:"amount"`
+	ProviderRefundID  string       `db:"provider_refund_id" json:"provider_refund_id,omitempty"`
+}
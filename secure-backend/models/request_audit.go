@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// RequestAudit is one entry of the request audit log: a request to an audited route with its
+// redacted bodies. Entries are kept for REQUEST_AUDIT_RETENTION.
+type RequestAudit struct {
+	ID           string    `db:"id" json:"id"`
+	RequestID    string    `db:"request_id" json:"request_id"`
+	UserID       *string   `db:"user_id" json:"user_id,omitempty"`
+	Method       string    `db:"method" json:"method"`
+	Route        string    `db:"route" json:"route"` // route pattern, e.g. /api/admin/users/:id/role
+	Path         string    `db:"path" json:"path"`   // path and redacted query string
+	Status       int       `db:"status" json:"status"`
+	RequestBody  string    `db:"request_body" json:"request_body"`
+	ResponseBody string    `db:"response_body" json:"response_body"`
+	IPAddress    string    `db:"ip_address" json:"ip_address,omitempty"`
+	DurationMs   int       `db:"duration_ms" json:"duration_ms"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"secure-backend/money"
+	"time"
+)
+
+// MaxSavedSearches is how many searches a buyer can save
+const MaxSavedSearches = 25
+
+// SavedSearch is a search query and filters a buyer saved. With Alert set, the buyer is
+// notified when a product that matches is published, or changes to match.
+type SavedSearch struct {
+	ID            string        `db:"id" json:"id"`
+	UserID        string        `db:"user_id" json:"user_id"`
+	Name          string        `db:"name" json:"name"`
+	Query         string        `db:"query" json:"query"`       // full-text search query ("" = any)
+	Category      string        `db:"category" json:"category"` // category slug ("" = any)
+	Tag           string        `db:"tag" json:"tag"`           // tag slug ("" = any)
+	MinPrice      *money.Amount `db:"min_price" json:"min_price"`
+	MaxPrice      *money.Amount `db:"max_price" json:"max_price"`
+	Alert         bool          `db:"alert" json:"alert"`
+	LastAlertedAt *time.Time    `db:"last_alerted_at" json:"last_alerted_at,omitempty"`
+	CreatedAt     time.Time     `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time     `db:"updated_at" json:"updated_at"`
+}
+
+// SavedSearchMatch is a product that newly matches a saved search with alerts on
+type SavedSearchMatch struct {
+	SavedSearchID string       `db:"saved_search_id"`
+	UserID        string       `db:"user_id"`
+	SearchName    string       `db:"search_name"`
+	ProductID     string       `db:"product_id"`
+	ProductName   string       `db:"product_name"`
+	Price         money.Amount `db:"price"`
+}
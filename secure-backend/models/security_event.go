@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Security event types
+const (
+	SecurityAdminLoginFailed = "admin_login_failed" // Wrong break-glass password or admin bootstrap token
+	SecurityRoleChanged      = "role_changed"       // A user's role changed or the first admin was bootstrapped
+	SecurityAPIKeyCreated    = "api_key_created"    // A partner API key was issued
+	SecurityRateLimited      = "rate_limited"       // A client was throttled by a rate limit
+	SecurityJWKSFailure      = "jwks_failure"       // The Supabase signing keys couldn't be refreshed
+)
+
+// Security event severities; high-severity events are alerted
+const (
+	SeverityLow    = "low"
+	SeverityMedium = "medium"
+	SeverityHigh   = "high"
+)
+
+// SecurityEvent is one entry of the security event log. Events are kept apart from the
+// application logs, for SECURITY_EVENT_RETENTION.
+type SecurityEvent struct {
+	ID        string    `db:"id" json:"id"`
+	Type      string    `db:"type" json:"type"`
+	Severity  string    `db:"severity" json:"severity"`
+	UserID    *string   `db:"user_id" json:"user_id,omitempty"`
+	IPAddress string    `db:"ip_address" json:"ip_address,omitempty"`
+	Detail    string    `db:"detail" json:"detail"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
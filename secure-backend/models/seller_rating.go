@@ -0,0 +1,85 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Seller rating statuses
+const (
+	RatingPublished = "published"
+	RatingHidden    = "hidden" // taken down by an admin; left out of averages and listings
+)
+
+// SellerRating is a buyer's rating of a seller for an order, distinct from feedback on the
+// products themselves
+type SellerRating struct {
+	ID             string         `db:"id" json:"id"`
+	OrderID        string         `db:"order_id" json:"order_id"`
+	SellerID       string         `db:"seller_id" json:"seller_id"`
+	BuyerID        *string        `db:"buyer_id" json:"buyer_id,omitempty"` // nil once the buyer's account is deleted
+	ShippingSpeed  int            `db:"shipping_speed" json:"shipping_speed"`
+	Communication  int            `db:"communication" json:"communication"`
+	Comment        string         `db:"comment" json:"comment"`
+	Flags          pq.StringArray `db:"flags" json:"flags"` // what moderation masked in the comment
+	Status         string         `db:"status" json:"status"`
+	ModerationNote string         `db:"moderation_note" json:"moderation_note,omitempty"`
+	ModeratedBy    *string        `db:"moderated_by" json:"moderated_by,omitempty"`
+	ModeratedAt    *time.Time     `db:"moderated_at" json:"moderated_at,omitempty"`
+	CreatedAt      time.Time      `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time      `db:"updated_at" json:"updated_at"`
+}
+
+// SellerRatingSummary aggregates a seller's published ratings for their profile
+type SellerRatingSummary struct {
+	SellerID      string  `db:"seller_id" json:"seller_id"`
+	Count         int     `db:"count" json:"count"`
+	ShippingSpeed float64 `db:"shipping_speed" json:"shipping_speed"` // averages, 0 without ratings
+	Communication float64 `db:"communication" json:"communication"`
+	Overall       float64 `db:"overall" json:"overall"` // mean of both averages
+}
+
+// Seller quality score weights and prior. Averages of few ratings are pulled towards
+// qualityPriorRating as if the seller had qualityPriorCount such ratings, so a single
+// rating doesn't make or break a new seller.
+const (
+	qualityPriorRating   = 4.0
+	qualityPriorCount    = 5
+	qualityRatingWeight  = 0.7
+	qualityDisputeWeight = 0.3
+	// qualityMaxDisputeRate is the share of disputed orders at which the dispute part of
+	// the score reaches zero
+	qualityMaxDisputeRate = 0.1
+)
+
+// SellerQuality is the quality score of a seller shown to admins, with the figures it is
+// computed from over the scored period
+type SellerQuality struct {
+	SellerID       string  `db:"seller_id" json:"seller_id"`
+	Email          string  `db:"email" json:"email"`
+	Ratings        int     `db:"ratings" json:"ratings"` // published ratings
+	AverageRating  float64 `db:"average_rating" json:"average_rating"`
+	FlaggedRatings int     `db:"flagged_ratings" json:"flagged_ratings"` // comments moderation masked something in
+	HiddenRatings  int     `db:"hidden_ratings" json:"hidden_ratings"`
+	Orders         int     `db:"orders" json:"orders"`
+	Disputes       int     `db:"disputes" json:"disputes"`
+	DisputeRate    float64 `db:"-" json:"dispute_rate"`
+	Score          float64 `db:"-" json:"score"` // 0 to 100
+}
+
+// ComputeScore sets the dispute rate and the score: 70% from the smoothed average rating
+// (1 to 5 mapped to 0 to 1) and 30% from the dispute rate, which costs the full 30 points at
+// qualityMaxDisputeRate of orders disputed
+func (q *SellerQuality) ComputeScore() {
+	q.DisputeRate = 0
+	if q.Orders > 0 {
+		q.DisputeRate = float64(q.Disputes) / float64(q.Orders)
+	}
+
+	smoothed := (qualityPriorRating*qualityPriorCount + q.AverageRating*float64(q.Ratings)) / float64(qualityPriorCount+q.Ratings)
+	rating := (smoothed - 1) / 4
+	disputes := 1 - math.Min(q.DisputeRate/qualityMaxDisputeRate, 1)
+	q.Score = math.Round((qualityRatingWeight*rating+qualityDisputeWeight*disputes)*1000) / 10
+}
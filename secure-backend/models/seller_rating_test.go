@@ -0,0 +1,31 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSellerQualityScore(t *testing.T) {
+	cases := []struct {
+		name        string
+		quality     SellerQuality
+		score       float64
+		disputeRate float64
+	}{
+		{"new seller", SellerQuality{}, 82.5, 0},
+		{"well rated", SellerQuality{Ratings: 20, AverageRating: 5, Orders: 100}, 96.5, 0},
+		{"one bad rating is smoothed", SellerQuality{Ratings: 1, AverageRating: 1, Orders: 1}, 73.8, 0},
+		{"many bad ratings", SellerQuality{Ratings: 45, AverageRating: 1, Orders: 50}, 35.3, 0},
+		{"disputes at the maximum rate", SellerQuality{Orders: 50, Disputes: 5}, 52.5, 0.1},
+		{"disputes above the maximum rate", SellerQuality{Orders: 10, Disputes: 5}, 52.5, 0.5},
+		{"some disputes", SellerQuality{Orders: 100, Disputes: 5}, 67.5, 0.05},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.quality.ComputeScore()
+			assert.InDelta(t, tc.score, tc.quality.Score, 0.1)
+			assert.InDelta(t, tc.disputeRate, tc.quality.DisputeRate, 1e-9)
+		})
+	}
+}
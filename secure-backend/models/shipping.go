@@ -0,0 +1,46 @@
+package models
+
+import (
+	"secure-backend/money"
+	"time"
+)
+
+// Seller ledger entry reasons
+const (
+	SellerLedgerShippingLabel = "shipping_label" // a label bought through the shop, charged to the seller
+)
+
+// ShippingLabel is a label a seller bought for their items of an order
+type ShippingLabel struct {
+	ID              string       `db:"id" json:"id"`
+	OrderID         string       `db:"order_id" json:"order_id"`
+	SellerID        string       `db:"seller_id" json:"seller_id"`
+	JobID           *string      `db:"job_id" json:"job_id,omitempty"`
+	ProviderLabelID string       `db:"provider_label_id" json:"provider_label_id"`
+	Carrier         string       `db:"carrier" json:"carrier"`
+	Service         string       `db:"service" json:"service"`
+	TrackingNumber  string       `db:"tracking_number" json:"tracking_number"`
+	Cost            money.Amount `db:"cost" json:"cost"`
+	Currency        string       `db:"currency" json:"currency"`
+	StorageKey      string       `db:"storage_key" json:"-"`
+	CreatedAt       time.Time    `db:"created_at" json:"created_at"`
+}
+
+// SellerLedgerEntry is a signed amount settled with a seller's payouts: negative for
+// charges such as shipping labels
+type SellerLedgerEntry struct {
+	ID              string       `db:"id" json:"id"`
+	SellerID        string       `db:"seller_id" json:"seller_id"`
+	Amount          money.Amount `db:"amount" json:"amount"`
+	Currency        string       `db:"currency" json:"currency"`
+	Reason          string       `db:"reason" json:"reason"`
+	OrderID         *string      `db:"order_id" json:"order_id,omitempty"`
+	ShippingLabelID *string      `db:"shipping_label_id" json:"shipping_label_id,omitempty"`
+	CreatedAt       time.Time    `db:"created_at" json:"created_at"`
+}
+
+// LedgerBalance is the sum of a seller's ledger entries in one currency
+type LedgerBalance struct {
+	Currency string       `db:"currency" json:"currency"`
+	Amount   money.Amount `db:"amount" json:"amount"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// Stock movement reasons
+const (
+	MovementOpening      = "opening"       // stock a product was created with
+	MovementSellerUpdate = "seller_update" // seller edited the stock
+	MovementReservation  = "reservation"   // held for a pending order at checkout
+	MovementRelease      = "release"       // reservation returned (expired or cancelled order)
+	MovementRestock      = "restock"       // refunded items put back in stock
+	MovementAdjustment   = "adjustment"    // admin correction after a stock audit
+)
+
+// StockMovement is one signed change to a product's stock
+type StockMovement struct {
+	ID        string    `db:"id" json:"id"`
+	ProductID string    `db:"product_id" json:"product_id"`
+	Quantity  int       `db:"quantity" json:"quantity"`
+	Reason    string    `db:"reason" json:"reason"`
+	OrderID   *string   `db:"order_id" json:"order_id,omitempty"`
+	ActorID   *string   `db:"actor_id" json:"actor_id,omitempty"`
+	Note      string    `db:"note" json:"note,omitempty"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// StockDiscrepancy is a product whose stock disagrees with its movement ledger, or whose
+// ledgered reservations disagree with the stock_reservations table
+type StockDiscrepancy struct {
+	ProductID      string `db:"product_id" json:"product_id"`
+	Name           string `db:"name" json:"name"`
+	SellerID       string `db:"seller_id" json:"seller_id"`
+	Stock          int    `db:"stock" json:"stock"`                     // products.stock
+	LedgerStock    int    `db:"ledger_stock" json:"ledger_stock"`       // sum of all movements
+	LedgerReserved int    `db:"ledger_reserved" json:"ledger_reserved"` // units taken by reservation minus release movements
+	Reserved       int    `db:"reserved" json:"reserved"`               // active and committed reservations
+}
+
+// StockDrift is how far the stock is off its ledger (positive: more stock than ledgered)
+func (d *StockDiscrepancy) StockDrift() int {
+	return d.Stock - d.LedgerStock
+}
+
+// ReservationDrift is how far the ledgered reservations are off the reservation table
+func (d *StockDiscrepancy) ReservationDrift() int {
+	return d.LedgerReserved - d.Reserved
+}
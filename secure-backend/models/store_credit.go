@@ -0,0 +1,77 @@
+package models
+
+import (
+	"secure-backend/money"
+	"time"
+)
+
+// PaymentProviderStoreCredit identifies payments settled from the buyer's store credit.
+// Their provider payment ID is the ledger entry that debited the credit.
+const PaymentProviderStoreCredit = "store_credit"
+
+// Store credit ledger entry reasons
+const (
+	StoreCreditGiftCard        = "gift_card"        // a redeemed gift card
+	StoreCreditPayment         = "payment"          // credit applied to an order
+	StoreCreditPaymentReleased = "payment_released" // credit returned when an unpaid order is cancelled
+	StoreCreditRefund          = "refund"           // the store credit share of a refund
+)
+
+// StoreCreditEntry is a signed movement in a buyer's store credit; the balance is their sum
+type StoreCreditEntry struct {
+	ID         string       `db:"id" json:"id"`
+	UserID     string       `db:"user_id" json:"user_id"`
+	Amount     money.Amount `db:"amount" json:"amount"`
+	Reason     string       `db:"reason" json:"reason"`
+	OrderID    *string      `db:"order_id" json:"order_id,omitempty"`
+	RefundID   *string      `db:"refund_id" json:"refund_id,omitempty"`
+	GiftCardID *string      `db:"gift_card_id" json:"gift_card_id,omitempty"`
+	CreatedAt  time.Time    `db:"created_at" json:"created_at"`
+}
+
+// GiftCard is a prepaid code that adds its amount to the store credit of whoever redeems it.
+// Only a hash of the code is stored.
+type GiftCard struct {
+	ID         string       `db:"id" json:"id"`
+	CodeLast4  string       `db:"code_last4" json:"code_last4"`
+	Amount     money.Amount `db:"amount" json:"amount"`
+	ExpiresAt  *time.Time   `db:"expires_at" json:"expires_at,omitempty"`
+	RedeemedBy *string      `db:"redeemed_by" json:"redeemed_by,omitempty"`
+	RedeemedAt *time.Time   `db:"redeemed_at" json:"redeemed_at,omitempty"`
+	CreatedBy  *string      `db:"created_by" json:"created_by,omitempty"`
+	CreatedAt  time.Time    `db:"created_at" json:"created_at"`
+}
+
+// AllocateRefund splits a refund of amount across an order's payments in proportion to what
+// each payment still has refundable (remaining). Rounding goes to the last payment, and no
+// payment is allocated more than its remaining amount. The caller must ensure amount does
+// not exceed the sum of remaining.
+func AllocateRefund(amount money.Amount, remaining []money.Amount) []money.Amount {
+	allocations := make([]money.Amount, len(remaining))
+	var total money.Amount
+	for _, r := range remaining {
+		total += r
+	}
+	if amount <= 0 || total <= 0 {
+		return allocations
+	}
+
+	var allocated money.Amount
+	for i := 0; i < len(remaining)-1; i++ {
+		allocations[i] = amount * remaining[i] / total
+		allocated += allocations[i]
+	}
+	last := len(remaining) - 1
+	allocations[last] = amount - allocated
+
+	// Rounding can push the last payment past what it has left; move the excess back
+	for i := 0; i < last && allocations[last] > remaining[last]; i++ {
+		excess := allocations[last] - remaining[last]
+		if spare := remaining[i] - allocations[i]; spare < excess {
+			excess = spare
+		}
+		allocations[i] += excess
+		allocations[last] -= excess
+	}
+	return allocations
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"secure-backend/money"
+	"testing"
+)
+
+func TestAllocateRefund(t *testing.T) {
+	cases := []struct {
+		name      string
+		amount    money.Amount
+		remaining []money.Amount
+		want      []money.Amount
+	}{
+		{"single payment", 1500, []money.Amount{5000}, []money.Amount{1500}},
+		{"proportional split", 3000, []money.Amount{2000, 4000}, []money.Amount{1000, 2000}},
+		{"rounding goes to the card", 1000, []money.Amount{3333, 6667}, []money.Amount{333, 667}},
+		{"everything left", 4321, []money.Amount{1234, 3087}, []money.Amount{1234, 3087}},
+		{"card already refunded", 500, []money.Amount{2000, 0}, []money.Amount{500, 0}},
+		{"excess moved back", 2, []money.Amount{1, 1, 1}, []money.Amount{1, 0, 1}},
+		{"nothing to refund", 0, []money.Amount{1000, 1000}, []money.Amount{0, 0}},
+	}
+
+	for _, tc := range cases {
+		got := AllocateRefund(tc.amount, tc.remaining)
+		var sum money.Amount
+		for i := range got {
+			sum += got[i]
+			if got[i] != tc.want[i] {
+				t.Errorf("%s: allocations = %v, want %v", tc.name, got, tc.want)
+				break
+			}
+		}
+		if sum != tc.amount {
+			t.Errorf("%s: allocated %d, want %d", tc.name, sum, tc.amount)
+		}
+	}
+}
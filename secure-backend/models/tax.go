@@ -0,0 +1,43 @@
+package models
+
+import (
+	"math"
+	"secure-backend/money"
+)
+
+// DefaultTaxJurisdiction is recorded on tax lines when TAX_JURISDICTION isn't configured
+const DefaultTaxJurisdiction = "default"
+
+// TaxLine is the tax included in one order item, recorded at checkout with the jurisdiction
+// and rate that applied
+type TaxLine struct {
+	OrderID       string       `db:"order_id" json:"order_id"`
+	OrderItemID   string       `db:"order_item_id" json:"order_item_id"`
+	SellerID      string       `db:"seller_id" json:"seller_id"`
+	Jurisdiction  string       `db:"jurisdiction" json:"jurisdiction"`
+	Rate          float64      `db:"rate" json:"rate"`
+	GrossAmount   money.Amount `db:"gross_amount" json:"gross_amount"`
+	TaxableAmount money.Amount `db:"taxable_amount" json:"taxable_amount"`
+	TaxAmount     money.Amount `db:"tax_amount" json:"tax_amount"`
+}
+
+// SplitIncludedTax splits a tax-inclusive amount into its taxable (net) part and the tax,
+// rounding to cents the same way invoices do so the two always add up to gross
+func SplitIncludedTax(gross money.Amount, rate float64) (taxable, tax money.Amount) {
+	net := money.Amount(math.Round(float64(gross) / (1 + rate)))
+	return net, gross - net
+}
+
+// TaxReportRow is the tax collected in one jurisdiction at one rate over a report period.
+// Sales count the items of orders placed in the period that were paid; refunds count the
+// tax share of refunds issued in the period.
+type TaxReportRow struct {
+	Jurisdiction string       `db:"jurisdiction" json:"jurisdiction"`
+	Rate         float64      `db:"rate" json:"rate"`
+	OrderCount   int          `db:"order_count" json:"order_count"`
+	GrossSales   money.Amount `db:"gross_sales" json:"gross_sales"`
+	TaxableSales money.Amount `db:"taxable_sales" json:"taxable_sales"`
+	TaxCollected money.Amount `db:"tax_collected" json:"tax_collected"`
+	TaxRefunded  money.Amount `db:"tax_refunded" json:"tax_refunded"`
+	NetTax       money.Amount `db:"net_tax" json:"net_tax"` // tax collected minus tax refunded
+}
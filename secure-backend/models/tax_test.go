@@ -0,0 +1,27 @@
+package models
+
+import (
+	"secure-backend/money"
+	"testing"
+)
+
+func TestSplitIncludedTax(t *testing.T) {
+	cases := []struct {
+		gross        money.Amount
+		rate         float64
+		taxable, tax money.Amount
+	}{
+		{12000, 0.2, 10000, 2000},
+		{1000, 0.2, 833, 167},
+		{1999, 0.19, 1680, 319},
+		{500, 0, 500, 0},
+		{0, 0.2, 0, 0},
+	}
+
+	for _, tc := range cases {
+		taxable, tax := SplitIncludedTax(tc.gross, tc.rate)
+		if taxable != tc.taxable || tax != tc.tax {
+			t.Errorf("SplitIncludedTax(%v, %v) = %v, %v; want %v, %v", tc.gross, tc.rate, taxable, tax, tc.taxable, tc.tax)
+		}
+	}
+}
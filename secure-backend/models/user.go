@@ -10,6 +10,59 @@ type User struct {
 	Password  string    `db:"password_hash" json:"-"` // Password hash, not exposed in JSON
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+
+	// BreakGlass marks a local-auth emergency admin that signs in with a password instead of Supabase
+	BreakGlass bool `db:"break_glass" json:"break_glass,omitempty"`
+}
+
+// Admin audit log actions
+const (
+	AuditBootstrapIssued       = "bootstrap.issued"
+	AuditBootstrapClaimed      = "bootstrap.claimed"
+	AuditBreakGlassProvisioned = "break_glass.provisioned"
+	AuditBreakGlassDisabled    = "break_glass.disabled"
+	AuditBreakGlassLogin       = "break_glass.login"
+	AuditBreakGlassLoginFailed = "break_glass.login_failed"
+	AuditBreakGlassRequest     = "break_glass.request"
+	AuditStockAdjusted         = "stock.adjusted"
+	AuditDuplicateReviewed     = "listing.duplicate_reviewed"
+	AuditPartnerKeyCreated     = "partner_key.created"
+	AuditPartnerKeyRevoked     = "partner_key.revoked"
+	AuditRoleChanged           = "user.role_changed"
+	AuditAddressChangeResolved = "order.address_change_resolved"
+	AuditGiftCardIssued        = "gift_card.issued"
+	AuditTokensRevoked         = "user.tokens_revoked"
+	AuditDisputeEvidence       = "dispute.evidence_submitted"
+	AuditPayoutResolved        = "payout.resolved"
+	AuditPeriodClosed          = "finance.period_closed"
+	AuditUserErased            = "user.erased"
+	AuditDebugAccessed         = "debug.accessed"
+	AuditProductsArchived      = "products.bulk_archived"
+	AuditProductsDeleted       = "products.bulk_deleted"
+	AuditSellerRatingModerated = "seller_rating.moderated"
+)
+
+// AuditEntry records one admin bootstrap, break-glass or stock correction event
+type AuditEntry struct {
+	ID         string    `db:"id" json:"id"`
+	ActorID    *string   `db:"actor_id" json:"actor_id,omitempty"`
+	ActorEmail string    `db:"actor_email" json:"actor_email,omitempty"`
+	Action     string    `db:"action" json:"action"`
+	Detail     string    `db:"detail" json:"detail,omitempty"`
+	IPAddress  string    `db:"ip_address" json:"ip_address,omitempty"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
+
+// Session is a server-side login session of the web storefront
+type Session struct {
+	ID        string    `db:"id" json:"-"`
+	TokenHash string    `db:"token_hash" json:"-"`
+	UserID    string    `db:"user_id" json:"-"`
+	Email     string    `db:"email" json:"-"`
+	CSRFToken string    `db:"csrf_token" json:"csrf_token"`
+	UserAgent string    `db:"user_agent" json:"-"`
+	ExpiresAt time.Time `db:"expires_at" json:"expires_at"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
 }
 
 // AuthUser represents an authenticated user with claims from Supabase JWT
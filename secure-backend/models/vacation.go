@@ -0,0 +1,65 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// OrderRuleSellerOnVacation is the order rule code for products of a seller on vacation
+const OrderRuleSellerOnVacation = "seller_on_vacation"
+
+// SellerVacation is a seller's scheduled break. While it is active the seller's products
+// can't be added to carts or ordered, and with HideListings they are left out of product
+// listings and search. A nil StartsAt means no vacation is scheduled; a nil EndsAt lasts
+// until the seller ends it.
+type SellerVacation struct {
+	StartsAt     *time.Time `db:"vacation_starts_at" json:"starts_at"`
+	EndsAt       *time.Time `db:"vacation_ends_at" json:"ends_at"`
+	Message      string     `db:"vacation_message" json:"message"`
+	HideListings bool       `db:"vacation_hide_listings" json:"hide_listings"`
+}
+
+// Active reports whether the vacation is in effect at now
+func (v *SellerVacation) Active(now time.Time) bool {
+	if v == nil || v.StartsAt == nil || now.Before(*v.StartsAt) {
+		return false
+	}
+	return v.EndsAt == nil || now.Before(*v.EndsAt)
+}
+
+// CheckOrders returns an *OrderRuleError if the vacation blocks ordering the product at now.
+// The error carries the seller's message and when they are back, if known.
+func (v *SellerVacation) CheckOrders(sellerID, productID string, now time.Time) error {
+	if !v.Active(now) {
+		return nil
+	}
+
+	message := "This seller is on vacation and isn't taking orders"
+	if v.EndsAt != nil {
+		message = fmt.Sprintf("This seller is on vacation and isn't taking orders until %s", v.EndsAt.Format("2006-01-02"))
+	}
+	if v.Message != "" {
+		message += ": " + v.Message
+	}
+
+	return &OrderRuleError{
+		Message:   message,
+		Code:      OrderRuleSellerOnVacation,
+		ProductID: productID,
+		SellerID:  sellerID,
+		Until:     v.EndsAt,
+	}
+}
+
+// AutoReply is the message posted for the seller when a buyer starts a conversation with
+// them during the vacation
+func (v *SellerVacation) AutoReply() string {
+	reply := "I'm on vacation and may be slow to reply"
+	if v.EndsAt != nil {
+		reply = fmt.Sprintf("I'm on vacation until %s and may be slow to reply", v.EndsAt.Format("2006-01-02"))
+	}
+	if v.Message != "" {
+		return reply + ". " + v.Message
+	}
+	return reply + "."
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSellerVacation(t *testing.T) {
+	now := time.Date(2024, 7, 10, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-24*time.Hour), now.Add(24*time.Hour)
+
+	cases := []struct {
+		name     string
+		vacation *SellerVacation
+		active   bool
+	}{
+		{"none", nil, false},
+		{"not scheduled", &SellerVacation{}, false},
+		{"scheduled", &SellerVacation{StartsAt: &future}, false},
+		{"open-ended", &SellerVacation{StartsAt: &past}, true},
+		{"until tomorrow", &SellerVacation{StartsAt: &past, EndsAt: &future}, true},
+		{"over", &SellerVacation{StartsAt: &past, EndsAt: &now}, false},
+	}
+
+	for _, tc := range cases {
+		if got := tc.vacation.Active(now); got != tc.active {
+			t.Errorf("%s: Active = %t, want %t", tc.name, got, tc.active)
+		}
+
+		err := tc.vacation.CheckOrders("s1", "p1", now)
+		var ruleErr *OrderRuleError
+		switch {
+		case !tc.active && err != nil:
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		case tc.active && (!errors.As(err, &ruleErr) || ruleErr.Code != OrderRuleSellerOnVacation || ruleErr.Until != tc.vacation.EndsAt):
+			t.Errorf("%s: got %v, want a %s error", tc.name, err, OrderRuleSellerOnVacation)
+		}
+	}
+}
+
+func TestSellerVacationAutoReply(t *testing.T) {
+	until := time.Date(2024, 7, 20, 0, 0, 0, 0, time.UTC)
+
+	if got, want := (&SellerVacation{}).AutoReply(), "I'm on vacation and may be slow to reply."; got != want {
+		t.Errorf("AutoReply = %q, want %q", got, want)
+	}
+	vacation := &SellerVacation{EndsAt: &until, Message: "Orders ship when I'm back."}
+	if got, want := vacation.AutoReply(), "I'm on vacation until 2024-07-20 and may be slow to reply. Orders ship when I'm back."; got != want {
+		t.Errorf("AutoReply = %q, want %q", got, want)
+	}
+}
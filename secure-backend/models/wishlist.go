@@ -0,0 +1,40 @@
+package models
+
+import (
+	"secure-backend/money"
+	"time"
+)
+
+// WishlistItem is a product a buyer keeps an eye on. With PriceAlert set, the buyer is
+// notified when the price drops below AlertPrice (and TargetPrice, if set).
+type WishlistItem struct {
+	ID            string        `db:"id" json:"id"`
+	UserID        string        `db:"user_id" json:"user_id"`
+	ProductID     string        `db:"product_id" json:"product_id"`
+	PriceAlert    bool          `db:"price_alert" json:"price_alert"`
+	TargetPrice   *money.Amount `db:"target_price" json:"target_price"`
+	AlertPrice    money.Amount  `db:"alert_price" json:"alert_price"` // price when added or last alerted
+	LastAlertedAt *time.Time    `db:"last_alerted_at" json:"last_alerted_at,omitempty"`
+	CreatedAt     time.Time     `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time     `db:"updated_at" json:"updated_at"`
+}
+
+// WishlistItemWithProduct is a wishlist item with product details and availability
+type WishlistItemWithProduct struct {
+	WishlistItem
+	Product      Product `json:"product"`
+	Availability string  `json:"availability"`
+}
+
+// CheckAvailability sets whether the product can be bought now
+func (i *WishlistItemWithProduct) CheckAvailability() {
+	i.Availability, _ = availability(i.Product, 1)
+}
+
+// PriceDropAlert is a wishlist price alert triggered by a price change
+type PriceDropAlert struct {
+	UserID        string       `db:"user_id"`
+	ProductID     string       `db:"product_id"`
+	PreviousPrice money.Amount `db:"previous_price"`
+	Price         money.Amount `db:"price"`
+}
@@ -0,0 +1,124 @@
+// Package moderation screens text users write to each other before it is stored. Each filter
+// of a pipeline masks one kind of content (contact details that would take a sale off the
+// shop, profanity) and flags the text, so the message still reaches the other party and admins
+// can find flagged conversations when resolving disputes.
+package moderation
+
+import (
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Flags recorded for masked content
+const (
+	FlagLink      = "link"
+	FlagEmail     = "email"
+	FlagPhone     = "phone"
+	FlagProfanity = "profanity"
+)
+
+// Filter masks one kind of content
+type Filter struct {
+	Flag string         // recorded when the filter masked something
+	Find *regexp.Regexp // what to mask
+	Mask func(match string) string
+}
+
+// Pipeline runs filters in order; a later filter sees the text earlier ones masked
+type Pipeline []Filter
+
+// Result is screened text and what was masked in it
+type Result struct {
+	Text  string
+	Flags []string // flags of the filters that matched, in pipeline order
+}
+
+// Flagged reports whether anything was masked
+func (r Result) Flagged() bool {
+	return len(r.Flags) > 0
+}
+
+// Screen runs text through the pipeline
+func (p Pipeline) Screen(text string) Result {
+	result := Result{Text: text}
+	for _, filter := range p {
+		if !filter.Find.MatchString(result.Text) {
+			continue
+		}
+		result.Text = filter.Find.ReplaceAllStringFunc(result.Text, filter.Mask)
+		result.Flags = append(result.Flags, filter.Flag)
+	}
+	return result
+}
+
+// replaceWith masks every match with the same placeholder
+func replaceWith(placeholder string) func(string) string {
+	return func(string) string { return placeholder }
+}
+
+// stars masks a word with as many asterisks as it has letters
+func stars(match string) string {
+	return strings.Repeat("*", len([]rune(match)))
+}
+
+var (
+	emailPattern = regexp.MustCompile(`(?i)[a-z0-9._%+-]+@[a-z0-9-]+(?:\.[a-z0-9-]+)+`)
+	linkPattern  = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+|\b[a-z0-9-]+(?:\.[a-z0-9-]+)*\.(?:com|net|org|io|co|shop|store|info|biz|me|app)\b(?:/\S*)?`)
+	phonePattern = regexp.MustCompile(`\+?\d[\d ()./-]{7,}\d`)
+)
+
+// defaultBlockedWords are masked in every message; MODERATION_BLOCKED_WORDS adds to them
+var defaultBlockedWords = []string{
+	"arsehole", "asshole", "bastard", "bitch", "bullshit", "cunt", "dickhead",
+	"fuck", "fucker", "fucking", "motherfucker", "shit", "wanker",
+}
+
+// profanityPattern matches the blocked words as whole words, case-insensitively
+func profanityPattern(words []string) *regexp.Regexp {
+	quoted := make([]string, 0, len(words))
+	for _, word := range words {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+			quoted = append(quoted, regexp.QuoteMeta(word))
+		}
+	}
+	return regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+}
+
+// blockedWords returns the default blocked words and those in MODERATION_BLOCKED_WORDS
+// (comma-separated)
+func blockedWords() []string {
+	words := append([]string{}, defaultBlockedWords...)
+	if value := os.Getenv("MODERATION_BLOCKED_WORDS"); value != "" {
+		extra := strings.Split(value, ",")
+		words = append(words, extra...)
+		log.Printf("Moderation: blocking %d additional words from MODERATION_BLOCKED_WORDS", len(extra))
+	}
+	return words
+}
+
+// NewMessagePipeline returns the filters for messages between buyers and sellers. Email
+// addresses go before links so their domain isn't masked as a link on its own.
+func NewMessagePipeline(words []string) Pipeline {
+	return Pipeline{
+		{Flag: FlagEmail, Find: emailPattern, Mask: replaceWith("[email removed]")},
+		{Flag: FlagLink, Find: linkPattern, Mask: replaceWith("[link removed]")},
+		{Flag: FlagPhone, Find: phonePattern, Mask: replaceWith("[phone number removed]")},
+		{Flag: FlagProfanity, Find: profanityPattern(words), Mask: stars},
+	}
+}
+
+var (
+	messagesOnce sync.Once
+	messages     Pipeline
+)
+
+// ScreenMessage runs a buyer or seller message through the message pipeline
+func ScreenMessage(text string) Result {
+	messagesOnce.Do(func() {
+		messages = NewMessagePipeline(blockedWords())
+	})
+	return messages.Screen(text)
+}
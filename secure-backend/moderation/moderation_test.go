@@ -0,0 +1,44 @@
+package moderation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessagePipeline(t *testing.T) {
+	pipeline := NewMessagePipeline(defaultBlockedWords)
+
+	result := pipeline.Screen("Is the lamp still available? Does it come with a bulb?")
+	assert.False(t, result.Flagged())
+	assert.Equal(t, "Is the lamp still available? Does it come with a bulb?", result.Text)
+
+	result = pipeline.Screen("Mail me at jane.doe+shop@example.com instead")
+	assert.Equal(t, "Mail me at [email removed] instead", result.Text)
+	assert.Equal(t, []string{FlagEmail}, result.Flags)
+
+	result = pipeline.Screen("Cheaper at https://example.com/lamp?ref=1 or www.example.org, also lamps.shop")
+	assert.Equal(t, "Cheaper at [link removed] or [link removed] also [link removed]", result.Text)
+	assert.Equal(t, []string{FlagLink}, result.Flags)
+
+	result = pipeline.Screen("Call +49 (30) 1234-5678, order 12 shipped on 2024.")
+	assert.Equal(t, "Call [phone number removed], order 12 shipped on 2024.", result.Text)
+	assert.Equal(t, []string{FlagPhone}, result.Flags)
+
+	result = pipeline.Screen("This is SHIT, total bullshit. Shitake mushrooms are fine.")
+	assert.Equal(t, "This is ****, total ********. Shitake mushrooms are fine.", result.Text)
+	assert.Equal(t, []string{FlagProfanity}, result.Flags)
+
+	result = pipeline.Screen("Damn it, see www.example.com you bastard")
+	assert.Equal(t, "Damn it, see [link removed] you *******", result.Text)
+	assert.Equal(t, []string{FlagLink, FlagProfanity}, result.Flags)
+}
+
+func TestBlockedWordsFromEnv(t *testing.T) {
+	t.Setenv("MODERATION_BLOCKED_WORDS", " Darn ,heck")
+	pipeline := NewMessagePipeline(blockedWords())
+
+	result := pipeline.Screen("darn it, what the Heck")
+	assert.Equal(t, "**** it, what the ****", result.Text)
+	assert.Equal(t, []string{FlagProfanity}, result.Flags)
+}
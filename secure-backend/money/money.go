@@ -0,0 +1,130 @@
+// Package money holds amounts of money as integer cents, so sums and comparisons are exact.
+// Amounts are DECIMAL(…,2) columns in the database and decimal numbers with two places in
+// JSON, so neither the schema nor clients see the difference.
+package money
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// Amount is an amount of money in cents
+type Amount int64
+
+// ErrInvalid is returned when parsing something that isn't a decimal amount of whole cents
+var ErrInvalid = errors.New("invalid amount")
+
+// FromFloat rounds a float to the nearest cent. It is meant for results of arithmetic with
+// rates and ratios, not for amounts read from clients or the database.
+func FromFloat(f float64) Amount {
+	return Amount(math.Round(f * 100))
+}
+
+// Parse reads a decimal amount such as "12", "12.5" or "-0.99". More than two decimal
+// places are refused rather than rounded.
+func Parse(s string) (Amount, error) {
+	return parse(s, false)
+}
+
+// parse converts a decimal string into cents, rounding half away from zero when round is set
+func parse(s string, round bool) (Amount, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrInvalid, s)
+	}
+	r.Mul(r, big.NewRat(100, 1))
+	if !r.IsInt() && !round {
+		return 0, fmt.Errorf("%w: %q has more than two decimal places", ErrInvalid, s)
+	}
+	cents, rem := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if rem.Sign() != 0 && new(big.Int).Mul(rem.Abs(rem), big.NewInt(2)).Cmp(r.Denom()) >= 0 {
+		cents.Add(cents, big.NewInt(int64(r.Sign())))
+	}
+	if !cents.IsInt64() {
+		return 0, fmt.Errorf("%w: %q is out of range", ErrInvalid, s)
+	}
+	return Amount(cents.Int64()), nil
+}
+
+// Cents returns the amount in cents, the minor unit payment providers take
+func (a Amount) Cents() int64 {
+	return int64(a)
+}
+
+// Float64 returns the amount as a decimal float, for arithmetic with rates
+func (a Amount) Float64() float64 {
+	return float64(a) / 100
+}
+
+// Times returns the amount for quantity units
+func (a Amount) Times(quantity int) Amount {
+	return a * Amount(quantity)
+}
+
+// String formats the amount with two decimal places, e.g. "-12.50"
+func (a Amount) String() string {
+	sign := ""
+	cents := int64(a)
+	if cents < 0 {
+		sign = "-"
+	}
+	units, frac := cents/100, cents%100
+	if units < 0 {
+		units = -units
+	}
+	if frac < 0 {
+		frac = -frac
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, units, frac)
+}
+
+// MarshalJSON writes the amount as a JSON number with two decimal places
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON reads a JSON number, or a string holding one
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	s := string(data)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	amount, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
+
+// Scan reads a DECIMAL column. Values with more places, such as averages, are rounded to
+// the cent.
+func (a *Amount) Scan(src interface{}) error {
+	var err error
+	switch v := src.(type) {
+	case []byte:
+		*a, err = parse(string(v), true)
+	case string:
+		*a, err = parse(v, true)
+	case int64:
+		*a = Amount(v * 100)
+	case float64:
+		*a = FromFloat(v)
+	default:
+		err = fmt.Errorf("money: cannot scan %T into an Amount", src)
+	}
+	return err
+}
+
+// Value writes the amount as a decimal string, which Postgres reads as a NUMERIC exactly
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
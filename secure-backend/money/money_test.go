@@ -0,0 +1,82 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	for input, want := range map[string]Amount{
+		"12":     1200,
+		"12.5":   1250,
+		"12.50":  1250,
+		"0.1":    10,
+		"-0.99":  -99,
+		"1e2":    10000,
+		"0.30":   30,
+		"199.99": 19999,
+	} {
+		got, err := Parse(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, want, got, input)
+	}
+
+	for _, input := range []string{"", "abc", "12.345", "0.001", "1e30"} {
+		_, err := Parse(input)
+		assert.True(t, errors.Is(err, ErrInvalid), input)
+	}
+}
+
+func TestString(t *testing.T) {
+	assert.Equal(t, "0.00", Amount(0).String())
+	assert.Equal(t, "12.50", Amount(1250).String())
+	assert.Equal(t, "0.05", Amount(5).String())
+	assert.Equal(t, "-0.05", Amount(-5).String())
+	assert.Equal(t, "-12.30", Amount(-1230).String())
+}
+
+func TestJSON(t *testing.T) {
+	var v struct {
+		Price  Amount  `json:"price"`
+		Target *Amount `json:"target"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(`{"price": 10.10, "target": "4.5"}`), &v))
+	assert.Equal(t, Amount(1010), v.Price)
+	assert.Equal(t, Amount(450), *v.Target)
+
+	out, err := json.Marshal(v)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"price": 10.10, "target": 4.50}`, string(out))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"price": 0.125}`), &v), "fractions of a cent are refused")
+}
+
+func TestScan(t *testing.T) {
+	var a Amount
+	assert.NoError(t, a.Scan([]byte("30.60")))
+	assert.Equal(t, Amount(3060), a)
+
+	// Averages and products with rates come back with more places and are rounded
+	assert.NoError(t, a.Scan([]byte("12.3450000")))
+	assert.Equal(t, Amount(1235), a)
+	assert.NoError(t, a.Scan([]byte("-12.345")))
+	assert.Equal(t, Amount(-1235), a)
+
+	assert.NoError(t, a.Scan(int64(7)))
+	assert.Equal(t, Amount(700), a)
+	assert.Error(t, a.Scan(nil))
+
+	value, err := Amount(-1999).Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "-19.99", value)
+}
+
+func TestFromFloat(t *testing.T) {
+	// 0.1 + 0.2 isn't 0.3 in floating point, but is 30 cents
+	assert.Equal(t, Amount(30), FromFloat(0.1+0.2))
+	assert.Equal(t, Amount(1010).Times(3)+Amount(30), Amount(3060))
+	assert.Equal(t, 30.6, Amount(3060).Float64())
+}
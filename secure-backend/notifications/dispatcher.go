@@ -0,0 +1,104 @@
+package notifications
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Notification types
+const (
+	TypeOrderStatus    = "order_status"
+	TypeJobCompleted   = "job_completed"
+	TypeJobFailed      = "job_failed"
+	TypePriceDrop      = "price_drop"
+	TypeOrderAddress   = "order_address"
+	TypePaymentFailed  = "payment_failed"
+	TypePayoutHold     = "payout_hold"
+	TypeReconciliation = "payout_reconciliation"
+	TypeMessage        = "message"
+	TypeSavedSearch    = "saved_search"
+)
+
+// Notification is a message addressed to a single user
+type Notification struct {
+	UserID string            `json:"user_id"`
+	Type   string            `json:"type"`
+	Title  string            `json:"title"`
+	Body   string            `json:"body"`
+	Data   map[string]string `json:"data,omitempty"`
+}
+
+// Channel delivers notifications over one medium (push, email, ...)
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, n Notification) error
+}
+
+// Dispatcher fans notifications out to every registered channel
+type Dispatcher struct {
+	mu       sync.RWMutex
+	channels []Channel
+	timeout  time.Duration
+}
+
+// NewDispatcher creates a dispatcher with no channels
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{timeout: 30 * time.Second}
+}
+
+// defaultDispatcher is the process-wide dispatcher used by the package-level helpers
+var defaultDispatcher = NewDispatcher()
+
+// Register adds a delivery channel to the default dispatcher
+func Register(ch Channel) {
+	defaultDispatcher.Register(ch)
+}
+
+// Dispatch sends a notification through the default dispatcher
+func Dispatch(n Notification) {
+	defaultDispatcher.Dispatch(n)
+}
+
+// Channels returns the names of the channels registered with the default dispatcher
+func Channels() []string {
+	return defaultDispatcher.Channels()
+}
+
+// Register adds a delivery channel
+func (d *Dispatcher) Register(ch Channel) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.channels = append(d.channels, ch)
+}
+
+// Channels returns the names of the registered channels
+func (d *Dispatcher) Channels() []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	names := make([]string, len(d.channels))
+	for i, ch := range d.channels {
+		names[i] = ch.Name()
+	}
+	return names
+}
+
+// Dispatch delivers the notification asynchronously on every channel.
+// Delivery failures are logged and never block the caller.
+func (d *Dispatcher) Dispatch(n Notification) {
+	d.mu.RLock()
+	channels := append([]Channel(nil), d.channels...)
+	d.mu.RUnlock()
+
+	for _, ch := range channels {
+		go func(ch Channel) {
+			ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+			defer cancel()
+
+			if err := ch.Send(ctx, n); err != nil {
+				log.Printf("Failed to deliver %s notification via %s: %v", n.Type, ch.Name(), err)
+			}
+		}(ch)
+	}
+}
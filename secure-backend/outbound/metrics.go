@@ -0,0 +1,61 @@
+package outbound
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// IntegrationStats summarizes the calls made to one integration since startup
+type IntegrationStats struct {
+	Integration  string  `json:"integration"`
+	Calls        uint64  `json:"calls"`
+	Failures     uint64  `json:"failures"`
+	FailureRate  float64 `json:"failure_rate"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+}
+
+type counters struct {
+	calls, failures uint64
+	latency         time.Duration
+}
+
+var (
+	statsMu sync.Mutex
+	stats   = map[string]*counters{}
+)
+
+// record counts one call; transport errors and 4xx/5xx responses count as failures
+func record(integration string, failed bool, latency time.Duration) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	c, ok := stats[integration]
+	if !ok {
+		c = &counters{}
+		stats[integration] = c
+	}
+	c.calls++
+	c.latency += latency
+	if failed {
+		c.failures++
+	}
+}
+
+// Stats returns call counts, failure rates and average latency per integration, by name
+func Stats() []IntegrationStats {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	result := make([]IntegrationStats, 0, len(stats))
+	for name, c := range stats {
+		s := IntegrationStats{Integration: name, Calls: c.calls, Failures: c.failures}
+		if c.calls > 0 {
+			s.FailureRate = float64(c.failures) / float64(c.calls)
+			s.AvgLatencyMs = float64(c.latency) / float64(time.Millisecond) / float64(c.calls)
+		}
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Integration < result[j].Integration })
+	return result
+}
@@ -0,0 +1,175 @@
+// Package outbound instruments HTTP calls to third-party integrations (payments, push,
+// object storage). Each call is logged as one structured line with its target, latency,
+// status, retry count, a redacted payload summary and the ID of the request or job that
+// caused it, and counted in per-integration metrics.
+package outbound
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"secure-backend/tracing"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxSegmentLength is the longest URL path segment logged verbatim; longer segments
+// (device tokens, signatures) are redacted
+const maxSegmentLength = 40
+
+// maxSummaryKeys caps the number of payload field names listed in a log line
+const maxSummaryKeys = 10
+
+type contextKey int
+
+const (
+	correlationKey contextKey = iota
+	retryKey
+)
+
+// WithCorrelationID tags outbound calls made with ctx with a request or job ID
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey, id)
+}
+
+// CorrelationID returns the request or job ID attached to ctx, or "-"
+func CorrelationID(ctx context.Context) string {
+	if id, ok := ctx.Value(correlationKey).(string); ok && id != "" {
+		return id
+	}
+	return "-"
+}
+
+// WithRetry marks outbound calls made with ctx as the nth retry of an operation
+func WithRetry(ctx context.Context, retry int) context.Context {
+	return context.WithValue(ctx, retryKey, retry)
+}
+
+func retryCount(ctx context.Context) int {
+	retry, _ := ctx.Value(retryKey).(int)
+	return retry
+}
+
+// Transport is an http.RoundTripper that logs and measures calls to one integration
+type Transport struct {
+	Integration string
+	Base        http.RoundTripper
+}
+
+// NewClient returns an HTTP client whose calls are logged and counted under integration
+func NewClient(integration string, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &Transport{Integration: integration},
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	// Trace the call as part of the request or job that made it and pass the trace on
+	ctx, span := tracing.StartChild(req.Context(), t.Integration+" "+req.Method, tracing.KindClient)
+	defer span.End()
+	if span != nil {
+		span.SetAttribute("integration", t.Integration)
+		span.SetAttribute("http.request.method", req.Method)
+		span.SetAttribute("server.address", req.URL.Host)
+		req = req.Clone(ctx)
+		req.Header.Set(tracing.TraceparentHeader, span.Context().Traceparent())
+	}
+
+	summary := payloadSummary(req)
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+	latency := time.Since(start)
+
+	status := "error"
+	failed := err != nil
+	if resp != nil {
+		status = fmt.Sprintf("%d", resp.StatusCode)
+		failed = failed || resp.StatusCode >= 400
+		span.SetAttribute("http.response.status_code", resp.StatusCode)
+	}
+	record(t.Integration, failed, latency)
+	if err != nil {
+		span.SetError(err)
+	} else if failed {
+		span.SetError(fmt.Errorf("%d response", resp.StatusCode))
+	}
+
+	line := fmt.Sprintf("outbound integration=%s method=%s target=%s status=%s latency_ms=%d retry=%d correlation_id=%s payload=%q",
+		t.Integration, req.Method, redactURL(req.URL), status, latency.Milliseconds(),
+		retryCount(req.Context()), CorrelationID(req.Context()), summary)
+	if err != nil {
+		line += fmt.Sprintf(" error=%q", err.Error())
+	}
+	log.Print(line)
+
+	return resp, err
+}
+
+// redactURL drops the query string and credentials and hides long path segments
+func redactURL(u *url.URL) string {
+	segments := strings.Split(u.EscapedPath(), "/")
+	for i, segment := range segments {
+		if len(segment) > maxSegmentLength {
+			segments[i] = "[redacted]"
+		}
+	}
+	return u.Scheme + "://" + u.Host + strings.Join(segments, "/")
+}
+
+// payloadSummary describes a request body without its values: its size and, for form
+// and JSON bodies, the names of the top-level fields
+func payloadSummary(req *http.Request) string {
+	if req.Body == nil || req.Body == http.NoBody {
+		return "none"
+	}
+
+	contentType := req.Header.Get("Content-Type")
+	size := fmt.Sprintf("%dB", req.ContentLength)
+	if req.GetBody == nil {
+		return strings.TrimSpace(contentType + " " + size)
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return strings.TrimSpace(contentType + " " + size)
+	}
+	defer body.Close()
+
+	var keys []string
+	switch {
+	case strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
+		data, _ := io.ReadAll(body)
+		if form, err := url.ParseQuery(string(data)); err == nil {
+			for key := range form {
+				keys = append(keys, key)
+			}
+		}
+	case strings.HasPrefix(contentType, "application/json"):
+		var fields map[string]json.RawMessage
+		if json.NewDecoder(body).Decode(&fields) == nil {
+			for key := range fields {
+				keys = append(keys, key)
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return strings.TrimSpace(contentType + " " + size)
+	}
+
+	sort.Strings(keys)
+	if len(keys) > maxSummaryKeys {
+		keys = append(keys[:maxSummaryKeys], "...")
+	}
+	return fmt.Sprintf("%s %s fields=%s", contentType, size, strings.Join(keys, ","))
+}
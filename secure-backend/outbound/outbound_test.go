@@ -0,0 +1,88 @@
+package outbound
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"secure-backend/tracing"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransportLogsRedactedCall(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusPaymentRequired)
+	}))
+	defer server.Close()
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	client := NewClient("test-integration", 5*time.Second)
+	token := strings.Repeat("ab", 32)
+	form := url.Values{"amount": {"1999"}, "card_number": {"4242424242424242"}}
+	ctx := WithRetry(WithCorrelationID(context.Background(), "req-123"), 2)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/device/"+token+"?secret=s3cr3t",
+		strings.NewReader(form.Encode()))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	line := logs.String()
+	assert.Contains(t, line, "integration=test-integration")
+	assert.Contains(t, line, "status=402")
+	assert.Contains(t, line, "retry=2")
+	assert.Contains(t, line, "correlation_id=req-123")
+	assert.Contains(t, line, "/device/[redacted]")
+	assert.Contains(t, line, "fields=amount,card_number")
+	assert.NotContains(t, line, token)
+	assert.NotContains(t, line, "s3cr3t")
+	assert.NotContains(t, line, "4242")
+
+	for _, s := range Stats() {
+		if s.Integration == "test-integration" {
+			assert.Equal(t, uint64(1), s.Calls)
+			assert.Equal(t, 1.0, s.FailureRate)
+			return
+		}
+	}
+	t.Fatal("no stats recorded for test-integration")
+}
+
+func TestTransportPropagatesTrace(t *testing.T) {
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get(tracing.TraceparentHeader)
+	}))
+	defer server.Close()
+
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", server.URL+"/v1/traces")
+	tracing.Init()
+	defer tracing.Shutdown(context.Background())
+
+	client := NewClient("traced-integration", 5*time.Second)
+	ctx, span := tracing.Start(context.Background(), "POST /api/checkout", tracing.KindServer)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/charge", nil)
+	assert.NoError(t, err)
+	resp, err := client.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	span.End()
+
+	sc, ok := tracing.ParseTraceparent(traceparent)
+	assert.True(t, ok, "traceparent %q", traceparent)
+	assert.Equal(t, span.Context().TraceID, sc.TraceID)
+	assert.NotEqual(t, span.Context().SpanID, sc.SpanID, "the call gets its own span")
+	assert.Empty(t, req.Header.Get(tracing.TraceparentHeader), "the caller's request is not modified")
+}
@@ -0,0 +1,90 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/services"
+)
+
+var (
+	// ErrOrderNotCancellable is returned when the order has shipped or already ended
+	ErrOrderNotCancellable = errors.New("order can no longer be cancelled")
+	// ErrCancelRefundFailed is returned when a paid order was cancelled but its refund could not be issued
+	ErrCancelRefundFailed = errors.New("order cancelled but the refund failed")
+)
+
+// CancelOrder cancels a pending or paid order. Cancelling returns the order's reserved stock
+// and any store credit applied to an unpaid order in the same transaction as the status change;
+// a paid order is then refunded in full and an unpaid order's open payment intents are cancelled
+// so they can no longer be charged.
+func CancelOrder(ctx context.Context, order *models.Order, actor *models.AuthUser, reason string) (*models.OrderStatusChange, *models.Refund, error) {
+	wasPaid := order.Status == services.OrderStatusPaid
+	if order.Status != services.OrderStatusPending && !wasPaid {
+		return nil, nil, ErrOrderNotCancellable
+	}
+	if wasPaid {
+		// The refund would fail; the buyer gets the money back through the dispute instead
+		disputed, err := database.HasOpenDispute(ctx, order.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if disputed {
+			return nil, nil, ErrOrderDisputed
+		}
+	}
+
+	change, err := services.TransitionOrder(ctx, order.ID, services.OrderStatusCancelled, actor, reason)
+	if errors.Is(err, services.ErrInvalidTransition) || errors.Is(err, services.ErrTransitionConflict) ||
+		errors.Is(err, database.ErrFulfillmentStarted) {
+		// Shipped or paid concurrently, or a seller already shipped some of the items
+		return nil, nil, ErrOrderNotCancellable
+	} else if err != nil {
+		return nil, nil, err
+	}
+	order.Status = services.OrderStatusCancelled
+
+	if !wasPaid {
+		cancelOpenPaymentIntents(ctx, order.ID)
+		return change, nil, nil
+	}
+
+	refund, err := RefundOrder(ctx, order, database.RefundRequest{Reason: reason}, actor)
+	if err != nil {
+		log.Printf("Failed to refund cancelled order %s: %v", order.ID, err)
+		return change, nil, fmt.Errorf("%w: %v", ErrCancelRefundFailed, err)
+	}
+
+	return change, refund, nil
+}
+
+// cancelOpenPaymentIntents cancels the provider intents of an order that were never paid.
+// Failures are logged: a late payment on a cancelled order is caught by the webhook.
+func cancelOpenPaymentIntents(ctx context.Context, orderID string) {
+	if stripeClient == nil {
+		return
+	}
+
+	payments, err := database.GetPaymentsByOrder(ctx, orderID)
+	if err != nil {
+		log.Printf("Failed to load payments of cancelled order %s: %v", orderID, err)
+		return
+	}
+
+	for _, payment := range payments {
+		if payment.Provider != ProviderStripe || payment.Status == intentSucceeded || payment.Status == intentCanceled {
+			continue
+		}
+		intent, err := stripeClient.CancelPaymentIntent(ctx, payment.ProviderPaymentID)
+		if err != nil {
+			log.Printf("Failed to cancel payment intent %s: %v", payment.ProviderPaymentID, err)
+			continue
+		}
+		if err := database.UpdatePaymentStatus(ctx, payment.ID, intent.Status); err != nil {
+			log.Printf("Failed to update status of payment %s: %v", payment.ID, err)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+//go:build e2e
+
+// Order cancellation tests against a real PostgreSQL database (with database/schema.sql applied)
+// and a fake Stripe API:
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run TestCancelOrder ./payments
+package payments
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/services"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// orderFixture is a seller's product a buyer checks out, an admin, and a fake Stripe API
+// that cancels payment intents and accepts refunds unless told to fail them
+type orderFixture struct {
+	admin     *models.AuthUser
+	buyer     *models.AuthUser
+	seller    *models.AuthUser
+	productID string
+
+	mu            sync.Mutex
+	failRefunds   bool
+	stripeRefunds int
+}
+
+func newOrderFixture(t *testing.T, stock int) *orderFixture {
+	t.Helper()
+	users := createTestUsers(t, "order", "seller", "admin", "buyer")
+	f := &orderFixture{seller: users[0], admin: users[1], buyer: users[2]}
+	t.Cleanup(func() { deleteTestOrders(f.buyer.ID) })
+	require.NoError(t, database.DB.GetContext(context.Background(), &f.productID, `
+		INSERT INTO products (name, price, stock, status, seller_id) VALUES ('Cancelled product', 5, $1, 'published', $2) RETURNING id
+	`, stock, f.seller.ID))
+
+	previous := stripeClient
+	t.Cleanup(func() { stripeClient = previous })
+	stripeClient = newTestStripeClient(t, func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		switch {
+		case r.URL.Path == "/refunds" && f.failRefunds:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case r.URL.Path == "/refunds":
+			f.stripeRefunds++
+			assert.NoError(t, r.ParseForm())
+			fmt.Fprintf(w, `{"id":"re_%d","amount":%s,"status":"succeeded"}`, f.stripeRefunds, r.PostForm.Get("amount"))
+		case strings.HasSuffix(r.URL.Path, "/cancel"):
+			id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/payment_intents/"), "/cancel")
+			fmt.Fprintf(w, `{"id":%q,"status":%q}`, id, intentCanceled)
+		default:
+			t.Errorf("unexpected Stripe request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	return f
+}
+
+// checkout puts quantity units of the product in the buyer's cart and checks out
+func (f *orderFixture) checkout(t *testing.T, quantity int) *models.Order {
+	t.Helper()
+	_, err := database.AddToCart(context.Background(), f.buyer.ID, f.productID, quantity)
+	require.NoError(t, err)
+	order, _, err := services.Checkout(context.Background(), f.buyer, &models.ClientInfo{Platform: "web"}, "")
+	require.NoError(t, err)
+	return order
+}
+
+// pay records a card payment of what store credit didn't cover and marks the order paid
+func (f *orderFixture) pay(t *testing.T, order *models.Order) *models.Order {
+	t.Helper()
+	ctx := context.Background()
+	credit, err := database.GetAppliedStoreCredit(ctx, order.ID)
+	require.NoError(t, err)
+	require.NoError(t, database.CreatePayment(ctx, &models.Payment{
+		OrderID:           order.ID,
+		Provider:          ProviderStripe,
+		ProviderPaymentID: "pi_" + uuid.NewString(),
+		Amount:            order.TotalAmount - credit,
+		Currency:          "usd",
+		Status:            intentSucceeded,
+	}))
+	_, err = services.TransitionOrder(ctx, order.ID, services.OrderStatusPaid, nil, "")
+	require.NoError(t, err)
+	return f.order(t, order.ID)
+}
+
+// setFailRefunds makes the fake Stripe API refuse refunds
+func (f *orderFixture) setFailRefunds(fail bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failRefunds = fail
+}
+
+// refunds returns how many refunds the fake Stripe API accepted
+func (f *orderFixture) refunds() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stripeRefunds
+}
+
+// dispute opens a dispute of the order's card payment
+func (f *orderFixture) dispute(t *testing.T, order *models.Order) {
+	t.Helper()
+	ctx := context.Background()
+	payments, err := database.GetPaymentsByOrder(ctx, order.ID)
+	require.NoError(t, err)
+	for _, payment := range payments {
+		if payment.Provider != ProviderStripe {
+			continue
+		}
+		_, err = database.DB.ExecContext(ctx, `
+			INSERT INTO disputes (order_id, payment_id, provider, provider_dispute_id, amount, currency, status)
+			VALUES ($1, $2, $3, $4, $5, 'usd', 'needs_response')
+		`, order.ID, payment.ID, ProviderStripe, "dp_"+uuid.NewString(), payment.Amount)
+		require.NoError(t, err)
+	}
+}
+
+func (f *orderFixture) order(t *testing.T, orderID string) *models.Order {
+	t.Helper()
+	order, err := database.GetOrderByID(context.Background(), orderID)
+	require.NoError(t, err)
+	return order
+}
+
+func (f *orderFixture) stock(t *testing.T) int {
+	t.Helper()
+	product, err := database.GetProductByID(context.Background(), f.productID)
+	require.NoError(t, err)
+	return product.Stock
+}
+
+func TestCancelOrderPending(t *testing.T) {
+	f := newOrderFixture(t, 5)
+	ctx := context.Background()
+
+	order := f.checkout(t, 2)
+	intent := &models.Payment{
+		OrderID:           order.ID,
+		Provider:          ProviderStripe,
+		ProviderPaymentID: "pi_" + uuid.NewString(),
+		Amount:            order.TotalAmount,
+		Currency:          "usd",
+		Status:            intentRequiresPaymentMethod,
+	}
+	require.NoError(t, database.CreatePayment(ctx, intent))
+	assert.Equal(t, 3, f.stock(t))
+
+	change, refund, err := CancelOrder(ctx, order, f.buyer, "changed my mind")
+	require.NoError(t, err)
+	assert.Nil(t, refund, "nothing was paid")
+	assert.Equal(t, services.OrderStatusCancelled, change.ToStatus)
+	assert.Equal(t, services.OrderStatusCancelled, f.order(t, order.ID).Status)
+	assert.Equal(t, 5, f.stock(t))
+
+	// The open payment intent can no longer be charged
+	payments, err := database.GetPaymentsByOrder(ctx, order.ID)
+	require.NoError(t, err)
+	require.Len(t, payments, 1)
+	assert.Equal(t, intentCanceled, payments[0].Status)
+
+	_, _, err = CancelOrder(ctx, f.order(t, order.ID), f.buyer, "")
+	assert.ErrorIs(t, err, ErrOrderNotCancellable)
+}
+
+func TestCancelOrderPaid(t *testing.T) {
+	f := newOrderFixture(t, 5)
+	ctx := context.Background()
+
+	order := f.pay(t, f.checkout(t, 2))
+
+	_, refund, err := CancelOrder(ctx, order, f.buyer, "")
+	require.NoError(t, err)
+	require.NotNil(t, refund)
+	assert.Equal(t, order.TotalAmount, refund.Amount)
+	assert.False(t, refund.Restock, "cancelling already returned the stock")
+	assert.Equal(t, 1, f.refunds())
+	assert.Equal(t, services.OrderStatusCancelled, f.order(t, order.ID).Status)
+	assert.Equal(t, 5, f.stock(t))
+}
+
+func TestCancelOrderRefundRetry(t *testing.T) {
+	f := newOrderFixture(t, 5)
+	ctx := context.Background()
+
+	order := f.pay(t, f.checkout(t, 2))
+
+	// The order is cancelled and restocked even though the provider refused the refund
+	f.setFailRefunds(true)
+	_, _, err := CancelOrder(ctx, order, f.buyer, "")
+	assert.ErrorIs(t, err, ErrCancelRefundFailed)
+	order = f.order(t, order.ID)
+	assert.Equal(t, services.OrderStatusCancelled, order.Status)
+	assert.Equal(t, 5, f.stock(t))
+
+	// An admin retrying the refund can't put the units back in stock a second time
+	f.setFailRefunds(false)
+	_, err = RefundOrder(ctx, order, database.RefundRequest{Restock: true}, f.admin)
+	assert.ErrorIs(t, err, ErrAlreadyRestocked)
+	refund, err := RefundOrder(ctx, order, database.RefundRequest{}, f.admin)
+	require.NoError(t, err)
+	assert.Equal(t, order.TotalAmount, refund.Amount)
+	assert.Equal(t, 5, f.stock(t))
+}
+
+func TestCancelOrderShippedItem(t *testing.T) {
+	f := newOrderFixture(t, 5)
+	ctx := context.Background()
+
+	order := f.pay(t, f.checkout(t, 2))
+	_, err := database.DB.ExecContext(ctx, `UPDATE order_items SET fulfillment_status = 'shipped' WHERE order_id = $1`, order.ID)
+	require.NoError(t, err)
+
+	_, _, err = CancelOrder(ctx, order, f.buyer, "")
+	assert.ErrorIs(t, err, ErrOrderNotCancellable)
+	assert.Equal(t, services.OrderStatusPaid, f.order(t, order.ID).Status)
+	assert.Equal(t, 3, f.stock(t), "the shipped units aren't restocked")
+	assert.Zero(t, f.refunds())
+}
+
+func TestCancelOrderDisputed(t *testing.T) {
+	f := newOrderFixture(t, 5)
+	ctx := context.Background()
+
+	order := f.pay(t, f.checkout(t, 2))
+	f.dispute(t, order)
+
+	_, _, err := CancelOrder(ctx, order, f.buyer, "")
+	assert.ErrorIs(t, err, ErrOrderDisputed)
+	assert.Equal(t, services.OrderStatusPaid, f.order(t, order.ID).Status)
+	assert.Zero(t, f.refunds())
+}
@@ -0,0 +1,84 @@
+//go:build e2e
+
+// Card payment tests against a real PostgreSQL database (with database/schema.sql applied)
+// and a fake Stripe API:
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run TestConfirmOrderPayment ./payments
+package payments
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"secure-backend/database"
+	"secure-backend/money"
+	"secure-backend/services"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfirmOrderPayment(t *testing.T) {
+	buyerID := createTestUsers(t, "card", "buyer")[0].ID
+	t.Cleanup(func() { deleteTestOrders(buyerID) })
+	ctx := context.Background()
+
+	var orderID string
+	require.NoError(t, database.DB.GetContext(ctx, &orderID, `
+		INSERT INTO orders (buyer_id, status, total_amount) VALUES ($1, 'pending', 20) RETURNING id
+	`, buyerID))
+
+	// The fake Stripe API keeps one PaymentIntent whose status the test moves along
+	intentID := "pi_" + uuid.NewString()[:8]
+	var mu sync.Mutex
+	status := intentRequiresPaymentMethod
+	setStatus := func(s string) {
+		mu.Lock()
+		defer mu.Unlock()
+		status = s
+	}
+	previous := stripeClient
+	t.Cleanup(func() { stripeClient = previous })
+	stripeClient = newTestStripeClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(w, `{"id":%q,"amount":2000,"currency":"usd","status":%q,"client_secret":"secret"}`, intentID, status)
+	})
+
+	order, err := database.GetOrderByID(ctx, orderID)
+	require.NoError(t, err)
+	intent, payment, err := CreatePaymentIntent(ctx, order)
+	require.NoError(t, err)
+	assert.Equal(t, intentID, intent.ID)
+	assert.Equal(t, money.FromFloat(20), payment.Amount)
+
+	// Retrying returns the same payment record
+	_, retried, err := CreatePaymentIntent(ctx, order)
+	require.NoError(t, err)
+	assert.Equal(t, payment.ID, retried.ID)
+
+	// The order isn't paid until Stripe says so
+	_, err = ConfirmOrderPayment(ctx, order)
+	assert.ErrorIs(t, err, ErrPaymentIncomplete)
+	order, err = database.GetOrderByID(ctx, orderID)
+	require.NoError(t, err)
+	assert.Equal(t, services.OrderStatusPending, order.Status)
+
+	setStatus(intentSucceeded)
+	confirmed, err := ConfirmOrderPayment(ctx, order)
+	require.NoError(t, err)
+	assert.Equal(t, intentSucceeded, confirmed.Status)
+	order, err = database.GetOrderByID(ctx, orderID)
+	require.NoError(t, err)
+	assert.Equal(t, services.OrderStatusPaid, order.Status)
+
+	// Confirming again is harmless, and a paid order can't get another PaymentIntent
+	_, err = ConfirmOrderPayment(ctx, order)
+	assert.NoError(t, err)
+	_, _, err = CreatePaymentIntent(ctx, order)
+	assert.ErrorIs(t, err, ErrOrderNotPayable)
+}
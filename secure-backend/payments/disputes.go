@@ -0,0 +1,104 @@
+package payments
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/money"
+	"secure-backend/notifications"
+	"time"
+)
+
+// ErrOrderDisputed is returned when refunding an order whose payment is being disputed; the
+// card issuer returns the money if the buyer wins
+var ErrOrderDisputed = errors.New("order has an open payment dispute")
+
+// handleDispute records a dispute opened, updated or closed at the provider. The seller
+// payouts of the order are held while it is open, and the sellers are told when the hold is
+// placed and when it is lifted.
+func handleDispute(ctx context.Context, event *StripeDispute) error {
+	payment, err := database.GetPaymentByProviderID(ctx, ProviderStripe, event.PaymentIntent)
+	if err == sql.ErrNoRows {
+		log.Printf("Dispute webhook for unknown payment intent %s", event.PaymentIntent)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	dispute := &models.Dispute{
+		OrderID:           payment.OrderID,
+		PaymentID:         payment.ID,
+		Provider:          ProviderStripe,
+		ProviderDisputeID: event.ID,
+		Amount:            money.Amount(event.Amount),
+		Currency:          event.Currency,
+		Reason:            event.Reason,
+		Status:            event.Status,
+	}
+	if event.EvidenceDetails.DueBy > 0 {
+		dueBy := time.Unix(event.EvidenceDetails.DueBy, 0).UTC()
+		dispute.EvidenceDueBy = &dueBy
+	}
+
+	held, released, err := database.RecordDispute(ctx, dispute)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case held:
+		log.Printf("Dispute %s opened on order %s; seller payouts held", dispute.ProviderDisputeID, dispute.OrderID)
+		notifySellersOfPayoutHold(ctx, dispute, "Payout on hold",
+			"A buyer disputed the payment of an order. Your payout for it is on hold until the dispute is resolved.")
+	case released:
+		notifySellersOfPayoutHold(ctx, dispute, "Payout released",
+			"The payment dispute on an order was resolved in your favor. Your payout for it is no longer on hold.")
+	}
+	return nil
+}
+
+// notifySellersOfPayoutHold tells the sellers of a disputed order about their payout. Failures
+// are logged; the dispute itself is already saved.
+func notifySellersOfPayoutHold(ctx context.Context, dispute *models.Dispute, title, body string) {
+	sellerIDs, err := database.GetOrderSellerIDs(ctx, dispute.OrderID)
+	if err != nil {
+		log.Printf("Failed to notify sellers of dispute on order %s: %v", dispute.OrderID, err)
+		return
+	}
+
+	for _, sellerID := range sellerIDs {
+		notifications.Dispatch(notifications.Notification{
+			UserID: sellerID,
+			Type:   notifications.TypePayoutHold,
+			Title:  title,
+			Body:   body,
+			Data:   map[string]string{"order_id": dispute.OrderID, "dispute_status": dispute.Status},
+		})
+	}
+}
+
+// SubmitDisputeEvidence sends evidence for a dispute to the provider and stores it with the
+// dispute. With submit the evidence goes to the card issuer for review and can't be changed
+// afterwards; without it the evidence is only staged. Returns sql.ErrNoRows if the dispute
+// doesn't exist and database.ErrDisputeClosed if it was already decided.
+func SubmitDisputeEvidence(ctx context.Context, id string, evidence models.DisputeEvidence, submit bool, audit *models.AuditEntry) (*models.Dispute, error) {
+	if stripeClient == nil {
+		return nil, ErrNotConfigured
+	}
+
+	dispute, err := database.GetDispute(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if dispute.ClosedAt != nil {
+		return nil, database.ErrDisputeClosed
+	}
+
+	if _, err := stripeClient.UpdateDispute(ctx, dispute.ProviderDisputeID, evidence, submit); err != nil {
+		return nil, err
+	}
+	return database.SaveDisputeEvidence(ctx, id, evidence, submit, audit)
+}
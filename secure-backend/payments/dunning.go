@@ -0,0 +1,289 @@
+package payments
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"secure-backend/database"
+	"secure-backend/jobs"
+	"secure-backend/models"
+	"secure-backend/notifications"
+	"secure-backend/services"
+	"secure-backend/tokens"
+	"strings"
+	"time"
+)
+
+// TypePaymentRetry retries an order's failed card payment; each attempt schedules the next
+const TypePaymentRetry = "payment_retry"
+
+// defaultRetryDelays are the waits before each retry of a failed payment
+var defaultRetryDelays = []time.Duration{time.Hour, 6 * time.Hour, 24 * time.Hour}
+
+// Stripe PaymentIntent status after a failed attempt
+const intentRequiresPaymentMethod = "requires_payment_method"
+
+func init() {
+	jobs.Register(TypePaymentRetry, jobs.Definition{
+		Description: "payment retry",
+		Task:        runPaymentRetry,
+	})
+}
+
+// paymentRetryParams are the params of a payment retry job
+type paymentRetryParams struct {
+	OrderID string `json:"order_id"`
+}
+
+// RetryDelays returns the waits before each retry of a failed payment, configurable via
+// PAYMENT_RETRY_DELAYS as comma-separated durations (e.g. "1h,6h,24h"). The order is
+// cancelled when the last retry fails.
+func RetryDelays() []time.Duration {
+	value := os.Getenv("PAYMENT_RETRY_DELAYS")
+	if value == "" {
+		return defaultRetryDelays
+	}
+
+	var delays []time.Duration
+	for _, part := range strings.Split(value, ",") {
+		delay, err := time.ParseDuration(strings.TrimSpace(part))
+		if err != nil || delay <= 0 {
+			log.Printf("Invalid PAYMENT_RETRY_DELAYS %q, using the default", value)
+			return defaultRetryDelays
+		}
+		delays = append(delays, delay)
+	}
+	return delays
+}
+
+// PaymentUpdateURL returns the signed link a buyer uses to pay a failed order with another
+// card until the final retry. Links are absolute when PUBLIC_API_URL is set.
+func PaymentUpdateURL(orderID string, expiresAt time.Time) (string, error) {
+	token, err := tokens.Sign(tokens.PurposePaymentUpdate, orderID, expiresAt)
+	if err != nil {
+		return "", err
+	}
+	base := strings.TrimSuffix(os.Getenv("PUBLIC_API_URL"), "/")
+	return base + "/api/payments/update/" + token, nil
+}
+
+// startDunning schedules retries of a failed card payment and tells the buyer how to update
+// their payment method. The order's stock stays reserved until the final retry. Failures of
+// the retries themselves don't start another round.
+func startDunning(ctx context.Context, payment *models.Payment) error {
+	order, err := database.GetOrderByID(ctx, payment.OrderID)
+	if err != nil {
+		return err
+	}
+	if order.Status != services.OrderStatusPending {
+		return nil
+	}
+
+	delays := RetryDelays()
+	now := clk.Now()
+	firstAttempt := now.Add(delays[0])
+	finalAttempt := now
+	for _, delay := range delays {
+		finalAttempt = finalAttempt.Add(delay)
+	}
+
+	started, err := database.StartPaymentDunning(ctx, order.ID, payment.ID, firstAttempt, finalAttempt,
+		finalAttempt.Add(services.ReservationTTL()))
+	if err != nil || !started {
+		return err
+	}
+
+	if _, err := jobs.Schedule(ctx, order.UserID, TypePaymentRetry, paymentRetryParams{OrderID: order.ID}, firstAttempt); err != nil {
+		// The reservation reaper still cancels the order once its extended hold runs out
+		log.Printf("Failed to schedule payment retry for order %s: %v", order.ID, err)
+	}
+
+	notifyPaymentFailed(order, firstAttempt, finalAttempt)
+	return nil
+}
+
+// runPaymentRetry charges the saved payment method of an order in dunning again. A failed
+// attempt schedules the next retry, or cancels the order and releases its stock after the last.
+func runPaymentRetry(ctx context.Context, job *models.Job) error {
+	var params paymentRetryParams
+	if err := json.Unmarshal(job.Params, &params); err != nil {
+		return fmt.Errorf("decoding payment retry params: %w", err)
+	}
+
+	dunning, err := database.GetPaymentDunning(ctx, params.OrderID)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if dunning.Status != models.DunningActive {
+		return nil
+	}
+
+	order, err := database.GetOrderByID(ctx, dunning.OrderID)
+	if err != nil {
+		return err
+	}
+	switch order.Status {
+	case services.OrderStatusPending:
+	case services.OrderStatusCancelled:
+		return closeDunning(ctx, order.ID, models.DunningCancelled)
+	default:
+		// Paid in the meantime, e.g. through the payment update link
+		return closeDunning(ctx, order.ID, models.DunningRecovered)
+	}
+
+	if stripeClient == nil {
+		return ErrNotConfigured
+	}
+
+	attempt := dunning.Attempts + 1
+	intent, failure, err := retryPaymentIntent(ctx, dunning, attempt)
+	if err != nil {
+		return err
+	}
+	if err := database.UpdatePaymentStatus(ctx, dunning.PaymentID, intent.Status); err != nil {
+		log.Printf("Failed to update status of payment %s: %v", dunning.PaymentID, err)
+	}
+
+	if intent.Status == intentSucceeded {
+		return markOrderPaid(ctx, order.ID, fmt.Sprintf("Payment %s succeeded on retry %d", intent.ID, attempt))
+	}
+
+	delays := RetryDelays()
+	if attempt < len(delays) {
+		next := clk.Now().Add(delays[attempt])
+		if err := database.RecordDunningAttempt(ctx, order.ID, failure, &next); err != nil {
+			return err
+		}
+		if _, err := jobs.Schedule(ctx, order.UserID, TypePaymentRetry, params, next); err != nil {
+			return err
+		}
+		notifyPaymentFailed(order, next, dunning.FinalAttemptAt)
+		return nil
+	}
+
+	if err := database.RecordDunningAttempt(ctx, order.ID, failure, nil); err != nil {
+		return err
+	}
+	return cancelAfterDunning(ctx, order, attempt)
+}
+
+// retryPaymentIntent confirms the order's PaymentIntent again with the payment method that was
+// declined. It returns the intent and, if the attempt failed, why. Declines are not errors;
+// errors are left for the job runner so the attempt can be retried.
+func retryPaymentIntent(ctx context.Context, dunning *models.PaymentDunning, attempt int) (*PaymentIntent, string, error) {
+	intent, err := stripeClient.GetPaymentIntent(ctx, dunning.ProviderPaymentID)
+	if err != nil {
+		return nil, "", err
+	}
+	if intent.Status != intentRequiresPaymentMethod {
+		// Paid since, or still processing or waiting for the buyer to authenticate (checked again next time)
+		return intent, "payment is " + intent.Status, nil
+	}
+	if intent.LastPaymentError == nil || intent.LastPaymentError.PaymentMethod == nil {
+		return intent, "no saved payment method to retry", nil
+	}
+
+	retried, err := stripeClient.ConfirmPaymentIntent(ctx, intent.ID, intent.LastPaymentError.PaymentMethod.ID,
+		fmt.Sprintf("order-%s-payment-retry-%d", dunning.OrderID, attempt))
+	var stripeErr *StripeError
+	if errors.As(err, &stripeErr) && stripeErr.StatusCode < 500 {
+		// Declined again; Stripe answers with an error instead of the intent
+		intent.Status = intentRequiresPaymentMethod
+		return intent, stripeErr.Message, nil
+	} else if err != nil {
+		return nil, "", err
+	}
+
+	failure := ""
+	if retried.LastPaymentError != nil {
+		failure = retried.LastPaymentError.Message
+	}
+	return retried, failure, nil
+}
+
+// cancelAfterDunning cancels an order whose final payment retry failed, which releases its
+// reserved stock and any store credit, and cancels its PaymentIntent so it can't be paid late
+func cancelAfterDunning(ctx context.Context, order *models.Order, attempts int) error {
+	_, err := services.TransitionOrder(ctx, order.ID, services.OrderStatusCancelled, nil,
+		fmt.Sprintf("Payment failed after %d retries", attempts))
+	if errors.Is(err, services.ErrInvalidTransition) || errors.Is(err, services.ErrTransitionConflict) {
+		// Paid concurrently (which already ended the retries) or cancelled by the buyer
+		return closeDunning(ctx, order.ID, models.DunningCancelled)
+	} else if err != nil {
+		return err
+	}
+
+	cancelOpenPaymentIntents(ctx, order.ID)
+	if err := closeDunning(ctx, order.ID, models.DunningCancelled); err != nil {
+		return err
+	}
+
+	notifications.Dispatch(notifications.Notification{
+		UserID: order.UserID,
+		Type:   notifications.TypePaymentFailed,
+		Title:  "Order cancelled",
+		Body:   "We couldn't collect payment for your order, so it has been cancelled and its items released.",
+		Data:   map[string]string{"order_id": order.ID},
+	})
+	return nil
+}
+
+// closeDunning ends an order's payment retries; orders without active retries are ignored
+func closeDunning(ctx context.Context, orderID, status string) error {
+	if err := database.CloseDunning(ctx, orderID, status); err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	return nil
+}
+
+// notifyPaymentFailed tells the buyer their payment failed, when it is retried next and
+// where to pay with another card
+func notifyPaymentFailed(order *models.Order, nextAttempt, finalAttempt time.Time) {
+	link, err := PaymentUpdateURL(order.ID, finalAttempt)
+	if err != nil {
+		log.Printf("Failed to sign payment update link for order %s: %v", order.ID, err)
+	}
+
+	notifications.Dispatch(notifications.Notification{
+		UserID: order.UserID,
+		Type:   notifications.TypePaymentFailed,
+		Title:  "Payment failed",
+		Body: fmt.Sprintf("We couldn't charge your payment method for your order. We'll try again on %s; "+
+			"update your payment method to keep the order.", nextAttempt.UTC().Format("Jan 2 15:04 MST")),
+		Data: map[string]string{
+			"order_id":           order.ID,
+			"next_attempt_at":    nextAttempt.UTC().Format(time.RFC3339),
+			"payment_update_url": link,
+		},
+	})
+}
+
+// PaymentUpdate returns the retries and the PaymentIntent of an order whose payment failed, so
+// the buyer can pay it with another card before the final retry
+func PaymentUpdate(ctx context.Context, orderID string) (*models.PaymentDunning, *PaymentIntent, error) {
+	if stripeClient == nil {
+		return nil, nil, ErrNotConfigured
+	}
+
+	dunning, err := database.GetPaymentDunning(ctx, orderID)
+	if err == sql.ErrNoRows {
+		return nil, nil, ErrPaymentNotFound
+	} else if err != nil {
+		return nil, nil, err
+	}
+	if dunning.Status != models.DunningActive {
+		return nil, nil, ErrOrderNotPayable
+	}
+
+	intent, err := stripeClient.GetPaymentIntent(ctx, dunning.ProviderPaymentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dunning, intent, nil
+}
@@ -0,0 +1,19 @@
+package payments
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryDelays(t *testing.T) {
+	t.Setenv("PAYMENT_RETRY_DELAYS", "")
+	assert.Equal(t, defaultRetryDelays, RetryDelays())
+
+	t.Setenv("PAYMENT_RETRY_DELAYS", "30m, 2h,1d")
+	assert.Equal(t, defaultRetryDelays, RetryDelays(), "invalid durations fall back to the default")
+
+	t.Setenv("PAYMENT_RETRY_DELAYS", "30m, 2h,48h")
+	assert.Equal(t, []time.Duration{30 * time.Minute, 2 * time.Hour, 48 * time.Hour}, RetryDelays())
+}
@@ -0,0 +1,201 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/money"
+	"secure-backend/services"
+	"strings"
+)
+
+// ProviderStripe identifies Stripe payment records
+const ProviderStripe = "stripe"
+
+// Stripe PaymentIntent statuses
+const (
+	intentSucceeded = "succeeded" // the money was collected
+	intentCanceled  = "canceled"
+)
+
+var (
+	// ErrNotConfigured is returned when no payment provider credentials are set
+	ErrNotConfigured = errors.New("payments are not configured")
+	// ErrOrderNotPayable is returned when the order is not awaiting payment
+	ErrOrderNotPayable = errors.New("order is not awaiting payment")
+	// ErrPaymentNotFound is returned when the order has no payment to confirm
+	ErrPaymentNotFound = errors.New("payment not found")
+	// ErrPaymentIncomplete is returned when the provider has not confirmed the payment yet
+	ErrPaymentIncomplete = errors.New("payment has not been completed")
+)
+
+// stripeClient is nil when STRIPE_SECRET_KEY is not set
+var stripeClient *StripeClient
+
+// Init configures the payment provider from the environment
+func Init() {
+	if key := os.Getenv("STRIPE_SECRET_KEY"); key != "" {
+		stripeClient = NewStripeClient(key)
+	}
+}
+
+// Enabled reports whether a payment provider is configured
+func Enabled() bool {
+	return stripeClient != nil
+}
+
+// Currency returns the ISO currency used for charges (STRIPE_CURRENCY, default usd)
+func Currency() string {
+	if currency := os.Getenv("STRIPE_CURRENCY"); currency != "" {
+		return strings.ToLower(currency)
+	}
+	return "usd"
+}
+
+// CreatePaymentIntent creates (or returns the existing) Stripe PaymentIntent for a buyer's pending order
+// and records it as a payment linked to the order. Store credit already applied to the order is
+// deducted, so the card is only charged for the rest.
+func CreatePaymentIntent(ctx context.Context, order *models.Order) (*PaymentIntent, *models.Payment, error) {
+	if stripeClient == nil {
+		return nil, nil, ErrNotConfigured
+	}
+	if order.Status != services.OrderStatusPending {
+		return nil, nil, ErrOrderNotPayable
+	}
+
+	applied, err := database.GetAppliedStoreCredit(ctx, order.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	due := order.TotalAmount - applied
+	if due <= 0 {
+		return nil, nil, ErrOrderNotPayable
+	}
+
+	// The amount is part of the idempotency key: it only changes if credit was applied in between
+	intent, err := stripeClient.CreatePaymentIntent(ctx, due.Cents(), Currency(),
+		map[string]string{"order_id": order.ID, "buyer_id": order.UserID},
+		fmt.Sprintf("order-%s-payment-intent-%d", order.ID, due.Cents()))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	payment := &models.Payment{
+		OrderID:           order.ID,
+		Provider:          ProviderStripe,
+		ProviderPaymentID: intent.ID,
+		Amount:            due,
+		Currency:          intent.Currency,
+		Status:            intent.Status,
+	}
+	if err := database.CreatePayment(ctx, payment); err != nil {
+		return nil, nil, err
+	}
+
+	return intent, payment, nil
+}
+
+// ConfirmOrderPayment checks the order's PaymentIntent with Stripe and marks the order as paid
+// only if the provider reports the payment succeeded
+func ConfirmOrderPayment(ctx context.Context, order *models.Order) (*models.Payment, error) {
+	if stripeClient == nil {
+		return nil, ErrNotConfigured
+	}
+
+	payment, err := latestCardPayment(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	intent, err := stripeClient.GetPaymentIntent(ctx, payment.ProviderPaymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if intent.Status != payment.Status {
+		if err := database.UpdatePaymentStatus(ctx, payment.ID, intent.Status); err != nil {
+			return nil, err
+		}
+		payment.Status = intent.Status
+	}
+
+	if intent.Status != intentSucceeded {
+		return payment, ErrPaymentIncomplete
+	}
+
+	if err := markOrderPaid(ctx, order.ID, "Payment "+intent.ID+" succeeded"); err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// latestCardPayment returns the order's most recent Stripe payment
+func latestCardPayment(ctx context.Context, orderID string) (*models.Payment, error) {
+	payments, err := database.GetPaymentsByOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	for _, payment := range payments {
+		if payment.Provider == ProviderStripe {
+			return &payment, nil
+		}
+	}
+	return nil, ErrPaymentNotFound
+}
+
+// ApplyStoreCredit pays up to amount of a buyer's pending order from their store credit
+// (as much as possible if amount is zero). Once credit covers the whole total the order is
+// marked paid; otherwise the rest is paid by card with CreatePaymentIntent.
+func ApplyStoreCredit(ctx context.Context, order *models.Order, amount money.Amount) (*models.Payment, bool, error) {
+	if order.Status != services.OrderStatusPending {
+		return nil, false, ErrOrderNotPayable
+	}
+
+	payment, covered, err := database.ApplyStoreCredit(ctx, order.ID, order.UserID, amount, Currency())
+	if errors.Is(err, database.ErrOrderNotAwaitingPayment) {
+		return nil, false, ErrOrderNotPayable
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	if covered {
+		if err := markOrderPaid(ctx, order.ID, "Paid with store credit"); err != nil {
+			return nil, false, err
+		}
+	}
+	return payment, covered, nil
+}
+
+// markOrderPaid transitions an order to paid and stops any retries of a failed payment.
+// An order that already moved past payment is treated as success; a cancelled order cannot be paid.
+func markOrderPaid(ctx context.Context, orderID, note string) error {
+	_, err := services.TransitionOrder(ctx, orderID, services.OrderStatusPaid, nil, note)
+	if err == nil {
+		if err := closeDunning(ctx, orderID, models.DunningRecovered); err != nil {
+			log.Printf("Failed to close payment retries of order %s: %v", orderID, err)
+		}
+		return nil
+	}
+
+	if errors.Is(err, services.ErrInvalidTransition) || errors.Is(err, services.ErrTransitionConflict) {
+		current, getErr := database.GetOrderByID(ctx, orderID)
+		if getErr != nil {
+			return getErr
+		}
+		switch current.Status {
+		case services.OrderStatusCancelled:
+			return ErrOrderNotPayable
+		case services.OrderStatusPending:
+			return err
+		default:
+			return nil
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,252 @@
+package payments
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/money"
+	"secure-backend/notifications"
+	"sort"
+	"time"
+)
+
+// reconciliationLookback is how far back payouts are checked. Payouts that reconciled or that an
+// admin resolved are not checked again.
+const reconciliationLookback = 14 * 24 * time.Hour
+
+// Stripe payout statuses of payouts that never moved money
+const (
+	payoutFailed   = "failed"
+	payoutCanceled = "canceled"
+)
+
+// StartPayoutReconciler periodically reconciles recent Stripe payouts until ctx is cancelled.
+// It does nothing when Stripe is not configured.
+func StartPayoutReconciler(ctx context.Context, interval time.Duration) {
+	if stripeClient == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				checked, blocked, err := ReconcilePayouts(ctx)
+				if err != nil {
+					log.Printf("Failed to reconcile payouts: %v", err)
+				} else if blocked > 0 {
+					log.Printf("Checked %d payouts; %d don't reconcile and were blocked", checked, blocked)
+				}
+			}
+		}
+	}()
+}
+
+// ReconcilePayouts checks the balance transactions of recent Stripe payouts against the shop's
+// payments and refunds. A payout that doesn't reconcile is blocked: the seller payouts of its
+// orders are held and admins are notified. Blocked payouts are checked again on every run and
+// released once they reconcile. It returns how many payouts were checked and newly blocked.
+func ReconcilePayouts(ctx context.Context) (checked, blocked int, err error) {
+	if stripeClient == nil {
+		return 0, 0, ErrNotConfigured
+	}
+
+	payouts, err := stripeClient.ListPayouts(ctx, clk.Now().Add(-reconciliationLookback))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, payout := range payouts {
+		// Manual payouts can't be broken down into balance transactions
+		if !payout.Automatic || payout.Status == payoutFailed || payout.Status == payoutCanceled {
+			continue
+		}
+		status, err := database.GetPayoutReconciliationStatus(ctx, ProviderStripe, payout.ID)
+		if err != nil && err != sql.ErrNoRows {
+			return checked, blocked, err
+		}
+		if status == models.ReconciliationReconciled || status == models.ReconciliationResolved {
+			continue
+		}
+
+		newlyBlocked, err := reconcilePayout(ctx, payout)
+		if err != nil {
+			return checked, blocked, fmt.Errorf("reconciling payout %s: %w", payout.ID, err)
+		}
+		checked++
+		if newlyBlocked {
+			blocked++
+		}
+	}
+	return checked, blocked, nil
+}
+
+// reconcilePayout checks one payout and records the result
+func reconcilePayout(ctx context.Context, payout StripePayout) (bool, error) {
+	txns, err := stripeClient.ListPayoutTransactions(ctx, payout.ID)
+	if err != nil {
+		return false, err
+	}
+
+	var intentIDs, refundIDs []string
+	for _, txn := range txns {
+		switch txn.Source.Object {
+		case "charge":
+			intentIDs = append(intentIDs, txn.Source.PaymentIntent)
+		case "refund":
+			refundIDs = append(refundIDs, txn.Source.ID)
+		}
+	}
+	payments, err := database.GetPaymentsByProviderIDs(ctx, ProviderStripe, intentIDs)
+	if err != nil {
+		return false, err
+	}
+	refunds, err := database.GetRefundAllocationsByProviderIDs(ctx, refundIDs)
+	if err != nil {
+		return false, err
+	}
+
+	mismatches, orderIDs := reconcileTransactions(payout, txns, payments, refunds)
+	fees := map[string]money.Amount{}
+	for _, txn := range txns {
+		if payment, ok := payments[txn.Source.PaymentIntent]; ok && txn.Source.Object == "charge" {
+			fees[payment.ID] = money.Amount(txn.Fee)
+		}
+	}
+	rec := &models.PayoutReconciliation{
+		Provider:         ProviderStripe,
+		ProviderPayoutID: payout.ID,
+		Amount:           money.Amount(payout.Amount),
+		Currency:         payout.Currency,
+		Status:           models.ReconciliationReconciled,
+		TransactionCount: len(txns),
+		CheckedAt:        clk.Now(),
+	}
+	if payout.ArrivalDate > 0 {
+		arrival := time.Unix(payout.ArrivalDate, 0).UTC()
+		rec.ArrivalDate = &arrival
+	}
+	if len(mismatches) > 0 {
+		rec.Status = models.ReconciliationBlocked
+	}
+
+	blocked, err := database.RecordPayoutReconciliation(ctx, rec, mismatches, orderIDs, fees)
+	if err != nil {
+		return false, err
+	}
+	if blocked {
+		notifyAdminsOfBlockedPayout(ctx, rec)
+	}
+	return blocked, nil
+}
+
+// reconcileTransactions matches a payout's balance transactions with the shop's payments (keyed
+// by PaymentIntent ID) and refund allocations (keyed by Stripe refund ID). It returns the
+// mismatches and the IDs of the orders the payout paid out, sorted.
+func reconcileTransactions(payout StripePayout, txns []BalanceTransaction, payments map[string]models.Payment, refunds map[string]models.RefundAllocation) ([]models.ReconciliationMismatch, []string) {
+	var mismatches []models.ReconciliationMismatch
+	orders := map[string]bool{}
+	add := func(txn BalanceTransaction, kind, orderID string, expected, actual *money.Amount, detail string) {
+		mismatch := models.ReconciliationMismatch{
+			BalanceTransactionID: txn.ID,
+			Kind:                 kind,
+			Expected:             expected,
+			Actual:               actual,
+			Detail:               detail,
+		}
+		if orderID != "" {
+			mismatch.OrderID = &orderID
+		}
+		mismatches = append(mismatches, mismatch)
+	}
+
+	var net int64
+	for _, txn := range txns {
+		if txn.Type == "payout" {
+			// The payout itself
+			continue
+		}
+		net += txn.Net
+		actual := amountPtr(txn.Source.Amount)
+
+		switch txn.Source.Object {
+		case "charge":
+			payment, ok := payments[txn.Source.PaymentIntent]
+			if !ok {
+				add(txn, models.MismatchUnknownPayment, "", nil, actual,
+					fmt.Sprintf("charge %s for payment intent %s", txn.Source.ID, txn.Source.PaymentIntent))
+				continue
+			}
+			orders[payment.OrderID] = true
+			if payment.Amount.Cents() != txn.Source.Amount {
+				add(txn, models.MismatchAmount, payment.OrderID, &payment.Amount, actual,
+					"charge "+txn.Source.ID+" differs from payment "+payment.ID)
+			}
+			if payment.Status != intentSucceeded && payment.Status != paymentRefunded && payment.Status != paymentPartiallyRefunded {
+				add(txn, models.MismatchStatus, payment.OrderID, nil, nil,
+					"payment "+payment.ID+" is "+payment.Status)
+			}
+
+		case "refund":
+			allocation, ok := refunds[txn.Source.ID]
+			if !ok {
+				add(txn, models.MismatchUnknownRefund, "", nil, actual,
+					fmt.Sprintf("refund %s of payment intent %s", txn.Source.ID, txn.Source.PaymentIntent))
+				continue
+			}
+			orders[allocation.OrderID] = true
+			if allocation.Amount.Cents() != txn.Source.Amount {
+				add(txn, models.MismatchAmount, allocation.OrderID, &allocation.Amount, actual,
+					"refund "+txn.Source.ID+" differs from refund "+allocation.RefundID)
+			}
+		}
+	}
+
+	if net != payout.Amount {
+		add(BalanceTransaction{ID: payout.ID}, models.MismatchTotal, "", amountPtr(payout.Amount), amountPtr(net),
+			"balance transactions don't add up to the payout")
+	}
+
+	orderIDs := make([]string, 0, len(orders))
+	for id := range orders {
+		orderIDs = append(orderIDs, id)
+	}
+	sort.Strings(orderIDs)
+	return mismatches, orderIDs
+}
+
+// amountPtr converts the provider's minor units into an amount
+func amountPtr(minor int64) *money.Amount {
+	amount := money.Amount(minor)
+	return &amount
+}
+
+// notifyAdminsOfBlockedPayout tells admins that a payout doesn't reconcile. Failures are logged;
+// the result is already saved.
+func notifyAdminsOfBlockedPayout(ctx context.Context, rec *models.PayoutReconciliation) {
+	adminIDs, err := database.GetAdminIDs(ctx)
+	if err != nil {
+		log.Printf("Failed to notify admins of blocked payout %s: %v", rec.ProviderPayoutID, err)
+		return
+	}
+
+	for _, adminID := range adminIDs {
+		notifications.Dispatch(notifications.Notification{
+			UserID: adminID,
+			Type:   notifications.TypeReconciliation,
+			Title:  "Payout blocked",
+			Body: fmt.Sprintf("Payout %s of %s %s doesn't match the shop's records (%d mismatches). "+
+				"Seller payouts of its orders are on hold until it is resolved.",
+				rec.ProviderPayoutID, rec.Amount, rec.Currency, len(rec.Mismatches)),
+			Data: map[string]string{"reconciliation_id": rec.ID, "payout_id": rec.ProviderPayoutID},
+		})
+	}
+}
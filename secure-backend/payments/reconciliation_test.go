@@ -0,0 +1,62 @@
+package payments
+
+import (
+	"encoding/json"
+	"secure-backend/models"
+	"secure-backend/money"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconcileTransactions(t *testing.T) {
+	payments := map[string]models.Payment{
+		"pi_1": {ID: "pay-1", OrderID: "order-1", Amount: 5000, Status: intentSucceeded},
+		"pi_2": {ID: "pay-2", OrderID: "order-2", Amount: 2000, Status: paymentPartiallyRefunded},
+	}
+	refunds := map[string]models.RefundAllocation{
+		"re_1": {RefundID: "refund-1", OrderID: "order-2", Amount: 500},
+	}
+	txns := []BalanceTransaction{
+		{ID: "txn_1", Type: "charge", Net: 4825, Source: BalanceSource{ID: "ch_1", Object: "charge", PaymentIntent: "pi_1", Amount: 5000}},
+		{ID: "txn_2", Type: "charge", Net: 1912, Source: BalanceSource{ID: "ch_2", Object: "charge", PaymentIntent: "pi_2", Amount: 2000}},
+		{ID: "txn_3", Type: "refund", Net: -500, Source: BalanceSource{ID: "re_1", Object: "refund", PaymentIntent: "pi_2", Amount: 500}},
+		{ID: "txn_4", Type: "stripe_fee", Net: -100, Source: BalanceSource{ID: "fee_1"}},
+		{ID: "txn_5", Type: "payout", Net: -6137, Source: BalanceSource{ID: "po_1", Object: "payout"}},
+	}
+	payout := StripePayout{ID: "po_1", Amount: 6137}
+
+	mismatches, orderIDs := reconcileTransactions(payout, txns, payments, refunds)
+	assert.Empty(t, mismatches)
+	assert.Equal(t, []string{"order-1", "order-2"}, orderIDs)
+
+	// A charge the shop doesn't know, a refund of the wrong amount and a payment never marked paid
+	payments["pi_1"] = models.Payment{ID: "pay-1", OrderID: "order-1", Amount: 5000, Status: "requires_payment_method"}
+	refunds["re_1"] = models.RefundAllocation{RefundID: "refund-1", OrderID: "order-2", Amount: 400}
+	txns = append(txns, BalanceTransaction{ID: "txn_6", Type: "charge", Net: 975,
+		Source: BalanceSource{ID: "ch_3", Object: "charge", PaymentIntent: "pi_unknown", Amount: 1000}})
+
+	mismatches, orderIDs = reconcileTransactions(payout, txns, payments, refunds)
+	kinds := []string{}
+	for _, m := range mismatches {
+		kinds = append(kinds, m.Kind+" "+m.BalanceTransactionID)
+	}
+	assert.Equal(t, []string{
+		"status_mismatch txn_1",
+		"amount_mismatch txn_3",
+		"unknown_payment txn_6",
+		"total_mismatch po_1",
+	}, kinds)
+	assert.Equal(t, money.Amount(400), *mismatches[1].Expected)
+	assert.Equal(t, money.Amount(500), *mismatches[1].Actual)
+	assert.Equal(t, []string{"order-1", "order-2"}, orderIDs)
+}
+
+func TestBalanceSourceAcceptsBareID(t *testing.T) {
+	var txn BalanceTransaction
+	assert.NoError(t, json.Unmarshal([]byte(`{"id":"txn_1","source":"ch_1"}`), &txn))
+	assert.Equal(t, "ch_1", txn.Source.ID)
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"id":"txn_2","source":{"id":"re_1","object":"refund","amount":500}}`), &txn))
+	assert.Equal(t, BalanceSource{ID: "re_1", Object: "refund", Amount: 500}, txn.Source)
+}
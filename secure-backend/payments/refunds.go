@@ -0,0 +1,110 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"log"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/services"
+)
+
+// Payment statuses recorded after refunds
+const (
+	paymentRefunded          = "refunded"
+	paymentPartiallyRefunded = "partially_refunded"
+)
+
+var (
+	// ErrOrderNotRefundable is returned when the order was never paid or is already refunded
+	ErrOrderNotRefundable = errors.New("order cannot be refunded")
+	// ErrAlreadyRestocked is returned when asking to restock the items of a cancelled order
+	ErrAlreadyRestocked = errors.New("cancelled orders were already restocked")
+)
+
+// RefundOrder records a refund, issues it with the provider and settles the order:
+// refunded items are restocked if requested and a fully refunded order moves to refunded.
+// When the order was paid with store credit and a card, the refund is split between them;
+// the card share is refunded through Stripe and the store credit share credited back.
+// The refund is recorded before calling the provider so a crash can't refund twice. Orders
+// with an open dispute can't be refunded, which would pay the buyer back twice.
+func RefundOrder(ctx context.Context, order *models.Order, req database.RefundRequest, actor *models.AuthUser) (*models.Refund, error) {
+	// Cancelled orders may still hold a captured payment (cancelled after payment)
+	switch order.Status {
+	case services.OrderStatusPaid, services.OrderStatusShipped, services.OrderStatusDelivered, services.OrderStatusCancelled:
+	default:
+		return nil, ErrOrderNotRefundable
+	}
+	// Cancelling released the order's stock; restocking again would count the units twice
+	if order.Status == services.OrderStatusCancelled && req.Restock {
+		return nil, ErrAlreadyRestocked
+	}
+
+	if err := services.EnsurePeriodOpen(ctx, order, actor); err != nil {
+		return nil, err
+	}
+
+	disputed, err := database.HasOpenDispute(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	if disputed {
+		return nil, ErrOrderDisputed
+	}
+
+	req.OrderID = order.ID
+	req.ActorID = actor.ID
+	req.ActorRole = actor.Role
+
+	refund, err := database.CreateRefund(ctx, req)
+	if errors.Is(err, database.ErrNoCapturedPayment) {
+		return nil, ErrPaymentNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	for i := range refund.Allocations {
+		allocation := &refund.Allocations[i]
+		if allocation.Provider != ProviderStripe {
+			continue
+		}
+		providerRefundID, err := refundCard(ctx, order.ID, refund.ID, allocation)
+		if err != nil {
+			if failErr := database.FailRefund(ctx, refund.ID); failErr != nil {
+				log.Printf("Failed to mark refund %s as failed: %v", refund.ID, failErr)
+			}
+			return nil, err
+		}
+		allocation.ProviderRefundID = providerRefundID
+		refund.ProviderRefundID = providerRefundID
+	}
+
+	fullyRefunded, err := database.CompleteRefund(ctx, refund)
+	if err != nil {
+		return nil, err
+	}
+
+	if fullyRefunded && order.Status != services.OrderStatusCancelled {
+		_, err := services.TransitionOrder(ctx, order.ID, services.OrderStatusRefunded, actor, req.Reason)
+		if err != nil && !errors.Is(err, services.ErrInvalidTransition) {
+			log.Printf("Failed to mark order %s refunded: %v", order.ID, err)
+		}
+	}
+
+	return refund, nil
+}
+
+// refundCard refunds a card payment's share of a refund through Stripe and returns the provider refund ID
+func refundCard(ctx context.Context, orderID, refundID string, allocation *models.RefundAllocation) (string, error) {
+	if stripeClient == nil {
+		return "", ErrNotConfigured
+	}
+
+	providerRefund, err := stripeClient.CreateRefund(ctx, allocation.ProviderPaymentID, allocation.Amount.Cents(),
+		map[string]string{"order_id": orderID, "refund_id": refundID},
+		"refund-"+refundID+"-"+allocation.PaymentID)
+	if err != nil {
+		return "", err
+	}
+	return providerRefund.ID, nil
+}
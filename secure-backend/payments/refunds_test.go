@@ -0,0 +1,189 @@
+//go:build e2e
+
+// Refund tests against a real PostgreSQL database (with database/schema.sql applied) and a
+// fake Stripe API:
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run TestRefundOrder ./payments
+package payments
+
+import (
+	"context"
+	"testing"
+
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/money"
+	"secure-backend/services"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// orderItemID returns the ID of the order's only item
+func orderItemID(t *testing.T, order *models.Order) string {
+	t.Helper()
+	var id string
+	require.NoError(t, database.DB.GetContext(context.Background(), &id, `SELECT id FROM order_items WHERE order_id = $1`, order.ID))
+	return id
+}
+
+func TestRefundOrderPartial(t *testing.T) {
+	f := newOrderFixture(t, 5)
+	ctx := context.Background()
+
+	order := f.pay(t, f.checkout(t, 2))
+
+	refund, err := RefundOrder(ctx, order, database.RefundRequest{Amount: money.FromFloat(3)}, f.admin)
+	require.NoError(t, err)
+	assert.Equal(t, money.FromFloat(3), refund.Amount)
+	assert.Equal(t, database.RefundSucceeded, refund.Status)
+	assert.Empty(t, refund.Items, "a plain amount returns no items")
+	assert.Equal(t, services.OrderStatusPaid, f.order(t, order.ID).Status)
+	payments, err := database.GetPaymentsByOrder(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, paymentPartiallyRefunded, payments[0].Status)
+
+	// Only the 7.00 left can be refunded
+	_, err = RefundOrder(ctx, order, database.RefundRequest{Amount: money.FromFloat(8)}, f.admin)
+	assert.ErrorIs(t, err, database.ErrRefundExceedsPaid)
+
+	// A refund the provider refused is failed and its amount refundable again
+	f.setFailRefunds(true)
+	_, err = RefundOrder(ctx, order, database.RefundRequest{Amount: money.FromFloat(2)}, f.admin)
+	require.Error(t, err)
+	f.setFailRefunds(false)
+	refunds, err := database.GetRefundsByOrder(ctx, order.ID)
+	require.NoError(t, err)
+	require.Len(t, refunds, 2)
+	assert.Equal(t, database.RefundFailed, refunds[1].Status)
+
+	// Refunding the rest refunds the order
+	refund, err = RefundOrder(ctx, order, database.RefundRequest{}, f.admin)
+	require.NoError(t, err)
+	assert.Equal(t, money.FromFloat(7), refund.Amount)
+	assert.Equal(t, 2, f.refunds())
+	assert.Equal(t, services.OrderStatusRefunded, f.order(t, order.ID).Status)
+	payments, err = database.GetPaymentsByOrder(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, paymentRefunded, payments[0].Status)
+
+	_, err = RefundOrder(ctx, f.order(t, order.ID), database.RefundRequest{}, f.admin)
+	assert.ErrorIs(t, err, ErrOrderNotRefundable)
+}
+
+func TestRefundOrderItems(t *testing.T) {
+	f := newOrderFixture(t, 5)
+	ctx := context.Background()
+
+	order := f.pay(t, f.checkout(t, 3))
+	itemID := orderItemID(t, order)
+	assert.Equal(t, 2, f.stock(t))
+
+	refund, err := RefundOrder(ctx, order, database.RefundRequest{
+		Items:   []database.RefundItemRequest{{OrderItemID: itemID, Quantity: 1}},
+		Restock: true,
+	}, f.admin)
+	require.NoError(t, err)
+	assert.Equal(t, money.FromFloat(5), refund.Amount, "items are refunded at their price")
+	require.Len(t, refund.Items, 1)
+	assert.Equal(t, 1, refund.Items[0].Quantity)
+	assert.Equal(t, 3, f.stock(t), "the refunded unit is back in stock")
+
+	// Units can't be refunded twice, and only the order's own items can be refunded
+	for name, items := range map[string][]database.RefundItemRequest{
+		"more than left": {{OrderItemID: itemID, Quantity: 3}},
+		"zero":           {{OrderItemID: itemID, Quantity: 0}},
+		"listed twice":   {{OrderItemID: itemID, Quantity: 1}, {OrderItemID: itemID, Quantity: 1}},
+		"unknown":        {{OrderItemID: uuid.NewString(), Quantity: 1}},
+	} {
+		_, err := RefundOrder(ctx, order, database.RefundRequest{Items: items}, f.admin)
+		assert.ErrorIs(t, err, database.ErrInvalidRefundItem, name)
+	}
+
+	// Without restocking, the refunded units stay out of stock
+	refund, err = RefundOrder(ctx, order, database.RefundRequest{
+		Items: []database.RefundItemRequest{{OrderItemID: itemID, Quantity: 2}},
+	}, f.admin)
+	require.NoError(t, err)
+	assert.Equal(t, money.FromFloat(10), refund.Amount)
+	assert.Equal(t, 3, f.stock(t))
+	assert.Equal(t, services.OrderStatusRefunded, f.order(t, order.ID).Status)
+
+	_, err = RefundOrder(ctx, order, database.RefundRequest{Amount: money.FromFloat(1)}, f.admin)
+	assert.ErrorIs(t, err, database.ErrRefundExceedsPaid)
+}
+
+func TestRefundOrderSplitPayment(t *testing.T) {
+	f := newOrderFixture(t, 5)
+	ctx := context.Background()
+
+	// The buyer pays 4.00 of a 10.00 order from store credit and the rest by card
+	_, err := database.DB.ExecContext(ctx, `
+		INSERT INTO store_credit_entries (user_id, amount, reason) VALUES ($1, 4, $2)
+	`, f.buyer.ID, models.StoreCreditGiftCard)
+	require.NoError(t, err)
+	order := f.checkout(t, 2)
+	_, covered, err := database.ApplyStoreCredit(ctx, order.ID, f.buyer.ID, 0, "usd")
+	require.NoError(t, err)
+	require.False(t, covered)
+	order = f.pay(t, order)
+
+	balance := func() money.Amount {
+		t.Helper()
+		balance, err := database.GetStoreCreditBalance(ctx, f.buyer.ID)
+		require.NoError(t, err)
+		return balance
+	}
+	require.Zero(t, balance())
+
+	// Half the order is refunded from each payment in proportion, store credit first
+	refund, err := RefundOrder(ctx, order, database.RefundRequest{Amount: money.FromFloat(5)}, f.admin)
+	require.NoError(t, err)
+	require.Len(t, refund.Allocations, 2)
+	assert.Equal(t, models.PaymentProviderStoreCredit, refund.Allocations[0].Provider)
+	assert.Equal(t, money.FromFloat(2), refund.Allocations[0].Amount)
+	assert.Equal(t, ProviderStripe, refund.Allocations[1].Provider)
+	assert.Equal(t, money.FromFloat(3), refund.Allocations[1].Amount)
+	assert.NotEmpty(t, refund.Allocations[1].ProviderRefundID)
+	assert.Equal(t, money.FromFloat(2), balance(), "the store credit share is credited back")
+	assert.Equal(t, 1, f.refunds(), "only the card share goes through Stripe")
+
+	// The rest empties both payments
+	refund, err = RefundOrder(ctx, order, database.RefundRequest{}, f.admin)
+	require.NoError(t, err)
+	assert.Equal(t, money.FromFloat(5), refund.Amount)
+	assert.Equal(t, money.FromFloat(4), balance())
+	assert.Equal(t, 2, f.refunds())
+	assert.Equal(t, services.OrderStatusRefunded, f.order(t, order.ID).Status)
+	payments, err := database.GetPaymentsByOrder(ctx, order.ID)
+	require.NoError(t, err)
+	require.Len(t, payments, 2)
+	for _, payment := range payments {
+		assert.Equal(t, paymentRefunded, payment.Status, payment.Provider)
+	}
+}
+
+func TestRefundOrderDisputed(t *testing.T) {
+	f := newOrderFixture(t, 5)
+	ctx := context.Background()
+
+	order := f.pay(t, f.checkout(t, 2))
+	f.dispute(t, order)
+
+	_, err := RefundOrder(ctx, order, database.RefundRequest{Amount: money.FromFloat(3)}, f.admin)
+	assert.ErrorIs(t, err, ErrOrderDisputed)
+	refunds, err := database.GetRefundsByOrder(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Empty(t, refunds, "nothing is recorded")
+	assert.Zero(t, f.refunds())
+	assert.Equal(t, services.OrderStatusPaid, f.order(t, order.ID).Status)
+}
+
+func TestRefundOrderUnpaid(t *testing.T) {
+	f := newOrderFixture(t, 5)
+
+	_, err := RefundOrder(context.Background(), f.checkout(t, 2), database.RefundRequest{}, f.admin)
+	assert.ErrorIs(t, err, ErrOrderNotRefundable)
+	assert.Zero(t, f.refunds())
+}
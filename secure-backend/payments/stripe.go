@@ -0,0 +1,303 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"secure-backend/outbound"
+	"strings"
+	"time"
+)
+
+const stripeAPIBase = "https://api.stripe.com/v1"
+
+// PaymentIntent is the subset of a Stripe PaymentIntent used by the shop
+type PaymentIntent struct {
+	ID           string            `json:"id"`
+	Amount       int64             `json:"amount"`
+	Currency     string            `json:"currency"`
+	Status       string            `json:"status"`
+	ClientSecret string            `json:"client_secret"`
+	Metadata     map[string]string `json:"metadata"`
+	// LastPaymentError explains the latest failed attempt; it is cleared when a payment succeeds
+	LastPaymentError *PaymentError `json:"last_payment_error"`
+}
+
+// PaymentError is the reason a PaymentIntent's last attempt failed, with the payment method that was declined
+type PaymentError struct {
+	Code          string `json:"code"`
+	DeclineCode   string `json:"decline_code"`
+	Message       string `json:"message"`
+	PaymentMethod *struct {
+		ID string `json:"id"`
+	} `json:"payment_method"`
+}
+
+// StripeRefund is the subset of a Stripe Refund used by the shop
+type StripeRefund struct {
+	ID     string `json:"id"`
+	Amount int64  `json:"amount"`
+	Status string `json:"status"`
+}
+
+// StripeDispute is the subset of a Stripe Dispute used by the shop
+type StripeDispute struct {
+	ID              string `json:"id"`
+	Amount          int64  `json:"amount"`
+	Currency        string `json:"currency"`
+	PaymentIntent   string `json:"payment_intent"`
+	Reason          string `json:"reason"`
+	Status          string `json:"status"`
+	EvidenceDetails struct {
+		DueBy int64 `json:"due_by"` // unix time, 0 if no evidence can be submitted
+	} `json:"evidence_details"`
+}
+
+// StripePayout is the subset of a Stripe Payout used by the shop
+type StripePayout struct {
+	ID          string `json:"id"`
+	Amount      int64  `json:"amount"`
+	Currency    string `json:"currency"`
+	ArrivalDate int64  `json:"arrival_date"`
+	Status      string `json:"status"` // paid, pending, in_transit, canceled or failed
+	Automatic   bool   `json:"automatic"`
+}
+
+// BalanceTransaction is the subset of a Stripe balance transaction used by the shop. Amount is
+// in the balance currency; Net is Amount less Fee.
+type BalanceTransaction struct {
+	ID       string        `json:"id"`
+	Amount   int64         `json:"amount"`
+	Fee      int64         `json:"fee"`
+	Net      int64         `json:"net"`
+	Currency string        `json:"currency"`
+	Type     string        `json:"type"` // charge, payment, refund, payment_refund, payout, adjustment, ...
+	Source   BalanceSource `json:"source"`
+}
+
+// BalanceSource is the charge, refund or other object that moved money in a balance transaction.
+// Only the ID is known unless the source was expanded.
+type BalanceSource struct {
+	ID            string `json:"id"`
+	Object        string `json:"object"` // charge, refund, payout, dispute, ...
+	PaymentIntent string `json:"payment_intent"`
+	Amount        int64  `json:"amount"` // in the currency of the charge or refund
+}
+
+// UnmarshalJSON accepts both an expanded source object and a bare source ID
+func (b *BalanceSource) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &b.ID)
+	}
+	type source BalanceSource
+	return json.Unmarshal(data, (*source)(b))
+}
+
+// StripeError is an error response from the Stripe API
+type StripeError struct {
+	StatusCode int
+	Type       string `json:"type"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+// Error implements the error interface
+func (e *StripeError) Error() string {
+	return fmt.Sprintf("stripe error %d (%s): %s", e.StatusCode, e.Type, e.Message)
+}
+
+// StripeClient is a minimal Stripe REST API client
+type StripeClient struct {
+	secretKey string
+	baseURL   string
+	client    *http.Client
+}
+
+// NewStripeClient creates a Stripe client authenticated with the given secret key
+func NewStripeClient(secretKey string) *StripeClient {
+	return &StripeClient{
+		secretKey: secretKey,
+		baseURL:   stripeAPIBase,
+		client:    outbound.NewClient("stripe", 15*time.Second),
+	}
+}
+
+// CreatePaymentIntent creates a PaymentIntent. The idempotency key makes retries return the same intent.
+func (s *StripeClient) CreatePaymentIntent(ctx context.Context, amount int64, currency string, metadata map[string]string, idempotencyKey string) (*PaymentIntent, error) {
+	form := url.Values{
+		"amount":                             {fmt.Sprintf("%d", amount)},
+		"currency":                           {currency},
+		"automatic_payment_methods[enabled]": {"true"},
+	}
+	for k, v := range metadata {
+		form.Set("metadata["+k+"]", v)
+	}
+
+	var intent PaymentIntent
+	err := s.do(ctx, http.MethodPost, "/payment_intents", form, idempotencyKey, &intent)
+	if err != nil {
+		return nil, err
+	}
+	return &intent, nil
+}
+
+// GetPaymentIntent retrieves a PaymentIntent by ID
+func (s *StripeClient) GetPaymentIntent(ctx context.Context, id string) (*PaymentIntent, error) {
+	var intent PaymentIntent
+	if err := s.do(ctx, http.MethodGet, "/payment_intents/"+url.PathEscape(id), nil, "", &intent); err != nil {
+		return nil, err
+	}
+	return &intent, nil
+}
+
+// CancelPaymentIntent cancels a PaymentIntent that has not been paid yet
+func (s *StripeClient) CancelPaymentIntent(ctx context.Context, id string) (*PaymentIntent, error) {
+	var intent PaymentIntent
+	err := s.do(ctx, http.MethodPost, "/payment_intents/"+url.PathEscape(id)+"/cancel", url.Values{}, "", &intent)
+	if err != nil {
+		return nil, err
+	}
+	return &intent, nil
+}
+
+// ConfirmPaymentIntent charges a PaymentIntent again with a saved payment method while the buyer
+// is away (off-session). The idempotency key makes retries of the same attempt charge once.
+func (s *StripeClient) ConfirmPaymentIntent(ctx context.Context, id, paymentMethodID, idempotencyKey string) (*PaymentIntent, error) {
+	form := url.Values{
+		"payment_method": {paymentMethodID},
+		"off_session":    {"true"},
+	}
+
+	var intent PaymentIntent
+	err := s.do(ctx, http.MethodPost, "/payment_intents/"+url.PathEscape(id)+"/confirm", form, idempotencyKey, &intent)
+	if err != nil {
+		return nil, err
+	}
+	return &intent, nil
+}
+
+// CreateRefund refunds part or all of a PaymentIntent. The idempotency key makes retries return the same refund.
+func (s *StripeClient) CreateRefund(ctx context.Context, paymentIntentID string, amount int64, metadata map[string]string, idempotencyKey string) (*StripeRefund, error) {
+	form := url.Values{
+		"payment_intent": {paymentIntentID},
+		"amount":         {fmt.Sprintf("%d", amount)},
+	}
+	for k, v := range metadata {
+		form.Set("metadata["+k+"]", v)
+	}
+
+	var refund StripeRefund
+	if err := s.do(ctx, http.MethodPost, "/refunds", form, idempotencyKey, &refund); err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// UpdateDispute adds text evidence to a dispute. With submit the evidence is sent to the card
+// issuer for review and can't be changed afterwards.
+func (s *StripeClient) UpdateDispute(ctx context.Context, id string, evidence map[string]string, submit bool) (*StripeDispute, error) {
+	form := url.Values{"submit": {fmt.Sprintf("%t", submit)}}
+	for k, v := range evidence {
+		form.Set("evidence["+k+"]", v)
+	}
+
+	var dispute StripeDispute
+	if err := s.do(ctx, http.MethodPost, "/disputes/"+url.PathEscape(id), form, "", &dispute); err != nil {
+		return nil, err
+	}
+	return &dispute, nil
+}
+
+// ListPayouts returns the payouts created since the given time, newest first
+func (s *StripeClient) ListPayouts(ctx context.Context, since time.Time) ([]StripePayout, error) {
+	query := url.Values{"created[gte]": {fmt.Sprintf("%d", since.Unix())}}
+	var payouts []StripePayout
+	err := s.list(ctx, "/payouts", query, func(data json.RawMessage) (string, error) {
+		var page []StripePayout
+		if err := json.Unmarshal(data, &page); err != nil || len(page) == 0 {
+			return "", err
+		}
+		payouts = append(payouts, page...)
+		return page[len(page)-1].ID, nil
+	})
+	return payouts, err
+}
+
+// ListPayoutTransactions returns the balance transactions paid out by an automatic payout,
+// with their sources expanded
+func (s *StripeClient) ListPayoutTransactions(ctx context.Context, payoutID string) ([]BalanceTransaction, error) {
+	query := url.Values{"payout": {payoutID}, "expand[]": {"data.source"}}
+	var txns []BalanceTransaction
+	err := s.list(ctx, "/balance_transactions", query, func(data json.RawMessage) (string, error) {
+		var page []BalanceTransaction
+		if err := json.Unmarshal(data, &page); err != nil || len(page) == 0 {
+			return "", err
+		}
+		txns = append(txns, page...)
+		return page[len(page)-1].ID, nil
+	})
+	return txns, err
+}
+
+// list pages through a Stripe list endpoint, passing each page's data to add, which returns
+// the ID of the page's last object
+func (s *StripeClient) list(ctx context.Context, path string, query url.Values, add func(data json.RawMessage) (string, error)) error {
+	query.Set("limit", "100")
+	for {
+		var page struct {
+			Data    json.RawMessage `json:"data"`
+			HasMore bool            `json:"has_more"`
+		}
+		if err := s.do(ctx, http.MethodGet, path+"?"+query.Encode(), nil, "", &page); err != nil {
+			return err
+		}
+		last, err := add(page.Data)
+		if err != nil {
+			return err
+		}
+		if !page.HasMore || last == "" {
+			return nil
+		}
+		query.Set("starting_after", last)
+	}
+}
+
+// do performs a form-encoded Stripe API request and decodes the JSON response into out
+func (s *StripeClient) do(ctx context.Context, method, path string, form url.Values, idempotencyKey string, out interface{}) error {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(s.secretKey, "")
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("calling stripe: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		var errResp struct {
+			Error StripeError `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&errResp)
+		errResp.Error.StatusCode = resp.StatusCode
+		return &errResp.Error
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,97 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"secure-backend/models"
+	"secure-backend/services"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStripeClient returns a client of a fake Stripe API served by handler
+func newTestStripeClient(t *testing.T, handler http.HandlerFunc) *StripeClient {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return &StripeClient{secretKey: "sk_test", baseURL: server.URL, client: server.Client()}
+}
+
+func TestStripeCreatePaymentIntent(t *testing.T) {
+	client := newTestStripeClient(t, func(w http.ResponseWriter, r *http.Request) {
+		user, _, _ := r.BasicAuth()
+		assert.Equal(t, "sk_test", user)
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/payment_intents", r.URL.Path)
+		assert.Equal(t, "order-1-payment-intent-2500", r.Header.Get("Idempotency-Key"))
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "2500", r.PostForm.Get("amount"))
+		assert.Equal(t, "usd", r.PostForm.Get("currency"))
+		assert.Equal(t, "true", r.PostForm.Get("automatic_payment_methods[enabled]"))
+		assert.Equal(t, "order-1", r.PostForm.Get("metadata[order_id]"))
+
+		w.Write([]byte(`{"id":"pi_1","amount":2500,"currency":"usd","status":"requires_payment_method","client_secret":"pi_1_secret"}`))
+	})
+
+	intent, err := client.CreatePaymentIntent(context.Background(), 2500, "usd", map[string]string{"order_id": "order-1"}, "order-1-payment-intent-2500")
+	require.NoError(t, err)
+	assert.Equal(t, &PaymentIntent{ID: "pi_1", Amount: 2500, Currency: "usd", Status: "requires_payment_method", ClientSecret: "pi_1_secret"}, intent)
+}
+
+func TestStripeGetPaymentIntentEscapesID(t *testing.T) {
+	client := newTestStripeClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/payment_intents/pi_1%2Fcancel", r.URL.EscapedPath())
+		w.Write([]byte(`{"id":"pi_1/cancel","status":"succeeded"}`))
+	})
+
+	intent, err := client.GetPaymentIntent(context.Background(), "pi_1/cancel")
+	require.NoError(t, err)
+	assert.Equal(t, intentSucceeded, intent.Status)
+}
+
+func TestStripeErrorResponse(t *testing.T) {
+	client := newTestStripeClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusPaymentRequired)
+		w.Write([]byte(`{"error":{"type":"card_error","code":"card_declined","message":"Your card was declined."}}`))
+	})
+
+	_, err := client.ConfirmPaymentIntent(context.Background(), "pi_1", "pm_1", "retry-1")
+	var stripeErr *StripeError
+	require.True(t, errors.As(err, &stripeErr), "got %v", err)
+	assert.Equal(t, http.StatusPaymentRequired, stripeErr.StatusCode)
+	assert.Equal(t, "card_declined", stripeErr.Code)
+	assert.Equal(t, "Your card was declined.", stripeErr.Message)
+
+	// An error without a body still reports the status
+	client = newTestStripeClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	_, err = client.GetPaymentIntent(context.Background(), "pi_1")
+	require.True(t, errors.As(err, &stripeErr), "got %v", err)
+	assert.Equal(t, http.StatusServiceUnavailable, stripeErr.StatusCode)
+}
+
+func TestCreatePaymentIntentChecksOrder(t *testing.T) {
+	previous := stripeClient
+	defer func() { stripeClient = previous }()
+
+	stripeClient = nil
+	_, _, err := CreatePaymentIntent(context.Background(), &models.Order{Status: services.OrderStatusPending})
+	assert.ErrorIs(t, err, ErrNotConfigured)
+	_, err = ConfirmOrderPayment(context.Background(), &models.Order{Status: services.OrderStatusPending})
+	assert.ErrorIs(t, err, ErrNotConfigured)
+
+	// Only pending orders can be paid; Stripe isn't called for the others
+	stripeClient = newTestStripeClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected Stripe request %s %s", r.Method, r.URL.Path)
+	})
+	for _, status := range []string{services.OrderStatusPaid, services.OrderStatusCancelled, services.OrderStatusRefunded} {
+		_, _, err := CreatePaymentIntent(context.Background(), &models.Order{Status: status})
+		assert.ErrorIs(t, err, ErrOrderNotPayable, status)
+	}
+}
@@ -0,0 +1,71 @@
+//go:build e2e
+
+package payments
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"secure-backend/database"
+	"secure-backend/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testDBOnce sync.Once
+	testDBErr  error
+)
+
+// initTestDB connects the database package to TEST_DATABASE_URL, skipping the test if it isn't set
+func initTestDB(t *testing.T) {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	testDBOnce.Do(func() {
+		os.Setenv("DATABASE_URL", dsn)
+		testDBErr = database.InitDB()
+	})
+	if testDBErr != nil {
+		t.Fatalf("failed to connect to test database: %v", testDBErr)
+	}
+}
+
+// createTestUsers connects to the test database and creates a user per role with an email
+// unique to the run. The users are deleted when the test ends; cleanups registered afterwards
+// run first, so tests can delete rows that would keep them.
+func createTestUsers(t *testing.T, prefix string, roles ...string) []*models.AuthUser {
+	t.Helper()
+	initTestDB(t)
+
+	suffix := uuid.NewString()[:8]
+	users := make([]*models.AuthUser, len(roles))
+	ids := make([]string, len(roles))
+	for i, role := range roles {
+		email := fmt.Sprintf("%s-%s-%d-%s@example.com", prefix, role, i, suffix)
+		require.NoError(t, database.DB.GetContext(context.Background(), &ids[i], `INSERT INTO users (email, role) VALUES ($1, $2) RETURNING id`, email, role))
+		users[i] = &models.AuthUser{ID: ids[i], Email: email, Role: role}
+	}
+	t.Cleanup(func() {
+		database.DB.ExecContext(context.Background(), `DELETE FROM users WHERE id = ANY($1)`, pq.Array(ids))
+	})
+	return users
+}
+
+// deleteTestOrders deletes a buyer's orders with the disputes, refunds and payments that block deleting them
+func deleteTestOrders(buyerID string) {
+	ctx := context.Background()
+	orders := `SELECT id FROM orders WHERE buyer_id = $1`
+	database.DB.ExecContext(ctx, `DELETE FROM disputes WHERE order_id IN (`+orders+`)`, buyerID)
+	database.DB.ExecContext(ctx, `DELETE FROM refunds WHERE order_id IN (`+orders+`)`, buyerID)
+	database.DB.ExecContext(ctx, `DELETE FROM payments WHERE order_id IN (`+orders+`)`, buyerID)
+	database.DB.ExecContext(ctx, `DELETE FROM orders WHERE buyer_id = $1`, buyerID)
+}
@@ -0,0 +1,278 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"secure-backend/clock"
+	"secure-backend/database"
+	"secure-backend/services"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureTolerance is how old a signed webhook may be before it is rejected as a replay
+const SignatureTolerance = 5 * time.Minute
+
+// Supported webhook event types
+const (
+	EventPaymentSucceeded = "payment_intent.succeeded"
+	EventPaymentFailed    = "payment_intent.payment_failed"
+	EventChargeRefunded   = "charge.refunded"
+	EventDisputeCreated   = "charge.dispute.created"
+	EventDisputeUpdated   = "charge.dispute.updated"
+	EventDisputeClosed    = "charge.dispute.closed"
+)
+
+var (
+	// ErrInvalidSignature is returned when a webhook signature does not verify
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrSignatureExpired is returned when a webhook timestamp is outside the tolerance
+	ErrSignatureExpired = errors.New("webhook timestamp outside tolerance")
+	// ErrWebhookNotConfigured is returned when STRIPE_WEBHOOK_SECRET is not set
+	ErrWebhookNotConfigured = errors.New("webhook secret is not configured")
+)
+
+// clk is checked against webhook signature timestamps; replaced in tests
+var clk clock.Clock = clock.System()
+
+// SetClock replaces the clock used to reject replayed webhooks
+func SetClock(c clock.Clock) {
+	clk = c
+}
+
+// Event is a payment provider webhook event
+type Event struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+// VerifySignature checks a Stripe-style signature header ("t=<unix>,v1=<hex hmac>")
+// against HMAC-SHA256("<t>.<payload>") and rejects timestamps outside the tolerance
+func VerifySignature(payload []byte, header, secret string, tolerance time.Duration, now time.Time) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return ErrSignatureExpired
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	expected := mac.Sum(nil)
+
+	for _, sig := range signatures {
+		decoded, err := hex.DecodeString(sig)
+		if err == nil && hmac.Equal(decoded, expected) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// ParseWebhook verifies the signature of a webhook payload and decodes the event
+func ParseWebhook(payload []byte, signatureHeader string) (*Event, error) {
+	secret := os.Getenv("STRIPE_WEBHOOK_SECRET")
+	if secret == "" {
+		return nil, ErrWebhookNotConfigured
+	}
+
+	if err := VerifySignature(payload, signatureHeader, secret, SignatureTolerance, clk.Now()); err != nil {
+		return nil, err
+	}
+
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("decoding webhook event: %w", err)
+	}
+	if event.ID == "" || event.Type == "" {
+		return nil, errors.New("webhook event is missing id or type")
+	}
+	return &event, nil
+}
+
+// HandleEvent processes a verified webhook event exactly once. It returns
+// duplicate=true when the event was already processed successfully.
+func HandleEvent(ctx context.Context, event *Event, payload []byte) (duplicate bool, err error) {
+	alreadyProcessed, err := database.RecordWebhookEvent(ctx, ProviderStripe, event.ID, event.Type, payload)
+	if err != nil {
+		return false, err
+	}
+	if alreadyProcessed {
+		return true, nil
+	}
+
+	if err := dispatchEvent(ctx, event); err != nil {
+		if markErr := database.MarkWebhookEventFailed(ctx, ProviderStripe, event.ID, err.Error()); markErr != nil {
+			log.Printf("Failed to record webhook failure for %s: %v", event.ID, markErr)
+		}
+		return false, err
+	}
+
+	return false, database.MarkWebhookEventProcessed(ctx, ProviderStripe, event.ID)
+}
+
+// dispatchEvent applies a webhook event to payments and orders
+func dispatchEvent(ctx context.Context, event *Event) error {
+	switch event.Type {
+	case EventPaymentSucceeded, EventPaymentFailed:
+		var intent PaymentIntent
+		if err := json.Unmarshal(event.Data.Object, &intent); err != nil {
+			return fmt.Errorf("decoding payment intent: %w", err)
+		}
+		return handlePaymentIntent(ctx, &intent)
+
+	case EventChargeRefunded:
+		var charge struct {
+			PaymentIntent  string `json:"payment_intent"`
+			Amount         int64  `json:"amount"`
+			AmountRefunded int64  `json:"amount_refunded"`
+		}
+		if err := json.Unmarshal(event.Data.Object, &charge); err != nil {
+			return fmt.Errorf("decoding charge: %w", err)
+		}
+		return handleChargeRefunded(ctx, charge.PaymentIntent, charge.Amount, charge.AmountRefunded)
+
+	case EventDisputeCreated, EventDisputeUpdated, EventDisputeClosed:
+		var dispute StripeDispute
+		if err := json.Unmarshal(event.Data.Object, &dispute); err != nil {
+			return fmt.Errorf("decoding dispute: %w", err)
+		}
+		return handleDispute(ctx, &dispute)
+	}
+
+	// Unhandled event types are acknowledged so the provider stops retrying them
+	return nil
+}
+
+// settledPaymentStatuses are the payment statuses payment intent events can't move a payment
+// out of. Webhooks may arrive out of order: a failure delivered after the success must not
+// undo the payment.
+var settledPaymentStatuses = []string{intentSucceeded, intentCanceled, paymentRefunded, paymentPartiallyRefunded}
+
+// handlePaymentIntent records the intent's status, marks the order paid once it succeeded and
+// starts payment retries when it failed. Events for payments that already settled are ignored.
+func handlePaymentIntent(ctx context.Context, intent *PaymentIntent) error {
+	payment, err := database.GetPaymentByProviderID(ctx, ProviderStripe, intent.ID)
+	if err == sql.ErrNoRows {
+		log.Printf("Webhook for unknown payment intent %s", intent.ID)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	advanced, err := database.AdvancePaymentStatus(ctx, payment.ID, intent.Status, settledPaymentStatuses)
+	if err != nil {
+		return err
+	}
+	if !advanced {
+		log.Printf("Ignoring %s status of payment intent %s, which already settled", intent.Status, intent.ID)
+		return nil
+	}
+
+	if intent.Status == intentRequiresPaymentMethod && intent.LastPaymentError != nil {
+		// The charge was declined: retry it on a schedule before giving up on the order
+		return startDunning(ctx, payment)
+	}
+	if intent.Status != intentSucceeded {
+		return nil
+	}
+
+	err = markOrderPaid(ctx, payment.OrderID, "Payment "+intent.ID+" succeeded")
+	if errors.Is(err, ErrOrderNotPayable) {
+		// The checkout expired before the payment landed; needs a refund by an operator
+		log.Printf("Payment %s succeeded for cancelled order %s", intent.ID, payment.OrderID)
+		return nil
+	}
+	return err
+}
+
+// handleChargeRefunded records refunds issued at the provider and refunds the order when fully refunded
+func handleChargeRefunded(ctx context.Context, intentID string, amount, amountRefunded int64) error {
+	payment, err := database.GetPaymentByProviderID(ctx, ProviderStripe, intentID)
+	if err == sql.ErrNoRows {
+		log.Printf("Refund webhook for unknown payment intent %s", intentID)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	status := paymentPartiallyRefunded
+	if amountRefunded >= amount {
+		status = paymentRefunded
+	}
+	if err := database.UpdatePaymentStatus(ctx, payment.ID, status); err != nil {
+		return err
+	}
+
+	if status != paymentRefunded {
+		return nil
+	}
+
+	// Another payment of a split order (e.g. store credit) may still hold money
+	payments, err := database.GetPaymentsByOrder(ctx, payment.OrderID)
+	if err != nil {
+		return err
+	}
+	for _, other := range payments {
+		if other.ID != payment.ID && (other.Status == intentSucceeded || other.Status == paymentPartiallyRefunded) {
+			return nil
+		}
+	}
+
+	_, err = services.TransitionOrder(ctx, payment.OrderID, services.OrderStatusRefunded, nil, "Payment refunded at provider")
+	if errors.Is(err, services.ErrInvalidTransition) {
+		// Already refunded or cancelled
+		return nil
+	}
+	return err
+}
+
+// ReplayWebhookEvent processes a stored webhook event again, e.g. after a downstream outage.
+// The signature was verified when the event was received.
+func ReplayWebhookEvent(ctx context.Context, id string) error {
+	stored, err := database.GetWebhookEventByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	var event Event
+	if err := json.Unmarshal(stored.Payload, &event); err != nil {
+		return fmt.Errorf("decoding stored webhook event: %w", err)
+	}
+
+	_, err = HandleEvent(ctx, &event, stored.Payload)
+	return err
+}
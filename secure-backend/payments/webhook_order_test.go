@@ -0,0 +1,122 @@
+//go:build e2e
+
+// Webhook delivery tests against a real PostgreSQL database (with database/schema.sql applied):
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run TestHandleEvent ./payments
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/money"
+	"secure-backend/services"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleEventOutOfOrder(t *testing.T) {
+	buyerID := createTestUsers(t, "webhook", "buyer")[0].ID
+	t.Setenv("STRIPE_WEBHOOK_SECRET", "whsec_test")
+	ctx := context.Background()
+
+	suffix := uuid.NewString()[:8]
+	t.Cleanup(func() {
+		deleteTestOrders(buyerID)
+		database.DB.ExecContext(context.Background(), `DELETE FROM webhook_events WHERE event_id LIKE $1`, "evt_%_"+suffix)
+	})
+
+	var orderID string
+	require.NoError(t, database.DB.GetContext(ctx, &orderID, `
+		INSERT INTO orders (buyer_id, status, total_amount) VALUES ($1, 'pending', 20) RETURNING id
+	`, buyerID))
+
+	intentID := "pi_" + suffix
+	payment := &models.Payment{
+		OrderID:           orderID,
+		Provider:          ProviderStripe,
+		ProviderPaymentID: intentID,
+		Amount:            money.FromFloat(20),
+		Currency:          "usd",
+		Status:            intentRequiresPaymentMethod,
+	}
+	require.NoError(t, database.CreatePayment(ctx, payment))
+
+	deliver := func(kind, eventType, object string) bool {
+		payload := []byte(fmt.Sprintf(`{"id":"evt_%s_%s","type":%q,"data":{"object":%s}}`, kind, suffix, eventType, object))
+		event, err := ParseWebhook(payload, sign(payload, "whsec_test", clk.Now()))
+		require.NoError(t, err)
+		duplicate, err := HandleEvent(ctx, event, payload)
+		require.NoError(t, err)
+		return duplicate
+	}
+	succeeded := fmt.Sprintf(`{"id":%q,"status":"succeeded"}`, intentID)
+	failed := fmt.Sprintf(`{"id":%q,"status":"requires_payment_method","last_payment_error":{"code":"card_declined","message":"Your card was declined."}}`, intentID)
+
+	assert.False(t, deliver("succeeded", EventPaymentSucceeded, succeeded))
+
+	// The failure of an earlier attempt arrives after the success
+	assert.False(t, deliver("failed", EventPaymentFailed, failed))
+
+	stored, err := database.GetPaymentByProviderID(ctx, ProviderStripe, intentID)
+	require.NoError(t, err)
+	assert.Equal(t, intentSucceeded, stored.Status, "a late failure doesn't undo the payment")
+	order, err := database.GetOrderByID(ctx, orderID)
+	require.NoError(t, err)
+	assert.Equal(t, services.OrderStatusPaid, order.Status)
+	var dunning int
+	require.NoError(t, database.DB.GetContext(ctx, &dunning, `SELECT COUNT(*) FROM payment_dunning WHERE order_id = $1`, orderID))
+	assert.Zero(t, dunning, "no payment retries start for a paid order")
+
+	// Redelivering an event that was processed is acknowledged without applying it again
+	assert.True(t, deliver("succeeded", EventPaymentSucceeded, succeeded))
+}
+
+func TestHandleEventRetriesFailedEvents(t *testing.T) {
+	initTestDB(t)
+	ctx := context.Background()
+
+	suffix := uuid.NewString()[:8]
+	eventID := "evt_retry_" + suffix
+	t.Cleanup(func() {
+		database.DB.ExecContext(context.Background(), `DELETE FROM webhook_events WHERE event_id LIKE $1`, "evt_%_"+suffix)
+	})
+	stored := func(eventID string) (attempts int, processed bool) {
+		t.Helper()
+		require.NoError(t, database.DB.QueryRowContext(ctx, `
+			SELECT attempts, processed_at IS NOT NULL FROM webhook_events WHERE provider = $1 AND event_id = $2
+		`, ProviderStripe, eventID).Scan(&attempts, &processed))
+		return attempts, processed
+	}
+
+	// An event that fails to apply is kept unprocessed, so the provider's redelivery runs it again
+	event := &Event{ID: eventID, Type: EventPaymentSucceeded}
+	event.Data.Object = json.RawMessage(`"not an object"`)
+	payload := []byte(`{"id":"` + eventID + `"}`)
+	for attempt := 1; attempt <= 2; attempt++ {
+		duplicate, err := HandleEvent(ctx, event, payload)
+		assert.Error(t, err)
+		assert.False(t, duplicate, "a failed event isn't reported as a duplicate")
+		attempts, processed := stored(eventID)
+		assert.Equal(t, attempt, attempts)
+		assert.False(t, processed)
+	}
+
+	// Once it applies it is processed, and later deliveries are duplicates
+	event.Data.Object = json.RawMessage(`{"id":"pi_unknown_` + suffix + `","status":"succeeded"}`)
+	duplicate, err := HandleEvent(ctx, event, payload)
+	require.NoError(t, err)
+	assert.False(t, duplicate)
+	duplicate, err = HandleEvent(ctx, event, payload)
+	require.NoError(t, err)
+	assert.True(t, duplicate)
+	attempts, processed := stored(eventID)
+	assert.Equal(t, 4, attempts)
+	assert.True(t, processed)
+}
@@ -0,0 +1,113 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"secure-backend/clock"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sign(payload []byte, secret string, ts time.Time) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.%s", ts.Unix(), payload)
+	return fmt.Sprintf("t=%d,v1=%s", ts.Unix(), hex.EncodeToString(mac.Sum(nil)))
+}
+
+func TestVerifySignature(t *testing.T) {
+	payload := []byte(`{"id":"evt_1","type":"payment_intent.succeeded"}`)
+	secret := "whsec_test"
+	now := time.Now()
+
+	assert.NoError(t, VerifySignature(payload, sign(payload, secret, now), secret, SignatureTolerance, now))
+
+	// Wrong secret or tampered payload
+	assert.ErrorIs(t, VerifySignature(payload, sign(payload, "other", now), secret, SignatureTolerance, now), ErrInvalidSignature)
+	assert.ErrorIs(t, VerifySignature([]byte(`{}`), sign(payload, secret, now), secret, SignatureTolerance, now), ErrInvalidSignature)
+
+	// Replayed outside the tolerance window
+	old := now.Add(-10 * time.Minute)
+	assert.ErrorIs(t, VerifySignature(payload, sign(payload, secret, old), secret, SignatureTolerance, now), ErrSignatureExpired)
+
+	// Signed too far in the future (clock skew or a forged timestamp), but not a little
+	assert.ErrorIs(t, VerifySignature(payload, sign(payload, secret, now.Add(10*time.Minute)), secret, SignatureTolerance, now), ErrSignatureExpired)
+	assert.NoError(t, VerifySignature(payload, sign(payload, secret, now.Add(time.Minute)), secret, SignatureTolerance, now))
+
+	// The signature of a rolled secret may come first during a secret rotation
+	rotated := strings.Replace(sign(payload, secret, now), ",v1=", ",v1=not-hex,v1="+strings.Repeat("00", sha256.Size)+",v1=", 1)
+	assert.NoError(t, VerifySignature(payload, rotated, secret, SignatureTolerance, now))
+
+	// A valid signature with a different timestamp than the one signed
+	forged := strings.Replace(sign(payload, secret, now), fmt.Sprintf("t=%d", now.Unix()), fmt.Sprintf("t=%d", now.Unix()-1), 1)
+	assert.ErrorIs(t, VerifySignature(payload, forged, secret, SignatureTolerance, now), ErrInvalidSignature)
+
+	// Malformed headers
+	assert.ErrorIs(t, VerifySignature(payload, "", secret, SignatureTolerance, now), ErrInvalidSignature)
+	assert.ErrorIs(t, VerifySignature(payload, "t=abc,v1=00", secret, SignatureTolerance, now), ErrInvalidSignature)
+	assert.ErrorIs(t, VerifySignature(payload, fmt.Sprintf("t=%d", now.Unix()), secret, SignatureTolerance, now), ErrInvalidSignature)
+	assert.ErrorIs(t, VerifySignature(payload, "v0="+strings.Repeat("00", sha256.Size), secret, SignatureTolerance, now), ErrInvalidSignature)
+}
+
+func TestParseWebhookRejectsBadEvents(t *testing.T) {
+	payload := []byte(`{"id":"evt_1","type":"payment_intent.succeeded","data":{"object":{}}}`)
+	now := clk.Now()
+
+	t.Setenv("STRIPE_WEBHOOK_SECRET", "")
+	_, err := ParseWebhook(payload, sign(payload, "whsec_test", now))
+	assert.ErrorIs(t, err, ErrWebhookNotConfigured)
+
+	t.Setenv("STRIPE_WEBHOOK_SECRET", "whsec_test")
+	_, err = ParseWebhook(payload, sign(payload, "whsec_other", now))
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	// Signed but not an event
+	for _, body := range []string{`not json`, `{"type":"payment_intent.succeeded"}`, `{"id":"evt_1"}`} {
+		_, err := ParseWebhook([]byte(body), sign([]byte(body), "whsec_test", now))
+		assert.Error(t, err, body)
+	}
+}
+
+func TestDispatchEventWithoutPayments(t *testing.T) {
+	dispatch := func(eventType, object string) error {
+		event := &Event{ID: "evt_1", Type: eventType}
+		event.Data.Object = json.RawMessage(object)
+		return dispatchEvent(context.Background(), event)
+	}
+
+	// Event types the shop doesn't handle are acknowledged
+	require.NoError(t, dispatch("customer.created", `{"id":"cus_1"}`))
+
+	// Objects that don't decode fail so the event is retried
+	for _, eventType := range []string{EventPaymentSucceeded, EventPaymentFailed, EventChargeRefunded, EventDisputeCreated} {
+		assert.Error(t, dispatch(eventType, `"not an object"`), eventType)
+	}
+}
+
+func TestParseWebhookRejectsReplays(t *testing.T) {
+	t.Setenv("STRIPE_WEBHOOK_SECRET", "whsec_test")
+	payload := []byte(`{"id":"evt_1","type":"payment_intent.succeeded","data":{"object":{}}}`)
+
+	signedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	header := sign(payload, "whsec_test", signedAt)
+
+	mock := clock.NewMock(signedAt.Add(time.Minute))
+	SetClock(mock)
+	defer SetClock(clock.System())
+
+	event, err := ParseWebhook(payload, header)
+	assert.NoError(t, err)
+	assert.Equal(t, "evt_1", event.ID)
+
+	// The same delivery replayed after the tolerance window is rejected
+	mock.Advance(SignatureTolerance)
+	_, err = ParseWebhook(payload, header)
+	assert.ErrorIs(t, err, ErrSignatureExpired)
+}
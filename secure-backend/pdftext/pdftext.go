@@ -0,0 +1,20 @@
+// Package pdftext prepares text for the PDF documents (invoices, pick lists and packing
+// slips), which print with the Latin-1 core fonts.
+package pdftext
+
+import "github.com/go-pdf/fpdf"
+
+// Translator returns a function converting UTF-8 text to the code page of the core fonts,
+// so accented names and the ellipsis of truncated values print correctly
+func Translator(pdf *fpdf.Fpdf) func(string) string {
+	return pdf.UnicodeTranslatorFromDescriptor("")
+}
+
+// Truncate shortens s to at most n runes so long values don't overflow their column
+func Truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
@@ -0,0 +1,19 @@
+package pdftext
+
+import (
+	"testing"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "Lamp", Truncate("Lamp", 4))
+	assert.Equal(t, "Des…", Truncate("Desk lamp", 4))
+	assert.Equal(t, "Café…", Truncate("Café crème", 5), "counts runes, not bytes")
+}
+
+func TestTranslator(t *testing.T) {
+	tr := Translator(fpdf.New("P", "mm", "A4", ""))
+	assert.Equal(t, "Caf\xe9 \x85", tr("Café …"), "accents and the ellipsis map to the core fonts' code page")
+}
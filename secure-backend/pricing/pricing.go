@@ -0,0 +1,93 @@
+// Package pricing computes cart money values on the server so clients only display them.
+// Amounts are exact cents (money.Amount), like order totals.
+package pricing
+
+import (
+	"log"
+	"os"
+	"secure-backend/invoices"
+	"secure-backend/models"
+	"secure-backend/money"
+)
+
+// Config holds the tax and shipping settings used for estimates
+type Config struct {
+	// TaxRate is the tax included in product prices (the invoice tax rate)
+	TaxRate float64
+	// ShippingFlat is charged on non-empty carts below the free shipping threshold
+	ShippingFlat money.Amount
+	// FreeShippingThreshold waives shipping from this subtotal on (0 never waives it)
+	FreeShippingThreshold money.Amount
+}
+
+// ConfigFromEnv reads SHIPPING_FLAT_RATE and FREE_SHIPPING_THRESHOLD (decimal amounts, default 0)
+// and takes the tax rate from INVOICE_TAX_RATE, so estimates match the invoice
+func ConfigFromEnv() Config {
+	return Config{
+		TaxRate:               invoices.TaxRate(),
+		ShippingFlat:          amountFromEnv("SHIPPING_FLAT_RATE"),
+		FreeShippingThreshold: amountFromEnv("FREE_SHIPPING_THRESHOLD"),
+	}
+}
+
+// amountFromEnv parses a non-negative decimal amount, defaulting to 0
+func amountFromEnv(name string) money.Amount {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0
+	}
+	amount, err := money.Parse(value)
+	if err != nil || amount < 0 {
+		log.Printf("Invalid %s %q, using 0", name, value)
+		return 0
+	}
+	return amount
+}
+
+// Line is a priced quantity of one product
+type Line struct {
+	UnitPrice money.Amount
+	Quantity  int
+}
+
+// Summary holds the server-computed totals of a cart
+type Summary struct {
+	ItemCount        int          `json:"item_count"` // units counted in the subtotal
+	Subtotal         money.Amount `json:"subtotal"`
+	EstimatedTax     money.Amount `json:"estimated_tax"`
+	TaxRate          float64      `json:"tax_rate"`
+	TaxIncluded      bool         `json:"tax_included"` // tax is part of the subtotal, not added to it
+	ShippingEstimate money.Amount `json:"shipping_estimate"`
+	Total            money.Amount `json:"total"`
+}
+
+// Summarize totals the lines. Prices include tax, so the estimated tax is the share of
+// the subtotal that is tax and the total is the subtotal plus shipping.
+func (cfg Config) Summarize(lines []Line) Summary {
+	var subtotal money.Amount
+	items := 0
+	for _, line := range lines {
+		if line.Quantity <= 0 {
+			continue
+		}
+		subtotal += line.UnitPrice.Times(line.Quantity)
+		items += line.Quantity
+	}
+
+	_, tax := models.SplitIncludedTax(subtotal, cfg.TaxRate)
+
+	var shipping money.Amount
+	if items > 0 && (cfg.FreeShippingThreshold == 0 || subtotal < cfg.FreeShippingThreshold) {
+		shipping = cfg.ShippingFlat
+	}
+
+	return Summary{
+		ItemCount:        items,
+		Subtotal:         subtotal,
+		EstimatedTax:     tax,
+		TaxRate:          cfg.TaxRate,
+		TaxIncluded:      true,
+		ShippingEstimate: shipping,
+		Total:            subtotal + shipping,
+	}
+}
@@ -0,0 +1,30 @@
+package pricing
+
+import (
+	"secure-backend/money"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarize(t *testing.T) {
+	cfg := Config{TaxRate: 0.2, ShippingFlat: 499, FreeShippingThreshold: 5000}
+
+	// 3 x 10.10 + 1 x 0.30 = 30.60, of which 5.10 is tax; below the threshold so shipping applies
+	summary := cfg.Summarize([]Line{{UnitPrice: 1010, Quantity: 3}, {UnitPrice: 30, Quantity: 1}, {UnitPrice: 9900, Quantity: 0}})
+	assert.Equal(t, 4, summary.ItemCount)
+	assert.Equal(t, money.Amount(3060), summary.Subtotal)
+	assert.Equal(t, money.Amount(510), summary.EstimatedTax)
+	assert.Equal(t, money.Amount(499), summary.ShippingEstimate)
+	assert.Equal(t, money.Amount(3559), summary.Total)
+	assert.True(t, summary.TaxIncluded)
+
+	// Free shipping from the threshold on
+	summary = cfg.Summarize([]Line{{UnitPrice: 2500, Quantity: 2}})
+	assert.Equal(t, money.Amount(0), summary.ShippingEstimate)
+	assert.Equal(t, money.Amount(5000), summary.Total)
+
+	// Empty carts have no shipping
+	summary = cfg.Summarize(nil)
+	assert.Equal(t, Summary{TaxRate: 0.2, TaxIncluded: true}, summary)
+}
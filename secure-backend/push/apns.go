@@ -0,0 +1,156 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"secure-backend/outbound"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	apnsProductionHost = "https://api.push.apple.com"
+	apnsSandboxHost    = "https://api.sandbox.push.apple.com"
+
+	// APNs rejects provider tokens older than an hour and throttles refreshes under 20 minutes
+	apnsTokenLifetime = 50 * time.Minute
+)
+
+// apnsPermanentReasons are APNs error reasons meaning the device token will never work again
+var apnsPermanentReasons = map[string]bool{
+	"BadDeviceToken":         true,
+	"DeviceTokenNotForTopic": true,
+	"Unregistered":           true,
+}
+
+// APNsSender delivers messages to iOS devices using token-based APNs authentication
+type APNsSender struct {
+	key    *ecdsa.PrivateKey
+	keyID  string
+	teamID string
+	topic  string
+	host   string
+	client *http.Client
+
+	mu        sync.Mutex
+	authToken string
+	issuedAt  time.Time
+}
+
+// NewAPNsSender creates an APNs sender from a .p8 signing key
+func NewAPNsSender(keyFile, keyID, teamID, topic string, production bool) (*APNsSender, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading APNs key: %w", err)
+	}
+
+	key, err := jwt.ParseECPrivateKeyFromPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing APNs key: %w", err)
+	}
+
+	host := apnsSandboxHost
+	if production {
+		host = apnsProductionHost
+	}
+
+	return &APNsSender{
+		key:    key,
+		keyID:  keyID,
+		teamID: teamID,
+		topic:  topic,
+		host:   host,
+		client: outbound.NewClient("apns", 10*time.Second),
+	}, nil
+}
+
+// Send implements Sender
+func (s *APNsSender) Send(ctx context.Context, token string, msg Message) error {
+	authToken, err := s.token()
+	if err != nil {
+		return err
+	}
+
+	body := map[string]interface{}{
+		"aps": map[string]interface{}{
+			"alert": map[string]string{
+				"title": msg.Title,
+				"body":  msg.Body,
+			},
+			"sound": "default",
+		},
+	}
+	for k, v := range msg.Data {
+		if k != "aps" {
+			body[k] = v
+		}
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.host+"/3/device/"+token, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "bearer "+authToken)
+	req.Header.Set("apns-topic", s.topic)
+	req.Header.Set("apns-push-type", "alert")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending APNs notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var apnsErr struct {
+		Reason string `json:"reason"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&apnsErr)
+
+	if resp.StatusCode == http.StatusGone || apnsPermanentReasons[apnsErr.Reason] {
+		return &PermanentError{Reason: apnsErr.Reason}
+	}
+
+	return fmt.Errorf("APNs returned %d: %s", resp.StatusCode, apnsErr.Reason)
+}
+
+// token returns a cached provider authentication token, re-signing it when it gets old
+func (s *APNsSender) token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.authToken != "" && time.Since(s.issuedAt) < apnsTokenLifetime {
+		return s.authToken, nil
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": s.teamID,
+		"iat": now.Unix(),
+	})
+	token.Header["kid"] = s.keyID
+
+	signed, err := token.SignedString(s.key)
+	if err != nil {
+		return "", fmt.Errorf("signing APNs token: %w", err)
+	}
+
+	s.authToken = signed
+	s.issuedAt = now
+	return signed, nil
+}
@@ -0,0 +1,138 @@
+package push
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestKey writes key as a PKCS #8 PEM file and returns its path
+func writeTestKey(t *testing.T, key crypto.PrivateKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+	return path
+}
+
+// recordedRequest is what a fake provider saw of a request
+type recordedRequest struct {
+	Header http.Header
+	Body   map[string]interface{}
+}
+
+// recorder keeps the requests a fake provider received
+type recorder struct {
+	mu       sync.Mutex
+	requests []recordedRequest
+}
+
+func (r *recorder) record(req *http.Request) {
+	var body map[string]interface{}
+	_ = json.NewDecoder(req.Body).Decode(&body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, recordedRequest{Header: req.Header.Clone(), Body: body})
+}
+
+func (r *recorder) all() []recordedRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]recordedRequest(nil), r.requests...)
+}
+
+func TestAPNsSender(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	// The device token in the path picks the response
+	var seen recorder
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen.record(r)
+		switch strings.TrimPrefix(r.URL.Path, "/3/device/") {
+		case "ok":
+		case "gone":
+			w.WriteHeader(http.StatusGone)
+			w.Write([]byte(`{"reason":"Unregistered"}`))
+		case "bad":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"reason":"BadDeviceToken"}`))
+		default:
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"reason":"TooManyRequests"}`))
+		}
+	}))
+	defer server.Close()
+
+	sender, err := NewAPNsSender(writeTestKey(t, key), "KEY123", "TEAM123", "com.example.shop", false)
+	require.NoError(t, err)
+	assert.Equal(t, apnsSandboxHost, sender.host)
+	sender.host, sender.client = server.URL, server.Client()
+	ctx := context.Background()
+
+	msg := Message{Title: "Order shipped", Body: "Your order is on its way", Data: map[string]string{"order_id": "o-1", "aps": "ignored"}}
+	require.NoError(t, sender.Send(ctx, "ok", msg))
+
+	requests := seen.all()
+	require.Len(t, requests, 1)
+	request := requests[0]
+	assert.Equal(t, "com.example.shop", request.Header.Get("apns-topic"))
+	assert.Equal(t, "alert", request.Header.Get("apns-push-type"))
+	alert := request.Body["aps"].(map[string]interface{})["alert"].(map[string]interface{})
+	assert.Equal(t, "Order shipped", alert["title"])
+	assert.Equal(t, "Your order is on its way", alert["body"])
+	assert.Equal(t, "o-1", request.Body["order_id"], "data is sent alongside aps")
+
+	// The provider token is signed by the key and names the team and key
+	authToken := strings.TrimPrefix(request.Header.Get("Authorization"), "bearer ")
+	parsed, err := jwt.Parse(authToken, func(token *jwt.Token) (interface{}, error) {
+		return &key.PublicKey, nil
+	}, jwt.WithValidMethods([]string{"ES256"}))
+	require.NoError(t, err)
+	assert.Equal(t, "KEY123", parsed.Header["kid"])
+	issuer, err := parsed.Claims.GetIssuer()
+	require.NoError(t, err)
+	assert.Equal(t, "TEAM123", issuer)
+
+	// Tokens the device will never accept again are permanent failures; throttling isn't
+	assert.True(t, IsPermanent(sender.Send(ctx, "gone", msg)))
+	assert.True(t, IsPermanent(sender.Send(ctx, "bad", msg)))
+	err = sender.Send(ctx, "busy", msg)
+	require.Error(t, err)
+	assert.False(t, IsPermanent(err))
+	assert.Contains(t, err.Error(), "429")
+
+	// The provider token is reused rather than signed per request
+	for _, request := range seen.all() {
+		assert.Equal(t, "bearer "+authToken, request.Header.Get("Authorization"))
+	}
+}
+
+func TestNewAPNsSenderProduction(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	sender, err := NewAPNsSender(writeTestKey(t, key), "KEY123", "TEAM123", "com.example.shop", true)
+	require.NoError(t, err)
+	assert.Equal(t, apnsProductionHost, sender.host)
+
+	_, err = NewAPNsSender(filepath.Join(t.TempDir(), "missing.p8"), "KEY123", "TEAM123", "com.example.shop", true)
+	assert.Error(t, err)
+}
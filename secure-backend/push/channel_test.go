@@ -0,0 +1,99 @@
+//go:build e2e
+
+// Push channel tests against a real PostgreSQL database (with database/schema.sql applied):
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run TestChannel ./push
+package push
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+
+	"secure-backend/database"
+	"secure-backend/notifications"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSender fails the tokens it is given an error for and records every message it was asked to send
+type fakeSender struct {
+	errs map[string]error
+
+	mu   sync.Mutex
+	sent map[string]Message
+}
+
+func (s *fakeSender) Send(ctx context.Context, token string, msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sent == nil {
+		s.sent = make(map[string]Message)
+	}
+	s.sent[token] = msg
+	return s.errs[token]
+}
+
+func (s *fakeSender) tokens() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens := make([]string, 0, len(s.sent))
+	for token := range s.sent {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+func TestChannelSend(t *testing.T) {
+	userID := createTestUsers(t, "push", "buyer")[0]
+	ctx := context.Background()
+
+	suffix := uuid.NewString()
+	phone, tablet, stale := "ios-phone-"+suffix, "ios-tablet-"+suffix, "ios-stale-"+suffix
+	android := "android-" + suffix
+	for token, platform := range map[string]string{phone: PlatformIOS, tablet: PlatformIOS, stale: PlatformIOS, android: PlatformAndroid} {
+		_, err := database.RegisterDeviceToken(ctx, userID, token, platform)
+		require.NoError(t, err)
+	}
+	registered := func() []string {
+		t.Helper()
+		devices, err := database.GetDeviceTokens(ctx, userID)
+		require.NoError(t, err)
+		tokens := make([]string, len(devices))
+		for i, device := range devices {
+			tokens[i] = device.Token
+		}
+		sort.Strings(tokens)
+		return tokens
+	}
+
+	ios := &fakeSender{errs: map[string]error{
+		tablet: errors.New("APNs returned 503"),
+		stale:  &PermanentError{Reason: "Unregistered"},
+	}}
+	notification := notifications.Notification{
+		UserID: userID, Type: notifications.TypeOrderStatus, Title: "Order shipped", Body: "On its way",
+		Data: map[string]string{"order_id": "o-1"},
+	}
+
+	// Devices of platforms without a sender are skipped; every other device is tried
+	err := NewChannel(map[string]Sender{PlatformIOS: ios}).Send(ctx, notification)
+	assert.ElementsMatch(t, []string{phone, tablet, stale}, ios.tokens())
+	assert.Equal(t, Message{Title: "Order shipped", Body: "On its way", Data: map[string]string{"order_id": "o-1"}}, ios.sent[phone])
+
+	// Temporary failures are reported, while tokens rejected for good are removed quietly
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "APNs returned 503")
+	assert.NotContains(t, err.Error(), "Unregistered")
+	want := []string{android, phone, tablet}
+	sort.Strings(want)
+	assert.Equal(t, want, registered())
+
+	// A user without devices gets nothing, without an error
+	assert.NoError(t, NewChannel(map[string]Sender{PlatformIOS: ios}).Send(ctx, notifications.Notification{UserID: uuid.NewString()}))
+}
@@ -0,0 +1,41 @@
+package push
+
+import (
+	"log"
+	"os"
+)
+
+// NewChannelFromEnv builds a push channel from the FCM_* and APNS_* environment variables.
+// Platforms without configuration are skipped; nil is returned if none is configured.
+func NewChannelFromEnv() *Channel {
+	senders := make(map[string]Sender)
+
+	if credentials := os.Getenv("FCM_CREDENTIALS_FILE"); credentials != "" {
+		sender, err := NewFCMSender(credentials)
+		if err != nil {
+			log.Printf("FCM push disabled: %v", err)
+		} else {
+			senders[PlatformAndroid] = sender
+		}
+	}
+
+	if keyFile := os.Getenv("APNS_KEY_FILE"); keyFile != "" {
+		sender, err := NewAPNsSender(
+			keyFile,
+			os.Getenv("APNS_KEY_ID"),
+			os.Getenv("APNS_TEAM_ID"),
+			os.Getenv("APNS_TOPIC"),
+			os.Getenv("APNS_PRODUCTION") == "true",
+		)
+		if err != nil {
+			log.Printf("APNs push disabled: %v", err)
+		} else {
+			senders[PlatformIOS] = sender
+		}
+	}
+
+	if len(senders) == 0 {
+		return nil
+	}
+	return NewChannel(senders)
+}
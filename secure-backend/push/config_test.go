@@ -0,0 +1,32 @@
+package push
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewChannelFromEnv(t *testing.T) {
+	t.Setenv("FCM_CREDENTIALS_FILE", "")
+	t.Setenv("APNS_KEY_FILE", "")
+	assert.Nil(t, NewChannelFromEnv(), "no platform configured")
+
+	// Platforms that fail to load are left out
+	t.Setenv("FCM_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "missing.json"))
+	assert.Nil(t, NewChannelFromEnv())
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	t.Setenv("APNS_KEY_FILE", writeTestKey(t, key))
+	t.Setenv("APNS_PRODUCTION", "true")
+	channel := NewChannelFromEnv()
+	require.NotNil(t, channel)
+	assert.Len(t, channel.senders, 1)
+	require.IsType(t, &APNsSender{}, channel.senders[PlatformIOS])
+	assert.Equal(t, apnsProductionHost, channel.senders[PlatformIOS].(*APNsSender).host)
+}
@@ -0,0 +1,101 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"secure-backend/database"
+	"secure-backend/notifications"
+)
+
+// Supported device platforms
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+)
+
+// Message is the payload delivered to a single device
+type Message struct {
+	Title string
+	Body  string
+	Data  map[string]string
+}
+
+// Sender delivers messages to devices of one platform
+type Sender interface {
+	Send(ctx context.Context, token string, msg Message) error
+}
+
+// PermanentError indicates the provider rejected the token for good (uninstalled app, invalid token),
+// so it should be removed instead of retried
+type PermanentError struct {
+	Reason string
+}
+
+// Error implements the error interface
+func (e *PermanentError) Error() string {
+	return fmt.Sprintf("permanent push delivery failure: %s", e.Reason)
+}
+
+// IsPermanent reports whether err is a permanent delivery failure
+func IsPermanent(err error) bool {
+	var permanent *PermanentError
+	return errors.As(err, &permanent)
+}
+
+// IsValidPlatform validates device platform values
+func IsValidPlatform(platform string) bool {
+	return platform == PlatformIOS || platform == PlatformAndroid
+}
+
+// Channel is a notifications.Channel delivering to every device a user registered
+type Channel struct {
+	senders map[string]Sender
+}
+
+// NewChannel creates a push channel using the given sender per platform
+func NewChannel(senders map[string]Sender) *Channel {
+	return &Channel{senders: senders}
+}
+
+// Name implements notifications.Channel
+func (ch *Channel) Name() string {
+	return "push"
+}
+
+// Send implements notifications.Channel. Tokens rejected permanently by the
+// provider are deleted; other failures are reported after every device was tried.
+func (ch *Channel) Send(ctx context.Context, n notifications.Notification) error {
+	devices, err := database.GetDeviceTokens(ctx, n.UserID)
+	if err != nil {
+		return fmt.Errorf("loading device tokens: %w", err)
+	}
+
+	msg := Message{Title: n.Title, Body: n.Body, Data: n.Data}
+
+	var errs []error
+	for _, device := range devices {
+		sender, ok := ch.senders[device.Platform]
+		if !ok {
+			continue
+		}
+
+		err := sender.Send(ctx, device.Token, msg)
+		if err == nil {
+			continue
+		}
+
+		if IsPermanent(err) {
+			log.Printf("Removing %s device token for user %s: %v", device.Platform, device.UserID, err)
+			if err := database.PurgeDeviceToken(ctx, device.Token); err != nil {
+				errs = append(errs, fmt.Errorf("removing device token: %w", err))
+			}
+			continue
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", device.Platform, err))
+	}
+
+	return errors.Join(errs...)
+}
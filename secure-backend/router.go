@@ -0,0 +1,441 @@
+package main
+
+import (
+	"os"
+	"secure-backend/events"
+	"secure-backend/handlers"
+	"secure-backend/middleware"
+	"secure-backend/sessions"
+	"secure-backend/tracing"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// setupRouter builds the Gin engine with all middleware and API routes
+func setupRouter() *gin.Engine {
+	// Initialize router without default middleware
+	r := gin.New()
+
+	// Recovery middleware (must be first to handle panics), reporting them to the error tracker
+	r.Use(middleware.Recovery())
+
+	// Request ID middleware (for tracing)
+	r.Use(middleware.RequestID())
+
+	// OpenTelemetry span per request, continuing the caller's traceparent
+	r.Use(middleware.Tracing())
+
+	// Request logging middleware with metrics
+	r.Use(middleware.RequestLogger())
+
+	// Redacted request and response bodies of the routes in REQUEST_AUDIT_ROUTES
+	r.Use(middleware.AuditRequests())
+
+	// Client platform details (for per-device analytics)
+	r.Use(middleware.ClientInfo())
+
+	// Error handling middleware
+	r.Use(middleware.ErrorHandler())
+
+	// Security headers
+	r.Use(middleware.SecurityHeaders())
+
+	// Request size limits (10MB)
+	r.Use(middleware.RequestSizeMiddleware(10 << 20))
+
+	// CORS middleware with environment-based configuration
+	config := cors.DefaultConfig()
+	if os.Getenv("GIN_MODE") == "release" {
+		// Production CORS settings
+		config.AllowOrigins = strings.Split(os.Getenv("ALLOWED_ORIGINS"), ",")
+	} else {
+		// Development CORS settings
+		config.AllowOrigins = []string{"http://localhost:3000", "http://localhost:5173"}
+	}
+	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", middleware.ClientInfoHeader, sessions.CSRFHeader, middleware.CartTokenHeader, tracing.TraceparentHeader}
+	config.ExposeHeaders = []string{
+		handlers.TotalCountHeader,
+		middleware.RateLimitLimitHeader,
+		middleware.RateLimitRemainingHeader,
+		middleware.RateLimitResetHeader,
+		middleware.RateLimitCostHeader,
+		middleware.RetryAfterHeader,
+		middleware.QuotaDailyLimitHeader,
+		middleware.QuotaDailyRemainingHeader,
+		middleware.QuotaMonthlyLimitHeader,
+		middleware.QuotaMonthlyRemainingHeader,
+		middleware.CartTokenHeader,
+	}
+	config.AllowCredentials = true
+	r.Use(cors.New(config))
+
+	// JSON Schemas of the versioned webhook payloads
+	r.GET(events.SchemaPath, handlers.GetEventSchemas)                   // Published event contracts
+	r.GET(events.SchemaPath+"/:event/:version", handlers.GetEventSchema) // Schema of one version
+
+	// Go profiling (admins connecting from DEBUG_ALLOWED_IPS only; audited)
+	debugGroup := r.Group("/debug/pprof")
+	debugGroup.Use(middleware.SupabaseAuthMiddleware(), middleware.DebugAccess())
+	{
+		debugGroup.GET("/*name", handlers.Pprof)  // Index, named profiles, CPU profile (?seconds=) and trace
+		debugGroup.POST("/*name", handlers.Pprof) // Symbol lookups
+	}
+
+	// API routes
+	api := r.Group("/api")
+	{
+		// Public endpoints (no auth required)
+		api.GET("", handlers.GetAPIRoot)                           // Capability document: enabled features, versions, limits, currencies and locales
+		api.GET("/healthz", handlers.HealthCheck)                  // Health check endpoint
+		api.GET("/livez", handlers.Liveness)                       // Liveness probe: the process is up
+		api.GET("/readyz", handlers.Readiness)                     // Readiness probe: database, migrations and signing keys
+		api.GET("/metrics", handlers.BasicMetrics)                 // Basic metrics endpoint
+		api.GET("/metrics/prometheus", handlers.PrometheusMetrics) // Request metrics in the Prometheus text format
+		api.GET("/rate-limits", handlers.GetRateLimits)            // Published rate limits per route group
+		api.GET("/labels", handlers.GetLabels)                     // Status display labels in the negotiated language
+
+		// Payment provider webhooks (authenticated by signature, not rate limited)
+		api.POST("/webhooks/payments", middleware.RequestSizeMiddleware(handlers.MaxWebhookBodySize), handlers.PaymentWebhook)
+
+		// Public endpoints rate limited by IP
+		public := api.Group("")
+		public.Use(middleware.RateLimitByIP("/api/jobs/:id/download"))
+		{
+			// Job result downloads (authorized by signed link)
+			public.GET("/jobs/:id/download", handlers.DownloadJobResult)
+
+			// Pay an order with another card after its payment failed (authorized by signed link)
+			public.GET("/payments/update/:token", handlers.GetPaymentUpdate)
+
+			// Confirm an account erasure request (authorized by the emailed token)
+			public.POST("/account/erasure/confirm", handlers.ConfirmErasureRequest)
+
+			// Sign in as a seeded demo account (DEMO_MODE only)
+			public.POST("/demo/sessions", handlers.StartDemoSession)
+		}
+
+		// Break-glass admin login (local password auth for emergencies; audited, 5 attempts per minute per IP)
+		api.POST("/auth/break-glass",
+			middleware.RateLimitByIPWith("POST /api/auth/break-glass", rate.Every(12*time.Second), 5),
+			handlers.BreakGlassLogin)
+
+		// Read-only catalog for comparison-shopping partners, authenticated by X-API-Key with a
+		// strict per-key rate limit and daily quota (IP-limited first to slow key guessing)
+		partner := api.Group("/partner")
+		partner.Use(middleware.RateLimitByIP("/api/partner/*"))
+		partner.Use(middleware.PartnerAuth())
+		partner.Use(middleware.RateLimitByPartnerWith("/api/partner/*", rate.Every(time.Second), 10))
+		{
+			partner.GET("/catalog", handlers.GetPartnerCatalog) // Catalog with granted fields (?updated_since= or ?cursor= for delta sync)
+			partner.GET("/changes", handlers.GetPartnerChanges) // Feed of product creations, updates, deletions and stock changes (?cursor=)
+		}
+
+		// Guest carts for shoppers who haven't logged in, identified by a signed X-Cart-Token
+		guestCart := api.Group("/guest-cart")
+		guestCart.Use(middleware.RateLimitByIP("/api/guest-cart/*"))
+		guestCart.Use(middleware.GuestCart())
+		{
+			guestCart.GET("", handlers.GetGuestCart)               // Get guest cart (empty without a token)
+			guestCart.POST("", handlers.AddToGuestCart)            // Add item (starts a cart and returns its token)
+			guestCart.PUT("/:id", handlers.UpdateGuestCartItem)    // Update guest cart item quantity
+			guestCart.DELETE("/:id", handlers.RemoveGuestCartItem) // Remove guest cart item
+		}
+
+		// Protected routes (require Supabase Auth), rate limited per user rather than per IP
+		// so buyers sharing an address don't exhaust each other's budget
+		protected := middleware.Guard(api.Group(""))
+		protected.Use(middleware.SupabaseAuthMiddleware())
+		protected.Use(middleware.Authorize()) // Role required by each route's access rule
+		protected.Use(middleware.RateLimitByUser("/api/* (authenticated)"))
+		protected.Use(middleware.EnforceQuota()) // Daily and monthly quotas per role and seller plan
+		protected.Use(middleware.TrackWrites())  // Keeps users' reads on the primary right after they write
+		{
+			// Product routes
+			products := protected.Group("/products")
+			{
+				products.GET("", middleware.Authenticated, middleware.ReadReplica(), handlers.GetProducts)           // List products (filtered by role)
+				products.GET("/search", middleware.Authenticated, middleware.ReadReplica(), handlers.SearchProducts) // Full-text search (?q=)
+				products.POST("", middleware.SellerOwned, handlers.CreateProduct)                                    // Create product (sellers only)
+				products.GET("/:id", middleware.Authenticated, handlers.GetProduct)                                  // Get single product
+				products.GET("/slug/:slug", middleware.Authenticated, handlers.GetProductBySlug)                     // Get single product by slug
+				products.GET("/:id/price-history", middleware.Authenticated, handlers.GetPriceHistory)               // Price changes and 30-day low
+				products.PUT("/:id", middleware.SellerOwned, handlers.UpdateProduct)                                 // Update product (seller's own only)
+				products.DELETE("/:id", middleware.SellerOwned, handlers.DeleteProduct)                              // Delete product (seller's own only)
+				products.POST("/bulk-archive", middleware.SellerOwned, handlers.BulkArchiveProducts)                 // Archive many products (per-ID results; audited)
+				products.POST("/bulk-delete", middleware.SellerOwned, handlers.BulkDeleteProducts)                   // Delete many never-ordered products (per-ID results; audited)
+				products.POST("/:id/images", middleware.SellerOwned,
+					middleware.RequestSizeMiddleware(handlers.MaxProductImageBodySize),
+					handlers.UploadProductImage) // Upload product image (seller's own only)
+
+				// Cross-sells and upsells shown on product detail
+				products.GET("/:id/recommendations", middleware.Authenticated, handlers.GetProductRecommendations)                                 // List recommendations
+				products.PUT("/:id/recommendations", middleware.SellerOrAdmin.OwnedBy(middleware.OwnerSeller), handlers.SetProductRecommendations) // Replace recommendations (seller's own only)
+			}
+
+			// Catalog navigation
+			protected.GET("/categories", middleware.Authenticated, handlers.GetCategories) // List categories with product counts
+			protected.GET("/tags", middleware.Authenticated, handlers.GetTags)             // List tags with product counts
+
+			// Cart routes
+			cart := protected.Group("/cart")
+			{
+				cart.GET("", middleware.OwnRecords, middleware.ReadReplica(), handlers.GetCart)                // Get user's cart (?since=<version> for delta sync)
+				cart.POST("", middleware.OwnRecords, handlers.AddToCart)                                       // Add item to cart
+				cart.POST("/bulk", middleware.OwnRecords, handlers.AddToCartBulk)                              // Add several items at once (per-item results)
+				cart.PUT("/:id", middleware.OwnRecords, handlers.UpdateCartItem)                               // Update cart item quantity
+				cart.DELETE("/:id", middleware.OwnRecords, handlers.RemoveCartItem)                            // Remove cart item
+				cart.DELETE("", middleware.OwnRecords, handlers.ClearCart)                                     // Clear entire cart
+				cart.GET("/count", middleware.OwnRecords, middleware.ReadReplica(), handlers.GetCartCount)     // Get cart item count
+				cart.GET("/summary", middleware.OwnRecords, middleware.ReadReplica(), handlers.GetCartSummary) // Subtotal, tax, shipping and total (server-computed)
+				cart.POST("/merge", middleware.OwnRecords, middleware.GuestCart(), handlers.MergeGuestCart)    // Merge the X-Cart-Token guest cart after login
+				cart.PUT("/:id/save", middleware.OwnRecords, handlers.SaveCartItemForLater)                    // Move cart item to saved items
+				cart.GET("/saved", middleware.OwnRecords, handlers.GetSavedItems)                              // List saved-for-later items
+				cart.POST("/saved/:id/move", middleware.OwnRecords, handlers.MoveSavedItemToCart)              // Move saved item back to the cart
+				cart.DELETE("/saved/:id", middleware.OwnRecords, handlers.RemoveSavedItem)                     // Delete saved item
+
+				cart.GET("/recommendations", middleware.OwnRecords, handlers.GetCartRecommendations) // Cross-sells and upsells for cart and checkout
+
+				cart.POST("/share", middleware.OwnRecords, handlers.ShareCart)                       // Share the cart as a signed, expiring link
+				cart.GET("/shared/:token", middleware.Authenticated, handlers.GetSharedCart)         // Preview a shared cart
+				cart.POST("/shared/:token/import", middleware.OwnRecords, handlers.ImportSharedCart) // Add a shared cart's items to the user's cart
+			}
+
+			// Wishlist routes
+			wishlist := protected.Group("/wishlist")
+			{
+				wishlist.GET("", middleware.OwnRecords, handlers.GetWishlist)               // List wishlist items
+				wishlist.POST("", middleware.OwnRecords, handlers.AddToWishlist)            // Add product (optionally with a price-drop alert)
+				wishlist.PUT("/:id", middleware.OwnRecords, handlers.UpdateWishlistItem)    // Change price alert settings
+				wishlist.DELETE("/:id", middleware.OwnRecords, handlers.RemoveFromWishlist) // Remove wishlist item
+			}
+
+			// Saved searches, optionally alerting when new products match
+			savedSearches := protected.Group("/saved-searches")
+			{
+				savedSearches.GET("", middleware.OwnRecords, handlers.GetSavedSearches)         // List saved searches
+				savedSearches.POST("", middleware.OwnRecords, handlers.CreateSavedSearch)       // Save a query and filters (up to 25)
+				savedSearches.PUT("/:id", middleware.OwnRecords, handlers.UpdateSavedSearch)    // Replace query, filters and alert setting
+				savedSearches.DELETE("/:id", middleware.OwnRecords, handlers.DeleteSavedSearch) // Delete saved search
+			}
+
+			// Recommendation analytics
+			protected.POST("/recommendations/clicks", middleware.Authenticated, handlers.RecordRecommendationClick)                                      // Track a recommendation click
+			protected.GET("/seller/reports/recommendations", middleware.SellerOrAdmin.OwnedBy(middleware.OwnerSeller), handlers.GetRecommendationReport) // Clicks and attaches for own products
+
+			// Checkout routes
+			protected.POST("/checkout", middleware.OwnRecords, handlers.Checkout)                           // Create pending order and reserve stock
+			protected.POST("/checkout/payment-intent", middleware.BuyerOwned, handlers.CreatePaymentIntent) // Create Stripe PaymentIntent for an order
+			protected.POST("/checkout/confirm", middleware.BuyerOwned, handlers.ConfirmPayment)             // Mark order paid once payment succeeded
+			protected.POST("/checkout/store-credit", middleware.BuyerOwned, handlers.ApplyStoreCredit)      // Pay part or all of an order from store credit
+
+			// Store credit and gift cards (redemption limited to 1 per 6s per user, bursts of 5)
+			protected.GET("/store-credit", middleware.OwnRecords, handlers.GetStoreCredit) // Balance and ledger (paginated)
+			protected.POST("/store-credit/redeem", middleware.OwnRecords,
+				middleware.RateLimitByUserWith("POST /api/store-credit/redeem", rate.Every(6*time.Second), 5),
+				handlers.RedeemGiftCard) // Redeem a gift card code into store credit
+
+			// Order routes
+			orders := protected.Group("/orders")
+			{
+				orders.GET("", middleware.BuyerOwned, handlers.GetOrders)                                                    // List buyer's orders (paginated)
+				orders.GET("/:id", middleware.BuyerOwned, handlers.GetOrder)                                                 // Get single order with items
+				orders.GET("/:id/timeline", middleware.BuyerOwned, handlers.GetOrderTimeline)                                // Get order status history
+				orders.POST("/:id/cancel", middleware.BuyerOwned, handlers.CancelOrder)                                      // Cancel before shipment (refunds paid orders)
+				orders.GET("/:id/invoice", middleware.BuyerOwned, handlers.GetOrderInvoice)                                  // PDF invoice of a paid order
+				orders.GET("/:id/refunds", middleware.BuyerOwned, handlers.GetOrderRefunds)                                  // List refunds of an order
+				orders.POST("/:id/refunds", middleware.SellerOrAdmin.OwnedBy(middleware.OwnerSeller), handlers.CreateRefund) // Issue full/partial refund (admins, sellers for own items)
+
+				orders.PUT("/:id/shipping-address", middleware.BuyerOwned, handlers.ChangeOrderAddress)    // Edit the address (sent to support after the edit window)
+				orders.GET("/:id/address-changes", middleware.BuyerOwned, handlers.GetOrderAddressChanges) // Address edits and support requests
+				orders.POST("/:id/seller-ratings", middleware.BuyerOwned, handlers.CreateSellerRating)     // Rate a seller of a delivered order (once per seller)
+			}
+
+			// Seller order management routes
+			sellerOrders := protected.Group("/seller/orders")
+			{
+				sellerOrders.GET("", middleware.SellerOwned, handlers.GetSellerOrders)                    // List order items for seller's products
+				sellerOrders.PUT("/:id/status", middleware.SellerOwned, handlers.UpdateSellerOrderStatus) // Mark an order item shipped/fulfilled
+				sellerOrders.GET("/pick-list", middleware.SellerOwned, handlers.GetPickList)              // Pick list of a day's or a batch's orders (PDF/HTML)
+				sellerOrders.GET("/packing-slips", middleware.SellerOwned, handlers.GetPackingSlips)      // Packing slip per order of a day or batch (PDF/HTML)
+			}
+
+			// Seller profile ratings (published ones only; moderated by admins)
+			protected.GET("/sellers/:id/ratings", middleware.Authenticated, handlers.GetSellerRatings) // Averages and ratings of a seller (paginated)
+
+			// Shipping labels bought through the shop, charged to the seller's payout ledger
+			protected.POST("/seller/shipping-labels", middleware.SellerOwned, handlers.BuyShippingLabels)            // Buy labels for a batch of orders (background job)
+			protected.GET("/seller/shipping-labels", middleware.SellerOwned, handlers.GetShippingLabels)             // List bought labels
+			protected.GET("/seller/shipping-labels/:id/pdf", middleware.SellerOwned, handlers.DownloadShippingLabel) // Download a label PDF
+			protected.GET("/seller/ledger", middleware.SellerOwned, handlers.GetSellerLedger)                        // Payout ledger entries and balances
+
+			// Seller order rules
+			protected.GET("/seller/settings", middleware.SellerOwned, handlers.GetSellerSettings)    // Get minimum order value
+			protected.PUT("/seller/settings", middleware.SellerOwned, handlers.UpdateSellerSettings) // Set minimum order value
+
+			// Product import column mappings and validation preview
+			protected.GET("/seller/import-templates", middleware.SellerOwned, handlers.GetImportTemplates)          // List saved column mappings
+			protected.POST("/seller/import-templates", middleware.SellerOwned, handlers.CreateImportTemplate)       // Save a column mapping
+			protected.PUT("/seller/import-templates/:id", middleware.SellerOwned, handlers.UpdateImportTemplate)    // Rename or change a column mapping
+			protected.DELETE("/seller/import-templates/:id", middleware.SellerOwned, handlers.DeleteImportTemplate) // Delete a column mapping
+			protected.POST("/seller/imports/preview", middleware.SellerOwned,
+				middleware.RequestSizeMiddleware(handlers.MaxImportBodySize),
+				handlers.PreviewImport) // Parse the first rows of a CSV with a mapping, without importing
+
+			// Stock and price sync from an inventory file
+			protected.POST("/seller/inventory/import", middleware.SellerOwned,
+				middleware.RequestSizeMiddleware(handlers.MaxImportBodySize),
+				handlers.ImportInventory) // Set stock and price of many products, reporting each row
+
+			// Seller vacation mode
+			protected.GET("/seller/vacation", middleware.SellerOwned, handlers.GetSellerVacation)    // Get vacation settings
+			protected.PUT("/seller/vacation", middleware.SellerOwned, handlers.SetSellerVacation)    // Schedule vacation (start, end, message, hide listings)
+			protected.DELETE("/seller/vacation", middleware.SellerOwned, handlers.EndSellerVacation) // End or cancel vacation
+
+			// Consent to analytics and marketing, honoured by event recording and marketing exports
+			protected.GET("/consent", middleware.OwnRecords, handlers.GetConsent)     // Current choices
+			protected.POST("/consent", middleware.OwnRecords, handlers.RecordConsent) // Record choices with the policy version shown
+
+			// Account erasure (buyers only): confirmed by email, carried out after a grace period
+			protected.POST("/account/erasure", middleware.Access{Roles: []string{"buyer"}, Owner: middleware.OwnerSelf}, handlers.RequestErasure) // Request erasure and email a confirmation link
+			protected.GET("/account/erasure", middleware.OwnRecords, handlers.GetErasureRequest)                                                  // Latest erasure request
+			protected.DELETE("/account/erasure", middleware.OwnRecords, handlers.CancelErasureRequest)                                            // Cancel before the grace period ends
+
+			// Push notification device routes
+			protected.POST("/push/devices", middleware.OwnRecords, handlers.RegisterDevice)            // Register a device push token
+			protected.DELETE("/push/devices/:token", middleware.OwnRecords, handlers.UnregisterDevice) // Remove a device push token
+
+			// Client error reporting (size-capped and limited to 1 batch per 10s per user, bursts of 5)
+			protected.POST("/client-errors", middleware.Authenticated,
+				middleware.RequestSizeMiddleware(handlers.MaxClientErrorBodySize),
+				middleware.RateLimitByUserWith("POST /api/client-errors", rate.Every(10*time.Second), 5),
+				handlers.ReportClientErrors)
+
+			// Buyer-seller messages about a product or an order, screened by moderation (sending
+			// limited to 1 message per 20s per user, bursts of 10, across both routes)
+			sendMessageLimit := middleware.RateLimitByUserWith("POST /api/messages, /api/messages/threads/:id", rate.Every(20*time.Second), 10)
+			messages := protected.Group("/messages")
+			{
+				messages.POST("", middleware.Access{Roles: []string{"buyer"}, Owner: middleware.OwnerSelf}, sendMessageLimit, handlers.SendMessage) // Message the seller of a product or an order
+				messages.GET("/threads", middleware.OwnRecords, handlers.GetMessageThreads)                                                         // Threads the user takes part in (paginated)
+				messages.GET("/threads/:id", middleware.OwnRecords, handlers.GetMessageThread)                                                      // Thread with its messages
+				messages.POST("/threads/:id", middleware.OwnRecords, sendMessageLimit, handlers.ReplyToMessageThread)                               // Reply as the thread's buyer or seller
+			}
+
+			// Export and job routes
+			protected.POST("/exports", middleware.OwnRecords, handlers.CreateExport) // Start an async export job
+			jobRoutes := protected.Group("/jobs")
+			{
+				jobRoutes.GET("", middleware.OwnRecords, handlers.ListJobs)              // List my jobs (admins: ?all=true)
+				jobRoutes.GET("/:id", middleware.OwnRecords, handlers.GetJob)            // Job status, progress and download link
+				jobRoutes.POST("/:id/cancel", middleware.OwnRecords, handlers.CancelJob) // Cancel a queued or running job
+				jobRoutes.POST("/:id/retry", middleware.OwnRecords, handlers.RetryJob)   // Requeue a failed or cancelled job
+			}
+
+			// Offline sync routes
+			protected.GET("/sync", middleware.OwnRecords, handlers.Sync)           // Change feeds since a sync cursor
+			protected.POST("/sync/cart", middleware.OwnRecords, handlers.SyncCart) // Replay offline cart edits
+
+			// Admin routes
+			admin := protected.Group("/admin")
+			{
+				admin.POST("/bootstrap", middleware.Authenticated, handlers.ClaimAdminBootstrap)        // Claim the first admin with the one-time bootstrap token
+				admin.GET("/audit-log", middleware.AdminOnly, handlers.GetAdminAuditLog)                // Bootstrap and break-glass audit trail
+				admin.PUT("/users/:id/role", middleware.AdminOnly, handlers.UpdateUserRole)             // Change a user's role (audited)
+				admin.POST("/users/:id/revoke-tokens", middleware.AdminOnly, handlers.RevokeUserTokens) // Refuse a user's tokens issued before a time (audited)
+
+				// Security event log (alerted to SECURITY_ALERT_WEBHOOK_URL when high severity)
+				admin.GET("/security-events", middleware.AdminOnly, handlers.GetSecurityEvents)    // List events (?type=&severity=&from=&to=)
+				admin.GET("/security-events/:id", middleware.AdminOnly, handlers.GetSecurityEvent) // Single event
+
+				admin.GET("/request-audit", middleware.AdminOnly, handlers.GetRequestAudits) // Redacted requests to REQUEST_AUDIT_ROUTES (?route=&user_id=&from=&to=)
+
+				admin.GET("/config/effective", middleware.AdminOnly, handlers.GetEffectiveConfig)                     // Effective settings and their sources, secrets masked
+				admin.GET("/debug/runtime", middleware.AdminOnly, middleware.DebugAccess(), handlers.GetRuntimeDebug) // Heap, GC and goroutine stats (?goroutines=true adds a dump)
+
+				admin.PUT("/orders/:id/status", middleware.AdminOnly, handlers.UpdateOrderStatus) // Transition order status
+				admin.GET("/reports/devices", middleware.AdminOnly, handlers.GetDeviceReport)     // Cart/order breakdown by platform
+				admin.GET("/client-errors", middleware.AdminOnly, handlers.GetClientErrors)       // List client error reports
+
+				admin.GET("/reports/recommendations", middleware.AdminOnly, handlers.GetRecommendationReport) // Recommendation clicks and attaches
+				admin.GET("/reports/abandoned-carts", middleware.AdminOnly, handlers.GetAbandonedCarts)       // Carts abandoned after CART_ABANDON_AFTER_DAYS idle
+				admin.GET("/reports/tax", middleware.AdminOnly, handlers.GetTaxReport)                        // Tax collected per jurisdiction and rate (?format=csv)
+
+				// Stock audit and reconciliation
+				admin.POST("/stock-audits", middleware.AdminOnly, handlers.StartStockAudit)                  // Queue a stock audit job (CSV of discrepancies)
+				admin.GET("/products/:id/stock-movements", middleware.AdminOnly, handlers.GetStockMovements) // Stock ledger of a product
+				admin.POST("/products/:id/stock-adjustments", middleware.AdminOnly, handlers.AdjustStock)    // Correct stock with an audited adjustment movement
+
+				// Duplicate listing review queue
+				admin.GET("/duplicate-listings", middleware.AdminOnly, handlers.GetDuplicateListings)               // Probable duplicates found by the background scan
+				admin.POST("/duplicate-listings/:id/review", middleware.AdminOnly, handlers.ReviewDuplicateListing) // Dismiss, or confirm and archive the newer listing
+
+				// Shipping address changes sent to support after the self-service window
+				admin.GET("/address-changes", middleware.AdminOnly, handlers.GetAddressChangeRequests)          // List requests (?status=)
+				admin.POST("/address-changes/:id/resolve", middleware.AdminOnly, handlers.ResolveAddressChange) // Approve (applies the address) or reject
+
+				// Comparison-shopping partner API keys
+				admin.GET("/partner-keys", middleware.AdminOnly, handlers.GetPartnerAPIKeys)          // List partner keys (secrets are never shown again)
+				admin.POST("/partner-keys", middleware.AdminOnly, handlers.CreatePartnerAPIKey)       // Issue a key with granted fields and daily quota
+				admin.DELETE("/partner-keys/:id", middleware.AdminOnly, handlers.RevokePartnerAPIKey) // Revoke a key immediately
+
+				admin.POST("/gift-cards", middleware.AdminOnly, handlers.CreateGiftCard) // Issue a gift card (the code is shown once)
+
+				// Payment disputes (chargebacks) reported by the payment webhook
+				admin.GET("/disputes", middleware.AdminOnly, handlers.GetDisputes)                        // List disputes (?state=open|closed)
+				admin.GET("/disputes/:id", middleware.AdminOnly, handlers.GetDispute)                     // Dispute with its order's payout holds
+				admin.PUT("/disputes/:id/evidence", middleware.AdminOnly, handlers.SubmitDisputeEvidence) // Stage or submit evidence to the card issuer
+
+				// Buyer-seller message threads, for resolving disputes
+				admin.GET("/message-threads", middleware.AdminOnly, handlers.GetAdminMessageThreads)    // List threads (?order_id=&user_id=&flagged=true)
+				admin.GET("/message-threads/:id", middleware.AdminOnly, handlers.GetAdminMessageThread) // Any thread with its messages
+
+				// Seller ratings moderation and the seller quality score
+				admin.GET("/seller-ratings", middleware.AdminOnly, handlers.GetAdminSellerRatings)              // List ratings (?seller_id=&status=&flagged=true)
+				admin.POST("/seller-ratings/:id/moderate", middleware.AdminOnly, handlers.ModerateSellerRating) // Hide or restore a rating (audited)
+				admin.GET("/sellers/quality", middleware.AdminOnly, handlers.GetSellerQuality)                  // Quality scores, lowest first (?days=)
+
+				// Provider payouts reconciled against payments and refunds
+				admin.GET("/payouts", middleware.AdminOnly, handlers.GetPayoutReconciliations)                 // Checked payouts (?status=blocked|reconciled|resolved)
+				admin.GET("/payouts/:id", middleware.AdminOnly, handlers.GetPayoutReconciliation)              // Payout with the mismatches of its latest check
+				admin.POST("/payouts/:id/resolve", middleware.AdminOnly, handlers.ResolvePayoutReconciliation) // Release a blocked payout (audited)
+
+				// Monthly financial summaries; closing a month freezes its orders and refunds to non-admins
+				admin.GET("/finance/:period", middleware.AdminOnly, handlers.GetFinancialSummary)         // Gross sales, refunds, fees and net of a month
+				admin.POST("/finance/:period/close", middleware.AdminOnly, handlers.CloseFinancialPeriod) // Close an ended month (audited)
+
+				// Account erasure requests of buyers
+				admin.GET("/erasure-requests", middleware.AdminOnly, handlers.GetErasureRequests) // List requests (?status=, default scheduled)
+
+				// Catalog taxonomy
+				admin.POST("/categories", middleware.AdminOnly, handlers.CreateCategory)       // Create category
+				admin.PUT("/categories/:id", middleware.AdminOnly, handlers.UpdateCategory)    // Rename category
+				admin.DELETE("/categories/:id", middleware.AdminOnly, handlers.DeleteCategory) // Delete category (products become uncategorized)
+				admin.POST("/tags", middleware.AdminOnly, handlers.CreateTag)                  // Create tag
+				admin.PUT("/tags/:id", middleware.AdminOnly, handlers.UpdateTag)               // Rename tag
+				admin.DELETE("/tags/:id", middleware.AdminOnly, handlers.DeleteTag)            // Delete tag (detached from products)
+
+				// Dead-letter queue (source: jobs or webhooks)
+				admin.GET("/dead-letters/:source", middleware.AdminOnly, handlers.GetDeadLetters)              // List failed items
+				admin.GET("/dead-letters/:source/:id", middleware.AdminOnly, handlers.GetDeadLetter)           // Payload and error history
+				admin.POST("/dead-letters/:source/requeue", middleware.AdminOnly, handlers.RequeueDeadLetters) // Retry items by ID
+				admin.POST("/dead-letters/:source/discard", middleware.AdminOnly, handlers.DiscardDeadLetters) // Drop items by ID
+			}
+
+			// User routes
+			protected.GET("/user", middleware.OwnRecords, handlers.GetUserInfo)    // Get authenticated user info
+			protected.GET("/user/quota", middleware.OwnRecords, handlers.GetQuota) // Quota tier and daily/monthly usage
+
+			// Cookie sessions for the web storefront (when SESSION_MODE=cookie)
+			protected.POST("/auth/session", middleware.OwnRecords, handlers.CreateSession)   // Exchange the Bearer JWT for a session cookie
+			protected.GET("/auth/session", middleware.OwnRecords, handlers.GetSession)       // CSRF token and expiry of the current session
+			protected.DELETE("/auth/session", middleware.OwnRecords, handlers.DeleteSession) // Sign out and clear the cookie
+		}
+	}
+
+	return r
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"secure-backend/database"
+	"secure-backend/services"
+)
+
+// runSeed handles the seed subcommand, which fills a database for local development or demos
+// with the demo accounts and catalog plus generated buyers with carts and sellers with
+// products:
+//
+//	main seed [-buyers 20] [-sellers 5] [-products 12] [-random-seed 1] [-force]
+//
+// Like the nightly demo reset it replaces the accounts on the demo email domain and
+// everything they own, leaving other data alone. A database with other accounts is
+// refused without -force, as a guard against seeding production.
+func runSeed(args []string) {
+	opts := services.DefaultSeedOptions
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	fs.IntVar(&opts.Buyers, "buyers", opts.Buyers, "generated buyers, each with up to four cart items")
+	fs.IntVar(&opts.Sellers, "sellers", opts.Sellers, "generated sellers")
+	fs.IntVar(&opts.ProductsPerSeller, "products", opts.ProductsPerSeller, "products per generated seller")
+	fs.Int64Var(&opts.RandomSeed, "random-seed", opts.RandomSeed, "seed of the generator; the same seed generates the same data")
+	force := fs.Bool("force", false, "seed even if the database has accounts outside the demo email domain")
+	fs.Parse(args)
+	if opts.Buyers < 0 || opts.Sellers < 0 || opts.ProductsPerSeller < 0 || fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "usage: main seed [-buyers n] [-sellers n] [-products n] [-random-seed n] [-force]")
+		os.Exit(2)
+	}
+
+	if os.Getenv("DATABASE_URL") == "" {
+		log.Fatal("DATABASE_URL environment variable is required")
+	}
+	if err := database.InitDB(); err != nil {
+		log.Fatal("Failed to initialize database:", err)
+	}
+	defer database.DB.Close()
+	ctx := context.Background()
+
+	// A fresh development database needs the schema first
+	if database.AutoMigrate() {
+		if _, err := database.Migrate(ctx); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+	}
+
+	others, err := database.CountNonDemoUsers(ctx)
+	if err != nil {
+		log.Fatalf("Failed to count accounts: %v", err)
+	}
+	if others > 0 && !*force {
+		log.Fatalf("The database has %d accounts outside the demo email domain; rerun with -force to seed it anyway", others)
+	}
+
+	seed, err := services.SeedDevData(ctx, opts)
+	if err != nil {
+		log.Fatalf("Seeding failed: %v", err)
+	}
+	fmt.Printf("Seeded %d users, %d products, %d orders and %d cart items\n",
+		len(seed.Users), len(seed.Products), len(seed.Orders), len(seed.CartItems))
+	fmt.Println("Start the API with DEMO_MODE=true to sign in as the demo accounts through POST /api/demo/sessions")
+}
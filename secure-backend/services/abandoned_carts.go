@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"log"
+	"os"
+	"secure-backend/database"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultCartAbandonAfterDays is how long a cart may sit idle before it counts as abandoned
+	defaultCartAbandonAfterDays = 7
+	// cartAbandonBatch caps how many carts are marked abandoned per sweep
+	cartAbandonBatch = 500
+)
+
+// CartAbandonAfter returns how long a cart may sit idle before it is abandoned,
+// configurable via CART_ABANDON_AFTER_DAYS
+func CartAbandonAfter() time.Duration {
+	days := defaultCartAbandonAfterDays
+	if value := os.Getenv("CART_ABANDON_AFTER_DAYS"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			days = parsed
+		} else {
+			log.Printf("Invalid CART_ABANDON_AFTER_DAYS %q, using %d", value, defaultCartAbandonAfterDays)
+		}
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// releaseAbandonedStock reports whether CART_ABANDON_RELEASE_STOCK asks for the unpaid
+// checkouts of abandoned carts to be cancelled, returning their reserved stock
+func releaseAbandonedStock() bool {
+	release, _ := strconv.ParseBool(os.Getenv("CART_ABANDON_RELEASE_STOCK"))
+	return release
+}
+
+// AbandonIdleCarts marks carts idle for longer than CartAbandonAfter as abandoned and
+// records an abandonment event for each, optionally cancelling their unpaid checkouts.
+// It returns how many carts were abandoned.
+func AbandonIdleCarts(ctx context.Context) (int, error) {
+	now := clk.Now()
+	abandonments, err := database.MarkAbandonedCarts(ctx, now.Add(-CartAbandonAfter()), now, cartAbandonBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	if releaseAbandonedStock() {
+		for _, abandonment := range abandonments {
+			orderIDs, err := database.GetPendingCheckoutOrders(ctx, abandonment.UserID)
+			if err != nil {
+				log.Printf("Failed to load unpaid checkouts of abandoned cart %s: %v", abandonment.ID, err)
+				continue
+			}
+
+			released := 0
+			for _, orderID := range orderIDs {
+				if expireCheckout(ctx, orderID) {
+					released++
+				}
+			}
+			if released == 0 {
+				continue
+			}
+			if err := database.SetCartAbandonmentReleasedOrders(ctx, abandonment.ID, released); err != nil {
+				log.Printf("Failed to record released checkouts of abandoned cart %s: %v", abandonment.ID, err)
+			}
+		}
+	}
+
+	return len(abandonments), nil
+}
+
+// StartAbandonedCartReaper periodically marks idle carts as abandoned until ctx is cancelled
+func StartAbandonedCartReaper(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				abandoned, err := AbandonIdleCarts(ctx)
+				if err != nil {
+					log.Printf("Failed to mark abandoned carts: %v", err)
+				} else if abandoned > 0 {
+					log.Printf("Marked %d idle carts as abandoned", abandoned)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,120 @@
+//go:build e2e
+
+// Abandoned cart tests against a real PostgreSQL database (with database/schema.sql applied):
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run TestAbandonIdleCarts ./services
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/money"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAbandonIdleCarts(t *testing.T) {
+	f := newCheckoutFixture(t, 10)
+	t.Setenv("CART_ABANDON_AFTER_DAYS", "7")
+	t.Setenv("CART_ABANDON_RELEASE_STOCK", "true")
+	ctx := context.Background()
+
+	users := createTestUsers(t, "abandon", "buyer", "buyer")
+	shopper, browser := users[0], users[1]
+	userIDs := []string{f.buyer.ID, shopper.ID, browser.ID}
+
+	// The fixture buyer is left with an unpaid checkout, the shopper with items in the
+	// cart and the browser with a cart that was emptied again
+	order := f.checkout(t, 2)
+	_, err := database.AddToCart(ctx, shopper.ID, f.productID, 3)
+	require.NoError(t, err)
+	item, err := database.AddToCart(ctx, browser.ID, f.productID, 1)
+	require.NoError(t, err)
+	require.NoError(t, database.RemoveFromCart(ctx, item.ID, browser.ID))
+
+	idle := func(days int) {
+		t.Helper()
+		_, err := database.DB.ExecContext(ctx, `
+			UPDATE cart_versions SET updated_at = now() - make_interval(days => $2) WHERE user_id = ANY($1)
+		`, pq.Array(userIDs), days)
+		require.NoError(t, err)
+	}
+	abandonments := func() map[string][]models.CartAbandonment {
+		t.Helper()
+		var rows []models.CartAbandonment
+		require.NoError(t, database.DB.SelectContext(ctx, &rows, `
+			SELECT id, user_id, cart_version, item_count, subtotal, last_activity_at, released_orders, notified_at, created_at
+			FROM cart_abandonments WHERE user_id = ANY($1) ORDER BY cart_version
+		`, pq.Array(userIDs)))
+		byUser := make(map[string][]models.CartAbandonment)
+		for _, row := range rows {
+			byUser[row.UserID] = append(byUser[row.UserID], row)
+		}
+		return byUser
+	}
+
+	// Carts idle for less than the configured days are left alone
+	idle(6)
+	_, err = AbandonIdleCarts(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, abandonments())
+
+	// Idle carts with items or an unpaid checkout are abandoned, releasing the checkout's stock
+	idle(8)
+	abandoned, err := AbandonIdleCarts(ctx)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, abandoned, 2)
+	byUser := abandonments()
+	assert.NotContains(t, byUser, browser.ID, "an empty cart isn't abandoned")
+	require.Len(t, byUser[shopper.ID], 1)
+	assert.Equal(t, 3, byUser[shopper.ID][0].ItemCount)
+	assert.Equal(t, money.FromFloat(15), byUser[shopper.ID][0].Subtotal)
+	assert.Zero(t, byUser[shopper.ID][0].ReleasedOrders)
+	require.Len(t, byUser[f.buyer.ID], 1)
+	assert.Zero(t, byUser[f.buyer.ID][0].ItemCount)
+	assert.Equal(t, 1, byUser[f.buyer.ID][0].ReleasedOrders)
+	f.assertOrder(t, order.ID, OrderStatusCancelled, database.ReservationReleased)
+	assert.Equal(t, 10, f.stock(t), "the unpaid checkout's units are back in stock")
+
+	// A cart is abandoned once until it changes again
+	_, err = AbandonIdleCarts(ctx)
+	require.NoError(t, err)
+	assert.Len(t, abandonments()[shopper.ID], 1)
+
+	_, err = database.AddToCart(ctx, shopper.ID, f.productID, 1)
+	require.NoError(t, err)
+	idle(8)
+	_, err = AbandonIdleCarts(ctx)
+	require.NoError(t, err)
+	byUser = abandonments()
+	require.Len(t, byUser[shopper.ID], 2)
+	assert.Greater(t, byUser[shopper.ID][1].CartVersion, byUser[shopper.ID][0].CartVersion)
+	assert.Equal(t, 4, byUser[shopper.ID][1].ItemCount)
+	assert.Len(t, byUser[f.buyer.ID], 1, "an unchanged cart stays abandoned")
+
+	// The report marks who may be emailed, and can leave out everyone else
+	require.NoError(t, database.RecordConsent(ctx, &models.Consent{UserID: shopper.ID, Marketing: true, PolicyVersion: "test"}))
+	report := func(marketingOnly bool) map[string]bool {
+		t.Helper()
+		rows, _, err := database.GetCartAbandonments(ctx, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), marketingOnly, 500, 0)
+		require.NoError(t, err)
+		consent := make(map[string]bool)
+		for _, row := range rows {
+			consent[row.UserID] = row.MarketingConsent
+		}
+		return consent
+	}
+	all := report(false)
+	assert.True(t, all[shopper.ID])
+	assert.Contains(t, all, f.buyer.ID)
+	assert.False(t, all[f.buyer.ID])
+	marketing := report(true)
+	assert.Contains(t, marketing, shopper.ID)
+	assert.NotContains(t, marketing, f.buyer.ID)
+}
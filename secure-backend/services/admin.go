@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/tokens"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// BootstrapTokenTTL is how long a printed admin bootstrap token can be claimed
+	BootstrapTokenTTL = 24 * time.Hour
+	// BreakGlassTokenTTL is how long a break-glass login stays valid
+	BreakGlassTokenTTL = time.Hour
+	// minBreakGlassPasswordLength applies to passwords supplied to ProvisionBreakGlassAdmin
+	minBreakGlassPasswordLength = 16
+)
+
+// ErrInvalidCredentials is returned for any failed break-glass login, so callers can't tell
+// unknown accounts from wrong passwords
+var ErrInvalidCredentials = errors.New("invalid email or password")
+
+// dummyPasswordHash is compared against when the account doesn't exist, so failed logins
+// take as long whether or not the email is a break-glass account
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("break-glass-dummy-password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// EnsureAdminBootstrap prints a one-time bootstrap token at startup while no admin exists,
+// so the first admin can be created without editing the database. A live token from an
+// earlier startup is left alone (only its hash is stored); reissue it with cmd/admin.
+func EnsureAdminBootstrap(ctx context.Context) error {
+	exists, err := database.AdminExists(ctx)
+	if err != nil || exists {
+		return err
+	}
+
+	live, err := database.HasLiveBootstrapToken(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	if live {
+		log.Printf("No admin account exists; an unused bootstrap token was already issued (run `go run ./cmd/admin bootstrap-token` to issue a new one)")
+		return nil
+	}
+
+	token, expiresAt, err := IssueBootstrapToken(ctx)
+	if err != nil {
+		return err
+	}
+	log.Printf("No admin account exists. Claim admin by signing in and calling POST /api/admin/bootstrap with this one-time token (expires %s): %s",
+		expiresAt.Format(time.RFC3339), token)
+	return nil
+}
+
+// IssueBootstrapToken creates a new admin bootstrap token, revoking unused ones
+func IssueBootstrapToken(ctx context.Context) (string, time.Time, error) {
+	token, err := randomSecret(32)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(BootstrapTokenTTL)
+	if err := database.CreateBootstrapToken(ctx, hashSecret(token), expiresAt, now); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// ClaimAdminBootstrap makes the user the first admin if token is the live bootstrap token.
+// Claims and attempts with a wrong token are recorded as high-severity security events.
+func ClaimAdminBootstrap(ctx context.Context, token string, user *models.AuthUser, ipAddress string) error {
+	err := database.ClaimAdminBootstrap(ctx, hashSecret(strings.TrimSpace(token)), user, ipAddress, time.Now())
+	switch {
+	case errors.Is(err, database.ErrInvalidBootstrapToken):
+		RecordSecurityEvent(ctx, &models.SecurityEvent{
+			Type:      models.SecurityAdminLoginFailed,
+			Severity:  models.SeverityHigh,
+			UserID:    &user.ID,
+			IPAddress: ipAddress,
+			Detail:    fmt.Sprintf("invalid admin bootstrap token presented by %s", user.Email),
+		})
+	case err == nil:
+		RecordSecurityEvent(ctx, &models.SecurityEvent{
+			Type:      models.SecurityRoleChanged,
+			Severity:  models.SeverityHigh,
+			UserID:    &user.ID,
+			IPAddress: ipAddress,
+			Detail:    fmt.Sprintf("%s claimed the admin bootstrap token and became admin", user.Email),
+		})
+	}
+	return err
+}
+
+// ProvisionBreakGlassAdmin creates a break-glass admin (or resets its password). When password
+// is empty a random one is generated; the password is returned so it can be printed once and
+// sealed away.
+func ProvisionBreakGlassAdmin(ctx context.Context, email, password string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return "", errors.New("email is required")
+	}
+
+	if password == "" {
+		generated, err := randomSecret(24)
+		if err != nil {
+			return "", err
+		}
+		password = generated
+	} else if len(password) < minBreakGlassPasswordLength {
+		return "", errors.New("break-glass passwords must be at least 16 characters")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := database.UpsertBreakGlassAdmin(ctx, email, string(hash)); err != nil {
+		return "", err
+	}
+	return password, nil
+}
+
+// DisableBreakGlassAdmin turns a break-glass account off; its tokens stop working immediately
+func DisableBreakGlassAdmin(ctx context.Context, email string) error {
+	return database.DisableBreakGlassAdmin(ctx, strings.ToLower(strings.TrimSpace(email)))
+}
+
+// BreakGlassLogin checks a break-glass account's password and issues a short-lived Bearer
+// token for it. Every attempt is audited with the stated reason; a successful login is
+// refused if it can't be audited.
+func BreakGlassLogin(ctx context.Context, email, password, reason, ipAddress string) (string, time.Time, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	user, err := database.GetBreakGlassUser(ctx, email)
+	if err != nil && err != sql.ErrNoRows {
+		return "", time.Time{}, err
+	}
+
+	hash := dummyPasswordHash()
+	if user != nil && user.Password != "" {
+		hash = []byte(user.Password)
+	}
+	matched := bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+	if user == nil || user.Password == "" || !matched {
+		entry := &models.AuditEntry{
+			ActorEmail: email,
+			Action:     models.AuditBreakGlassLoginFailed,
+			Detail:     reason,
+			IPAddress:  ipAddress,
+		}
+		if user != nil {
+			entry.ActorID = &user.ID
+		}
+		if err := database.RecordAdminAudit(ctx, entry); err != nil {
+			log.Printf("Failed to audit break-glass login failure for %s: %v", email, err)
+		}
+		RecordSecurityEvent(ctx, &models.SecurityEvent{
+			Type:      models.SecurityAdminLoginFailed,
+			Severity:  models.SeverityHigh,
+			UserID:    entry.ActorID,
+			IPAddress: ipAddress,
+			Detail:    fmt.Sprintf("failed break-glass login for %q", email),
+		})
+		return "", time.Time{}, ErrInvalidCredentials
+	}
+
+	err = database.RecordAdminAudit(ctx, &models.AuditEntry{
+		ActorID:    &user.ID,
+		ActorEmail: user.Email,
+		Action:     models.AuditBreakGlassLogin,
+		Detail:     reason,
+		IPAddress:  ipAddress,
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := time.Now().Add(BreakGlassTokenTTL)
+	token, err := tokens.Sign(tokens.PurposeBreakGlass, user.ID, expiresAt)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// randomSecret returns n random bytes, hex encoded
+func randomSecret(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashSecret returns the SHA-256 hex digest stored in place of a one-time token
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,25 @@
+package services
+
+import (
+	"log"
+	"os"
+	"strconv"
+)
+
+// defaultCartMaxUnits is the cart size limit used when CART_MAX_UNITS is not set
+const defaultCartMaxUnits = 500
+
+// CartMaxUnits returns the most units a cart can hold in total, from CART_MAX_UNITS
+// (0 disables the limit)
+func CartMaxUnits() int {
+	value := os.Getenv("CART_MAX_UNITS")
+	if value == "" {
+		return defaultCartMaxUnits
+	}
+	units, err := strconv.Atoi(value)
+	if err != nil || units < 0 {
+		log.Printf("Invalid CART_MAX_UNITS %q, using %d", value, defaultCartMaxUnits)
+		return defaultCartMaxUnits
+	}
+	return units
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"secure-backend/clock"
+	"secure-backend/database"
+	"secure-backend/invoices"
+	"secure-backend/models"
+	"time"
+)
+
+const (
+	// defaultReservationTTL is how long stock is held for an unpaid order
+	defaultReservationTTL = 15 * time.Minute
+	// reservationReapBatch caps how many expired checkouts are processed per sweep
+	reservationReapBatch = 100
+)
+
+// clk decides when reservations have expired; replaced in tests
+var clk clock.Clock = clock.System()
+
+// SetClock replaces the clock used for reservation expiry
+func SetClock(c clock.Clock) {
+	clk = c
+}
+
+// ReservationTTL returns how long checkout holds stock, configurable via CHECKOUT_RESERVATION_TTL (e.g. "15m")
+func ReservationTTL() time.Duration {
+	if value := os.Getenv("CHECKOUT_RESERVATION_TTL"); value != "" {
+		if ttl, err := time.ParseDuration(value); err == nil && ttl > 0 {
+			return ttl
+		}
+		log.Printf("Invalid CHECKOUT_RESERVATION_TTL %q, using %s", value, defaultReservationTTL)
+	}
+	return defaultReservationTTL
+}
+
+// Checkout turns the buyer's cart into a pending order and reserves its stock until the order
+// is paid or the reservation expires. The tax included in each item is recorded for tax reports.
+func Checkout(ctx context.Context, buyer *models.AuthUser, client *models.ClientInfo, shippingAddress string) (*models.Order, []models.StockReservation, error) {
+	return database.CreateOrderFromCart(ctx, database.CheckoutRequest{
+		BuyerID:         buyer.ID,
+		ShippingAddress: shippingAddress,
+		ClientPlatform:  client.Platform,
+		ReservationTTL:  ReservationTTL(),
+		TaxRate:         invoices.TaxRate(),
+		TaxJurisdiction: invoices.TaxJurisdiction(),
+	})
+}
+
+// ReleaseExpiredReservations cancels pending orders whose reservation expired and returns their stock
+func ReleaseExpiredReservations(ctx context.Context) (int, error) {
+	orderIDs, err := database.GetExpiredReservationOrders(ctx, clk.Now(), reservationReapBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	released := 0
+	for _, orderID := range orderIDs {
+		if expireCheckout(ctx, orderID) {
+			released++
+		}
+	}
+
+	return released, nil
+}
+
+// expireCheckout cancels a pending order, returns its reserved stock and notifies the buyer.
+// It reports whether the order was cancelled; failures are logged.
+func expireCheckout(ctx context.Context, orderID string) bool {
+	order, err := database.GetOrderByID(ctx, orderID)
+	if err != nil {
+		log.Printf("Failed to load expired order %s: %v", orderID, err)
+		return false
+	}
+
+	err = database.ExpireCheckout(ctx, orderID)
+	if err == sql.ErrNoRows {
+		// Paid or cancelled concurrently
+		return false
+	} else if err != nil {
+		log.Printf("Failed to release reservation for order %s: %v", orderID, err)
+		return false
+	}
+
+	notifyOrderStatus(order.UserID, &models.OrderStatusChange{
+		OrderID:    orderID,
+		FromStatus: OrderStatusPending,
+		ToStatus:   OrderStatusCancelled,
+	})
+	return true
+}
+
+// StartReservationReaper periodically releases expired stock reservations until ctx is cancelled
+func StartReservationReaper(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				released, err := ReleaseExpiredReservations(ctx)
+				if err != nil {
+					log.Printf("Failed to release expired reservations: %v", err)
+				} else if released > 0 {
+					log.Printf("Released stock for %d expired checkouts", released)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,190 @@
+//go:build e2e
+
+// Checkout reservation tests against a real PostgreSQL database (with database/schema.sql applied):
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run 'TestReservation|TestCancelOrder' ./services
+package services
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"secure-backend/clock"
+	"secure-backend/database"
+	"secure-backend/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// checkoutFixture is a buyer and a published product with some stock, and a mock clock
+// deciding when reservations expire
+type checkoutFixture struct {
+	clock     *clock.Mock
+	admin     *models.AuthUser
+	buyer     *models.AuthUser
+	productID string
+}
+
+func newCheckoutFixture(t *testing.T, stock int) *checkoutFixture {
+	t.Helper()
+	initTestDB(t)
+	t.Setenv("CHECKOUT_RESERVATION_TTL", "10m")
+
+	mock := clock.NewMock(time.Now())
+	SetClock(mock)
+	database.SetClock(mock)
+	t.Cleanup(func() {
+		SetClock(clock.System())
+		database.SetClock(clock.System())
+	})
+
+	users := createTestUsers(t, "reserve", "seller", "admin", "buyer")
+	seller := users[0]
+	f := &checkoutFixture{clock: mock, admin: users[1], buyer: users[2]}
+	t.Cleanup(func() {
+		database.DB.ExecContext(context.Background(), `DELETE FROM orders WHERE buyer_id = $1`, f.buyer.ID)
+	})
+	require.NoError(t, database.DB.GetContext(context.Background(), &f.productID, `
+		INSERT INTO products (name, price, stock, status, seller_id) VALUES ('Reserved product', 5, $1, 'published', $2) RETURNING id
+	`, stock, seller.ID))
+	return f
+}
+
+// checkout puts quantity units of the product in the buyer's cart and checks out
+func (f *checkoutFixture) checkout(t *testing.T, quantity int) *models.Order {
+	t.Helper()
+	_, err := database.AddToCart(context.Background(), f.buyer.ID, f.productID, quantity)
+	require.NoError(t, err)
+	order, reservations, err := Checkout(context.Background(), f.buyer, &models.ClientInfo{Platform: "web"}, "")
+	require.NoError(t, err)
+	require.Len(t, reservations, 1)
+	assert.Equal(t, database.ReservationActive, reservations[0].Status)
+	assert.WithinDuration(t, f.clock.Now().Add(10*time.Minute), reservations[0].ExpiresAt, time.Second)
+	return order
+}
+
+func (f *checkoutFixture) stock(t *testing.T) int {
+	t.Helper()
+	product, err := database.GetProductByID(context.Background(), f.productID)
+	require.NoError(t, err)
+	return product.Stock
+}
+
+func (f *checkoutFixture) assertOrder(t *testing.T, orderID, status, reservationStatus string) {
+	t.Helper()
+	order, err := database.GetOrderByID(context.Background(), orderID)
+	require.NoError(t, err)
+	assert.Equal(t, status, order.Status)
+	reservations, err := database.GetOrderReservations(context.Background(), orderID)
+	require.NoError(t, err)
+	require.Len(t, reservations, 1)
+	assert.Equal(t, reservationStatus, reservations[0].Status)
+}
+
+func TestReservationExpiryReleasesStock(t *testing.T) {
+	f := newCheckoutFixture(t, 5)
+	ctx := context.Background()
+
+	order := f.checkout(t, 3)
+	assert.Equal(t, 2, f.stock(t), "checkout holds the ordered units")
+
+	// Nothing is released before the reservation expires
+	f.clock.Advance(9 * time.Minute)
+	_, err := ReleaseExpiredReservations(ctx)
+	require.NoError(t, err)
+	f.assertOrder(t, order.ID, OrderStatusPending, database.ReservationActive)
+	assert.Equal(t, 2, f.stock(t))
+
+	f.clock.Advance(time.Minute)
+	_, err = ReleaseExpiredReservations(ctx)
+	require.NoError(t, err)
+	f.assertOrder(t, order.ID, OrderStatusCancelled, database.ReservationReleased)
+	assert.Equal(t, 5, f.stock(t), "the held units are back in stock")
+
+	history, err := database.GetOrderStatusHistory(ctx, order.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, SystemActorRole, history[0].ActorRole)
+	assert.Equal(t, OrderStatusCancelled, history[0].ToStatus)
+
+	// A second sweep or a late expiry doesn't return the stock twice
+	_, err = ReleaseExpiredReservations(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, sql.ErrNoRows, database.ExpireCheckout(ctx, order.ID))
+	assert.Equal(t, 5, f.stock(t))
+
+	var released int
+	require.NoError(t, database.DB.GetContext(ctx, &released, `
+		SELECT COALESCE(SUM(quantity), 0) FROM stock_movements WHERE order_id = $1 AND reason = 'release'
+	`, order.ID))
+	assert.Equal(t, 3, released, "the release is in the stock ledger once")
+}
+
+func TestReservationCommittedOnPayment(t *testing.T) {
+	f := newCheckoutFixture(t, 5)
+	ctx := context.Background()
+
+	order := f.checkout(t, 2)
+	_, err := TransitionOrder(ctx, order.ID, OrderStatusPaid, nil, "")
+	require.NoError(t, err)
+	f.assertOrder(t, order.ID, OrderStatusPaid, database.ReservationCommitted)
+
+	// A paid order keeps its stock after the reservation would have expired
+	f.clock.Advance(time.Hour)
+	_, err = ReleaseExpiredReservations(ctx)
+	require.NoError(t, err)
+	f.assertOrder(t, order.ID, OrderStatusPaid, database.ReservationCommitted)
+	assert.Equal(t, 3, f.stock(t))
+}
+
+func TestCancelOrderRestocks(t *testing.T) {
+	f := newCheckoutFixture(t, 5)
+	ctx := context.Background()
+
+	pending := f.checkout(t, 1)
+	paid := f.checkout(t, 2)
+	_, err := TransitionOrder(ctx, paid.ID, OrderStatusPaid, nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, 2, f.stock(t))
+
+	// Cancelling an unpaid order releases its reservation
+	_, err = TransitionOrder(ctx, pending.ID, OrderStatusCancelled, f.buyer, "")
+	require.NoError(t, err)
+	f.assertOrder(t, pending.ID, OrderStatusCancelled, database.ReservationReleased)
+	assert.Equal(t, 3, f.stock(t))
+
+	// So does cancelling a paid order before it ships
+	_, err = TransitionOrder(ctx, paid.ID, OrderStatusCancelled, f.admin, "")
+	require.NoError(t, err)
+	f.assertOrder(t, paid.ID, OrderStatusCancelled, database.ReservationReleased)
+	assert.Equal(t, 5, f.stock(t))
+
+	// Cancelled orders can't be cancelled again, and expiring them changes nothing
+	_, err = TransitionOrder(ctx, paid.ID, OrderStatusCancelled, f.admin, "")
+	assert.ErrorIs(t, err, ErrInvalidTransition)
+	f.clock.Advance(time.Hour)
+	_, err = ReleaseExpiredReservations(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 5, f.stock(t))
+}
+
+func TestCancelOrderRefusedAfterShipping(t *testing.T) {
+	f := newCheckoutFixture(t, 5)
+	ctx := context.Background()
+
+	order := f.checkout(t, 2)
+	_, err := TransitionOrder(ctx, order.ID, OrderStatusPaid, nil, "")
+	require.NoError(t, err)
+
+	// The seller shipped the item while the order itself is still paid
+	_, err = database.DB.ExecContext(ctx, `UPDATE order_items SET fulfillment_status = 'shipped' WHERE order_id = $1`, order.ID)
+	require.NoError(t, err)
+
+	_, err = TransitionOrder(ctx, order.ID, OrderStatusCancelled, f.buyer, "")
+	assert.ErrorIs(t, err, database.ErrFulfillmentStarted)
+	f.assertOrder(t, order.ID, OrderStatusPaid, database.ReservationCommitted)
+	assert.Equal(t, 3, f.stock(t), "the shipped units aren't restocked")
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"os"
+	"secure-backend/database"
+	"secure-backend/models"
+	"sync"
+)
+
+// warnUnkeyedIPHash logs once that consent IP hashes aren't keyed
+var warnUnkeyedIPHash sync.Once
+
+// RecordConsent stores a user's consent choices under the policy version they were shown.
+// The IP address is kept only as a hash, as proof of the request.
+func RecordConsent(ctx context.Context, userID string, analytics, marketing bool, policyVersion, ip string) (*models.Consent, error) {
+	consent := &models.Consent{
+		UserID:        userID,
+		Analytics:     analytics,
+		Marketing:     marketing,
+		PolicyVersion: policyVersion,
+		IPHash:        hashIP(ip),
+	}
+	if err := database.RecordConsent(ctx, consent); err != nil {
+		return nil, err
+	}
+	return consent, nil
+}
+
+// hashIP hashes an IP address with HMAC-SHA256 keyed by IP_HASH_SECRET, so stored hashes
+// can't be reversed by hashing every address. Without the secret a plain SHA-256 is used.
+func hashIP(ip string) string {
+	secret := os.Getenv("IP_HASH_SECRET")
+	if secret == "" {
+		warnUnkeyedIPHash.Do(func() {
+			log.Printf("IP_HASH_SECRET not set; consent IP addresses are hashed without a key")
+		})
+		sum := sha256.Sum256([]byte(ip))
+		return hex.EncodeToString(sum[:])
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,21 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashIP(t *testing.T) {
+	t.Setenv("IP_HASH_SECRET", "")
+	unkeyed := hashIP("203.0.113.7")
+	assert.Len(t, unkeyed, 64)
+	assert.NotContains(t, unkeyed, "203.0.113.7")
+
+	t.Setenv("IP_HASH_SECRET", "consent-secret")
+	keyed := hashIP("203.0.113.7")
+	assert.Len(t, keyed, 64)
+	assert.NotEqual(t, unkeyed, keyed, "the secret must change the hash")
+	assert.Equal(t, keyed, hashIP("203.0.113.7"))
+	assert.NotEqual(t, keyed, hashIP("203.0.113.8"))
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"secure-backend/database"
+	"secure-backend/invoices"
+	"secure-backend/models"
+	"secure-backend/money"
+	"secure-backend/tokens"
+	"secure-backend/utils"
+	"strconv"
+	"time"
+)
+
+const (
+	// DemoSessionTTL is how long a demo session token stays valid
+	DemoSessionTTL = time.Hour
+	// defaultDemoResetHour is the hour (UTC) demo data is reset at unless DEMO_RESET_HOUR says otherwise
+	defaultDemoResetHour = 3
+)
+
+var (
+	// ErrDemoModeOff is returned when demo sessions are requested while DEMO_MODE is off
+	ErrDemoModeOff = errors.New("demo mode is off")
+	// ErrNoDemoAccount is returned for a role without a demo account
+	ErrNoDemoAccount = errors.New("role must be buyer, seller or admin")
+)
+
+// DemoMode reports whether demo mode is on (DEMO_MODE=true). The demo accounts and catalog
+// are then seeded, reset every night and open to demo sessions.
+func DemoMode() bool {
+	on, _ := strconv.ParseBool(os.Getenv("DEMO_MODE"))
+	return on
+}
+
+// DemoResetHour returns the hour (UTC) demo data is reset at, configurable via DEMO_RESET_HOUR
+func DemoResetHour() int {
+	if value := os.Getenv("DEMO_RESET_HOUR"); value != "" {
+		if hour, err := strconv.Atoi(value); err == nil && hour >= 0 && hour < 24 {
+			return hour
+		}
+		log.Printf("Invalid DEMO_RESET_HOUR %q, using %d", value, defaultDemoResetHour)
+	}
+	return defaultDemoResetHour
+}
+
+// lastDemoReset returns the most recent scheduled reset time at or before now
+func lastDemoReset(now time.Time, hour int) time.Time {
+	now = now.UTC()
+	reset := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, time.UTC)
+	if reset.After(now) {
+		reset = reset.AddDate(0, 0, -1)
+	}
+	return reset
+}
+
+// ResetDemoIfDue restores the demo data unless it was seeded since the last scheduled reset
+func ResetDemoIfDue(ctx context.Context) (bool, error) {
+	now := clk.Now()
+	return database.ResetDemoData(ctx, demoSeed(now), lastDemoReset(now, DemoResetHour()))
+}
+
+// StartDemoReset seeds the demo data if it is missing or due for its nightly reset, then
+// checks again every interval until ctx is cancelled
+func StartDemoReset(ctx context.Context, interval time.Duration) {
+	reset := func() {
+		if done, err := ResetDemoIfDue(ctx); err != nil {
+			log.Printf("Failed to reset demo data: %v", err)
+		} else if done {
+			log.Printf("Reset demo data")
+		}
+	}
+
+	go func() {
+		reset()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				reset()
+			}
+		}
+	}()
+}
+
+// IssueDemoSession issues a Bearer token for the demo account with the role
+func IssueDemoSession(role string) (string, time.Time, *models.DemoUser, error) {
+	if !DemoMode() {
+		return "", time.Time{}, nil, ErrDemoModeOff
+	}
+	user, ok := models.DemoUserByRole(role)
+	if !ok {
+		return "", time.Time{}, nil, ErrNoDemoAccount
+	}
+
+	expiresAt := clk.Now().Add(DemoSessionTTL)
+	token, err := tokens.Sign(tokens.PurposeDemo, user.ID, expiresAt)
+	if err != nil {
+		return "", time.Time{}, nil, err
+	}
+	return token, expiresAt, &user, nil
+}
+
+// Indexes into models.DemoUsers
+const (
+	demoBuyer = iota
+	demoSeller
+	demoCraftsSeller
+)
+
+// demoSeed returns the curated demo data, with sample orders placed in the weeks before now
+// and a few items in the buyer's cart
+func demoSeed(now time.Time) *models.DemoSeed {
+	seller, crafts := models.DemoUsers[demoSeller].ID, models.DemoUsers[demoCraftsSeller].ID
+	product := func(sellerID, category, name string, price float64, stock int, description string) models.DemoProduct {
+		return models.DemoProduct{
+			SellerID: sellerID, CategorySlug: category, Slug: "demo-" + utils.Slugify(name),
+			Name: name, Description: description, Price: money.FromFloat(price), Stock: stock,
+		}
+	}
+
+	seed := &models.DemoSeed{
+		Users: models.DemoUsers,
+		Categories: []models.Category{
+			{Name: "Kitchen", Slug: "kitchen", Description: "Cookware, tableware and coffee gear"},
+			{Name: "Home", Slug: "home", Description: "Textiles, lighting and decor"},
+			{Name: "Stationery", Slug: "stationery", Description: "Notebooks, pens and desk accessories"},
+			{Name: "Outdoors", Slug: "outdoors", Description: "Gear for hikes and picnics"},
+		},
+		Products: []models.DemoProduct{
+			product(seller, "kitchen", "Cast Iron Skillet 26cm", 49.00, 40, "Pre-seasoned cast iron skillet that goes from stovetop to oven."),
+			product(seller, "kitchen", "Pour-Over Coffee Set", 39.50, 60, "Ceramic dripper, glass carafe and 100 paper filters."),
+			product(seller, "kitchen", "Walnut Serving Board", 34.00, 25, "Oiled walnut board with a juice groove, 40 x 25 cm."),
+			product(seller, "home", "Linen Throw Blanket", 79.00, 30, "Stonewashed European linen throw in natural oat."),
+			product(seller, "home", "Ceramic Table Lamp", 95.00, 15, "Hand-glazed ceramic base with a linen shade."),
+			product(seller, "outdoors", "Insulated Water Bottle 750ml", 29.00, 120, "Keeps drinks cold for 24 hours or hot for 12."),
+			product(seller, "outdoors", "Picnic Blanket", 45.00, 35, "Water-resistant backing, folds into a carry strap."),
+			product(crafts, "stationery", "Dot Grid Notebook A5", 18.00, 200, "192 numbered pages of 100gsm paper, lay-flat binding."),
+			product(crafts, "stationery", "Brass Fountain Pen", 64.00, 20, "Solid brass body that develops a patina over time."),
+			product(crafts, "stationery", "Desk Organizer", 27.50, 45, "Beech wood tray with slots for pens, cards and a phone."),
+			product(crafts, "home", "Hand-Poured Soy Candle", 22.00, 80, "Cedar and bergamot, about 45 hours of burn time."),
+			product(crafts, "kitchen", "Stoneware Mug Set", 42.00, 50, "Four speckled stoneware mugs, 350ml each."),
+		},
+		TaxRate:      invoices.TaxRate(),
+		Jurisdiction: invoices.TaxJurisdiction(),
+	}
+
+	buyer := models.DemoUsers[demoBuyer].ID
+	const address = "221B Baker Street, London NW1 6XE, United Kingdom"
+	order := func(daysAgo int, status, platform string, items ...models.DemoOrderItem) models.DemoOrder {
+		return models.DemoOrder{
+			BuyerID: buyer, Status: status, PlacedAt: now.AddDate(0, 0, -daysAgo),
+			ShippingAddress: address, ClientPlatform: platform, Items: items,
+		}
+	}
+	item := func(product, quantity int) models.DemoOrderItem {
+		return models.DemoOrderItem{Product: product, Quantity: quantity}
+	}
+	seed.Orders = []models.DemoOrder{
+		order(24, "delivered", "web", item(0, 1), item(1, 1)),
+		order(17, "delivered", "ios", item(7, 3), item(8, 1)),
+		order(9, "delivered", "android", item(3, 1), item(10, 2)),
+		order(4, "shipped", "web", item(5, 2), item(6, 1)),
+		order(1, "paid", "ios", item(11, 1), item(2, 1)),
+	}
+	seed.CartItems = []models.DemoCartItem{
+		{UserID: buyer, Product: 4, Quantity: 1},
+		{UserID: buyer, Product: 9, Quantity: 2},
+	}
+	return seed
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"secure-backend/models"
+	"secure-backend/tokens"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLastDemoReset(t *testing.T) {
+	at := func(value string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		require.NoError(t, err)
+		return parsed
+	}
+
+	assert.Equal(t, at("2026-10-15T03:00:00Z"), lastDemoReset(at("2026-10-15T09:30:00Z"), 3))
+	assert.Equal(t, at("2026-10-15T03:00:00Z"), lastDemoReset(at("2026-10-15T03:00:00Z"), 3))
+	assert.Equal(t, at("2026-10-14T03:00:00Z"), lastDemoReset(at("2026-10-15T02:59:00Z"), 3))
+	assert.Equal(t, at("2026-10-14T23:00:00Z"), lastDemoReset(at("2026-10-15T01:00:00+02:00"), 23))
+}
+
+func TestDemoSeedIsConsistent(t *testing.T) {
+	now := time.Date(2026, 10, 15, 3, 0, 0, 0, time.UTC)
+	assertSeedConsistent(t, demoSeed(now), now)
+}
+
+// assertSeedConsistent checks that a seed only refers to its own users, categories and
+// products, and that its orders and carts don't take more than the products' stock
+func assertSeedConsistent(t *testing.T, seed *models.DemoSeed, now time.Time) {
+	t.Helper()
+	users := map[string]bool{}
+	emails := map[string]bool{}
+	for _, user := range seed.Users {
+		assert.False(t, users[user.ID], "duplicate user %s", user.ID)
+		assert.False(t, emails[user.Email], "duplicate email %s", user.Email)
+		assert.True(t, strings.HasSuffix(user.Email, "@"+models.DemoEmailDomain), user.Email)
+		users[user.ID], emails[user.Email] = true, true
+	}
+
+	categories := map[string]bool{}
+	for _, category := range seed.Categories {
+		categories[category.Slug] = true
+	}
+	sellers := map[string]bool{}
+	for _, user := range seed.Users {
+		if user.Role == "seller" {
+			sellers[user.ID] = true
+		}
+	}
+
+	stock := make([]int, len(seed.Products))
+	slugs := map[string]bool{}
+	for i, product := range seed.Products {
+		assert.True(t, sellers[product.SellerID], product.Name)
+		assert.True(t, categories[product.CategorySlug], product.Name)
+		assert.False(t, slugs[product.Slug], "duplicate slug %s", product.Slug)
+		slugs[product.Slug] = true
+		stock[i] = product.Stock
+	}
+
+	require.NotEmpty(t, seed.Orders)
+	for _, order := range seed.Orders {
+		assert.Contains(t, []string{"paid", "shipped", "delivered"}, order.Status)
+		assert.True(t, order.PlacedAt.Before(now))
+		for _, item := range order.Items {
+			require.Less(t, item.Product, len(seed.Products))
+			stock[item.Product] -= item.Quantity
+			assert.GreaterOrEqual(t, stock[item.Product], 0, seed.Products[item.Product].Name)
+		}
+	}
+
+	inCart := map[string]bool{}
+	for _, item := range seed.CartItems {
+		require.Less(t, item.Product, len(seed.Products))
+		assert.True(t, users[item.UserID])
+		key := item.UserID + "/" + seed.Products[item.Product].Slug
+		assert.False(t, inCart[key], "product twice in a cart: %s", key)
+		inCart[key] = true
+		assert.Positive(t, item.Quantity)
+		assert.LessOrEqual(t, item.Quantity, seed.Products[item.Product].Stock)
+	}
+}
+
+func TestDevSeed(t *testing.T) {
+	now := time.Date(2026, 10, 15, 3, 0, 0, 0, time.UTC)
+	opts := SeedOptions{Buyers: 30, Sellers: 10, ProductsPerSeller: 20, RandomSeed: 7}
+	seed := DevSeed(now, opts)
+	assertSeedConsistent(t, seed, now)
+
+	demo := demoSeed(now)
+	assert.Len(t, seed.Users, len(demo.Users)+opts.Buyers+opts.Sellers)
+	assert.Len(t, seed.Products, len(demo.Products)+opts.Sellers*opts.ProductsPerSeller)
+	assert.Greater(t, len(seed.CartItems), len(demo.CartItems))
+	assert.Len(t, models.DemoUsers, len(demo.Users), "the demo accounts aren't changed")
+	assert.Equal(t, seed, DevSeed(now, opts), "the same random seed generates the same data")
+}
+
+func TestIssueDemoSession(t *testing.T) {
+	tokens.SetKeyring(tokens.NewKeyring(tokens.Key{ID: "test", Secret: []byte("secret")}))
+
+	t.Setenv("DEMO_MODE", "")
+	_, _, _, err := IssueDemoSession("buyer")
+	assert.ErrorIs(t, err, ErrDemoModeOff)
+
+	t.Setenv("DEMO_MODE", "true")
+	_, _, _, err = IssueDemoSession("owner")
+	assert.ErrorIs(t, err, ErrNoDemoAccount)
+
+	token, expiresAt, user, err := IssueDemoSession("seller")
+	require.NoError(t, err)
+	assert.Equal(t, models.DemoUsers[demoSeller], *user)
+	assert.WithinDuration(t, time.Now().Add(DemoSessionTTL), expiresAt, time.Minute)
+
+	subject, err := tokens.Subject(token, tokens.PurposeDemo, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, subject)
+	_, err = tokens.Subject(token, tokens.PurposeBreakGlass, time.Now())
+	assert.Error(t, err, "demo tokens aren't break-glass tokens")
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"log"
+	"secure-backend/database"
+	"secure-backend/models"
+	"time"
+)
+
+const (
+	// duplicateScanBatch caps how many changed listings are checked per sweep
+	duplicateScanBatch = 100
+	// duplicateCandidateLimit caps how many similar listings each listing is compared with
+	duplicateCandidateLimit = 20
+)
+
+// ScanDuplicateListings compares listings created or changed since their last check with
+// other sellers' listings and queues probable duplicates for admin review. Each pair is
+// flagged with the newer listing as the duplicate. It returns how many pairs were flagged.
+func ScanDuplicateListings(ctx context.Context) (int, error) {
+	listings, err := database.GetUncheckedListings(ctx, duplicateScanBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	flagged := 0
+	for i := range listings {
+		listing := &listings[i]
+		candidates, err := database.GetDuplicateCandidates(ctx, listing, duplicateCandidateLimit)
+		if err != nil {
+			return flagged, err
+		}
+
+		var duplicates []models.DuplicateListing
+		for j := range candidates {
+			candidate := &candidates[j]
+			score, reasons := models.ListingSimilarity(listing, candidate)
+			if score < models.DuplicateThreshold {
+				continue
+			}
+
+			newer, older := listing, candidate
+			if older.CreatedAt.After(newer.CreatedAt) {
+				newer, older = older, newer
+			}
+			duplicates = append(duplicates, models.DuplicateListing{
+				ProductID:     newer.ID,
+				DuplicateOfID: older.ID,
+				Score:         score,
+				Reasons:       reasons,
+			})
+		}
+
+		count, err := database.RecordDuplicateCheck(ctx, listing.ID, duplicates, clk.Now())
+		if err != nil {
+			return flagged, err
+		}
+		flagged += count
+	}
+
+	return flagged, nil
+}
+
+// StartDuplicateListingScanner periodically checks changed listings for duplicates until ctx is cancelled
+func StartDuplicateListingScanner(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				flagged, err := ScanDuplicateListings(ctx)
+				if err != nil {
+					log.Printf("Failed to scan for duplicate listings: %v", err)
+				} else if flagged > 0 {
+					log.Printf("Flagged %d probable duplicate listings for review", flagged)
+				}
+			}
+		}
+	}()
+}
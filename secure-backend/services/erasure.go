@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"secure-backend/database"
+	"secure-backend/mail"
+	"secure-backend/models"
+	"secure-backend/tokens"
+	"time"
+)
+
+const (
+	// erasureConfirmWindow is how long the emailed confirmation link works
+	erasureConfirmWindow = 24 * time.Hour
+	// defaultErasureGracePeriod is how long a confirmed erasure waits, so it can still be cancelled
+	defaultErasureGracePeriod = 14 * 24 * time.Hour
+	// erasureBatch caps how many accounts are erased per sweep
+	erasureBatch = 50
+)
+
+var (
+	// ErrErasureNotAllowed is returned when a seller or admin requests erasure; their accounts
+	// hold listings and operations that support has to wind down first
+	ErrErasureNotAllowed = errors.New("only buyer accounts can request erasure; please contact support")
+	// ErrErasureUnavailable is returned when confirmation emails can't be sent
+	ErrErasureUnavailable = errors.New("account erasure is not available")
+)
+
+// ErasureGracePeriod returns how long after confirmation an account is erased, configurable
+// via ERASURE_GRACE_PERIOD (e.g. "336h")
+func ErasureGracePeriod() time.Duration {
+	if value := os.Getenv("ERASURE_GRACE_PERIOD"); value != "" {
+		if grace, err := time.ParseDuration(value); err == nil && grace >= 0 {
+			return grace
+		}
+		log.Printf("Invalid ERASURE_GRACE_PERIOD %q, using %s", value, defaultErasureGracePeriod)
+	}
+	return defaultErasureGracePeriod
+}
+
+// RequestErasure starts a buyer's erasure request and emails them a link to confirm it. The
+// link opens ERASURE_CONFIRM_URL with the token in ?token=, which the page posts back.
+func RequestErasure(ctx context.Context, user *models.AuthUser) (*models.ErasureRequest, error) {
+	if user.Role != "buyer" {
+		return nil, ErrErasureNotAllowed
+	}
+	confirmURL := os.Getenv("ERASURE_CONFIRM_URL")
+	if confirmURL == "" || !mail.Configured() {
+		return nil, ErrErasureUnavailable
+	}
+
+	account, err := database.GetUserByID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := clk.Now()
+	request, err := database.CreateErasureRequest(ctx, user.ID, now.Add(erasureConfirmWindow), now)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := tokens.Sign(tokens.PurposeErasureConfirm, request.ID, request.ConfirmBy)
+	if err != nil {
+		return nil, err
+	}
+	err = mail.Send(mail.Message{
+		To:      account.Email,
+		Subject: "Confirm the deletion of your account",
+		Body: fmt.Sprintf("We received a request to delete your account and personal data.\n\n"+
+			"To confirm, open this link before %s:\n%s?token=%s\n\n"+
+			"Your data is deleted %s after you confirm, and you can cancel until then. "+
+			"Records we must keep for accounting, such as invoices, are kept without your personal details.\n\n"+
+			"If you didn't ask for this, you can ignore this email.",
+			request.ConfirmBy.UTC().Format(time.RFC1123), confirmURL, url.QueryEscape(token), formatGrace(ErasureGracePeriod())),
+	})
+	if err != nil {
+		// Don't leave a request behind that can never be confirmed
+		if _, cancelErr := database.CancelErasureRequest(ctx, user.ID, now); cancelErr != nil {
+			log.Printf("Failed to cancel unsent erasure request %s: %v", request.ID, cancelErr)
+		}
+		return nil, fmt.Errorf("sending erasure confirmation: %w", err)
+	}
+
+	return request, nil
+}
+
+// ConfirmErasure confirms the erasure request a token was emailed for and schedules the
+// erasure after the grace period. Returns tokens.ErrInvalidToken for bad or expired tokens and
+// sql.ErrNoRows when the request was cancelled or has expired.
+func ConfirmErasure(ctx context.Context, token string) (*models.ErasureRequest, error) {
+	now := clk.Now()
+	requestID, err := tokens.Subject(token, tokens.PurposeErasureConfirm, now)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := database.GetErasureRequest(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request.Status == models.ErasureScheduled {
+		return request, nil
+	}
+	if request.Status != models.ErasureAwaitingConfirmation {
+		return nil, sql.ErrNoRows
+	}
+
+	return database.ConfirmErasureRequest(ctx, request.ID, now, now.Add(ErasureGracePeriod()))
+}
+
+// CancelErasure cancels the user's pending erasure request. Returns sql.ErrNoRows if there is none.
+func CancelErasure(ctx context.Context, userID string) (*models.ErasureRequest, error) {
+	return database.CancelErasureRequest(ctx, userID, clk.Now())
+}
+
+// EraseDueAccounts erases the accounts whose confirmed erasure is due and returns how many
+func EraseDueAccounts(ctx context.Context) (int, error) {
+	now := clk.Now()
+	requestIDs, err := database.GetDueErasureRequests(ctx, now, erasureBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, requestID := range requestIDs {
+		erased, err := database.EraseUser(ctx, requestID, now)
+		if err != nil {
+			log.Printf("Failed to carry out erasure request %s: %v", requestID, err)
+			continue
+		}
+		if erased {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// StartAccountEraser periodically erases the accounts whose grace period has ended until ctx
+// is cancelled
+func StartAccountEraser(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				erased, err := EraseDueAccounts(ctx)
+				if err != nil {
+					log.Printf("Failed to load due erasure requests: %v", err)
+				} else if erased > 0 {
+					log.Printf("Erased %d accounts", erased)
+				}
+			}
+		}
+	}()
+}
+
+// formatGrace writes a grace period in days when it is a whole number of them
+func formatGrace(d time.Duration) string {
+	if d >= 24*time.Hour && d%(24*time.Hour) == 0 {
+		days := int(d / (24 * time.Hour))
+		if days == 1 {
+			return "1 day"
+		}
+		return fmt.Sprintf("%d days", days)
+	}
+	return d.String()
+}
@@ -0,0 +1,26 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErasureGracePeriod(t *testing.T) {
+	t.Setenv("ERASURE_GRACE_PERIOD", "")
+	assert.Equal(t, defaultErasureGracePeriod, ErasureGracePeriod())
+
+	t.Setenv("ERASURE_GRACE_PERIOD", "72h")
+	assert.Equal(t, 72*time.Hour, ErasureGracePeriod())
+
+	t.Setenv("ERASURE_GRACE_PERIOD", "two weeks")
+	assert.Equal(t, defaultErasureGracePeriod, ErasureGracePeriod())
+}
+
+func TestFormatGrace(t *testing.T) {
+	assert.Equal(t, "14 days", formatGrace(14*24*time.Hour))
+	assert.Equal(t, "1 day", formatGrace(24*time.Hour))
+	assert.Equal(t, "36h0m0s", formatGrace(36*time.Hour))
+	assert.Equal(t, "30m0s", formatGrace(30*time.Minute))
+}
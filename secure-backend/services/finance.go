@@ -0,0 +1,28 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"secure-backend/database"
+	"secure-backend/models"
+)
+
+// ErrPeriodClosed is returned when someone other than an admin changes an order placed in a
+// closed accounting month
+var ErrPeriodClosed = errors.New("the accounting period of this order is closed")
+
+// EnsurePeriodOpen refuses changes to the status and refunds of an order placed in a closed
+// accounting month, unless they are made by an admin or by the platform itself (nil actor)
+func EnsurePeriodOpen(ctx context.Context, order *models.Order, actor *models.AuthUser) error {
+	if actor == nil || actor.Role == "admin" {
+		return nil
+	}
+	closed, err := database.IsPeriodClosed(ctx, order.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if closed {
+		return ErrPeriodClosed
+	}
+	return nil
+}
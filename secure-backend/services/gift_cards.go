@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"secure-backend/database"
+	"secure-backend/models"
+	"strings"
+	"time"
+)
+
+// giftCardCodeBytes is the random part of a gift card code (16 hex characters)
+const giftCardCodeBytes = 8
+
+// IssueGiftCard creates a gift card and returns it with its code, which is only stored as a
+// hash and must be handed over now. Codes are printed in groups of four, e.g. 1A2B-3C4D-5E6F-7A8B.
+func IssueGiftCard(ctx context.Context, card *models.GiftCard, audit *models.AuditEntry) (*models.GiftCard, string, error) {
+	secret, err := randomSecret(giftCardCodeBytes)
+	if err != nil {
+		return nil, "", err
+	}
+	code := normalizeGiftCardCode(secret)
+	card.CodeLast4 = code[len(code)-4:]
+	audit.Detail = fmt.Sprintf("gift card ending %s for %s", card.CodeLast4, card.Amount)
+
+	created, err := database.CreateGiftCard(ctx, card, hashSecret(code), audit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	groups := make([]string, 0, len(code)/4)
+	for i := 0; i < len(code); i += 4 {
+		groups = append(groups, code[i:i+4])
+	}
+	return created, strings.Join(groups, "-"), nil
+}
+
+// RedeemGiftCard adds the gift card's amount to the user's store credit
+func RedeemGiftCard(ctx context.Context, code, userID string, now time.Time) (*models.StoreCreditEntry, error) {
+	code = normalizeGiftCardCode(code)
+	if len(code) != giftCardCodeBytes*2 {
+		return nil, database.ErrGiftCardInvalid
+	}
+	return database.RedeemGiftCard(ctx, hashSecret(code), userID, now)
+}
+
+// normalizeGiftCardCode drops separators and whitespace and upper-cases the code,
+// so codes typed with or without dashes match
+func normalizeGiftCardCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+}
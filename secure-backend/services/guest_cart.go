@@ -0,0 +1,38 @@
+package services
+
+import (
+	"context"
+	"log"
+	"secure-backend/database"
+	"time"
+)
+
+// StartGuestCartReaper periodically deletes anonymous carts that expired without being merged
+// and cart share links that expired
+func StartGuestCartReaper(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				deleted, err := database.DeleteExpiredCartSessions(ctx, time.Now())
+				if err != nil {
+					log.Printf("Failed to delete expired guest carts: %v", err)
+				} else if deleted > 0 {
+					log.Printf("Deleted %d expired guest carts", deleted)
+				}
+
+				deleted, err = database.DeleteExpiredCartShares(ctx, time.Now())
+				if err != nil {
+					log.Printf("Failed to delete expired cart shares: %v", err)
+				} else if deleted > 0 {
+					log.Printf("Deleted %d expired cart shares", deleted)
+				}
+			}
+		}
+	}()
+}
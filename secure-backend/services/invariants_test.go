@@ -0,0 +1,412 @@
+//go:build e2e
+
+// Property tests for the cart, checkout and refund invariants. Random sequences of cart
+// edits, checkouts, payments, cancellations, expiries and refunds run against a real
+// PostgreSQL database (with database/schema.sql applied):
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run TestCommerceInvariants ./services
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"secure-backend/clock"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/money"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"pgregory.net/rapid"
+)
+
+var (
+	testDBOnce sync.Once
+	testDBErr  error
+)
+
+// initTestDB connects to TEST_DATABASE_URL once per test binary, skipping the test if it is unset
+func initTestDB(t *testing.T) {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	testDBOnce.Do(func() {
+		os.Setenv("DATABASE_URL", dsn)
+		testDBErr = database.InitDB()
+	})
+	if testDBErr != nil {
+		t.Fatalf("failed to connect to test database: %v", testDBErr)
+	}
+}
+
+// createTestUsers connects to the test database and creates a user per role with an email
+// unique to the run. The users are deleted when the test ends; cleanups registered afterwards
+// run first, so tests can delete rows that would keep them.
+func createTestUsers(t *testing.T, prefix string, roles ...string) []*models.AuthUser {
+	t.Helper()
+	initTestDB(t)
+
+	suffix := uuid.NewString()[:8]
+	users := make([]*models.AuthUser, len(roles))
+	ids := make([]string, len(roles))
+	for i, role := range roles {
+		email := fmt.Sprintf("%s-%s-%d-%s@example.com", prefix, role, i, suffix)
+		if err := database.DB.Get(&ids[i], `INSERT INTO users (email, role) VALUES ($1, $2) RETURNING id`, email, role); err != nil {
+			t.Fatalf("failed to seed %s: %v", role, err)
+		}
+		users[i] = &models.AuthUser{ID: ids[i], Email: email, Role: role}
+	}
+	t.Cleanup(func() {
+		database.DB.Exec(`DELETE FROM users WHERE id = ANY($1)`, pq.Array(ids))
+	})
+	return users
+}
+
+// seededProduct is a product created for a run with the stock it started with
+type seededProduct struct {
+	ID           string
+	InitialStock int
+}
+
+// commerceModel drives one randomized run: a seller with a few products, two buyers and an admin
+type commerceModel struct {
+	clock    *clock.Mock
+	admin    *models.AuthUser
+	buyers   []*models.AuthUser
+	products []seededProduct
+	orders   []string
+	userIDs  []string
+}
+
+func TestCommerceInvariants(t *testing.T) {
+	initTestDB(t)
+
+	mock := clock.NewMock(time.Now())
+	SetClock(mock)
+	database.SetClock(mock)
+	defer SetClock(clock.System())
+	defer database.SetClock(clock.System())
+
+	rapid.Check(t, func(t *rapid.T) {
+		m := newCommerceModel(t, mock)
+		t.Cleanup(m.cleanup)
+
+		t.Repeat(map[string]func(*rapid.T){
+			"addToCart": m.addToCart,
+			"checkout":  m.checkout,
+			"pay":       func(t *rapid.T) { m.transition(t, OrderStatusPaid) },
+			"ship":      func(t *rapid.T) { m.transition(t, OrderStatusShipped) },
+			"cancel":    func(t *rapid.T) { m.transition(t, OrderStatusCancelled) },
+			"expire":    m.expire,
+			"refund":    m.refund,
+			"":          m.checkInvariants,
+		})
+	})
+}
+
+func newCommerceModel(t *rapid.T, mock *clock.Mock) *commerceModel {
+	m := &commerceModel{clock: mock}
+
+	createUser := func(role string) *models.AuthUser {
+		var id string
+		email := fmt.Sprintf("prop-%s-%s@example.com", role, uuid.NewString()[:8])
+		if err := database.DB.Get(&id, `INSERT INTO users (email, role) VALUES ($1, $2) RETURNING id`, email, role); err != nil {
+			t.Fatalf("failed to seed %s: %v", role, err)
+		}
+		m.userIDs = append(m.userIDs, id)
+		return &models.AuthUser{ID: id, Email: email, Role: role}
+	}
+
+	seller := createUser("seller")
+	m.admin = createUser("admin")
+	m.buyers = []*models.AuthUser{createUser("buyer"), createUser("buyer")}
+
+	productCount := rapid.IntRange(1, 3).Draw(t, "products")
+	for i := 0; i < productCount; i++ {
+		stock := rapid.IntRange(0, 6).Draw(t, "stock")
+		price := money.Amount(rapid.IntRange(1, 20000).Draw(t, "priceCents"))
+
+		var id string
+		err := database.DB.Get(&id, `
+			INSERT INTO products (name, description, price, stock, status, seller_id)
+			VALUES ($1, 'Seeded by the property tests', $2, $3, 'published', $4)
+			RETURNING id
+		`, fmt.Sprintf("Property product %d", i), price, stock, seller.ID)
+		if err != nil {
+			t.Fatalf("failed to seed product: %v", err)
+		}
+		if stock > 0 {
+			_, err = database.DB.Exec(`
+				INSERT INTO stock_movements (product_id, quantity, reason) VALUES ($1, $2, 'opening')
+			`, id, stock)
+			if err != nil {
+				t.Fatalf("failed to seed opening stock movement: %v", err)
+			}
+		}
+		m.products = append(m.products, seededProduct{ID: id, InitialStock: stock})
+	}
+
+	return m
+}
+
+// cleanup removes the run's rows; refunds and payments block order deletion, and order items block products
+func (m *commerceModel) cleanup() {
+	ids := pq.Array(m.userIDs)
+	for _, query := range []string{
+		`DELETE FROM refunds WHERE order_id IN (SELECT id FROM orders WHERE buyer_id = ANY($1))`,
+		`DELETE FROM payments WHERE order_id IN (SELECT id FROM orders WHERE buyer_id = ANY($1))`,
+		`DELETE FROM orders WHERE buyer_id = ANY($1)`,
+		`DELETE FROM users WHERE id = ANY($1)`,
+	} {
+		if _, err := database.DB.Exec(query, ids); err != nil {
+			panic(fmt.Sprintf("cleanup failed: %v", err))
+		}
+	}
+}
+
+func (m *commerceModel) drawOrder(t *rapid.T) string {
+	if len(m.orders) == 0 {
+		t.Skip("no orders yet")
+	}
+	return rapid.SampledFrom(m.orders).Draw(t, "order")
+}
+
+func (m *commerceModel) addToCart(t *rapid.T) {
+	buyer := rapid.SampledFrom(m.buyers).Draw(t, "buyer")
+	product := rapid.SampledFrom(m.products).Draw(t, "product")
+	quantity := rapid.IntRange(1, 4).Draw(t, "quantity")
+
+	if _, err := database.AddToCart(context.Background(), buyer.ID, product.ID, quantity); err != nil {
+		t.Fatalf("add to cart: %v", err)
+	}
+}
+
+// checkout asserts that a successful checkout charges exactly the sum of the cart's line totals
+func (m *commerceModel) checkout(t *rapid.T) {
+	buyer := rapid.SampledFrom(m.buyers).Draw(t, "buyer")
+
+	cart, err := database.GetCartItems(context.Background(), buyer.ID)
+	if err != nil {
+		t.Fatalf("load cart: %v", err)
+	}
+	var cartTotal money.Amount
+	for _, item := range cart {
+		cartTotal += item.Product.Price.Times(item.Quantity)
+	}
+
+	order, _, err := Checkout(context.Background(), buyer, &models.ClientInfo{Platform: "web"}, "")
+	var stockErr *database.StockError
+	if errors.Is(err, database.ErrCartEmpty) || errors.As(err, &stockErr) {
+		return
+	} else if err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+	m.orders = append(m.orders, order.ID)
+
+	if order.TotalAmount != cartTotal {
+		t.Fatalf("order total %s, cart line totals sum to %s", order.TotalAmount, cartTotal)
+	}
+
+	itemsByOrder, err := database.GetOrderItemsForOrders(context.Background(), []string{order.ID})
+	if err != nil {
+		t.Fatalf("load order items: %v", err)
+	}
+	var lineTotal money.Amount
+	for _, item := range itemsByOrder[order.ID] {
+		lineTotal += item.TotalPrice
+	}
+	if lineTotal != cartTotal {
+		t.Fatalf("order items total %s, cart line totals sum to %s", lineTotal, cartTotal)
+	}
+}
+
+// transition moves a random order to status; paying also records the captured payment
+func (m *commerceModel) transition(t *rapid.T, status string) {
+	orderID := m.drawOrder(t)
+
+	_, err := TransitionOrder(context.Background(), orderID, status, m.admin, "")
+	if errors.Is(err, ErrInvalidTransition) || errors.Is(err, ErrTransitionConflict) {
+		return
+	} else if err != nil {
+		t.Fatalf("transition to %s: %v", status, err)
+	}
+
+	if status == OrderStatusPaid {
+		order, err := database.GetOrderByID(context.Background(), orderID)
+		if err != nil {
+			t.Fatalf("load order: %v", err)
+		}
+		err = database.CreatePayment(context.Background(), &models.Payment{
+			OrderID:           orderID,
+			Provider:          "test",
+			ProviderPaymentID: uuid.NewString(),
+			Amount:            order.TotalAmount,
+			Currency:          "usd",
+			Status:            "succeeded",
+		})
+		if err != nil {
+			t.Fatalf("record payment: %v", err)
+		}
+	}
+}
+
+// expire lets every open reservation lapse and runs the reaper
+func (m *commerceModel) expire(t *rapid.T) {
+	m.clock.Advance(ReservationTTL() + time.Minute)
+	if _, err := ReleaseExpiredReservations(context.Background()); err != nil {
+		t.Fatalf("release expired reservations: %v", err)
+	}
+}
+
+// refund requests a full, amount or item refund of a paid order and completes or fails it
+func (m *commerceModel) refund(t *rapid.T) {
+	orderID := m.drawOrder(t)
+
+	payments, err := database.GetPaymentsByOrder(context.Background(), orderID)
+	if err != nil {
+		t.Fatalf("load payments: %v", err)
+	}
+	if len(payments) == 0 {
+		return
+	}
+
+	req := database.RefundRequest{
+		OrderID:   orderID,
+		Restock:   rapid.Bool().Draw(t, "restock"),
+		ActorID:   m.admin.ID,
+		ActorRole: m.admin.Role,
+	}
+	switch rapid.IntRange(0, 2).Draw(t, "mode") {
+	case 1:
+		// May ask for more than was paid; that must be rejected
+		limit := int(payments[0].Amount.Cents()) + 500
+		req.Amount = money.Amount(rapid.IntRange(1, limit).Draw(t, "amountCents"))
+	case 2:
+		itemsByOrder, err := database.GetOrderItemsForOrders(context.Background(), []string{orderID})
+		if err != nil {
+			t.Fatalf("load order items: %v", err)
+		}
+		item := rapid.SampledFrom(itemsByOrder[orderID]).Draw(t, "item")
+		req.Items = []database.RefundItemRequest{{
+			OrderItemID: item.ID,
+			Quantity:    rapid.IntRange(1, item.Quantity+1).Draw(t, "refundQuantity"),
+		}}
+	}
+
+	refund, err := database.CreateRefund(context.Background(), req)
+	if errors.Is(err, database.ErrNothingToRefund) || errors.Is(err, database.ErrRefundExceedsPaid) ||
+		errors.Is(err, database.ErrInvalidRefundItem) {
+		return
+	} else if err != nil {
+		t.Fatalf("create refund: %v", err)
+	}
+
+	if rapid.Bool().Draw(t, "refundSucceeds") {
+		refund.ProviderRefundID = "re_" + uuid.NewString()
+		for i := range refund.Allocations {
+			refund.Allocations[i].ProviderRefundID = refund.ProviderRefundID
+		}
+		if _, err := database.CompleteRefund(context.Background(), refund); err != nil {
+			t.Fatalf("complete refund: %v", err)
+		}
+	} else if err := database.FailRefund(context.Background(), refund.ID); err != nil {
+		t.Fatalf("fail refund: %v", err)
+	}
+}
+
+// checkInvariants runs after every action
+func (m *commerceModel) checkInvariants(t *rapid.T) {
+	m.checkStock(t)
+	m.checkRefunds(t)
+}
+
+// checkStock asserts that stock never goes negative and that no unit is created or lost:
+// what is on the shelf plus what is held for orders equals the initial stock plus restocked
+// refunds, and the stock movement ledger adds up to the stock
+func (m *commerceModel) checkStock(t *rapid.T) {
+	for _, product := range m.products {
+		var row struct {
+			Stock     int `db:"stock"`
+			Ledger    int `db:"ledger"`
+			Reserved  int `db:"reserved"`
+			Restocked int `db:"restocked"`
+		}
+		err := database.DB.Get(&row, `
+			SELECT p.stock,
+				COALESCE((SELECT SUM(sm.quantity) FROM stock_movements sm WHERE sm.product_id = p.id), 0) AS ledger,
+				COALESCE((
+					SELECT SUM(sr.quantity) FROM stock_reservations sr
+					WHERE sr.product_id = p.id AND sr.status IN ('active', 'committed')
+				), 0) AS reserved,
+				COALESCE((
+					SELECT SUM(ri.quantity) FROM refund_items ri JOIN refunds r ON ri.refund_id = r.id
+					WHERE ri.product_id = p.id AND r.restock AND r.status = $2
+				), 0) AS restocked
+			FROM products p WHERE p.id = $1
+		`, product.ID, database.RefundSucceeded)
+		if err != nil {
+			t.Fatalf("load stock: %v", err)
+		}
+
+		if row.Stock < 0 {
+			t.Fatalf("product %s has negative stock %d", product.ID, row.Stock)
+		}
+		if row.Stock+row.Reserved != product.InitialStock+row.Restocked {
+			t.Fatalf("product %s: stock %d + reserved %d != initial %d + restocked %d",
+				product.ID, row.Stock, row.Reserved, product.InitialStock, row.Restocked)
+		}
+		if row.Ledger != row.Stock {
+			t.Fatalf("product %s: stock movements add up to %d, stock is %d", product.ID, row.Ledger, row.Stock)
+		}
+	}
+}
+
+// checkRefunds asserts that refunds never exceed what was charged, in money or in units
+func (m *commerceModel) checkRefunds(t *rapid.T) {
+	var overRefunded []struct {
+		OrderID  string       `db:"id"`
+		Total    money.Amount `db:"total_amount"`
+		Paid     money.Amount `db:"paid"`
+		Refunded money.Amount `db:"refunded"`
+	}
+	err := database.DB.Select(&overRefunded, `
+		SELECT o.id, o.total_amount,
+			COALESCE((SELECT SUM(amount) FROM payments WHERE order_id = o.id), 0) AS paid,
+			COALESCE((SELECT SUM(amount) FROM refunds WHERE order_id = o.id AND status <> $2), 0) AS refunded
+		FROM orders o
+		WHERE o.buyer_id = ANY($1)
+	`, pq.Array(m.userIDs), database.RefundFailed)
+	if err != nil {
+		t.Fatalf("load refund totals: %v", err)
+	}
+	for _, o := range overRefunded {
+		if o.Refunded > o.Total || o.Refunded > o.Paid {
+			t.Fatalf("order %s refunded %s of total %s (paid %s)", o.OrderID, o.Refunded, o.Total, o.Paid)
+		}
+	}
+
+	var overRefundedItems int
+	err = database.DB.Get(&overRefundedItems, `
+		SELECT COUNT(*) FROM order_items oi
+		JOIN orders o ON oi.order_id = o.id
+		WHERE o.buyer_id = ANY($1) AND oi.quantity < (
+			SELECT COALESCE(SUM(ri.quantity), 0) FROM refund_items ri JOIN refunds r ON ri.refund_id = r.id
+			WHERE ri.order_item_id = oi.id AND r.status <> $2
+		)
+	`, pq.Array(m.userIDs), database.RefundFailed)
+	if err != nil {
+		t.Fatalf("load refunded quantities: %v", err)
+	}
+	if overRefundedItems > 0 {
+		t.Fatalf("%d order items refunded more units than were ordered", overRefundedItems)
+	}
+}
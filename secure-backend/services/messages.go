@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"log"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/moderation"
+	"secure-backend/notifications"
+)
+
+// SendBuyerMessage screens a buyer's message about a product or an order and adds it to
+// their thread with its seller, who is notified. When the message starts the thread while the
+// seller is on vacation, the seller's auto-reply follows it.
+func SendBuyerMessage(ctx context.Context, buyerID string, subject database.MessageSubject, body string) (*models.MessageThread, *models.Message, error) {
+	screened := moderation.ScreenMessage(body)
+	thread, message, started, err := database.SendBuyerMessage(ctx, buyerID, subject, screened.Text, screened.Flags)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	notifyMessageRecipient(thread, message, buyerID)
+	if started {
+		// The buyer's message is already sent; a failed auto-reply doesn't fail it
+		if err := sendVacationAutoReply(ctx, thread); err != nil {
+			log.Printf("Failed to send the vacation auto-reply of seller %s: %v", thread.SellerID, err)
+		}
+	}
+	return thread, message, nil
+}
+
+// sendVacationAutoReply posts the seller's vacation auto-reply to a thread if the seller is on
+// vacation. It is screened like the seller's own messages, and the buyer is notified.
+func sendVacationAutoReply(ctx context.Context, thread *models.MessageThread) error {
+	vacations, err := database.GetSellerVacations(ctx, []string{thread.SellerID})
+	if err != nil {
+		return err
+	}
+	vacation := vacations[thread.SellerID]
+	if !vacation.Active(clk.Now()) {
+		return nil
+	}
+
+	screened := moderation.ScreenMessage(vacation.AutoReply())
+	thread, reply, err := database.ReplyToMessageThread(ctx, thread.ID, thread.SellerID, screened.Text, screened.Flags)
+	if err != nil {
+		return err
+	}
+	notifyMessageRecipient(thread, reply, thread.SellerID)
+	return nil
+}
+
+// ReplyToMessageThread screens a message of a thread's buyer or seller and adds it to the
+// thread, notifying the other participant
+func ReplyToMessageThread(ctx context.Context, threadID, senderID, body string) (*models.MessageThread, *models.Message, error) {
+	screened := moderation.ScreenMessage(body)
+	thread, message, err := database.ReplyToMessageThread(ctx, threadID, senderID, screened.Text, screened.Flags)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	notifyMessageRecipient(thread, message, senderID)
+	return thread, message, nil
+}
+
+// notifyMessageRecipient tells the other participant of a thread that a message arrived.
+// The body isn't included; clients load the thread to read it.
+func notifyMessageRecipient(thread *models.MessageThread, message *models.Message, senderID string) {
+	data := map[string]string{"thread_id": thread.ID, "message_id": message.ID}
+	body := "You have a new message about a product."
+	if thread.OrderID != nil {
+		data["order_id"] = *thread.OrderID
+		body = "You have a new message about an order."
+	} else if thread.ProductID != nil {
+		data["product_id"] = *thread.ProductID
+	}
+
+	notifications.Dispatch(notifications.Notification{
+		UserID: thread.RecipientOf(senderID),
+		Type:   notifications.TypeMessage,
+		Title:  "New message",
+		Body:   body,
+		Data:   data,
+	})
+}
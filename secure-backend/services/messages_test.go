@@ -0,0 +1,68 @@
+//go:build e2e
+
+// Messaging tests against a real PostgreSQL database (with database/schema.sql applied):
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run TestSendBuyerMessage ./services
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/moderation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendBuyerMessageVacationAutoReply(t *testing.T) {
+	users := createTestUsers(t, "away", "seller", "buyer")
+	sellerID, buyerID := users[0].ID, users[1].ID
+	ctx := context.Background()
+
+	var productID string
+	require.NoError(t, database.DB.GetContext(ctx, &productID, `
+		INSERT INTO products (name, price, stock, status, seller_id) VALUES ('Vacation product', 5, 10, 'published', $1) RETURNING id
+	`, sellerID))
+
+	started, until := time.Now().Add(-time.Hour), time.Now().Add(72*time.Hour)
+	require.NoError(t, database.SetSellerVacation(ctx, sellerID, &models.SellerVacation{
+		StartsAt: &started,
+		EndsAt:   &until,
+		Message:  "Write to me at away@example.com",
+	}))
+
+	subject := database.MessageSubject{ProductID: productID}
+	thread, _, err := SendBuyerMessage(ctx, buyerID, subject, "Is this still available?")
+	require.NoError(t, err)
+
+	messages, err := database.GetThreadMessages(ctx, thread.ID)
+	require.NoError(t, err)
+	require.Len(t, messages, 2, "the seller's auto-reply follows the first message")
+	reply := messages[1]
+	require.NotNil(t, reply.SenderID)
+	assert.Equal(t, sellerID, *reply.SenderID)
+	assert.Contains(t, reply.Body, "I'm on vacation until "+until.Format("2006-01-02"))
+	assert.NotContains(t, reply.Body, "away@example.com", "the auto-reply is screened like the seller's messages")
+	assert.Contains(t, []string(reply.Flags), moderation.FlagEmail)
+
+	// Only the message starting the thread gets one
+	_, _, err = SendBuyerMessage(ctx, buyerID, subject, "Hello again")
+	require.NoError(t, err)
+	messages, err = database.GetThreadMessages(ctx, thread.ID)
+	require.NoError(t, err)
+	assert.Len(t, messages, 3)
+
+	// Sellers who aren't away don't reply automatically
+	require.NoError(t, database.SetSellerVacation(ctx, sellerID, &models.SellerVacation{}))
+	_, err = database.DB.ExecContext(ctx, `DELETE FROM message_threads WHERE id = $1`, thread.ID)
+	require.NoError(t, err)
+	thread, _, err = SendBuyerMessage(ctx, buyerID, subject, "Is this still available?")
+	require.NoError(t, err)
+	messages, err = database.GetThreadMessages(ctx, thread.ID)
+	require.NoError(t, err)
+	assert.Len(t, messages, 1)
+}
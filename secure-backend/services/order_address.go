@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"log"
+	"os"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/notifications"
+	"time"
+)
+
+// defaultAddressEditWindow is how long after placing an order buyers can edit its address
+const defaultAddressEditWindow = time.Hour
+
+// AddressEditWindow returns how long after placing an order buyers can edit its shipping
+// address themselves, from ORDER_ADDRESS_EDIT_WINDOW (e.g. "2h"; 0 sends every edit to support)
+func AddressEditWindow() time.Duration {
+	if value := os.Getenv("ORDER_ADDRESS_EDIT_WINDOW"); value != "" {
+		if window, err := time.ParseDuration(value); err == nil && window >= 0 {
+			return window
+		}
+		log.Printf("Invalid ORDER_ADDRESS_EDIT_WINDOW %q, using %s", value, defaultAddressEditWindow)
+	}
+	return defaultAddressEditWindow
+}
+
+// ChangeOrderAddress edits the shipping address of a buyer's order, or routes the change
+// to support once the edit window has closed or fulfillment has started. Sellers of the
+// order are notified when the address changes.
+func ChangeOrderAddress(ctx context.Context, orderID, buyerID, address string) (*models.OrderAddressChange, error) {
+	change, err := database.ChangeOrderAddress(ctx, orderID, buyerID, address, AddressEditWindow(), clk.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if change.Status == models.AddressChangeApplied {
+		notifySellersOfAddress(ctx, change)
+	}
+	return change, nil
+}
+
+// ResolveAddressChange records support's decision on an address change request, notifying
+// the buyer and, when approved, the sellers
+func ResolveAddressChange(ctx context.Context, id, status, note string, audit *models.AuditEntry) (*models.OrderAddressChange, error) {
+	change, err := database.ResolveAddressChange(ctx, id, status, note, audit)
+	if err != nil {
+		return nil, err
+	}
+
+	body := "Your shipping address change was declined."
+	if status == models.AddressChangeApproved {
+		body = "Your shipping address was updated."
+		notifySellersOfAddress(ctx, change)
+	}
+	if note != "" {
+		body += " " + note
+	}
+	notifications.Dispatch(notifications.Notification{
+		UserID: change.RequestedBy,
+		Type:   notifications.TypeOrderAddress,
+		Title:  "Shipping address",
+		Body:   body,
+		Data:   map[string]string{"order_id": change.OrderID, "status": change.Status},
+	})
+
+	return change, nil
+}
+
+// notifySellersOfAddress tells the sellers of an order that its shipping address changed.
+// Failures are logged; the change itself is already saved.
+func notifySellersOfAddress(ctx context.Context, change *models.OrderAddressChange) {
+	sellerIDs, err := database.GetOrderSellerIDs(ctx, change.OrderID)
+	if err != nil {
+		log.Printf("Failed to notify sellers of address change on order %s: %v", change.OrderID, err)
+		return
+	}
+
+	for _, sellerID := range sellerIDs {
+		notifications.Dispatch(notifications.Notification{
+			UserID: sellerID,
+			Type:   notifications.TypeOrderAddress,
+			Title:  "Shipping address changed",
+			Body:   "The shipping address of an order changed. Ship to the new address.",
+			Data:   map[string]string{"order_id": change.OrderID, "shipping_address": change.NewAddress},
+		})
+	}
+}
@@ -0,0 +1,83 @@
+//go:build e2e
+
+// Order workflow tests against a real PostgreSQL database (with database/schema.sql applied):
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run TestTransitionOrder ./services
+package services
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"secure-backend/database"
+	"secure-backend/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransitionOrderRecordsHistory(t *testing.T) {
+	users := createTestUsers(t, "workflow", "seller", "buyer")
+	seller, buyerID := users[0], users[1].ID
+	ctx := context.Background()
+
+	var orderID string
+	require.NoError(t, database.DB.GetContext(ctx, &orderID, `
+		INSERT INTO orders (buyer_id, status, total_amount) VALUES ($1, 'pending', 20) RETURNING id
+	`, buyerID))
+
+	// Steps the workflow doesn't have are refused and leave no trace
+	_, err := TransitionOrder(ctx, orderID, OrderStatusShipped, seller, "")
+	assert.ErrorIs(t, err, ErrInvalidTransition)
+	history, err := database.GetOrderStatusHistory(ctx, orderID)
+	require.NoError(t, err)
+	assert.Empty(t, history)
+
+	// A nil actor is the platform
+	paid, err := TransitionOrder(ctx, orderID, OrderStatusPaid, nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, SystemActorRole, paid.ActorRole)
+	assert.Nil(t, paid.ActorID)
+
+	shipped, err := TransitionOrder(ctx, orderID, OrderStatusShipped, seller, "Tracking 1Z999")
+	require.NoError(t, err)
+	require.NotNil(t, shipped.ActorID)
+	assert.Equal(t, seller.ID, *shipped.ActorID)
+
+	history, err = database.GetOrderStatusHistory(ctx, orderID)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, []string{OrderStatusPending, OrderStatusPaid}, []string{history[0].FromStatus, history[0].ToStatus})
+	assert.Equal(t, SystemActorRole, history[0].ActorRole)
+	assert.Equal(t, []string{OrderStatusPaid, OrderStatusShipped}, []string{history[1].FromStatus, history[1].ToStatus})
+	assert.Equal(t, "seller", history[1].ActorRole)
+	assert.Equal(t, "Tracking 1Z999", history[1].Note)
+
+	// A transition decided on a stale status loses to the one that got there first
+	err = database.UpdateOrderStatus(ctx, &models.OrderStatusChange{
+		OrderID:    orderID,
+		FromStatus: OrderStatusPaid,
+		ToStatus:   OrderStatusCancelled,
+		ActorRole:  SystemActorRole,
+	})
+	assert.Equal(t, sql.ErrNoRows, err)
+	order, err := database.GetOrderByID(ctx, orderID)
+	require.NoError(t, err)
+	assert.Equal(t, OrderStatusShipped, order.Status)
+	history, err = database.GetOrderStatusHistory(ctx, orderID)
+	require.NoError(t, err)
+	assert.Len(t, history, 2, "the lost transition isn't recorded")
+
+	// Cancelled and refunded orders stay where they are
+	_, err = TransitionOrder(ctx, orderID, OrderStatusRefunded, nil, "")
+	require.NoError(t, err)
+	for _, to := range []string{OrderStatusPaid, OrderStatusCancelled, OrderStatusRefunded} {
+		_, err = TransitionOrder(ctx, orderID, to, nil, "")
+		assert.ErrorIs(t, err, ErrInvalidTransition, to)
+	}
+
+	_, err = TransitionOrder(ctx, uuid.NewString(), OrderStatusPaid, nil, "")
+	assert.ErrorIs(t, err, ErrOrderNotFound)
+}
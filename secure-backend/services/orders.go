@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/notifications"
+)
+
+// Order statuses
+const (
+	OrderStatusPending   = "pending"
+	OrderStatusPaid      = "paid"
+	OrderStatusShipped   = "shipped"
+	OrderStatusDelivered = "delivered"
+	OrderStatusCancelled = "cancelled"
+	OrderStatusRefunded  = "refunded"
+)
+
+// SystemActorRole is recorded on transitions triggered by the platform rather than a user
+const SystemActorRole = "system"
+
+var (
+	// ErrOrderNotFound is returned when the order does not exist
+	ErrOrderNotFound = errors.New("order not found")
+	// ErrInvalidTransition is returned when the requested status change is not allowed
+	ErrInvalidTransition = errors.New("invalid order status transition")
+	// ErrTransitionConflict is returned when the order changed status concurrently
+	ErrTransitionConflict = errors.New("order status changed concurrently")
+)
+
+// orderTransitions is the order status workflow:
+//
+//	pending → paid → shipped → delivered
+//	pending/paid → cancelled
+//	paid/shipped/delivered → refunded
+var orderTransitions = map[string][]string{
+	OrderStatusPending:   {OrderStatusPaid, OrderStatusCancelled},
+	OrderStatusPaid:      {OrderStatusShipped, OrderStatusCancelled, OrderStatusRefunded},
+	OrderStatusShipped:   {OrderStatusDelivered, OrderStatusRefunded},
+	OrderStatusDelivered: {OrderStatusRefunded},
+}
+
+// IsValidOrderStatus reports whether status is a known order status
+func IsValidOrderStatus(status string) bool {
+	switch status {
+	case OrderStatusPending, OrderStatusPaid, OrderStatusShipped,
+		OrderStatusDelivered, OrderStatusCancelled, OrderStatusRefunded:
+		return true
+	}
+	return false
+}
+
+// CanTransitionOrder reports whether an order may move from one status to another
+func CanTransitionOrder(from, to string) bool {
+	for _, allowed := range orderTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionOrder moves an order to a new status if the workflow allows it and
+// records the transition with the acting user. A nil actor records a system transition.
+// Only admins and the platform may change orders of a closed accounting month.
+func TransitionOrder(ctx context.Context, orderID, to string, actor *models.AuthUser, note string) (*models.OrderStatusChange, error) {
+	order, err := database.GetOrderByID(ctx, orderID)
+	if err == sql.ErrNoRows {
+		return nil, ErrOrderNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	if !CanTransitionOrder(order.Status, to) {
+		return nil, fmt.Errorf("%w: %s → %s", ErrInvalidTransition, order.Status, to)
+	}
+	if err := EnsurePeriodOpen(ctx, order, actor); err != nil {
+		return nil, err
+	}
+
+	change := &models.OrderStatusChange{
+		OrderID:    orderID,
+		FromStatus: order.Status,
+		ToStatus:   to,
+		ActorRole:  SystemActorRole,
+		Note:       note,
+	}
+	if actor != nil {
+		change.ActorID = &actor.ID
+		change.ActorRole = actor.Role
+	}
+
+	if err := database.UpdateOrderStatus(ctx, change); err == sql.ErrNoRows {
+		return nil, ErrTransitionConflict
+	} else if err != nil {
+		return nil, err
+	}
+
+	notifyOrderStatus(order.UserID, change)
+
+	return change, nil
+}
+
+// ShipOrderIfFulfilled moves a paid order to shipped once every item of it has shipped.
+// Orders with items still to ship, or that already moved on, are left as they are.
+func ShipOrderIfFulfilled(ctx context.Context, orderID string, actor *models.AuthUser) error {
+	shipped, err := database.OrderItemsShipped(ctx, orderID)
+	if err != nil || !shipped {
+		return err
+	}
+
+	_, err = TransitionOrder(ctx, orderID, OrderStatusShipped, actor, "")
+	if errors.Is(err, ErrInvalidTransition) || errors.Is(err, ErrTransitionConflict) {
+		return nil
+	}
+	return err
+}
+
+// orderStatusMessages are the buyer-facing notification texts per status
+var orderStatusMessages = map[string]string{
+	OrderStatusPaid:      "Your payment was received and your order is being prepared.",
+	OrderStatusShipped:   "Your order is on its way.",
+	OrderStatusDelivered: "Your order was delivered.",
+	OrderStatusCancelled: "Your order was cancelled.",
+	OrderStatusRefunded:  "Your order was refunded.",
+}
+
+// notifyOrderStatus tells the buyer their order moved to a new status
+func notifyOrderStatus(buyerID string, change *models.OrderStatusChange) {
+	body, ok := orderStatusMessages[change.ToStatus]
+	if !ok {
+		return
+	}
+
+	notifications.Dispatch(notifications.Notification{
+		UserID: buyerID,
+		Type:   notifications.TypeOrderStatus,
+		Title:  "Order update",
+		Body:   body,
+		Data: map[string]string{
+			"order_id": change.OrderID,
+			"status":   change.ToStatus,
+		},
+	})
+}
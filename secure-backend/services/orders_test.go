@@ -0,0 +1,66 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"pgregory.net/rapid"
+)
+
+func TestCanTransitionOrder(t *testing.T) {
+	tests := []struct {
+		from, to string
+		allowed  bool
+	}{
+		{OrderStatusPending, OrderStatusPaid, true},
+		{OrderStatusPending, OrderStatusCancelled, true},
+		{OrderStatusPending, OrderStatusShipped, false},
+		{OrderStatusPaid, OrderStatusShipped, true},
+		{OrderStatusPaid, OrderStatusRefunded, true},
+		{OrderStatusShipped, OrderStatusDelivered, true},
+		{OrderStatusShipped, OrderStatusCancelled, false},
+		{OrderStatusDelivered, OrderStatusRefunded, true},
+		{OrderStatusDelivered, OrderStatusPending, false},
+		{OrderStatusCancelled, OrderStatusPaid, false},
+		{OrderStatusRefunded, OrderStatusPaid, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+"_to_"+tt.to, func(t *testing.T) {
+			assert.Equal(t, tt.allowed, CanTransitionOrder(tt.from, tt.to))
+		})
+	}
+}
+
+// TestOrderWorkflowWalks follows random sequences of requested transitions from a new order:
+// the order only ever holds known statuses, never returns to a status it left, and stops
+// moving once cancelled or refunded.
+func TestOrderWorkflowWalks(t *testing.T) {
+	statuses := []string{
+		OrderStatusPending, OrderStatusPaid, OrderStatusShipped,
+		OrderStatusDelivered, OrderStatusCancelled, OrderStatusRefunded,
+	}
+
+	rapid.Check(t, func(t *rapid.T) {
+		current := OrderStatusPending
+		visited := map[string]bool{current: true}
+
+		requests := rapid.SliceOf(rapid.SampledFrom(statuses)).Draw(t, "requests")
+		for _, to := range requests {
+			if !CanTransitionOrder(current, to) {
+				continue
+			}
+			if current == OrderStatusCancelled || current == OrderStatusRefunded {
+				t.Fatalf("terminal status %s moved to %s", current, to)
+			}
+			if !IsValidOrderStatus(to) {
+				t.Fatalf("transition to unknown status %q", to)
+			}
+			if visited[to] {
+				t.Fatalf("order returned to %s", to)
+			}
+			visited[to] = true
+			current = to
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"secure-backend/database"
+	"secure-backend/models"
+	"strings"
+	"time"
+)
+
+// partnerKeyPrefix marks partner API keys so leaked keys are easy to recognize
+const partnerKeyPrefix = "sspk_"
+
+// CatalogChangeRetention is how long changes stay in the catalog change feed. Partners that
+// fall further behind get their cursor refused and resync from the catalog.
+const CatalogChangeRetention = 30 * 24 * time.Hour
+
+// ErrInvalidPartnerKey is returned for partner keys that don't exist or were revoked
+var ErrInvalidPartnerKey = errors.New("invalid API key")
+
+// IssuePartnerAPIKey creates a partner key and returns it with the generated secret, which
+// is only stored as a hash and must be handed to the partner now
+func IssuePartnerAPIKey(ctx context.Context, key *models.PartnerAPIKey, audit *models.AuditEntry) (*models.PartnerAPIKey, string, error) {
+	secret, err := randomSecret(24)
+	if err != nil {
+		return nil, "", err
+	}
+	secret = partnerKeyPrefix + secret
+	key.KeyPrefix = secret[:len(partnerKeyPrefix)+6]
+	audit.Detail = fmt.Sprintf("partner key %q (%s) with fields %s", key.Name, key.KeyPrefix, strings.Join(key.Fields, ","))
+
+	created, err := database.CreatePartnerAPIKey(ctx, key, hashSecret(secret), audit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	RecordSecurityEvent(ctx, &models.SecurityEvent{
+		Type:      models.SecurityAPIKeyCreated,
+		Severity:  models.SeverityMedium,
+		UserID:    audit.ActorID,
+		IPAddress: audit.IPAddress,
+		Detail:    fmt.Sprintf("%s issued %s", audit.ActorEmail, audit.Detail),
+	})
+	return created, secret, nil
+}
+
+// AuthenticatePartnerKey returns the live partner key matching secret
+func AuthenticatePartnerKey(ctx context.Context, secret string) (*models.PartnerAPIKey, error) {
+	secret = strings.TrimSpace(secret)
+	if !strings.HasPrefix(secret, partnerKeyPrefix) {
+		return nil, ErrInvalidPartnerKey
+	}
+
+	key, err := database.GetPartnerAPIKeyByHash(ctx, hashSecret(secret))
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidPartnerKey
+	}
+	return key, err
+}
+
+// StartCatalogChangePruner periodically deletes catalog changes older than
+// CatalogChangeRetention until ctx is cancelled
+func StartCatalogChangePruner(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				deleted, err := database.DeleteCatalogChangesBefore(ctx, clk.Now().Add(-CatalogChangeRetention))
+				if err != nil {
+					log.Printf("Failed to prune catalog changes: %v", err)
+				} else if deleted > 0 {
+					log.Printf("Pruned %d expired catalog changes", deleted)
+				}
+			}
+		}
+	}()
+}
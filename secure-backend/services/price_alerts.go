@@ -0,0 +1,39 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/notifications"
+)
+
+// NotifyPriceDrops notifies buyers with a price alert on a wishlisted product when its new
+// price is below the price they were last alerted at (or added it at). Only published
+// products alert. Failures are logged; a price change never fails because of alerts.
+func NotifyPriceDrops(ctx context.Context, product *models.Product) {
+	if product.Status != "published" {
+		return
+	}
+
+	alerts, err := database.ClaimPriceDropAlerts(ctx, product.ID, product.Price)
+	if err != nil {
+		log.Printf("Failed to check price alerts for product %s: %v", product.ID, err)
+		return
+	}
+
+	for _, alert := range alerts {
+		notifications.Dispatch(notifications.Notification{
+			UserID: alert.UserID,
+			Type:   notifications.TypePriceDrop,
+			Title:  "Price drop",
+			Body:   fmt.Sprintf("%s is now %s (was %s).", product.Name, alert.Price, alert.PreviousPrice),
+			Data: map[string]string{
+				"product_id":     alert.ProductID,
+				"price":          alert.Price.String(),
+				"previous_price": alert.PreviousPrice.String(),
+			},
+		})
+	}
+}
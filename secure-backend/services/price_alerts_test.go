@@ -0,0 +1,125 @@
+//go:build e2e
+
+// Wishlist price alert tests against a real PostgreSQL database (with database/schema.sql applied):
+//
+//	TEST_DATABASE_URL=postgres://localhost/secureshop_test?sslmode=disable go test -tags e2e -run TestNotifyPriceDrops ./services
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/money"
+	"secure-backend/notifications"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingChannel keeps the notifications dispatched to a set of users. The default
+// dispatcher can't drop channels, so it ignores everyone else once registered.
+type recordingChannel struct {
+	mu    sync.Mutex
+	users map[string]bool
+	sent  []notifications.Notification
+}
+
+func (c *recordingChannel) Name() string { return "recording" }
+
+func (c *recordingChannel) Send(ctx context.Context, n notifications.Notification) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.users[n.UserID] {
+		c.sent = append(c.sent, n)
+	}
+	return nil
+}
+
+// take returns and forgets the notifications received so far
+func (c *recordingChannel) take() []notifications.Notification {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sent := c.sent
+	c.sent = nil
+	return sent
+}
+
+func TestNotifyPriceDrops(t *testing.T) {
+	users := createTestUsers(t, "pricedrop", "seller", "buyer", "buyer", "buyer")
+	seller, watcher, bargainHunter, browser := users[0], users[1], users[2], users[3]
+	ctx := context.Background()
+
+	channel := &recordingChannel{users: map[string]bool{watcher.ID: true, bargainHunter.ID: true, browser.ID: true}}
+	notifications.Register(channel)
+
+	product := &models.Product{Name: "Alert kettle", Price: money.FromFloat(10), Stock: 5, Status: "published", SellerID: seller.ID}
+	require.NoError(t, database.DB.GetContext(ctx, &product.ID, `
+		INSERT INTO products (name, price, stock, status, seller_id) VALUES ($1, $2, $3, $4, $5) RETURNING id
+	`, product.Name, product.Price, product.Stock, product.Status, product.SellerID))
+
+	target := money.FromFloat(6)
+	_, err := database.AddToWishlist(ctx, watcher.ID, product.ID, true, nil)
+	require.NoError(t, err)
+	_, err = database.AddToWishlist(ctx, bargainHunter.ID, product.ID, true, &target)
+	require.NoError(t, err)
+	browsed, err := database.AddToWishlist(ctx, browser.ID, product.ID, false, nil)
+	require.NoError(t, err)
+
+	// reprice sets the product's price and returns who was alerted, with the prices of each alert.
+	// Notifications are delivered asynchronously, so it waits for the expected number.
+	reprice := func(price float64, want int) map[string][2]string {
+		t.Helper()
+		product.Price = money.FromFloat(price)
+		_, err := database.DB.ExecContext(ctx, `UPDATE products SET price = $2, status = $3 WHERE id = $1`, product.ID, product.Price, product.Status)
+		require.NoError(t, err)
+		NotifyPriceDrops(ctx, product)
+
+		var sent []notifications.Notification
+		if want > 0 {
+			require.Eventually(t, func() bool {
+				sent = append(sent, channel.take()...)
+				return len(sent) >= want
+			}, 5*time.Second, 10*time.Millisecond)
+		}
+		time.Sleep(50 * time.Millisecond) // let unexpected alerts arrive
+		sent = append(sent, channel.take()...)
+
+		alerted := make(map[string][2]string, len(sent))
+		for _, n := range sent {
+			assert.Equal(t, notifications.TypePriceDrop, n.Type)
+			assert.Equal(t, product.ID, n.Data["product_id"])
+			alerted[n.UserID] = [2]string{n.Data["previous_price"], n.Data["price"]}
+		}
+		return alerted
+	}
+
+	// A drop alerts buyers with an alert whose target it reaches
+	assert.Equal(t, map[string][2]string{watcher.ID: {"10.00", "9.00"}}, reprice(9, 1))
+
+	// Each drop alerts once, measured from the price last alerted at
+	assert.Empty(t, reprice(9, 0))
+	assert.Equal(t, map[string][2]string{
+		watcher.ID:       {"9.00", "5.00"},
+		bargainHunter.ID: {"10.00", "5.00"},
+	}, reprice(5, 2))
+	assert.Empty(t, reprice(8, 0), "price rises don't alert")
+	assert.Empty(t, reprice(7, 0), "a drop that stays above the last alert doesn't alert")
+
+	// Turning an alert on starts measuring from the current price
+	_, err = database.UpdateWishlistItem(ctx, browsed.ID, browser.ID, true, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][2]string{browser.ID: {"7.00", "6.00"}}, reprice(6, 1))
+
+	// Products that aren't published don't alert
+	product.Status = "draft"
+	assert.Empty(t, reprice(1, 0))
+	wishlist, err := database.GetWishlist(ctx, watcher.ID)
+	require.NoError(t, err)
+	require.Len(t, wishlist, 1)
+	assert.Equal(t, money.FromFloat(5), wishlist[0].AlertPrice)
+	assert.NotNil(t, wishlist[0].LastAlertedAt)
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+	"log"
+	"os"
+	"secure-backend/database"
+	"time"
+)
+
+// defaultRequestAuditRetention is how long request audit entries are kept unless
+// REQUEST_AUDIT_RETENTION says otherwise
+const defaultRequestAuditRetention = 90 * 24 * time.Hour
+
+// RequestAuditRetention returns how long request audit entries are kept, configurable via
+// REQUEST_AUDIT_RETENTION (e.g. "720h")
+func RequestAuditRetention() time.Duration {
+	if value := os.Getenv("REQUEST_AUDIT_RETENTION"); value != "" {
+		if retention, err := time.ParseDuration(value); err == nil && retention > 0 {
+			return retention
+		}
+		log.Printf("Invalid REQUEST_AUDIT_RETENTION %q, using %s", value, defaultRequestAuditRetention)
+	}
+	return defaultRequestAuditRetention
+}
+
+// StartRequestAuditPruner periodically deletes request audit entries older than the
+// retention period until ctx is cancelled
+func StartRequestAuditPruner(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				deleted, err := database.DeleteRequestAuditsBefore(ctx, clk.Now().Add(-RequestAuditRetention()))
+				if err != nil {
+					log.Printf("Failed to prune request audit log: %v", err)
+				} else if deleted > 0 {
+					log.Printf("Pruned %d expired request audit entries", deleted)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/notifications"
+	"strconv"
+	"time"
+)
+
+// savedSearchBatchSize is how many catalog changes a saved search alert run reads at a time
+const savedSearchBatchSize = 500
+
+// StartSavedSearchAlerts checks the catalog change feed every interval for products that
+// newly match buyers' saved searches with alerts on, and notifies the buyers, until ctx is
+// cancelled
+func StartSavedSearchAlerts(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := RunSavedSearchAlerts(ctx); err != nil {
+					log.Printf("Failed to check saved search alerts: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// RunSavedSearchAlerts reads the catalog changes made since the last run and notifies buyers
+// of the products that newly match their saved searches, one notification per search
+func RunSavedSearchAlerts(ctx context.Context) error {
+	for {
+		matches, read, err := database.ClaimSavedSearchMatches(ctx, savedSearchBatchSize)
+		if err != nil {
+			return err
+		}
+		notifySavedSearchMatches(matches)
+		if read < savedSearchBatchSize {
+			return nil
+		}
+	}
+}
+
+// notifySavedSearchMatches sends one notification per saved search for its new matches,
+// which arrive grouped by search
+func notifySavedSearchMatches(matches []models.SavedSearchMatch) {
+	for start := 0; start < len(matches); {
+		end := start + 1
+		for end < len(matches) && matches[end].SavedSearchID == matches[start].SavedSearchID {
+			end++
+		}
+		notifications.Dispatch(savedSearchNotification(matches[start:end]))
+		start = end
+	}
+}
+
+// savedSearchNotification describes the new matches of one saved search
+func savedSearchNotification(matches []models.SavedSearchMatch) notifications.Notification {
+	first := matches[0]
+	body := fmt.Sprintf("%s (%s) matches your saved search %q.", first.ProductName, first.Price, first.SearchName)
+	if len(matches) > 1 {
+		body = fmt.Sprintf("%d new products match your saved search %q, including %s.", len(matches), first.SearchName, first.ProductName)
+	}
+
+	data := map[string]string{
+		"saved_search_id": first.SavedSearchID,
+		"product_id":      first.ProductID,
+		"count":           strconv.Itoa(len(matches)),
+	}
+	return notifications.Notification{
+		UserID: first.UserID,
+		Type:   notifications.TypeSavedSearch,
+		Title:  "New matches for a saved search",
+		Body:   body,
+		Data:   data,
+	}
+}
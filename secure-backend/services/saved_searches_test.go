@@ -0,0 +1,30 @@
+package services
+
+import (
+	"testing"
+
+	"secure-backend/models"
+	"secure-backend/money"
+	"secure-backend/notifications"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSavedSearchNotification(t *testing.T) {
+	match := models.SavedSearchMatch{
+		SavedSearchID: "search-1", UserID: "buyer-1", SearchName: "Linen",
+		ProductID: "product-1", ProductName: "Linen Throw", Price: money.FromFloat(79),
+	}
+
+	n := savedSearchNotification([]models.SavedSearchMatch{match})
+	assert.Equal(t, "buyer-1", n.UserID)
+	assert.Equal(t, notifications.TypeSavedSearch, n.Type)
+	assert.Equal(t, `Linen Throw (79.00) matches your saved search "Linen".`, n.Body)
+	assert.Equal(t, map[string]string{"saved_search_id": "search-1", "product_id": "product-1", "count": "1"}, n.Data)
+
+	other := match
+	other.ProductID, other.ProductName = "product-2", "Linen Napkins"
+	n = savedSearchNotification([]models.SavedSearchMatch{match, other})
+	assert.Equal(t, `2 new products match your saved search "Linen", including Linen Throw.`, n.Body)
+	assert.Equal(t, "2", n.Data["count"])
+}
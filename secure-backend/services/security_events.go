@@ -0,0 +1,131 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"os"
+	"secure-backend/database"
+	"secure-backend/events"
+	"secure-backend/models"
+	"secure-backend/outbound"
+	"time"
+)
+
+const (
+	// defaultSecurityEventRetention is how long security events are kept unless
+	// SECURITY_EVENT_RETENTION says otherwise
+	defaultSecurityEventRetention = 365 * 24 * time.Hour
+	// SecurityAlertSignatureHeader carries the HMAC-SHA256 of an alert body when
+	// SECURITY_ALERT_WEBHOOK_SECRET is set
+	SecurityAlertSignatureHeader = "X-Signature-256"
+)
+
+// securityAlertClient posts alerts to SECURITY_ALERT_WEBHOOK_URL
+var securityAlertClient = outbound.NewClient("security-alerts", 10*time.Second)
+
+// RecordSecurityEvent adds an event to the security event log and alerts high-severity
+// events to SECURITY_ALERT_WEBHOOK_URL in the background. Failures are logged rather than
+// returned, so the action that raised the event isn't affected. The event is recorded even
+// if ctx is cancelled, so a client can't avoid it by dropping the connection.
+func RecordSecurityEvent(ctx context.Context, event *models.SecurityEvent) {
+	if err := database.RecordSecurityEvent(context.WithoutCancel(ctx), event); err != nil {
+		log.Printf("Failed to record %s security event: %v", event.Type, err)
+		event.CreatedAt = clk.Now()
+	}
+
+	if event.Severity == models.SeverityHigh {
+		if url := os.Getenv("SECURITY_ALERT_WEBHOOK_URL"); url != "" {
+			go sendSecurityAlert(url, *event)
+		}
+	}
+}
+
+// SecurityAlertVersion returns the contract version of alert bodies, configurable via
+// SECURITY_ALERT_WEBHOOK_VERSION (v1 or v2). It defaults to v1, the body alerts had before
+// they were versioned.
+func SecurityAlertVersion() string {
+	switch version := os.Getenv("SECURITY_ALERT_WEBHOOK_VERSION"); version {
+	case "":
+		return events.V1
+	case events.V1, events.V2:
+		return version
+	default:
+		log.Printf("Invalid SECURITY_ALERT_WEBHOOK_VERSION %q, using %s", version, events.V1)
+		return events.V1
+	}
+}
+
+// sendSecurityAlert posts an event to the alert webhook. The body is the security_alert
+// event contract, whose version is named in X-Event-Version; every version carries a "text"
+// summary, which chat webhooks such as Slack display.
+func sendSecurityAlert(url string, event models.SecurityEvent) {
+	version := SecurityAlertVersion()
+	body, err := events.EncodeSecurityAlert(version, event)
+	if err != nil {
+		log.Printf("Failed to encode security alert: %v", err)
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Failed to create security alert: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(events.VersionHeader, version)
+	if secret := os.Getenv("SECURITY_ALERT_WEBHOOK_SECRET"); secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		req.Header.Set(SecurityAlertSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := securityAlertClient.Do(req)
+	if err != nil {
+		log.Printf("Failed to send security alert for event %s: %v", event.ID, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("Security alert webhook returned %d for event %s", resp.StatusCode, event.ID)
+	}
+}
+
+// SecurityEventRetention returns how long security events are kept, configurable via
+// SECURITY_EVENT_RETENTION (e.g. "2160h")
+func SecurityEventRetention() time.Duration {
+	if value := os.Getenv("SECURITY_EVENT_RETENTION"); value != "" {
+		if retention, err := time.ParseDuration(value); err == nil && retention > 0 {
+			return retention
+		}
+		log.Printf("Invalid SECURITY_EVENT_RETENTION %q, using %s", value, defaultSecurityEventRetention)
+	}
+	return defaultSecurityEventRetention
+}
+
+// StartSecurityEventPruner periodically deletes security events older than the retention
+// period until ctx is cancelled
+func StartSecurityEventPruner(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				deleted, err := database.DeleteSecurityEventsBefore(ctx, clk.Now().Add(-SecurityEventRetention()))
+				if err != nil {
+					log.Printf("Failed to prune security events: %v", err)
+				} else if deleted > 0 {
+					log.Printf("Pruned %d expired security events", deleted)
+				}
+			}
+		}
+	}()
+}
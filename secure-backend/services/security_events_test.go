@@ -0,0 +1,70 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"secure-backend/events"
+	"secure-backend/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendSecurityAlertSignsBody(t *testing.T) {
+	var body []byte
+	var signature, version string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(SecurityAlertSignatureHeader)
+		version = r.Header.Get(events.VersionHeader)
+	}))
+	defer server.Close()
+
+	t.Setenv("SECURITY_ALERT_WEBHOOK_SECRET", "alert-secret")
+	t.Setenv("SECURITY_ALERT_WEBHOOK_VERSION", "")
+	sendSecurityAlert(server.URL, models.SecurityEvent{
+		ID:        "event-1",
+		Type:      models.SecurityAdminLoginFailed,
+		Severity:  models.SeverityHigh,
+		IPAddress: "203.0.113.9",
+		Detail:    `failed break-glass login for "ops@example.com"`,
+	})
+
+	var alert struct {
+		Text  string               `json:"text"`
+		Event models.SecurityEvent `json:"event"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &alert))
+	assert.Equal(t, `[high] Security event admin_login_failed: failed break-glass login for "ops@example.com" (from 203.0.113.9)`, alert.Text)
+	assert.Equal(t, "event-1", alert.Event.ID)
+	assert.Equal(t, events.V1, version)
+
+	mac := hmac.New(sha256.New, []byte("alert-secret"))
+	mac.Write(body)
+	assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), signature)
+}
+
+func TestSecurityAlertVersion(t *testing.T) {
+	t.Setenv("SECURITY_ALERT_WEBHOOK_VERSION", "v2")
+	assert.Equal(t, events.V2, SecurityAlertVersion())
+
+	t.Setenv("SECURITY_ALERT_WEBHOOK_VERSION", "v9")
+	assert.Equal(t, events.V1, SecurityAlertVersion(), "unknown versions fall back to v1")
+}
+
+func TestSecurityEventRetention(t *testing.T) {
+	t.Setenv("SECURITY_EVENT_RETENTION", "")
+	assert.Equal(t, defaultSecurityEventRetention, SecurityEventRetention())
+
+	t.Setenv("SECURITY_EVENT_RETENTION", "2160h")
+	assert.Equal(t, 90*24*time.Hour, SecurityEventRetention())
+
+	t.Setenv("SECURITY_EVENT_RETENTION", "-1h")
+	assert.Equal(t, defaultSecurityEventRetention, SecurityEventRetention())
+}
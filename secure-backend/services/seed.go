@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/money"
+	"secure-backend/utils"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SeedOptions sizes the data the seed command generates on top of the demo data
+type SeedOptions struct {
+	Buyers            int
+	Sellers           int
+	ProductsPerSeller int
+	RandomSeed        int64 // the same seed generates the same data
+}
+
+// DefaultSeedOptions are the sizes of `main seed` without flags
+var DefaultSeedOptions = SeedOptions{Buyers: 20, Sellers: 5, ProductsPerSeller: 12, RandomSeed: 1}
+
+// seedCategory describes the products generated in a category of the demo catalog
+type seedCategory struct {
+	slug      string
+	items     []string
+	materials []string
+	minPrice  float64
+	maxPrice  float64
+}
+
+var seedCategories = []seedCategory{
+	{"kitchen", []string{"Chef's Knife", "Cutting Board", "Dutch Oven", "Espresso Cups", "Salad Bowl", "Spice Rack", "Teapot", "Utensil Set"},
+		[]string{"Oak", "Ceramic", "Enamel", "Stainless Steel", "Bamboo", "Stoneware"}, 12, 140},
+	{"home", []string{"Cushion Cover", "Wall Clock", "Vase", "Wool Rug", "Photo Frame", "Storage Basket", "Bedside Lamp", "Mirror"},
+		[]string{"Linen", "Rattan", "Brass", "Cotton", "Glass", "Walnut"}, 15, 220},
+	{"stationery", []string{"Notebook", "Pencil Case", "Desk Calendar", "Letter Opener", "Sketchbook", "Bookmark Set", "Pen Stand"},
+		[]string{"Leather", "Recycled Paper", "Cork", "Brass", "Beech", "Linen"}, 5, 80},
+	{"outdoors", []string{"Camping Mug", "Daypack", "Hammock", "Lantern", "Folding Stool", "Trail Flask", "Rain Poncho"},
+		[]string{"Canvas", "Titanium", "Ripstop Nylon", "Aluminium", "Waxed Cotton", "Steel"}, 10, 180},
+}
+
+var seedAdjectives = []string{"Classic", "Everyday", "Handmade", "Minimal", "Rustic", "Heritage", "Compact", "Nordic", "Vintage", "Studio"}
+
+var seedFeatures = []string{
+	"Made in small batches.",
+	"Ships in plastic-free packaging.",
+	"Backed by a two-year warranty.",
+	"Each piece varies slightly in colour.",
+	"Designed to last for years of daily use.",
+	"Easy to clean with warm soapy water.",
+}
+
+var (
+	seedFirstNames = []string{"amelia", "oliver", "isla", "noah", "ava", "leo", "mia", "arthur", "freya", "jack", "sofia", "theo", "grace", "oscar", "ella", "henry"}
+	seedLastNames  = []string{"hughes", "patel", "walsh", "okafor", "nguyen", "kowalski", "silva", "murphy", "larsen", "rossi", "haddad", "fischer"}
+	seedShopNames  = []string{"oakandiron", "northlight", "fernhouse", "papermill", "copperleaf", "saltandstone", "wildtrail", "greyhound"}
+)
+
+// DevSeed returns the demo data (see demoSeed) plus the generated buyers with carts and
+// sellers with products of opts, for local development and load demos
+func DevSeed(now time.Time, opts SeedOptions) *models.DemoSeed {
+	seed := demoSeed(now)
+	rng := rand.New(rand.NewSource(opts.RandomSeed))
+	seed.Users = append([]models.DemoUser(nil), seed.Users...)
+
+	emails := map[string]bool{}
+	user := func(local, role string) models.DemoUser {
+		email := local + "@" + models.DemoEmailDomain
+		for n := 2; emails[email]; n++ {
+			email = fmt.Sprintf("%s%d@%s", local, n, models.DemoEmailDomain)
+		}
+		emails[email] = true
+		id, _ := uuid.NewRandomFromReader(rng) // never fails reading from a rand.Rand
+		return models.DemoUser{ID: id.String(), Email: email, Role: role}
+	}
+	pick := func(values []string) string { return values[rng.Intn(len(values))] }
+
+	slugs := map[string]bool{}
+	for _, product := range seed.Products {
+		slugs[product.Slug] = true
+	}
+	for i := 0; i < opts.Sellers; i++ {
+		seller := user(seedShopNames[i%len(seedShopNames)], "seller")
+		seed.Users = append(seed.Users, seller)
+
+		for j := 0; j < opts.ProductsPerSeller; j++ {
+			category := seedCategories[rng.Intn(len(seedCategories))]
+			material, item := pick(category.materials), pick(category.items)
+			name := fmt.Sprintf("%s %s %s", pick(seedAdjectives), material, item)
+			slug := "demo-" + utils.Slugify(name)
+			for n := 2; slugs[slug]; n++ {
+				slug = fmt.Sprintf("demo-%s-%d", utils.Slugify(name), n)
+			}
+			slugs[slug] = true
+
+			// Whole prices ending in .99 or .00, and about one product in ten sold out
+			price := category.minPrice + rng.Float64()*(category.maxPrice-category.minPrice)
+			if rng.Intn(2) == 0 {
+				price = float64(int(price)) + 0.99
+			} else {
+				price = float64(int(price))
+			}
+			stock := 0
+			if rng.Intn(10) != 0 {
+				stock = 1 + rng.Intn(150)
+			}
+
+			seed.Products = append(seed.Products, models.DemoProduct{
+				SellerID: seller.ID, CategorySlug: category.slug, Slug: slug, Name: name,
+				Description: fmt.Sprintf("%s %s in %s. %s", pick(seedAdjectives), strings.ToLower(item), strings.ToLower(material), pick(seedFeatures)),
+				Price:       money.FromFloat(price), Stock: stock,
+			})
+		}
+	}
+
+	for i := 0; i < opts.Buyers; i++ {
+		buyer := user(pick(seedFirstNames)+"."+pick(seedLastNames), "buyer")
+		seed.Users = append(seed.Users, buyer)
+
+		// Up to four different products in stock, in quantities they have
+		inCart := map[int]bool{}
+		for n := rng.Intn(5); n > 0; n-- {
+			product := rng.Intn(len(seed.Products))
+			if inCart[product] || seed.Products[product].Stock == 0 {
+				continue
+			}
+			inCart[product] = true
+			seed.CartItems = append(seed.CartItems, models.DemoCartItem{
+				UserID: buyer.ID, Product: product, Quantity: 1 + rng.Intn(min(3, seed.Products[product].Stock)),
+			})
+		}
+	}
+	return seed
+}
+
+// SeedDevData replaces the demo accounts and everything they own with DevSeed data, whether
+// or not it was seeded before. Other users and their data are left alone.
+func SeedDevData(ctx context.Context, opts SeedOptions) (*models.DemoSeed, error) {
+	seed := DevSeed(clk.Now(), opts)
+	if _, err := database.ResetDemoData(ctx, seed, time.Time{}); err != nil {
+		return nil, err
+	}
+	return seed, nil
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"context"
+	"secure-backend/database"
+	"secure-backend/models"
+	"secure-backend/moderation"
+	"sort"
+	"time"
+)
+
+// CreateSellerRating screens the comment of a buyer's seller rating the way messages are
+// screened and stores the rating, published right away. Admins can hide it afterwards.
+func CreateSellerRating(ctx context.Context, rating *models.SellerRating) error {
+	screened := moderation.ScreenMessage(rating.Comment)
+	rating.Comment, rating.Flags = screened.Text, screened.Flags
+	return database.CreateSellerRating(ctx, rating)
+}
+
+// GetSellerQuality scores every seller over the given number of days, lowest score first so
+// the sellers that need attention come up top
+func GetSellerQuality(ctx context.Context, days int) ([]models.SellerQuality, error) {
+	stats, err := database.GetSellerQualityStats(ctx, clk.Now().Add(-time.Duration(days)*24*time.Hour))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range stats {
+		stats[i].ComputeScore()
+	}
+	sort.SliceStable(stats, func(i, j int) bool { return stats[i].Score < stats[j].Score })
+	return stats, nil
+}
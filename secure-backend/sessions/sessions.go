@@ -0,0 +1,242 @@
+// Package sessions implements the optional cookie session mode for the web storefront:
+// after signing in with Supabase the browser exchanges its JWT for a server-side session
+// whose token is kept in an encrypted httpOnly cookie, out of reach of page scripts.
+// API clients keep using Bearer JWTs.
+package sessions
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"secure-backend/database"
+	"secure-backend/models"
+	"time"
+)
+
+const (
+	// CookieName is the session cookie
+	CookieName = "secureshop_session"
+	// CSRFHeader must echo the session's CSRF token on requests that change state
+	CSRFHeader = "X-CSRF-Token"
+	// defaultTTL is how long a session lasts without SESSION_TTL
+	defaultTTL = 12 * time.Hour
+)
+
+var (
+	// ErrDisabled is returned when cookie sessions are not enabled on this deployment
+	ErrDisabled = errors.New("cookie sessions are not enabled")
+	// ErrInvalidSession is returned for cookies that can't be decrypted or name no live session
+	ErrInvalidSession = errors.New("invalid or expired session")
+)
+
+var (
+	aead   cipher.AEAD // nil unless SESSION_MODE=cookie
+	ttl    = defaultTTL
+	secure = true
+)
+
+// Init enables cookie sessions when SESSION_MODE=cookie. SESSION_COOKIE_KEY must then hold
+// a 32-byte key (hex or base64) that encrypts the cookie. Expired sessions are purged
+// until ctx is cancelled.
+func Init(ctx context.Context) error {
+	if os.Getenv("SESSION_MODE") != "cookie" {
+		return nil
+	}
+
+	key, err := decodeKey(os.Getenv("SESSION_COOKIE_KEY"))
+	if err != nil {
+		return err
+	}
+	if err := setKey(key); err != nil {
+		return err
+	}
+
+	if value := os.Getenv("SESSION_TTL"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid SESSION_TTL %q", value)
+		}
+		ttl = d
+	}
+	// Browsers only send Secure cookies over HTTPS; allow plain HTTP for local development
+	secure = os.Getenv("SESSION_COOKIE_SECURE") != "false"
+
+	go purgeExpired(ctx, time.Hour)
+	return nil
+}
+
+// decodeKey accepts a 32-byte key encoded as hex or base64
+func decodeKey(value string) ([]byte, error) {
+	if key, err := hex.DecodeString(value); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(value); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, errors.New("SESSION_COOKIE_KEY must be a 32-byte key in hex or base64")
+}
+
+// setKey configures the AES-256-GCM cipher that encrypts cookies
+func setKey(key []byte) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	aead = gcm
+	return nil
+}
+
+// Enabled reports whether cookie sessions are enabled on this deployment
+func Enabled() bool {
+	return aead != nil
+}
+
+// Create starts a session for a user authenticated by JWT and returns the cookie to set
+func Create(ctx context.Context, user *models.AuthUser, userAgent string) (*http.Cookie, *models.Session, error) {
+	if !Enabled() {
+		return nil, nil, ErrDisabled
+	}
+
+	token, err := randomHex(32)
+	if err != nil {
+		return nil, nil, err
+	}
+	csrf, err := randomHex(32)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	session := &models.Session{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		Email:     user.Email,
+		CSRFToken: csrf,
+		UserAgent: userAgent,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := database.CreateSession(ctx, session); err != nil {
+		return nil, nil, err
+	}
+
+	value, err := encrypt(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cookie(value, session.ExpiresAt), session, nil
+}
+
+// Open returns the live session named by an encrypted cookie value
+func Open(ctx context.Context, value string) (*models.Session, error) {
+	if !Enabled() {
+		return nil, ErrDisabled
+	}
+	token, err := decrypt(value)
+	if err != nil {
+		return nil, ErrInvalidSession
+	}
+	session, err := database.GetSession(ctx, hashToken(token), time.Now())
+	if err != nil {
+		return nil, ErrInvalidSession
+	}
+	return session, nil
+}
+
+// Destroy ends the session named by an encrypted cookie value and returns a cookie that
+// clears it in the browser
+func Destroy(ctx context.Context, value string) (*http.Cookie, error) {
+	if !Enabled() {
+		return nil, ErrDisabled
+	}
+	if token, err := decrypt(value); err == nil {
+		if err := database.DeleteSession(ctx, hashToken(token)); err != nil {
+			return nil, err
+		}
+	}
+	return cookie("", time.Unix(0, 0)), nil
+}
+
+// cookie builds the session cookie: httpOnly so scripts can't read it, SameSite=Lax so
+// other sites can't send it with cross-site POSTs, and scoped to the API
+func cookie(value string, expires time.Time) *http.Cookie {
+	c := &http.Cookie{
+		Name:     CookieName,
+		Value:    value,
+		Path:     "/api",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if value == "" {
+		c.MaxAge = -1
+	}
+	return c
+}
+
+// encrypt seals a session token as base64url(nonce || ciphertext)
+func encrypt(token string) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(token), []byte(CookieName))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt opens a cookie value produced by encrypt
+func decrypt(value string) (string, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrInvalidSession
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	token, err := aead.Open(nil, nonce, ciphertext, []byte(CookieName))
+	if err != nil {
+		return "", ErrInvalidSession
+	}
+	return string(token), nil
+}
+
+// hashToken is what the database stores, so a leaked sessions table can't be replayed
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// purgeExpired periodically deletes expired sessions
+func purgeExpired(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := database.DeleteExpiredSessions(ctx, time.Now()); err != nil {
+				log.Printf("Failed to delete expired sessions: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package sessions
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCookieEncryptionRoundTrip(t *testing.T) {
+	require.NoError(t, setKey(bytes.Repeat([]byte{7}, 32)))
+	defer func() { aead = nil }()
+
+	value, err := encrypt("session-token")
+	require.NoError(t, err)
+	assert.NotContains(t, value, "session-token")
+
+	token, err := decrypt(value)
+	require.NoError(t, err)
+	assert.Equal(t, "session-token", token)
+
+	// Tampered or foreign cookies are rejected
+	tampered := []byte(value)
+	tampered[len(tampered)-1] ^= 1
+	_, err = decrypt(string(tampered))
+	assert.ErrorIs(t, err, ErrInvalidSession)
+	_, err = decrypt("not-a-cookie")
+	assert.ErrorIs(t, err, ErrInvalidSession)
+
+	// Each cookie uses a fresh nonce
+	again, err := encrypt("session-token")
+	require.NoError(t, err)
+	assert.NotEqual(t, value, again)
+}
+
+func TestDecodeKey(t *testing.T) {
+	_, err := decodeKey(strings.Repeat("ab", 32))
+	assert.NoError(t, err)
+	_, err = decodeKey("AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=")
+	assert.NoError(t, err)
+	_, err = decodeKey("too-short")
+	assert.Error(t, err)
+}
@@ -0,0 +1,188 @@
+// Package shipping buys shipping labels from a label provider. The provider (or an adapter in
+// front of a carrier aggregator) is reached over a small JSON API at SHIPPING_LABEL_API_URL:
+// POST /labels buys a label and returns its tracking number, cost and a URL of the label PDF.
+package shipping
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"secure-backend/money"
+	"secure-backend/outbound"
+	"strings"
+	"time"
+)
+
+// maxLabelSize caps the label PDFs downloaded from the provider
+const maxLabelSize = 10 << 20
+
+// ErrNotConfigured is returned when no label provider is configured
+var ErrNotConfigured = errors.New("shipping labels are not configured")
+
+// Parcel is the package a label is bought for
+type Parcel struct {
+	WeightKg float64 `json:"weight_kg"`
+}
+
+// LabelRequest asks the provider for a label. Addresses are free text, as buyers enter them.
+type LabelRequest struct {
+	Reference   string `json:"reference"` // the order ID, printed on the label
+	FromAddress string `json:"from_address"`
+	ToAddress   string `json:"to_address"`
+	Service     string `json:"service,omitempty"` // provider service level; the provider's default when empty
+	Parcel      Parcel `json:"parcel"`
+	LabelFormat string `json:"label_format"`
+}
+
+// Label is a label bought from the provider
+type Label struct {
+	ID             string       `json:"id"`
+	Carrier        string       `json:"carrier"`
+	Service        string       `json:"service"`
+	TrackingNumber string       `json:"tracking_number"`
+	Cost           money.Amount `json:"cost"`
+	Currency       string       `json:"currency"`
+	LabelURL       string       `json:"label_url"`
+}
+
+// LabelError is an error response from the label provider
+type LabelError struct {
+	StatusCode int
+	Message    string `json:"error"`
+}
+
+// Error implements the error interface
+func (e *LabelError) Error() string {
+	return fmt.Sprintf("label provider error %d: %s", e.StatusCode, e.Message)
+}
+
+// LabelClient is a client for the label provider's API
+type LabelClient struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+// NewLabelClient creates a client for the provider at baseURL authenticated with apiKey
+func NewLabelClient(baseURL, apiKey string) *LabelClient {
+	return &LabelClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		client:  outbound.NewClient("shipping-labels", 30*time.Second),
+	}
+}
+
+// BuyLabel buys a PDF label. The idempotency key makes retries return the same label
+// instead of buying (and paying for) another one.
+func (l *LabelClient) BuyLabel(ctx context.Context, request LabelRequest, idempotencyKey string) (*Label, error) {
+	request.LabelFormat = "pdf"
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.baseURL+"/labels", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+l.apiKey)
+	req.Header.Set("Idempotency-Key", idempotencyKey)
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("calling label provider: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		labelErr := &LabelError{StatusCode: resp.StatusCode}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(data, labelErr) != nil || labelErr.Message == "" {
+			labelErr.Message = strings.TrimSpace(string(data))
+		}
+		return nil, labelErr
+	}
+
+	var label Label
+	if err := json.NewDecoder(resp.Body).Decode(&label); err != nil {
+		return nil, fmt.Errorf("decoding label: %w", err)
+	}
+	if label.ID == "" || label.LabelURL == "" {
+		return nil, errors.New("label provider returned no label")
+	}
+	label.Currency = strings.ToLower(label.Currency)
+	return &label, nil
+}
+
+// DownloadLabel fetches the PDF of a bought label
+func (l *LabelClient) DownloadLabel(ctx context.Context, label *Label) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, label.LabelURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Label URLs on the provider's own host need the API key; presigned ones elsewhere don't
+	if strings.HasPrefix(label.LabelURL, l.baseURL+"/") {
+		req.Header.Set("Authorization", "Bearer "+l.apiKey)
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("downloading label: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return nil, &LabelError{StatusCode: resp.StatusCode, Message: "label download failed"}
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxLabelSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxLabelSize {
+		return nil, errors.New("label PDF is too large")
+	}
+	return data, nil
+}
+
+// labelClient is nil when SHIPPING_LABEL_API_URL is not set
+var labelClient *LabelClient
+
+// Init configures the label provider from SHIPPING_LABEL_API_URL and SHIPPING_LABEL_API_KEY
+func Init() {
+	if url := os.Getenv("SHIPPING_LABEL_API_URL"); url != "" {
+		labelClient = NewLabelClient(url, os.Getenv("SHIPPING_LABEL_API_KEY"))
+	}
+}
+
+// Enabled reports whether a label provider is configured
+func Enabled() bool {
+	return labelClient != nil
+}
+
+// DefaultService returns the service level labels are bought with unless the seller picks
+// one (SHIPPING_LABEL_SERVICE); empty leaves the choice to the provider
+func DefaultService() string {
+	return os.Getenv("SHIPPING_LABEL_SERVICE")
+}
+
+// BuyLabel buys a label from the configured provider
+func BuyLabel(ctx context.Context, request LabelRequest, idempotencyKey string) (*Label, error) {
+	if labelClient == nil {
+		return nil, ErrNotConfigured
+	}
+	return labelClient.BuyLabel(ctx, request, idempotencyKey)
+}
+
+// DownloadLabel fetches a label's PDF from the configured provider
+func DownloadLabel(ctx context.Context, label *Label) ([]byte, error) {
+	if labelClient == nil {
+		return nil, ErrNotConfigured
+	}
+	return labelClient.DownloadLabel(ctx, label)
+}
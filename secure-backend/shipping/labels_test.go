@@ -0,0 +1,68 @@
+package shipping
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"secure-backend/money"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuyAndDownloadLabel(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/labels":
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "Bearer test-key", r.Header.Get("Authorization"))
+			assert.Equal(t, "label-order-1", r.Header.Get("Idempotency-Key"))
+
+			var request LabelRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			assert.Equal(t, "order-1", request.Reference)
+			assert.Equal(t, "pdf", request.LabelFormat)
+			assert.Equal(t, 1.5, request.Parcel.WeightKg)
+			if request.Service == "overnight" {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				w.Write([]byte(`{"error":"service not available for this route"}`))
+				return
+			}
+
+			json.NewEncoder(w).Encode(Label{
+				ID: "lbl_1", Carrier: "UPS", Service: "ground", TrackingNumber: "1Z999", Cost: 745, Currency: "USD",
+				LabelURL: srv.URL + "/labels/lbl_1.pdf",
+			})
+		case "/labels/lbl_1.pdf":
+			assert.Equal(t, "Bearer test-key", r.Header.Get("Authorization"))
+			w.Write([]byte("%PDF-1.4"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	client := NewLabelClient(srv.URL+"/", "test-key")
+	request := LabelRequest{Reference: "order-1", FromAddress: "1 Dock Rd", ToAddress: "2 Main St", Parcel: Parcel{WeightKg: 1.5}}
+
+	label, err := client.BuyLabel(context.Background(), request, "label-order-1")
+	require.NoError(t, err)
+	assert.Equal(t, "1Z999", label.TrackingNumber)
+	assert.Equal(t, money.Amount(745), label.Cost)
+	assert.Equal(t, "usd", label.Currency)
+
+	pdf, err := client.DownloadLabel(context.Background(), label)
+	require.NoError(t, err)
+	assert.Equal(t, "%PDF-1.4", string(pdf))
+
+	request.Service = "overnight"
+	_, err = client.BuyLabel(context.Background(), request, "label-order-1")
+	var labelErr *LabelError
+	require.True(t, errors.As(err, &labelErr))
+	assert.Equal(t, http.StatusUnprocessableEntity, labelErr.StatusCode)
+	assert.Equal(t, "service not available for this route", labelErr.Message)
+}
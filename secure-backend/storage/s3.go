@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"secure-backend/outbound"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Error is an error response from the object store
+type S3Error struct {
+	StatusCode int
+	Body       string
+}
+
+// Error implements the error interface
+func (e *S3Error) Error() string {
+	return fmt.Sprintf("object store error %d: %s", e.StatusCode, e.Body)
+}
+
+// S3Client is a minimal client for S3-compatible object stores (AWS S3, MinIO, R2, ...).
+// Objects are addressed path-style: <endpoint>/<bucket>/<key>.
+type S3Client struct {
+	endpoint  string
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	publicURL string
+	client    *http.Client
+}
+
+// NewS3Client creates a client for the bucket. Uploaded objects are served from publicURL,
+// or from the bucket path on the endpoint when publicURL is empty.
+func NewS3Client(endpoint, bucket, region, accessKey, secretKey, publicURL string) *S3Client {
+	endpoint = strings.TrimRight(endpoint, "/")
+	if publicURL == "" {
+		publicURL = endpoint + "/" + bucket
+	}
+	return &S3Client{
+		endpoint:  endpoint,
+		bucket:    bucket,
+		region:    region,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		publicURL: strings.TrimRight(publicURL, "/"),
+		client:    outbound.NewClient("s3", 30*time.Second),
+	}
+}
+
+// PutObject stores data under key with a public-read ACL and returns its public URL
+func (s *S3Client) PutObject(ctx context.Context, key, contentType string, data []byte) (string, error) {
+	if err := s.putObject(ctx, key, contentType, data, "public-read"); err != nil {
+		return "", err
+	}
+	return s.publicURL + "/" + escapeKey(key), nil
+}
+
+// PutPrivateObject stores data under key with the bucket's default (private) ACL
+func (s *S3Client) PutPrivateObject(ctx context.Context, key, contentType string, data []byte) error {
+	return s.putObject(ctx, key, contentType, data, "")
+}
+
+// putObject stores data under key, with acl unless it is empty
+func (s *S3Client) putObject(ctx context.Context, key, contentType string, data []byte, acl string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if acl != "" {
+		req.Header.Set("X-Amz-Acl", acl)
+	}
+
+	payloadHash := sha256.Sum256(data)
+	s.sign(req, hex.EncodeToString(payloadHash[:]), time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("calling object store: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &S3Error{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return nil
+}
+
+// GetObject reads the object stored under key
+func (s *S3Client) GetObject(ctx context.Context, key string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	emptyPayload := sha256.Sum256(nil)
+	s.sign(req, hex.EncodeToString(emptyPayload[:]), time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("calling object store: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, &S3Error{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// objectURL returns the path-style URL of key
+func (s *S3Client) objectURL(key string) string {
+	return s.endpoint + "/" + s.bucket + "/" + escapeKey(key)
+}
+
+// sign adds an AWS Signature Version 4 Authorization header covering the host and every
+// header already set on the request
+func (s *S3Client) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery encodes query parameters sorted by name, as SigV4 requires
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var pairs []string
+	for _, name := range names {
+		values := append([]string(nil), query[name]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, url.QueryEscape(name)+"="+url.QueryEscape(value))
+		}
+	}
+	return strings.ReplaceAll(strings.Join(pairs, "&"), "+", "%20")
+}
+
+// escapeKey URL-encodes each segment of an object key, keeping the slashes
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+)
+
+// ErrNotConfigured is returned when no object store is configured
+var ErrNotConfigured = errors.New("object storage is not configured")
+
+// s3Client is nil when S3_BUCKET is not set
+var s3Client *S3Client
+
+// Init configures the object store from the S3_* environment variables
+func Init() {
+	bucket := os.Getenv("S3_BUCKET")
+	if bucket == "" {
+		return
+	}
+
+	endpoint := os.Getenv("S3_ENDPOINT")
+	region := os.Getenv("S3_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+
+	s3Client = NewS3Client(endpoint, bucket, region,
+		os.Getenv("S3_ACCESS_KEY_ID"), os.Getenv("S3_SECRET_ACCESS_KEY"), os.Getenv("S3_PUBLIC_URL"))
+}
+
+// Upload stores a publicly readable object and returns its URL
+func Upload(ctx context.Context, key, contentType string, data []byte) (string, error) {
+	if s3Client == nil {
+		return "", ErrNotConfigured
+	}
+	return s3Client.PutObject(ctx, key, contentType, data)
+}
+
+// UploadPrivate stores an object that is only readable through Download, such as a
+// document containing addresses
+func UploadPrivate(ctx context.Context, key, contentType string, data []byte) error {
+	if s3Client == nil {
+		return ErrNotConfigured
+	}
+	return s3Client.PutPrivateObject(ctx, key, contentType, data)
+}
+
+// Download reads an object stored with UploadPrivate
+func Download(ctx context.Context, key string) ([]byte, error) {
+	if s3Client == nil {
+		return nil, ErrNotConfigured
+	}
+	return s3Client.GetObject(ctx, key)
+}
+
+// Configured reports whether an object store is configured
+func Configured() bool {
+	return s3Client != nil
+}
@@ -0,0 +1,158 @@
+package tokens
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"secure-backend/outbound"
+	"strings"
+	"time"
+)
+
+// Key is a named HMAC signing key. The ID is written into the "kid" header of every
+// token signed with it so verification picks the right key after a rotation.
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// Keyring holds the key new tokens are signed with and the older keys that are still
+// accepted, which lets keys rotate without invalidating tokens already handed out
+type Keyring struct {
+	active Key
+	keys   map[string][]byte
+}
+
+// NewKeyring creates a keyring signing with active and also verifying with previous
+func NewKeyring(active Key, previous ...Key) *Keyring {
+	k := &Keyring{active: active, keys: map[string][]byte{active.ID: active.Secret}}
+	for _, key := range previous {
+		k.keys[key.ID] = key.Secret
+	}
+	return k
+}
+
+// ActiveKeyID returns the ID of the key new tokens are signed with
+func (k *Keyring) ActiveKeyID() string {
+	return k.active.ID
+}
+
+// lookup returns the secret of a signing key that is still accepted
+func (k *Keyring) lookup(id string) ([]byte, bool) {
+	secret, ok := k.keys[id]
+	return secret, ok
+}
+
+// parseKeys builds a keyring from kid → secret pairs; activeID selects the signing key
+func parseKeys(activeID string, secrets map[string]string) (*Keyring, error) {
+	activeSecret, ok := secrets[activeID]
+	if activeID == "" || !ok || activeSecret == "" {
+		return nil, fmt.Errorf("active signing key %q not found", activeID)
+	}
+
+	var previous []Key
+	for id, secret := range secrets {
+		if id != activeID && secret != "" {
+			previous = append(previous, Key{ID: id, Secret: []byte(secret)})
+		}
+	}
+	return NewKeyring(Key{ID: activeID, Secret: []byte(activeSecret)}, previous...), nil
+}
+
+// envKeyring reads TOKEN_SIGNING_KEYS ("kid:secret,kid:secret", the first key signs).
+// Without it EXPORT_SIGNING_SECRET is used, and failing that a random per-process key,
+// so tokens stop working after a restart.
+func envKeyring() (*Keyring, error) {
+	if value := os.Getenv("TOKEN_SIGNING_KEYS"); value != "" {
+		secrets := map[string]string{}
+		activeID := ""
+		for _, pair := range strings.Split(value, ",") {
+			id, secret, ok := strings.Cut(strings.TrimSpace(pair), ":")
+			if !ok || id == "" || secret == "" {
+				return nil, errors.New("TOKEN_SIGNING_KEYS must be a list of kid:secret pairs")
+			}
+			if activeID == "" {
+				activeID = id
+			}
+			secrets[id] = secret
+		}
+		return parseKeys(activeID, secrets)
+	}
+
+	if secret := os.Getenv("EXPORT_SIGNING_SECRET"); secret != "" {
+		return NewKeyring(Key{ID: "default", Secret: []byte(secret)}), nil
+	}
+
+	log.Println("TOKEN_SIGNING_KEYS not set, signed links and tokens will not survive a restart")
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return NewKeyring(Key{ID: "ephemeral", Secret: secret}), nil
+}
+
+// VaultSource loads signing keys from a Vault KV secret. The secret holds an "active_kid"
+// field naming the signing key; every other field is a kid → secret pair that is still
+// accepted. Rotating means adding a key, pointing active_kid at it and removing the old
+// key once the tokens it signed have expired.
+type VaultSource struct {
+	addr   string
+	token  string
+	path   string
+	client *http.Client
+}
+
+// NewVaultSource reads the secret at path (the API path after /v1/, e.g.
+// "secret/data/secureshop/token-keys" for KV v2) from the Vault server at addr
+func NewVaultSource(addr, token, path string) *VaultSource {
+	return &VaultSource{
+		addr:   strings.TrimRight(addr, "/"),
+		token:  token,
+		path:   strings.Trim(path, "/"),
+		client: outbound.NewClient("vault", 10*time.Second),
+	}
+}
+
+// Load fetches the current keyring
+func (v *VaultSource) Load(ctx context.Context) (*Keyring, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.addr+"/v1/"+v.path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("calling vault: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned %d reading %s", resp.StatusCode, v.path)
+	}
+
+	// KV v2 nests the secret under data.data, KV v1 directly under data
+	var body struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decoding vault response: %w", err)
+	}
+	var nested struct {
+		Data map[string]string `json:"data"`
+	}
+	secrets := map[string]string{}
+	if err := json.Unmarshal(body.Data, &nested); err == nil && nested.Data != nil {
+		secrets = nested.Data
+	} else if err := json.Unmarshal(body.Data, &secrets); err != nil {
+		return nil, fmt.Errorf("decoding vault secret: %w", err)
+	}
+
+	activeID := secrets["active_kid"]
+	delete(secrets, "active_kid")
+	return parseKeys(activeID, secrets)
+}
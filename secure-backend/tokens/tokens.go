@@ -0,0 +1,162 @@
+// Package tokens signs and verifies the tokens the shop issues itself (download links and
+// guest carts today; preview links, tracking and impersonation tokens as they are added).
+// Tokens are HS256 JWTs with a "kid" header naming the signing key and a "pur" claim binding them to
+// one purpose, so a token minted for one feature can't be replayed against another.
+package tokens
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Token purposes
+const (
+	PurposeJobDownload    = "job-download"
+	PurposeGuestCart      = "guest-cart"
+	PurposeCartShare      = "cart-share"
+	PurposeBreakGlass     = "break-glass"     // Bearer token of a break-glass admin login
+	PurposePaymentUpdate  = "payment-update"  // Link sent to a buyer whose payment failed
+	PurposeErasureConfirm = "erasure-confirm" // Link emailed to confirm an account erasure request
+	PurposeDemo           = "demo"            // Bearer token of a demo account session (DEMO_MODE only)
+)
+
+// ErrInvalidToken is returned for tokens with a bad signature, unknown key, wrong
+// purpose or subject, or that have expired
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// refreshInterval is how often keys are reloaded from Vault to pick up rotations
+const refreshInterval = 5 * time.Minute
+
+var (
+	mu      sync.RWMutex
+	keyring *Keyring
+)
+
+// claims are the registered claims plus the token purpose
+type claims struct {
+	Purpose string `json:"pur"`
+	jwt.RegisteredClaims
+}
+
+// Init loads the signing keys. With VAULT_ADDR, VAULT_TOKEN and TOKEN_KEYS_VAULT_PATH set,
+// keys come from Vault and are reloaded every few minutes until ctx is cancelled;
+// otherwise they are read from the environment (see envKeyring).
+func Init(ctx context.Context) error {
+	addr, path := os.Getenv("VAULT_ADDR"), os.Getenv("TOKEN_KEYS_VAULT_PATH")
+	if addr == "" || path == "" {
+		k, err := envKeyring()
+		if err != nil {
+			return err
+		}
+		SetKeyring(k)
+		return nil
+	}
+
+	source := NewVaultSource(addr, os.Getenv("VAULT_TOKEN"), path)
+	k, err := source.Load(ctx)
+	if err != nil {
+		return err
+	}
+	SetKeyring(k)
+
+	go func() {
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				k, err := source.Load(ctx)
+				if err != nil {
+					// Keep signing with the keys we have
+					log.Printf("Failed to refresh token signing keys: %v", err)
+					continue
+				}
+				SetKeyring(k)
+			}
+		}
+	}()
+	return nil
+}
+
+// SetKeyring replaces the signing keys (Init calls it on every reload; tests use it directly)
+func SetKeyring(k *Keyring) {
+	mu.Lock()
+	defer mu.Unlock()
+	keyring = k
+}
+
+// current returns the keyring, loading it from the environment if Init was not called
+func current() *Keyring {
+	mu.RLock()
+	k := keyring
+	mu.RUnlock()
+	if k != nil {
+		return k
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if keyring == nil {
+		k, err := envKeyring()
+		if err != nil {
+			log.Fatalf("Failed to load token signing keys: %v", err)
+		}
+		keyring = k
+	}
+	return keyring
+}
+
+// Sign issues a token for purpose about subject (e.g. a job ID) that expires at expiresAt
+func Sign(purpose, subject string, expiresAt time.Time) (string, error) {
+	k := current()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims{
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	})
+	token.Header["kid"] = k.active.ID
+	return token.SignedString(k.active.Secret)
+}
+
+// Verify checks a token's signature, expiry (against now), purpose and subject
+func Verify(tokenString, purpose, subject string, now time.Time) error {
+	sub, err := Subject(tokenString, purpose, now)
+	if err != nil || sub != subject {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// Subject checks a token's signature, expiry (against now) and purpose, and returns the
+// subject it was issued for. It is for tokens that identify their subject (e.g. a guest
+// cart) rather than authorize access to one the caller names.
+func Subject(tokenString, purpose string, now time.Time) (string, error) {
+	k := current()
+	var c claims
+	_, err := jwt.ParseWithClaims(tokenString, &c, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		secret, ok := k.lookup(kid)
+		if !ok {
+			return nil, ErrInvalidToken
+		}
+		return secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(func() time.Time { return now }),
+	)
+	if err != nil || c.Purpose != purpose || c.Subject == "" {
+		return "", ErrInvalidToken
+	}
+	return c.Subject, nil
+}
@@ -0,0 +1,83 @@
+package tokens
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotationKeepsOldTokensValid(t *testing.T) {
+	now := time.Now()
+	oldKey := Key{ID: "2026-09", Secret: []byte("old-secret")}
+	newKey := Key{ID: "2026-10", Secret: []byte("new-secret")}
+
+	SetKeyring(NewKeyring(oldKey))
+	issuedBefore, err := Sign(PurposeJobDownload, "job-1", now.Add(time.Hour))
+	require.NoError(t, err)
+
+	// Rotate: sign with the new key, still accept the old one
+	SetKeyring(NewKeyring(newKey, oldKey))
+	issuedAfter, err := Sign(PurposeJobDownload, "job-1", now.Add(time.Hour))
+	require.NoError(t, err)
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(issuedAfter, &claims{})
+	require.NoError(t, err)
+	assert.Equal(t, "2026-10", parsed.Header["kid"])
+
+	assert.NoError(t, Verify(issuedBefore, PurposeJobDownload, "job-1", now))
+	assert.NoError(t, Verify(issuedAfter, PurposeJobDownload, "job-1", now))
+
+	// Once the old key is retired its tokens stop working
+	SetKeyring(NewKeyring(newKey))
+	assert.ErrorIs(t, Verify(issuedBefore, PurposeJobDownload, "job-1", now), ErrInvalidToken)
+	assert.NoError(t, Verify(issuedAfter, PurposeJobDownload, "job-1", now))
+
+	// Tokens are bound to their purpose, subject and expiry
+	assert.ErrorIs(t, Verify(issuedAfter, "impersonation", "job-1", now), ErrInvalidToken)
+	assert.ErrorIs(t, Verify(issuedAfter, PurposeJobDownload, "job-2", now), ErrInvalidToken)
+	assert.ErrorIs(t, Verify(issuedAfter, PurposeJobDownload, "job-1", now.Add(2*time.Hour)), ErrInvalidToken)
+}
+
+func TestVaultSourceReadsKVv2Secret(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/secret/data/token-keys", r.URL.Path)
+		assert.Equal(t, "vault-token", r.Header.Get("X-Vault-Token"))
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"data": map[string]string{"active_kid": "k2", "k1": "one", "k2": "two"},
+			},
+		})
+	}))
+	defer server.Close()
+
+	k, err := NewVaultSource(server.URL, "vault-token", "secret/data/token-keys").Load(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "k2", k.ActiveKeyID())
+	secret, ok := k.lookup("k1")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("one"), secret)
+}
+
+func TestSubjectReturnsTokenSubject(t *testing.T) {
+	now := time.Now()
+	SetKeyring(NewKeyring(Key{ID: "k1", Secret: []byte("secret")}))
+
+	token, err := Sign(PurposeGuestCart, "cart-1", now.Add(time.Hour))
+	require.NoError(t, err)
+
+	subject, err := Subject(token, PurposeGuestCart, now)
+	require.NoError(t, err)
+	assert.Equal(t, "cart-1", subject)
+
+	_, err = Subject(token, PurposeJobDownload, now)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = Subject(token, PurposeGuestCart, now.Add(2*time.Hour))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
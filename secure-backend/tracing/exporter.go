@@ -0,0 +1,330 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// queueSize caps the spans waiting for export; spans ended while it is full are dropped
+	queueSize = 2048
+	// batchSize is the most spans sent in one export request
+	batchSize = 512
+	// flushInterval is how often queued spans are exported when fewer than a batch wait
+	flushInterval = 5 * time.Second
+	// exportTimeout bounds one export request to the collector
+	exportTimeout = 10 * time.Second
+	// defaultServiceName names this service in traces unless OTEL_SERVICE_NAME is set
+	defaultServiceName = "secure-backend"
+)
+
+// exporter sends ended spans to the collector in batches
+type exporter struct {
+	endpoint    string
+	headers     map[string]string
+	serviceName string
+	ratio       float64
+	client      *http.Client
+
+	queue   chan *Span
+	stop    chan struct{}
+	done    chan struct{}
+	dropped atomic.Uint64
+}
+
+var (
+	mu     sync.RWMutex
+	active *exporter
+)
+
+// Init turns tracing on when OTEL_EXPORTER_OTLP_TRACES_ENDPOINT (used as is) or
+// OTEL_EXPORTER_OTLP_ENDPOINT (with /v1/traces appended) names an OTLP/HTTP collector.
+// OTEL_EXPORTER_OTLP_HEADERS adds headers such as API keys ("key=value,key2=value2"),
+// OTEL_SERVICE_NAME names the service and OTEL_TRACES_SAMPLER_ARG sets the ratio of new
+// traces recorded (default 1). Traces continued from a caller follow its sampling decision.
+// Spans are exported in the background until Shutdown.
+func Init() {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); base != "" {
+			endpoint = strings.TrimRight(base, "/") + "/v1/traces"
+		}
+	}
+	if endpoint == "" {
+		return
+	}
+
+	serviceName := os.Getenv("OTEL_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	ratio := 1.0
+	if value := os.Getenv("OTEL_TRACES_SAMPLER_ARG"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			log.Printf("Invalid OTEL_TRACES_SAMPLER_ARG %q, recording every trace", value)
+		} else {
+			ratio = parsed
+		}
+	}
+
+	e := &exporter{
+		endpoint:    endpoint,
+		headers:     parseHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")),
+		serviceName: serviceName,
+		ratio:       ratio,
+		// Not an outbound.NewClient: exports would otherwise be logged and traced themselves
+		client: &http.Client{Timeout: exportTimeout},
+		queue:  make(chan *Span, queueSize),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go e.run()
+
+	mu.Lock()
+	active = e
+	mu.Unlock()
+	log.Printf("Tracing enabled, exporting spans to %s", endpoint)
+}
+
+// Enabled reports whether spans are being recorded
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return active != nil
+}
+
+// Shutdown turns tracing off and exports the spans still queued, waiting until ctx is done
+func Shutdown(ctx context.Context) error {
+	mu.Lock()
+	e := active
+	active = nil
+	mu.Unlock()
+	if e == nil {
+		return nil
+	}
+
+	close(e.stop)
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sampleRatio returns the ratio of new traces recorded
+func sampleRatio() float64 {
+	mu.RLock()
+	defer mu.RUnlock()
+	if active == nil {
+		return 0
+	}
+	return active.ratio
+}
+
+// enqueue queues an ended span for export, dropping it if the queue is full
+func enqueue(s *Span) {
+	mu.RLock()
+	e := active
+	mu.RUnlock()
+	if e == nil {
+		return
+	}
+
+	select {
+	case e.queue <- s:
+	default:
+		if e.dropped.Add(1)%1000 == 1 {
+			log.Printf("Tracing queue full, dropped %d spans so far", e.dropped.Load())
+		}
+	}
+}
+
+// run exports spans in batches until stopped, then exports what is left
+func (e *exporter) run() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.export(batch); err != nil {
+			log.Printf("Failed to export %d spans: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case span := <-e.queue:
+			batch = append(batch, span)
+			if len(batch) == batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.stop:
+			for {
+				select {
+				case span := <-e.queue:
+					batch = append(batch, span)
+					if len(batch) == batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// export posts spans to the collector as OTLP JSON
+func (e *exporter) export(spans []*Span) error {
+	body, err := json.Marshal(encodeSpans(e.serviceName, spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// parseHeaders reads OTEL_EXPORTER_OTLP_HEADERS ("key=value,key2=value2")
+func parseHeaders(value string) map[string]string {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		key, val, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			continue
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+	return headers
+}
+
+// OTLP JSON request body (ExportTraceServiceRequest)
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              Kind            `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"` // 0 unset, 2 error
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+// encodeSpans builds the OTLP JSON body for spans of this service
+func encodeSpans(serviceName string, spans []*Span) otlpRequest {
+	encoded := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		s.mu.Lock()
+		span := otlpSpan{
+			TraceID:           hex.EncodeToString(s.sc.TraceID[:]),
+			SpanID:            hex.EncodeToString(s.sc.SpanID[:]),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		}
+		if s.parent != [8]byte{} {
+			span.ParentSpanID = hex.EncodeToString(s.parent[:])
+		}
+		for _, attr := range s.attributes {
+			span.Attributes = append(span.Attributes, encodeAttribute(attr.key, attr.value))
+		}
+		if s.failed {
+			span.Status = otlpStatus{Code: 2, Message: s.errMessage}
+		}
+		s.mu.Unlock()
+		encoded = append(encoded, span)
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpAttribute{encodeAttribute("service.name", serviceName)}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "secure-backend/tracing"}, Spans: encoded}},
+	}}}
+}
+
+// encodeAttribute wraps a value in the OTLP AnyValue of its type
+func encodeAttribute(key string, value interface{}) otlpAttribute {
+	var v map[string]interface{}
+	switch value := value.(type) {
+	case int64:
+		v = map[string]interface{}{"intValue": strconv.FormatInt(value, 10)}
+	case float64:
+		v = map[string]interface{}{"doubleValue": value}
+	case bool:
+		v = map[string]interface{}{"boolValue": value}
+	default:
+		v = map[string]interface{}{"stringValue": fmt.Sprint(value)}
+	}
+	return otlpAttribute{Key: key, Value: v}
+}
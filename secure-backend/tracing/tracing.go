@@ -0,0 +1,229 @@
+// Package tracing records OpenTelemetry spans for API requests, database calls and outbound
+// integration calls and exports them to an OTLP/HTTP collector. Trace context is read from
+// and passed on in W3C traceparent headers, so spans join the traces of callers and of the
+// services called. Tracing is off until Init finds an OTLP endpoint in the environment.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceparentHeader is the W3C Trace Context header carrying the caller's trace
+const TraceparentHeader = "traceparent"
+
+// Kind says what a span measures
+type Kind int
+
+// Span kinds, numbered as in OTLP
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2 // Handling an API request
+	KindClient   Kind = 3 // Calling the database or an integration
+)
+
+// SpanContext identifies a span within its trace
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+// IsValid reports whether both IDs are set (all-zero IDs are invalid)
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Traceparent formats the span context as a version 00 traceparent header value
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]), flags)
+}
+
+// ParseTraceparent reads a traceparent header value. ok is false for malformed values and
+// all-zero IDs, in which case a new trace should be started.
+func ParseTraceparent(value string) (sc SpanContext, ok bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return SpanContext{}, false
+	}
+	// Version 00 has exactly four fields; later versions may append more
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}, false
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, sc.IsValid()
+}
+
+// attribute is a key and a string, int64, float64 or bool value
+type attribute struct {
+	key   string
+	value interface{}
+}
+
+// Span is one timed operation of a trace. All methods are safe on a nil Span, which is
+// what Start returns while tracing is off.
+type Span struct {
+	name   string
+	kind   Kind
+	sc     SpanContext
+	parent [8]byte
+	start  time.Time
+
+	mu         sync.Mutex
+	end        time.Time
+	attributes []attribute
+	errMessage string
+	failed     bool
+	ended      bool
+}
+
+// Context returns the span's IDs for propagation
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetAttribute records a string, integer, float or boolean attribute on the span; other
+// values are recorded with their fmt formatting
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil || !s.sc.Sampled {
+		return
+	}
+	switch v := value.(type) {
+	case string, int64, float64, bool:
+	case int:
+		value = int64(v)
+	default:
+		value = fmt.Sprint(v)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attributes = append(s.attributes, attribute{key: key, value: value})
+}
+
+// SetError marks the span as failed with err's message; a nil err does nothing
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed = true
+	s.errMessage = err.Error()
+}
+
+// End finishes the span and queues it for export. Later calls do nothing.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	if s.sc.Sampled {
+		enqueue(s)
+	}
+}
+
+type contextKey int
+
+const (
+	spanKey contextKey = iota
+	remoteKey
+)
+
+// FromContext returns the span started with ctx, or nil
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey).(*Span)
+	return span
+}
+
+// WithRemoteParent makes sc, read from a caller's traceparent header, the parent of the
+// next span started with the returned context
+func WithRemoteParent(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey, sc)
+}
+
+// Start begins a span that is a child of the span in ctx or of the remote parent set with
+// WithRemoteParent, or starts a new trace. It returns nil (and ctx unchanged) while tracing
+// is off. End the span when the operation finishes.
+func Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	if !Enabled() {
+		return ctx, nil
+	}
+
+	span := &Span{name: name, kind: kind, start: time.Now()}
+	if parent := FromContext(ctx); parent != nil {
+		span.sc.TraceID = parent.sc.TraceID
+		span.sc.Sampled = parent.sc.Sampled
+		span.parent = parent.sc.SpanID
+	} else if remote, ok := ctx.Value(remoteKey).(SpanContext); ok && remote.IsValid() {
+		span.sc.TraceID = remote.TraceID
+		span.sc.Sampled = remote.Sampled
+		span.parent = remote.SpanID
+	} else {
+		rand.Read(span.sc.TraceID[:])
+		span.sc.Sampled = sampleRoot(span.sc.TraceID)
+	}
+	rand.Read(span.sc.SpanID[:])
+
+	return context.WithValue(ctx, spanKey, span), span
+}
+
+// StartChild is Start for operations only worth tracing as part of a larger one, such as
+// database calls: it returns nil unless ctx already carries a span
+func StartChild(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	if FromContext(ctx) == nil {
+		return ctx, nil
+	}
+	return Start(ctx, name, kind)
+}
+
+// sampleRoot decides whether a new trace is recorded, keeping the configured ratio of traces.
+// The decision is derived from the trace ID so services sampling at the same ratio agree.
+func sampleRoot(traceID [16]byte) bool {
+	ratio := sampleRatio()
+	if ratio >= 1 {
+		return true
+	}
+	if ratio <= 0 {
+		return false
+	}
+	var x uint64
+	for _, b := range traceID[8:] {
+		x = x<<8 | uint64(b)
+	}
+	return x>>1 < uint64(ratio*math.MaxInt64)
+}
@@ -0,0 +1,149 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	sc, ok := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.True(t, ok)
+	assert.True(t, sc.Sampled)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", sc.Traceparent())
+
+	sc, ok = ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	require.True(t, ok)
+	assert.False(t, sc.Sampled)
+
+	// Later versions may add fields
+	_, ok = ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra")
+	assert.True(t, ok)
+
+	for _, value := range []string{
+		"",
+		"garbage",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-zzf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	} {
+		_, ok := ParseTraceparent(value)
+		assert.False(t, ok, value)
+	}
+}
+
+func TestStartIsNoopWhileDisabled(t *testing.T) {
+	ctx, span := Start(context.Background(), "op", KindInternal)
+	assert.Nil(t, span)
+	assert.Nil(t, FromContext(ctx))
+
+	// Nil spans are safe to use
+	span.SetAttribute("key", "value")
+	span.SetError(errors.New("boom"))
+	span.End()
+	assert.False(t, span.Context().IsValid())
+}
+
+func TestSpansAreExported(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received otlpRequest
+		header   string
+	)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		header = r.Header.Get("X-Api-Key")
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer collector.Close()
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", collector.URL+"/")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "X-Api-Key=secret")
+	t.Setenv("OTEL_SERVICE_NAME", "shop-test")
+	Init()
+	require.True(t, Enabled())
+
+	remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, server := Start(WithRemoteParent(context.Background(), remote), "GET /api/products", KindServer)
+	server.SetAttribute("http.response.status_code", 200)
+
+	// Child spans join the trace; StartChild needs a span in the context
+	_, query := StartChild(ctx, "db SELECT", KindClient)
+	query.SetError(errors.New("canceled"))
+	query.End()
+	_, orphan := StartChild(context.Background(), "db SELECT", KindClient)
+	assert.Nil(t, orphan)
+	server.End()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, Shutdown(shutdownCtx))
+	assert.False(t, Enabled())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, "secret", header)
+	require.Len(t, received.ResourceSpans, 1)
+	assert.Equal(t, "shop-test", received.ResourceSpans[0].Resource.Attributes[0].Value["stringValue"])
+	spans := received.ResourceSpans[0].ScopeSpans[0].Spans
+	require.Len(t, spans, 2)
+
+	child, parent := spans[0], spans[1]
+	assert.Equal(t, "db SELECT", child.Name)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", parent.TraceID)
+	assert.Equal(t, "00f067aa0ba902b7", parent.ParentSpanID)
+	assert.Equal(t, parent.TraceID, child.TraceID)
+	assert.Equal(t, parent.SpanID, child.ParentSpanID)
+	assert.Equal(t, 2, child.Status.Code)
+	assert.Equal(t, "canceled", child.Status.Message)
+	assert.Equal(t, "http.response.status_code", parent.Attributes[0].Key)
+	assert.Equal(t, "200", parent.Attributes[0].Value["intValue"])
+}
+
+func TestUnsampledTracesAreNotExported(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("unsampled span exported")
+	}))
+	defer collector.Close()
+
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", collector.URL)
+	Init()
+
+	remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	_, span := Start(WithRemoteParent(context.Background(), remote), "op", KindServer)
+	require.NotNil(t, span, "unsampled spans still carry IDs to pass on")
+	assert.False(t, span.Context().Sampled)
+	span.End()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, Shutdown(ctx))
+}
+
+func TestSampleRoot(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "http://127.0.0.1:1/v1/traces")
+	t.Setenv("OTEL_TRACES_SAMPLER_ARG", "0.25")
+	Init()
+	defer Shutdown(context.Background())
+
+	sampled := 0
+	for i := 0; i < 4000; i++ {
+		if _, span := Start(context.Background(), "op", KindInternal); span.Context().Sampled {
+			sampled++
+		}
+	}
+	assert.InDelta(t, 1000, sampled, 150)
+}
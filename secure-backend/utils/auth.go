@@ -60,3 +60,13 @@ func IsAdmin(c *gin.Context) bool {
 	user, err := GetAuthUser(c)
 	return err == nil && user.Role == "admin"
 }
+
+// GetClientInfo returns the client details parsed by the ClientInfo middleware
+func GetClientInfo(c *gin.Context) *models.ClientInfo {
+	if infoAny, exists := c.Get("client_info"); exists {
+		if info, ok := infoAny.(*models.ClientInfo); ok {
+			return info
+		}
+	}
+	return &models.ClientInfo{Platform: "unknown"}
+}
@@ -106,6 +106,50 @@ func SanitizeProductDescription(description string) string {
 	return SanitizeInput(description, DefaultDescriptionOptions)
 }
 
+// SanitizeAltText sanitizes image alternative text
+func SanitizeAltText(alt string) string {
+	return SanitizeInput(alt, SanitizationOptions{
+		TrimWhitespace: true,
+		EscapeHTML:     true,
+		RemoveNewlines: true,
+		MaxLength:      250,
+		PreserveSpaces: true,
+	})
+}
+
+// SanitizeMetaTitle sanitizes the search engine title of a product
+func SanitizeMetaTitle(title string) string {
+	return SanitizeInput(title, SanitizationOptions{
+		TrimWhitespace: true,
+		EscapeHTML:     true,
+		RemoveNewlines: true,
+		MaxLength:      70,
+		PreserveSpaces: true,
+	})
+}
+
+// SanitizeShelfLocation sanitizes where a seller keeps a product, such as an aisle and bin code
+func SanitizeShelfLocation(location string) string {
+	return SanitizeInput(location, SanitizationOptions{
+		TrimWhitespace: true,
+		EscapeHTML:     true,
+		RemoveNewlines: true,
+		MaxLength:      50,
+		PreserveSpaces: true,
+	})
+}
+
+// SanitizeMetaDescription sanitizes the search engine description of a product
+func SanitizeMetaDescription(description string) string {
+	return SanitizeInput(description, SanitizationOptions{
+		TrimWhitespace: true,
+		EscapeHTML:     true,
+		RemoveNewlines: true,
+		MaxLength:      160,
+		PreserveSpaces: true,
+	})
+}
+
 // SanitizeEmail sanitizes email addresses
 func SanitizeEmail(email string) string {
 	sanitized := SanitizeInput(email, DefaultEmailOptions)
@@ -144,6 +188,29 @@ func IsValidProductStatus(status string) bool {
 	return validStatuses[strings.ToLower(strings.TrimSpace(status))]
 }
 
+// IsValidFulfillmentStatus validates order item fulfillment status values
+func IsValidFulfillmentStatus(status string) bool {
+	validStatuses := map[string]bool{
+		"pending":   true,
+		"shipped":   true,
+		"fulfilled": true,
+	}
+	return validStatuses[strings.ToLower(strings.TrimSpace(status))]
+}
+
+// slugSeparators matches runs of characters that are not allowed in slugs
+var slugSeparators = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Slugify turns a display name into a URL-safe slug ("Home & Garden" -> "home-garden")
+func Slugify(name string) string {
+	slug := slugSeparators.ReplaceAllString(strings.ToLower(name), "-")
+	slug = strings.Trim(slug, "-")
+	if len(slug) > 50 {
+		slug = strings.TrimRight(slug[:50], "-")
+	}
+	return slug
+}
+
 // IsValidUserRole validates user role values
 func IsValidUserRole(role string) bool {
 	validRoles := map[string]bool{